@@ -0,0 +1,190 @@
+package main
+
+import (
+	"context"
+	"io"
+	"log"
+	"strings"
+	"time"
+	"url-shortener/db"
+	"url-shortener/events"
+	"url-shortener/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mssola/useragent"
+)
+
+// Device types reported in click breakdowns
+const (
+	deviceDesktop = "desktop"
+	deviceMobile  = "mobile"
+	deviceTablet  = "tablet"
+	unknownValue  = "unknown"
+)
+
+// parseClick extracts the device type, browser family and OS from a User-Agent
+func parseClick(userAgent string) db.Click {
+	ua := useragent.New(userAgent)
+
+	click := db.Click{Device: deviceDesktop, Browser: unknownValue, OS: unknownValue}
+
+	// The parser has no notion of tablets; iPads and Android devices without
+	// the "Mobile" token are the common cases
+	lower := strings.ToLower(userAgent)
+	switch {
+	case strings.Contains(lower, "ipad") || strings.Contains(lower, "tablet") ||
+		(strings.Contains(lower, "android") && !strings.Contains(lower, "mobile")):
+		click.Device = deviceTablet
+	case ua.Mobile():
+		click.Device = deviceMobile
+	}
+
+	if name, _ := ua.Browser(); name != "" {
+		click.Browser = name
+	}
+	if name := ua.OSInfo().Name; name != "" {
+		click.OS = name
+	}
+
+	return click
+}
+
+// visitorCountry reads the visitor's country code from the GEO_COUNTRY_HEADER
+// set by the CDN or proxy, dropping values that are not two letters
+func visitorCountry(c *gin.Context) string {
+	country := strings.ToUpper(c.GetHeader(countryHeader))
+	if len(country) != 2 || country[0] < 'A' || country[0] > 'Z' || country[1] < 'A' || country[1] > 'Z' {
+		return ""
+	}
+	return country
+}
+
+// visitorCookie pins a browser's visitor ID, so unique clicks still count it
+// once after its IP changes
+const visitorCookie = "vid"
+
+// visitorCookieMaxAge is how long a browser is recognized as the same visitor
+const visitorCookieMaxAge = 365 * 24 * 60 * 60
+
+// visitorCookieEnabled is the VISITOR_COOKIE setting
+var visitorCookieEnabled bool
+
+// visitorID identifies the visitor of a redirect for unique click counting.
+// Visitors are told apart by a keyed hash of their IP and User-Agent, which
+// is also stored in a first-party cookie when enabled, so a returning browser
+// keeps its ID.
+func visitorID(c *gin.Context) string {
+	if !visitorCookieEnabled {
+		return hashIP(c.ClientIP() + "|" + c.Request.UserAgent())
+	}
+	if id, err := c.Cookie(visitorCookie); err == nil && len(id) == 32 {
+		return id
+	}
+	id := hashIP(c.ClientIP() + "|" + c.Request.UserAgent())
+	c.SetCookie(visitorCookie, id, visitorCookieMaxAge, "/", "", isSecureRequest(c), true)
+	return id
+}
+
+// urlStats is the stats response: the link plus its click breakdown
+type urlStats struct {
+	models.URL
+	Breakdown *db.ClickBreakdown `json:"breakdown"`
+	// Variants carries per-variant clicks and conversions for A/B tested links
+	Variants []db.VariantStats `json:"variants,omitempty"`
+	// Rotation carries per-destination clicks for links rotating through destinations
+	Rotation []db.RotationStats `json:"rotation,omitempty"`
+	// Conversions carries the conversions reported per goal for links tracking them
+	Conversions []db.ConversionStats `json:"conversions,omitempty"`
+}
+
+// publishClick hands a url.clicked event to live stats streams and, when
+// configured, the external event publisher. suspicious is the reason click
+// fraud detection flagged the click, if it did.
+func publishClick(c *gin.Context, link *db.URL, click db.Click, bot bool, suspicious string) {
+	shortCode := link.ShortCode
+	event := events.New(events.URLClicked, events.ClickData{
+		ShortCode:  shortCode,
+		ClickID:    click.ClickID,
+		Referrer:   c.Request.Referer(),
+		UserAgent:  c.Request.UserAgent(),
+		Country:    click.Country,
+		Device:     click.Device,
+		Browser:    click.Browser,
+		OS:         click.OS,
+		Bot:        bot,
+		Suspicious: suspicious,
+		Campaign:   link.Campaign,
+	})
+
+	clickHub.Publish(c.Request.Context(), shortCode, event)
+
+	if eventPublisher == nil {
+		return
+	}
+
+	// The request context ends with the redirect, before the async write completes
+	if err := eventPublisher.Publish(context.Background(), shortCode, event); err != nil {
+		log.Printf("Failed to publish click event for %s: %v", shortCode, err)
+	}
+}
+
+// statsStreamInterval is how often a stats stream sends refreshed counters
+const statsStreamInterval = 5 * time.Second
+
+// clickHub delivers this instance's click events to live stats streams
+var clickHub = events.NewHub()
+
+// streamURLStats pushes click events for a link as they happen, plus periodic
+// counters read from the database, over Server-Sent Events
+func streamURLStats(c *gin.Context) {
+	shortCode := c.Param("shortCode")
+
+	url, err := database.GetURLByShortCode(c.Request.Context(), shortCode)
+	if err != nil {
+		respondError(c, notFound(err, "Short URL not found"))
+		return
+	}
+
+	clicks, unsubscribe := clickHub.Subscribe(url.ShortCode)
+	defer unsubscribe()
+
+	ticker := time.NewTicker(statsStreamInterval)
+	defer ticker.Stop()
+
+	c.Header("Cache-Control", "no-cache")
+	c.Header("X-Accel-Buffering", "no")
+
+	sendCounters := func() bool {
+		current, err := database.GetURLByShortCode(c.Request.Context(), shortCode)
+		if err != nil {
+			return false
+		}
+		lastMinute, err := database.CountRecentClicks(c.Request.Context(), current.ID, time.Minute)
+		if err != nil {
+			return false
+		}
+		c.SSEvent("stats", gin.H{
+			"accessCount": current.Clicks,
+			"botClicks":   current.BotClicks,
+			"lastMinute":  lastMinute,
+		})
+		return true
+	}
+
+	if !sendCounters() {
+		return
+	}
+	c.Writer.Flush()
+
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case <-c.Request.Context().Done():
+			return false
+		case event := <-clicks:
+			c.SSEvent(string(event.Type), event)
+			return true
+		case <-ticker.C:
+			return sendCounters()
+		}
+	})
+}
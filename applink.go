@@ -0,0 +1,126 @@
+package main
+
+import (
+	"html/template"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+	"url-shortener/db"
+	"url-shortener/pkg/apierror"
+
+	"github.com/gin-gonic/gin"
+)
+
+// appLinkFallbackDelay is how long the app link page waits for the app to
+// open before sending the visitor to the fallback
+var appLinkFallbackDelay = 1500 * time.Millisecond
+
+// webSchemes cannot be used as app link URIs: they either open in the browser
+// rather than an app or run code in the page
+var webSchemes = []string{"http", "https", "javascript", "data", "vbscript", "file", "blob", "about"}
+
+// isAbsoluteURL reports whether s parses as a URL with a scheme, including
+// app deep links such as market://details?id=...
+func isAbsoluteURL(s string) bool {
+	u, err := url.Parse(s)
+	return err == nil && u.Scheme != "" && (u.Host != "" || u.Opaque != "" || u.Path != "")
+}
+
+// normalizeAppLink validates an app link: a URI with an app's own scheme and
+// optional http(s) app store URLs. An empty URI means no app link.
+func normalizeAppLink(link db.AppLink) (*db.AppLink, bool) {
+	link.URI = strings.TrimSpace(link.URI)
+	if link.URI == "" {
+		return nil, true
+	}
+
+	u, err := url.Parse(link.URI)
+	if err != nil || !isAbsoluteURL(link.URI) || slices.Contains(webSchemes, strings.ToLower(u.Scheme)) {
+		return nil, false
+	}
+	for _, store := range []string{link.IOSStore, link.AndroidStore} {
+		if store != "" && !validDestination(store) {
+			return nil, false
+		}
+	}
+	return &link, true
+}
+
+// openAppLink serves iOS and Android visitors of a link with an app link a
+// page that tries to open the app, falling back to the app store for their
+// platform or else destination when the app does not open. It reports
+// whether the page was served; links whose fallback is not an http(s) URL,
+// stored before destinations were checked, get a plain redirect instead.
+func openAppLink(c *gin.Context, link *db.URL, destination string) bool {
+	if link.AppLink == nil {
+		return false
+	}
+
+	fallback := destination
+	switch mobilePlatform(c.Request.UserAgent()) {
+	case platformIOS:
+		if link.AppLink.IOSStore != "" {
+			fallback = link.AppLink.IOSStore
+		}
+	case platformAndroid:
+		if link.AppLink.AndroidStore != "" {
+			fallback = link.AppLink.AndroidStore
+		}
+	default:
+		return false
+	}
+	if !validDestination(fallback) {
+		return false
+	}
+
+	// The page is a redirect of its own, so it must not be reused for
+	// later clicks
+	c.Header("Cache-Control", "no-store")
+	c.HTML(http.StatusOK, "applink.html", gin.H{
+		// The URI's scheme was checked against webSchemes when stored;
+		// template.URL keeps the app's custom scheme from being filtered
+		// out of the link
+		"appURI":   template.URL(link.AppLink.URI),
+		"fallback": fallback,
+		"delay":    appLinkFallbackDelay.Milliseconds(),
+	})
+	return true
+}
+
+// setURLAppLink sets the app URI iOS and Android visitors are sent to, with
+// the app store URLs they fall back to when the app is not installed; an
+// empty URI removes the app link.
+func setURLAppLink(c *gin.Context) {
+	shortCode := c.Param("shortCode")
+
+	var request db.AppLink
+	if err := c.ShouldBindJSON(&request); err != nil {
+		respondError(c, apierror.Validation("Invalid request body"))
+		return
+	}
+
+	appLink, ok := normalizeAppLink(request)
+	if !ok {
+		respondError(c, apierror.Validation("Invalid app link: uri needs an app scheme and store URLs must be http(s)"))
+		return
+	}
+	if appLink != nil && !allowedDestinations(c, appLink.URI, appLink.IOSStore, appLink.AndroidStore) {
+		return
+	}
+
+	previous, err := database.GetURLByShortCode(c.Request.Context(), shortCode)
+	if err != nil {
+		respondError(c, notFound(err, "Short URL not found"))
+		return
+	}
+
+	if err := database.SetAppLink(c.Request.Context(), shortCode, appLink); err != nil {
+		respondError(c, notFound(err, "Short URL not found"))
+		return
+	}
+
+	recordAudit(c, auditUpdate, "url", shortCode, gin.H{"appLink": previous.AppLink}, gin.H{"appLink": appLink})
+	c.JSON(http.StatusOK, gin.H{"message": "App link updated successfully", "appLink": appLink})
+}
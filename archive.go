@@ -0,0 +1,32 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync/atomic"
+	"time"
+	"url-shortener/db"
+	"url-shortener/models"
+	"url-shortener/pkg/objectstore"
+)
+
+var objectStore objectstore.Store
+var archiveOnDelete atomic.Bool
+
+// archiveURL writes the full record of a link to object storage before it is removed
+func archiveURL(ctx context.Context, record *db.URL) error {
+	now := time.Now()
+	archived := models.ArchivedURL{
+		URL:        toURLModel(nil, record),
+		ArchivedAt: now,
+	}
+
+	data, err := json.MarshalIndent(archived, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	key := fmt.Sprintf("archive/urls/%s/%d.json", record.ShortCode, now.Unix())
+	return objectStore.Put(ctx, key, data, "application/json")
+}
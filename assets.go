@@ -0,0 +1,66 @@
+package main
+
+import (
+	"embed"
+	"errors"
+	"io/fs"
+	"os"
+)
+
+// The pages, emails and static files are compiled into the binary, so it
+// runs without the source tree next to it. Each can be overridden from disk.
+var (
+	//go:embed templates/*.html
+	defaultTemplates embed.FS
+
+	//go:embed emails/*.html
+	defaultEmails embed.FS
+
+	//go:embed static
+	defaultStatic embed.FS
+)
+
+// overlayFS serves a file from override when it exists there and from base
+// otherwise. Directories are reported as missing, so they are never listed.
+type overlayFS struct {
+	override fs.FS
+	base     fs.FS
+}
+
+func (o overlayFS) Open(name string) (fs.File, error) {
+	if o.override != nil {
+		if f, err := openFile(o.override, name); err == nil {
+			return f, nil
+		}
+	}
+	return openFile(o.base, name)
+}
+
+func openFile(fsys fs.FS, name string) (fs.File, error) {
+	f, err := fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	info, err := f.Stat()
+	if err == nil && info.IsDir() {
+		err = &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return f, nil
+}
+
+// staticFiles returns the built-in static files, overlaid with STATIC_DIR when set
+func staticFiles(dir string) fs.FS {
+	base, err := fs.Sub(defaultStatic, "static")
+	if err != nil {
+		panic(errors.New("static files are not embedded"))
+	}
+	var override fs.FS
+	if dir != "" {
+		override = os.DirFS(dir)
+	}
+	return overlayFS{override: override, base: base}
+}
@@ -0,0 +1,20 @@
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+	"url-shortener/pkg/backup"
+)
+
+// runBackup dumps the link and analytics tables to object storage. It is
+// scheduled every BACKUP_INTERVAL; enable it on a single instance only.
+func runBackup(ctx context.Context) error {
+	started := time.Now()
+	prefix, err := backup.Create(ctx, database, objectStore)
+	if err != nil {
+		return err
+	}
+	log.Printf("Backup written to %s in %s", prefix, time.Since(started).Round(time.Millisecond))
+	return nil
+}
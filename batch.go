@@ -0,0 +1,255 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+	"url-shortener/db"
+	"url-shortener/pkg/apierror"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxBatchLinks bounds how many links one batch request changes
+const maxBatchLinks = 1000
+
+// Outcomes of a batch operation for one link
+const (
+	batchUpdated   = "updated"
+	batchDeleted   = "deleted"
+	batchNotFound  = "not_found"
+	batchForbidden = "forbidden"
+	batchLocked    = "locked"
+	batchFailed    = "failed"
+)
+
+// batchResult reports what a batch operation did to one link
+type batchResult struct {
+	ShortCode string `json:"shortCode"`
+	Status    string `json:"status"`
+	Error     string `json:"error,omitempty"`
+}
+
+// batchSelection is the part of a batch request picking its links
+type batchSelection struct {
+	ShortCodes []string `json:"shortCodes"`
+	Filter     struct {
+		Tag        string `json:"tag"`
+		CampaignID int    `json:"campaignId"`
+	} `json:"filter"`
+}
+
+// selectBatchLinks loads the links a batch request picks, writing an error
+// when the selection is missing, too large or names a campaign the caller
+// can't see. Every link is checked like a single-link write: the ones the
+// caller may not change are reported as forbidden, and requested short codes
+// without a link as not found.
+func selectBatchLinks(c *gin.Context, request batchSelection) ([]db.URL, []batchResult, bool) {
+	sel := db.LinkSelection{
+		ShortCodes: request.ShortCodes,
+		Tag:        request.Filter.Tag,
+		CampaignID: request.Filter.CampaignID,
+	}
+	if len(sel.ShortCodes) > 0 {
+		slices.Sort(sel.ShortCodes)
+		sel.ShortCodes = slices.Compact(sel.ShortCodes)
+		sel.Tag, sel.CampaignID = "", 0
+	}
+	if sel.Empty() {
+		respondError(c, apierror.Validation("Either shortCodes or a filter is required"))
+		return nil, nil, false
+	}
+	if len(sel.ShortCodes) > maxBatchLinks {
+		respondError(c, apierror.Validation("At most "+strconv.Itoa(maxBatchLinks)+" short codes can be changed at once"))
+		return nil, nil, false
+	}
+	if sel.CampaignID != 0 {
+		if _, err := findCampaign(c, sel.CampaignID); err != nil {
+			respondError(c, err)
+			return nil, nil, false
+		}
+	}
+
+	links, err := database.SelectLinks(c.Request.Context(), sel, maxBatchLinks+1)
+	if err != nil {
+		respondError(c, apierror.Internal("Database error").Wrap(err))
+		return nil, nil, false
+	}
+	if len(links) > maxBatchLinks {
+		respondError(c, apierror.Validation("The filter matches more than "+strconv.Itoa(maxBatchLinks)+" links; narrow it or pass shortCodes"))
+		return nil, nil, false
+	}
+
+	var results []batchResult
+	found := make(map[string]bool, len(links))
+	allowed := links[:0]
+	for _, link := range links {
+		found[link.ShortCode] = true
+		switch err := linkAccess(c, link.ShortCode, true); {
+		case err != nil:
+			results = append(results, batchResult{ShortCode: link.ShortCode, Status: batchForbidden, Error: err.Error()})
+		case link.Locked:
+			results = append(results, batchResult{ShortCode: link.ShortCode, Status: batchLocked})
+		default:
+			allowed = append(allowed, link)
+		}
+	}
+	for _, shortCode := range sel.ShortCodes {
+		if !found[shortCode] {
+			results = append(results, batchResult{ShortCode: shortCode, Status: batchNotFound})
+		}
+	}
+	return allowed, results, true
+}
+
+// applyBatch runs a batch write over links and adds the outcome of each to
+// results. Links the write skipped were locked in the meantime.
+func applyBatch(links []db.URL, results []batchResult, status string, write func([]int) ([]string, error)) ([]batchResult, []db.URL, error) {
+	ids := make([]int, 0, len(links))
+	for _, link := range links {
+		ids = append(ids, link.ID)
+	}
+	done, err := write(ids)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var changed []db.URL
+	for _, link := range links {
+		if slices.Contains(done, link.ShortCode) {
+			results = append(results, batchResult{ShortCode: link.ShortCode, Status: status})
+			changed = append(changed, link)
+		} else {
+			results = append(results, batchResult{ShortCode: link.ShortCode, Status: batchLocked})
+		}
+	}
+	slices.SortFunc(results, func(a, b batchResult) int {
+		switch {
+		case a.ShortCode < b.ShortCode:
+			return -1
+		case a.ShortCode > b.ShortCode:
+			return 1
+		}
+		return 0
+	})
+	return results, changed, nil
+}
+
+// batchUpdateURLs applies the same changes to many links, picked by short
+// code or by tag or campaign, in one transaction. Links the caller may not
+// change, and locked links, are left out and reported per link.
+func batchUpdateURLs(c *gin.Context) {
+	var request struct {
+		batchSelection
+		ForwardQuery     *bool           `json:"forwardQuery"`
+		ForwardPath      *bool           `json:"forwardPath"`
+		PublicStats      *bool           `json:"publicStats"`
+		TrackConversions *bool           `json:"trackConversions"`
+		Notes            *string         `json:"notes"`
+		Metadata         json.RawMessage `json:"metadata"`
+		// Disabled stops the links from redirecting, or lets them redirect again
+		Disabled       *bool  `json:"disabled"`
+		DisabledReason string `json:"disabledReason"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		respondError(c, apierror.Validation("Invalid request body"))
+		return
+	}
+
+	update := db.BatchUpdate{
+		ForwardQuery:     request.ForwardQuery,
+		ForwardPath:      request.ForwardPath,
+		PublicStats:      request.PublicStats,
+		TrackConversions: request.TrackConversions,
+	}
+	if request.Notes != nil {
+		notes, ok := normalizeNotes(*request.Notes)
+		if !ok {
+			respondError(c, apierror.Validation("notes must be at most 2000 characters"))
+			return
+		}
+		update.Notes = &notes
+	}
+	if request.Metadata != nil {
+		metadata, ok := normalizeMetadata(request.Metadata)
+		if !ok {
+			respondError(c, apierror.Validation("metadata must be a JSON object of at most 4096 bytes"))
+			return
+		}
+		update.Metadata = metadata
+	}
+	if request.Disabled != nil {
+		reason := ""
+		if *request.Disabled {
+			reason = request.DisabledReason
+			if reason == "" {
+				reason = "Disabled by its owner"
+			}
+		}
+		update.DisabledReason = &reason
+	}
+	if update.Empty() {
+		respondError(c, apierror.Validation("No changes given"))
+		return
+	}
+
+	links, results, ok := selectBatchLinks(c, request.batchSelection)
+	if !ok {
+		return
+	}
+	results, changed, err := applyBatch(links, results, batchUpdated, func(ids []int) ([]string, error) {
+		return database.UpdateLinks(c.Request.Context(), ids, update)
+	})
+	if err != nil {
+		respondError(c, apierror.Internal("Failed to update URLs").Wrap(err))
+		return
+	}
+
+	for _, link := range changed {
+		recordAudit(c, auditUpdate, "url", link.ShortCode, toURLModel(c, &link), update)
+	}
+	c.JSON(http.StatusOK, gin.H{"updated": len(changed), "results": results})
+}
+
+// batchDeleteURLs deletes many links, picked by short code or by tag or
+// campaign, in one transaction, e.g. to purge a finished campaign. With
+// ARCHIVE_ON_DELETE, links that fail to archive are kept.
+func batchDeleteURLs(c *gin.Context) {
+	var request batchSelection
+	if err := c.ShouldBindJSON(&request); err != nil {
+		respondError(c, apierror.Validation("Invalid request body"))
+		return
+	}
+
+	links, results, ok := selectBatchLinks(c, request)
+	if !ok {
+		return
+	}
+	if archiveOnDelete.Load() {
+		archived := links[:0]
+		for _, link := range links {
+			if err := archiveURL(c.Request.Context(), &link); err != nil {
+				log.Printf("Failed to archive %s before delete: %v", link.ShortCode, err)
+				results = append(results, batchResult{ShortCode: link.ShortCode, Status: batchFailed, Error: "Failed to archive URL"})
+				continue
+			}
+			archived = append(archived, link)
+		}
+		links = archived
+	}
+
+	results, deleted, err := applyBatch(links, results, batchDeleted, func(ids []int) ([]string, error) {
+		return database.DeleteLinks(c.Request.Context(), ids)
+	})
+	if err != nil {
+		respondError(c, apierror.Internal("Failed to delete URLs").Wrap(err))
+		return
+	}
+
+	for _, link := range deleted {
+		recordAudit(c, auditDelete, "url", link.ShortCode, toURLModel(c, &link), nil)
+	}
+	c.JSON(http.StatusOK, gin.H{"deleted": len(deleted), "results": results})
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// Benchmarks of the create and redirect paths through the full router and
+// the database named by the DATABASE_* variables. They measure the server
+// alone; cmd/loadgen measures a running instance over the network.
+
+func BenchmarkCreateShortURL(b *testing.B) {
+	r := routerWithDatabase(b)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		w := createForBenchmark(r, fmt.Sprintf("https://example.com/benchmark/%d", i))
+		if w.Code != http.StatusCreated {
+			b.Fatalf("create: want %d, got %d: %s", http.StatusCreated, w.Code, w.Body)
+		}
+	}
+}
+
+func BenchmarkRedirect(b *testing.B) {
+	r := routerWithDatabase(b)
+	w := createForBenchmark(r, "https://example.com/benchmark/redirect")
+	var created struct {
+		ShortCode string `json:"shortCode"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &created); err != nil || created.ShortCode == "" {
+		b.Fatalf("create: response has no shortCode: %s", w.Body)
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/"+created.ShortCode, nil))
+		if w.Code != http.StatusFound {
+			b.Fatalf("redirect: want %d, got %d: %s", http.StatusFound, w.Code, w.Body)
+		}
+	}
+}
+
+func createForBenchmark(r http.Handler, destination string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/urls", strings.NewReader(`{"url": "`+destination+`"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
@@ -0,0 +1,407 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+	"url-shortener/config"
+	"url-shortener/db"
+	"url-shortener/middleware"
+	"url-shortener/pkg/apierror"
+	"url-shortener/pkg/stripe"
+
+	"github.com/gin-gonic/gin"
+)
+
+// stripeSignatureHeader carries the signature of Stripe webhooks
+const stripeSignatureHeader = "Stripe-Signature"
+
+var (
+	// stripeClient sells plans through Stripe Checkout; nil when billing is disabled
+	stripeClient *stripe.Client
+	// stripeWebhookSecret verifies that webhooks come from Stripe
+	stripeWebhookSecret string
+	// billingSuccessURL and billingCancelURL are where Checkout sends customers back to
+	billingSuccessURL, billingCancelURL string
+)
+
+// billingPlans are the plans sold, by name
+var billingPlans atomic.Pointer[map[string]db.BillingPlan]
+
+// configureBilling enables billing when a Stripe secret key is configured
+func configureBilling(cfg *config.Config) error {
+	if cfg.Billing.StripeSecretKey == "" {
+		return nil
+	}
+	if cfg.Billing.StripeWebhookSecret == "" || cfg.Billing.SuccessURL == "" || cfg.Billing.CancelURL == "" {
+		return errors.New("STRIPE_SECRET_KEY needs STRIPE_WEBHOOK_SECRET, BILLING_SUCCESS_URL and BILLING_CANCEL_URL")
+	}
+
+	stripeClient = stripe.New(cfg.Billing.StripeSecretKey, cfg.Billing.Timeout)
+	stripeWebhookSecret = cfg.Billing.StripeWebhookSecret
+	billingSuccessURL, billingCancelURL = cfg.Billing.SuccessURL, cfg.Billing.CancelURL
+	return nil
+}
+
+// loadBillingPlans reads the plans sold from the database
+func loadBillingPlans(ctx context.Context) error {
+	plans, err := database.GetBillingPlans(ctx)
+	if err != nil {
+		return err
+	}
+
+	byName := make(map[string]db.BillingPlan, len(plans))
+	for _, p := range plans {
+		byName[p.Name] = p
+	}
+	billingPlans.Store(&byName)
+	return nil
+}
+
+// billingPlan returns a plan sold under name
+func billingPlan(name string) (db.BillingPlan, bool) {
+	plans := billingPlans.Load()
+	if plans == nil {
+		return db.BillingPlan{}, false
+	}
+	p, ok := (*plans)[name]
+	return p, ok
+}
+
+// billingPlanByPrice returns the plan sold at a Stripe price
+func billingPlanByPrice(priceID string) (db.BillingPlan, bool) {
+	if plans := billingPlans.Load(); plans != nil && priceID != "" {
+		for _, p := range *plans {
+			if p.StripePriceID == priceID {
+				return p, true
+			}
+		}
+	}
+	return db.BillingPlan{}, false
+}
+
+// checkCustomDomain writes a 402 and returns false when billing is enabled
+// and the plan of the organization, or of the user for personal links, does
+// not include custom domains. Admins are not limited.
+func checkCustomDomain(c *gin.Context, orgID int) bool {
+	return checkFeature(c, orgID, "customDomains", "Custom domains are not included in your plan",
+		func(p db.BillingPlan) bool { return p.CustomDomains })
+}
+
+// checkSSO writes a 402 and returns false when billing is enabled and the
+// plan of the organization does not include single sign-on
+func checkSSO(c *gin.Context, orgID int) bool {
+	return checkFeature(c, orgID, "sso", "Single sign-on is not included in your plan",
+		func(p db.BillingPlan) bool { return p.SSO })
+}
+
+// checkFeature writes a 402 and returns false when billing is enabled and the
+// plan sold as the caller's account plan lacks a feature
+func checkFeature(c *gin.Context, orgID int, feature, message string, included func(db.BillingPlan) bool) bool {
+	if stripeClient == nil || isAdmin(c) {
+		return true
+	}
+
+	p := planFor("")
+	if userID := c.GetString(middleware.ContextUserID); userID != "" {
+		var err error
+		if p, err = accountPlan(c.Request.Context(), userID, orgID); err != nil {
+			respondError(c, apierror.Internal("Database error").Wrap(err))
+			return false
+		}
+	}
+	if sold, ok := billingPlan(p.Name); ok && included(sold) {
+		return true
+	}
+	respondError(c, apierror.PaymentRequired(message).
+		WithDetails(gin.H{"feature": feature, "plan": p.Name}))
+	return false
+}
+
+// planOffer is a plan sold along with its limits; 0 means no limit
+type planOffer struct {
+	db.BillingPlan
+	Links           int   `json:"links"`
+	MonthlyLinks    int   `json:"monthlyLinks"`
+	MonthlyAPICalls int64 `json:"monthlyApiCalls"`
+}
+
+// getPlans lists the plans sold with their features and limits, e.g. for a pricing page
+func getPlans(c *gin.Context) {
+	plans, err := database.GetBillingPlans(c.Request.Context())
+	if err != nil {
+		respondError(c, apierror.Internal("Database error").Wrap(err))
+		return
+	}
+
+	offers := make([]planOffer, 0, len(plans))
+	for _, sold := range plans {
+		limits := planFor(sold.Name)
+		offers = append(offers, planOffer{
+			BillingPlan:     sold,
+			Links:           limits.Links,
+			MonthlyLinks:    limits.MonthlyLinks,
+			MonthlyAPICalls: limits.MonthlyAPICalls,
+		})
+	}
+	c.JSON(http.StatusOK, offers)
+}
+
+// setBillingPlan sells a plan at a Stripe price, or changes its price or features
+func setBillingPlan(c *gin.Context) {
+	name := c.Param("name")
+	var request struct {
+		StripePriceID string `json:"stripePriceId"`
+		CustomDomains bool   `json:"customDomains"`
+		SSO           bool   `json:"sso"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		respondError(c, apierror.Validation("Invalid request body"))
+		return
+	}
+	// The limits of a plan sold come from QUOTA_PLANS, so it must be there
+	if quotasEnabled() {
+		if _, ok := planLimits.Load().plans[name]; !ok {
+			respondError(c, apierror.Validation("Plan is not in QUOTA_PLANS"))
+			return
+		}
+	}
+
+	previous, existed := billingPlan(name)
+	plan := db.BillingPlan{Name: name, StripePriceID: strings.TrimSpace(request.StripePriceID), CustomDomains: request.CustomDomains,
+		SSO: request.SSO}
+	err := database.SetBillingPlan(c.Request.Context(), &plan)
+	if errors.Is(err, db.ErrPriceTaken) {
+		respondError(c, apierror.Conflict("Another plan is sold at this price"))
+		return
+	}
+	if err != nil {
+		respondError(c, apierror.Internal("Database error").Wrap(err))
+		return
+	}
+
+	reloadBillingPlans(c)
+	if existed {
+		recordAudit(c, auditUpdate, "plan", name, previous, plan)
+	} else {
+		recordAudit(c, auditCreate, "plan", name, nil, plan)
+	}
+	c.JSON(http.StatusOK, plan)
+}
+
+// deleteBillingPlan stops selling a plan; subscribers keep it until their
+// subscription ends
+func deleteBillingPlan(c *gin.Context) {
+	plan, err := database.DeleteBillingPlan(c.Request.Context(), c.Param("name"))
+	if errors.Is(err, sql.ErrNoRows) {
+		respondError(c, apierror.NotFound("Plan not found"))
+		return
+	}
+	if err != nil {
+		respondError(c, apierror.Internal("Database error").Wrap(err))
+		return
+	}
+
+	reloadBillingPlans(c)
+	recordAudit(c, auditDelete, "plan", plan.Name, plan, nil)
+	c.JSON(http.StatusOK, gin.H{"message": "Plan removed successfully"})
+}
+
+// reloadBillingPlans applies a plan change on this instance right away
+func reloadBillingPlans(c *gin.Context) {
+	if err := loadBillingPlans(c.Request.Context()); err != nil {
+		log.Printf("Failed to reload billing plans: %v", err)
+	}
+}
+
+// billingAccount returns the signed-in user managing billing for their own
+// account or, when orgID is set, an organization, writing an error unless
+// they own it
+func billingAccount(c *gin.Context, orgID int) (string, bool) {
+	userID := c.GetString(middleware.ContextUserID)
+	if userID == "" {
+		respondError(c, apierror.Unauthorized("Authentication required"))
+		return "", false
+	}
+	if orgID < 0 {
+		respondError(c, apierror.Validation("Invalid organization ID"))
+		return "", false
+	}
+	if orgID > 0 {
+		role, ok := orgRole(c, orgID)
+		if !ok {
+			return "", false
+		}
+		if role != db.RoleOwner {
+			respondError(c, apierror.Forbidden("Only owners can manage the organization's subscription"))
+			return "", false
+		}
+	}
+	return userID, true
+}
+
+// createCheckoutSession starts subscribing the signed-in user, or one of
+// their organizations, to a plan and returns the Stripe Checkout page to send
+// them to. The plan changes once Stripe reports the subscription active.
+func createCheckoutSession(c *gin.Context) {
+	if stripeClient == nil {
+		respondError(c, apierror.NotFound("Billing is not enabled"))
+		return
+	}
+	var request struct {
+		Plan  string `json:"plan"`
+		OrgID int    `json:"orgId"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		respondError(c, apierror.Validation("Invalid request body"))
+		return
+	}
+	userID, ok := billingAccount(c, request.OrgID)
+	if !ok {
+		return
+	}
+	sold, ok := billingPlan(request.Plan)
+	if !ok || sold.StripePriceID == "" {
+		respondError(c, apierror.Validation("Plan is not for sale"))
+		return
+	}
+
+	metadata := map[string]string{
+		"user_id":   userID,
+		"tenant_id": strconv.Itoa(db.TenantFrom(c.Request.Context())),
+	}
+	reference := userID
+	if request.OrgID > 0 {
+		metadata["org_id"] = strconv.Itoa(request.OrgID)
+		reference = "org-" + metadata["org_id"]
+	}
+	session, err := stripeClient.CreateCheckoutSession(c.Request.Context(), stripe.CheckoutParams{
+		PriceID:    sold.StripePriceID,
+		SuccessURL: billingSuccessURL,
+		CancelURL:  billingCancelURL,
+		Reference:  reference,
+		Metadata:   metadata,
+	})
+	if err != nil {
+		respondError(c, apierror.Upstream("Failed to start checkout").Wrap(err))
+		return
+	}
+
+	c.JSON(http.StatusCreated, session)
+}
+
+// getSubscription returns the signed-in user's subscription, or that of one
+// of their organizations with ?orgId
+func getSubscription(c *gin.Context) {
+	orgID := 0
+	if raw := c.Query("orgId"); raw != "" {
+		var err error
+		if orgID, err = strconv.Atoi(raw); err != nil {
+			orgID = -1
+		}
+	}
+	userID, ok := billingAccount(c, orgID)
+	if !ok {
+		return
+	}
+
+	sub, err := database.GetSubscription(c.Request.Context(), userID, orgID)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondError(c, apierror.NotFound("No subscription"))
+		return
+	}
+	if err != nil {
+		respondError(c, apierror.Internal("Database error").Wrap(err))
+		return
+	}
+
+	c.JSON(http.StatusOK, sub)
+}
+
+// subscriptionGrantsPlan reports whether a subscription in a Stripe status
+// keeps its plan. Past due subscriptions keep it while Stripe retries payment.
+func subscriptionGrantsPlan(status string) bool {
+	switch status {
+	case "active", "trialing", "past_due":
+		return true
+	default:
+		return false
+	}
+}
+
+// stripeWebhook applies subscription lifecycle events, putting the
+// subscriber on the plan they pay for and back on the default plan once the
+// subscription ends. Errors make Stripe retry the event.
+func stripeWebhook(c *gin.Context) {
+	if stripeClient == nil {
+		respondError(c, apierror.NotFound("Billing is not enabled"))
+		return
+	}
+	payload, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		respondError(c, apierror.Validation("Invalid request body"))
+		return
+	}
+	event, err := stripe.ParseWebhook(payload, c.GetHeader(stripeSignatureHeader), stripeWebhookSecret, time.Now())
+	if err != nil {
+		respondError(c, apierror.Validation("Invalid webhook").Wrap(err))
+		return
+	}
+
+	ended := event.Type == "customer.subscription.deleted"
+	if !ended && event.Type != "customer.subscription.created" && event.Type != "customer.subscription.updated" {
+		c.JSON(http.StatusOK, gin.H{"received": true})
+		return
+	}
+	var sub stripe.Subscription
+	if err := json.Unmarshal(event.Data.Object, &sub); err != nil {
+		respondError(c, apierror.Validation("Invalid subscription").Wrap(err))
+		return
+	}
+	// Subscriptions not started through createCheckoutSession are not ours to apply
+	userID := sub.Metadata["user_id"]
+	orgID, _ := strconv.Atoi(sub.Metadata["org_id"])
+	tenant, _ := strconv.Atoi(sub.Metadata["tenant_id"])
+	if userID == "" && orgID == 0 {
+		c.JSON(http.StatusOK, gin.H{"received": true})
+		return
+	}
+
+	record := db.Subscription{ID: sub.ID, CustomerID: sub.Customer, UserID: userID, OrgID: orgID, Status: sub.Status}
+	if sub.CurrentPeriodEnd > 0 {
+		periodEnd := time.Unix(sub.CurrentPeriodEnd, 0).UTC()
+		record.CurrentPeriodEnd = &periodEnd
+	}
+	sold, known := billingPlanByPrice(sub.PriceID())
+	record.Plan = sold.Name
+	granted := ""
+	if !ended && subscriptionGrantsPlan(sub.Status) {
+		if !known {
+			// Keep what the subscriber has until the price is sold again or the subscription ends
+			log.Printf("Stripe subscription %s is for price %q, which no plan is sold at", sub.ID, sub.PriceID())
+			c.JSON(http.StatusOK, gin.H{"received": true})
+			return
+		}
+		granted = sold.Name
+	}
+
+	ctx := db.WithTenant(c.Request.Context(), tenant)
+	applied, err := database.SaveSubscription(ctx, &record, time.Unix(event.Created, 0).UTC(), granted)
+	if err != nil {
+		respondError(c, apierror.Internal("Failed to store subscription").Wrap(err))
+		return
+	}
+	if applied && orgID == 0 {
+		meteredPlanChanged(userID, granted)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"received": true})
+}
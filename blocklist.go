@@ -0,0 +1,229 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"regexp"
+	"strconv"
+	"sync/atomic"
+	"url-shortener/db"
+	"url-shortener/pkg/apierror"
+	"url-shortener/pkg/blocklist"
+
+	"github.com/gin-gonic/gin"
+)
+
+// destinationBlocklist holds the compiled destination blocklist; nil blocks nothing
+var destinationBlocklist atomic.Pointer[blocklist.List]
+
+// loadBlocklist reads the destination blocklist from the database
+func loadBlocklist(ctx context.Context) error {
+	rules, err := database.GetBlockedDestinations(ctx)
+	if err != nil {
+		return err
+	}
+
+	compiled := make([]blocklist.Rule, 0, len(rules))
+	for _, r := range rules {
+		compiled = append(compiled, blocklist.Rule{Kind: r.Kind, Pattern: r.Pattern})
+	}
+	list, err := blocklist.New(compiled)
+	if err != nil {
+		return err
+	}
+
+	destinationBlocklist.Store(list)
+	return nil
+}
+
+// allowedDestinations rejects the request with 422 when any destination is
+// longer than MAX_URL_LENGTH or blocklisted, reporting whether the handler may continue
+func allowedDestinations(c *gin.Context, destinations ...string) bool {
+	list := destinationBlocklist.Load()
+	for _, destination := range destinations {
+		if maxURLLength > 0 && len(destination) > maxURLLength {
+			respondError(c, apierror.Unprocessable("Destination must be at most "+strconv.Itoa(maxURLLength)+" characters"))
+			return false
+		}
+		if _, blocked := list.Match(destination); blocked {
+			respondError(c, apierror.Unprocessable("Destination is not allowed"))
+			return false
+		}
+	}
+	return true
+}
+
+// linkDestinations lists every URL a link can redirect to
+func linkDestinations(link *db.URL) []string {
+	destinations := []string{link.OriginalURL}
+	for _, target := range link.Targets {
+		destinations = append(destinations, target)
+	}
+	for _, v := range link.Variants {
+		destinations = append(destinations, v.URL)
+	}
+	if link.Rotation != nil {
+		destinations = append(destinations, link.Rotation.Destinations...)
+	}
+	if link.AppLink != nil {
+		destinations = append(destinations, link.AppLink.URI)
+		for _, store := range []string{link.AppLink.IOSStore, link.AppLink.AndroidStore} {
+			if store != "" {
+				destinations = append(destinations, store)
+			}
+		}
+	}
+	return destinations
+}
+
+// getBlockedDestinations lists the destination blocklist
+func getBlockedDestinations(c *gin.Context) {
+	rules, err := database.GetBlockedDestinations(c.Request.Context())
+	if err != nil {
+		respondError(c, apierror.Internal("Database error").Wrap(err))
+		return
+	}
+
+	c.JSON(http.StatusOK, rules)
+}
+
+// addBlockedDestination blocks a destination domain (with its subdomains) or URL pattern
+func addBlockedDestination(c *gin.Context) {
+	var request struct {
+		Kind    string `json:"kind"`
+		Pattern string `json:"pattern"`
+		Note    string `json:"note"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		respondError(c, apierror.Validation("Invalid request body"))
+		return
+	}
+
+	switch request.Kind {
+	case blocklist.Domain:
+		request.Pattern = blocklist.NormalizeDomain(request.Pattern)
+		if !domainPattern.MatchString(request.Pattern) {
+			respondError(c, apierror.Validation("Invalid domain"))
+			return
+		}
+	case blocklist.Regex:
+		if _, err := regexp.Compile(request.Pattern); err != nil || request.Pattern == "" {
+			respondError(c, apierror.Validation("Invalid pattern"))
+			return
+		}
+	default:
+		respondError(c, apierror.Validation("Kind must be domain or regex"))
+		return
+	}
+
+	rule := db.BlockedDestination{Kind: request.Kind, Pattern: request.Pattern, Note: request.Note, CreatedBy: auditActor(c)}
+	if err := database.AddBlockedDestination(c.Request.Context(), &rule); err != nil {
+		if errors.Is(err, db.ErrDuplicateBlockedDestination) {
+			respondError(c, apierror.Conflict("Rule already exists"))
+			return
+		}
+		respondError(c, apierror.Internal("Failed to store rule").Wrap(err))
+		return
+	}
+
+	reloadBlocklist(c)
+	recordAudit(c, auditCreate, "blocked_destination", strconv.FormatInt(rule.ID, 10), nil, rule)
+	c.JSON(http.StatusCreated, rule)
+}
+
+// deleteBlockedDestination removes a rule. Links disabled by it stay disabled
+// until an admin enables them.
+func deleteBlockedDestination(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		respondError(c, apierror.NotFound("Rule not found"))
+		return
+	}
+
+	rule, err := database.DeleteBlockedDestination(c.Request.Context(), id)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondError(c, apierror.NotFound("Rule not found"))
+		return
+	}
+	if err != nil {
+		respondError(c, apierror.Internal("Database error").Wrap(err))
+		return
+	}
+
+	reloadBlocklist(c)
+	recordAudit(c, auditDelete, "blocked_destination", strconv.FormatInt(rule.ID, 10), rule, nil)
+	c.JSON(http.StatusOK, gin.H{"message": "Rule deleted successfully"})
+}
+
+// scanBlockedDestinations checks every enabled link against the blocklist and
+// disables those pointing at a blocked destination
+func scanBlockedDestinations(c *gin.Context) {
+	if err := loadBlocklist(c.Request.Context()); err != nil {
+		respondError(c, apierror.Internal("Failed to load blocklist").Wrap(err))
+		return
+	}
+	list := destinationBlocklist.Load()
+
+	// The links of every tenant are scanned
+	type offender struct {
+		link   *db.URL
+		reason string
+	}
+	scanned := 0
+	var offenders []offender
+	err := database.ForEachURL(c.Request.Context(), func(link *db.URL) error {
+		scanned++
+		for _, destination := range linkDestinations(link) {
+			if rule, blocked := list.Match(destination); blocked {
+				offenders = append(offenders, offender{link, "Destination blocked by " + rule})
+				break
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		respondError(c, apierror.Internal("Database error").Wrap(err))
+		return
+	}
+
+	disabled := make([]string, 0, len(offenders))
+	for _, o := range offenders {
+		if err := database.SetDisabled(db.WithLink(c.Request.Context(), o.link), o.link.ShortCode, o.reason); err != nil {
+			log.Printf("Failed to disable %s: %v", o.link.ShortCode, err)
+			continue
+		}
+		recordAudit(c, auditDisable, "url", o.link.ShortCode, gin.H{"disabled": false}, gin.H{"disabled": true, "reason": o.reason})
+		disabled = append(disabled, o.link.ShortCode)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"scanned": scanned, "disabled": disabled})
+}
+
+// enableShortURL lets a disabled link redirect again, e.g. after a blocklist false positive
+func enableShortURL(c *gin.Context) {
+	shortCode := c.Param("shortCode")
+
+	link, err := database.GetURLByShortCode(c.Request.Context(), shortCode)
+	if err != nil {
+		respondError(c, notFound(err, "Short URL not found"))
+		return
+	}
+
+	if err := database.SetDisabled(c.Request.Context(), shortCode, ""); err != nil {
+		respondError(c, notFound(err, "Short URL not found"))
+		return
+	}
+
+	recordAudit(c, auditEnable, "url", shortCode, gin.H{"disabled": link.Disabled, "reason": link.DisabledReason}, gin.H{"disabled": false})
+	c.JSON(http.StatusOK, gin.H{"message": "URL enabled successfully"})
+}
+
+// reloadBlocklist applies a blocklist change on this instance right away
+func reloadBlocklist(c *gin.Context) {
+	if err := loadBlocklist(c.Request.Context()); err != nil {
+		log.Printf("Failed to reload destination blocklist: %v", err)
+	}
+}
@@ -0,0 +1,239 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"url-shortener/db"
+	"url-shortener/middleware"
+	"url-shortener/models"
+	"url-shortener/pkg/apierror"
+
+	"github.com/gin-gonic/gin"
+)
+
+// campaignAccess loads the :id campaign for its owner or an admin, writing an
+// error response and returning false otherwise
+func campaignAccess(c *gin.Context) (*db.Campaign, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		id = 0
+	}
+	campaign, err := findCampaign(c, id)
+	if err != nil {
+		respondError(c, err)
+		return nil, false
+	}
+	return campaign, true
+}
+
+// findCampaign loads a campaign for its owner or an admin
+func findCampaign(c *gin.Context, id int) (*db.Campaign, error) {
+	userID := c.GetString(middleware.ContextUserID)
+	admin := isAdmin(c)
+	if userID == "" && !admin {
+		return nil, apierror.Unauthorized("Authentication required")
+	}
+	if id <= 0 {
+		return nil, apierror.NotFound("Campaign not found")
+	}
+
+	campaign, err := database.GetCampaign(c.Request.Context(), id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, apierror.NotFound("Campaign not found")
+	}
+	if err != nil {
+		return nil, apierror.Internal("Database error").Wrap(err)
+	}
+
+	if !admin && campaign.OwnerID != userID {
+		return nil, apierror.Forbidden("Campaign belongs to another user")
+	}
+	return campaign, nil
+}
+
+// getCampaigns lists the requesting user's campaigns, or every campaign for admins
+func getCampaigns(c *gin.Context) {
+	userID := c.GetString(middleware.ContextUserID)
+	if isAdmin(c) {
+		userID = ""
+	} else if userID == "" {
+		respondError(c, apierror.Unauthorized("Authentication required"))
+		return
+	}
+
+	campaigns, err := database.GetCampaigns(c.Request.Context(), userID)
+	if err != nil {
+		respondError(c, apierror.Internal("Database error").Wrap(err))
+		return
+	}
+
+	c.JSON(http.StatusOK, campaigns)
+}
+
+func createCampaign(c *gin.Context) {
+	var request struct {
+		Name string `json:"name" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil || strings.TrimSpace(request.Name) == "" {
+		respondError(c, apierror.Validation("Invalid request body"))
+		return
+	}
+
+	userID := c.GetString(middleware.ContextUserID)
+	if userID == "" && !isAdmin(c) {
+		respondError(c, apierror.Unauthorized("Authentication required"))
+		return
+	}
+
+	campaign, err := database.CreateCampaign(c.Request.Context(), strings.TrimSpace(request.Name), userID)
+	if err != nil {
+		respondError(c, apierror.Internal("Failed to create campaign").Wrap(err))
+		return
+	}
+
+	recordAudit(c, auditCreate, "campaign", strconv.Itoa(campaign.ID), nil, campaign)
+	c.JSON(http.StatusCreated, campaign)
+}
+
+// deleteCampaign removes a campaign; its links keep working but are detached
+func deleteCampaign(c *gin.Context) {
+	campaign, ok := campaignAccess(c)
+	if !ok {
+		return
+	}
+
+	if err := database.DeleteCampaign(c.Request.Context(), campaign.ID); err != nil && !errors.Is(err, sql.ErrNoRows) {
+		respondError(c, apierror.Internal("Database error").Wrap(err))
+		return
+	}
+
+	recordAudit(c, auditDelete, "campaign", strconv.Itoa(campaign.ID), campaign, nil)
+	c.JSON(http.StatusOK, gin.H{"message": "Campaign deleted successfully"})
+}
+
+// getCampaignURLs lists the links attached to a campaign
+func getCampaignURLs(c *gin.Context) {
+	campaign, ok := campaignAccess(c)
+	if !ok {
+		return
+	}
+
+	records, err := database.GetCampaignURLs(c.Request.Context(), campaign.ID)
+	if err != nil {
+		respondError(c, apierror.Internal("Database error").Wrap(err))
+		return
+	}
+
+	urls := make([]models.URL, 0, len(records))
+	for _, record := range records {
+		urls = append(urls, toURLModel(c, &record))
+	}
+
+	c.JSON(http.StatusOK, urls)
+}
+
+// attachCampaignURLs adds links to a campaign. Users can only attach links
+// they created; locked links and unknown codes are reported as skipped.
+func attachCampaignURLs(c *gin.Context) {
+	campaign, ok := campaignAccess(c)
+	if !ok {
+		return
+	}
+
+	var request struct {
+		ShortCodes []string `json:"shortCodes" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil || len(request.ShortCodes) == 0 {
+		respondError(c, apierror.Validation("shortCodes is required"))
+		return
+	}
+
+	ownerID := c.GetString(middleware.ContextUserID)
+	if isAdmin(c) {
+		ownerID = ""
+	}
+
+	attached, err := database.AttachToCampaign(c.Request.Context(), campaign.ID, request.ShortCodes, ownerID)
+	if err != nil {
+		respondError(c, apierror.Internal("Database error").Wrap(err))
+		return
+	}
+
+	done := make(map[string]bool, len(attached))
+	for _, shortCode := range attached {
+		done[shortCode] = true
+	}
+	skipped := []string{}
+	for _, shortCode := range request.ShortCodes {
+		if !done[shortCode] {
+			skipped = append(skipped, shortCode)
+		}
+	}
+
+	if len(attached) > 0 {
+		recordAudit(c, auditUpdate, "campaign", strconv.Itoa(campaign.ID), nil, gin.H{"attached": attached})
+	}
+	c.JSON(http.StatusOK, gin.H{"attached": attached, "skipped": skipped})
+}
+
+// detachCampaignURL removes a link from a campaign
+func detachCampaignURL(c *gin.Context) {
+	campaign, ok := campaignAccess(c)
+	if !ok {
+		return
+	}
+
+	shortCode := c.Param("shortCode")
+	err := database.DetachFromCampaign(c.Request.Context(), campaign.ID, shortCode)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondError(c, apierror.NotFound("Short URL is not part of this campaign"))
+		return
+	}
+	if err != nil {
+		respondError(c, apierror.Internal("Database error").Wrap(err))
+		return
+	}
+
+	recordAudit(c, auditUpdate, "campaign", strconv.Itoa(campaign.ID), gin.H{"attached": []string{shortCode}}, nil)
+	c.JSON(http.StatusOK, gin.H{"message": "URL detached successfully"})
+}
+
+// getCampaignStats aggregates clicks across every link of a campaign
+func getCampaignStats(c *gin.Context) {
+	campaign, ok := campaignAccess(c)
+	if !ok {
+		return
+	}
+
+	stats, err := database.GetCampaignStats(c.Request.Context(), campaign)
+	if err != nil {
+		respondError(c, apierror.Internal("Database error").Wrap(err))
+		return
+	}
+
+	c.JSON(http.StatusOK, stats)
+}
+
+// expireCampaign disables every link of a campaign at once, e.g. when a
+// promotion ends. Single links can be brought back with the enable endpoint.
+func expireCampaign(c *gin.Context) {
+	campaign, ok := campaignAccess(c)
+	if !ok {
+		return
+	}
+
+	reason := "Campaign " + campaign.Name + " has ended"
+	expired, err := database.ExpireCampaign(c.Request.Context(), campaign.ID, reason)
+	if err != nil {
+		respondError(c, apierror.Internal("Database error").Wrap(err))
+		return
+	}
+
+	for _, shortCode := range expired {
+		recordAudit(c, auditDisable, "url", shortCode, gin.H{"disabled": false}, gin.H{"disabled": true, "reason": reason})
+	}
+	c.JSON(http.StatusOK, gin.H{"expired": expired})
+}
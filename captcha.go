@@ -0,0 +1,54 @@
+package main
+
+import (
+	"log"
+	"time"
+	"url-shortener/middleware"
+	"url-shortener/pkg/apierror"
+	"url-shortener/pkg/captcha"
+	"url-shortener/pkg/limiter"
+
+	"github.com/gin-gonic/gin"
+)
+
+// captchaHeader carries the CAPTCHA response token on anonymous link creation
+const captchaHeader = "X-Captcha-Token"
+
+// captchaAllowance counts the links anonymous clients created without a
+// CAPTCHA; nil when every creation is challenged
+var captchaAllowance limiter.Limiter
+
+// requireCaptcha makes anonymous clients solve a CAPTCHA once they have created
+// threshold links within the last hour (always, when threshold is 0). Signed-in
+// users, JWT holders and admins are never challenged.
+func requireCaptcha(verifier *captcha.Verifier, threshold int) gin.HandlerFunc {
+	if threshold > 0 {
+		captchaAllowance = limiter.NewSlidingWindow(threshold, time.Hour)
+	}
+
+	return func(c *gin.Context) {
+		if c.GetString(middleware.ContextUserID) != "" || isAdmin(c) {
+			c.Next()
+			return
+		}
+
+		ip := c.ClientIP()
+		if captchaAllowance != nil && captchaAllowance.Allow(ip) {
+			c.Next()
+			return
+		}
+
+		ok, err := verifier.Verify(c.Request.Context(), c.GetHeader(captchaHeader), ip)
+		if err != nil {
+			log.Printf("CAPTCHA verification failed: %v", err)
+			respondError(c, apierror.Unavailable("CAPTCHA verification unavailable"))
+			return
+		}
+		if !ok {
+			respondError(c, apierror.Forbidden("CAPTCHA verification required"))
+			return
+		}
+
+		c.Next()
+	}
+}
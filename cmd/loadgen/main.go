@@ -0,0 +1,343 @@
+// Command loadgen sends link creations and redirects at a fixed rate against a
+// running instance and reports latency percentiles, so changes to caching and
+// click counting can be compared before and after.
+//
+// Requests are scheduled open-loop: each one's latency is measured from when
+// it was due, not from when a free slot let it go out, so a stalled server
+// shows up in the percentiles instead of slowing the load down. Run it against
+// an instance with RATE_LIMIT_ENABLED=false, or every request past the limit
+// is answered 429.
+//
+// Usage:
+//
+//	go run ./cmd/loadgen -target http://localhost:8080 -scenario redirect -rps 500 -duration 1m -out before.json
+//	go run ./cmd/loadgen -target http://localhost:8080 -scenario redirect -rps 500 -duration 1m -baseline before.json
+//	go run ./cmd/loadgen -target http://localhost:8080 -codes codes.txt      # redirect through links made by cmd/seed
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"math/rand/v2"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Operations a scenario is made of
+const (
+	opCreate   = "create"
+	opRedirect = "redirect"
+)
+
+// client sends the requests of one run
+type client struct {
+	http   *http.Client
+	target string
+	apiKey string
+}
+
+func main() {
+	target := flag.String("target", "http://localhost:8080", "base URL of the instance to load")
+	scenario := flag.String("scenario", "redirect", "redirect, create or mixed")
+	rps := flag.Float64("rps", 100, "requests per second to send")
+	duration := flag.Duration("duration", 30*time.Second, "how long to send requests")
+	concurrency := flag.Int("concurrency", 200, "maximum in-flight requests")
+	links := flag.Int("links", 100, "links created up front for redirects to spread over")
+	codesFile := flag.String("codes", "", "redirect through the short codes in this file, one per line, instead of creating links")
+	createShare := flag.Float64("create-share", 0.1, "share of requests creating links in the mixed scenario")
+	apiKey := flag.String("api-key", "", "X-API-Key sent with link creations")
+	out := flag.String("out", "", "write the results as JSON to this file")
+	baseline := flag.String("baseline", "", "compare with the JSON results of an earlier run")
+	flag.Parse()
+
+	share := map[string]float64{"redirect": 0, "create": 1, "mixed": *createShare}
+	creates, ok := share[*scenario]
+	if !ok {
+		log.Fatalf("Unknown scenario %q", *scenario)
+	}
+	if *rps <= 0 || *concurrency <= 0 {
+		log.Fatal("-rps and -concurrency must be positive")
+	}
+
+	c := &client{
+		http: &http.Client{
+			Timeout: 10 * time.Second,
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+			Transport: &http.Transport{MaxIdleConnsPerHost: *concurrency},
+		},
+		target: strings.TrimRight(*target, "/"),
+		apiKey: *apiKey,
+	}
+
+	var codes []string
+	if *codesFile != "" {
+		data, err := os.ReadFile(*codesFile)
+		if err != nil {
+			log.Fatalf("Failed to read short codes: %v", err)
+		}
+		codes = strings.Fields(string(data))
+		if len(codes) == 0 && creates < 1 {
+			log.Fatalf("No short codes in %s", *codesFile)
+		}
+	} else if creates < 1 {
+		log.Printf("Creating %d links to redirect through", *links)
+		for i := 0; i < *links; i++ {
+			code, _, err := c.create()
+			if err != nil {
+				log.Fatalf("Failed to create link: %v", err)
+			}
+			codes = append(codes, code)
+		}
+	}
+
+	log.Printf("Sending %s load at %.0f req/s for %s against %s", *scenario, *rps, *duration, c.target)
+	res := run(c, codes, creates, *rps, *duration, *concurrency)
+	res.print(os.Stdout)
+
+	if *baseline != "" {
+		before, err := readResults(*baseline)
+		if err != nil {
+			log.Fatalf("Failed to read baseline: %v", err)
+		}
+		res.compare(os.Stdout, before)
+	}
+	if *out != "" {
+		data, err := json.MarshalIndent(res.summary(), "", "  ")
+		if err != nil {
+			log.Fatalf("Failed to encode results: %v", err)
+		}
+		if err := os.WriteFile(*out, data, 0o644); err != nil {
+			log.Fatalf("Failed to write results: %v", err)
+		}
+	}
+}
+
+// create creates a link, returning its short code and the response status
+func (c *client) create() (string, int, error) {
+	body := fmt.Sprintf(`{"url": "https://example.com/loadgen/%d"}`, rand.Int64())
+	req, err := http.NewRequest(http.MethodPost, c.target+"/api/v1/urls", bytes.NewBufferString(body))
+	if err != nil {
+		return "", 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if c.apiKey != "" {
+		req.Header.Set("X-API-Key", c.apiKey)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return "", 0, err
+	}
+	defer resp.Body.Close()
+
+	var created struct {
+		ShortCode string `json:"shortCode"`
+	}
+	if resp.StatusCode != http.StatusCreated {
+		io.Copy(io.Discard, resp.Body)
+		return "", resp.StatusCode, fmt.Errorf("create answered %s", resp.Status)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&created); err != nil {
+		return "", resp.StatusCode, err
+	}
+	return created.ShortCode, resp.StatusCode, nil
+}
+
+// redirect follows a short link once, without following the redirect itself
+func (c *client) redirect(code string) (int, error) {
+	resp, err := c.http.Get(c.target + "/" + code)
+	if err != nil {
+		return 0, err
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	return resp.StatusCode, nil
+}
+
+// opResult collects the outcomes of one operation
+type opResult struct {
+	requests  int
+	statuses  map[int]int
+	errors    int
+	latencies []time.Duration
+}
+
+type result struct {
+	mu      sync.Mutex
+	ops     map[string]*opResult
+	elapsed time.Duration
+}
+
+func (r *result) record(op string, status int, err error, latency time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	o := r.ops[op]
+	if o == nil {
+		o = &opResult{statuses: map[int]int{}}
+		r.ops[op] = o
+	}
+	o.requests++
+	// Statuses the client got an answer with still count, e.g. a failed create's 429
+	if status != 0 {
+		o.statuses[status]++
+		o.latencies = append(o.latencies, latency)
+	}
+	if err != nil || status >= 500 {
+		o.errors++
+	}
+}
+
+// run sends requests at rps for duration, each creating a link with
+// probability creates and otherwise redirecting through a random one of codes
+func run(c *client, codes []string, creates, rps float64, duration time.Duration, concurrency int) *result {
+	res := &result{ops: map[string]*opResult{}}
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+
+	interval := time.Duration(float64(time.Second) / rps)
+	start := time.Now()
+	for due := start; due.Before(start.Add(duration)); due = due.Add(interval) {
+		time.Sleep(time.Until(due))
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(due time.Time) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			if rand.Float64() < creates {
+				_, status, err := c.create()
+				res.record(opCreate, status, err, time.Since(due))
+				return
+			}
+			status, err := c.redirect(codes[rand.IntN(len(codes))])
+			res.record(opRedirect, status, err, time.Since(due))
+		}(due)
+	}
+	wg.Wait()
+	res.elapsed = time.Since(start)
+
+	return res
+}
+
+// percentiles reported for every operation
+var percentiles = []float64{50, 90, 99, 99.9}
+
+// opSummary is what is kept of an operation's results, latencies in
+// milliseconds. Errors are transport failures and 5xx responses, and for
+// creates any response but 201.
+type opSummary struct {
+	Requests    int                `json:"requests"`
+	Errors      int                `json:"errors"`
+	Statuses    map[int]int        `json:"statuses"`
+	Rate        float64            `json:"rate"`
+	Percentiles map[string]float64 `json:"percentilesMs"`
+	Max         float64            `json:"maxMs"`
+}
+
+func (r *result) summary() map[string]opSummary {
+	summary := make(map[string]opSummary, len(r.ops))
+	for op, o := range r.ops {
+		latencies := append([]time.Duration(nil), o.latencies...)
+		sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+
+		s := opSummary{
+			Requests:    o.requests,
+			Errors:      o.errors,
+			Statuses:    o.statuses,
+			Rate:        float64(o.requests) / r.elapsed.Seconds(),
+			Percentiles: map[string]float64{},
+		}
+		if n := len(latencies); n > 0 {
+			for _, p := range percentiles {
+				s.Percentiles[percentileName(p)] = milliseconds(latencies[int(float64(n-1)*p/100)])
+			}
+			s.Max = milliseconds(latencies[n-1])
+		}
+		summary[op] = s
+	}
+	return summary
+}
+
+func percentileName(p float64) string {
+	return fmt.Sprintf("p%g", p)
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
+
+func (r *result) print(w io.Writer) {
+	summary := r.summary()
+	for _, op := range sortedOps(summary) {
+		s := summary[op]
+		fmt.Fprintf(w, "%s: %d requests in %s (%.1f req/s), %d errors\n", op, s.Requests, r.elapsed.Round(time.Millisecond), s.Rate, s.Errors)
+
+		codes := make([]int, 0, len(s.Statuses))
+		for code := range s.Statuses {
+			codes = append(codes, code)
+		}
+		sort.Ints(codes)
+		for _, code := range codes {
+			fmt.Fprintf(w, "  status %d: %d\n", code, s.Statuses[code])
+		}
+		for _, p := range percentiles {
+			name := percentileName(p)
+			fmt.Fprintf(w, "  %-6s %8.2fms\n", name+":", s.Percentiles[name])
+		}
+		fmt.Fprintf(w, "  %-6s %8.2fms\n", "max:", s.Max)
+	}
+}
+
+// compare prints how each percentile moved since the baseline run
+func (r *result) compare(w io.Writer, before map[string]opSummary) {
+	summary := r.summary()
+	fmt.Fprintln(w, "compared with baseline:")
+	for _, op := range sortedOps(summary) {
+		old, ok := before[op]
+		if !ok {
+			continue
+		}
+		fmt.Fprintf(w, "%s:\n", op)
+		for _, p := range percentiles {
+			name := percentileName(p)
+			fmt.Fprintf(w, "  %-6s %8.2fms -> %8.2fms (%s)\n", name+":", old.Percentiles[name], summary[op].Percentiles[name], change(old.Percentiles[name], summary[op].Percentiles[name]))
+		}
+		fmt.Fprintf(w, "  %-6s %8.1f/s  -> %8.1f/s\n", "rate:", old.Rate, summary[op].Rate)
+	}
+}
+
+func change(before, after float64) string {
+	if before == 0 {
+		return "n/a"
+	}
+	return fmt.Sprintf("%+.1f%%", (after-before)/before*100)
+}
+
+func sortedOps(summary map[string]opSummary) []string {
+	ops := make([]string, 0, len(summary))
+	for op := range summary {
+		ops = append(ops, op)
+	}
+	sort.Strings(ops)
+	return ops
+}
+
+func readResults(path string) (map[string]opSummary, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var summary map[string]opSummary
+	return summary, json.Unmarshal(data, &summary)
+}
@@ -0,0 +1,207 @@
+// Command replay re-issues recorded redirect traffic against a running instance
+// so capacity can be validated before large campaigns.
+//
+// Usage:
+//
+//	go run ./cmd/replay -input access.log -target http://localhost:8080 -speed 10
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// hit is a single recorded redirect request
+type hit struct {
+	at   time.Time
+	path string
+}
+
+var (
+	// [GIN] 2023/05/20 - 15:30:45 | 302 |  1.2ms |  127.0.0.1 | GET      "/urls/aBc123"
+	ginLine = regexp.MustCompile(`^\[GIN\] (\d{4}/\d{2}/\d{2} - \d{2}:\d{2}:\d{2}) \|.*\| *GET +"([^"]+)"`)
+	// 127.0.0.1 - - [20/May/2023:15:30:45 +0000] "GET /urls/aBc123 HTTP/1.1" 302 ...
+	combinedLine = regexp.MustCompile(`\[([^\]]+)\] "GET ([^ "]+)[^"]*"`)
+)
+
+func main() {
+	input := flag.String("input", "", "access log or click export to replay (defaults to stdin)")
+	format := flag.String("format", "gin", "input format: gin, combined or csv (timestamp,short_code)")
+	target := flag.String("target", "http://localhost:8080", "base URL of the instance to replay against")
+	prefix := flag.String("prefix", "/urls/", "path prefix used to build redirect URLs from csv short codes")
+	speed := flag.Float64("speed", 1, "speed multiplier applied to recorded inter-arrival times (0 sends as fast as possible)")
+	concurrency := flag.Int("concurrency", 50, "maximum in-flight requests")
+	flag.Parse()
+
+	var r io.Reader = os.Stdin
+	if *input != "" {
+		f, err := os.Open(*input)
+		if err != nil {
+			log.Fatalf("Failed to open input: %v", err)
+		}
+		defer f.Close()
+		r = f
+	}
+
+	hits, err := readHits(r, *format, *prefix)
+	if err != nil {
+		log.Fatalf("Failed to read input: %v", err)
+	}
+	if len(hits) == 0 {
+		log.Fatal("No redirect requests found in input")
+	}
+
+	sort.SliceStable(hits, func(i, j int) bool { return hits[i].at.Before(hits[j].at) })
+	log.Printf("Replaying %d requests against %s at %.2fx", len(hits), *target, *speed)
+
+	res := replay(hits, strings.TrimRight(*target, "/"), *speed, *concurrency)
+	res.print(os.Stdout)
+}
+
+func readHits(r io.Reader, format, prefix string) ([]hit, error) {
+	if format == "csv" {
+		return readCSV(r, prefix)
+	}
+
+	var pattern *regexp.Regexp
+	var layout string
+	switch format {
+	case "gin":
+		pattern, layout = ginLine, "2006/01/02 - 15:04:05"
+	case "combined":
+		pattern, layout = combinedLine, "02/Jan/2006:15:04:05 -0700"
+	default:
+		return nil, fmt.Errorf("unknown format: %s", format)
+	}
+
+	var hits []hit
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		m := pattern.FindStringSubmatch(scanner.Text())
+		if m == nil {
+			continue
+		}
+		at, err := time.Parse(layout, m[1])
+		if err != nil {
+			continue
+		}
+		hits = append(hits, hit{at: at, path: m[2]})
+	}
+	return hits, scanner.Err()
+}
+
+func readCSV(r io.Reader, prefix string) ([]hit, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+
+	var hits []hit
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return hits, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(record) < 2 {
+			continue
+		}
+		at, err := time.Parse(time.RFC3339, strings.TrimSpace(record[0]))
+		if err != nil {
+			// Skip header rows and malformed lines
+			continue
+		}
+		hits = append(hits, hit{at: at, path: prefix + strings.TrimSpace(record[1])})
+	}
+}
+
+type result struct {
+	mu        sync.Mutex
+	statuses  map[int]int
+	errors    int
+	latencies []time.Duration
+	elapsed   time.Duration
+}
+
+func replay(hits []hit, target string, speed float64, concurrency int) *result {
+	client := &http.Client{
+		Timeout: 10 * time.Second,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	res := &result{statuses: make(map[int]int)}
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+
+	start := time.Now()
+	first := hits[0].at
+	for _, h := range hits {
+		if speed > 0 {
+			due := start.Add(time.Duration(float64(h.at.Sub(first)) / speed))
+			time.Sleep(time.Until(due))
+		}
+
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(path string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			sent := time.Now()
+			resp, err := client.Get(target + path)
+			latency := time.Since(sent)
+
+			res.mu.Lock()
+			defer res.mu.Unlock()
+			if err != nil {
+				res.errors++
+				return
+			}
+			resp.Body.Close()
+			res.statuses[resp.StatusCode]++
+			res.latencies = append(res.latencies, latency)
+		}(h.path)
+	}
+	wg.Wait()
+	res.elapsed = time.Since(start)
+
+	return res
+}
+
+func (r *result) print(w io.Writer) {
+	total := len(r.latencies) + r.errors
+	fmt.Fprintf(w, "requests: %d in %s (%.1f req/s)\n", total, r.elapsed.Round(time.Millisecond), float64(total)/r.elapsed.Seconds())
+	fmt.Fprintf(w, "errors:   %d\n", r.errors)
+
+	codes := make([]int, 0, len(r.statuses))
+	for code := range r.statuses {
+		codes = append(codes, code)
+	}
+	sort.Ints(codes)
+	for _, code := range codes {
+		fmt.Fprintf(w, "status %d: %d\n", code, r.statuses[code])
+	}
+
+	if len(r.latencies) == 0 {
+		return
+	}
+	sort.Slice(r.latencies, func(i, j int) bool { return r.latencies[i] < r.latencies[j] })
+	for _, p := range []float64{50, 90, 99} {
+		idx := int(float64(len(r.latencies)-1) * p / 100)
+		fmt.Fprintf(w, "p%.0f:      %s\n", p, r.latencies[idx])
+	}
+	fmt.Fprintf(w, "max:      %s\n", r.latencies[len(r.latencies)-1])
+}
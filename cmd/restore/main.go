@@ -0,0 +1,59 @@
+// Command restore loads a backup written by the BACKUP_INTERVAL job from the
+// configured object storage into the database. It reads the same environment
+// (or .env file) as the server.
+//
+// Tables must be empty unless -truncate is given, which replaces their rows.
+//
+// Usage:
+//
+//	go run ./cmd/restore                                  # most recent backup
+//	go run ./cmd/restore -backup backups/20240101T000000Z  # a specific backup
+//	go run ./cmd/restore -truncate                        # overwrite existing data
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"log"
+	"url-shortener/config"
+	"url-shortener/db"
+	"url-shortener/pkg/backup"
+	"url-shortener/pkg/objectstore"
+
+	"github.com/joho/godotenv"
+)
+
+func main() {
+	prefix := flag.String("backup", "", "backup to restore, e.g. backups/20240101T000000Z (defaults to the latest)")
+	truncate := flag.Bool("truncate", false, "discard existing rows in the restored tables first")
+	flag.Parse()
+
+	if err := godotenv.Load(); err != nil {
+		log.Println("Warning: .env file not found, using environment variables")
+	}
+	cfg := config.GetDefaultConfig()
+
+	store, err := objectstore.FromConfig(cfg)
+	if err != nil {
+		log.Fatalf("Failed to configure object storage: %v", err)
+	}
+	if store == nil {
+		log.Fatal("OBJECT_STORE must be set to the storage the backups were written to")
+	}
+
+	database, err := db.InitDB(cfg)
+	if err != nil {
+		log.Fatalf("Failed to initialize database: %v", err)
+	}
+	defer database.Close()
+
+	restored, err := backup.Restore(context.Background(), database, store, *prefix, *truncate)
+	if errors.Is(err, db.ErrNotEmpty) {
+		log.Fatalf("Failed to restore: %v (pass -truncate to replace existing data)", err)
+	}
+	if err != nil {
+		log.Fatalf("Failed to restore: %v", err)
+	}
+	log.Printf("Restored %s", restored)
+}
@@ -0,0 +1,217 @@
+// Command seed fills a development database with links and months of click
+// history, so dashboards have something to show and load tests start from a
+// warm dataset. It reads the same environment (or .env file) as the server.
+//
+// Click counts follow a long tail: a few links get most of the clicks. Each
+// link's clicks peak around a launch day within -days and fade after it,
+// mostly in the evening, with a device, browser, OS and country mix like
+// real traffic. The same -seed yields the same links and clicks.
+//
+// Usage:
+//
+//	go run ./cmd/seed                                   # 100 links, ~200 clicks each over 90 days
+//	go run ./cmd/seed -links 1000 -clicks 500 -days 180
+//	go run ./cmd/seed -owner 1 -codes codes.txt         # links of user 1, codes written for cmd/loadgen
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"math"
+	"math/rand/v2"
+	"os"
+	"strings"
+	"time"
+	"url-shortener/config"
+	"url-shortener/db"
+
+	"github.com/joho/godotenv"
+)
+
+// insertBatch is how many clicks are inserted per statement
+const insertBatch = 10000
+
+// choice is a value picked with a relative weight
+type choice struct {
+	value  string
+	weight int
+}
+
+func pick(r *rand.Rand, choices []choice) string {
+	total := 0
+	for _, c := range choices {
+		total += c.weight
+	}
+	n := r.IntN(total)
+	for _, c := range choices {
+		if n < c.weight {
+			return c.value
+		}
+		n -= c.weight
+	}
+	return choices[len(choices)-1].value
+}
+
+var (
+	hosts    = []string{"example.com", "shop.example.com", "blog.example.org", "docs.example.net", "news.example.io"}
+	sections = []string{"products", "blog", "docs", "events", "careers", "offers"}
+	words    = []string{"spring", "guide", "launch", "pricing", "team", "release", "summer", "tips", "update", "webinar", "story", "deal"}
+	tags     = []string{"marketing", "social", "email", "launch", "docs", "support", "newsletter", "partners"}
+
+	campaigns = []choice{{"", 6}, {"spring-sale", 1}, {"product-launch", 1}, {"newsletter-weekly", 1}, {"black-friday", 1}}
+	devices   = []choice{{"mobile", 55}, {"desktop", 38}, {"tablet", 7}}
+	countries = []choice{{"US", 35}, {"GB", 10}, {"DE", 9}, {"IN", 8}, {"FR", 7}, {"BR", 6}, {"CA", 5}, {"JP", 5}, {"NL", 4}, {"AU", 4}, {"", 7}}
+
+	// systems and browsers are the names the user agent parser reports
+	systems = map[string][]choice{
+		"mobile":  {{"Android", 55}, {"iPhone OS", 45}},
+		"tablet":  {{"iPhone OS", 60}, {"Android", 40}},
+		"desktop": {{"Windows", 60}, {"Mac OS X", 30}, {"Linux", 10}},
+	}
+	browsers = map[string][]choice{
+		"Android":   {{"Chrome", 85}, {"Firefox", 10}, {"Opera", 5}},
+		"iPhone OS": {{"Safari", 80}, {"Chrome", 20}},
+		"Windows":   {{"Chrome", 70}, {"Edge", 20}, {"Firefox", 10}},
+		"Mac OS X":  {{"Chrome", 50}, {"Safari", 40}, {"Firefox", 10}},
+		"Linux":     {{"Chrome", 60}, {"Firefox", 40}},
+	}
+
+	// hourWeights is the share of clicks in each UTC hour, peaking in the evening
+	hourWeights = []int{2, 1, 1, 1, 1, 2, 3, 4, 5, 6, 6, 6, 7, 6, 6, 6, 7, 8, 9, 10, 10, 8, 5, 3}
+)
+
+func main() {
+	links := flag.Int("links", 100, "links to create")
+	clicks := flag.Int("clicks", 200, "average clicks per link")
+	days := flag.Int("days", 90, "days of click history")
+	owner := flag.String("owner", "", "user ID owning the links, so they show on that user's dashboard")
+	org := flag.Int("org", 0, "organization the links belong to")
+	tenant := flag.Int("tenant", 0, "tenant the links belong to")
+	seed := flag.Uint64("seed", 1, "random seed")
+	codes := flag.String("codes", "", "write the created short codes to this file, one per line")
+	flag.Parse()
+
+	if *links <= 0 || *clicks < 0 || *days <= 0 {
+		log.Fatal("-links and -days must be positive and -clicks not negative")
+	}
+
+	if err := godotenv.Load(); err != nil {
+		log.Println("Warning: .env file not found, using environment variables")
+	}
+	cfg := config.GetDefaultConfig()
+
+	database, err := db.InitDB(cfg)
+	if err != nil {
+		log.Fatalf("Failed to initialize database: %v", err)
+	}
+	defer database.Close()
+
+	ctx := db.WithTenant(context.Background(), *tenant)
+	r := rand.New(rand.NewPCG(*seed, *seed))
+	counts := clickCounts(*links, *clicks)
+	now := time.Now().UTC()
+
+	created := make([]string, 0, *links)
+	var total int
+	for i := 0; i < *links; i++ {
+		id, code, err := database.CreateSequencedURL(ctx, newLink(r, *owner, *org))
+		if err != nil {
+			log.Fatalf("Failed to create link: %v", err)
+		}
+		history := clickHistory(r, counts[i], now, *days)
+		for start := 0; start < len(history); start += insertBatch {
+			if err := database.InsertClicks(ctx, id, history[start:min(start+insertBatch, len(history))]); err != nil {
+				log.Fatalf("Failed to insert clicks of %s: %v", code, err)
+			}
+		}
+		created = append(created, code)
+		total += len(history)
+	}
+
+	if *codes != "" {
+		if err := os.WriteFile(*codes, []byte(strings.Join(created, "\n")+"\n"), 0o644); err != nil {
+			log.Fatalf("Failed to write short codes: %v", err)
+		}
+	}
+	log.Printf("Created %d links with %d clicks over the last %d days", len(created), total, *days)
+}
+
+// newLink makes up a link to a plausible page, with some tags and, for some,
+// a campaign
+func newLink(r *rand.Rand, owner string, org int) db.NewURL {
+	slug := words[r.IntN(len(words))] + "-" + words[r.IntN(len(words))]
+	link := db.NewURL{
+		OriginalURL: fmt.Sprintf("https://%s/%s/%s-%d", hosts[r.IntN(len(hosts))], sections[r.IntN(len(sections))], slug, r.IntN(1000)),
+		OwnerID:     owner,
+		OrgID:       org,
+		Campaign:    pick(r, campaigns),
+		Tags:        []string{},
+	}
+	for _, i := range r.Perm(len(tags))[:r.IntN(3)] {
+		link.Tags = append(link.Tags, tags[i])
+	}
+	return link
+}
+
+// clickCounts spreads links*average clicks over the links along a Zipf-like
+// long tail, the first link getting the most
+func clickCounts(links, average int) []int {
+	weights := make([]float64, links)
+	var sum float64
+	for i := range weights {
+		weights[i] = 1 / math.Pow(float64(i+1), 1.07)
+		sum += weights[i]
+	}
+	counts := make([]int, links)
+	for i, w := range weights {
+		counts[i] = int(math.Round(float64(links*average) * w / sum))
+	}
+	return counts
+}
+
+// clickHistory makes up n clicks within the last days before now, fading
+// out from a launch day picked at random
+func clickHistory(r *rand.Rand, n int, now time.Time, days int) []db.PastClick {
+	span := time.Duration(days) * 24 * time.Hour
+	launch := now.Add(-time.Duration(r.Int64N(int64(span))))
+	// Most clicks come within the first couple of weeks after launch
+	fade := span / 6
+
+	clicks := make([]db.PastClick, n)
+	for i := range clicks {
+		at := launch.Add(time.Duration(r.ExpFloat64() * float64(fade)))
+		if at.After(now) {
+			at = launch.Add(time.Duration(r.Int64N(int64(now.Sub(launch)) + 1)))
+		}
+		day := at.Truncate(24 * time.Hour)
+		at = day.Add(time.Duration(pickHour(r))*time.Hour + time.Duration(r.Int64N(int64(time.Hour))))
+		if at.After(now) {
+			at = now.Add(-time.Duration(r.Int64N(int64(time.Hour))))
+		}
+
+		device := pick(r, devices)
+		system := pick(r, systems[device])
+		clicks[i] = db.PastClick{
+			Click: db.Click{Device: device, OS: system, Browser: pick(r, browsers[system]), Country: pick(r, countries)},
+			At:    at,
+		}
+	}
+	return clicks
+}
+
+func pickHour(r *rand.Rand) int {
+	total := 0
+	for _, w := range hourWeights {
+		total += w
+	}
+	n := r.IntN(total)
+	for hour, w := range hourWeights {
+		if n < w {
+			return hour
+		}
+		n -= w
+	}
+	return len(hourWeights) - 1
+}
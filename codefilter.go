@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"time"
+	"url-shortener/config"
+	"url-shortener/db"
+	"url-shortener/pkg/base62"
+	"url-shortener/pkg/bloom"
+	"url-shortener/pkg/jobs"
+)
+
+// codeFilterOverlap is how far each refresh looks back past the previous one,
+// covering links whose insert had not yet committed when it ran
+const codeFilterOverlap = time.Minute
+
+// codeFilterHeadroom is the spare capacity a filter is built with, as a
+// multiple of the links it starts with, before it has to be rebuilt
+const codeFilterHeadroom = 2
+
+// codeFilter holds every existing short code, so probes for codes that were
+// never created are answered without a database query; nil when disabled
+var codeFilter atomic.Pointer[bloom.Filter]
+var codeFilterRate float64
+
+// codeFilterMark is the highest link ID the filter covers: every link with a
+// lower ID was created, on whichever instance, before the filter last took
+// in new codes. Sequence codes of higher IDs may belong to links created
+// since, so they are looked up.
+var codeFilterMark atomic.Int64
+
+// configureCodeFilter loads the codes of all links and, with
+// CODE_FILTER_REFRESH set, keeps adding new ones until ctx ends
+func configureCodeFilter(ctx context.Context, cfg *config.Config) error {
+	if cfg.CodeFilter.Refresh <= 0 {
+		return nil
+	}
+	codeFilterRate = cfg.CodeFilter.FalsePositiveRate
+
+	asOf, err := loadCodeFilter(ctx)
+	if err != nil {
+		return err
+	}
+	backgroundJobs.Schedule(jobs.Job{Name: "refresh-code-filter", Every: cfg.CodeFilter.Refresh, Run: refreshCodeFilter(asOf)})
+	return nil
+}
+
+// loadCodeFilter builds a filter from every link's code, returning the
+// database time it reflects
+func loadCodeFilter(ctx context.Context) (time.Time, error) {
+	codes, asOf, err := database.GetShortCodesSince(ctx, time.Time{})
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	filter := bloom.New(max(codeFilterHeadroom*len(codes), 10000), codeFilterRate)
+	for _, code := range codes {
+		filter.Add(code)
+	}
+	if err := advanceCodeFilterMark(ctx, asOf); err != nil {
+		return time.Time{}, err
+	}
+	codeFilter.Store(filter)
+	return asOf, nil
+}
+
+// advanceCodeFilterMark moves the mark up to the links the filter holds as of
+// asOf. IDs are drawn from the sequence in order and used within
+// db.IDLeaseLifetime, so every ID below that of a link created before
+// asOf, less the lease lifetime and the overlap for commits in flight, has
+// been taken in or will never be used.
+func advanceCodeFilterMark(ctx context.Context, asOf time.Time) error {
+	id, err := database.GetLastLinkIDBefore(ctx, asOf.Add(-db.IDLeaseLifetime-codeFilterOverlap))
+	if err != nil {
+		return err
+	}
+	codeFilterMark.Store(id)
+	return nil
+}
+
+// refreshCodeFilter returns the job that adds links created on any instance
+// since its last run, starting from asOf, and rebuilds the filter once it
+// outgrows its capacity
+func refreshCodeFilter(asOf time.Time) jobs.Func {
+	return func(ctx context.Context) error {
+		filter := codeFilter.Load()
+		if filter.Full() {
+			loaded, err := loadCodeFilter(ctx)
+			if err != nil {
+				return fmt.Errorf("rebuilding short code filter: %w", err)
+			}
+			asOf = loaded
+			return nil
+		}
+
+		codes, loaded, err := database.GetShortCodesSince(ctx, asOf.Add(-codeFilterOverlap))
+		if err != nil {
+			return err
+		}
+		for _, code := range codes {
+			filter.Add(code)
+		}
+		if err := advanceCodeFilterMark(ctx, loaded); err != nil {
+			return err
+		}
+		asOf = loaded
+		return nil
+	}
+}
+
+// rememberCode adds a link created by this instance to the filter right away
+func rememberCode(shortCode string) {
+	if filter := codeFilter.Load(); filter != nil {
+		filter.Add(shortCode)
+	}
+}
+
+// codeMayExist reports whether shortCode may belong to a link. False means
+// it certainly does not, so the lookup can be skipped. Sequence codes above
+// the mark always may, as other instances may have created them since the
+// last refresh.
+func codeMayExist(shortCode string) bool {
+	filter := codeFilter.Load()
+	if filter == nil || filter.Test(shortCode) {
+		return true
+	}
+	id, err := base62.DecodeCode(shortCode)
+	return err == nil && id > codeFilterMark.Load() && base62.EncodeID(id) == shortCode
+}
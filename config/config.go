@@ -1,17 +1,502 @@
 package config
 
+import (
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
 type Config struct {
+	Server struct {
+		ShutdownTimeout time.Duration
+		BasePath        string
+		BaseURL         string
+		LegacySunset    time.Time
+		TrustedProxies  []string
+		ClientIPHeaders []string
+		RequestTimeout  time.Duration
+		MaxBodyBytes    int
+		MaxURLLength    int
+	}
+	TLS struct {
+		CertFile         string
+		KeyFile          string
+		AutocertDomains  []string
+		AutocertCacheDir string
+		AutocertEmail    string
+		RedirectPort     string
+		HSTSMaxAge       time.Duration
+	}
 	RateLimit struct {
 		Enabled           bool
 		RequestsPerMinute int
+		Algorithm         string
+		Adaptive          struct {
+			Enabled          bool
+			LatencyThreshold time.Duration
+			ErrorThreshold   float64
+			MinFactor        float64
+			Interval         time.Duration
+		}
+	}
+	// LoadShed bounds the requests in flight, rejecting stats and listings
+	// first when the database slows down
+	LoadShed struct {
+		Enabled        bool
+		MinConcurrency int
+		MaxConcurrency int
+		TargetLatency  time.Duration
+	}
+	Admin struct {
+		Token string
+	}
+	Security struct {
+		IPRulesRefresh time.Duration
+		// SignedURLSecret signs time-limited stats and edit URLs for single links
+		SignedURLSecret string
+	}
+	CodeFilter struct {
+		Refresh           time.Duration
+		FalsePositiveRate float64
+	}
+	Captcha struct {
+		Provider  string
+		Secret    string
+		Threshold int
+		Timeout   time.Duration
+	}
+	OAuth struct {
+		GoogleClientID     string
+		GoogleClientSecret string
+		GitHubClientID     string
+		GitHubClientSecret string
+		CallbackURL        string
+		SessionTTL         time.Duration
+	}
+	JWT struct {
+		HMACSecret string
+		JWKSURL    string
+		Issuer     string
+		Audience   string
+		RoleClaim  string
+		WriteRoles []string
+	}
+	Database struct {
+		MaxOpenConns    int
+		MaxIdleConns    int
+		ConnMaxLifetime time.Duration
+		ConnectRetries  int
+		RetryBackoff    time.Duration
+		QueryTimeout    time.Duration
+		ReplicaDSNs     []string
+		IDBlockSize     int
+		LinkCacheSize   int
+		LinkCacheTTL    time.Duration
+		// LinkCacheStaleIfError is how long past its TTL a cached link is
+		// still used for redirects while the database fails
+		LinkCacheStaleIfError time.Duration
+		// ClickPartitioning partitions the clicks table by month
+		ClickPartitioning bool
+		// PreparedStatements prepares the redirect and link creation queries
+		// once per connection; PgBouncer in transaction mode needs it off
+		PreparedStatements bool
+	}
+	ObjectStore struct {
+		Backend     string
+		Dir         string
+		S3Endpoint  string
+		S3Region    string
+		S3Bucket    string
+		S3AccessKey string
+		S3SecretKey string
+	}
+	Archive struct {
+		OnDelete bool
+	}
+	Backup struct {
+		Interval time.Duration
+	}
+	Warehouse struct {
+		ExportInterval time.Duration
+	}
+	Debug struct {
+		Addr                 string
+		MutexProfileFraction int
+		BlockProfileRate     int
+		// ExplainThreshold logs the plan of queries slower than it, 0 disables
+		ExplainThreshold time.Duration
+	}
+	Pages struct {
+		TemplatesDir string
+		StaticDir    string
+		Theme        struct {
+			LogoURL         string
+			PrimaryColor    string
+			BackgroundColor string
+			TextColor       string
+			Footer          string
+		}
+	}
+	PageMeta struct {
+		Enabled       bool
+		Timeout       time.Duration
+		UserAgent     string
+		RespectRobots bool
+	}
+	BotFilter struct {
+		Enabled       bool
+		ExtraPatterns []string
+		AllowPatterns []string
+	}
+	ClickFraud struct {
+		Enabled         bool
+		IPLimit         int
+		IPWindow        time.Duration
+		SpikeFactor     float64
+		SpikeMinClicks  int
+		DatacenterCIDRs []string
+		DatacenterFile  string
+	}
+	Events struct {
+		KafkaBrokers   []string
+		KafkaTopic     string
+		LinkTopic      string
+		OutboxInterval time.Duration
+		CountryHeader  string
+	}
+	SMTP struct {
+		Host      string
+		Port      int
+		Username  string
+		Password  string
+		From      string
+		QueueSize int
+	}
+	Notifications struct {
+		ClickMilestones []int
+	}
+	Privacy struct {
+		ClickRetentionDays int
+		IPHashSalt         string
+		VisitorCookie      bool
+	}
+	Stats struct {
+		AggregateInterval time.Duration
+	}
+	Inactivity struct {
+		Months int
+		Action string
+	}
+	HealthCheck struct {
+		Interval    time.Duration
+		Timeout     time.Duration
+		Concurrency int
+		UserAgent   string
+		Notify      bool
+	}
+	AppLinks struct {
+		FallbackDelay time.Duration
+	}
+	Frames struct {
+		// AllowedHosts are the destination hosts links may show in frame mode
+		AllowedHosts []string
+	}
+	Pixels struct {
+		// RedirectDelay is how long the pixel page waits for pixels to fire
+		RedirectDelay time.Duration
+	}
+	ClickIDs struct {
+		// Param is the query parameter every redirect passes its click ID
+		// to the destination in; empty passes it on for links tracking
+		// conversions only
+		Param string
+	}
+	CORS struct {
+		AllowedOrigins []string
+	}
+	Reload struct {
+		WatchInterval time.Duration
+	}
+	EdgeCache struct {
+		TTL          time.Duration
+		BrowserTTL   time.Duration
+		Provider     string
+		Zone         string
+		APIToken     string
+		PurgeTimeout time.Duration
+	}
+	Features struct {
+		Environment string
+		Enabled     []string
+	}
+	Jobs struct {
+		Workers   int
+		QueueSize int
+	}
+	Quotas struct {
+		Plans       []string
+		DefaultPlan string
+	}
+	Billing struct {
+		StripeSecretKey     string
+		StripeWebhookSecret string
+		SuccessURL          string
+		CancelURL           string
+		Timeout             time.Duration
 	}
 }
 
 func GetDefaultConfig() *Config {
 	config := &Config{}
 
-	config.RateLimit.Enabled = true
-	config.RateLimit.RequestsPerMinute = 60
+	config.Server.ShutdownTimeout = getEnvDuration("SHUTDOWN_TIMEOUT", 15*time.Second)
+	config.Server.RequestTimeout = getEnvDuration("REQUEST_TIMEOUT", 10*time.Second)
+	config.Server.MaxBodyBytes = getEnvInt("MAX_BODY_BYTES", 2<<20)
+	config.Server.MaxURLLength = getEnvInt("MAX_URL_LENGTH", 2048)
+	config.Server.BasePath = "/" + strings.Trim(getEnv("BASE_PATH", "/"), "/")
+	config.Server.BaseURL = strings.TrimRight(getEnv("BASE_URL", ""), "/")
+	config.Server.LegacySunset, _ = time.Parse("2006-01-02", os.Getenv("LEGACY_API_SUNSET"))
+	config.Server.TrustedProxies = getEnvList("TRUSTED_PROXIES")
+	config.Server.ClientIPHeaders = getEnvList("CLIENT_IP_HEADER")
+	if len(config.Server.ClientIPHeaders) == 0 {
+		config.Server.ClientIPHeaders = []string{"X-Forwarded-For", "X-Real-IP"}
+	}
+
+	config.TLS.CertFile = getEnv("TLS_CERT_FILE", "")
+	config.TLS.KeyFile = getEnv("TLS_KEY_FILE", "")
+	config.TLS.AutocertDomains = getEnvList("TLS_AUTOCERT_DOMAINS")
+	config.TLS.AutocertCacheDir = getEnv("TLS_AUTOCERT_CACHE_DIR", "certs")
+	config.TLS.AutocertEmail = getEnv("TLS_AUTOCERT_EMAIL", "")
+	config.TLS.RedirectPort = getEnv("TLS_REDIRECT_PORT", "80")
+	config.TLS.HSTSMaxAge = getEnvDuration("HSTS_MAX_AGE", 365*24*time.Hour)
+
+	config.Admin.Token = getEnv("ADMIN_TOKEN", "")
+
+	config.Security.IPRulesRefresh = getEnvDuration("IP_RULES_REFRESH", time.Minute)
+	config.Security.SignedURLSecret = getEnv("SIGNED_URL_SECRET", "")
+
+	config.CodeFilter.Refresh = getEnvDuration("CODE_FILTER_REFRESH", 0)
+	config.CodeFilter.FalsePositiveRate = getEnvFloat("CODE_FILTER_FALSE_POSITIVE_RATE", 0.01)
+
+	config.Captcha.Provider = getEnv("CAPTCHA_PROVIDER", "")
+	config.Captcha.Secret = getEnv("CAPTCHA_SECRET", "")
+	config.Captcha.Threshold = getEnvInt("CAPTCHA_THRESHOLD", 0)
+	config.Captcha.Timeout = getEnvDuration("CAPTCHA_TIMEOUT", 5*time.Second)
+
+	config.OAuth.GoogleClientID = getEnv("OAUTH_GOOGLE_CLIENT_ID", "")
+	config.OAuth.GoogleClientSecret = getEnv("OAUTH_GOOGLE_CLIENT_SECRET", "")
+	config.OAuth.GitHubClientID = getEnv("OAUTH_GITHUB_CLIENT_ID", "")
+	config.OAuth.GitHubClientSecret = getEnv("OAUTH_GITHUB_CLIENT_SECRET", "")
+	config.OAuth.CallbackURL = getEnv("OAUTH_CALLBACK_URL", "")
+	config.OAuth.SessionTTL = getEnvDuration("SESSION_TTL", 30*24*time.Hour)
+
+	config.JWT.HMACSecret = getEnv("JWT_HMAC_SECRET", "")
+	config.JWT.JWKSURL = getEnv("JWT_JWKS_URL", "")
+	config.JWT.Issuer = getEnv("JWT_ISSUER", "")
+	config.JWT.Audience = getEnv("JWT_AUDIENCE", "")
+	config.JWT.RoleClaim = getEnv("JWT_ROLE_CLAIM", "role")
+	config.JWT.WriteRoles = getEnvList("JWT_WRITE_ROLES")
+	if len(config.JWT.WriteRoles) == 0 {
+		config.JWT.WriteRoles = []string{"editor", "admin"}
+	}
+
+	config.RateLimit.Enabled = getEnvBool("RATE_LIMIT_ENABLED", true)
+	config.RateLimit.RequestsPerMinute = getEnvInt("RATE_LIMIT_REQUESTS_PER_MINUTE", 60)
+	config.RateLimit.Algorithm = getEnv("RATE_LIMIT_ALGORITHM", "sliding_window")
+	config.RateLimit.Adaptive.Enabled = getEnvBool("RATE_LIMIT_ADAPTIVE", false)
+	config.RateLimit.Adaptive.LatencyThreshold = getEnvDuration("RATE_LIMIT_ADAPTIVE_LATENCY", 250*time.Millisecond)
+	config.RateLimit.Adaptive.ErrorThreshold = getEnvFloat("RATE_LIMIT_ADAPTIVE_ERROR_RATE", 0.05)
+	config.RateLimit.Adaptive.MinFactor = getEnvFloat("RATE_LIMIT_ADAPTIVE_MIN_FACTOR", 0.1)
+	config.RateLimit.Adaptive.Interval = getEnvDuration("RATE_LIMIT_ADAPTIVE_INTERVAL", 5*time.Second)
+	config.LoadShed.Enabled = getEnvBool("LOAD_SHED_ENABLED", false)
+	config.LoadShed.MinConcurrency = getEnvInt("LOAD_SHED_MIN_CONCURRENCY", 20)
+	config.LoadShed.MaxConcurrency = getEnvInt("LOAD_SHED_MAX_CONCURRENCY", 500)
+	config.LoadShed.TargetLatency = getEnvDuration("LOAD_SHED_TARGET_LATENCY", 100*time.Millisecond)
+
+	config.Database.MaxOpenConns = getEnvInt("DATABASE_MAX_OPEN_CONNS", 25)
+	config.Database.MaxIdleConns = getEnvInt("DATABASE_MAX_IDLE_CONNS", 10)
+	config.Database.ConnMaxLifetime = getEnvDuration("DATABASE_CONN_MAX_LIFETIME", 30*time.Minute)
+	config.Database.ConnectRetries = getEnvInt("DATABASE_CONNECT_RETRIES", 5)
+	config.Database.RetryBackoff = getEnvDuration("DATABASE_RETRY_BACKOFF", time.Second)
+	config.Database.QueryTimeout = getEnvDuration("DATABASE_QUERY_TIMEOUT", 3*time.Second)
+	config.Database.ReplicaDSNs = getEnvList("DATABASE_REPLICA_DSNS")
+	config.Database.IDBlockSize = getEnvInt("DATABASE_ID_BLOCK_SIZE", 50)
+	config.Database.LinkCacheSize = getEnvInt("LINK_CACHE_SIZE", 10000)
+	config.Database.LinkCacheTTL = getEnvDuration("LINK_CACHE_TTL", 10*time.Second)
+	config.Database.LinkCacheStaleIfError = getEnvDuration("LINK_CACHE_STALE_IF_ERROR", time.Hour)
+	config.Database.ClickPartitioning = getEnvBool("CLICK_PARTITIONING", false)
+	config.Database.PreparedStatements = getEnvBool("DATABASE_PREPARED_STATEMENTS", true)
+
+	config.ObjectStore.Backend = getEnv("OBJECT_STORE", "")
+	config.ObjectStore.Dir = getEnv("OBJECT_STORE_DIR", "data/objects")
+	config.ObjectStore.S3Endpoint = getEnv("S3_ENDPOINT", "https://s3.amazonaws.com")
+	config.ObjectStore.S3Region = getEnv("S3_REGION", "us-east-1")
+	config.ObjectStore.S3Bucket = getEnv("S3_BUCKET", "")
+	config.ObjectStore.S3AccessKey = getEnv("S3_ACCESS_KEY_ID", "")
+	config.ObjectStore.S3SecretKey = getEnv("S3_SECRET_ACCESS_KEY", "")
+
+	config.Archive.OnDelete = getEnvBool("ARCHIVE_ON_DELETE", false)
+
+	config.Backup.Interval = getEnvDuration("BACKUP_INTERVAL", 0)
+
+	config.Warehouse.ExportInterval = getEnvDuration("WAREHOUSE_EXPORT_INTERVAL", 0)
+
+	config.Debug.Addr = getEnv("DEBUG_ADDR", "")
+	config.Debug.MutexProfileFraction = getEnvInt("DEBUG_MUTEX_PROFILE_FRACTION", 10)
+	config.Debug.BlockProfileRate = getEnvInt("DEBUG_BLOCK_PROFILE_RATE", 0)
+	config.Debug.ExplainThreshold = getEnvDuration("DEBUG_EXPLAIN_SLOW_QUERIES", 0)
+
+	config.Pages.TemplatesDir = getEnv("TEMPLATES_DIR", "")
+	config.Pages.StaticDir = getEnv("STATIC_DIR", "")
+	config.Pages.Theme.LogoURL = getEnv("THEME_LOGO_URL", "")
+	config.Pages.Theme.PrimaryColor = getEnv("THEME_PRIMARY_COLOR", "#1a73e8")
+	config.Pages.Theme.BackgroundColor = getEnv("THEME_BACKGROUND_COLOR", "#ffffff")
+	config.Pages.Theme.TextColor = getEnv("THEME_TEXT_COLOR", "#202124")
+	config.Pages.Theme.Footer = getEnv("THEME_FOOTER", "")
+
+	config.PageMeta.Enabled = getEnvBool("PAGE_META_ENABLED", true)
+	config.PageMeta.Timeout = getEnvDuration("PAGE_META_TIMEOUT", 5*time.Second)
+	config.PageMeta.UserAgent = getEnv("PAGE_META_USER_AGENT", "url-shortener-bot/1.0")
+	config.PageMeta.RespectRobots = getEnvBool("PAGE_META_RESPECT_ROBOTS", true)
+
+	config.BotFilter.Enabled = getEnvBool("BOT_FILTER_ENABLED", true)
+	config.BotFilter.ExtraPatterns = getEnvList("BOT_FILTER_PATTERNS")
+	config.BotFilter.AllowPatterns = getEnvList("BOT_FILTER_ALLOW")
+
+	config.ClickFraud.Enabled = getEnvBool("CLICK_FRAUD_ENABLED", true)
+	config.ClickFraud.IPLimit = getEnvInt("CLICK_FRAUD_IP_LIMIT", 20)
+	config.ClickFraud.IPWindow = getEnvDuration("CLICK_FRAUD_IP_WINDOW", time.Minute)
+	config.ClickFraud.SpikeFactor = getEnvFloat("CLICK_FRAUD_SPIKE_FACTOR", 100)
+	config.ClickFraud.SpikeMinClicks = getEnvInt("CLICK_FRAUD_SPIKE_MIN_CLICKS", 100)
+	config.ClickFraud.DatacenterCIDRs = getEnvList("CLICK_FRAUD_DATACENTER_CIDRS")
+	config.ClickFraud.DatacenterFile = getEnv("CLICK_FRAUD_DATACENTER_FILE", "")
+
+	config.Events.KafkaBrokers = getEnvList("KAFKA_BROKERS")
+	config.Events.KafkaTopic = getEnv("KAFKA_CLICK_TOPIC", "url-clicks")
+	config.Events.LinkTopic = getEnv("KAFKA_LINK_TOPIC", "url-events")
+	config.Events.OutboxInterval = getEnvDuration("OUTBOX_POLL_INTERVAL", time.Second)
+	config.Events.CountryHeader = getEnv("GEO_COUNTRY_HEADER", "CF-IPCountry")
+
+	config.SMTP.Host = getEnv("SMTP_HOST", "")
+	config.SMTP.Port = getEnvInt("SMTP_PORT", 587)
+	config.SMTP.Username = getEnv("SMTP_USERNAME", "")
+	config.SMTP.Password = getEnv("SMTP_PASSWORD", "")
+	config.SMTP.From = getEnv("SMTP_FROM", "")
+	config.SMTP.QueueSize = getEnvInt("MAIL_QUEUE_SIZE", 100)
+
+	config.Notifications.ClickMilestones = getEnvIntList("CLICK_MILESTONES", []int{100, 1000, 10000, 100000})
+
+	config.Privacy.ClickRetentionDays = getEnvInt("CLICK_RETENTION_DAYS", 0)
+	config.Privacy.IPHashSalt = getEnv("IP_HASH_SALT", "")
+	config.Privacy.VisitorCookie = getEnvBool("VISITOR_COOKIE", true)
+
+	config.Stats.AggregateInterval = getEnvDuration("STATS_AGGREGATE_INTERVAL", time.Minute)
+
+	config.Inactivity.Months = getEnvInt("INACTIVE_LINK_MONTHS", 0)
+	config.Inactivity.Action = getEnv("INACTIVE_LINK_ACTION", "archive")
+
+	config.HealthCheck.Interval = getEnvDuration("HEALTH_CHECK_INTERVAL", 24*time.Hour)
+	config.HealthCheck.Timeout = getEnvDuration("HEALTH_CHECK_TIMEOUT", 10*time.Second)
+	config.HealthCheck.Concurrency = getEnvInt("HEALTH_CHECK_CONCURRENCY", 4)
+	config.HealthCheck.UserAgent = getEnv("HEALTH_CHECK_USER_AGENT", "url-shortener-health/1.0")
+	config.HealthCheck.Notify = getEnvBool("HEALTH_CHECK_NOTIFY", false)
+
+	config.AppLinks.FallbackDelay = getEnvDuration("APP_LINK_FALLBACK_DELAY", 1500*time.Millisecond)
+
+	config.Frames.AllowedHosts = getEnvList("FRAME_ALLOWED_HOSTS")
+	config.Pixels.RedirectDelay = getEnvDuration("PIXEL_REDIRECT_DELAY", 500*time.Millisecond)
+	config.ClickIDs.Param = strings.TrimSpace(os.Getenv("CLICK_ID_PARAM"))
+
+	config.CORS.AllowedOrigins = getEnvList("CORS_ALLOWED_ORIGINS")
+
+	config.Reload.WatchInterval = getEnvDuration("CONFIG_WATCH_INTERVAL", 0)
+
+	config.EdgeCache.TTL = getEnvDuration("REDIRECT_CACHE_TTL", 0)
+	config.EdgeCache.BrowserTTL = getEnvDuration("REDIRECT_BROWSER_CACHE_TTL", 0)
+	config.EdgeCache.Provider = getEnv("CDN_PROVIDER", "")
+	config.EdgeCache.Zone = getEnv("CDN_ZONE_ID", "")
+	config.EdgeCache.APIToken = getEnv("CDN_API_TOKEN", "")
+	config.EdgeCache.PurgeTimeout = getEnvDuration("CDN_PURGE_TIMEOUT", 10*time.Second)
+
+	config.Features.Environment = getEnv("APP_ENV", "production")
+	config.Features.Enabled = getEnvList("FEATURE_FLAGS")
+
+	config.Jobs.Workers = getEnvInt("JOB_WORKERS", 8)
+	config.Jobs.QueueSize = getEnvInt("JOB_QUEUE_SIZE", 100)
+
+	config.Quotas.Plans = getEnvList("QUOTA_PLANS")
+	config.Quotas.DefaultPlan = getEnv("QUOTA_DEFAULT_PLAN", "free")
+
+	config.Billing.StripeSecretKey = getEnv("STRIPE_SECRET_KEY", "")
+	config.Billing.StripeWebhookSecret = getEnv("STRIPE_WEBHOOK_SECRET", "")
+	config.Billing.SuccessURL = getEnv("BILLING_SUCCESS_URL", "")
+	config.Billing.CancelURL = getEnv("BILLING_CANCEL_URL", "")
+	config.Billing.Timeout = getEnvDuration("STRIPE_TIMEOUT", 10*time.Second)
 
 	return config
 }
+
+func getEnv(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
+
+// getEnvList splits a comma separated variable, dropping empty entries
+func getEnvList(key string) []string {
+	var values []string
+	for _, value := range strings.Split(os.Getenv(key), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+func getEnvInt(key string, fallback int) int {
+	if value, err := strconv.Atoi(os.Getenv(key)); err == nil {
+		return value
+	}
+	return fallback
+}
+
+// getEnvIntList parses a comma separated list of integers, falling back when
+// the variable is unset or any entry is invalid
+func getEnvIntList(key string, fallback []int) []int {
+	values := getEnvList(key)
+	if len(values) == 0 {
+		return fallback
+	}
+	ints := make([]int, 0, len(values))
+	for _, value := range values {
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return fallback
+		}
+		ints = append(ints, n)
+	}
+	return ints
+}
+
+func getEnvFloat(key string, fallback float64) float64 {
+	if value, err := strconv.ParseFloat(os.Getenv(key), 64); err == nil {
+		return value
+	}
+	return fallback
+}
+
+func getEnvBool(key string, fallback bool) bool {
+	if value, err := strconv.ParseBool(os.Getenv(key)); err == nil {
+		return value
+	}
+	return fallback
+}
+
+func getEnvDuration(key string, fallback time.Duration) time.Duration {
+	if value, err := time.ParseDuration(os.Getenv(key)); err == nil {
+		return value
+	}
+	return fallback
+}
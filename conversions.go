@@ -0,0 +1,103 @@
+package main
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"math"
+	"net/http"
+	"net/url"
+	"strings"
+	"url-shortener/db"
+	"url-shortener/pkg/apierror"
+
+	"github.com/gin-gonic/gin"
+)
+
+// clickIDParam is the query parameter every redirect passes its click ID to
+// the destination in, from CLICK_ID_PARAM; when unset, only links tracking
+// conversions pass it on, as defaultClickIDParam
+var clickIDParam string
+
+// defaultClickIDParam carries the click ID of links tracking conversions
+// while CLICK_ID_PARAM is unset
+const defaultClickIDParam = "clid"
+
+// defaultConversionName is the goal conversions are recorded for when the
+// destination site does not name one
+const defaultConversionName = "conversion"
+
+// newClickID returns a random ID unique to a redirect, which identifies its
+// click event and the conversions reported for it
+func newClickID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// clickIDParamFor returns the query parameter link passes click IDs to its
+// destination in, or "" if it doesn't
+func clickIDParamFor(link *db.URL) string {
+	switch {
+	case clickIDParam != "":
+		return clickIDParam
+	case link.TrackConversions:
+		return defaultClickIDParam
+	}
+	return ""
+}
+
+// withClickID passes a click ID on to link's destination. It is added before
+// the visitor's query is forwarded, so a parameter of the same name on the
+// short link cannot replace it.
+func withClickID(link *db.URL, destination, clickID string) string {
+	param := clickIDParamFor(link)
+	if param == "" || clickID == "" {
+		return destination
+	}
+	return withForwardedQuery(destination, url.Values{param: {clickID}})
+}
+
+// recordClickConversion records a conversion reported by a destination site
+// for the click ID it received at redirect time. Each click converts at most
+// once per goal, so retried reports are not counted twice.
+func recordClickConversion(c *gin.Context) {
+	var request struct {
+		ClickID string  `json:"clickId" binding:"required"`
+		Name    string  `json:"name"`
+		Value   float64 `json:"value"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		respondError(c, apierror.Validation("clickId is required"))
+		return
+	}
+	name := strings.TrimSpace(request.Name)
+	if name == "" {
+		name = defaultConversionName
+	}
+	if len(name) > 64 {
+		respondError(c, apierror.Validation("name must be at most 64 characters"))
+		return
+	}
+	if math.IsNaN(request.Value) || math.IsInf(request.Value, 0) || request.Value < 0 {
+		respondError(c, apierror.Validation("value must be a non-negative number"))
+		return
+	}
+
+	recorded, err := database.RecordClickConversion(c.Request.Context(), request.ClickID, name, request.Value)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondError(c, apierror.NotFound("Click not found"))
+		return
+	}
+	if err != nil {
+		respondError(c, apierror.Internal("Failed to record conversion").Wrap(err))
+		return
+	}
+	if !recorded {
+		c.JSON(http.StatusOK, gin.H{"message": "Conversion already recorded"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"message": "Conversion recorded"})
+}
@@ -0,0 +1,100 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// AnomalyReason is how often one kind of anomaly was seen on a link
+type AnomalyReason struct {
+	Reason      string    `json:"reason"`
+	Occurrences int64     `json:"occurrences"`
+	FirstSeen   time.Time `json:"firstSeen"`
+	LastSeen    time.Time `json:"lastSeen"`
+}
+
+// AnomalousLink is a link with abnormal click patterns
+type AnomalousLink struct {
+	ShortCode        string          `json:"shortCode"`
+	Clicks           int             `json:"clicks"`
+	SuspiciousClicks int             `json:"suspiciousClicks"`
+	Reasons          []AnomalyReason `json:"reasons"`
+}
+
+// recordAnomaly is the upsert counting one occurrence of an anomaly on the
+// link returned by a "url" CTE
+const recordAnomaly = `INSERT INTO click_anomalies (url_id, reason, occurrences)
+			  SELECT id, $2, 1 FROM url
+			  ON CONFLICT (url_id, reason) DO UPDATE
+			  SET occurrences = click_anomalies.occurrences + 1, last_seen = NOW()`
+
+// IncrementSuspiciousClickCount counts a click excluded by fraud detection,
+// kept apart from access_count so click stats only hold genuine visits
+func (db *Database) IncrementSuspiciousClickCount(ctx context.Context, shortCode, reason string) error {
+	ctx, cancel := db.queryContext(ctx)
+	defer cancel()
+
+	query := `WITH url AS (
+				UPDATE urls SET suspicious_clicks = suspicious_clicks + 1 WHERE short_code = $1 AND tenant_id = $3 AND COALESCE(domain, '') = $4 RETURNING id
+			  ) ` + recordAnomaly
+	_, err := db.conn.ExecContext(ctx, query, shortCode, reason, TenantFrom(ctx), DomainFrom(ctx))
+	return err
+}
+
+// RecordAnomaly flags a link for the anomaly report without excluding any clicks
+func (db *Database) RecordAnomaly(ctx context.Context, shortCode, reason string) error {
+	ctx, cancel := db.queryContext(ctx)
+	defer cancel()
+
+	query := `WITH url AS (SELECT id FROM urls WHERE short_code = $1 AND tenant_id = $3 AND COALESCE(domain, '') = $4) ` + recordAnomaly
+	_, err := db.conn.ExecContext(ctx, query, shortCode, reason, TenantFrom(ctx), DomainFrom(ctx))
+	return err
+}
+
+// GetAnomalousLinks lists up to limit of the tenant's links with anomalies,
+// most recently flagged first
+func (db *Database) GetAnomalousLinks(ctx context.Context, limit int) ([]AnomalousLink, error) {
+	query := `WITH flagged AS (
+				SELECT url_id, MAX(last_seen) AS last_seen FROM click_anomalies
+				WHERE url_id IN (SELECT id FROM urls WHERE tenant_id = $2)
+				GROUP BY url_id ORDER BY last_seen DESC LIMIT $1
+			  )
+			  SELECT u.short_code, u.access_count, u.suspicious_clicks,
+			  a.reason, a.occurrences, a.first_seen, a.last_seen
+			  FROM flagged f
+			  JOIN urls u ON u.id = f.url_id
+			  JOIN click_anomalies a ON a.url_id = f.url_id
+			  ORDER BY f.last_seen DESC, u.id, a.last_seen DESC`
+
+	var links []AnomalousLink
+	err := db.read(ctx, func(ctx context.Context, conn *sql.DB) error {
+		rows, err := conn.QueryContext(ctx, query, limit, TenantFrom(ctx))
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		links = make([]AnomalousLink, 0)
+		for rows.Next() {
+			var link AnomalousLink
+			var reason AnomalyReason
+			if err := rows.Scan(&link.ShortCode, &link.Clicks, &link.SuspiciousClicks,
+				&reason.Reason, &reason.Occurrences, &reason.FirstSeen, &reason.LastSeen); err != nil {
+				return err
+			}
+			// Rows of one link are adjacent, so a reason either extends the last link or starts a new one
+			if n := len(links); n > 0 && links[n-1].ShortCode == link.ShortCode {
+				links[n-1].Reasons = append(links[n-1].Reasons, reason)
+				continue
+			}
+			link.Reasons = []AnomalyReason{reason}
+			links = append(links, link)
+		}
+		return rows.Err()
+	})
+	if err != nil {
+		return nil, err
+	}
+	return links, nil
+}
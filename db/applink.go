@@ -0,0 +1,48 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+)
+
+// AppLink opens a link's content in a mobile app through a custom URI scheme.
+// Visitors without the app are sent to the store of their platform, or to
+// the link's usual destination when there is none.
+type AppLink struct {
+	URI          string `json:"uri"`
+	IOSStore     string `json:"iosStore,omitempty"`
+	AndroidStore string `json:"androidStore,omitempty"`
+}
+
+// SetAppLink replaces a link's app link; nil removes it
+func (db *Database) SetAppLink(ctx context.Context, shortCode string, link *AppLink) error {
+	ctx, cancel := db.queryContext(ctx)
+	defer cancel()
+
+	var encoded any
+	if link != nil {
+		b, err := json.Marshal(link)
+		if err != nil {
+			return err
+		}
+		encoded = b
+	}
+
+	query := `UPDATE urls SET app_link = $1, updated_at = NOW() WHERE short_code = $2 AND tenant_id = $3 AND COALESCE(domain, '') = $4 AND NOT locked`
+	result, err := db.conn.ExecContext(ctx, query, encoded, shortCode, TenantFrom(ctx), DomainFrom(ctx))
+	if err != nil {
+		return err
+	}
+	db.changed(ctx, shortCode)
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return db.missingOrLocked(ctx, shortCode)
+	}
+
+	return nil
+}
@@ -0,0 +1,114 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// AuditEntry records one mutating operation, with the entity's state before and after
+type AuditEntry struct {
+	ID         int64           `json:"id"`
+	OccurredAt time.Time       `json:"occurredAt"`
+	Actor      string          `json:"actor"`
+	Action     string          `json:"action"`
+	EntityType string          `json:"entityType"`
+	EntityID   string          `json:"entityId"`
+	OldValue   json.RawMessage `json:"oldValue,omitempty"`
+	NewValue   json.RawMessage `json:"newValue,omitempty"`
+	RemoteIP   string          `json:"remoteIp,omitempty"`
+}
+
+// AuditFilter narrows an audit log query; zero fields match everything
+type AuditFilter struct {
+	Actor      string
+	Action     string
+	EntityType string
+	EntityID   string
+	Since      time.Time
+	Until      time.Time
+	Limit      int
+}
+
+// RecordAudit appends an entry to the audit log of the tenant of ctx
+func (db *Database) RecordAudit(ctx context.Context, entry AuditEntry) error {
+	ctx, cancel := db.queryContext(ctx)
+	defer cancel()
+
+	query := `INSERT INTO audit_log (actor, action, entity_type, entity_id, old_value, new_value, remote_ip, tenant_id)
+			  VALUES ($1, $2, $3, $4, $5, $6, NULLIF($7, ''), $8)`
+	_, err := db.conn.ExecContext(ctx, query, entry.Actor, entry.Action, entry.EntityType, entry.EntityID,
+		nullJSON(entry.OldValue), nullJSON(entry.NewValue), entry.RemoteIP, TenantFrom(ctx))
+	return err
+}
+
+// GetAuditLog returns matching entries of the tenant of ctx, newest first
+func (db *Database) GetAuditLog(ctx context.Context, filter AuditFilter) ([]AuditEntry, error) {
+	var conditions []string
+	var args []any
+	add := func(condition string, value any) {
+		args = append(args, value)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+
+	add("tenant_id = $%d", TenantFrom(ctx))
+	if filter.Actor != "" {
+		add("actor = $%d", filter.Actor)
+	}
+	if filter.Action != "" {
+		add("action = $%d", filter.Action)
+	}
+	if filter.EntityType != "" {
+		add("entity_type = $%d", filter.EntityType)
+	}
+	if filter.EntityID != "" {
+		add("entity_id = $%d", filter.EntityID)
+	}
+	if !filter.Since.IsZero() {
+		add("occurred_at >= $%d", filter.Since)
+	}
+	if !filter.Until.IsZero() {
+		add("occurred_at < $%d", filter.Until)
+	}
+
+	query := `SELECT id, occurred_at, actor, action, entity_type, entity_id, old_value, new_value, COALESCE(remote_ip, '')
+			  FROM audit_log WHERE ` + strings.Join(conditions, " AND ")
+	args = append(args, filter.Limit)
+	query += fmt.Sprintf(` ORDER BY occurred_at DESC, id DESC LIMIT $%d`, len(args))
+
+	var entries []AuditEntry
+	err := db.read(ctx, func(ctx context.Context, conn *sql.DB) error {
+		rows, err := conn.QueryContext(ctx, query, args...)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		entries = make([]AuditEntry, 0)
+		for rows.Next() {
+			var e AuditEntry
+			var oldValue, newValue []byte
+			if err := rows.Scan(&e.ID, &e.OccurredAt, &e.Actor, &e.Action, &e.EntityType, &e.EntityID,
+				&oldValue, &newValue, &e.RemoteIP); err != nil {
+				return err
+			}
+			e.OldValue, e.NewValue = oldValue, newValue
+			entries = append(entries, e)
+		}
+		return rows.Err()
+	})
+	if err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+func nullJSON(v json.RawMessage) any {
+	if len(v) == 0 {
+		return nil
+	}
+	return []byte(v)
+}
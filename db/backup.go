@@ -0,0 +1,236 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+// BackupTables are the link and analytics tables a backup holds, parents
+// before the tables referencing them
+var BackupTables = []string{
+	"organizations",
+	"organization_members",
+	"campaigns",
+	"urls",
+	"url_versions",
+	"variant_stats",
+	"rotation_stats",
+	"clicks",
+	"click_rollups",
+	"click_anomalies",
+	"click_imports",
+	"click_import_records",
+}
+
+// derivedTables are left out of backups and emptied on restore: visitor
+// records only matter for the day, and hourly click counts are rebuilt from
+// the restored events and rollups
+var derivedTables = []string{"unique_visits", "click_hourly_rollups", "aggregator_state"}
+
+// ErrNotEmpty is returned by LoadTables when a table to restore already holds rows
+var ErrNotEmpty = errors.New("table is not empty")
+
+// nullField stands for NULL in dumped CSV, as in PostgreSQL's COPY text format
+const nullField = `\N`
+
+// DumpTables writes each of BackupTables as CSV with a header row through
+// create, reading all of them from one consistent snapshot of the primary.
+// It runs without the query timeout, since tables may be large.
+func (db *Database) DumpTables(ctx context.Context, create func(table string) (io.WriteCloser, error)) error {
+	tx, err := db.conn.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, table := range BackupTables {
+		if err := dumpTable(ctx, tx, table, create); err != nil {
+			return fmt.Errorf("dump %s: %w", table, err)
+		}
+	}
+	return nil
+}
+
+func dumpTable(ctx context.Context, tx *sql.Tx, table string, create func(string) (io.WriteCloser, error)) error {
+	columns, err := tableColumns(ctx, tx, table)
+	if err != nil {
+		return err
+	}
+
+	// Every value is read in PostgreSQL's own text form, which COPY parses back unchanged
+	selects := make([]string, len(columns))
+	for i, column := range columns {
+		selects[i] = pq.QuoteIdentifier(column) + "::TEXT"
+	}
+	rows, err := tx.QueryContext(ctx, `SELECT `+strings.Join(selects, ", ")+` FROM `+pq.QuoteIdentifier(table))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	// The writer is only closed once the whole table is written, so a failed
+	// dump never leaves a truncated file behind
+	w, err := create(table)
+	if err != nil {
+		return err
+	}
+
+	out := csv.NewWriter(w)
+	if err := out.Write(columns); err != nil {
+		return err
+	}
+
+	values := make([]sql.NullString, len(columns))
+	dest := make([]any, len(columns))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+	record := make([]string, len(columns))
+	for rows.Next() {
+		if err := rows.Scan(dest...); err != nil {
+			return err
+		}
+		for i, v := range values {
+			record[i] = nullField
+			if v.Valid {
+				record[i] = v.String
+			}
+		}
+		if err := out.Write(record); err != nil {
+			return err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	out.Flush()
+	if err := out.Error(); err != nil {
+		return err
+	}
+	return w.Close()
+}
+
+func tableColumns(ctx context.Context, tx *sql.Tx, table string) ([]string, error) {
+	rows, err := tx.QueryContext(ctx, `SELECT * FROM `+pq.QuoteIdentifier(table)+` LIMIT 0`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return rows.Columns()
+}
+
+// LoadTables restores CSV dumps written by DumpTables, reading each of tables
+// through open, in one transaction. Tables must be empty unless truncate is
+// set, in which case their current rows are discarded first. Serial ID
+// sequences are moved past the restored rows so new links get fresh codes.
+func (db *Database) LoadTables(ctx context.Context, tables []string, open func(table string) (io.ReadCloser, error), truncate bool) error {
+	tx, err := db.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Derived tables are emptied whether or not the restored ones are, and in
+	// the same statement, as they reference urls
+	discard := derivedTables
+	if truncate {
+		discard = append(slices.Clone(tables), derivedTables...)
+	}
+	quoted := make([]string, len(discard))
+	for i, table := range discard {
+		quoted[i] = pq.QuoteIdentifier(table)
+	}
+	if _, err := tx.ExecContext(ctx, `TRUNCATE `+strings.Join(quoted, ", ")); err != nil {
+		return err
+	}
+
+	for _, table := range tables {
+		if err := loadTable(ctx, tx, table, open); err != nil {
+			return fmt.Errorf("restore %s: %w", table, err)
+		}
+	}
+
+	for _, table := range tables {
+		columns, err := tableColumns(ctx, tx, table)
+		if err != nil {
+			return err
+		}
+		if !slices.Contains(columns, "id") {
+			continue
+		}
+		query := `SELECT setval(seq, COALESCE((SELECT MAX(id) FROM ` + pq.QuoteIdentifier(table) + `), 0) + 1, false)
+				  FROM pg_get_serial_sequence($1, 'id') AS seq WHERE seq IS NOT NULL`
+		if _, err := tx.ExecContext(ctx, query, table); err != nil {
+			return fmt.Errorf("reset %s sequence: %w", table, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	db.purge()
+	return nil
+}
+
+func loadTable(ctx context.Context, tx *sql.Tx, table string, open func(string) (io.ReadCloser, error)) error {
+	var exists bool
+	if err := tx.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM `+pq.QuoteIdentifier(table)+`)`).Scan(&exists); err != nil {
+		return err
+	}
+	if exists {
+		return ErrNotEmpty
+	}
+
+	r, err := open(table)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	in := csv.NewReader(r)
+	columns, err := in.Read()
+	if err != nil {
+		return err
+	}
+
+	stmt, err := tx.PrepareContext(ctx, pq.CopyIn(table, columns...))
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	values := make([]any, len(columns))
+	for {
+		record, err := in.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		for i, field := range record {
+			values[i] = field
+			if field == nullField {
+				values[i] = nil
+			}
+		}
+		if _, err := stmt.ExecContext(ctx, values...); err != nil {
+			return err
+		}
+	}
+
+	// An empty Exec flushes the buffered rows
+	if _, err := stmt.ExecContext(ctx); err != nil {
+		return err
+	}
+	return stmt.Close()
+}
@@ -0,0 +1,142 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"url-shortener/events"
+
+	"github.com/lib/pq"
+)
+
+// LinkSelection picks the links of a batch operation: the given short codes,
+// or every link with a tag and/or in a campaign. Links are those of the
+// domain of ctx.
+type LinkSelection struct {
+	ShortCodes []string `json:"shortCodes,omitempty"`
+	Tag        string   `json:"tag,omitempty"`
+	CampaignID int      `json:"campaignId,omitempty"`
+}
+
+// Empty reports whether the selection names no links, which would match every link
+func (s LinkSelection) Empty() bool {
+	return len(s.ShortCodes) == 0 && s.Tag == "" && s.CampaignID == 0
+}
+
+// BatchUpdate holds the changes applied to every link of a batch; nil fields
+// are left as they are
+type BatchUpdate struct {
+	ForwardQuery     *bool `json:"forwardQuery,omitempty"`
+	ForwardPath      *bool `json:"forwardPath,omitempty"`
+	PublicStats      *bool `json:"publicStats,omitempty"`
+	TrackConversions *bool `json:"trackConversions,omitempty"`
+	Frame            *bool `json:"frame,omitempty"`
+	// Tags replaces the tags of the links when not nil; empty removes them
+	Tags     []string        `json:"tags,omitempty"`
+	Notes    *string         `json:"notes,omitempty"`
+	Metadata json.RawMessage `json:"metadata,omitempty"`
+	// DisabledReason disables the links with that reason, or re-enables
+	// them when empty
+	DisabledReason *string `json:"disabledReason,omitempty"`
+}
+
+// Empty reports whether update changes nothing
+func (u BatchUpdate) Empty() bool {
+	return u.ForwardQuery == nil && u.ForwardPath == nil && u.PublicStats == nil && u.TrackConversions == nil &&
+		u.Frame == nil && u.Tags == nil && u.Notes == nil && u.Metadata == nil && u.DisabledReason == nil
+}
+
+// SelectLinks returns up to limit links of the tenant picked by sel, in the
+// order of sel.ShortCodes when given
+func (db *Database) SelectLinks(ctx context.Context, sel LinkSelection, limit int) ([]URL, error) {
+	ctx, cancel := db.queryContext(ctx)
+	defer cancel()
+
+	query := `SELECT ` + urlColumns + ` FROM urls
+			  WHERE tenant_id = $1 AND COALESCE(domain, '') = $2
+			  AND (CARDINALITY($3::TEXT[]) = 0 OR short_code = ANY($3))
+			  AND ($4 = '' OR $4 = ANY(tags))
+			  AND ($5 = 0 OR campaign_id = $5)
+			  ORDER BY ARRAY_POSITION($3::TEXT[], short_code), id LIMIT $6`
+	rows, err := db.conn.QueryContext(ctx, query, TenantFrom(ctx), DomainFrom(ctx), pq.Array(sel.ShortCodes), sel.Tag, sel.CampaignID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	links := make([]URL, 0)
+	for rows.Next() {
+		link, err := scanURL(rows)
+		if err != nil {
+			return nil, err
+		}
+		links = append(links, *link)
+	}
+	return links, rows.Err()
+}
+
+// UpdateLinks applies update to the links with the given IDs in one
+// transaction, skipping locked links, and returns the short codes updated
+func (db *Database) UpdateLinks(ctx context.Context, ids []int, update BatchUpdate) ([]string, error) {
+	ctx, cancel := db.queryContext(ctx)
+	defer cancel()
+
+	rows, err := db.conn.QueryContext(ctx, batchUpdateQuery, pq.Array(ids), TenantFrom(ctx),
+		update.ForwardQuery, update.ForwardPath, update.PublicStats, update.TrackConversions,
+		update.Notes, metadataJSON(update.Metadata), update.DisabledReason, update.Frame, pq.Array(update.Tags))
+	if err != nil {
+		return nil, err
+	}
+	return db.batchChanged(ctx, rows)
+}
+
+// batchUpdateQuery applies a BatchUpdate to the unlocked links with IDs $1
+// in tenant $2, returning their short codes and domains
+const batchUpdateQuery = `UPDATE urls SET
+				forward_query = COALESCE($3, forward_query),
+				forward_path = COALESCE($4, forward_path),
+				public_stats = COALESCE($5, public_stats),
+				track_conversions = COALESCE($6, track_conversions),
+				notes = COALESCE($7, notes),
+				metadata = COALESCE($8::JSONB, metadata),
+				disabled_at = CASE WHEN $9::TEXT IS NULL THEN disabled_at WHEN $9 = '' THEN NULL ELSE COALESCE(disabled_at, NOW()) END,
+				disabled_reason = CASE WHEN $9::TEXT IS NULL THEN disabled_reason ELSE NULLIF($9, '') END,
+				revived_at = CASE WHEN $9 = '' AND disabled_at IS NOT NULL THEN NOW() ELSE revived_at END,
+				frame = COALESCE($10, frame),
+				tags = COALESCE($11::TEXT[], tags),
+				updated_at = NOW()
+			  WHERE id = ANY($1) AND tenant_id = $2 AND NOT locked
+			  RETURNING short_code, COALESCE(domain, '')`
+
+// DeleteLinks deletes the links with the given IDs in one transaction,
+// skipping locked links, and returns the short codes deleted
+func (db *Database) DeleteLinks(ctx context.Context, ids []int) ([]string, error) {
+	ctx, cancel := db.queryContext(ctx)
+	defer cancel()
+
+	query := `WITH deleted AS (
+				DELETE FROM urls WHERE id = ANY($1) AND tenant_id = $2 AND NOT locked
+				RETURNING short_code, original, COALESCE(domain, '') AS domain
+			  )` + db.queueURLEvents(events.URLDeleted, "deleted") + `
+			  SELECT short_code, domain FROM deleted`
+	rows, err := db.conn.QueryContext(ctx, query, pq.Array(ids), TenantFrom(ctx))
+	if err != nil {
+		return nil, err
+	}
+	return db.batchChanged(ctx, rows)
+}
+
+// batchChanged evicts the links of a batch, read from rows of short codes and
+// domains, and returns their short codes
+func (db *Database) batchChanged(ctx context.Context, rows *sql.Rows) ([]string, error) {
+	links, err := scanLinkRefs(rows)
+	if err != nil {
+		return nil, err
+	}
+	codes := make([]string, 0, len(links))
+	for _, l := range links {
+		db.changed(WithDomain(ctx, l.domain), l.shortCode)
+		codes = append(codes, l.shortCode)
+	}
+	return codes, nil
+}
@@ -0,0 +1,154 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// ErrPriceTaken is returned when another plan is already sold at a Stripe price
+var ErrPriceTaken = errors.New("another plan is sold at this price")
+
+// BillingPlan is a plan sold through Stripe. Its name selects the limits of
+// the QUOTA_PLANS plan of the same name.
+type BillingPlan struct {
+	Name          string `json:"name"`
+	StripePriceID string `json:"stripePriceId,omitempty"`
+	// CustomDomains allows creating links on a custom domain
+	CustomDomains bool `json:"customDomains"`
+	// SSO allows an organization to sign its members in through SAML and
+	// provision them through SCIM
+	SSO       bool      `json:"sso"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// Subscription is a Stripe subscription paying for the plan of a user or,
+// when OrgID is set, an organization
+type Subscription struct {
+	ID               string     `json:"id"`
+	CustomerID       string     `json:"customerId"`
+	UserID           string     `json:"userId,omitempty"`
+	OrgID            int        `json:"orgId,omitempty"`
+	Plan             string     `json:"plan"`
+	Status           string     `json:"status"`
+	CurrentPeriodEnd *time.Time `json:"currentPeriodEnd,omitempty"`
+	UpdatedAt        time.Time  `json:"updatedAt"`
+}
+
+// GetBillingPlans lists the plans sold by name. It reads from the primary so
+// a change takes effect as soon as it is made.
+func (db *Database) GetBillingPlans(ctx context.Context) ([]BillingPlan, error) {
+	ctx, cancel := db.queryContext(ctx)
+	defer cancel()
+
+	rows, err := db.conn.QueryContext(ctx, `SELECT name, COALESCE(stripe_price_id, ''), custom_domains, sso, updated_at FROM plans ORDER BY name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	plans := make([]BillingPlan, 0)
+	for rows.Next() {
+		var p BillingPlan
+		if err := rows.Scan(&p.Name, &p.StripePriceID, &p.CustomDomains, &p.SSO, &p.UpdatedAt); err != nil {
+			return nil, err
+		}
+		plans = append(plans, p)
+	}
+	return plans, rows.Err()
+}
+
+// SetBillingPlan stores a plan, replacing any with the same name, and fills
+// in its update time. It returns ErrPriceTaken if another plan is sold at its price.
+func (db *Database) SetBillingPlan(ctx context.Context, plan *BillingPlan) error {
+	ctx, cancel := db.queryContext(ctx)
+	defer cancel()
+
+	query := `INSERT INTO plans (name, stripe_price_id, custom_domains, sso)
+			  SELECT $1, NULLIF($2, ''), $3, $4
+			  WHERE NOT EXISTS (SELECT 1 FROM plans WHERE stripe_price_id = $2 AND name <> $1)
+			  ON CONFLICT (name) DO UPDATE SET stripe_price_id = EXCLUDED.stripe_price_id,
+			  custom_domains = EXCLUDED.custom_domains, sso = EXCLUDED.sso, updated_at = NOW()
+			  RETURNING updated_at`
+	err := db.conn.QueryRowContext(ctx, query, plan.Name, plan.StripePriceID, plan.CustomDomains, plan.SSO).Scan(&plan.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrPriceTaken
+	}
+	return err
+}
+
+// DeleteBillingPlan stops selling a plan and returns it, or sql.ErrNoRows if
+// there is none with that name. Subscribers keep the plan until their
+// subscription ends.
+func (db *Database) DeleteBillingPlan(ctx context.Context, name string) (*BillingPlan, error) {
+	ctx, cancel := db.queryContext(ctx)
+	defer cancel()
+
+	query := `DELETE FROM plans WHERE name = $1 RETURNING name, COALESCE(stripe_price_id, ''), custom_domains, sso, updated_at`
+	var p BillingPlan
+	if err := db.conn.QueryRowContext(ctx, query, name).Scan(&p.Name, &p.StripePriceID, &p.CustomDomains, &p.SSO, &p.UpdatedAt); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// SaveSubscription records the state of a subscription as of eventAt and puts
+// its user or organization on plan ("" for the default plan). Webhooks may
+// arrive out of order, so a state older than the one recorded is ignored; it
+// reports whether this one was applied.
+func (db *Database) SaveSubscription(ctx context.Context, sub *Subscription, eventAt time.Time, plan string) (bool, error) {
+	ctx, cancel := db.queryContext(ctx)
+	defer cancel()
+
+	tx, err := db.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	query := `INSERT INTO subscriptions (id, customer_id, user_id, org_id, tenant_id, plan, status, current_period_end, event_at)
+			  VALUES ($1, $2, NULLIF($3, ''), NULLIF($4, 0), $5, $6, $7, $8, $9)
+			  ON CONFLICT (id) DO UPDATE SET plan = EXCLUDED.plan, status = EXCLUDED.status,
+			  current_period_end = EXCLUDED.current_period_end, event_at = EXCLUDED.event_at, updated_at = NOW()
+			  WHERE subscriptions.event_at <= EXCLUDED.event_at
+			  RETURNING updated_at`
+	err = tx.QueryRowContext(ctx, query, sub.ID, sub.CustomerID, sub.UserID, sub.OrgID, TenantFrom(ctx), sub.Plan, sub.Status,
+		sub.CurrentPeriodEnd, eventAt).Scan(&sub.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	if sub.OrgID > 0 {
+		_, err = tx.ExecContext(ctx, `UPDATE organizations SET plan = NULLIF($2, '') WHERE id = $1 AND tenant_id = $3`, sub.OrgID, plan, TenantFrom(ctx))
+	} else {
+		_, err = tx.ExecContext(ctx, `UPDATE users SET plan = NULLIF($2, '') WHERE id::TEXT = $1`, sub.UserID, plan)
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, tx.Commit()
+}
+
+// GetSubscription returns the most recently changed subscription of an
+// organization, or of a user's own account when orgID is 0, or
+// sql.ErrNoRows if there is none
+func (db *Database) GetSubscription(ctx context.Context, userID string, orgID int) (*Subscription, error) {
+	ctx, cancel := db.queryContext(ctx)
+	defer cancel()
+
+	query := `SELECT id, customer_id, COALESCE(user_id, ''), COALESCE(org_id, 0), plan, status, current_period_end, updated_at
+			  FROM subscriptions
+			  WHERE CASE WHEN $2 = 0 THEN user_id = $1 AND org_id IS NULL ELSE org_id = $2 END
+			  ORDER BY event_at DESC LIMIT 1`
+	var s Subscription
+	err := db.conn.QueryRowContext(ctx, query, userID, orgID).
+		Scan(&s.ID, &s.CustomerID, &s.UserID, &s.OrgID, &s.Plan, &s.Status, &s.CurrentPeriodEnd, &s.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
@@ -0,0 +1,130 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrDuplicateBlockedDestination is returned when the same rule is already on the blocklist
+var ErrDuplicateBlockedDestination = errors.New("blocked destination already exists")
+
+// BlockedDestination is a destination domain or URL pattern that cannot be shortened
+type BlockedDestination struct {
+	ID        int64     `json:"id"`
+	Kind      string    `json:"kind"`
+	Pattern   string    `json:"pattern"`
+	Note      string    `json:"note,omitempty"`
+	CreatedBy string    `json:"createdBy,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// GetBlockedDestinations lists the destination blocklist, oldest first. It
+// reads from the primary so a rule takes effect as soon as it is added.
+func (db *Database) GetBlockedDestinations(ctx context.Context) ([]BlockedDestination, error) {
+	ctx, cancel := db.queryContext(ctx)
+	defer cancel()
+
+	query := `SELECT id, kind, pattern, COALESCE(note, ''), COALESCE(created_by, ''), created_at
+			  FROM blocked_destinations ORDER BY id`
+	rows, err := db.conn.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	rules := make([]BlockedDestination, 0)
+	for rows.Next() {
+		var r BlockedDestination
+		if err := rows.Scan(&r.ID, &r.Kind, &r.Pattern, &r.Note, &r.CreatedBy, &r.CreatedAt); err != nil {
+			return nil, err
+		}
+		rules = append(rules, r)
+	}
+	return rules, rows.Err()
+}
+
+// AddBlockedDestination stores a rule, filling in its ID and creation time
+func (db *Database) AddBlockedDestination(ctx context.Context, rule *BlockedDestination) error {
+	ctx, cancel := db.queryContext(ctx)
+	defer cancel()
+
+	query := `INSERT INTO blocked_destinations (kind, pattern, note, created_by)
+			  VALUES ($1, $2, NULLIF($3, ''), NULLIF($4, ''))
+			  ON CONFLICT (kind, pattern) DO NOTHING
+			  RETURNING id, created_at`
+	err := db.conn.QueryRowContext(ctx, query, rule.Kind, rule.Pattern, rule.Note, rule.CreatedBy).
+		Scan(&rule.ID, &rule.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrDuplicateBlockedDestination
+	}
+	return err
+}
+
+// DeleteBlockedDestination removes a rule and returns it, or sql.ErrNoRows if there is none with that ID
+func (db *Database) DeleteBlockedDestination(ctx context.Context, id int64) (*BlockedDestination, error) {
+	ctx, cancel := db.queryContext(ctx)
+	defer cancel()
+
+	query := `DELETE FROM blocked_destinations WHERE id = $1
+			  RETURNING id, kind, pattern, COALESCE(note, ''), COALESCE(created_by, ''), created_at`
+	var r BlockedDestination
+	err := db.conn.QueryRowContext(ctx, query, id).
+		Scan(&r.ID, &r.Kind, &r.Pattern, &r.Note, &r.CreatedBy, &r.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// ForEachURL calls fn for every link of every tenant that is not disabled,
+// stopping at the first error.
+// Links are read from the primary so a scan sees the latest destinations.
+func (db *Database) ForEachURL(ctx context.Context, fn func(*URL) error) error {
+	rows, err := db.conn.QueryContext(ctx, `SELECT `+urlColumns+` FROM urls WHERE disabled_at IS NULL ORDER BY id`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		url, err := scanURL(rows)
+		if err != nil {
+			return err
+		}
+		if err := fn(url); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// SetDisabled stops a link from redirecting, recording why; an empty reason
+// re-enables it, restarting the count of its inactivity
+func (db *Database) SetDisabled(ctx context.Context, shortCode, reason string) error {
+	ctx, cancel := db.queryContext(ctx)
+	defer cancel()
+
+	query := `UPDATE urls SET disabled_at = CASE WHEN $1 = '' THEN NULL ELSE NOW() END,
+			  disabled_reason = NULLIF($1, ''), updated_at = NOW(),
+			  revived_at = CASE WHEN $1 = '' THEN NOW() ELSE revived_at END
+			  WHERE short_code = $2 AND tenant_id = $3 AND COALESCE(domain, '') = $4`
+	result, err := db.conn.ExecContext(ctx, query, reason, shortCode, TenantFrom(ctx), DomainFrom(ctx))
+	if err != nil {
+		return err
+	}
+	db.changed(ctx, shortCode)
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("%w: %s", ErrNotFound, shortCode)
+	}
+
+	return nil
+}
@@ -0,0 +1,117 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strconv"
+)
+
+// ResolveShortCode finds the URL for a short code, answering hot links from
+// the in-process cache. Concurrent misses for one code share a single query,
+// so a burst of hits on an uncached link reaches the database once.
+//
+// Writes through this instance evict the link at once; changes made on other
+// instances show up once the cached entry expires after LINK_CACHE_TTL.
+//
+// When the lookup fails for any reason but the link not existing, a link
+// that expired at most LINK_CACHE_STALE_IF_ERROR ago is returned instead,
+// marked Stale, so a database outage doesn't break links that were just
+// being served.
+func (db *Database) ResolveShortCode(ctx context.Context, shortCode string) (*URL, error) {
+	if db.links == nil {
+		return db.resolveShortCode(ctx, shortCode)
+	}
+	key := linkKey(ctx, shortCode)
+	if url, ok := db.links.Get(key); ok {
+		return copyURL(url), nil
+	}
+
+	// The shared lookup must not fail for every waiter when the client that
+	// started it goes away, so it only keeps the query timeout
+	shared, err, _ := db.lookups.Do(key, func() (any, error) {
+		url, err := db.resolveShortCode(context.WithoutCancel(ctx), shortCode)
+		if err != nil {
+			return nil, err
+		}
+		db.links.Add(key, url)
+		return url, nil
+	})
+	if err != nil {
+		if stale, ok := db.staleLink(key, err); ok {
+			return stale, nil
+		}
+		return nil, err
+	}
+	return copyURL(shared.(*URL)), nil
+}
+
+// staleLink returns the expired cache entry of a link whose lookup failed
+// with err, counting it in StaleLinksServed
+func (db *Database) staleLink(key string, err error) (*URL, bool) {
+	if db.staleIfError <= 0 || errors.Is(err, sql.ErrNoRows) {
+		return nil, false
+	}
+	url, ok := db.links.GetStale(key, db.staleIfError)
+	if !ok {
+		return nil, false
+	}
+	db.staleServed.Add(1)
+	stale := copyURL(url)
+	stale.Stale = true
+	return stale, true
+}
+
+// StaleLinksServed is how many lookups were answered from expired cache
+// entries because the database failed
+func (db *Database) StaleLinksServed() int64 {
+	return db.staleServed.Load()
+}
+
+// linkKey is the cache key of a link, as the same code may belong to several
+// tenants and domains
+func linkKey(ctx context.Context, shortCode string) string {
+	return strconv.Itoa(TenantFrom(ctx)) + "/" + DomainFrom(ctx) + "/" + shortCode
+}
+
+// copyURL keeps callers from modifying the cached entry
+func copyURL(url *URL) *URL {
+	c := *url
+	return &c
+}
+
+// forget evicts a link after it was changed or deleted
+func (db *Database) forget(ctx context.Context, shortCode string) {
+	if db.links == nil {
+		return
+	}
+	key := linkKey(ctx, shortCode)
+	db.lookups.Forget(key)
+	db.links.Remove(key)
+}
+
+// purge evicts every link after a write touching an unknown set of them
+func (db *Database) purge() {
+	if db.links != nil {
+		db.links.Purge()
+	}
+	if db.onChange != nil {
+		db.onChange()
+	}
+}
+
+// OnChange registers fn to be told the short codes of links whose redirect
+// changed through this instance, or none when any link may have, e.g. to
+// purge them from a CDN. It must be called before serving requests.
+func (db *Database) OnChange(fn func(shortCodes ...string)) {
+	db.onChange = fn
+}
+
+// changed evicts a link after a write that changes where or whether it
+// redirects, and tells the OnChange hook
+func (db *Database) changed(ctx context.Context, shortCode string) {
+	db.forget(ctx, shortCode)
+	if db.onChange != nil {
+		db.onChange(shortCode)
+	}
+}
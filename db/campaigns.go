@@ -0,0 +1,251 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Campaign groups short links so they can be reported on and retired together
+type Campaign struct {
+	ID        int       `json:"id"`
+	Name      string    `json:"name"`
+	OwnerID   string    `json:"ownerId,omitempty"`
+	Links     int       `json:"links"`
+	CreatedAt time.Time `json:"createdAt"`
+	// Pixels are the IDs of the retargeting pixels fired before the redirects of its links
+	Pixels []int64 `json:"pixels"`
+}
+
+// CampaignLinkStats is one link's share of a campaign's clicks
+type CampaignLinkStats struct {
+	ShortCode string `json:"shortCode"`
+	Clicks    int64  `json:"clicks"`
+	BotClicks int64  `json:"botClicks"`
+}
+
+// CampaignStats aggregates the clicks of every link in a campaign
+type CampaignStats struct {
+	Campaign
+	Clicks    int64               `json:"clicks"`
+	BotClicks int64               `json:"botClicks"`
+	Breakdown *ClickBreakdown     `json:"breakdown"`
+	URLs      []CampaignLinkStats `json:"urls"`
+}
+
+const campaignColumns = `c.id, c.name, COALESCE(c.owner_id, ''), c.created_at,
+	(SELECT COUNT(*) FROM urls WHERE urls.campaign_id = c.id), c.pixels`
+
+func scanCampaign(row rowScanner) (*Campaign, error) {
+	var c Campaign
+	if err := row.Scan(&c.ID, &c.Name, &c.OwnerID, &c.CreatedAt, &c.Links, pq.Array(&c.Pixels)); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// CreateCampaign creates an empty campaign owned by ownerID
+func (db *Database) CreateCampaign(ctx context.Context, name, ownerID string) (*Campaign, error) {
+	ctx, cancel := db.queryContext(ctx)
+	defer cancel()
+
+	campaign := Campaign{Name: name, OwnerID: ownerID, Pixels: []int64{}}
+	err := db.conn.QueryRowContext(ctx, `INSERT INTO campaigns (name, owner_id, tenant_id) VALUES ($1, NULLIF($2, ''), $3) RETURNING id, created_at`,
+		name, ownerID, TenantFrom(ctx)).Scan(&campaign.ID, &campaign.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &campaign, nil
+}
+
+// GetCampaign returns a campaign, or sql.ErrNoRows if the tenant has none with that ID
+func (db *Database) GetCampaign(ctx context.Context, id int) (*Campaign, error) {
+	var campaign *Campaign
+	err := db.read(ctx, func(ctx context.Context, conn *sql.DB) error {
+		var err error
+		campaign, err = scanCampaign(conn.QueryRowContext(ctx, `SELECT `+campaignColumns+` FROM campaigns c WHERE c.id = $1 AND c.tenant_id = $2`, id, TenantFrom(ctx)))
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return campaign, nil
+}
+
+// GetCampaigns lists the campaigns of ownerID, or every campaign of the tenant when ownerID is empty
+func (db *Database) GetCampaigns(ctx context.Context, ownerID string) ([]Campaign, error) {
+	query := `SELECT ` + campaignColumns + ` FROM campaigns c
+			  WHERE ($1 = '' OR c.owner_id = $1) AND c.tenant_id = $2 ORDER BY c.created_at DESC`
+
+	var campaigns []Campaign
+	err := db.read(ctx, func(ctx context.Context, conn *sql.DB) error {
+		rows, err := conn.QueryContext(ctx, query, ownerID, TenantFrom(ctx))
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		campaigns = make([]Campaign, 0)
+		for rows.Next() {
+			campaign, err := scanCampaign(rows)
+			if err != nil {
+				return err
+			}
+			campaigns = append(campaigns, *campaign)
+		}
+		return rows.Err()
+	})
+	if err != nil {
+		return nil, err
+	}
+	return campaigns, nil
+}
+
+// DeleteCampaign removes a campaign; its links stay but are detached from it
+func (db *Database) DeleteCampaign(ctx context.Context, id int) error {
+	affected, err := db.execCount(ctx, `DELETE FROM campaigns WHERE id = $1 AND tenant_id = $2`, id, TenantFrom(ctx))
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// AttachToCampaign moves links into a campaign and returns the codes that were
+// attached. Locked links are skipped, as are links not created by ownerID
+// unless ownerID is empty.
+func (db *Database) AttachToCampaign(ctx context.Context, id int, shortCodes []string, ownerID string) ([]string, error) {
+	ctx, cancel := db.queryContext(ctx)
+	defer cancel()
+
+	query := `UPDATE urls SET campaign_id = $1, updated_at = NOW()
+			  WHERE short_code = ANY($2) AND tenant_id = $4 AND COALESCE(domain, '') = $5 AND NOT locked AND ($3 = '' OR owner_id = $3)
+			  RETURNING short_code`
+	rows, err := db.conn.QueryContext(ctx, query, id, pq.Array(shortCodes), ownerID, TenantFrom(ctx), DomainFrom(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	attached := make([]string, 0, len(shortCodes))
+	for rows.Next() {
+		var shortCode string
+		if err := rows.Scan(&shortCode); err != nil {
+			return nil, err
+		}
+		attached = append(attached, shortCode)
+		// Links carry their campaign's pixels
+		db.changed(ctx, shortCode)
+	}
+	return attached, rows.Err()
+}
+
+// DetachFromCampaign removes a link from a campaign, returning sql.ErrNoRows
+// if the link is not part of it
+func (db *Database) DetachFromCampaign(ctx context.Context, id int, shortCode string) error {
+	query := `UPDATE urls SET campaign_id = NULL, updated_at = NOW() WHERE campaign_id = $1 AND short_code = $2 AND tenant_id = $3 AND COALESCE(domain, '') = $4`
+	affected, err := db.execCount(ctx, query, id, shortCode, TenantFrom(ctx), DomainFrom(ctx))
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return sql.ErrNoRows
+	}
+	db.changed(ctx, shortCode)
+	return nil
+}
+
+// GetCampaignURLs lists the links of a campaign, most recently updated first
+func (db *Database) GetCampaignURLs(ctx context.Context, id int) ([]URL, error) {
+	query := `SELECT ` + urlColumns + ` FROM urls WHERE campaign_id = $1 ORDER BY updated_at DESC`
+
+	var urls []URL
+	err := db.read(ctx, func(ctx context.Context, conn *sql.DB) error {
+		rows, err := conn.QueryContext(ctx, query, id)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		urls = make([]URL, 0)
+		for rows.Next() {
+			url, err := scanURL(rows)
+			if err != nil {
+				return err
+			}
+			urls = append(urls, *url)
+		}
+		return rows.Err()
+	})
+	if err != nil {
+		return nil, err
+	}
+	return urls, nil
+}
+
+// GetCampaignStats sums the clicks of a campaign's links, with the combined
+// device, browser and OS breakdown and each link's clicks, busiest first
+func (db *Database) GetCampaignStats(ctx context.Context, campaign *Campaign) (*CampaignStats, error) {
+	query := `SELECT short_code, access_count, bot_clicks FROM urls
+			  WHERE campaign_id = $1 ORDER BY access_count DESC, short_code`
+
+	stats := &CampaignStats{Campaign: *campaign, URLs: []CampaignLinkStats{}}
+	err := db.read(ctx, func(ctx context.Context, conn *sql.DB) error {
+		rows, err := conn.QueryContext(ctx, query, campaign.ID)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var link CampaignLinkStats
+			if err := rows.Scan(&link.ShortCode, &link.Clicks, &link.BotClicks); err != nil {
+				return err
+			}
+			stats.Clicks += link.Clicks
+			stats.BotClicks += link.BotClicks
+			stats.URLs = append(stats.URLs, link)
+		}
+		return rows.Err()
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	stats.Breakdown, err = db.clickBreakdown(ctx, `url_id IN (SELECT id FROM urls WHERE campaign_id = $1)`, campaign.ID)
+	if err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
+
+// ExpireCampaign disables every enabled link of a campaign with reason and
+// returns their codes
+func (db *Database) ExpireCampaign(ctx context.Context, id int, reason string) ([]string, error) {
+	ctx, cancel := db.queryContext(ctx)
+	defer cancel()
+
+	query := `UPDATE urls SET disabled_at = NOW(), disabled_reason = $2, updated_at = NOW()
+			  WHERE campaign_id = $1 AND disabled_at IS NULL
+			  RETURNING short_code, COALESCE(domain, '')`
+	rows, err := db.conn.QueryContext(ctx, query, id, reason)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	expired := []string{}
+	for rows.Next() {
+		var shortCode, domain string
+		if err := rows.Scan(&shortCode, &domain); err != nil {
+			return nil, err
+		}
+		expired = append(expired, shortCode)
+		db.changed(WithDomain(ctx, domain), shortCode)
+	}
+	return expired, rows.Err()
+}
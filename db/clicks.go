@@ -0,0 +1,202 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"sort"
+	"time"
+)
+
+// Click holds the details recorded for a single human redirect
+type Click struct {
+	Device  string
+	Browser string
+	OS      string
+	// Country is the ISO 3166-1 alpha-2 code of the visitor, empty when unknown
+	Country string
+	// ClickID is unique to the redirect and identifies the click in
+	// conversions reported for it
+	ClickID string
+}
+
+// BreakdownEntry is the number of clicks sharing one value of a dimension
+type BreakdownEntry struct {
+	Name   string `json:"name"`
+	Clicks int    `json:"clicks"`
+}
+
+// ClickBreakdown splits a link's recorded clicks by device type, browser and OS
+type ClickBreakdown struct {
+	Devices  []BreakdownEntry `json:"devices"`
+	Browsers []BreakdownEntry `json:"browsers"`
+	OS       []BreakdownEntry `json:"os"`
+}
+
+// GetClickBreakdown aggregates a link's clicks per device type, browser family and OS,
+// each sorted by descending click count
+func (db *Database) GetClickBreakdown(ctx context.Context, urlID int) (*ClickBreakdown, error) {
+	return db.clickBreakdown(ctx, `url_id = $1`, urlID)
+}
+
+// clickBreakdown aggregates the clicks matching where, which takes one
+// argument, including those already folded into daily rollups
+func (db *Database) clickBreakdown(ctx context.Context, where string, arg any) (*ClickBreakdown, error) {
+	query := `SELECT GROUPING(device), GROUPING(browser), COALESCE(device, browser, os), SUM(clicks)
+			  FROM (
+				SELECT device, browser, os, 1 AS clicks FROM clicks WHERE ` + where + `
+				UNION ALL
+				SELECT device, browser, os, clicks FROM click_rollups WHERE ` + where + `
+			  ) c
+			  GROUP BY GROUPING SETS ((device), (browser), (os))`
+
+	breakdown := &ClickBreakdown{
+		Devices:  []BreakdownEntry{},
+		Browsers: []BreakdownEntry{},
+		OS:       []BreakdownEntry{},
+	}
+	err := db.read(ctx, func(ctx context.Context, conn *sql.DB) error {
+		rows, err := conn.QueryContext(ctx, query, arg)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var noDevice, noBrowser int
+			var entry BreakdownEntry
+			if err := rows.Scan(&noDevice, &noBrowser, &entry.Name, &entry.Clicks); err != nil {
+				return err
+			}
+			switch {
+			case noDevice == 0:
+				breakdown.Devices = append(breakdown.Devices, entry)
+			case noBrowser == 0:
+				breakdown.Browsers = append(breakdown.Browsers, entry)
+			default:
+				breakdown.OS = append(breakdown.OS, entry)
+			}
+		}
+		return rows.Err()
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, entries := range [][]BreakdownEntry{breakdown.Devices, breakdown.Browsers, breakdown.OS} {
+		sort.Slice(entries, func(i, j int) bool {
+			if entries[i].Clicks != entries[j].Clicks {
+				return entries[i].Clicks > entries[j].Clicks
+			}
+			return entries[i].Name < entries[j].Name
+		})
+	}
+
+	return breakdown, nil
+}
+
+// CountRecentClicks counts a link's recorded human clicks within the trailing window
+func (db *Database) CountRecentClicks(ctx context.Context, urlID int, window time.Duration) (int, error) {
+	query := `SELECT COUNT(*) FROM clicks WHERE url_id = $1 AND clicked_at >= NOW() - $2 * INTERVAL '1 second'`
+
+	var count int
+	err := db.read(ctx, func(ctx context.Context, conn *sql.DB) error {
+		return conn.QueryRowContext(ctx, query, urlID, window.Seconds()).Scan(&count)
+	})
+	if err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// DailyClicks is the number of human clicks a link received on one day
+type DailyClicks struct {
+	Date   string `json:"date"`
+	Clicks int    `json:"clicks"`
+}
+
+// GetDailyClicks counts a link's clicks per day over the trailing days,
+// oldest first, including those already folded into daily rollups. Days
+// without clicks are listed with zero.
+func (db *Database) GetDailyClicks(ctx context.Context, urlID, days int) ([]DailyClicks, error) {
+	query := `SELECT d::DATE::TEXT, COALESCE(SUM(c.clicks), 0)
+			  FROM generate_series(CURRENT_DATE - ($2 - 1), CURRENT_DATE, INTERVAL '1 day') AS d
+			  LEFT JOIN (
+				SELECT clicked_at::DATE AS day, 1 AS clicks FROM clicks
+				WHERE url_id = $1 AND clicked_at >= CURRENT_DATE - ($2 - 1)
+				UNION ALL
+				SELECT day, clicks FROM click_rollups
+				WHERE url_id = $1 AND day >= CURRENT_DATE - ($2 - 1)
+			  ) c ON c.day = d::DATE
+			  GROUP BY d ORDER BY d`
+
+	var series []DailyClicks
+	err := db.read(ctx, func(ctx context.Context, conn *sql.DB) error {
+		rows, err := conn.QueryContext(ctx, query, urlID, days)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		series = make([]DailyClicks, 0, days)
+		for rows.Next() {
+			var day DailyClicks
+			if err := rows.Scan(&day.Date, &day.Clicks); err != nil {
+				return err
+			}
+			series = append(series, day)
+		}
+		return rows.Err()
+	})
+	if err != nil {
+		return nil, err
+	}
+	return series, nil
+}
+
+// GetTopCountries lists the countries a link's clicks came from, most clicks
+// first, including those already folded into daily rollups. Clicks without
+// a known country are left out.
+func (db *Database) GetTopCountries(ctx context.Context, urlID, limit int) ([]BreakdownEntry, error) {
+	query := `SELECT country, SUM(clicks) FROM (
+				SELECT country, 1 AS clicks FROM clicks WHERE url_id = $1
+				UNION ALL
+				SELECT country, clicks FROM click_rollups WHERE url_id = $1
+			  ) c
+			  WHERE country <> ''
+			  GROUP BY country ORDER BY SUM(clicks) DESC, country LIMIT $2`
+
+	var countries []BreakdownEntry
+	err := db.read(ctx, func(ctx context.Context, conn *sql.DB) error {
+		rows, err := conn.QueryContext(ctx, query, urlID, limit)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		countries = make([]BreakdownEntry, 0, limit)
+		for rows.Next() {
+			var entry BreakdownEntry
+			if err := rows.Scan(&entry.Name, &entry.Clicks); err != nil {
+				return err
+			}
+			countries = append(countries, entry)
+		}
+		return rows.Err()
+	})
+	if err != nil {
+		return nil, err
+	}
+	return countries, nil
+}
+
+// RecordVisit counts a unique click for the link when visitor has not
+// clicked it yet today
+func (db *Database) RecordVisit(ctx context.Context, urlID int, visitor string) error {
+	query := `WITH visit AS (
+				INSERT INTO unique_visits (url_id, day, visitor) VALUES ($1, CURRENT_DATE, $2)
+				ON CONFLICT DO NOTHING RETURNING url_id
+			  )
+			  UPDATE urls SET unique_clicks = unique_clicks + 1 WHERE id IN (SELECT url_id FROM visit)`
+	_, err := db.execCount(ctx, query, urlID, visitor)
+	return err
+}
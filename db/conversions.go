@@ -0,0 +1,75 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+)
+
+// ConversionStats counts the conversions of one goal on a link, e.g. signup
+// or purchase, from clicks on it
+type ConversionStats struct {
+	Name        string  `json:"name"`
+	Conversions int64   `json:"conversions"`
+	Value       float64 `json:"value"`
+	// Rate is the share of the link's clicks that converted
+	Rate float64 `json:"rate"`
+}
+
+// RecordClickConversion records a conversion of the click with clickID for
+// the goal name, reporting false when the click already converted for that
+// goal. It returns sql.ErrNoRows for unknown click IDs, including those of
+// clicks deleted after the retention period.
+func (db *Database) RecordClickConversion(ctx context.Context, clickID, name string, value float64) (bool, error) {
+	ctx, cancel := db.queryContext(ctx)
+	defer cancel()
+
+	query := `WITH click AS (
+				SELECT url_id FROM clicks WHERE click_id = $1
+			  ), conversion AS (
+				INSERT INTO conversions (url_id, click_id, name, value)
+				SELECT url_id, $1, $2, $3 FROM click
+				ON CONFLICT (click_id, name) DO NOTHING
+				RETURNING url_id
+			  ), counted AS (
+				UPDATE urls SET conversions = conversions + 1 WHERE id IN (SELECT url_id FROM conversion)
+			  )
+			  SELECT (SELECT COUNT(*) FROM click), (SELECT COUNT(*) FROM conversion)`
+	var clicks, recorded int
+	if err := db.conn.QueryRowContext(ctx, query, clickID, name, value).Scan(&clicks, &recorded); err != nil {
+		return false, err
+	}
+	if clicks == 0 {
+		return false, sql.ErrNoRows
+	}
+	return recorded > 0, nil
+}
+
+// GetConversionStats returns the conversions of a link per goal, the most
+// frequent first
+func (db *Database) GetConversionStats(ctx context.Context, urlID int) ([]ConversionStats, error) {
+	query := `SELECT name, COUNT(*), COALESCE(SUM(value), 0) FROM conversions
+			  WHERE url_id = $1 GROUP BY name ORDER BY COUNT(*) DESC, name`
+
+	var stats []ConversionStats
+	err := db.read(ctx, func(ctx context.Context, conn *sql.DB) error {
+		rows, err := conn.QueryContext(ctx, query, urlID)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		stats = make([]ConversionStats, 0)
+		for rows.Next() {
+			var s ConversionStats
+			if err := rows.Scan(&s.Name, &s.Conversions, &s.Value); err != nil {
+				return err
+			}
+			stats = append(stats, s)
+		}
+		return rows.Err()
+	})
+	if err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
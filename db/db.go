@@ -1,18 +1,60 @@
 package db
 
 import (
+	"context"
 	"database/sql"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"log"
 	"os"
+	"sync/atomic"
+	"time"
+	"url-shortener/config"
+	"url-shortener/events"
+	"url-shortener/pkg/base62"
+	"url-shortener/pkg/lru"
 
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
+	"golang.org/x/sync/singleflight"
 )
 
+// ErrNotFound is returned when a write targets a short code no URL has
+var ErrNotFound = errors.New("no URL found with short code")
+
+// ErrLocked is returned when a locked URL is modified or deleted
+var ErrLocked = errors.New("url is locked")
+
+// ErrEditConflict is returned when a URL was changed since the version an
+// edit was based on
+var ErrEditConflict = errors.New("url was changed since it was read")
+
+// maxRetryBackoff caps the delay between startup connection attempts
+const maxRetryBackoff = 30 * time.Second
+
+// ProbeInterval is how often Probe should ping the database
+const ProbeInterval = 5 * time.Second
+
 type Database struct {
-	conn *sql.DB
+	conn          *sql.DB
+	replicas      []*replica
+	replicaCursor atomic.Uint64
+	queryTimeout  time.Duration
+	metrics       queryMetrics
+	reserved      map[string]bool
+	idBlock       idBlock
+	links         *lru.Cache[string, *URL]
+	staleIfError  time.Duration
+	staleServed   atomic.Int64
+	lookups       singleflight.Group
+	onChange      func(shortCodes ...string)
+	outbox        bool
+	// prepared holds the prepared hot statements of each pool by query, set
+	// once by PrepareStatements
+	prepared map[*sql.DB]map[string]*sql.Stmt
 }
 
-func InitDB() (*Database, error) {
+func InitDB(cfg *config.Config) (*Database, error) {
 	dbHost := os.Getenv("DATABASE_HOST")
 	dbUser := os.Getenv("DATABASE_USER")
 	dbPassword := os.Getenv("DATABASE_PASSWORD")
@@ -31,12 +73,17 @@ func InitDB() (*Database, error) {
 	connStr := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=%s",
 		dbHost, dbPort, dbUser, dbPassword, dbName, sslMode)
 
-	conn, err := sql.Open("postgres", connStr)
+	conn, err := openDB(connStr, cfg.Debug.ExplainThreshold)
 	if err != nil {
 		return nil, fmt.Errorf("error opening database: %w", err)
 	}
 
-	if err := conn.Ping(); err != nil {
+	conn.SetMaxOpenConns(cfg.Database.MaxOpenConns)
+	conn.SetMaxIdleConns(cfg.Database.MaxIdleConns)
+	conn.SetConnMaxLifetime(cfg.Database.ConnMaxLifetime)
+
+	if err := pingWithRetry(conn, cfg.Database.ConnectRetries, cfg.Database.RetryBackoff); err != nil {
+		conn.Close()
 		return nil, fmt.Errorf("error connecting to database: %w", err)
 	}
 
@@ -44,15 +91,71 @@ func InitDB() (*Database, error) {
 		return nil, fmt.Errorf("error creating schema: %w", err)
 	}
 
-	return &Database{conn: conn}, nil
+	replicas, err := openReplicas(cfg)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("error opening read replica: %w", err)
+	}
+
+	database := New(conn, cfg)
+	database.replicas = replicas
+	return database, nil
+}
+
+// New returns a Database over an open connection pool, configured by cfg. The
+// schema must already exist; InitDB connects to PostgreSQL and creates it.
+func New(conn *sql.DB, cfg *config.Config) *Database {
+	database := &Database{conn: conn, queryTimeout: cfg.Database.QueryTimeout}
+	database.idBlock.size = cfg.Database.IDBlockSize
+	if cfg.Database.LinkCacheSize > 0 {
+		database.links = lru.New[string, *URL](cfg.Database.LinkCacheSize, cfg.Database.LinkCacheTTL)
+		database.staleIfError = cfg.Database.LinkCacheStaleIfError
+	}
+	return database
+}
+
+// pingWithRetry pings the database, backing off exponentially between failed attempts
+func pingWithRetry(conn *sql.DB, retries int, backoff time.Duration) error {
+	var err error
+	for attempt := 0; ; attempt++ {
+		if err = conn.Ping(); err == nil {
+			return nil
+		}
+		if attempt >= retries {
+			return err
+		}
+
+		log.Printf("Database not reachable (attempt %d/%d): %v; retrying in %s", attempt+1, retries+1, err, backoff)
+		time.Sleep(backoff)
+		backoff = min(backoff*2, maxRetryBackoff)
+	}
+}
+
+// SetReservedCodes stops generated short codes from shadowing routes such as "urls"
+func (db *Database) SetReservedCodes(codes []string) {
+	db.reserved = make(map[string]bool, len(codes))
+	for _, code := range codes {
+		db.reserved[code] = true
+	}
+}
+
+// Ping checks that the primary database is reachable
+func (db *Database) Ping(ctx context.Context) error {
+	ctx, cancel := db.queryContext(ctx)
+	defer cancel()
+
+	return db.conn.PingContext(ctx)
 }
 
 func (db *Database) Close() error {
+	for _, r := range db.replicas {
+		r.conn.Close()
+	}
 	return db.conn.Close()
 }
 
 func createSchema(db *sql.DB) error {
-	query := `
+	queries := []string{`
 	CREATE TABLE IF NOT EXISTS urls (
 		id SERIAL PRIMARY KEY,
 		original TEXT NOT NULL,
@@ -60,80 +163,1021 @@ func createSchema(db *sql.DB) error {
 		created_at TIMESTAMP NOT NULL,
 		updated_at TIMESTAMP NOT NULL,
 		access_count INTEGER DEFAULT 0
-	)`
+	)`,
+		`ALTER TABLE urls ADD COLUMN IF NOT EXISTS title TEXT`,
+		`ALTER TABLE urls ADD COLUMN IF NOT EXISTS description TEXT`,
+		`ALTER TABLE urls ADD COLUMN IF NOT EXISTS metadata_fetched_at TIMESTAMP`,
+		`ALTER TABLE urls ADD COLUMN IF NOT EXISTS domain TEXT`,
+		`ALTER TABLE urls ADD COLUMN IF NOT EXISTS locked BOOLEAN NOT NULL DEFAULT FALSE`,
+		`ALTER TABLE urls ADD COLUMN IF NOT EXISTS lock_changed_at TIMESTAMP`,
+		`ALTER TABLE urls ADD COLUMN IF NOT EXISTS lock_changed_by TEXT`,
+		`ALTER TABLE urls ADD COLUMN IF NOT EXISTS lock_reason TEXT`,
+		`ALTER TABLE urls ADD COLUMN IF NOT EXISTS tags TEXT[] NOT NULL DEFAULT '{}'`,
+		`CREATE INDEX IF NOT EXISTS urls_tags_idx ON urls USING GIN (tags)`,
+		`ALTER TABLE urls ADD COLUMN IF NOT EXISTS bot_clicks INTEGER NOT NULL DEFAULT 0`,
+		`ALTER TABLE urls ADD COLUMN IF NOT EXISTS owner_token_hash TEXT`,
+		`ALTER TABLE urls ADD COLUMN IF NOT EXISTS owner_id TEXT`,
+		`CREATE TABLE IF NOT EXISTS users (
+			id SERIAL PRIMARY KEY,
+			email TEXT,
+			name TEXT,
+			role TEXT NOT NULL DEFAULT 'editor',
+			created_at TIMESTAMP NOT NULL DEFAULT NOW()
+		)`,
+		`CREATE TABLE IF NOT EXISTS user_identities (
+			provider TEXT NOT NULL,
+			subject TEXT NOT NULL,
+			user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			PRIMARY KEY (provider, subject)
+		)`,
+		`CREATE TABLE IF NOT EXISTS organizations (
+			id SERIAL PRIMARY KEY,
+			name TEXT NOT NULL,
+			created_at TIMESTAMP NOT NULL DEFAULT NOW()
+		)`,
+		`CREATE TABLE IF NOT EXISTS organization_members (
+			org_id INTEGER NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+			user_id TEXT NOT NULL,
+			role TEXT NOT NULL CHECK (role IN ('owner', 'editor', 'viewer')),
+			added_at TIMESTAMP NOT NULL DEFAULT NOW(),
+			PRIMARY KEY (org_id, user_id)
+		)`,
+		`CREATE INDEX IF NOT EXISTS organization_members_user_idx ON organization_members (user_id)`,
+		`ALTER TABLE urls ADD COLUMN IF NOT EXISTS org_id INTEGER REFERENCES organizations(id)`,
+		`CREATE INDEX IF NOT EXISTS urls_org_idx ON urls (org_id, updated_at)`,
+		`ALTER TABLE urls ADD COLUMN IF NOT EXISTS targets JSONB`,
+		`ALTER TABLE urls ADD COLUMN IF NOT EXISTS variants JSONB`,
+		`ALTER TABLE urls ADD COLUMN IF NOT EXISTS forward_query BOOLEAN NOT NULL DEFAULT FALSE`,
+		`ALTER TABLE urls ADD COLUMN IF NOT EXISTS utm_campaign TEXT`,
+		`ALTER TABLE urls ADD COLUMN IF NOT EXISTS forward_path BOOLEAN NOT NULL DEFAULT FALSE`,
+		`CREATE INDEX IF NOT EXISTS idx_urls_utm_campaign ON urls (utm_campaign) WHERE utm_campaign IS NOT NULL`,
+		`CREATE TABLE IF NOT EXISTS variant_stats (
+			url_id INTEGER NOT NULL REFERENCES urls(id) ON DELETE CASCADE,
+			variant TEXT NOT NULL,
+			clicks BIGINT NOT NULL DEFAULT 0,
+			conversions BIGINT NOT NULL DEFAULT 0,
+			PRIMARY KEY (url_id, variant)
+		)`,
+		`CREATE TABLE IF NOT EXISTS url_versions (
+			id BIGSERIAL PRIMARY KEY,
+			url_id INTEGER NOT NULL REFERENCES urls(id) ON DELETE CASCADE,
+			original TEXT NOT NULL,
+			created_by TEXT,
+			created_at TIMESTAMP NOT NULL DEFAULT NOW()
+		)`,
+		`CREATE INDEX IF NOT EXISTS url_versions_url_idx ON url_versions (url_id, created_at)`,
+		`CREATE TABLE IF NOT EXISTS audit_log (
+			id BIGSERIAL PRIMARY KEY,
+			occurred_at TIMESTAMP NOT NULL DEFAULT NOW(),
+			actor TEXT NOT NULL,
+			action TEXT NOT NULL,
+			entity_type TEXT NOT NULL,
+			entity_id TEXT NOT NULL,
+			old_value JSONB,
+			new_value JSONB,
+			remote_ip TEXT
+		)`,
+		`CREATE INDEX IF NOT EXISTS audit_log_occurred_idx ON audit_log (occurred_at)`,
+		`CREATE INDEX IF NOT EXISTS audit_log_entity_idx ON audit_log (entity_type, entity_id, occurred_at)`,
+		`ALTER TABLE urls ADD COLUMN IF NOT EXISTS disabled_at TIMESTAMP`,
+		`ALTER TABLE urls ADD COLUMN IF NOT EXISTS disabled_reason TEXT`,
+		`CREATE TABLE IF NOT EXISTS blocked_destinations (
+			id BIGSERIAL PRIMARY KEY,
+			kind TEXT NOT NULL,
+			pattern TEXT NOT NULL,
+			note TEXT,
+			created_by TEXT,
+			created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+			UNIQUE (kind, pattern)
+		)`,
+		`CREATE TABLE IF NOT EXISTS ip_rules (
+			id BIGSERIAL PRIMARY KEY,
+			cidr CIDR NOT NULL,
+			action TEXT NOT NULL,
+			note TEXT,
+			created_by TEXT,
+			created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+			UNIQUE (cidr, action)
+		)`,
+		`CREATE TABLE IF NOT EXISTS sessions (
+			token_hash TEXT PRIMARY KEY,
+			user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+			expires_at TIMESTAMP NOT NULL
+		)`,
+		`CREATE TABLE IF NOT EXISTS clicks (
+			id BIGSERIAL PRIMARY KEY,
+			url_id INTEGER NOT NULL REFERENCES urls(id) ON DELETE CASCADE,
+			clicked_at TIMESTAMP NOT NULL DEFAULT NOW(),
+			device TEXT NOT NULL,
+			browser TEXT NOT NULL,
+			os TEXT NOT NULL
+		)`,
+		`CREATE INDEX IF NOT EXISTS clicks_url_idx ON clicks (url_id, clicked_at)`,
+		`CREATE TABLE IF NOT EXISTS click_imports (
+			id SERIAL PRIMARY KEY,
+			source TEXT NOT NULL,
+			external_id TEXT,
+			note TEXT,
+			imported_by TEXT NOT NULL,
+			imported_at TIMESTAMP NOT NULL DEFAULT NOW(),
+			total_clicks BIGINT NOT NULL DEFAULT 0,
+			UNIQUE (source, external_id)
+		)`,
+		`CREATE TABLE IF NOT EXISTS click_import_records (
+			import_id INTEGER NOT NULL REFERENCES click_imports(id) ON DELETE CASCADE,
+			url_id INTEGER NOT NULL REFERENCES urls(id) ON DELETE CASCADE,
+			day DATE NOT NULL,
+			clicks INTEGER NOT NULL
+		)`,
+		`CREATE INDEX IF NOT EXISTS click_import_records_url_idx ON click_import_records (url_id, day)`,
+		`CREATE TABLE IF NOT EXISTS campaigns (
+			id SERIAL PRIMARY KEY,
+			name TEXT NOT NULL,
+			owner_id TEXT,
+			created_at TIMESTAMP NOT NULL DEFAULT NOW()
+		)`,
+		`ALTER TABLE urls ADD COLUMN IF NOT EXISTS campaign_id INTEGER REFERENCES campaigns(id) ON DELETE SET NULL`,
+		`CREATE INDEX IF NOT EXISTS urls_campaign_id_idx ON urls (campaign_id) WHERE campaign_id IS NOT NULL`,
+		`ALTER TABLE urls ADD COLUMN IF NOT EXISTS suspicious_clicks INTEGER NOT NULL DEFAULT 0`,
+		`CREATE TABLE IF NOT EXISTS click_anomalies (
+			url_id INTEGER NOT NULL REFERENCES urls(id) ON DELETE CASCADE,
+			reason TEXT NOT NULL,
+			occurrences BIGINT NOT NULL DEFAULT 0,
+			first_seen TIMESTAMP NOT NULL DEFAULT NOW(),
+			last_seen TIMESTAMP NOT NULL DEFAULT NOW(),
+			PRIMARY KEY (url_id, reason)
+		)`,
+		`CREATE INDEX IF NOT EXISTS click_anomalies_last_seen_idx ON click_anomalies (last_seen)`,
+		`CREATE TABLE IF NOT EXISTS click_rollups (
+			url_id INTEGER NOT NULL REFERENCES urls(id) ON DELETE CASCADE,
+			day DATE NOT NULL,
+			device TEXT NOT NULL,
+			browser TEXT NOT NULL,
+			os TEXT NOT NULL,
+			clicks BIGINT NOT NULL,
+			PRIMARY KEY (url_id, day, device, browser, os)
+		)`,
+		`ALTER TABLE urls ADD COLUMN IF NOT EXISTS public_stats BOOLEAN NOT NULL DEFAULT FALSE`,
+		`ALTER TABLE clicks ADD COLUMN IF NOT EXISTS country TEXT NOT NULL DEFAULT ''`,
+		`ALTER TABLE click_rollups ADD COLUMN IF NOT EXISTS country TEXT NOT NULL DEFAULT ''`,
+		// Rollups are kept per country too, so they need a wider key
+		`ALTER TABLE click_rollups DROP CONSTRAINT IF EXISTS click_rollups_pkey`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS click_rollups_key ON click_rollups (url_id, day, device, browser, os, country)`,
+		`ALTER TABLE urls ADD COLUMN IF NOT EXISTS unique_clicks BIGINT NOT NULL DEFAULT 0`,
+		`CREATE TABLE IF NOT EXISTS unique_visits (
+			url_id INTEGER NOT NULL REFERENCES urls(id) ON DELETE CASCADE,
+			day DATE NOT NULL,
+			visitor TEXT NOT NULL,
+			PRIMARY KEY (url_id, day, visitor)
+		)`,
+		`CREATE INDEX IF NOT EXISTS unique_visits_day_idx ON unique_visits (day)`,
+		`CREATE TABLE IF NOT EXISTS click_hourly_rollups (
+			url_id INTEGER NOT NULL REFERENCES urls(id) ON DELETE CASCADE,
+			hour TIMESTAMP NOT NULL,
+			clicks BIGINT NOT NULL,
+			PRIMARY KEY (url_id, hour)
+		)`,
+		`CREATE TABLE IF NOT EXISTS aggregator_state (
+			name TEXT PRIMARY KEY,
+			last_id BIGINT NOT NULL
+		)`,
+		`ALTER TABLE urls ADD COLUMN IF NOT EXISTS health JSONB`,
+		`ALTER TABLE urls ADD COLUMN IF NOT EXISTS health_checked_at TIMESTAMP`,
+		`CREATE INDEX IF NOT EXISTS urls_health_checked_idx ON urls (health_checked_at NULLS FIRST) WHERE disabled_at IS NULL`,
+		`ALTER TABLE urls ADD COLUMN IF NOT EXISTS archived_at TIMESTAMP`,
+		`ALTER TABLE urls ADD COLUMN IF NOT EXISTS revived_at TIMESTAMP`,
+		`CREATE INDEX IF NOT EXISTS urls_archived_idx ON urls (org_id, archived_at) WHERE archived_at IS NOT NULL`,
+		`ALTER TABLE organizations ADD COLUMN IF NOT EXISTS inactive_months INTEGER`,
+		`ALTER TABLE organizations ADD COLUMN IF NOT EXISTS inactive_action TEXT`,
+		`ALTER TABLE urls ADD COLUMN IF NOT EXISTS rotation JSONB`,
+		`ALTER TABLE urls ADD COLUMN IF NOT EXISTS rotation_position BIGINT NOT NULL DEFAULT 0`,
+		`CREATE TABLE IF NOT EXISTS rotation_stats (
+			url_id INTEGER NOT NULL REFERENCES urls(id) ON DELETE CASCADE,
+			destination TEXT NOT NULL,
+			clicks BIGINT NOT NULL DEFAULT 0,
+			PRIMARY KEY (url_id, destination)
+		)`,
+		`ALTER TABLE urls ADD COLUMN IF NOT EXISTS app_link JSONB`,
+		`ALTER TABLE urls ADD COLUMN IF NOT EXISTS open_graph JSONB`,
+		`ALTER TABLE urls ADD COLUMN IF NOT EXISTS cache_ttl INTEGER`,
+		`CREATE TABLE IF NOT EXISTS outbox (
+			id BIGSERIAL PRIMARY KEY,
+			event_id TEXT NOT NULL DEFAULT md5(random()::TEXT || clock_timestamp()::TEXT),
+			event_type TEXT NOT NULL,
+			event_key TEXT NOT NULL,
+			payload JSONB NOT NULL,
+			created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+			attempts INTEGER NOT NULL DEFAULT 0,
+			next_attempt_at TIMESTAMP NOT NULL DEFAULT NOW(),
+			last_error TEXT
+		)`,
+		`CREATE INDEX IF NOT EXISTS outbox_next_attempt_idx ON outbox (next_attempt_at)`,
+		`CREATE TABLE IF NOT EXISTS feature_flags (
+			name TEXT NOT NULL,
+			environment TEXT NOT NULL DEFAULT '',
+			org_id INTEGER NOT NULL DEFAULT 0,
+			enabled BOOLEAN NOT NULL,
+			updated_by TEXT,
+			updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+			PRIMARY KEY (name, environment, org_id)
+		)`,
+		`CREATE TABLE IF NOT EXISTS tenants (
+			id SERIAL PRIMARY KEY,
+			name TEXT NOT NULL,
+			created_at TIMESTAMP NOT NULL DEFAULT NOW()
+		)`,
+		`CREATE TABLE IF NOT EXISTS tenant_hostnames (
+			hostname TEXT PRIMARY KEY,
+			tenant_id INTEGER NOT NULL REFERENCES tenants(id) ON DELETE CASCADE
+		)`,
+		`CREATE TABLE IF NOT EXISTS api_keys (
+			id SERIAL PRIMARY KEY,
+			tenant_id INTEGER NOT NULL REFERENCES tenants(id) ON DELETE CASCADE,
+			name TEXT NOT NULL,
+			key_hash TEXT NOT NULL UNIQUE,
+			created_by TEXT,
+			created_at TIMESTAMP NOT NULL DEFAULT NOW()
+		)`,
+		`ALTER TABLE urls ADD COLUMN IF NOT EXISTS tenant_id INTEGER NOT NULL DEFAULT 0`,
+		`ALTER TABLE organizations ADD COLUMN IF NOT EXISTS tenant_id INTEGER NOT NULL DEFAULT 0`,
+		`ALTER TABLE campaigns ADD COLUMN IF NOT EXISTS tenant_id INTEGER NOT NULL DEFAULT 0`,
+		// Short codes are unique within a tenant rather than across the deployment
+		`CREATE UNIQUE INDEX IF NOT EXISTS urls_tenant_short_code_idx ON urls (tenant_id, short_code)`,
+		`ALTER TABLE urls DROP CONSTRAINT IF EXISTS urls_short_code_key`,
+		`ALTER TABLE users ADD COLUMN IF NOT EXISTS plan TEXT`,
+		`ALTER TABLE organizations ADD COLUMN IF NOT EXISTS plan TEXT`,
+		`CREATE INDEX IF NOT EXISTS urls_owner_idx ON urls (owner_id, created_at)`,
+		`CREATE TABLE IF NOT EXISTS api_usage (
+			user_id TEXT NOT NULL,
+			month DATE NOT NULL,
+			calls BIGINT NOT NULL DEFAULT 0,
+			PRIMARY KEY (user_id, month)
+		)`,
+		`CREATE TABLE IF NOT EXISTS plans (
+			name TEXT PRIMARY KEY,
+			stripe_price_id TEXT UNIQUE,
+			custom_domains BOOLEAN NOT NULL DEFAULT FALSE,
+			updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+		)`,
+		`CREATE TABLE IF NOT EXISTS subscriptions (
+			id TEXT PRIMARY KEY,
+			customer_id TEXT NOT NULL,
+			user_id TEXT,
+			org_id INTEGER REFERENCES organizations(id) ON DELETE CASCADE,
+			tenant_id INTEGER NOT NULL DEFAULT 0,
+			plan TEXT NOT NULL,
+			status TEXT NOT NULL,
+			current_period_end TIMESTAMP,
+			event_at TIMESTAMP NOT NULL,
+			updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+		)`,
+		`CREATE INDEX IF NOT EXISTS subscriptions_user_idx ON subscriptions (user_id)`,
+		`CREATE INDEX IF NOT EXISTS subscriptions_org_idx ON subscriptions (org_id)`,
+		// Each custom domain has a namespace of short codes of its own
+		`CREATE UNIQUE INDEX IF NOT EXISTS urls_tenant_domain_short_code_idx ON urls (tenant_id, COALESCE(domain, ''), short_code)`,
+		`DROP INDEX IF EXISTS urls_tenant_short_code_idx`,
+		`CREATE TABLE IF NOT EXISTS transfers (
+			id SERIAL PRIMARY KEY,
+			tenant_id INTEGER NOT NULL DEFAULT 0,
+			url_id INTEGER REFERENCES urls(id) ON DELETE CASCADE,
+			campaign_id INTEGER REFERENCES campaigns(id) ON DELETE CASCADE,
+			to_user_id TEXT,
+			to_org_id INTEGER REFERENCES organizations(id) ON DELETE CASCADE,
+			requested_by TEXT NOT NULL,
+			status TEXT NOT NULL DEFAULT 'pending',
+			created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+			resolved_by TEXT,
+			resolved_at TIMESTAMP,
+			CHECK ((url_id IS NULL) <> (campaign_id IS NULL)),
+			CHECK ((to_user_id IS NULL) <> (to_org_id IS NULL))
+		)`,
+		// A link or campaign has at most one transfer awaiting acceptance
+		`CREATE UNIQUE INDEX IF NOT EXISTS transfers_pending_url_idx ON transfers (url_id) WHERE status = 'pending'`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS transfers_pending_campaign_idx ON transfers (campaign_id) WHERE status = 'pending'`,
+		`ALTER TABLE plans ADD COLUMN IF NOT EXISTS sso BOOLEAN NOT NULL DEFAULT FALSE`,
+		`CREATE TABLE IF NOT EXISTS org_sso (
+			org_id INTEGER PRIMARY KEY REFERENCES organizations(id) ON DELETE CASCADE,
+			idp_entity_id TEXT NOT NULL,
+			sso_url TEXT NOT NULL,
+			certificate TEXT NOT NULL,
+			default_role TEXT NOT NULL DEFAULT 'viewer',
+			scim_token_hash TEXT UNIQUE,
+			updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+		)`,
+		`ALTER TABLE users ADD COLUMN IF NOT EXISTS totp_secret TEXT`,
+		`ALTER TABLE users ADD COLUMN IF NOT EXISTS totp_enabled_at TIMESTAMP`,
+		`ALTER TABLE users ADD COLUMN IF NOT EXISTS totp_last_step BIGINT NOT NULL DEFAULT 0`,
+		`CREATE TABLE IF NOT EXISTS recovery_codes (
+			user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+			code_hash TEXT NOT NULL,
+			PRIMARY KEY (user_id, code_hash)
+		)`,
+		`ALTER TABLE sessions ADD COLUMN IF NOT EXISTS pending_two_factor BOOLEAN NOT NULL DEFAULT FALSE`,
+		`ALTER TABLE sessions ADD COLUMN IF NOT EXISTS two_factor_attempts INTEGER NOT NULL DEFAULT 0`,
+		`ALTER TABLE organizations ADD COLUMN IF NOT EXISTS require_two_factor BOOLEAN NOT NULL DEFAULT FALSE`,
+		`ALTER TABLE api_keys ADD COLUMN IF NOT EXISTS scopes TEXT[] NOT NULL DEFAULT '{}'`,
+		`ALTER TABLE urls ADD COLUMN IF NOT EXISTS frame BOOLEAN NOT NULL DEFAULT FALSE`,
+		`CREATE TABLE IF NOT EXISTS pixels (
+			id SERIAL PRIMARY KEY,
+			org_id INTEGER NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+			name TEXT NOT NULL,
+			provider TEXT NOT NULL,
+			pixel_id TEXT NOT NULL,
+			created_at TIMESTAMP NOT NULL DEFAULT NOW()
+		)`,
+		`CREATE INDEX IF NOT EXISTS pixels_org_id_idx ON pixels (org_id)`,
+		`ALTER TABLE urls ADD COLUMN IF NOT EXISTS pixels INTEGER[] NOT NULL DEFAULT '{}'`,
+		`ALTER TABLE campaigns ADD COLUMN IF NOT EXISTS pixels INTEGER[] NOT NULL DEFAULT '{}'`,
+		`ALTER TABLE urls ADD COLUMN IF NOT EXISTS track_conversions BOOLEAN NOT NULL DEFAULT FALSE`,
+		`ALTER TABLE urls ADD COLUMN IF NOT EXISTS conversions BIGINT NOT NULL DEFAULT 0`,
+		`ALTER TABLE clicks ADD COLUMN IF NOT EXISTS click_id TEXT`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS clicks_click_id_idx ON clicks (click_id) WHERE click_id IS NOT NULL`,
+		`CREATE TABLE IF NOT EXISTS conversions (
+			id BIGSERIAL PRIMARY KEY,
+			url_id INTEGER NOT NULL REFERENCES urls(id) ON DELETE CASCADE,
+			click_id TEXT NOT NULL,
+			name TEXT NOT NULL,
+			value DOUBLE PRECISION NOT NULL DEFAULT 0,
+			converted_at TIMESTAMP NOT NULL DEFAULT NOW(),
+			UNIQUE (click_id, name)
+		)`,
+		`CREATE INDEX IF NOT EXISTS conversions_url_id_idx ON conversions (url_id)`,
+		`ALTER TABLE urls ADD COLUMN IF NOT EXISTS notes TEXT NOT NULL DEFAULT ''`,
+		`ALTER TABLE urls ADD COLUMN IF NOT EXISTS metadata JSONB NOT NULL DEFAULT '{}'`,
+		`CREATE TABLE IF NOT EXISTS reserved_prefixes (
+			id SERIAL PRIMARY KEY,
+			tenant_id INTEGER NOT NULL DEFAULT 0,
+			org_id INTEGER NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+			prefix TEXT NOT NULL,
+			created_at TIMESTAMP NOT NULL DEFAULT NOW()
+		)`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS reserved_prefixes_prefix_idx ON reserved_prefixes (tenant_id, prefix)`,
+		`CREATE TABLE IF NOT EXISTS warehouse_exports (
+			org_id INTEGER PRIMARY KEY REFERENCES organizations(id) ON DELETE CASCADE,
+			destination TEXT NOT NULL,
+			settings JSONB NOT NULL DEFAULT '{}',
+			credentials TEXT NOT NULL DEFAULT '',
+			exported_through DATE,
+			last_error TEXT,
+			updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+		)`,
+		// Listings of a tenant's or a campaign's links are newest first
+		`CREATE INDEX IF NOT EXISTS urls_tenant_updated_idx ON urls (tenant_id, updated_at DESC) WHERE archived_at IS NULL`,
+		`CREATE INDEX IF NOT EXISTS urls_campaign_updated_idx ON urls (campaign_id, updated_at DESC) WHERE campaign_id IS NOT NULL`,
+		`DROP INDEX IF EXISTS urls_campaign_id_idx`,
+		`CREATE INDEX IF NOT EXISTS sessions_user_idx ON sessions (user_id)`,
+		`CREATE INDEX IF NOT EXISTS sessions_expires_idx ON sessions (expires_at)`,
+		// Each tenant's admins see the audit entries of their own tenant
+		`ALTER TABLE audit_log ADD COLUMN IF NOT EXISTS tenant_id INTEGER NOT NULL DEFAULT 0`,
+		`CREATE INDEX IF NOT EXISTS audit_log_tenant_idx ON audit_log (tenant_id, occurred_at)`,
+	}
+
+	for _, query := range queries {
+		if _, err := db.Exec(query); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (db *Database) GetURLByShortCode(ctx context.Context, shortCode string) (*URL, error) {
+	var url *URL
+	err := db.read(ctx, func(ctx context.Context, conn *sql.DB) error {
+		var err error
+		url, err = scanURL(db.queryRow(ctx, conn, urlByShortCodeQuery, shortCode, TenantFrom(ctx), DomainFrom(ctx)))
+		return err
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return url, nil
+}
+
+// GetURLByID looks a URL of the tenant of ctx up by its primary key
+func (db *Database) GetURLByID(ctx context.Context, id int64) (*URL, error) {
+	var url *URL
+	err := db.read(ctx, func(ctx context.Context, conn *sql.DB) error {
+		var err error
+		url, err = scanURL(db.queryRow(ctx, conn, urlByIDQuery, id, TenantFrom(ctx)))
+		return err
+	})
+
+	if err != nil {
+		return nil, err
+	}
 
-	_, err := db.Exec(query)
+	return url, nil
+}
+
+// resolveShortCode finds the URL for a short code. Sequence-based codes are decoded
+// straight to their primary key; anything else falls back to the short_code index.
+// It returns sql.ErrNoRows only when no link has the code.
+func (db *Database) resolveShortCode(ctx context.Context, shortCode string) (*URL, error) {
+	if id, err := base62.DecodeCode(shortCode); err == nil {
+		url, err := db.GetURLByID(ctx, id)
+		if err == nil && url.ShortCode == shortCode && url.Domain == DomainFrom(ctx) {
+			return url, nil
+		}
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return nil, err
+		}
+	}
+
+	return db.GetURLByShortCode(ctx, shortCode)
+}
+
+func (db *Database) IncrementClickCount(ctx context.Context, shortCode string, click Click) (int, error) {
+	ctx, cancel := db.queryContext(ctx)
+	defer cancel()
+
+	var count int
+	err := db.queryRow(ctx, db.conn, incrementClickQuery, shortCode, click.Device, click.Browser, click.OS, click.Country, TenantFrom(ctx), DomainFrom(ctx), click.ClickID).Scan(&count)
+	return count, err
+}
+
+// IncrementBotClickCount records a redirect made by a crawler or link unfurler,
+// kept apart from access_count so human click stats stay meaningful
+func (db *Database) IncrementBotClickCount(ctx context.Context, shortCode string) error {
+	ctx, cancel := db.queryContext(ctx)
+	defer cancel()
+
+	query := `UPDATE urls SET bot_clicks = bot_clicks + 1 WHERE short_code = $1 AND tenant_id = $2 AND COALESCE(domain, '') = $3`
+	_, err := db.conn.ExecContext(ctx, query, shortCode, TenantFrom(ctx), DomainFrom(ctx))
 	return err
 }
 
-func (db *Database) GetURLByShortCode(shortCode string) (*URL, error) {
-	var url URL
-	query := `SELECT id, original, short_code, created_at, updated_at, access_count 
-			  FROM urls WHERE short_code = $1`
+// urlColumns is the select list matching scanURL
+const urlColumns = `id, original, short_code, created_at, updated_at, access_count,
+	COALESCE(title, ''), COALESCE(description, ''), COALESCE(domain, ''), locked, tags, bot_clicks,
+	COALESCE(org_id, 0), COALESCE(targets, '{}'), COALESCE(variants, '[]'), forward_query, forward_path,
+	COALESCE(utm_campaign, ''), disabled_at IS NOT NULL, COALESCE(disabled_reason, ''), COALESCE(campaign_id, 0), suspicious_clicks,
+	public_stats, unique_clicks, COALESCE(health, 'null'), archived_at IS NOT NULL, COALESCE(rotation, 'null'),
+	COALESCE(app_link, 'null'), COALESCE(open_graph, 'null'), cache_ttl, tenant_id, frame, pixels,
+	COALESCE((SELECT campaigns.pixels FROM campaigns WHERE campaigns.id = urls.campaign_id), '{}'), track_conversions, conversions, notes, metadata`
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
 
-	err := db.conn.QueryRow(query, shortCode).Scan(
+func scanURL(row rowScanner) (*URL, error) {
+	var url URL
+	var targets, variants, health, rotation, appLink, openGraph, metadata []byte
+	err := row.Scan(
 		&url.ID,
 		&url.OriginalURL,
 		&url.ShortCode,
 		&url.CreatedAt,
 		&url.UpdatedAt,
 		&url.Clicks,
+		&url.Title,
+		&url.Description,
+		&url.Domain,
+		&url.Locked,
+		pq.Array(&url.Tags),
+		&url.BotClicks,
+		&url.OrgID,
+		&targets,
+		&variants,
+		&url.ForwardQuery,
+		&url.ForwardPath,
+		&url.Campaign,
+		&url.Disabled,
+		&url.DisabledReason,
+		&url.CampaignID,
+		&url.SuspiciousClicks,
+		&url.PublicStats,
+		&url.UniqueClicks,
+		&health,
+		&url.Archived,
+		&rotation,
+		&appLink,
+		&openGraph,
+		&url.CacheTTL,
+		&url.TenantID,
+		&url.Frame,
+		pq.Array(&url.Pixels),
+		pq.Array(&url.CampaignPixels),
+		&url.TrackConversions,
+		&url.Conversions,
+		&url.Notes,
+		&metadata,
 	)
-
 	if err != nil {
 		return nil, err
 	}
-
+	if err := json.Unmarshal(targets, &url.Targets); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(variants, &url.Variants); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(health, &url.Health); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(rotation, &url.Rotation); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(appLink, &url.AppLink); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(openGraph, &url.OpenGraph); err != nil {
+		return nil, err
+	}
+	if string(metadata) != "{}" {
+		url.Metadata = metadata
+	}
 	return &url, nil
 }
 
-func (db *Database) IncrementClickCount(shortCode string) error {
-	query := `UPDATE urls SET access_count = access_count + 1 WHERE short_code = $1`
-	_, err := db.conn.Exec(query, shortCode)
-	return err
+type URL struct {
+	ID          int      `json:"id"`
+	OriginalURL string   `json:"original"`
+	ShortCode   string   `json:"shortCode"`
+	CreatedAt   string   `json:"createdAt"`
+	UpdatedAt   string   `json:"updatedAt"`
+	Clicks      int      `json:"clicks"`
+	BotClicks   int      `json:"botClicks"`
+	OrgID       int      `json:"orgId"`
+	Title       string   `json:"title"`
+	Description string   `json:"description"`
+	Domain      string   `json:"domain"`
+	Locked      bool     `json:"locked"`
+	Tags        []string `json:"tags"`
+	// Targets maps a platform (ios, android, mobile, tablet, desktop) to the
+	// destination used instead of OriginalURL for visitors on it
+	Targets map[string]string `json:"targets"`
+	// Variants are the weighted destinations of an A/B test, empty when the link isn't split
+	Variants []Variant `json:"variants"`
+	// ForwardQuery appends the query string of the short link request to the destination
+	ForwardQuery bool `json:"forwardQuery"`
+	// ForwardPath appends the path after the short code to the destination, or
+	// substitutes it for a "*" in the destination
+	ForwardPath bool `json:"forwardPath"`
+	// Campaign is the utm_campaign the link was created with
+	Campaign string `json:"campaign"`
+	// Disabled links no longer redirect, e.g. after their destination was blocklisted
+	Disabled       bool   `json:"disabled"`
+	DisabledReason string `json:"disabledReason"`
+	// CampaignID is the campaign resource the link is attached to, 0 when none
+	CampaignID int `json:"campaignId"`
+	// SuspiciousClicks are clicks excluded from Clicks by click fraud detection
+	SuspiciousClicks int `json:"suspiciousClicks"`
+	// PublicStats serves the link's stats page to anyone at /:shortCode/stats
+	PublicStats bool `json:"publicStats"`
+	// UniqueClicks counts each visitor once per day, unlike Clicks
+	UniqueClicks int `json:"uniqueClicks"`
+	// Health is the latest check of the destination, nil until it is checked
+	Health *LinkHealth `json:"health"`
+	// Archived links are left out of listings but keep redirecting
+	Archived bool `json:"archived"`
+	// Rotation spreads clicks over several destinations, nil for single-destination links
+	Rotation *Rotation `json:"rotation"`
+	// AppLink opens the link in a mobile app on phones and tablets, nil when it has none
+	AppLink *AppLink `json:"appLink"`
+	// OpenGraph overrides the title, description and image of share previews, nil when unset
+	OpenGraph *OpenGraph `json:"openGraph"`
+	// CacheTTL is how many seconds a CDN may cache the link's redirect, nil
+	// for the instance default
+	CacheTTL *int `json:"cacheTtl"`
+	// TenantID is the tenant the link belongs to, for jobs acting on links of every tenant
+	TenantID int `json:"-"`
+	// Frame shows the destination in an iframe on the short domain instead of redirecting
+	Frame bool `json:"frame"`
+	// Pixels are the IDs of the retargeting pixels fired before the redirect,
+	// and CampaignPixels those attached to the link's campaign
+	Pixels         []int64 `json:"pixels"`
+	CampaignPixels []int64 `json:"-"`
+	// TrackConversions gives each click an ID passed to the destination,
+	// which reports conversions with it
+	TrackConversions bool `json:"trackConversions"`
+	// Conversions counts the conversions reported for the link's clicks
+	Conversions int64 `json:"conversions"`
+	// Notes and Metadata are free-form context kept with the link, e.g. by
+	// integrations; Metadata is a JSON object, nil when empty
+	Notes    string          `json:"notes"`
+	Metadata json.RawMessage `json:"metadata"`
+	// Stale is set on links resolved from an expired cache entry because the
+	// database failed
+	Stale bool `json:"-"`
 }
 
-type URL struct {
-	ID          int    `json:"id"`
-	OriginalURL string `json:"original"`
-	ShortCode   string `json:"shortCode"`
-	CreatedAt   string `json:"createdAt"`
-	UpdatedAt   string `json:"updatedAt"`
-	Clicks      int    `json:"clicks"`
-}
-
-func (db *Database) CreateShortURL(originalURL, shortCode string) error {
-	query := `INSERT INTO urls (original, short_code, created_at, updated_at, access_count)
-			  VALUES ($1, $2, NOW(), NOW(), 0)`
-	_, err := db.conn.Exec(query, originalURL, shortCode)
-	return err
+// GetURLVersion returns a fingerprint of the fields that change a URL's stats response
+func (db *Database) GetURLVersion(ctx context.Context, shortCode string) (string, error) {
+	var version string
+	query := `SELECT CONCAT(id, '-', updated_at, '-', access_count, '-', COALESCE(metadata_fetched_at::TEXT, ''), '-',
+			  COALESCE(health->>'checkedAt', ''), '-', conversions, '-', bot_clicks, '-', suspicious_clicks, '-',
+			  unique_clicks)
+			  FROM urls WHERE short_code = $1 AND tenant_id = $2 AND COALESCE(domain, '') = $3`
+
+	err := db.read(ctx, func(ctx context.Context, conn *sql.DB) error {
+		return conn.QueryRowContext(ctx, query, shortCode, TenantFrom(ctx), DomainFrom(ctx)).Scan(&version)
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return version, nil
 }
 
-func (db *Database) GetAllURLs(limit int) ([]URL, error) {
-	query := `SELECT id, original, short_code, created_at, updated_at, access_count
-              FROM urls ORDER BY updated_at DESC LIMIT $1`
+// GetShortCodesSince lists the codes of links of every tenant created at or
+// after since, or of every link when since is zero, along with the database time the listing was
+// taken at. It reads from the primary, so links created on other instances are
+// seen at once, and runs without the query timeout, since a full load may be large.
+func (db *Database) GetShortCodesSince(ctx context.Context, since time.Time) ([]string, time.Time, error) {
+	var asOf time.Time
+	if err := db.conn.QueryRowContext(ctx, `SELECT NOW()`).Scan(&asOf); err != nil {
+		return nil, time.Time{}, err
+	}
 
-	rows, err := db.conn.Query(query, limit)
+	rows, err := db.conn.QueryContext(ctx, `SELECT short_code FROM urls WHERE created_at >= $1`, since)
 	if err != nil {
-		return nil, err
+		return nil, time.Time{}, err
 	}
 	defer rows.Close()
 
-	urls := make([]URL, 0)
+	var codes []string
 	for rows.Next() {
-		var url URL
-		if err := rows.Scan(&url.ID, &url.OriginalURL, &url.ShortCode, &url.CreatedAt, &url.UpdatedAt, &url.Clicks); err != nil {
-			return nil, err
+		var code string
+		if err := rows.Scan(&code); err != nil {
+			return nil, time.Time{}, err
+		}
+		codes = append(codes, code)
+	}
+	return codes, asOf, rows.Err()
+}
+
+// GetLastLinkIDBefore returns the highest ID of the links created before
+// before, or 0 when there are none
+func (db *Database) GetLastLinkIDBefore(ctx context.Context, before time.Time) (int64, error) {
+	var id int64
+	err := db.conn.QueryRowContext(ctx, `SELECT COALESCE(MAX(id), 0) FROM urls WHERE created_at < $1`, before).Scan(&id)
+	return id, err
+}
+
+// GetURLsVersion returns a fingerprint of the tenant's links used to validate cached listings
+func (db *Database) GetURLsVersion(ctx context.Context) (string, error) {
+	var version string
+	query := `SELECT CONCAT(COUNT(*), '-', COALESCE(MAX(updated_at)::TEXT, ''), '-',
+			  COALESCE(SUM(access_count), 0), '-', COALESCE(MAX(metadata_fetched_at)::TEXT, ''), '-',
+			  COALESCE(MAX(health_checked_at)::TEXT, ''), '-', COALESCE(SUM(bot_clicks), 0), '-',
+			  COALESCE(SUM(suspicious_clicks), 0), '-', COALESCE(SUM(unique_clicks), 0))
+			  FROM urls WHERE tenant_id = $1`
+
+	err := db.read(ctx, func(ctx context.Context, conn *sql.DB) error {
+		return conn.QueryRowContext(ctx, query, TenantFrom(ctx)).Scan(&version)
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return version, nil
+}
+
+func (db *Database) CreateShortURL(ctx context.Context, originalURL, shortCode string) error {
+	ctx, cancel := db.queryContext(ctx)
+	defer cancel()
+
+	query := `INSERT INTO urls (original, short_code, tenant_id, created_at, updated_at, access_count)
+			  VALUES ($1, $2, $3, NOW(), NOW(), 0)`
+	_, err := db.conn.ExecContext(ctx, query, originalURL, shortCode, TenantFrom(ctx))
+	return err
+}
+
+// NewURL holds what is needed to create a link
+type NewURL struct {
+	OriginalURL string
+	// ShortCode is a custom code chosen by the client, empty to generate one
+	ShortCode      string
+	Domain         string
+	Tags           []string
+	OwnerTokenHash string
+	// OwnerID is the authenticated user creating the link, empty for anonymous users
+	OwnerID string
+	// OrgID is the organization the link belongs to, 0 for personal links
+	OrgID        int
+	Targets      map[string]string
+	ForwardQuery bool
+	ForwardPath  bool
+	Campaign     string
+	PublicStats  bool
+	OpenGraph    *OpenGraph
+	Frame        bool
+
+	TrackConversions bool
+	Notes            string
+	// Metadata is a JSON object; nil stores the empty object
+	Metadata json.RawMessage
+}
+
+// CreateSequencedURL takes the next primary key from this node's leased block
+// and stores the URL under the base62 encoding of that key, so generated codes
+// never collide, even across replicas or tenants. The link belongs to the
+// tenant of ctx. An empty domain means the link is served
+// from the default base URL.
+//
+// A custom ShortCode is stored as given instead, returning ErrCodeTaken when
+// the domain already has a link with that code. A generated code that a
+// custom code has already claimed is skipped for the next key.
+func (db *Database) CreateSequencedURL(ctx context.Context, u NewURL) (int64, string, error) {
+	ctx, cancel := db.queryContext(ctx)
+	defer cancel()
+
+	// The initial destination is the link's first version
+	openGraph, err := openGraphJSON(u.OpenGraph)
+	if err != nil {
+		return 0, "", err
+	}
+
+	query := db.createURLQuery()
+	for {
+		id, err := db.idBlock.next(ctx, db)
+		if err != nil {
+			return 0, "", err
+		}
+		shortCode := u.ShortCode
+		if shortCode == "" {
+			shortCode = base62.EncodeID(id)
+			if db.reserved[shortCode] {
+				continue
+			}
+		}
+
+		var created int
+		if err := db.queryRow(ctx, db.conn, query, id, u.OriginalURL, shortCode, u.Domain, pq.Array(u.Tags), u.OwnerTokenHash, u.OwnerID, u.OrgID, targetsJSON(u.Targets), u.ForwardQuery, u.ForwardPath, u.Campaign, u.PublicStats, openGraph, TenantFrom(ctx), u.Frame, u.TrackConversions, u.Notes, metadataJSON(u.Metadata)).Scan(&created); err != nil {
+			return 0, "", err
+		}
+		if created > 0 {
+			return id, shortCode, nil
+		}
+		if u.ShortCode != "" {
+			return 0, "", ErrCodeTaken
+		}
+	}
+}
+
+// Ownership identifies who may manage a link
+type Ownership struct {
+	// TokenHash is empty for links created before management tokens existed
+	TokenHash string
+	OwnerID   string
+	OrgID     int
+}
+
+// GetOwnership returns the management token hash and owning user of a link
+func (db *Database) GetOwnership(ctx context.Context, shortCode string) (*Ownership, error) {
+	var o Ownership
+	query := `SELECT COALESCE(owner_token_hash, ''), COALESCE(owner_id, ''), COALESCE(org_id, 0) FROM urls WHERE short_code = $1 AND tenant_id = $2 AND COALESCE(domain, '') = $3`
+	err := db.read(ctx, func(ctx context.Context, conn *sql.DB) error {
+		return conn.QueryRowContext(ctx, query, shortCode, TenantFrom(ctx), DomainFrom(ctx)).Scan(&o.TokenHash, &o.OwnerID, &o.OrgID)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &o, nil
+}
+
+// GetAllURLs lists the tenant's most recently updated links of an organization,
+// or the links outside any organization when orgID is 0, leaving out archived links.
+// They may be narrowed to one UTM campaign and to links whose destination was
+// last found broken or healthy (health "broken" or "ok").
+func (db *Database) GetAllURLs(ctx context.Context, limit, orgID int, campaign, health string, metadata map[string]string) ([]URL, error) {
+	filter, err := json.Marshal(metadata)
+	if err != nil {
+		return nil, err
+	}
+	query := `SELECT ` + urlColumns + `
+              FROM urls WHERE org_id IS NOT DISTINCT FROM NULLIF($2, 0)
+              AND ($3 = '' OR utm_campaign = $3)
+              AND ` + healthFilter("$4") + ` AND archived_at IS NULL AND tenant_id = $5
+              AND NOT EXISTS (SELECT 1 FROM jsonb_each_text(COALESCE($6::JSONB, '{}')) f WHERE urls.metadata->>f.key IS DISTINCT FROM f.value)
+              ORDER BY updated_at DESC LIMIT $1`
+
+	var urls []URL
+	err = db.read(ctx, func(ctx context.Context, conn *sql.DB) error {
+		rows, err := conn.QueryContext(ctx, query, limit, orgID, campaign, health, TenantFrom(ctx), string(filter))
+		if err != nil {
+			return err
 		}
-		urls = append(urls, url)
+		defer rows.Close()
+
+		urls = make([]URL, 0)
+		for rows.Next() {
+			url, err := scanURL(rows)
+			if err != nil {
+				return err
+			}
+			urls = append(urls, *url)
+		}
+		return rows.Err()
+	})
+
+	if err != nil {
+		return nil, err
 	}
 
 	return urls, nil
 }
 
-func (db *Database) UpdateURL(shortCode, newOriginalURL string) error {
-	query := `UPDATE urls SET original = $1, updated_at = NOW() WHERE short_code = $2`
-	result, err := db.conn.Exec(query, newOriginalURL, shortCode)
+// GetURLsPage lists up to limit links newest first, starting after the link
+// with ID afterID (0 for the first page), filtered like GetAllURLs. Paging by
+// ID keeps pages stable while links are created or updated.
+func (db *Database) GetURLsPage(ctx context.Context, limit, afterID, orgID int, campaign, health string) ([]URL, error) {
+	query := `SELECT ` + urlColumns + `
+              FROM urls WHERE org_id IS NOT DISTINCT FROM NULLIF($3, 0)
+              AND ($4 = '' OR utm_campaign = $4)
+              AND ` + healthFilter("$5") + ` AND archived_at IS NULL
+              AND ($2 = 0 OR id < $2) AND tenant_id = $6
+              ORDER BY id DESC LIMIT $1`
+
+	var urls []URL
+	err := db.read(ctx, func(ctx context.Context, conn *sql.DB) error {
+		rows, err := conn.QueryContext(ctx, query, limit, afterID, orgID, campaign, health, TenantFrom(ctx))
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		urls = make([]URL, 0, limit)
+		for rows.Next() {
+			url, err := scanURL(rows)
+			if err != nil {
+				return err
+			}
+			urls = append(urls, *url)
+		}
+		return rows.Err()
+	})
+	if err != nil {
+		return nil, err
+	}
+	return urls, nil
+}
+
+// UpdateURL changes a link's destination and records it as a new version. Links
+// created before version history existed get their previous destination
+// recorded first, so the change can be rolled back.
+func (db *Database) UpdateURL(ctx context.Context, shortCode, newOriginalURL, actor string) error {
+	ctx, cancel := db.queryContext(ctx)
+	defer cancel()
+
+	result, err := db.conn.ExecContext(ctx, db.updateURLQuery(), newOriginalURL, shortCode, actor, TenantFrom(ctx), DomainFrom(ctx))
+	if err != nil {
+		return err
+	}
+	db.changed(ctx, shortCode)
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return db.missingOrLocked(ctx, shortCode)
+	}
+
+	return nil
+}
+
+// updateURLQuery changes the destination ($1) of the link with short code $2
+// by actor $3 in tenant $4 and domain $5, recording the versions
+func (db *Database) updateURLQuery() string {
+	return `WITH target AS (
+				SELECT id, original, created_at FROM urls WHERE short_code = $2 AND tenant_id = $4 AND COALESCE(domain, '') = $5 AND NOT locked FOR UPDATE
+			  ), seed AS (
+				INSERT INTO url_versions (url_id, original, created_at)
+				SELECT id, original, created_at FROM target
+				WHERE NOT EXISTS (SELECT 1 FROM url_versions v WHERE v.url_id = target.id)
+			  ), updated AS (
+				UPDATE urls SET original = $1, updated_at = NOW(), health = NULL, health_checked_at = NULL
+				WHERE id IN (SELECT id FROM target)
+				RETURNING id, original, short_code
+			  )` + db.queueURLEvents(events.URLUpdated, "updated") + `
+			  INSERT INTO url_versions (url_id, original, created_by)
+			  SELECT id, original, NULLIF($3, '') FROM updated`
+}
+
+// LinkEdit changes one link: its destination when Destination is set, and
+// the options set in Update
+type LinkEdit struct {
+	Destination string
+	Update      BatchUpdate
+}
+
+// EditLink applies an edit to a link in one transaction. When version is set,
+// it is the link's updatedAt as the edit read it: the link's row is locked
+// and ErrEditConflict returned when it was changed since, so of two edits
+// based on the same version only the first succeeds.
+func (db *Database) EditLink(ctx context.Context, shortCode, version string, edit LinkEdit, actor string) error {
+	ctx, cancel := db.queryContext(ctx)
+	defer cancel()
+
+	tx, err := db.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var id int
+	var locked, current bool
+	query := `SELECT id, locked, $4 = '' OR updated_at = NULLIF($4, '')::TIMESTAMP FROM urls
+			  WHERE short_code = $1 AND tenant_id = $2 AND COALESCE(domain, '') = $3 FOR UPDATE`
+	err = tx.QueryRowContext(ctx, query, shortCode, TenantFrom(ctx), DomainFrom(ctx), version).Scan(&id, &locked, &current)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return fmt.Errorf("%w: %s", ErrNotFound, shortCode)
+	case err != nil:
+		return err
+	case locked:
+		return ErrLocked
+	case !current:
+		return ErrEditConflict
+	}
+
+	if edit.Destination != "" {
+		if _, err := tx.ExecContext(ctx, db.updateURLQuery(), edit.Destination, shortCode, actor, TenantFrom(ctx), DomainFrom(ctx)); err != nil {
+			return err
+		}
+	}
+	if !edit.Update.Empty() {
+		u := edit.Update
+		if _, err := tx.ExecContext(ctx, batchUpdateQuery, pq.Array([]int{id}), TenantFrom(ctx),
+			u.ForwardQuery, u.ForwardPath, u.PublicStats, u.TrackConversions,
+			u.Notes, metadataJSON(u.Metadata), u.DisabledReason, u.Frame, pq.Array(u.Tags)); err != nil {
+			return err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	db.changed(ctx, shortCode)
+	return nil
+}
+
+// SetTargets replaces a link's platform-specific destinations; an empty map removes them
+func (db *Database) SetTargets(ctx context.Context, shortCode string, targets map[string]string) error {
+	ctx, cancel := db.queryContext(ctx)
+	defer cancel()
+
+	query := `UPDATE urls SET targets = $1, updated_at = NOW() WHERE short_code = $2 AND tenant_id = $3 AND COALESCE(domain, '') = $4 AND NOT locked`
+	result, err := db.conn.ExecContext(ctx, query, targetsJSON(targets), shortCode, TenantFrom(ctx), DomainFrom(ctx))
+	if err != nil {
+		return err
+	}
+	db.changed(ctx, shortCode)
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return db.missingOrLocked(ctx, shortCode)
+	}
+
+	return nil
+}
+
+// SetCacheTTL sets how many seconds a CDN may cache a link's redirect; nil
+// restores the instance default
+func (db *Database) SetCacheTTL(ctx context.Context, shortCode string, seconds *int) error {
+	affected, err := db.execCount(ctx, `UPDATE urls SET cache_ttl = $1, updated_at = NOW() WHERE short_code = $2 AND tenant_id = $3 AND COALESCE(domain, '') = $4 AND NOT locked`, seconds, shortCode, TenantFrom(ctx), DomainFrom(ctx))
+	if err != nil {
+		return err
+	}
+	db.changed(ctx, shortCode)
+	if affected == 0 {
+		return db.missingOrLocked(ctx, shortCode)
+	}
+	return nil
+}
+
+// targetsJSON encodes targets for the JSONB column, storing NULL when there are none
+func targetsJSON(targets map[string]string) any {
+	if len(targets) == 0 {
+		return nil
+	}
+	b, _ := json.Marshal(targets)
+	return b
+}
+
+// metadataJSON passes a link's metadata object to its JSONB column, NULL when missing
+func metadataJSON(metadata json.RawMessage) any {
+	if len(metadata) == 0 {
+		return nil
+	}
+	return string(metadata)
+}
+
+// UpdatePageMetadata stores the title and description fetched from the destination page
+func (db *Database) UpdatePageMetadata(ctx context.Context, shortCode, title, description string) error {
+	ctx, cancel := db.queryContext(ctx)
+	defer cancel()
+
+	query := `UPDATE urls SET title = $1, description = $2, metadata_fetched_at = NOW() WHERE short_code = $3 AND tenant_id = $4 AND COALESCE(domain, '') = $5`
+	result, err := db.conn.ExecContext(ctx, query, title, description, shortCode, TenantFrom(ctx), DomainFrom(ctx))
 	if err != nil {
 		return err
 	}
@@ -144,15 +1188,83 @@ func (db *Database) UpdateURL(shortCode, newOriginalURL string) error {
 	}
 
 	if rowsAffected == 0 {
-		return fmt.Errorf("no URL found with short code: %s", shortCode)
+		return fmt.Errorf("%w: %s", ErrNotFound, shortCode)
 	}
 
 	return nil
 }
 
-func (db *Database) DeleteURL(shortCode string) error {
-	query := `DELETE FROM urls WHERE short_code = $1`
-	result, err := db.conn.Exec(query, shortCode)
+func (db *Database) DeleteURL(ctx context.Context, shortCode string) error {
+	ctx, cancel := db.queryContext(ctx)
+	defer cancel()
+
+	query := `WITH deleted AS (
+				DELETE FROM urls WHERE short_code = $1 AND tenant_id = $2 AND COALESCE(domain, '') = $3 AND NOT locked RETURNING short_code, original
+			  )` + db.queueURLEvents(events.URLDeleted, "deleted") + `
+			  SELECT COUNT(*) FROM deleted`
+	var deleted int
+	if err := db.conn.QueryRowContext(ctx, query, shortCode, TenantFrom(ctx), DomainFrom(ctx)).Scan(&deleted); err != nil {
+		return err
+	}
+	db.changed(ctx, shortCode)
+
+	if deleted == 0 {
+		return db.missingOrLocked(ctx, shortCode)
+	}
+
+	return nil
+}
+
+// TakenShortCodes reports which of codes are in use on the domain of ctx,
+// including by archived links
+func (db *Database) TakenShortCodes(ctx context.Context, codes []string) (map[string]bool, error) {
+	ctx, cancel := db.queryContext(ctx)
+	defer cancel()
+
+	rows, err := db.conn.QueryContext(ctx, `SELECT short_code FROM urls WHERE short_code = ANY($1) AND tenant_id = $2 AND COALESCE(domain, '') = $3`,
+		pq.Array(codes), TenantFrom(ctx), DomainFrom(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	taken := make(map[string]bool)
+	for rows.Next() {
+		var code string
+		if err := rows.Scan(&code); err != nil {
+			return nil, err
+		}
+		taken[code] = true
+	}
+	return taken, rows.Err()
+}
+
+// missingOrLocked explains why a guarded write touched no rows. A failure to
+// find out is returned as is, so it is not mistaken for a missing link.
+func (db *Database) missingOrLocked(ctx context.Context, shortCode string) error {
+	var locked bool
+	err := db.conn.QueryRowContext(ctx, `SELECT locked FROM urls WHERE short_code = $1 AND tenant_id = $2 AND COALESCE(domain, '') = $3`, shortCode, TenantFrom(ctx), DomainFrom(ctx)).Scan(&locked)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return fmt.Errorf("%w: %s", ErrNotFound, shortCode)
+	case err != nil:
+		return err
+	case locked:
+		return ErrLocked
+	}
+
+	// The link exists and is unlocked, so it was deleted or unlocked in between
+	return fmt.Errorf("%w: %s", ErrNotFound, shortCode)
+}
+
+// SetLocked locks or unlocks a URL, recording who changed it and why
+func (db *Database) SetLocked(ctx context.Context, shortCode string, locked bool, actor, reason string) error {
+	ctx, cancel := db.queryContext(ctx)
+	defer cancel()
+
+	query := `UPDATE urls SET locked = $1, lock_changed_at = NOW(), lock_changed_by = $2, lock_reason = $3
+			  WHERE short_code = $4 AND tenant_id = $5 AND COALESCE(domain, '') = $6`
+	result, err := db.conn.ExecContext(ctx, query, locked, actor, reason, shortCode, TenantFrom(ctx), DomainFrom(ctx))
 	if err != nil {
 		return err
 	}
@@ -163,7 +1275,7 @@ func (db *Database) DeleteURL(shortCode string) error {
 	}
 
 	if rowsAffected == 0 {
-		return fmt.Errorf("no URL found with short code: %s", shortCode)
+		return fmt.Errorf("%w: %s", ErrNotFound, shortCode)
 	}
 
 	return nil
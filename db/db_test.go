@@ -0,0 +1,160 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"strings"
+	"testing"
+	"url-shortener/config"
+	"url-shortener/db/dbtest"
+	"url-shortener/pkg/base62"
+)
+
+var errConnection = errors.New("connection reset by peer")
+
+// noRows answers a query with an empty result
+var noRows = dbtest.Result{Columns: []string{"id"}}
+
+// stubDatabase returns a Database whose queries are answered by respond
+func stubDatabase(t testing.TB, respond dbtest.Responder) *Database {
+	conn := dbtest.Open(respond)
+	t.Cleanup(func() { conn.Close() })
+	return New(conn, config.GetDefaultConfig())
+}
+
+func TestResolveShortCode(t *testing.T) {
+	sequenceCode := base62.EncodeID(42)
+	tests := []struct {
+		name      string
+		shortCode string
+		byID      dbtest.Result
+		byCode    dbtest.Result
+		notFound  bool
+	}{
+		{name: "custom code missing", shortCode: "golden-missing", byCode: noRows, notFound: true},
+		{name: "custom code lookup fails", shortCode: "golden-missing", byCode: dbtest.Result{Err: errConnection}},
+		{name: "sequence code missing", shortCode: sequenceCode, byID: noRows, byCode: noRows, notFound: true},
+		{name: "sequence code lookup fails", shortCode: sequenceCode, byID: dbtest.Result{Err: errConnection}, byCode: noRows},
+		{name: "fallback lookup fails", shortCode: sequenceCode, byID: noRows, byCode: dbtest.Result{Err: errConnection}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			database := stubDatabase(t, func(query string, _ []driver.NamedValue) dbtest.Result {
+				switch query {
+				case urlByIDQuery:
+					return tt.byID
+				case urlByShortCodeQuery:
+					return tt.byCode
+				}
+				t.Fatalf("unexpected query: %s", query)
+				return dbtest.Result{}
+			})
+
+			_, err := database.resolveShortCode(context.Background(), tt.shortCode)
+			if tt.notFound {
+				if !errors.Is(err, sql.ErrNoRows) {
+					t.Fatalf("want sql.ErrNoRows, got %v", err)
+				}
+				return
+			}
+			if !errors.Is(err, errConnection) || errors.Is(err, sql.ErrNoRows) {
+				t.Fatalf("want the connection error, got %v", err)
+			}
+		})
+	}
+}
+
+func TestMissingOrLocked(t *testing.T) {
+	tests := []struct {
+		name   string
+		result dbtest.Result
+		want   error
+	}{
+		{name: "missing", result: noRows, want: ErrNotFound},
+		{name: "locked", result: dbtest.Result{Columns: []string{"locked"}, Rows: [][]driver.Value{{true}}}, want: ErrLocked},
+		{name: "unlocked since", result: dbtest.Result{Columns: []string{"locked"}, Rows: [][]driver.Value{{false}}}, want: ErrNotFound},
+		{name: "lookup fails", result: dbtest.Result{Err: errConnection}, want: errConnection},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			database := stubDatabase(t, func(string, []driver.NamedValue) dbtest.Result {
+				return tt.result
+			})
+
+			err := database.missingOrLocked(context.Background(), "golden")
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("want %v, got %v", tt.want, err)
+			}
+			if tt.want == errConnection && errors.Is(err, ErrNotFound) {
+				t.Fatalf("a failed lookup was reported as a missing link: %v", err)
+			}
+		})
+	}
+}
+
+func TestUpdateURL(t *testing.T) {
+	tests := []struct {
+		name   string
+		update dbtest.Result
+		lookup dbtest.Result
+		want   error
+	}{
+		{name: "updated", update: dbtest.Result{RowsAffected: 1}},
+		{name: "missing", update: dbtest.Result{}, lookup: noRows, want: ErrNotFound},
+		{name: "locked", update: dbtest.Result{}, lookup: dbtest.Result{Columns: []string{"locked"}, Rows: [][]driver.Value{{true}}}, want: ErrLocked},
+		{name: "update fails", update: dbtest.Result{Err: errConnection}, want: errConnection},
+		{name: "lookup fails", update: dbtest.Result{}, lookup: dbtest.Result{Err: errConnection}, want: errConnection},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			database := stubDatabase(t, func(query string, _ []driver.NamedValue) dbtest.Result {
+				switch {
+				case strings.HasPrefix(query, "WITH target AS"):
+					return tt.update
+				case strings.HasPrefix(query, "SELECT locked FROM urls"):
+					return tt.lookup
+				}
+				t.Fatalf("unexpected query: %s", query)
+				return dbtest.Result{}
+			})
+
+			err := database.UpdateURL(context.Background(), "golden", "https://example.com/updated", "test")
+			if tt.want == nil {
+				if err != nil {
+					t.Fatalf("want no error, got %v", err)
+				}
+				return
+			}
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("want %v, got %v", tt.want, err)
+			}
+			if tt.want == errConnection && errors.Is(err, ErrNotFound) {
+				t.Fatalf("a failed write was reported as a missing link: %v", err)
+			}
+		})
+	}
+}
+
+func TestQueryTimeoutDisabled(t *testing.T) {
+	cfg := config.GetDefaultConfig()
+	cfg.Database.QueryTimeout = 0
+	conn := dbtest.Open(func(string, []driver.NamedValue) dbtest.Result {
+		return dbtest.Result{Columns: []string{"version"}, Rows: [][]driver.Value{{"1-2"}}}
+	})
+	t.Cleanup(func() { conn.Close() })
+	database := New(conn, cfg)
+
+	ctx, cancel := database.queryContext(context.Background())
+	defer cancel()
+	if _, ok := ctx.Deadline(); ok {
+		t.Error("want no deadline with DATABASE_QUERY_TIMEOUT=0")
+	}
+	if _, err := database.GetURLsVersion(context.Background()); err != nil {
+		t.Errorf("query failed with DATABASE_QUERY_TIMEOUT=0: %v", err)
+	}
+}
@@ -0,0 +1,162 @@
+// Package dbtest provides a database/sql driver whose queries are answered by
+// a function, so code over the database layer can be tested without
+// PostgreSQL, including the failures PostgreSQL is hard to make produce
+package dbtest
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"io"
+)
+
+// Result is the answer to a query or statement. A non-nil Err fails it;
+// otherwise a query returns Rows, with values in the order of Columns, and a
+// statement reports RowsAffected.
+type Result struct {
+	Columns      []string
+	Rows         [][]driver.Value
+	RowsAffected int64
+	Err          error
+}
+
+// Responder answers every query and statement run on the database
+type Responder func(query string, args []driver.NamedValue) Result
+
+// Open returns a database answered by respond. Transactions are accepted,
+// but their writes are only what respond makes of them.
+func Open(respond Responder) *sql.DB {
+	return sql.OpenDB(connector{respond: respond})
+}
+
+type connector struct {
+	respond Responder
+}
+
+func (c connector) Connect(context.Context) (driver.Conn, error) {
+	return conn{respond: c.respond}, nil
+}
+
+func (c connector) Driver() driver.Driver {
+	return stubDriver{}
+}
+
+type stubDriver struct{}
+
+func (stubDriver) Open(string) (driver.Conn, error) {
+	return nil, driver.ErrSkip
+}
+
+type conn struct {
+	respond Responder
+}
+
+func (c conn) Prepare(query string) (driver.Stmt, error) {
+	return stmt{respond: c.respond, query: query}, nil
+}
+
+func (c conn) Close() error {
+	return nil
+}
+
+func (c conn) Begin() (driver.Tx, error) {
+	return tx{}, nil
+}
+
+func (c conn) QueryContext(_ context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	return runQuery(c.respond, query, args)
+}
+
+func (c conn) ExecContext(_ context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	return runExec(c.respond, query, args)
+}
+
+// CheckNamedValue accepts every argument as is, as pq does for the slices
+// and byte strings the database layer passes
+func (c conn) CheckNamedValue(*driver.NamedValue) error {
+	return nil
+}
+
+type tx struct{}
+
+func (tx) Commit() error   { return nil }
+func (tx) Rollback() error { return nil }
+
+type stmt struct {
+	respond Responder
+	query   string
+}
+
+func (s stmt) Close() error {
+	return nil
+}
+
+func (s stmt) NumInput() int {
+	return -1
+}
+
+func (s stmt) Exec(args []driver.Value) (driver.Result, error) {
+	return runExec(s.respond, s.query, named(args))
+}
+
+func (s stmt) Query(args []driver.Value) (driver.Rows, error) {
+	return runQuery(s.respond, s.query, named(args))
+}
+
+func (s stmt) ExecContext(_ context.Context, args []driver.NamedValue) (driver.Result, error) {
+	return runExec(s.respond, s.query, args)
+}
+
+func (s stmt) QueryContext(_ context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	return runQuery(s.respond, s.query, args)
+}
+
+func (s stmt) CheckNamedValue(*driver.NamedValue) error {
+	return nil
+}
+
+func named(args []driver.Value) []driver.NamedValue {
+	out := make([]driver.NamedValue, len(args))
+	for i, arg := range args {
+		out[i] = driver.NamedValue{Ordinal: i + 1, Value: arg}
+	}
+	return out
+}
+
+func runQuery(respond Responder, query string, args []driver.NamedValue) (driver.Rows, error) {
+	result := respond(query, args)
+	if result.Err != nil {
+		return nil, result.Err
+	}
+	return &rows{columns: result.Columns, values: result.Rows}, nil
+}
+
+func runExec(respond Responder, query string, args []driver.NamedValue) (driver.Result, error) {
+	result := respond(query, args)
+	if result.Err != nil {
+		return nil, result.Err
+	}
+	return driver.RowsAffected(result.RowsAffected), nil
+}
+
+type rows struct {
+	columns []string
+	values  [][]driver.Value
+}
+
+func (r *rows) Columns() []string {
+	return r.columns
+}
+
+func (r *rows) Close() error {
+	return nil
+}
+
+func (r *rows) Next(dest []driver.Value) error {
+	if len(r.values) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.values[0])
+	r.values = r.values[1:]
+	return nil
+}
@@ -0,0 +1,31 @@
+package db
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrCodeTaken is returned when a custom short code is already used on the
+// link's domain
+var ErrCodeTaken = errors.New("short code is already taken on this domain")
+
+type domainKey struct{}
+
+// WithDomain scopes the short codes looked up with the returned context to a
+// custom domain. Each domain has codes of its own, so the same code may lead
+// to different links on different domains; "" is the default base URL.
+func WithDomain(ctx context.Context, domain string) context.Context {
+	return context.WithValue(ctx, domainKey{}, domain)
+}
+
+// DomainFrom returns the domain short codes looked up with ctx belong to
+func DomainFrom(ctx context.Context) string {
+	domain, _ := ctx.Value(domainKey{}).(string)
+	return domain
+}
+
+// WithLink scopes the returned context to the tenant and domain of a link,
+// for work on links of several tenants or domains at once
+func WithLink(ctx context.Context, link *URL) context.Context {
+	return WithDomain(WithTenant(ctx, link.TenantID), link.Domain)
+}
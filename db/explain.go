@@ -0,0 +1,156 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+const (
+	// explainInterval is how often the plan of one query is logged at most
+	explainInterval = time.Minute
+	// explainTimeout bounds the EXPLAIN of a slow query
+	explainTimeout = 10 * time.Second
+)
+
+// openDB opens a connection pool to dsn. With a threshold above zero, the
+// plan of every query taking longer than it is logged, for
+// DEBUG_EXPLAIN_SLOW_QUERIES.
+func openDB(dsn string, explainThreshold time.Duration) (*sql.DB, error) {
+	if explainThreshold <= 0 {
+		return sql.Open("postgres", dsn)
+	}
+	connector, err := pq.NewConnector(dsn)
+	if err != nil {
+		return nil, err
+	}
+	e := &explainer{threshold: explainThreshold, explained: map[string]time.Time{}}
+	e.pool = sql.OpenDB(&explainConnector{Connector: connector, explainer: e})
+	return e.pool, nil
+}
+
+// explainer logs the plans of slow queries, explaining them on a
+// connection of their own pool once they are done
+type explainer struct {
+	threshold time.Duration
+	pool      *sql.DB
+
+	mu        sync.Mutex
+	explained map[string]time.Time
+}
+
+// observe explains query in the background when it took longer than the
+// threshold and was not explained within the last explainInterval
+func (e *explainer) observe(query string, args []driver.NamedValue, elapsed time.Duration) {
+	if elapsed < e.threshold || !explainable(query) {
+		return
+	}
+
+	now := time.Now()
+	e.mu.Lock()
+	if now.Sub(e.explained[query]) < explainInterval {
+		e.mu.Unlock()
+		return
+	}
+	e.explained[query] = now
+	e.mu.Unlock()
+
+	values := make([]any, len(args))
+	for i, arg := range args {
+		values[i] = arg.Value
+	}
+	go e.explain(query, values, elapsed)
+}
+
+// explain logs the plan PostgreSQL picks for query with values. Without
+// ANALYZE nothing is executed, so writes can be explained too.
+func (e *explainer) explain(query string, values []any, elapsed time.Duration) {
+	ctx, cancel := context.WithTimeout(context.Background(), explainTimeout)
+	defer cancel()
+
+	oneLine := strings.Join(strings.Fields(query), " ")
+	rows, err := e.pool.QueryContext(ctx, "EXPLAIN "+query, values...)
+	if err != nil {
+		log.Printf("Slow query (%s), failed to explain: %v: %s", elapsed, err, oneLine)
+		return
+	}
+	defer rows.Close()
+
+	var plan []string
+	for rows.Next() {
+		var line string
+		if err := rows.Scan(&line); err != nil {
+			log.Printf("Slow query (%s), failed to explain: %v: %s", elapsed, err, oneLine)
+			return
+		}
+		plan = append(plan, line)
+	}
+	log.Printf("Slow query (%s): %s\n%s", elapsed, oneLine, strings.Join(plan, "\n"))
+}
+
+// explainable reports whether EXPLAIN accepts query: reads and writes, but
+// not DDL, locks or EXPLAIN itself
+func explainable(query string) bool {
+	verb, _, _ := strings.Cut(strings.TrimSpace(query), " ")
+	switch strings.ToUpper(strings.TrimSpace(verb)) {
+	case "SELECT", "INSERT", "UPDATE", "DELETE", "WITH":
+		return true
+	}
+	return false
+}
+
+// explainConnector times the queries on the connections it opens
+type explainConnector struct {
+	driver.Connector
+	explainer *explainer
+}
+
+// pqConn is what lib/pq's connections implement, all of which the wrapper
+// passes on so the pool behaves as without it
+type pqConn interface {
+	driver.Conn
+	driver.QueryerContext
+	driver.ExecerContext
+	driver.ConnPrepareContext
+	driver.ConnBeginTx
+	driver.Pinger
+	driver.SessionResetter
+	driver.Validator
+}
+
+func (c *explainConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := c.Connector.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	pc, ok := conn.(pqConn)
+	if !ok {
+		return conn, nil
+	}
+	return &explainConn{pqConn: pc, explainer: c.explainer}, nil
+}
+
+type explainConn struct {
+	pqConn
+	explainer *explainer
+}
+
+func (c *explainConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	start := time.Now()
+	rows, err := c.pqConn.QueryContext(ctx, query, args)
+	c.explainer.observe(query, args, time.Since(start))
+	return rows, err
+}
+
+func (c *explainConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	start := time.Now()
+	result, err := c.pqConn.ExecContext(ctx, query, args)
+	c.explainer.observe(query, args, time.Since(start))
+	return result, err
+}
@@ -0,0 +1,76 @@
+package db
+
+import (
+	"context"
+	"time"
+)
+
+// FeatureFlag overrides whether an experimental behavior is on, everywhere or
+// only in one environment and/or for one organization
+type FeatureFlag struct {
+	Name string `json:"name"`
+	// Environment limits the override to instances with that APP_ENV; empty means all
+	Environment string `json:"environment,omitempty"`
+	// OrgID limits the override to one organization's links; 0 means all
+	OrgID     int       `json:"orgId,omitempty"`
+	Enabled   bool      `json:"enabled"`
+	UpdatedBy string    `json:"updatedBy,omitempty"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// GetFeatureFlags lists every feature flag override by name. It reads from
+// the primary so a change takes effect as soon as it is made.
+func (db *Database) GetFeatureFlags(ctx context.Context) ([]FeatureFlag, error) {
+	ctx, cancel := db.queryContext(ctx)
+	defer cancel()
+
+	query := `SELECT name, environment, org_id, enabled, COALESCE(updated_by, ''), updated_at
+			  FROM feature_flags ORDER BY name, environment, org_id`
+	rows, err := db.conn.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	flags := make([]FeatureFlag, 0)
+	for rows.Next() {
+		var f FeatureFlag
+		if err := rows.Scan(&f.Name, &f.Environment, &f.OrgID, &f.Enabled, &f.UpdatedBy, &f.UpdatedAt); err != nil {
+			return nil, err
+		}
+		flags = append(flags, f)
+	}
+	return flags, rows.Err()
+}
+
+// SetFeatureFlag stores an override, replacing any with the same name and
+// scope, and fills in its update time
+func (db *Database) SetFeatureFlag(ctx context.Context, flag *FeatureFlag) error {
+	ctx, cancel := db.queryContext(ctx)
+	defer cancel()
+
+	query := `INSERT INTO feature_flags (name, environment, org_id, enabled, updated_by)
+			  VALUES ($1, $2, $3, $4, NULLIF($5, ''))
+			  ON CONFLICT (name, environment, org_id)
+			  DO UPDATE SET enabled = EXCLUDED.enabled, updated_by = EXCLUDED.updated_by, updated_at = NOW()
+			  RETURNING updated_at`
+	return db.conn.QueryRowContext(ctx, query, flag.Name, flag.Environment, flag.OrgID, flag.Enabled, flag.UpdatedBy).
+		Scan(&flag.UpdatedAt)
+}
+
+// DeleteFeatureFlag removes an override and returns it, or sql.ErrNoRows if
+// there is none with that name and scope
+func (db *Database) DeleteFeatureFlag(ctx context.Context, name, environment string, orgID int) (*FeatureFlag, error) {
+	ctx, cancel := db.queryContext(ctx)
+	defer cancel()
+
+	query := `DELETE FROM feature_flags WHERE name = $1 AND environment = $2 AND org_id = $3
+			  RETURNING name, environment, org_id, enabled, COALESCE(updated_by, ''), updated_at`
+	var f FeatureFlag
+	err := db.conn.QueryRowContext(ctx, query, name, environment, orgID).
+		Scan(&f.Name, &f.Environment, &f.OrgID, &f.Enabled, &f.UpdatedBy, &f.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &f, nil
+}
@@ -0,0 +1,80 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+)
+
+// LinkHealth is the outcome of the latest check of a link's destination
+type LinkHealth struct {
+	CheckedAt time.Time `json:"checkedAt"`
+	// StatusCode is the status of the final response, 0 when none arrived
+	StatusCode int   `json:"statusCode"`
+	LatencyMs  int64 `json:"latencyMs"`
+	// Redirects are the URLs the destination redirected through, in order
+	Redirects []string `json:"redirects"`
+	Error     string   `json:"error"`
+	// Broken is set on 4xx and 5xx responses and on requests that failed,
+	// e.g. because the host did not resolve
+	Broken bool `json:"broken"`
+	// BrokenSince is the first of the consecutive failed checks, nil while healthy
+	BrokenSince *time.Time `json:"brokenSince"`
+}
+
+// healthFilter is the condition matching links whose latest check found the
+// destination broken or healthy, as the parameter is "broken" or "ok", or
+// every link when it is empty
+func healthFilter(param string) string {
+	return `(` + param + ` = '' OR (health IS NOT NULL AND (health->>'broken')::BOOLEAN = (` + param + ` = 'broken')))`
+}
+
+// ClaimHealthChecks picks up to limit enabled links of any tenant not checked since before,
+// the least recently checked first, and marks them as checked now so other
+// instances pass over them. The links hold the outcome of their previous check.
+func (db *Database) ClaimHealthChecks(ctx context.Context, before time.Time, limit int) ([]URL, error) {
+	ctx, cancel := db.queryContext(ctx)
+	defer cancel()
+
+	query := `UPDATE urls SET health_checked_at = NOW()
+			  WHERE id IN (
+				SELECT id FROM urls
+				WHERE disabled_at IS NULL AND (health_checked_at IS NULL OR health_checked_at < $1)
+				ORDER BY health_checked_at NULLS FIRST LIMIT $2
+				FOR UPDATE SKIP LOCKED
+			  )
+			  RETURNING ` + urlColumns
+	rows, err := db.conn.QueryContext(ctx, query, before, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var urls []URL
+	for rows.Next() {
+		url, err := scanURL(rows)
+		if err != nil {
+			return nil, err
+		}
+		urls = append(urls, *url)
+	}
+	return urls, rows.Err()
+}
+
+// SetLinkHealth stores the outcome of a health check of destination, unless
+// the link was pointed elsewhere while it ran
+func (db *Database) SetLinkHealth(ctx context.Context, shortCode, destination string, health *LinkHealth) error {
+	ctx, cancel := db.queryContext(ctx)
+	defer cancel()
+
+	value, err := json.Marshal(health)
+	if err != nil {
+		return err
+	}
+	if _, err := db.conn.ExecContext(ctx, `UPDATE urls SET health = $1 WHERE short_code = $2 AND original = $3 AND tenant_id = $4 AND COALESCE(domain, '') = $5`,
+		value, shortCode, destination, TenantFrom(ctx), DomainFrom(ctx)); err != nil {
+		return err
+	}
+	db.forget(ctx, shortCode)
+	return nil
+}
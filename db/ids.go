@@ -0,0 +1,61 @@
+package db
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// IDLeaseLifetime bounds how long leased IDs are handed out. What is left of
+// a block is dropped after it, so an ID drawn from the sequence longer ago
+// than that has either been used or never will be.
+const IDLeaseLifetime = time.Minute
+
+// idBlock hands out link IDs from a range leased from the urls id sequence,
+// so each replica reserves IDs in one round trip per block instead of one per
+// link. Leased values are unique across nodes; IDs left unused when a node
+// stops or its lease expires are simply skipped.
+type idBlock struct {
+	mu       sync.Mutex
+	size     int
+	ids      []int64
+	leasedAt time.Time
+}
+
+// next returns an unused ID, leasing a new block from the sequence once the
+// current one is exhausted
+func (b *idBlock) next(ctx context.Context, db *Database) (int64, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if len(b.ids) == 0 || time.Since(b.leasedAt) > IDLeaseLifetime {
+		ids, err := db.leaseIDs(ctx, max(b.size, 1))
+		if err != nil {
+			return 0, err
+		}
+		b.ids, b.leasedAt = ids, time.Now()
+	}
+
+	id := b.ids[0]
+	b.ids = b.ids[1:]
+	return id, nil
+}
+
+// leaseIDs draws n values from the urls id sequence in a single statement
+func (db *Database) leaseIDs(ctx context.Context, n int) ([]int64, error) {
+	rows, err := db.conn.QueryContext(ctx, `SELECT nextval(pg_get_serial_sequence('urls', 'id')) FROM generate_series(1, $1)`, n)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := make([]int64, 0, n)
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
@@ -0,0 +1,151 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// ErrDuplicateImport is returned when an import with the same source and external ID already ran
+var ErrDuplicateImport = errors.New("import already applied")
+
+// UnknownCodesError lists short codes in an import that do not exist
+type UnknownCodesError struct {
+	ShortCodes []string
+}
+
+func (e *UnknownCodesError) Error() string {
+	return fmt.Sprintf("unknown short codes: %v", e.ShortCodes)
+}
+
+// ClickImportRecord is a number of historical clicks attributed to a code on a day
+type ClickImportRecord struct {
+	ShortCode string
+	Day       time.Time
+	Clicks    int
+}
+
+// ClickImport describes where a batch of historical clicks came from
+type ClickImport struct {
+	ID          int       `json:"id"`
+	Source      string    `json:"source"`
+	ExternalID  string    `json:"externalId,omitempty"`
+	Note        string    `json:"note,omitempty"`
+	ImportedBy  string    `json:"importedBy"`
+	ImportedAt  time.Time `json:"importedAt"`
+	TotalClicks int64     `json:"totalClicks"`
+}
+
+// ImportClicks records a batch of historical clicks with its provenance and adds
+// them to each link's access count, all in one transaction
+func (db *Database) ImportClicks(ctx context.Context, batch ClickImport, records []ClickImportRecord) (*ClickImport, error) {
+	ctx, cancel := db.queryContext(ctx)
+	defer cancel()
+
+	tx, err := db.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	codes := make([]string, 0, len(records))
+	for _, r := range records {
+		codes = append(codes, r.ShortCode)
+	}
+
+	ids := make(map[string]int, len(codes))
+	rows, err := tx.QueryContext(ctx, `SELECT short_code, id FROM urls WHERE short_code = ANY($1) AND tenant_id = $2 AND COALESCE(domain, '') = $3`, pq.Array(codes), TenantFrom(ctx), DomainFrom(ctx))
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var code string
+		var id int
+		if err := rows.Scan(&code, &id); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		ids[code] = id
+	}
+	rows.Close()
+
+	var unknown []string
+	for _, code := range codes {
+		if _, ok := ids[code]; !ok {
+			unknown = append(unknown, code)
+		}
+	}
+	if len(unknown) > 0 {
+		return nil, &UnknownCodesError{ShortCodes: unknown}
+	}
+
+	var total int64
+	for _, r := range records {
+		total += int64(r.Clicks)
+	}
+
+	err = tx.QueryRowContext(ctx, `INSERT INTO click_imports (source, external_id, note, imported_by, total_clicks)
+		VALUES ($1, NULLIF($2, ''), NULLIF($3, ''), $4, $5)
+		ON CONFLICT (source, external_id) DO NOTHING
+		RETURNING id, imported_at`,
+		batch.Source, batch.ExternalID, batch.Note, batch.ImportedBy, total).Scan(&batch.ID, &batch.ImportedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrDuplicateImport
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for _, r := range records {
+		id := ids[r.ShortCode]
+		if _, err := tx.ExecContext(ctx, `INSERT INTO click_import_records (import_id, url_id, day, clicks) VALUES ($1, $2, $3, $4)`,
+			batch.ID, id, r.Day, r.Clicks); err != nil {
+			return nil, err
+		}
+		if _, err := tx.ExecContext(ctx, `UPDATE urls SET access_count = access_count + $1 WHERE id = $2`, r.Clicks, id); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	batch.TotalClicks = total
+	return &batch, nil
+}
+
+// GetClickImports lists past imports, newest first
+func (db *Database) GetClickImports(ctx context.Context, limit int) ([]ClickImport, error) {
+	query := `SELECT id, source, COALESCE(external_id, ''), COALESCE(note, ''), imported_by, imported_at, total_clicks
+			  FROM click_imports ORDER BY imported_at DESC LIMIT $1`
+
+	var imports []ClickImport
+	err := db.read(ctx, func(ctx context.Context, conn *sql.DB) error {
+		rows, err := conn.QueryContext(ctx, query, limit)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		imports = make([]ClickImport, 0)
+		for rows.Next() {
+			var ci ClickImport
+			if err := rows.Scan(&ci.ID, &ci.Source, &ci.ExternalID, &ci.Note, &ci.ImportedBy, &ci.ImportedAt, &ci.TotalClicks); err != nil {
+				return err
+			}
+			imports = append(imports, ci)
+		}
+		return rows.Err()
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return imports, nil
+}
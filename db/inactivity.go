@@ -0,0 +1,185 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// Actions an inactivity policy takes on links without recent clicks
+const (
+	InactiveArchive = "archive"
+	InactiveDisable = "disable"
+)
+
+// ErrNotArchived is returned when un-archiving a link that is not archived
+var ErrNotArchived = errors.New("url is not archived")
+
+// InactivityPolicy is what happens to links that get no clicks for Months
+// months: archived links are no longer listed but keep redirecting, disabled
+// links stop redirecting. Months 0 leaves links alone.
+type InactivityPolicy struct {
+	Months int    `json:"months"`
+	Action string `json:"action"`
+	// Inherited is set when an organization has no policy of its own and the
+	// instance default applies
+	Inherited bool `json:"inherited"`
+}
+
+// GetInactivityPolicy returns an organization's inactivity policy, or def
+// when it has none. It returns sql.ErrNoRows for unknown organizations.
+func (db *Database) GetInactivityPolicy(ctx context.Context, orgID int, def InactivityPolicy) (*InactivityPolicy, error) {
+	var months sql.NullInt64
+	var action sql.NullString
+	err := db.read(ctx, func(ctx context.Context, conn *sql.DB) error {
+		return conn.QueryRowContext(ctx, `SELECT inactive_months, inactive_action FROM organizations WHERE id = $1`, orgID).
+			Scan(&months, &action)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if !months.Valid {
+		def.Inherited = true
+		return &def, nil
+	}
+	return &InactivityPolicy{Months: int(months.Int64), Action: action.String}, nil
+}
+
+// SetInactivityPolicy gives an organization its own inactivity policy, or
+// makes it follow the instance default again when policy is nil
+func (db *Database) SetInactivityPolicy(ctx context.Context, orgID int, policy *InactivityPolicy) error {
+	var months, action any
+	if policy != nil {
+		months, action = policy.Months, policy.Action
+	}
+	affected, err := db.execCount(ctx, `UPDATE organizations SET inactive_months = $2, inactive_action = $3 WHERE id = $1`, orgID, months, action)
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// ApplyInactivityPolicies archives or disables links that got no clicks in
+// the months their organization's policy allows, or def for links outside an
+// organization or in one without a policy. Time before a link was created or
+// last un-archived or re-enabled does not count. Locked links are left alone.
+// It returns how many links were archived and disabled, and runs without the
+// query timeout, since it looks at every link.
+func (db *Database) ApplyInactivityPolicies(ctx context.Context, def InactivityPolicy) (archived, disabled int64, err error) {
+	query := `WITH policy AS (
+				SELECT u.id, COALESCE(o.inactive_months, $1) AS months, COALESCE(o.inactive_action, $2) AS action,
+				NOW() - make_interval(months => COALESCE(o.inactive_months, $1)) AS cutoff
+				FROM urls u LEFT JOIN organizations o ON o.id = u.org_id
+				WHERE u.archived_at IS NULL AND u.disabled_at IS NULL AND NOT u.locked
+			  ), inactive AS (
+				SELECT p.id, p.months, p.action FROM policy p JOIN urls u ON u.id = p.id
+				WHERE p.months > 0 AND GREATEST(u.created_at, u.revived_at) < p.cutoff
+				AND NOT EXISTS (SELECT 1 FROM clicks c WHERE c.url_id = u.id AND c.clicked_at >= p.cutoff)
+				AND NOT EXISTS (SELECT 1 FROM click_rollups r WHERE r.url_id = u.id AND r.day >= p.cutoff::DATE)
+			  )
+			  UPDATE urls SET updated_at = NOW(),
+			  archived_at = CASE WHEN i.action = 'archive' THEN NOW() END,
+			  disabled_at = CASE WHEN i.action = 'disable' THEN NOW() END,
+			  disabled_reason = CASE WHEN i.action = 'disable' THEN 'No clicks in ' || i.months || ' months' END
+			  FROM inactive i WHERE urls.id = i.id
+			  RETURNING i.action, urls.short_code, urls.tenant_id, COALESCE(urls.domain, '')`
+	rows, err := db.conn.QueryContext(ctx, query, def.Months, def.Action)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var action, shortCode, domain string
+		var tenant int
+		if err := rows.Scan(&action, &shortCode, &tenant, &domain); err != nil {
+			return 0, 0, err
+		}
+		// Archived links keep redirecting
+		link := WithDomain(WithTenant(ctx, tenant), domain)
+		if action == InactiveArchive {
+			archived++
+			db.forget(link, shortCode)
+		} else {
+			disabled++
+			db.changed(link, shortCode)
+		}
+	}
+	return archived, disabled, rows.Err()
+}
+
+// SearchArchivedURLs lists up to limit of the tenant's archived links of an organization, or
+// outside any organization when orgID is 0, most recently archived first.
+// A non-empty search narrows them to links whose short code, destination or
+// title contains it.
+func (db *Database) SearchArchivedURLs(ctx context.Context, orgID int, search string, limit int) ([]URL, error) {
+	query := `SELECT ` + urlColumns + `
+              FROM urls WHERE archived_at IS NOT NULL AND org_id IS NOT DISTINCT FROM NULLIF($1, 0)
+              AND ($2 = '' OR short_code ILIKE '%' || $2 || '%' OR original ILIKE '%' || $2 || '%' OR title ILIKE '%' || $2 || '%')
+              AND tenant_id = $4
+              ORDER BY archived_at DESC LIMIT $3`
+
+	var urls []URL
+	err := db.read(ctx, func(ctx context.Context, conn *sql.DB) error {
+		rows, err := conn.QueryContext(ctx, query, orgID, search, limit, TenantFrom(ctx))
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		urls = make([]URL, 0)
+		for rows.Next() {
+			url, err := scanURL(rows)
+			if err != nil {
+				return err
+			}
+			urls = append(urls, *url)
+		}
+		return rows.Err()
+	})
+	if err != nil {
+		return nil, err
+	}
+	return urls, nil
+}
+
+// UnarchiveURL lists an archived link again. Its inactivity is counted afresh
+// from now, so it is not archived again right away. It returns ErrNotArchived
+// when the link is not archived.
+func (db *Database) UnarchiveURL(ctx context.Context, shortCode string) error {
+	ctx, cancel := db.queryContext(ctx)
+	defer cancel()
+
+	query := `UPDATE urls SET archived_at = NULL, revived_at = NOW(), updated_at = NOW()
+			  WHERE short_code = $1 AND tenant_id = $2 AND COALESCE(domain, '') = $3 AND archived_at IS NOT NULL AND NOT locked`
+	result, err := db.conn.ExecContext(ctx, query, shortCode, TenantFrom(ctx), DomainFrom(ctx))
+	if err != nil {
+		return err
+	}
+	db.forget(ctx, shortCode)
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected > 0 {
+		return nil
+	}
+
+	var locked bool
+	err = db.conn.QueryRowContext(ctx, `SELECT locked FROM urls WHERE short_code = $1 AND tenant_id = $2 AND COALESCE(domain, '') = $3`, shortCode, TenantFrom(ctx), DomainFrom(ctx)).Scan(&locked)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return fmt.Errorf("%w: %s", ErrNotFound, shortCode)
+	case err != nil:
+		return err
+	case locked:
+		return ErrLocked
+	}
+	return ErrNotArchived
+}
@@ -0,0 +1,87 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// IP rule actions
+const (
+	// IPRuleBlock rejects every request from the network
+	IPRuleBlock = "block"
+	// IPRuleAdminAllow adds the network to the admin allowlist
+	IPRuleAdminAllow = "admin_allow"
+)
+
+// ErrDuplicateIPRule is returned when the same network already has a rule with that action
+var ErrDuplicateIPRule = errors.New("ip rule already exists")
+
+// IPRule blocks a network or admits it to the admin API
+type IPRule struct {
+	ID        int64     `json:"id"`
+	CIDR      string    `json:"cidr"`
+	Action    string    `json:"action"`
+	Note      string    `json:"note,omitempty"`
+	CreatedBy string    `json:"createdBy,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// GetIPRules lists all IP rules, oldest first. It reads from the primary so a
+// rule takes effect as soon as it is added.
+func (db *Database) GetIPRules(ctx context.Context) ([]IPRule, error) {
+	ctx, cancel := db.queryContext(ctx)
+	defer cancel()
+
+	query := `SELECT id, cidr::TEXT, action, COALESCE(note, ''), COALESCE(created_by, ''), created_at
+			  FROM ip_rules ORDER BY id`
+	rows, err := db.conn.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	rules := make([]IPRule, 0)
+	for rows.Next() {
+		var r IPRule
+		if err := rows.Scan(&r.ID, &r.CIDR, &r.Action, &r.Note, &r.CreatedBy, &r.CreatedAt); err != nil {
+			return nil, err
+		}
+		rules = append(rules, r)
+	}
+	return rules, rows.Err()
+}
+
+// AddIPRule stores a rule, filling in its ID and creation time
+func (db *Database) AddIPRule(ctx context.Context, rule *IPRule) error {
+	ctx, cancel := db.queryContext(ctx)
+	defer cancel()
+
+	query := `INSERT INTO ip_rules (cidr, action, note, created_by)
+			  VALUES ($1, $2, NULLIF($3, ''), NULLIF($4, ''))
+			  ON CONFLICT (cidr, action) DO NOTHING
+			  RETURNING id, cidr::TEXT, created_at`
+	err := db.conn.QueryRowContext(ctx, query, rule.CIDR, rule.Action, rule.Note, rule.CreatedBy).
+		Scan(&rule.ID, &rule.CIDR, &rule.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrDuplicateIPRule
+	}
+	return err
+}
+
+// DeleteIPRule removes a rule and returns it, or sql.ErrNoRows if there is none with that ID
+func (db *Database) DeleteIPRule(ctx context.Context, id int64) (*IPRule, error) {
+	ctx, cancel := db.queryContext(ctx)
+	defer cancel()
+
+	query := `DELETE FROM ip_rules WHERE id = $1
+			  RETURNING id, cidr::TEXT, action, COALESCE(note, ''), COALESCE(created_by, ''), created_at`
+	var r IPRule
+	err := db.conn.QueryRowContext(ctx, query, id).
+		Scan(&r.ID, &r.CIDR, &r.Action, &r.Note, &r.CreatedBy, &r.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
@@ -0,0 +1,73 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ewmaWeight is the weight given to each new observation in the moving averages
+const ewmaWeight = 0.1
+
+// queryMetrics keeps exponentially weighted moving averages of query latency and failure rate
+type queryMetrics struct {
+	mu        sync.Mutex
+	latency   time.Duration
+	errorRate float64
+}
+
+func (m *queryMetrics) observe(elapsed time.Duration, failed bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	failure := 0.0
+	if failed {
+		failure = 1
+	}
+
+	m.latency += time.Duration(ewmaWeight * float64(elapsed-m.latency))
+	m.errorRate += ewmaWeight * (failure - m.errorRate)
+}
+
+// Health reports the moving average query latency and error rate (0..1)
+func (db *Database) Health() (time.Duration, float64) {
+	db.metrics.mu.Lock()
+	defer db.metrics.mu.Unlock()
+
+	return db.metrics.latency, db.metrics.errorRate
+}
+
+// queryContext bounds a single query by the configured timeout while still
+// honouring cancellation of the caller's context. The returned cancel func
+// also records the query's latency, counting timeouts as failures.
+func (db *Database) queryContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	start := time.Now()
+	ctx, cancel := db.withTimeout(ctx)
+
+	return ctx, func() {
+		db.metrics.observe(time.Since(start), errors.Is(ctx.Err(), context.DeadlineExceeded))
+		cancel()
+	}
+}
+
+// withTimeout bounds ctx by the configured query timeout. A timeout of zero
+// or less disables it rather than failing every query at once.
+func (db *Database) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if db.queryTimeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, db.queryTimeout)
+}
+
+// Probe pings the database once. Run every ProbeInterval, it makes
+// connection failures show up in Health even when no requests are reaching
+// the db layer.
+func (db *Database) Probe(ctx context.Context) error {
+	start := time.Now()
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+	err := db.conn.PingContext(ctx)
+	db.metrics.observe(time.Since(start), err != nil)
+	return err
+}
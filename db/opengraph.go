@@ -0,0 +1,51 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+)
+
+// OpenGraph overrides how a link is previewed when it is shared: the title,
+// description and image social networks and chat apps show for it
+type OpenGraph struct {
+	Title       string `json:"title,omitempty"`
+	Description string `json:"description,omitempty"`
+	Image       string `json:"image,omitempty"`
+}
+
+// openGraphJSON encodes an Open Graph override for storage; nil stores NULL
+func openGraphJSON(og *OpenGraph) (any, error) {
+	if og == nil {
+		return nil, nil
+	}
+	return json.Marshal(og)
+}
+
+// SetOpenGraph replaces a link's Open Graph override; nil removes it
+func (db *Database) SetOpenGraph(ctx context.Context, shortCode string, og *OpenGraph) error {
+	ctx, cancel := db.queryContext(ctx)
+	defer cancel()
+
+	encoded, err := openGraphJSON(og)
+	if err != nil {
+		return err
+	}
+
+	query := `UPDATE urls SET open_graph = $1, updated_at = NOW() WHERE short_code = $2 AND tenant_id = $3 AND COALESCE(domain, '') = $4 AND NOT locked`
+	result, err := db.conn.ExecContext(ctx, query, encoded, shortCode, TenantFrom(ctx), DomainFrom(ctx))
+	if err != nil {
+		return err
+	}
+	db.changed(ctx, shortCode)
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return db.missingOrLocked(ctx, shortCode)
+	}
+
+	return nil
+}
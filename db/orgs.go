@@ -0,0 +1,212 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// Organization roles, from most to least privileged
+const (
+	RoleOwner  = "owner"
+	RoleEditor = "editor"
+	RoleViewer = "viewer"
+)
+
+// ErrLastOwner is returned when a change would leave an organization without an owner
+var ErrLastOwner = errors.New("organization must keep at least one owner")
+
+// Organization is a workspace whose members share its links
+type Organization struct {
+	ID        int       `json:"id"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"createdAt"`
+	// RequireTwoFactor keeps members signed in without two-factor
+	// authentication out of the organization
+	RequireTwoFactor bool `json:"requireTwoFactor"`
+	// Role is the requesting user's role, set when listing their organizations
+	Role string `json:"role,omitempty"`
+}
+
+// Member is a user's role in an organization
+type Member struct {
+	UserID  string    `json:"userId"`
+	Role    string    `json:"role"`
+	AddedAt time.Time `json:"addedAt"`
+}
+
+// CreateOrganization creates an organization of the tenant owned by userID
+func (db *Database) CreateOrganization(ctx context.Context, name, userID string) (*Organization, error) {
+	ctx, cancel := db.queryContext(ctx)
+	defer cancel()
+
+	tx, err := db.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	org := Organization{Name: name, Role: RoleOwner}
+	if err := tx.QueryRowContext(ctx, `INSERT INTO organizations (name, tenant_id) VALUES ($1, $2) RETURNING id, created_at`, name, TenantFrom(ctx)).
+		Scan(&org.ID, &org.CreatedAt); err != nil {
+		return nil, err
+	}
+	if _, err := tx.ExecContext(ctx, `INSERT INTO organization_members (org_id, user_id, role) VALUES ($1, $2, $3)`,
+		org.ID, userID, RoleOwner); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return &org, nil
+}
+
+// GetUserOrganizations lists the organizations of the tenant userID belongs to, with their role
+func (db *Database) GetUserOrganizations(ctx context.Context, userID string) ([]Organization, error) {
+	query := `SELECT o.id, o.name, o.created_at, o.require_two_factor, m.role
+			  FROM organizations o JOIN organization_members m ON m.org_id = o.id
+			  WHERE m.user_id = $1 AND o.tenant_id = $2 ORDER BY o.name`
+
+	var orgs []Organization
+	err := db.read(ctx, func(ctx context.Context, conn *sql.DB) error {
+		rows, err := conn.QueryContext(ctx, query, userID, TenantFrom(ctx))
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		orgs = make([]Organization, 0)
+		for rows.Next() {
+			var o Organization
+			if err := rows.Scan(&o.ID, &o.Name, &o.CreatedAt, &o.RequireTwoFactor, &o.Role); err != nil {
+				return err
+			}
+			orgs = append(orgs, o)
+		}
+		return rows.Err()
+	})
+	if err != nil {
+		return nil, err
+	}
+	return orgs, nil
+}
+
+// GetMemberRole returns userID's role in an organization, or sql.ErrNoRows for
+// non-members and organizations of other tenants
+func (db *Database) GetMemberRole(ctx context.Context, orgID int, userID string) (string, error) {
+	ctx, cancel := db.queryContext(ctx)
+	defer cancel()
+
+	// Membership decides access, so it is read from the primary to apply changes immediately
+	var role string
+	query := `SELECT m.role FROM organization_members m JOIN organizations o ON o.id = m.org_id
+			  WHERE m.org_id = $1 AND m.user_id = $2 AND o.tenant_id = $3`
+	err := db.conn.QueryRowContext(ctx, query, orgID, userID, TenantFrom(ctx)).Scan(&role)
+	if err != nil {
+		return "", err
+	}
+	return role, nil
+}
+
+// OrganizationExists reports whether orgID is an organization of the tenant of ctx
+func (db *Database) OrganizationExists(ctx context.Context, orgID int) (bool, error) {
+	ctx, cancel := db.queryContext(ctx)
+	defer cancel()
+
+	var exists bool
+	err := db.conn.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM organizations WHERE id = $1 AND tenant_id = $2)`,
+		orgID, TenantFrom(ctx)).Scan(&exists)
+	return exists, err
+}
+
+func (db *Database) GetMembers(ctx context.Context, orgID int) ([]Member, error) {
+	query := `SELECT user_id, role, added_at FROM organization_members WHERE org_id = $1 ORDER BY added_at`
+
+	var members []Member
+	err := db.read(ctx, func(ctx context.Context, conn *sql.DB) error {
+		rows, err := conn.QueryContext(ctx, query, orgID)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		members = make([]Member, 0)
+		for rows.Next() {
+			var m Member
+			if err := rows.Scan(&m.UserID, &m.Role, &m.AddedAt); err != nil {
+				return err
+			}
+			members = append(members, m)
+		}
+		return rows.Err()
+	})
+	if err != nil {
+		return nil, err
+	}
+	return members, nil
+}
+
+// SetMember adds a user to an organization or changes their role
+func (db *Database) SetMember(ctx context.Context, orgID int, userID, role string) error {
+	ctx, cancel := db.queryContext(ctx)
+	defer cancel()
+
+	tx, err := db.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `INSERT INTO organization_members (org_id, user_id, role) VALUES ($1, $2, $3)
+			  ON CONFLICT (org_id, user_id) DO UPDATE SET role = EXCLUDED.role`
+	if _, err := tx.ExecContext(ctx, query, orgID, userID, role); err != nil {
+		return err
+	}
+	if err := ensureOwner(ctx, tx, orgID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// RemoveMember removes a user from an organization
+func (db *Database) RemoveMember(ctx context.Context, orgID int, userID string) error {
+	ctx, cancel := db.queryContext(ctx)
+	defer cancel()
+
+	tx, err := db.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx, `DELETE FROM organization_members WHERE org_id = $1 AND user_id = $2`, orgID, userID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	if err := ensureOwner(ctx, tx, orgID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// ensureOwner fails when the pending membership changes leave the organization without an owner
+func ensureOwner(ctx context.Context, tx *sql.Tx, orgID int) error {
+	var owners int
+	// Lock the organization row so concurrent demotions can't both pass the check
+	if _, err := tx.ExecContext(ctx, `SELECT id FROM organizations WHERE id = $1 FOR UPDATE`, orgID); err != nil {
+		return err
+	}
+	if err := tx.QueryRowContext(ctx, `SELECT COUNT(*) FROM organization_members WHERE org_id = $1 AND role = $2`,
+		orgID, RoleOwner).Scan(&owners); err != nil {
+		return err
+	}
+	if owners == 0 {
+		return ErrLastOwner
+	}
+	return nil
+}
@@ -0,0 +1,106 @@
+package db
+
+import (
+	"cmp"
+	"context"
+	"slices"
+	"time"
+	"url-shortener/events"
+
+	"github.com/lib/pq"
+)
+
+// maxOutboxBackoff caps the delay before a failed event is retried, in seconds
+const maxOutboxBackoff = 3600
+
+// OutboxEvent is an event written along with the change it describes,
+// waiting to be delivered
+type OutboxEvent struct {
+	ID int64
+	// EventID is the envelope ID, kept across retries so consumers can drop duplicates
+	EventID   string
+	Type      events.Type
+	Key       string
+	Payload   []byte
+	CreatedAt time.Time
+	Attempts  int
+}
+
+// EnableOutbox makes link creation, destination changes and deletion queue a
+// url.created, url.updated or url.deleted event in the same statement, so no
+// change commits without its event. It must be called before serving requests.
+func (db *Database) EnableOutbox() {
+	db.outbox = true
+}
+
+// queueURLEvents is a CTE queueing an event of eventType for each link
+// returned by source, a CTE yielding short_code and original, or nothing
+// while the outbox is disabled. The payload matches events.URLData.
+func (db *Database) queueURLEvents(eventType events.Type, source string) string {
+	if !db.outbox {
+		return ""
+	}
+	return `, queued_events AS (
+				INSERT INTO outbox (event_type, event_key, payload)
+				SELECT '` + string(eventType) + `', short_code, jsonb_build_object('shortCode', short_code, 'original', original)
+				FROM ` + source + `
+			  )`
+}
+
+// ClaimOutboxEvents takes up to limit events due for delivery, oldest first,
+// and holds them for lease. Events that are neither delivered nor released
+// by then, e.g. because the instance died, are claimed again.
+func (db *Database) ClaimOutboxEvents(ctx context.Context, limit int, lease time.Duration) ([]OutboxEvent, error) {
+	ctx, cancel := db.queryContext(ctx)
+	defer cancel()
+
+	query := `UPDATE outbox SET attempts = attempts + 1, next_attempt_at = NOW() + $2 * INTERVAL '1 second'
+			  WHERE id IN (
+				SELECT id FROM outbox WHERE next_attempt_at <= NOW()
+				ORDER BY id LIMIT $1 FOR UPDATE SKIP LOCKED
+			  )
+			  RETURNING id, event_id, event_type, event_key, payload, created_at, attempts`
+	rows, err := db.conn.QueryContext(ctx, query, limit, lease.Seconds())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	claimed := make([]OutboxEvent, 0)
+	for rows.Next() {
+		var e OutboxEvent
+		if err := rows.Scan(&e.ID, &e.EventID, &e.Type, &e.Key, &e.Payload, &e.CreatedAt, &e.Attempts); err != nil {
+			return nil, err
+		}
+		claimed = append(claimed, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Claiming does not keep the order; deliver in the order events happened
+	slices.SortFunc(claimed, func(a, b OutboxEvent) int { return cmp.Compare(a.ID, b.ID) })
+	return claimed, nil
+}
+
+// DeleteOutboxEvents removes delivered events
+func (db *Database) DeleteOutboxEvents(ctx context.Context, ids []int64) error {
+	ctx, cancel := db.queryContext(ctx)
+	defer cancel()
+
+	_, err := db.conn.ExecContext(ctx, `DELETE FROM outbox WHERE id = ANY($1)`, pq.Array(ids))
+	return err
+}
+
+// RetryOutboxEvents records why events could not be delivered and backs
+// them off exponentially, up to an hour between attempts
+func (db *Database) RetryOutboxEvents(ctx context.Context, ids []int64, reason string) error {
+	ctx, cancel := db.queryContext(ctx)
+	defer cancel()
+
+	query := `UPDATE outbox SET last_error = $2,
+			  next_attempt_at = NOW() + LEAST(POWER(2, attempts), $3) * INTERVAL '1 second'
+			  WHERE id = ANY($1)`
+	_, err := db.conn.ExecContext(ctx, query, pq.Array(ids), reason, maxOutboxBackoff)
+	return err
+}
@@ -0,0 +1,254 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+const (
+	// legacyClickPartition holds the clicks recorded before the table was
+	// partitioned, up to the first monthly partition
+	legacyClickPartition = "clicks_legacy"
+	// clickPartitionPrefix names monthly partitions, e.g. clicks_p2025_01
+	clickPartitionPrefix = "clicks_p"
+	clickPartitionLayout = "2006_01"
+)
+
+// ClickPartition is a table holding the clicks of one range of time
+type ClickPartition struct {
+	Name string
+	// Start is zero for the legacy partition, which holds everything before
+	// the first monthly one
+	Start time.Time
+	End   time.Time
+}
+
+// ClickEvent is a raw click as archived from a detached partition
+type ClickEvent struct {
+	ID        int64
+	URLID     int64
+	ClickedAt time.Time
+	Device    string
+	Browser   string
+	OS        string
+	Country   string
+	ClickID   string
+}
+
+// clickPartitionName is the name of the partition holding month
+func clickPartitionName(month time.Time) string {
+	return clickPartitionPrefix + month.Format(clickPartitionLayout)
+}
+
+// PartitionClicks turns clicks into a table partitioned by month, unless it
+// already is. The existing table is kept as the legacy partition, covering
+// everything up to the end of the current month, so no rows are copied; it
+// is locked while the partition bound is checked and its primary key rebuilt
+// to include clicked_at. It reports whether the table was converted.
+func (db *Database) PartitionClicks(ctx context.Context) (bool, error) {
+	tx, err := db.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	var kind string
+	if err := tx.QueryRowContext(ctx, `SELECT relkind FROM pg_class WHERE oid = 'clicks'::regclass`).Scan(&kind); err != nil {
+		return false, err
+	}
+	if kind == "p" {
+		return false, nil
+	}
+
+	// Index names share a namespace with tables, so the legacy table's give
+	// theirs up to the partitioned table's
+	for _, query := range []string{
+		`LOCK TABLE clicks IN ACCESS EXCLUSIVE MODE`,
+		`ALTER TABLE clicks RENAME TO ` + legacyClickPartition,
+		`ALTER INDEX clicks_pkey RENAME TO clicks_legacy_pkey`,
+		`ALTER INDEX IF EXISTS clicks_url_idx RENAME TO clicks_legacy_url_idx`,
+		`ALTER INDEX IF EXISTS clicks_click_id_idx RENAME TO clicks_legacy_click_id_idx`,
+		// Unique indexes of a partitioned table must include the partition key
+		`CREATE TABLE clicks (
+			LIKE clicks_legacy INCLUDING DEFAULTS,
+			PRIMARY KEY (id, clicked_at),
+			FOREIGN KEY (url_id) REFERENCES urls(id) ON DELETE CASCADE
+		) PARTITION BY RANGE (clicked_at)`,
+		`ALTER SEQUENCE clicks_id_seq OWNED BY clicks.id`,
+		`CREATE INDEX clicks_url_idx ON clicks (url_id, clicked_at)`,
+		`CREATE INDEX clicks_click_id_idx ON clicks (click_id) WHERE click_id IS NOT NULL`,
+	} {
+		if _, err := tx.ExecContext(ctx, query); err != nil {
+			return false, err
+		}
+	}
+
+	var boundary time.Time
+	query := `SELECT date_trunc('month', GREATEST(MAX(clicked_at), NOW()::TIMESTAMP)) + INTERVAL '1 month' FROM clicks_legacy`
+	if err := tx.QueryRowContext(ctx, query).Scan(&boundary); err != nil {
+		return false, err
+	}
+	if _, err := tx.ExecContext(ctx, `ALTER TABLE clicks ATTACH PARTITION clicks_legacy FOR VALUES FROM (MINVALUE) TO ('`+boundary.Format(time.DateOnly)+`')`); err != nil {
+		return false, err
+	}
+	if err := createClickPartition(ctx, tx, boundary); err != nil {
+		return false, err
+	}
+	return true, tx.Commit()
+}
+
+// ClickPartitions lists the partitions attached to clicks, oldest first
+func (db *Database) ClickPartitions(ctx context.Context) ([]ClickPartition, error) {
+	ctx, cancel := db.queryContext(ctx)
+	defer cancel()
+
+	query := `SELECT c.relname FROM pg_inherits i JOIN pg_class c ON c.oid = i.inhrelid
+			  WHERE i.inhparent = 'clicks'::regclass`
+	rows, err := db.conn.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var partitions []ClickPartition
+	legacy := false
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		if name == legacyClickPartition {
+			legacy = true
+			continue
+		}
+		month, err := time.Parse(clickPartitionLayout, strings.TrimPrefix(name, clickPartitionPrefix))
+		if err != nil {
+			return nil, fmt.Errorf("unexpected partition %s of clicks", name)
+		}
+		partitions = append(partitions, ClickPartition{Name: name, Start: month, End: month.AddDate(0, 1, 0)})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	sort.Slice(partitions, func(i, j int) bool { return partitions[i].Start.Before(partitions[j].Start) })
+	// The legacy partition ends where the first monthly one starts
+	if legacy && len(partitions) > 0 {
+		partitions = append([]ClickPartition{{Name: legacyClickPartition, End: partitions[0].Start}}, partitions...)
+	}
+	return partitions, nil
+}
+
+// CreateClickPartition adds the partition for the month starting at month,
+// doing nothing when it exists
+func (db *Database) CreateClickPartition(ctx context.Context, month time.Time) error {
+	ctx, cancel := db.queryContext(ctx)
+	defer cancel()
+
+	tx, err := db.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := createClickPartition(ctx, tx, month); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func createClickPartition(ctx context.Context, tx *sql.Tx, month time.Time) error {
+	query := fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s PARTITION OF clicks FOR VALUES FROM ('%s') TO ('%s')`,
+		pq.QuoteIdentifier(clickPartitionName(month)), month.Format(time.DateOnly), month.AddDate(0, 1, 0).Format(time.DateOnly))
+	_, err := tx.ExecContext(ctx, query)
+	return err
+}
+
+// DetachClickPartition folds a partition's clicks into the daily rollups and
+// detaches it in one transaction, so stats keep their totals throughout. The
+// detached table is kept until DropClickPartition, so its raw clicks can be
+// archived. It runs without the query timeout, since a partition holds a
+// month of clicks.
+func (db *Database) DetachClickPartition(ctx context.Context, name string) error {
+	tx, err := db.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	table := pq.QuoteIdentifier(name)
+	query := `INSERT INTO click_rollups (url_id, day, device, browser, os, country, clicks)
+			  SELECT url_id, clicked_at::DATE, device, browser, os, country, COUNT(*) FROM ` + table + `
+			  GROUP BY url_id, clicked_at::DATE, device, browser, os, country
+			  ON CONFLICT (url_id, day, device, browser, os, country)
+			  DO UPDATE SET clicks = click_rollups.clicks + EXCLUDED.clicks`
+	if _, err := tx.ExecContext(ctx, query); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `ALTER TABLE clicks DETACH PARTITION `+table); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// DetachedClickPartitions lists the partitions detached from clicks that
+// have not been dropped yet
+func (db *Database) DetachedClickPartitions(ctx context.Context) ([]string, error) {
+	ctx, cancel := db.queryContext(ctx)
+	defer cancel()
+
+	query := `SELECT relname FROM pg_class
+			  WHERE relkind = 'r' AND NOT relispartition AND relnamespace = current_schema()::regnamespace
+			  AND (relname = $1 OR relname ~ '^clicks_p[0-9]{4}_[0-9]{2}$')
+			  ORDER BY relname`
+	rows, err := db.conn.QueryContext(ctx, query, legacyClickPartition)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var names []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+	}
+	return names, rows.Err()
+}
+
+// EachDetachedClick calls fn with every click of a detached partition in
+// the order they were recorded. It runs without the query timeout.
+func (db *Database) EachDetachedClick(ctx context.Context, name string, fn func(ClickEvent) error) error {
+	query := `SELECT id, url_id, clicked_at, device, browser, os, country, COALESCE(click_id, '')
+			  FROM ` + pq.QuoteIdentifier(name) + ` ORDER BY clicked_at, id`
+	rows, err := db.conn.QueryContext(ctx, query)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var e ClickEvent
+		if err := rows.Scan(&e.ID, &e.URLID, &e.ClickedAt, &e.Device, &e.Browser, &e.OS, &e.Country, &e.ClickID); err != nil {
+			return err
+		}
+		if err := fn(e); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// DropClickPartition drops a detached partition
+func (db *Database) DropClickPartition(ctx context.Context, name string) error {
+	_, err := db.execCount(ctx, `DROP TABLE `+pq.QuoteIdentifier(name))
+	return err
+}
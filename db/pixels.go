@@ -0,0 +1,180 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Pixel is an organization's retargeting pixel, fired on an intermediate page
+// before the redirect of the links and campaigns it is attached to
+type Pixel struct {
+	ID    int    `json:"id"`
+	OrgID int    `json:"orgId"`
+	Name  string `json:"name"`
+	// Provider picks the script template the pixel is rendered with
+	Provider string `json:"provider"`
+	// PixelID is the provider's ID of the pixel or tag, e.g. a Meta pixel ID
+	PixelID   string    `json:"pixelId"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+const pixelColumns = `p.id, p.org_id, p.name, p.provider, p.pixel_id, p.created_at`
+
+func scanPixels(rows *sql.Rows) ([]Pixel, error) {
+	defer rows.Close()
+
+	pixels := make([]Pixel, 0)
+	for rows.Next() {
+		var p Pixel
+		if err := rows.Scan(&p.ID, &p.OrgID, &p.Name, &p.Provider, &p.PixelID, &p.CreatedAt); err != nil {
+			return nil, err
+		}
+		pixels = append(pixels, p)
+	}
+	return pixels, rows.Err()
+}
+
+// GetPixels lists the pixels of an organization of the tenant, oldest first
+func (db *Database) GetPixels(ctx context.Context, orgID int) ([]Pixel, error) {
+	ctx, cancel := db.queryContext(ctx)
+	defer cancel()
+
+	query := `SELECT ` + pixelColumns + ` FROM pixels p JOIN organizations o ON o.id = p.org_id
+			  WHERE p.org_id = $1 AND o.tenant_id = $2 ORDER BY p.id`
+	rows, err := db.conn.QueryContext(ctx, query, orgID, TenantFrom(ctx))
+	if err != nil {
+		return nil, err
+	}
+	return scanPixels(rows)
+}
+
+// GetPixelsByID returns the pixels of the tenant with the given IDs, limited
+// to those of one organization unless orgID is 0. Unknown IDs are left out.
+func (db *Database) GetPixelsByID(ctx context.Context, orgID int, ids []int64) ([]Pixel, error) {
+	ctx, cancel := db.queryContext(ctx)
+	defer cancel()
+
+	query := `SELECT ` + pixelColumns + ` FROM pixels p JOIN organizations o ON o.id = p.org_id
+			  WHERE p.id = ANY($1) AND ($2 = 0 OR p.org_id = $2) AND o.tenant_id = $3 ORDER BY p.id`
+	rows, err := db.conn.QueryContext(ctx, query, pq.Array(ids), orgID, TenantFrom(ctx))
+	if err != nil {
+		return nil, err
+	}
+	return scanPixels(rows)
+}
+
+// CreatePixel adds a pixel to an organization and fills in its ID and creation time
+func (db *Database) CreatePixel(ctx context.Context, p *Pixel) error {
+	ctx, cancel := db.queryContext(ctx)
+	defer cancel()
+
+	query := `INSERT INTO pixels (org_id, name, provider, pixel_id) VALUES ($1, $2, $3, $4) RETURNING id, created_at`
+	return db.conn.QueryRowContext(ctx, query, p.OrgID, p.Name, p.Provider, p.PixelID).Scan(&p.ID, &p.CreatedAt)
+}
+
+// DeletePixel removes a pixel of an organization and detaches it from every
+// link and campaign, returning sql.ErrNoRows if the organization has no such pixel
+func (db *Database) DeletePixel(ctx context.Context, orgID, id int) error {
+	ctx, cancel := db.queryContext(ctx)
+	defer cancel()
+
+	tx, err := db.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, `DELETE FROM pixels WHERE id = $1 AND org_id = $2`, id, orgID)
+	if err != nil {
+		return err
+	}
+	if affected, err := result.RowsAffected(); err != nil {
+		return err
+	} else if affected == 0 {
+		return sql.ErrNoRows
+	}
+	if _, err := tx.ExecContext(ctx, `UPDATE campaigns SET pixels = array_remove(pixels, $1) WHERE $1 = ANY(pixels)`, id); err != nil {
+		return err
+	}
+	rows, err := tx.QueryContext(ctx, `UPDATE urls SET pixels = array_remove(pixels, $1) WHERE $1 = ANY(pixels) AND org_id = $2
+									   RETURNING short_code, COALESCE(domain, '')`, id, orgID)
+	if err != nil {
+		return err
+	}
+	links, err := scanLinkRefs(rows)
+	if err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	for _, l := range links {
+		db.forget(WithDomain(ctx, l.domain), l.shortCode)
+	}
+	return nil
+}
+
+// SetURLPixels replaces the pixels fired before a link's redirect
+func (db *Database) SetURLPixels(ctx context.Context, shortCode string, ids []int64) error {
+	affected, err := db.execCount(ctx, `UPDATE urls SET pixels = $1, updated_at = NOW() WHERE short_code = $2 AND tenant_id = $3 AND COALESCE(domain, '') = $4 AND NOT locked`,
+		pq.Array(ids), shortCode, TenantFrom(ctx), DomainFrom(ctx))
+	if err != nil {
+		return err
+	}
+	db.changed(ctx, shortCode)
+	if affected == 0 {
+		return db.missingOrLocked(ctx, shortCode)
+	}
+	return nil
+}
+
+// SetCampaignPixels replaces the pixels fired before the redirects of every
+// link of a campaign, returning sql.ErrNoRows if the tenant has no such campaign
+func (db *Database) SetCampaignPixels(ctx context.Context, id int, ids []int64) error {
+	ctx, cancel := db.queryContext(ctx)
+	defer cancel()
+
+	affected, err := db.execCount(ctx, `UPDATE campaigns SET pixels = $2 WHERE id = $1 AND tenant_id = $3`, id, pq.Array(ids), TenantFrom(ctx))
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return sql.ErrNoRows
+	}
+
+	// The campaign's pixels are read along with each of its links
+	rows, err := db.conn.QueryContext(ctx, `SELECT short_code, COALESCE(domain, '') FROM urls WHERE campaign_id = $1`, id)
+	if err != nil {
+		return err
+	}
+	links, err := scanLinkRefs(rows)
+	if err != nil {
+		return err
+	}
+	for _, l := range links {
+		db.changed(WithDomain(ctx, l.domain), l.shortCode)
+	}
+	return nil
+}
+
+// linkRef identifies a link within its tenant
+type linkRef struct{ shortCode, domain string }
+
+// scanLinkRefs reads rows of short codes and domains
+func scanLinkRefs(rows *sql.Rows) ([]linkRef, error) {
+	defer rows.Close()
+
+	var links []linkRef
+	for rows.Next() {
+		var l linkRef
+		if err := rows.Scan(&l.shortCode, &l.domain); err != nil {
+			return nil, err
+		}
+		links = append(links, l)
+	}
+	return links, rows.Err()
+}
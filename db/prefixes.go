@@ -0,0 +1,108 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// ErrPrefixOverlaps is returned when a prefix is, starts with or begins
+// another organization's reserved prefix
+var ErrPrefixOverlaps = errors.New("prefix overlaps a reserved prefix")
+
+// ReservedPrefix keeps the short codes starting with Prefix, compared case
+// insensitively, for the links of one organization
+type ReservedPrefix struct {
+	ID        int       `json:"id"`
+	OrgID     int       `json:"orgId"`
+	Prefix    string    `json:"prefix"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// GetReservedPrefixes lists the prefixes an organization of the tenant reserved
+func (db *Database) GetReservedPrefixes(ctx context.Context, orgID int) ([]ReservedPrefix, error) {
+	ctx, cancel := db.queryContext(ctx)
+	defer cancel()
+
+	rows, err := db.conn.QueryContext(ctx, `SELECT id, org_id, prefix, created_at FROM reserved_prefixes
+											WHERE org_id = $1 AND tenant_id = $2 ORDER BY prefix`, orgID, TenantFrom(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	prefixes := make([]ReservedPrefix, 0)
+	for rows.Next() {
+		var p ReservedPrefix
+		if err := rows.Scan(&p.ID, &p.OrgID, &p.Prefix, &p.CreatedAt); err != nil {
+			return nil, err
+		}
+		prefixes = append(prefixes, p)
+	}
+	return prefixes, rows.Err()
+}
+
+// CreateReservedPrefix reserves a lowercase prefix for an organization and
+// fills in its ID and creation time. It returns ErrPrefixOverlaps when the
+// prefix is already covered by, or would cover, another organization's.
+// Overlapping prefixes of the same organization are allowed.
+func (db *Database) CreateReservedPrefix(ctx context.Context, p *ReservedPrefix) error {
+	ctx, cancel := db.queryContext(ctx)
+	defer cancel()
+
+	query := `INSERT INTO reserved_prefixes (tenant_id, org_id, prefix)
+			  SELECT $1, $2, $3 WHERE NOT EXISTS (
+				SELECT 1 FROM reserved_prefixes
+				WHERE tenant_id = $1 AND org_id <> $2 AND (starts_with($3, prefix) OR starts_with(prefix, $3))
+			  )
+			  ON CONFLICT (tenant_id, prefix) DO NOTHING
+			  RETURNING id, created_at`
+	err := db.conn.QueryRowContext(ctx, query, TenantFrom(ctx), p.OrgID, p.Prefix).Scan(&p.ID, &p.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrPrefixOverlaps
+	}
+	return err
+}
+
+// DeleteReservedPrefix releases a prefix of an organization, returning
+// sql.ErrNoRows if it has no such reservation
+func (db *Database) DeleteReservedPrefix(ctx context.Context, orgID, id int) error {
+	affected, err := db.execCount(ctx, `DELETE FROM reserved_prefixes WHERE id = $1 AND org_id = $2 AND tenant_id = $3`, id, orgID, TenantFrom(ctx))
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// PrefixOwners maps each of codes that starts with a prefix reserved in the
+// tenant to the organization reserving it. Codes outside every reservation
+// are left out.
+func (db *Database) PrefixOwners(ctx context.Context, codes []string) (map[string]int, error) {
+	ctx, cancel := db.queryContext(ctx)
+	defer cancel()
+
+	rows, err := db.conn.QueryContext(ctx, `SELECT DISTINCT ON (code) code, p.org_id FROM unnest($1::TEXT[]) code
+											JOIN reserved_prefixes p ON p.tenant_id = $2 AND starts_with(lower(code), p.prefix)
+											ORDER BY code, length(p.prefix) DESC`, pq.Array(codes), TenantFrom(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	owners := make(map[string]int)
+	for rows.Next() {
+		var code string
+		var orgID int
+		if err := rows.Scan(&code, &orgID); err != nil {
+			return nil, err
+		}
+		owners[code] = orgID
+	}
+	return owners, rows.Err()
+}
@@ -0,0 +1,104 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"time"
+	"url-shortener/events"
+)
+
+// RollupClicks folds click events recorded before cutoff into daily per-link
+// counts and deletes the events, returning the number of rollup rows written.
+// It runs without the query timeout, since a first run may move a large backlog.
+func (db *Database) RollupClicks(ctx context.Context, cutoff time.Time) (int64, error) {
+	query := `WITH moved AS (
+				DELETE FROM clicks WHERE clicked_at < $1 RETURNING url_id, clicked_at, device, browser, os, country
+			  )
+			  INSERT INTO click_rollups (url_id, day, device, browser, os, country, clicks)
+			  SELECT url_id, clicked_at::DATE, device, browser, os, country, COUNT(*) FROM moved
+			  GROUP BY url_id, clicked_at::DATE, device, browser, os, country
+			  ON CONFLICT (url_id, day, device, browser, os, country)
+			  DO UPDATE SET clicks = click_rollups.clicks + EXCLUDED.clicks`
+	result, err := db.conn.ExecContext(ctx, query, cutoff)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// PruneVisits deletes the visitor records of days before today, which are
+// only kept to tell whether a click is the visitor's first of the day
+func (db *Database) PruneVisits(ctx context.Context) (int64, error) {
+	return db.execCount(ctx, `DELETE FROM unique_visits WHERE day < CURRENT_DATE`)
+}
+
+// EraseUser deletes every link a user created on the tenant of ctx, along
+// with the links' click events, and the user's campaigns, memberships,
+// subscriptions and transfers there, and anonymizes their entries in the
+// tenant's audit log. Accounts are shared by the tenants, so the account and
+// its API usage go with the user's last links and campaigns on any tenant.
+// It returns the number of deleted links, or sql.ErrNoRows when there is
+// neither an account nor a link for userID.
+func (db *Database) EraseUser(ctx context.Context, userID string) (int64, error) {
+	ctx, cancel := db.queryContext(ctx)
+	defer cancel()
+
+	tx, err := db.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	tenant := TenantFrom(ctx)
+
+	// Clicks, versions, variant counters and rollups go with their links
+	var links int64
+	query := `WITH deleted AS (
+				DELETE FROM urls WHERE owner_id = $1 AND tenant_id = $2 RETURNING short_code, original
+			  )` + db.queueURLEvents(events.URLDeleted, "deleted") + `
+			  SELECT COUNT(*) FROM deleted`
+	if err := tx.QueryRowContext(ctx, query, userID, tenant).Scan(&links); err != nil {
+		return 0, err
+	}
+
+	for _, query := range []string{
+		`DELETE FROM campaigns WHERE owner_id = $1 AND tenant_id = $2`,
+		`DELETE FROM organization_members WHERE user_id = $1 AND org_id IN (SELECT id FROM organizations WHERE tenant_id = $2)`,
+		`DELETE FROM subscriptions WHERE user_id = $1 AND org_id IS NULL AND tenant_id = $2`,
+		`DELETE FROM transfers WHERE (requested_by = $1 OR to_user_id = $1) AND tenant_id = $2`,
+		`UPDATE transfers SET resolved_by = 'erased-user' WHERE resolved_by = $1 AND tenant_id = $2`,
+		`UPDATE audit_log SET actor = 'erased-user', remote_ip = NULL WHERE actor = $1 AND tenant_id = $2`,
+	} {
+		if _, err := tx.ExecContext(ctx, query, userID, tenant); err != nil {
+			return 0, err
+		}
+	}
+
+	// Sessions and identities go with the account
+	result, err := tx.ExecContext(ctx, `DELETE FROM users WHERE id::TEXT = $1
+		AND NOT EXISTS (SELECT 1 FROM urls WHERE owner_id = $1)
+		AND NOT EXISTS (SELECT 1 FROM campaigns WHERE owner_id = $1)`, userID)
+	if err != nil {
+		return 0, err
+	}
+	accounts, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	if accounts > 0 {
+		if _, err := tx.ExecContext(ctx, `DELETE FROM api_usage WHERE user_id = $1`, userID); err != nil {
+			return 0, err
+		}
+	}
+
+	// JWT users have links but no account; anything else is unknown
+	if accounts == 0 && links == 0 {
+		return 0, sql.ErrNoRows
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	db.purge()
+	return links, nil
+}
@@ -0,0 +1,91 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+	"url-shortener/db/dbtest"
+)
+
+// TestEraseUserTenants erases a user on one of two tenants the user has
+// links and campaigns on, leaving those on the other tenant
+func TestEraseUserTenants(t *testing.T) {
+	database := openTestDatabase(t)
+	owner := fmt.Sprintf("erase-test-%d", time.Now().UnixNano())
+	// urls and campaigns don't reference tenants, so any ID will do
+	home := WithTenant(context.Background(), DefaultTenant)
+	other := WithTenant(context.Background(), int(time.Now().Unix()%1000000)+1000000)
+
+	create := func(ctx context.Context) (string, int) {
+		_, code, err := database.CreateSequencedURL(ctx, NewURL{OriginalURL: "https://example.com/erase", OwnerID: owner})
+		if err != nil {
+			t.Fatal(err)
+		}
+		campaign, err := database.CreateCampaign(ctx, "erase", owner)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return code, campaign.ID
+	}
+	homeCode, homeCampaign := create(home)
+	otherCode, otherCampaign := create(other)
+	t.Cleanup(func() { database.EraseUser(home, owner) })
+
+	links, err := database.EraseUser(other, owner)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if links != 1 {
+		t.Errorf("want 1 link erased, got %d", links)
+	}
+
+	if _, err := database.GetURLByShortCode(other, otherCode); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("link on the erasing tenant: want sql.ErrNoRows, got %v", err)
+	}
+	if _, err := database.GetCampaign(other, otherCampaign); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("campaign on the erasing tenant: want sql.ErrNoRows, got %v", err)
+	}
+	if _, err := database.GetURLByShortCode(home, homeCode); err != nil {
+		t.Errorf("link on the other tenant was erased: %v", err)
+	}
+	if _, err := database.GetCampaign(home, homeCampaign); err != nil {
+		t.Errorf("campaign on the other tenant was erased: %v", err)
+	}
+}
+
+// TestEraseUserScopesToTenant checks every statement that deletes or changes
+// tenant data is limited to the tenant of ctx
+func TestEraseUserScopesToTenant(t *testing.T) {
+	const tenant = 7
+	var statements []string
+	database := stubDatabase(t, func(query string, args []driver.NamedValue) dbtest.Result {
+		statements = append(statements, query)
+		// Account-wide rows are keyed on the user alone
+		if strings.Contains(query, "FROM users") || strings.Contains(query, "FROM api_usage") {
+			return dbtest.Result{RowsAffected: 1}
+		}
+		if !strings.Contains(query, "tenant_id = $2") || len(args) != 2 || args[1].Value != tenant {
+			t.Errorf("statement not limited to tenant %d (args %v): %s", tenant, args, query)
+		}
+		if strings.Contains(query, "FROM urls WHERE owner_id") {
+			return dbtest.Result{Columns: []string{"count"}, Rows: [][]driver.Value{{int64(2)}}}
+		}
+		return dbtest.Result{}
+	})
+
+	links, err := database.EraseUser(WithTenant(context.Background(), tenant), "user-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if links != 2 {
+		t.Errorf("want 2 links erased, got %d", links)
+	}
+	if len(statements) == 0 {
+		t.Fatal("no statements were run")
+	}
+}
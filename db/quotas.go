@@ -0,0 +1,112 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// GetUserPlan returns the plan assigned to a user, or "" for the default plan.
+// Users without an account row, such as JWT subjects, are on the default plan.
+func (db *Database) GetUserPlan(ctx context.Context, userID string) (string, error) {
+	ctx, cancel := db.queryContext(ctx)
+	defer cancel()
+
+	var plan string
+	err := db.conn.QueryRowContext(ctx, `SELECT COALESCE(plan, '') FROM users WHERE id::TEXT = $1`, userID).Scan(&plan)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	}
+	return plan, err
+}
+
+// GetOrgPlan returns the plan assigned to an organization, or "" for the default plan
+func (db *Database) GetOrgPlan(ctx context.Context, orgID int) (string, error) {
+	ctx, cancel := db.queryContext(ctx)
+	defer cancel()
+
+	var plan string
+	err := db.conn.QueryRowContext(ctx, `SELECT COALESCE(plan, '') FROM organizations WHERE id = $1`, orgID).Scan(&plan)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	}
+	return plan, err
+}
+
+// SetUserPlan assigns a plan to a user; "" puts them back on the default
+// plan. It returns sql.ErrNoRows if there is no user with that ID.
+func (db *Database) SetUserPlan(ctx context.Context, userID, plan string) error {
+	affected, err := db.execCount(ctx, `UPDATE users SET plan = NULLIF($2, '') WHERE id::TEXT = $1`, userID, plan)
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// SetOrgPlan assigns a plan to an organization of the tenant; "" puts it back
+// on the default plan. It returns sql.ErrNoRows if there is no such organization.
+func (db *Database) SetOrgPlan(ctx context.Context, orgID int, plan string) error {
+	affected, err := db.execCount(ctx, `UPDATE organizations SET plan = NULLIF($2, '') WHERE id = $1 AND tenant_id = $3`,
+		orgID, plan, TenantFrom(ctx))
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// CountLinks returns how many links an organization has, or a user outside
+// any organization when orgID is 0: those not archived, and all created since
+// since. It reads from the primary, so a link just created counts at once.
+func (db *Database) CountLinks(ctx context.Context, userID string, orgID int, since time.Time) (total, recent int, err error) {
+	ctx, cancel := db.queryContext(ctx)
+	defer cancel()
+
+	query := `SELECT COUNT(*) FILTER (WHERE archived_at IS NULL), COUNT(*) FILTER (WHERE created_at >= $3) FROM urls
+			  WHERE CASE WHEN $2 = 0 THEN owner_id = $1 AND org_id IS NULL ELSE org_id = $2 END`
+	err = db.conn.QueryRowContext(ctx, query, userID, orgID, since).Scan(&total, &recent)
+	return total, recent, err
+}
+
+// GetAPICalls returns the API calls recorded for a user in the month starting at month
+func (db *Database) GetAPICalls(ctx context.Context, userID string, month time.Time) (int64, error) {
+	ctx, cancel := db.queryContext(ctx)
+	defer cancel()
+
+	var calls int64
+	err := db.conn.QueryRowContext(ctx, `SELECT COALESCE(SUM(calls), 0) FROM api_usage WHERE user_id = $1 AND month = $2`, userID, month).
+		Scan(&calls)
+	return calls, err
+}
+
+// AddAPICalls adds calls by user to the month starting at month and returns
+// each user's total for the month, including calls recorded by other instances
+func (db *Database) AddAPICalls(ctx context.Context, month time.Time, calls map[string]int64) (map[string]int64, error) {
+	ctx, cancel := db.queryContext(ctx)
+	defer cancel()
+
+	tx, err := db.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	query := `INSERT INTO api_usage (user_id, month, calls) VALUES ($1, $2, $3)
+			  ON CONFLICT (user_id, month) DO UPDATE SET calls = api_usage.calls + EXCLUDED.calls
+			  RETURNING calls`
+	totals := make(map[string]int64, len(calls))
+	for userID, n := range calls {
+		var total int64
+		if err := tx.QueryRowContext(ctx, query, userID, month, n).Scan(&total); err != nil {
+			return nil, err
+		}
+		totals[userID] = total
+	}
+	return totals, tx.Commit()
+}
@@ -0,0 +1,89 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"sync/atomic"
+	"time"
+	"url-shortener/config"
+)
+
+// replicaCooldown is how long a replica is skipped after a failed read
+const replicaCooldown = 30 * time.Second
+
+type replica struct {
+	conn      *sql.DB
+	downUntil atomic.Int64 // unix nanos
+}
+
+func (r *replica) available(now time.Time) bool {
+	return now.UnixNano() >= r.downUntil.Load()
+}
+
+func (r *replica) markDown() {
+	r.downUntil.Store(time.Now().Add(replicaCooldown).UnixNano())
+}
+
+// openReplicas connects to each configured read replica. Replicas that cannot be
+// reached at startup are still kept, as reads fall back to the primary anyway.
+func openReplicas(cfg *config.Config) ([]*replica, error) {
+	replicas := make([]*replica, 0, len(cfg.Database.ReplicaDSNs))
+	for i, dsn := range cfg.Database.ReplicaDSNs {
+		conn, err := openDB(dsn, cfg.Debug.ExplainThreshold)
+		if err != nil {
+			for _, r := range replicas {
+				r.conn.Close()
+			}
+			return nil, err
+		}
+
+		conn.SetMaxOpenConns(cfg.Database.MaxOpenConns)
+		conn.SetMaxIdleConns(cfg.Database.MaxIdleConns)
+		conn.SetConnMaxLifetime(cfg.Database.ConnMaxLifetime)
+
+		r := &replica{conn: conn}
+		if err := conn.Ping(); err != nil {
+			log.Printf("Warning: read replica %d is not reachable: %v", i, err)
+			r.markDown()
+		}
+		replicas = append(replicas, r)
+	}
+	return replicas, nil
+}
+
+// nextReplica picks the next available replica round-robin, or nil if none is usable
+func (db *Database) nextReplica() *replica {
+	now := time.Now()
+	for range db.replicas {
+		r := db.replicas[db.replicaCursor.Add(1)%uint64(len(db.replicas))]
+		if r.available(now) {
+			return r
+		}
+	}
+	return nil
+}
+
+// read runs a read-only query on a replica when one is configured, failing back
+// to the primary when the replica errors or does not have the row yet (replication lag)
+func (db *Database) read(ctx context.Context, fn func(ctx context.Context, conn *sql.DB) error) error {
+	if r := db.nextReplica(); r != nil {
+		rctx, cancel := db.queryContext(ctx)
+		err := fn(rctx, r.conn)
+		cancel()
+
+		if err == nil || ctx.Err() != nil {
+			return err
+		}
+		if !errors.Is(err, sql.ErrNoRows) {
+			log.Printf("Read replica query failed, falling back to primary: %v", err)
+			r.markDown()
+		}
+	}
+
+	ctx, cancel := db.queryContext(ctx)
+	defer cancel()
+
+	return fn(ctx, db.conn)
+}
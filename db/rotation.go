@@ -0,0 +1,118 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+)
+
+// Rotation modes
+const (
+	RotationRoundRobin = "round-robin"
+	RotationRandom     = "random"
+)
+
+// Rotation spreads a link's clicks over several destinations, taking them in
+// turn (round-robin) or picking one at random on each click
+type Rotation struct {
+	Mode         string   `json:"mode"`
+	Destinations []string `json:"destinations"`
+}
+
+// RotationStats is a rotated destination with the clicks it received
+type RotationStats struct {
+	URL    string `json:"url"`
+	Clicks int64  `json:"clicks"`
+}
+
+// SetRotation replaces a link's rotated destinations; nil ends the rotation.
+// Click counts of destinations that stay in the rotation are preserved.
+func (db *Database) SetRotation(ctx context.Context, shortCode string, rotation *Rotation) error {
+	ctx, cancel := db.queryContext(ctx)
+	defer cancel()
+
+	var encoded any
+	if rotation != nil {
+		b, err := json.Marshal(rotation)
+		if err != nil {
+			return err
+		}
+		encoded = b
+	}
+
+	query := `UPDATE urls SET rotation = $1, updated_at = NOW() WHERE short_code = $2 AND tenant_id = $3 AND COALESCE(domain, '') = $4 AND NOT locked`
+	result, err := db.conn.ExecContext(ctx, query, encoded, shortCode, TenantFrom(ctx), DomainFrom(ctx))
+	if err != nil {
+		return err
+	}
+	db.changed(ctx, shortCode)
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return db.missingOrLocked(ctx, shortCode)
+	}
+
+	return nil
+}
+
+// NextRotationPosition advances a link's round-robin rotation and returns the
+// turn taken, counting from 0, so concurrent clicks each get their own turn
+func (db *Database) NextRotationPosition(ctx context.Context, shortCode string) (int64, error) {
+	ctx, cancel := db.queryContext(ctx)
+	defer cancel()
+
+	var position int64
+	query := `UPDATE urls SET rotation_position = rotation_position + 1 WHERE short_code = $1 AND tenant_id = $2 AND COALESCE(domain, '') = $3 RETURNING rotation_position - 1`
+	err := db.conn.QueryRowContext(ctx, query, shortCode, TenantFrom(ctx), DomainFrom(ctx)).Scan(&position)
+	return position, err
+}
+
+// RecordRotationClick counts a human redirect to one of a link's rotated destinations
+func (db *Database) RecordRotationClick(ctx context.Context, shortCode, destination string) error {
+	ctx, cancel := db.queryContext(ctx)
+	defer cancel()
+
+	query := `INSERT INTO rotation_stats (url_id, destination, clicks)
+			  SELECT id, $2, 1 FROM urls WHERE short_code = $1 AND tenant_id = $3 AND COALESCE(domain, '') = $4
+			  ON CONFLICT (url_id, destination) DO UPDATE SET clicks = rotation_stats.clicks + 1`
+	_, err := db.conn.ExecContext(ctx, query, shortCode, destination, TenantFrom(ctx), DomainFrom(ctx))
+	return err
+}
+
+// GetRotationStats returns the click counts of a link's current rotated
+// destinations, in the order they are rotated through
+func (db *Database) GetRotationStats(ctx context.Context, url *URL) ([]RotationStats, error) {
+	query := `SELECT destination, clicks FROM rotation_stats WHERE url_id = $1`
+
+	counts := make(map[string]int64)
+	err := db.read(ctx, func(ctx context.Context, conn *sql.DB) error {
+		rows, err := conn.QueryContext(ctx, query, url.ID)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var destination string
+			var clicks int64
+			if err := rows.Scan(&destination, &clicks); err != nil {
+				return err
+			}
+			counts[destination] = clicks
+		}
+		return rows.Err()
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	stats := make([]RotationStats, 0, len(url.Rotation.Destinations))
+	for _, destination := range url.Rotation.Destinations {
+		stats = append(stats, RotationStats{URL: destination, Clicks: counts[destination]})
+	}
+	return stats, nil
+}
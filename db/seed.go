@@ -0,0 +1,45 @@
+package db
+
+import (
+	"context"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// PastClick is a click recorded at a given time rather than now
+type PastClick struct {
+	Click
+	At time.Time
+}
+
+// InsertClicks records past click events on a link and adds them to its
+// access count in one transaction, for seeding development databases. The
+// hourly rollups pick them up like any other click.
+func (db *Database) InsertClicks(ctx context.Context, urlID int64, clicks []PastClick) error {
+	ctx, cancel := db.queryContext(ctx)
+	defer cancel()
+
+	n := len(clicks)
+	at, devices, browsers, systems, countries := make([]string, n), make([]string, n), make([]string, n), make([]string, n), make([]string, n)
+	for i, c := range clicks {
+		at[i] = c.At.UTC().Format("2006-01-02 15:04:05.999999")
+		devices[i], browsers[i], systems[i], countries[i] = c.Device, c.Browser, c.OS, c.Country
+	}
+
+	tx, err := db.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `INSERT INTO clicks (url_id, clicked_at, device, browser, os, country)
+			  SELECT $1, * FROM unnest($2::TIMESTAMP[], $3::TEXT[], $4::TEXT[], $5::TEXT[], $6::TEXT[])`
+	if _, err := tx.ExecContext(ctx, query, urlID, pq.Array(at), pq.Array(devices), pq.Array(browsers), pq.Array(systems), pq.Array(countries)); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `UPDATE urls SET access_count = access_count + $2 WHERE id = $1`, urlID, n); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
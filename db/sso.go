@@ -0,0 +1,286 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"strconv"
+	"time"
+)
+
+// OrgSSO is an organization's SAML identity provider, through which its
+// members sign in and, with SCIM enabled, are provisioned
+type OrgSSO struct {
+	OrgID       int    `json:"orgId"`
+	IdPEntityID string `json:"idpEntityId"`
+	SSOURL      string `json:"ssoUrl"`
+	// Certificate is the PEM certificate the identity provider signs with
+	Certificate string `json:"certificate"`
+	// DefaultRole is given to members added by their first sign-in or by SCIM
+	DefaultRole string    `json:"defaultRole"`
+	SCIMEnabled bool      `json:"scimEnabled"`
+	UpdatedAt   time.Time `json:"updatedAt"`
+}
+
+// SSOProvider is the identity provider name under which the members an
+// organization's SAML identity provider signs in are linked to their users
+func SSOProvider(orgID int) string {
+	return "saml:" + strconv.Itoa(orgID)
+}
+
+const orgSSOColumns = `s.org_id, s.idp_entity_id, s.sso_url, s.certificate, s.default_role, s.scim_token_hash IS NOT NULL, s.updated_at`
+
+// GetOrgSSO returns the SAML setup of an organization of the tenant, or
+// sql.ErrNoRows if it has none
+func (db *Database) GetOrgSSO(ctx context.Context, orgID int) (*OrgSSO, error) {
+	ctx, cancel := db.queryContext(ctx)
+	defer cancel()
+
+	query := `SELECT ` + orgSSOColumns + ` FROM org_sso s JOIN organizations o ON o.id = s.org_id
+			  WHERE s.org_id = $1 AND o.tenant_id = $2`
+	var s OrgSSO
+	err := db.conn.QueryRowContext(ctx, query, orgID, TenantFrom(ctx)).
+		Scan(&s.OrgID, &s.IdPEntityID, &s.SSOURL, &s.Certificate, &s.DefaultRole, &s.SCIMEnabled, &s.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// SetOrgSSO stores the SAML setup of an organization, keeping its SCIM
+// token, and fills in its update time
+func (db *Database) SetOrgSSO(ctx context.Context, s *OrgSSO) error {
+	ctx, cancel := db.queryContext(ctx)
+	defer cancel()
+
+	query := `INSERT INTO org_sso (org_id, idp_entity_id, sso_url, certificate, default_role) VALUES ($1, $2, $3, $4, $5)
+			  ON CONFLICT (org_id) DO UPDATE SET idp_entity_id = EXCLUDED.idp_entity_id, sso_url = EXCLUDED.sso_url,
+			  certificate = EXCLUDED.certificate, default_role = EXCLUDED.default_role, updated_at = NOW()
+			  RETURNING scim_token_hash IS NOT NULL, updated_at`
+	return db.conn.QueryRowContext(ctx, query, s.OrgID, s.IdPEntityID, s.SSOURL, s.Certificate, s.DefaultRole).
+		Scan(&s.SCIMEnabled, &s.UpdatedAt)
+}
+
+// DeleteOrgSSO removes the SAML setup of an organization, and with it SCIM
+// access, returning sql.ErrNoRows if it has none
+func (db *Database) DeleteOrgSSO(ctx context.Context, orgID int) error {
+	affected, err := db.execCount(ctx, `DELETE FROM org_sso WHERE org_id = $1`, orgID)
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// SetSCIMToken replaces the hash of the token an organization's identity
+// provider authenticates to SCIM with, or disables SCIM when empty. It
+// returns sql.ErrNoRows if the organization has no SAML setup.
+func (db *Database) SetSCIMToken(ctx context.Context, orgID int, tokenHash string) error {
+	affected, err := db.execCount(ctx, `UPDATE org_sso SET scim_token_hash = NULLIF($2, ''), updated_at = NOW() WHERE org_id = $1`,
+		orgID, tokenHash)
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// GetSCIMOrg returns the organization of the tenant whose SCIM token has
+// tokenHash, or sql.ErrNoRows if there is none
+func (db *Database) GetSCIMOrg(ctx context.Context, tokenHash string) (*OrgSSO, error) {
+	ctx, cancel := db.queryContext(ctx)
+	defer cancel()
+
+	query := `SELECT ` + orgSSOColumns + ` FROM org_sso s JOIN organizations o ON o.id = s.org_id
+			  WHERE s.scim_token_hash = $1 AND o.tenant_id = $2`
+	var s OrgSSO
+	err := db.conn.QueryRowContext(ctx, query, tokenHash, TenantFrom(ctx)).
+		Scan(&s.OrgID, &s.IdPEntityID, &s.SSOURL, &s.Certificate, &s.DefaultRole, &s.SCIMEnabled, &s.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// ProvisionedUser is a user an organization's identity provider signs in,
+// as seen through SCIM. Active users are members of the organization.
+type ProvisionedUser struct {
+	User
+	UserName string
+	Active   bool
+}
+
+// ProvisionMember returns the user the organization's identity provider
+// knows as userName, creating the user if needed and refreshing email and
+// name, and makes them a member with role unless they already are one
+func (db *Database) ProvisionMember(ctx context.Context, orgID int, userName, email, name, role string) (*ProvisionedUser, error) {
+	user, err := db.UpsertOAuthUser(ctx, SSOProvider(orgID), userName, email, name)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := db.queryContext(ctx)
+	defer cancel()
+	query := `INSERT INTO organization_members (org_id, user_id, role) VALUES ($1, $2, $3) ON CONFLICT (org_id, user_id) DO NOTHING`
+	if _, err := db.conn.ExecContext(ctx, query, orgID, strconv.Itoa(user.ID), role); err != nil {
+		return nil, err
+	}
+	return &ProvisionedUser{User: *user, UserName: userName, Active: true}, nil
+}
+
+const provisionedColumns = userColumns + `, i.subject, EXISTS (
+	SELECT 1 FROM organization_members m WHERE m.org_id = $1 AND m.user_id = users.id::TEXT
+)`
+
+func scanProvisionedUser(row rowScanner) (*ProvisionedUser, error) {
+	var u ProvisionedUser
+	if err := row.Scan(&u.ID, &u.Email, &u.Name, &u.Role, &u.CreatedAt, &u.TwoFactor, &u.UserName, &u.Active); err != nil {
+		return nil, err
+	}
+	return &u, nil
+}
+
+// GetProvisionedUsers lists up to limit of the users an organization's
+// identity provider signs in, after skipping offset, with their total;
+// userName narrows them to the one known by that name
+func (db *Database) GetProvisionedUsers(ctx context.Context, orgID int, userName string, offset, limit int) ([]ProvisionedUser, int, error) {
+	ctx, cancel := db.queryContext(ctx)
+	defer cancel()
+
+	var total int
+	err := db.conn.QueryRowContext(ctx, `SELECT COUNT(*) FROM user_identities WHERE provider = $1 AND ($2 = '' OR subject = $2)`,
+		SSOProvider(orgID), userName).Scan(&total)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	query := `SELECT ` + provisionedColumns + ` FROM user_identities i JOIN users ON users.id = i.user_id
+			  WHERE i.provider = $2 AND ($3 = '' OR i.subject = $3)
+			  ORDER BY users.id OFFSET $4 LIMIT $5`
+	rows, err := db.conn.QueryContext(ctx, query, orgID, SSOProvider(orgID), userName, offset, limit)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	users := make([]ProvisionedUser, 0)
+	for rows.Next() {
+		u, err := scanProvisionedUser(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		users = append(users, *u)
+	}
+	return users, total, rows.Err()
+}
+
+// GetProvisionedUser returns a user the organization's identity provider
+// signs in, or sql.ErrNoRows if it does not know the user
+func (db *Database) GetProvisionedUser(ctx context.Context, orgID, userID int) (*ProvisionedUser, error) {
+	ctx, cancel := db.queryContext(ctx)
+	defer cancel()
+
+	query := `SELECT ` + provisionedColumns + ` FROM user_identities i JOIN users ON users.id = i.user_id
+			  WHERE i.provider = $2 AND users.id = $3`
+	return scanProvisionedUser(db.conn.QueryRowContext(ctx, query, orgID, SSOProvider(orgID), userID))
+}
+
+// UpdateProvisionedUser changes the email and name of a user the
+// organization's identity provider signs in; empty values are kept
+func (db *Database) UpdateProvisionedUser(ctx context.Context, userID int, email, name string) error {
+	_, err := db.execCount(ctx, `UPDATE users SET email = COALESCE(NULLIF($2, ''), email), name = COALESCE(NULLIF($3, ''), name) WHERE id = $1`,
+		userID, email, name)
+	return err
+}
+
+// Offboarding is what leaving an organization took from a user
+type Offboarding struct {
+	// Links is how many of the user's links were handed to the organization
+	Links int `json:"links"`
+	// APIKeys are the IDs of the API keys the user created, now deleted
+	APIKeys []int `json:"apiKeys"`
+}
+
+// OffboardMember removes a user from an organization and ends their sessions.
+// Their API keys are deleted, and their personal links and links in the
+// organization are handed to the organization under tokenHash as the new
+// management token hash. It returns ErrLastOwner rather than remove the last
+// owner, and sql.ErrNoRows if the user is not a member.
+func (db *Database) OffboardMember(ctx context.Context, orgID, userID int, tokenHash string) (*Offboarding, error) {
+	ctx, cancel := db.queryContext(ctx)
+	defer cancel()
+
+	tx, err := db.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	member := strconv.Itoa(userID)
+	res, err := tx.ExecContext(ctx, `DELETE FROM organization_members WHERE org_id = $1 AND user_id = $2`, orgID, member)
+	if err != nil {
+		return nil, err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return nil, sql.ErrNoRows
+	}
+	if err := ensureOwner(ctx, tx, orgID); err != nil {
+		return nil, err
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM sessions WHERE user_id = $1`, userID); err != nil {
+		return nil, err
+	}
+
+	var offboarding Offboarding
+	rows, err := tx.QueryContext(ctx, `DELETE FROM api_keys WHERE created_by = $1 RETURNING id`, member)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		offboarding.APIKeys = append(offboarding.APIKeys, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	type link struct{ shortCode, domain string }
+	var moved []link
+	query := `UPDATE urls SET org_id = $2, owner_id = NULL, owner_token_hash = $3, updated_at = NOW()
+			  WHERE owner_id = $1 AND (org_id IS NULL OR org_id = $2) AND tenant_id = $4
+			  RETURNING short_code, COALESCE(domain, '')`
+	rows, err = tx.QueryContext(ctx, query, member, orgID, tokenHash, TenantFrom(ctx))
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var l link
+		if err := rows.Scan(&l.shortCode, &l.domain); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		moved = append(moved, l)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	for _, l := range moved {
+		db.forget(WithDomain(ctx, l.domain), l.shortCode)
+	}
+	offboarding.Links = len(moved)
+	return &offboarding, nil
+}
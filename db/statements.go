@@ -0,0 +1,88 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"url-shortener/events"
+)
+
+// Hot queries, kept in one place so the text prepared at startup is the
+// text looked up when they run
+const (
+	urlByShortCodeQuery = `SELECT ` + urlColumns + ` FROM urls WHERE short_code = $1 AND tenant_id = $2 AND COALESCE(domain, '') = $3`
+	urlByIDQuery        = `SELECT ` + urlColumns + ` FROM urls WHERE id = $1 AND tenant_id = $2`
+	// Count the click and record its details in one round trip, returning the
+	// new count so each value is seen by exactly one redirect
+	incrementClickQuery = `WITH url AS (
+				UPDATE urls SET access_count = access_count + 1 WHERE short_code = $1 AND tenant_id = $6 AND COALESCE(domain, '') = $7 RETURNING id, access_count
+			  )
+			  INSERT INTO clicks (url_id, device, browser, os, country, click_id)
+			  SELECT id, $2, $3, $4, $5, NULLIF($8, '') FROM url
+			  RETURNING (SELECT access_count FROM url)`
+)
+
+// createURLQuery inserts a link with its first version, and queues its
+// url.created event while the outbox is enabled
+func (db *Database) createURLQuery() string {
+	return `WITH created AS (
+				INSERT INTO urls (id, original, short_code, domain, tags, owner_token_hash, owner_id, org_id, targets, forward_query, forward_path, utm_campaign, public_stats, open_graph, tenant_id, frame, track_conversions, notes, metadata, created_at, updated_at, access_count)
+				VALUES ($1, $2, $3, NULLIF($4, ''), $5, NULLIF($6, ''), NULLIF($7, ''), NULLIF($8, 0), $9, $10, $11, NULLIF($12, ''), $13, $14, $15, $16, $17, $18, COALESCE($19::JSONB, '{}'), NOW(), NOW(), 0)
+				ON CONFLICT (tenant_id, COALESCE(domain, ''), short_code) DO NOTHING
+				RETURNING id, original, short_code, owner_id, created_at
+			  )` + db.queueURLEvents(events.URLCreated, "created") + `, versions AS (
+				INSERT INTO url_versions (url_id, original, created_by, created_at)
+				SELECT id, original, owner_id, created_at FROM created
+			  )
+			  SELECT COUNT(*) FROM created`
+}
+
+// PrepareStatements prepares the redirect lookups on the primary and every
+// replica, and click counting and link creation on the primary, so the
+// database parses and plans them once per connection instead of on every
+// call. A replica that can't prepare them, e.g. because it is down, runs
+// them unprepared. It must be called before serving requests, after
+// EnableOutbox.
+func (db *Database) PrepareStatements(ctx context.Context) error {
+	lookups := []string{urlByShortCodeQuery, urlByIDQuery}
+
+	db.prepared = map[*sql.DB]map[string]*sql.Stmt{}
+	primary, err := prepareAll(ctx, db.conn, append(lookups, incrementClickQuery, db.createURLQuery()))
+	if err != nil {
+		return err
+	}
+	db.prepared[db.conn] = primary
+
+	for i, r := range db.replicas {
+		statements, err := prepareAll(ctx, r.conn, lookups)
+		if err != nil {
+			log.Printf("Warning: failed to prepare statements on read replica %d: %v", i, err)
+			continue
+		}
+		db.prepared[r.conn] = statements
+	}
+	return nil
+}
+
+func prepareAll(ctx context.Context, conn *sql.DB, queries []string) (map[string]*sql.Stmt, error) {
+	statements := make(map[string]*sql.Stmt, len(queries))
+	for _, query := range queries {
+		stmt, err := conn.PrepareContext(ctx, query)
+		if err != nil {
+			for _, s := range statements {
+				s.Close()
+			}
+			return nil, err
+		}
+		statements[query] = stmt
+	}
+	return statements, nil
+}
+
+// queryRow runs query on conn, through its prepared statement when it has one
+func (db *Database) queryRow(ctx context.Context, conn *sql.DB, query string, args ...any) *sql.Row {
+	if stmt := db.prepared[conn][query]; stmt != nil {
+		return stmt.QueryRowContext(ctx, args...)
+	}
+	return conn.QueryRowContext(ctx, query, args...)
+}
@@ -0,0 +1,50 @@
+package db
+
+import (
+	"context"
+	"os"
+	"testing"
+	"url-shortener/config"
+)
+
+// openTestDatabase connects to the PostgreSQL database named by the
+// DATABASE_* variables, skipping tb when DATABASE_HOST is not set
+func openTestDatabase(tb testing.TB) *Database {
+	if os.Getenv("DATABASE_HOST") == "" {
+		tb.Skip("DATABASE_HOST is not set")
+	}
+	database, err := InitDB(config.GetDefaultConfig())
+	if err != nil {
+		tb.Fatal(err)
+	}
+	tb.Cleanup(func() { database.Close() })
+	return database
+}
+
+// BenchmarkGetURLByShortCode compares the redirect lookup run as a prepared
+// statement with the same query parsed and planned on every call
+func BenchmarkGetURLByShortCode(b *testing.B) {
+	database := openTestDatabase(b)
+	ctx := context.Background()
+
+	_, shortCode, err := database.CreateSequencedURL(ctx, NewURL{OriginalURL: "https://example.com/benchmark"})
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.Cleanup(func() { database.DeleteURL(ctx, shortCode) })
+
+	lookup := func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if _, err := database.GetURLByShortCode(ctx, shortCode); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+
+	b.Run("unprepared", lookup)
+	if err := database.PrepareStatements(ctx); err != nil {
+		b.Fatal(err)
+	}
+	b.Run("prepared", lookup)
+}
@@ -0,0 +1,91 @@
+package db
+
+import (
+	"context"
+)
+
+// ClickCounts are the totals a link's stats start from
+type ClickCounts struct {
+	Clicks           int64 `json:"clicks"`
+	UniqueClicks     int64 `json:"uniqueClicks"`
+	BotClicks        int64 `json:"botClicks"`
+	SuspiciousClicks int64 `json:"suspiciousClicks"`
+}
+
+// clickTables hold a link's click events and the counts derived from them
+var clickTables = []string{
+	"clicks",
+	"click_rollups",
+	"click_hourly_rollups",
+	"click_import_records",
+	"click_anomalies",
+	"unique_visits",
+	"variant_stats",
+	"rotation_stats",
+}
+
+// ResetStats zeroes a link's click counts and deletes everything behind its
+// stats: click events, rollups, imported clicks, anomalies, unique visitors
+// and A/B test and rotation counts. It returns the counts as they were.
+func (db *Database) ResetStats(ctx context.Context, shortCode string) (*ClickCounts, error) {
+	ctx, cancel := db.queryContext(ctx)
+	defer cancel()
+
+	tx, err := db.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var id int
+	var before ClickCounts
+	query := `SELECT id, access_count, unique_clicks, bot_clicks, suspicious_clicks FROM urls WHERE short_code = $1 AND tenant_id = $2 AND COALESCE(domain, '') = $3 FOR UPDATE`
+	if err := tx.QueryRowContext(ctx, query, shortCode, TenantFrom(ctx), DomainFrom(ctx)).Scan(&id, &before.Clicks, &before.UniqueClicks, &before.BotClicks, &before.SuspiciousClicks); err != nil {
+		return nil, err
+	}
+
+	// Wait out a running AggregateClicks, so it cannot add hourly counts of
+	// events deleted here
+	if _, err := tx.ExecContext(ctx, `SELECT 1 FROM aggregator_state WHERE name = $1 FOR UPDATE`, hourlyAggregator); err != nil {
+		return nil, err
+	}
+	for _, table := range clickTables {
+		if _, err := tx.ExecContext(ctx, `DELETE FROM `+table+` WHERE url_id = $1`, id); err != nil {
+			return nil, err
+		}
+	}
+	query = `UPDATE urls SET access_count = 0, unique_clicks = 0, bot_clicks = 0, suspicious_clicks = 0 WHERE id = $1`
+	if _, err := tx.ExecContext(ctx, query, id); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	db.forget(ctx, shortCode)
+	return &before, nil
+}
+
+// SubtractClicks takes correction amounts off a link's click and unique
+// click counts, stopping at zero, and returns the counts before and after.
+// Breakdowns and time series are left as they are.
+func (db *Database) SubtractClicks(ctx context.Context, shortCode string, clicks, uniqueClicks int64) (before, after *ClickCounts, err error) {
+	ctx, cancel := db.queryContext(ctx)
+	defer cancel()
+
+	before, after = &ClickCounts{}, &ClickCounts{}
+	query := `WITH old AS (
+				SELECT id, access_count, unique_clicks, bot_clicks, suspicious_clicks FROM urls WHERE short_code = $1 AND tenant_id = $4 AND COALESCE(domain, '') = $5 FOR UPDATE
+			  )
+			  UPDATE urls SET access_count = GREATEST(urls.access_count - $2, 0), unique_clicks = GREATEST(urls.unique_clicks - $3, 0)
+			  FROM old WHERE urls.id = old.id
+			  RETURNING old.access_count, old.unique_clicks, urls.access_count, urls.unique_clicks, urls.bot_clicks, urls.suspicious_clicks`
+	err = db.conn.QueryRowContext(ctx, query, shortCode, clicks, uniqueClicks, TenantFrom(ctx), DomainFrom(ctx)).
+		Scan(&before.Clicks, &before.UniqueClicks, &after.Clicks, &after.UniqueClicks, &after.BotClicks, &after.SuspiciousClicks)
+	if err != nil {
+		return nil, nil, err
+	}
+	before.BotClicks, before.SuspiciousClicks = after.BotClicks, after.SuspiciousClicks
+	db.forget(ctx, shortCode)
+	return before, after, nil
+}
@@ -0,0 +1,116 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"strconv"
+
+	"github.com/lib/pq"
+)
+
+// TagFilter selects links for bulk tag operations. Links matching any of the
+// short codes, or all of the non-empty filter fields, are selected.
+type TagFilter struct {
+	ShortCodes       []string `json:"shortCodes,omitempty"`
+	Tag              string   `json:"tag,omitempty"`
+	OriginalContains string   `json:"originalContains,omitempty"`
+}
+
+// TagCount is the number of links carrying a tag
+type TagCount struct {
+	Tag   string `json:"tag"`
+	Count int    `json:"count"`
+}
+
+// Empty reports whether the filter selects nothing specific, which would match every link
+func (f TagFilter) Empty() bool {
+	return len(f.ShortCodes) == 0 && f.Tag == "" && f.OriginalContains == ""
+}
+
+// where renders the filter as a SQL condition, numbering its parameters after
+// offset. Short codes are those of the domain of ctx.
+func (f TagFilter) where(ctx context.Context, offset int) (string, []any) {
+	if len(f.ShortCodes) > 0 {
+		return `short_code = ANY($` + strconv.Itoa(offset+1) + `) AND COALESCE(domain, '') = $` + strconv.Itoa(offset+2),
+			[]any{pq.Array(f.ShortCodes), DomainFrom(ctx)}
+	}
+
+	cond := `TRUE`
+	var args []any
+	if f.Tag != "" {
+		args = append(args, f.Tag)
+		cond += ` AND $` + strconv.Itoa(offset+len(args)) + ` = ANY(tags)`
+	}
+	if f.OriginalContains != "" {
+		args = append(args, "%"+f.OriginalContains+"%")
+		cond += ` AND original ILIKE $` + strconv.Itoa(offset+len(args))
+	}
+	return cond, args
+}
+
+// AddTag attaches tag to every selected link of the tenant that does not already have it
+func (db *Database) AddTag(ctx context.Context, tag string, filter TagFilter) (int64, error) {
+	cond, args := filter.where(ctx, 2)
+	query := `UPDATE urls SET tags = array_append(tags, $1), updated_at = NOW() WHERE NOT ($1 = ANY(tags)) AND tenant_id = $2 AND ` + cond
+	return db.execCount(ctx, query, append([]any{tag, TenantFrom(ctx)}, args...)...)
+}
+
+// RemoveTag detaches tag from every selected link of the tenant
+func (db *Database) RemoveTag(ctx context.Context, tag string, filter TagFilter) (int64, error) {
+	cond, args := filter.where(ctx, 2)
+	query := `UPDATE urls SET tags = array_remove(tags, $1), updated_at = NOW() WHERE $1 = ANY(tags) AND tenant_id = $2 AND ` + cond
+	return db.execCount(ctx, query, append([]any{tag, TenantFrom(ctx)}, args...)...)
+}
+
+// RenameTag renames from to to on every link of the tenant. Links that already carry to
+// simply lose from, which merges the two tags.
+func (db *Database) RenameTag(ctx context.Context, from, to string) (int64, error) {
+	query := `UPDATE urls SET tags = CASE
+				WHEN $2 = ANY(tags) THEN array_remove(tags, $1)
+				ELSE array_replace(tags, $1, $2)
+			  END, updated_at = NOW()
+			  WHERE $1 = ANY(tags) AND tenant_id = $3`
+	return db.execCount(ctx, query, from, to, TenantFrom(ctx))
+}
+
+// GetTagCounts lists every tag the tenant uses with the number of links carrying it
+func (db *Database) GetTagCounts(ctx context.Context) ([]TagCount, error) {
+	query := `SELECT tag, COUNT(*) FROM urls, unnest(tags) AS tag WHERE tenant_id = $1 GROUP BY tag ORDER BY tag`
+
+	var counts []TagCount
+	err := db.read(ctx, func(ctx context.Context, conn *sql.DB) error {
+		rows, err := conn.QueryContext(ctx, query, TenantFrom(ctx))
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		counts = make([]TagCount, 0)
+		for rows.Next() {
+			var tc TagCount
+			if err := rows.Scan(&tc.Tag, &tc.Count); err != nil {
+				return err
+			}
+			counts = append(counts, tc)
+		}
+		return rows.Err()
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return counts, nil
+}
+
+// execCount runs a write on the primary and returns the number of rows it touched
+func (db *Database) execCount(ctx context.Context, query string, args ...any) (int64, error) {
+	ctx, cancel := db.queryContext(ctx)
+	defer cancel()
+
+	result, err := db.conn.ExecContext(ctx, query, args...)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
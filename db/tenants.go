@@ -0,0 +1,209 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// DefaultTenant owns the links of requests no API key or hostname assigns to
+// another tenant, and every link created before tenants existed
+const DefaultTenant = 0
+
+// ErrHostnameTaken is returned when a hostname is already assigned to another tenant
+var ErrHostnameTaken = errors.New("hostname belongs to another tenant")
+
+type tenantKey struct{}
+
+// WithTenant scopes the queries made with the returned context to a tenant's
+// links, organizations and campaigns
+func WithTenant(ctx context.Context, tenantID int) context.Context {
+	return context.WithValue(ctx, tenantKey{}, tenantID)
+}
+
+// TenantFrom returns the tenant queries made with ctx are scoped to
+func TenantFrom(ctx context.Context) int {
+	if id, ok := ctx.Value(tenantKey{}).(int); ok {
+		return id
+	}
+	return DefaultTenant
+}
+
+// Tenant is a brand served by this deployment, with links, organizations and
+// campaigns of its own
+type Tenant struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+	// Hostnames route requests to the tenant when they carry no API key
+	Hostnames []string  `json:"hostnames"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// APIKey selects the tenant of requests that present it. Only a hash of the
+// key is stored.
+type APIKey struct {
+	ID       int    `json:"id"`
+	TenantID int    `json:"tenantId"`
+	Name     string `json:"name"`
+	KeyHash  string `json:"-"`
+	// Scopes limit what requests with the key may do, and let it act without
+	// other credentials within them; a key without scopes only selects its tenant
+	Scopes    []string  `json:"scopes"`
+	CreatedBy string    `json:"createdBy,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+const tenantColumns = `t.id, t.name, t.created_at,
+	ARRAY(SELECT h.hostname FROM tenant_hostnames h WHERE h.tenant_id = t.id ORDER BY h.hostname)`
+
+func scanTenant(row rowScanner) (*Tenant, error) {
+	var t Tenant
+	if err := row.Scan(&t.ID, &t.Name, &t.CreatedAt, pq.Array(&t.Hostnames)); err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// GetTenants lists every tenant other than the default one, oldest first. It
+// reads from the primary so a change takes effect as soon as it is made.
+func (db *Database) GetTenants(ctx context.Context) ([]Tenant, error) {
+	ctx, cancel := db.queryContext(ctx)
+	defer cancel()
+
+	rows, err := db.conn.QueryContext(ctx, `SELECT `+tenantColumns+` FROM tenants t ORDER BY t.id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tenants := make([]Tenant, 0)
+	for rows.Next() {
+		t, err := scanTenant(rows)
+		if err != nil {
+			return nil, err
+		}
+		tenants = append(tenants, *t)
+	}
+	return tenants, rows.Err()
+}
+
+// CreateTenant stores a tenant along with its hostnames, filling in its ID
+// and creation time
+func (db *Database) CreateTenant(ctx context.Context, tenant *Tenant) error {
+	ctx, cancel := db.queryContext(ctx)
+	defer cancel()
+
+	tx, err := db.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRowContext(ctx, `INSERT INTO tenants (name) VALUES ($1) RETURNING id, created_at`, tenant.Name).Scan(&tenant.ID, &tenant.CreatedAt)
+	if err != nil {
+		return err
+	}
+	if err := setTenantHostnames(ctx, tx, tenant.ID, tenant.Hostnames); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// UpdateTenant replaces a tenant's name and hostnames, returning
+// sql.ErrNoRows if there is no tenant with its ID
+func (db *Database) UpdateTenant(ctx context.Context, tenant *Tenant) error {
+	ctx, cancel := db.queryContext(ctx)
+	defer cancel()
+
+	tx, err := db.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRowContext(ctx, `UPDATE tenants SET name = $2 WHERE id = $1 RETURNING created_at`, tenant.ID, tenant.Name).Scan(&tenant.CreatedAt)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM tenant_hostnames WHERE tenant_id = $1`, tenant.ID); err != nil {
+		return err
+	}
+	if err := setTenantHostnames(ctx, tx, tenant.ID, tenant.Hostnames); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// setTenantHostnames assigns hostnames to a tenant, failing with
+// ErrHostnameTaken if another tenant already has one of them
+func setTenantHostnames(ctx context.Context, tx *sql.Tx, tenantID int, hostnames []string) error {
+	for _, hostname := range hostnames {
+		result, err := tx.ExecContext(ctx, `INSERT INTO tenant_hostnames (hostname, tenant_id) VALUES ($1, $2) ON CONFLICT (hostname) DO NOTHING`,
+			hostname, tenantID)
+		if err != nil {
+			return err
+		}
+		if n, err := result.RowsAffected(); err != nil {
+			return err
+		} else if n == 0 {
+			return fmt.Errorf("%w: %s", ErrHostnameTaken, hostname)
+		}
+	}
+	return nil
+}
+
+// GetAPIKeys lists the API keys of every tenant, oldest first, reading from the primary
+func (db *Database) GetAPIKeys(ctx context.Context) ([]APIKey, error) {
+	ctx, cancel := db.queryContext(ctx)
+	defer cancel()
+
+	query := `SELECT id, tenant_id, name, key_hash, scopes, COALESCE(created_by, ''), created_at FROM api_keys ORDER BY id`
+	rows, err := db.conn.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := make([]APIKey, 0)
+	for rows.Next() {
+		var k APIKey
+		if err := rows.Scan(&k.ID, &k.TenantID, &k.Name, &k.KeyHash, pq.Array(&k.Scopes), &k.CreatedBy, &k.CreatedAt); err != nil {
+			return nil, err
+		}
+		keys = append(keys, k)
+	}
+	return keys, rows.Err()
+}
+
+// CreateAPIKey stores a key for a tenant, filling in its ID and creation
+// time. It returns sql.ErrNoRows if the tenant does not exist.
+func (db *Database) CreateAPIKey(ctx context.Context, key *APIKey) error {
+	ctx, cancel := db.queryContext(ctx)
+	defer cancel()
+
+	query := `INSERT INTO api_keys (tenant_id, name, key_hash, scopes, created_by)
+			  SELECT id, $2, $3, $4, NULLIF($5, '') FROM tenants WHERE id = $1
+			  RETURNING id, created_at`
+	if key.Scopes == nil {
+		key.Scopes = []string{}
+	}
+	return db.conn.QueryRowContext(ctx, query, key.TenantID, key.Name, key.KeyHash, pq.Array(key.Scopes), key.CreatedBy).
+		Scan(&key.ID, &key.CreatedAt)
+}
+
+// DeleteAPIKey revokes a key and returns it, or sql.ErrNoRows if there is none with that ID
+func (db *Database) DeleteAPIKey(ctx context.Context, id int) (*APIKey, error) {
+	ctx, cancel := db.queryContext(ctx)
+	defer cancel()
+
+	query := `DELETE FROM api_keys WHERE id = $1 RETURNING id, tenant_id, name, scopes, COALESCE(created_by, ''), created_at`
+	var k APIKey
+	if err := db.conn.QueryRowContext(ctx, query, id).Scan(&k.ID, &k.TenantID, &k.Name, pq.Array(&k.Scopes), &k.CreatedBy, &k.CreatedAt); err != nil {
+		return nil, err
+	}
+	return &k, nil
+}
@@ -0,0 +1,117 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// hourlyAggregator names the aggregator_state row of AggregateClicks
+const hourlyAggregator = "click_hourly_rollups"
+
+// aggregationLag keeps AggregateClicks behind the newest click events, so
+// inserts still committing with a lower ID are not skipped
+const aggregationLag = time.Minute
+
+// TimeBucket is the number of human clicks in one interval of a time series
+type TimeBucket struct {
+	Start  time.Time `json:"start"`
+	Clicks int       `json:"clicks"`
+}
+
+// AggregateClicks adds click events recorded since its last run to hourly
+// per-link counts and returns the number of counts written. The first run
+// also takes in the daily rollups of events already deleted, counting them
+// in the first hour of their day. Instances running it at once take turns.
+// It runs without the query timeout, since a first run may go through a
+// large backlog.
+func (db *Database) AggregateClicks(ctx context.Context) (int64, error) {
+	tx, err := db.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	created, err := tx.ExecContext(ctx, `INSERT INTO aggregator_state (name, last_id) VALUES ($1, 0) ON CONFLICT DO NOTHING`, hourlyAggregator)
+	if err != nil {
+		return 0, err
+	}
+	if first, err := created.RowsAffected(); err != nil {
+		return 0, err
+	} else if first > 0 {
+		query := `INSERT INTO click_hourly_rollups (url_id, hour, clicks)
+				  SELECT url_id, day, SUM(clicks) FROM click_rollups GROUP BY url_id, day`
+		if _, err := tx.ExecContext(ctx, query); err != nil {
+			return 0, err
+		}
+	}
+
+	var lastID, upTo int64
+	if err := tx.QueryRowContext(ctx, `SELECT last_id FROM aggregator_state WHERE name = $1 FOR UPDATE`, hourlyAggregator).Scan(&lastID); err != nil {
+		return 0, err
+	}
+	query := `SELECT COALESCE(MAX(id), $1) FROM clicks WHERE id > $1 AND clicked_at < NOW() - $2 * INTERVAL '1 second'`
+	if err := tx.QueryRowContext(ctx, query, lastID, aggregationLag.Seconds()).Scan(&upTo); err != nil {
+		return 0, err
+	}
+	if upTo == lastID {
+		return 0, tx.Commit()
+	}
+
+	query = `INSERT INTO click_hourly_rollups (url_id, hour, clicks)
+			 SELECT url_id, date_trunc('hour', clicked_at), COUNT(*) FROM clicks
+			 WHERE id > $1 AND id <= $2
+			 GROUP BY url_id, date_trunc('hour', clicked_at)
+			 ON CONFLICT (url_id, hour) DO UPDATE SET clicks = click_hourly_rollups.clicks + EXCLUDED.clicks`
+	result, err := tx.ExecContext(ctx, query, lastID, upTo)
+	if err != nil {
+		return 0, err
+	}
+	if _, err := tx.ExecContext(ctx, `UPDATE aggregator_state SET last_id = $1 WHERE name = $2`, upTo, hourlyAggregator); err != nil {
+		return 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// GetClickTimeSeries sums a link's hourly click counts into buckets of unit
+// (hour, day or week) from from up to to, which must be aligned to unit.
+// Buckets without clicks are listed with zero. Clicks of the last minute or
+// so are only counted once AggregateClicks has run.
+func (db *Database) GetClickTimeSeries(ctx context.Context, urlID int, unit string, from, to time.Time) ([]TimeBucket, error) {
+	query := `WITH buckets AS (
+				SELECT date_trunc($2, hour) AS bucket, SUM(clicks) AS clicks FROM click_hourly_rollups
+				WHERE url_id = $1 AND hour >= $3 AND hour < $4
+				GROUP BY date_trunc($2, hour)
+			  )
+			  SELECT s, COALESCE(b.clicks, 0)
+			  FROM generate_series($3::TIMESTAMP, $4::TIMESTAMP - INTERVAL '1 second', ('1 ' || $2)::INTERVAL) AS s
+			  LEFT JOIN buckets b ON b.bucket = s
+			  ORDER BY s`
+
+	var series []TimeBucket
+	err := db.read(ctx, func(ctx context.Context, conn *sql.DB) error {
+		rows, err := conn.QueryContext(ctx, query, urlID, unit, from.UTC(), to.UTC())
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		series = make([]TimeBucket, 0)
+		for rows.Next() {
+			var bucket TimeBucket
+			if err := rows.Scan(&bucket.Start, &bucket.Clicks); err != nil {
+				return err
+			}
+			bucket.Start = bucket.Start.UTC()
+			series = append(series, bucket)
+		}
+		return rows.Err()
+	})
+	if err != nil {
+		return nil, err
+	}
+	return series, nil
+}
@@ -0,0 +1,207 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// Transfer statuses
+const (
+	TransferPending   = "pending"
+	TransferAccepted  = "accepted"
+	TransferDeclined  = "declined"
+	TransferCancelled = "cancelled"
+)
+
+// ErrTransferPending is returned when a link or campaign already has a
+// transfer awaiting acceptance
+var ErrTransferPending = errors.New("a transfer is already pending")
+
+// Transfer hands a link, or a campaign with all of its links, to another user
+// or organization once the recipient accepts it
+type Transfer struct {
+	ID         int    `json:"id"`
+	ShortCode  string `json:"shortCode,omitempty"`
+	Domain     string `json:"domain,omitempty"`
+	CampaignID int    `json:"campaignId,omitempty"`
+	// ToUserID or ToOrgID is the recipient
+	ToUserID    string     `json:"toUserId,omitempty"`
+	ToOrgID     int        `json:"toOrgId,omitempty"`
+	RequestedBy string     `json:"requestedBy"`
+	Status      string     `json:"status"`
+	CreatedAt   time.Time  `json:"createdAt"`
+	ResolvedBy  string     `json:"resolvedBy,omitempty"`
+	ResolvedAt  *time.Time `json:"resolvedAt,omitempty"`
+}
+
+const transferColumns = `t.id, COALESCE(u.short_code, ''), COALESCE(u.domain, ''), COALESCE(t.campaign_id, 0),
+	COALESCE(t.to_user_id, ''), COALESCE(t.to_org_id, 0), t.requested_by, t.status, t.created_at,
+	COALESCE(t.resolved_by, ''), t.resolved_at`
+
+func scanTransfer(row rowScanner) (*Transfer, error) {
+	var t Transfer
+	if err := row.Scan(&t.ID, &t.ShortCode, &t.Domain, &t.CampaignID, &t.ToUserID, &t.ToOrgID, &t.RequestedBy, &t.Status,
+		&t.CreatedAt, &t.ResolvedBy, &t.ResolvedAt); err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// CreateTransfer offers the link t.ShortCode of the domain of ctx, or the
+// campaign t.CampaignID, to its recipient and fills in the rest of t. It
+// returns ErrTransferPending if one is already awaiting acceptance, or
+// sql.ErrNoRows if the recipient organization is not one of the tenant's.
+func (db *Database) CreateTransfer(ctx context.Context, t *Transfer) error {
+	ctx, cancel := db.queryContext(ctx)
+	defer cancel()
+
+	query := `INSERT INTO transfers (tenant_id, url_id, campaign_id, to_user_id, to_org_id, requested_by)
+			  SELECT $1, (SELECT id FROM urls WHERE short_code = $2 AND tenant_id = $1 AND COALESCE(domain, '') = $3),
+			  NULLIF($4, 0), NULLIF($5, ''), NULLIF($6, 0), $7
+			  WHERE $6 = 0 OR EXISTS (SELECT 1 FROM organizations WHERE id = $6 AND tenant_id = $1)
+			  ON CONFLICT DO NOTHING
+			  RETURNING id, status, created_at`
+	err := db.conn.QueryRowContext(ctx, query, TenantFrom(ctx), t.ShortCode, DomainFrom(ctx), t.CampaignID, t.ToUserID, t.ToOrgID,
+		t.RequestedBy).Scan(&t.ID, &t.Status, &t.CreatedAt)
+	if !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+
+	// Nothing was inserted because of a pending transfer or an unknown organization
+	var pending bool
+	query = `SELECT EXISTS (SELECT 1 FROM transfers t LEFT JOIN urls u ON u.id = t.url_id
+			  WHERE t.status = 'pending' AND t.tenant_id = $1
+			  AND (t.campaign_id = NULLIF($4, 0) OR (u.short_code = $2 AND COALESCE(u.domain, '') = $3)))`
+	if err := db.conn.QueryRowContext(ctx, query, TenantFrom(ctx), t.ShortCode, DomainFrom(ctx), t.CampaignID).Scan(&pending); err != nil {
+		return err
+	}
+	if pending {
+		return ErrTransferPending
+	}
+	return sql.ErrNoRows
+}
+
+// GetTransfer returns a transfer of the tenant, or sql.ErrNoRows if there is
+// none with that ID. It reads from the primary, as it decides who may accept.
+func (db *Database) GetTransfer(ctx context.Context, id int) (*Transfer, error) {
+	ctx, cancel := db.queryContext(ctx)
+	defer cancel()
+
+	query := `SELECT ` + transferColumns + ` FROM transfers t LEFT JOIN urls u ON u.id = t.url_id
+			  WHERE t.id = $1 AND t.tenant_id = $2`
+	return scanTransfer(db.conn.QueryRowContext(ctx, query, id, TenantFrom(ctx)))
+}
+
+// GetPendingTransfers lists the tenant's transfers awaiting acceptance that
+// userID requested or may accept, as the recipient or an owner of the
+// recipient organization, oldest first; every pending transfer when userID is empty
+func (db *Database) GetPendingTransfers(ctx context.Context, userID string) ([]Transfer, error) {
+	query := `SELECT ` + transferColumns + ` FROM transfers t LEFT JOIN urls u ON u.id = t.url_id
+			  WHERE t.status = 'pending' AND t.tenant_id = $2
+			  AND ($1 = '' OR t.requested_by = $1 OR t.to_user_id = $1 OR t.to_org_id IN (
+				SELECT org_id FROM organization_members WHERE user_id = $1 AND role = 'owner'
+			  ))
+			  ORDER BY t.created_at`
+
+	var transfers []Transfer
+	err := db.read(ctx, func(ctx context.Context, conn *sql.DB) error {
+		rows, err := conn.QueryContext(ctx, query, userID, TenantFrom(ctx))
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		transfers = make([]Transfer, 0)
+		for rows.Next() {
+			t, err := scanTransfer(rows)
+			if err != nil {
+				return err
+			}
+			transfers = append(transfers, *t)
+		}
+		return rows.Err()
+	})
+	if err != nil {
+		return nil, err
+	}
+	return transfers, nil
+}
+
+// AcceptTransfer carries out a pending transfer accepted by acceptedBy. Its
+// links, and its campaign, are given to the recipient user or, for an
+// organization, to the organization with acceptedBy as their creator. The
+// links get tokenHash as their management token hash, so the previous
+// owner's token stops working. It returns how many links changed hands, or
+// sql.ErrNoRows if the transfer is no longer pending.
+func (db *Database) AcceptTransfer(ctx context.Context, id int, acceptedBy, tokenHash string) (int, error) {
+	ctx, cancel := db.queryContext(ctx)
+	defer cancel()
+
+	tx, err := db.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var urlID, campaignID sql.NullInt64
+	var ownerID string
+	var orgID int
+	query := `UPDATE transfers SET status = 'accepted', resolved_by = $2, resolved_at = NOW()
+			  WHERE id = $1 AND tenant_id = $3 AND status = 'pending'
+			  RETURNING url_id, campaign_id, COALESCE(to_user_id, $2), COALESCE(to_org_id, 0)`
+	if err := tx.QueryRowContext(ctx, query, id, acceptedBy, TenantFrom(ctx)).Scan(&urlID, &campaignID, &ownerID, &orgID); err != nil {
+		return 0, err
+	}
+
+	if campaignID.Valid {
+		if _, err := tx.ExecContext(ctx, `UPDATE campaigns SET owner_id = NULLIF($2, '') WHERE id = $1`, campaignID, ownerID); err != nil {
+			return 0, err
+		}
+	}
+	query = `UPDATE urls SET owner_id = NULLIF($3, ''), org_id = NULLIF($4, 0), owner_token_hash = $5, updated_at = NOW()
+			 WHERE id = $1 OR campaign_id = $2
+			 RETURNING short_code, COALESCE(domain, '')`
+	rows, err := tx.QueryContext(ctx, query, urlID, campaignID, ownerID, orgID, tokenHash)
+	if err != nil {
+		return 0, err
+	}
+	type link struct{ shortCode, domain string }
+	var moved []link
+	for rows.Next() {
+		var l link
+		if err := rows.Scan(&l.shortCode, &l.domain); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		moved = append(moved, l)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	for _, l := range moved {
+		db.forget(WithDomain(ctx, l.domain), l.shortCode)
+	}
+	return len(moved), nil
+}
+
+// CloseTransfer declines or cancels a pending transfer with status, recording
+// who closed it; it returns sql.ErrNoRows if the transfer is no longer pending
+func (db *Database) CloseTransfer(ctx context.Context, id int, status, closedBy string) error {
+	query := `UPDATE transfers SET status = $2, resolved_by = $3, resolved_at = NOW()
+			  WHERE id = $1 AND tenant_id = $4 AND status = 'pending'`
+	affected, err := db.execCount(ctx, query, id, status, closedBy, TenantFrom(ctx))
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
@@ -0,0 +1,223 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// ErrTwoFactorEnabled is returned when enrolling a user who already has two-factor authentication
+var ErrTwoFactorEnabled = errors.New("two-factor authentication is already enabled")
+
+// TOTP is a user's authenticator app enrollment
+type TOTP struct {
+	Secret  string
+	Enabled bool
+	// LastStep is the time step of the last code accepted, so no code is accepted twice
+	LastStep int64
+}
+
+// GetTOTP returns a user's enrollment; Secret is empty if they never started one
+func (db *Database) GetTOTP(ctx context.Context, userID int) (*TOTP, error) {
+	ctx, cancel := db.queryContext(ctx)
+	defer cancel()
+
+	var t TOTP
+	err := db.conn.QueryRowContext(ctx, `SELECT COALESCE(totp_secret, ''), totp_enabled_at IS NOT NULL, totp_last_step FROM users WHERE id = $1`,
+		userID).Scan(&t.Secret, &t.Enabled, &t.LastStep)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// StartTOTP stores the secret of an enrollment that EnableTOTP completes,
+// replacing any unfinished one. It returns ErrTwoFactorEnabled if the user
+// already has two-factor authentication.
+func (db *Database) StartTOTP(ctx context.Context, userID int, secret string) error {
+	affected, err := db.execCount(ctx, `UPDATE users SET totp_secret = $2, totp_last_step = 0 WHERE id = $1 AND totp_enabled_at IS NULL`,
+		userID, secret)
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return ErrTwoFactorEnabled
+	}
+	return nil
+}
+
+// EnableTOTP turns on two-factor authentication once the user proved their
+// app works with the code of step, and stores their recovery codes
+func (db *Database) EnableTOTP(ctx context.Context, userID int, step int64, recoveryHashes []string) error {
+	ctx, cancel := db.queryContext(ctx)
+	defer cancel()
+
+	tx, err := db.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx, `UPDATE users SET totp_enabled_at = NOW(), totp_last_step = $2
+		WHERE id = $1 AND totp_secret IS NOT NULL AND totp_enabled_at IS NULL AND totp_last_step < $2`, userID, step)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrTwoFactorEnabled
+	}
+	if err := replaceRecoveryCodes(ctx, tx, userID, recoveryHashes); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// DisableTOTP turns off two-factor authentication and drops the user's recovery codes
+func (db *Database) DisableTOTP(ctx context.Context, userID int) error {
+	ctx, cancel := db.queryContext(ctx)
+	defer cancel()
+
+	tx, err := db.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `UPDATE users SET totp_secret = NULL, totp_enabled_at = NULL, totp_last_step = 0 WHERE id = $1`,
+		userID); err != nil {
+		return err
+	}
+	if err := replaceRecoveryCodes(ctx, tx, userID, nil); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// UseTOTPStep records that a code of step was accepted, reporting false if a
+// code of that or a later step already was
+func (db *Database) UseTOTPStep(ctx context.Context, userID int, step int64) (bool, error) {
+	affected, err := db.execCount(ctx, `UPDATE users SET totp_last_step = $2 WHERE id = $1 AND totp_last_step < $2`, userID, step)
+	return affected > 0, err
+}
+
+// SetRecoveryCodes replaces a user's recovery codes with new ones
+func (db *Database) SetRecoveryCodes(ctx context.Context, userID int, hashes []string) error {
+	ctx, cancel := db.queryContext(ctx)
+	defer cancel()
+
+	tx, err := db.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := replaceRecoveryCodes(ctx, tx, userID, hashes); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func replaceRecoveryCodes(ctx context.Context, tx *sql.Tx, userID int, hashes []string) error {
+	if _, err := tx.ExecContext(ctx, `DELETE FROM recovery_codes WHERE user_id = $1`, userID); err != nil {
+		return err
+	}
+	for _, hash := range hashes {
+		if _, err := tx.ExecContext(ctx, `INSERT INTO recovery_codes (user_id, code_hash) VALUES ($1, $2) ON CONFLICT DO NOTHING`,
+			userID, hash); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// UseRecoveryCode spends one of a user's recovery codes, reporting false if
+// they have none with that hash
+func (db *Database) UseRecoveryCode(ctx context.Context, userID int, hash string) (bool, error) {
+	affected, err := db.execCount(ctx, `DELETE FROM recovery_codes WHERE user_id = $1 AND code_hash = $2`, userID, hash)
+	return affected > 0, err
+}
+
+// CountRecoveryCodes returns how many unused recovery codes a user has left
+func (db *Database) CountRecoveryCodes(ctx context.Context, userID int) (int, error) {
+	ctx, cancel := db.queryContext(ctx)
+	defer cancel()
+
+	var n int
+	err := db.conn.QueryRowContext(ctx, `SELECT COUNT(*) FROM recovery_codes WHERE user_id = $1`, userID).Scan(&n)
+	return n, err
+}
+
+// GetPendingSessionUser returns the user of an unexpired session still
+// waiting for a second factor
+func (db *Database) GetPendingSessionUser(ctx context.Context, tokenHash string) (*User, error) {
+	ctx, cancel := db.queryContext(ctx)
+	defer cancel()
+
+	query := `SELECT ` + userColumns + ` FROM sessions JOIN users ON users.id = sessions.user_id
+			  WHERE sessions.token_hash = $1 AND sessions.expires_at > NOW() AND sessions.pending_two_factor`
+	return scanUser(db.conn.QueryRowContext(ctx, query, tokenHash))
+}
+
+// CompleteSession makes a pending session usable for ttl from now, or
+// returns sql.ErrNoRows if it expired
+func (db *Database) CompleteSession(ctx context.Context, tokenHash string, ttl time.Duration) error {
+	affected, err := db.execCount(ctx, `UPDATE sessions SET pending_two_factor = FALSE, expires_at = NOW() + $2 * INTERVAL '1 second'
+		WHERE token_hash = $1 AND pending_two_factor AND expires_at > NOW()`, tokenHash, ttl.Seconds())
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// FailSessionTwoFactor counts a wrong code against a pending session and
+// ends it once maxAttempts were wrong, reporting whether it did
+func (db *Database) FailSessionTwoFactor(ctx context.Context, tokenHash string, maxAttempts int) (bool, error) {
+	ctx, cancel := db.queryContext(ctx)
+	defer cancel()
+
+	var attempts int
+	err := db.conn.QueryRowContext(ctx, `UPDATE sessions SET two_factor_attempts = two_factor_attempts + 1
+		WHERE token_hash = $1 AND pending_two_factor RETURNING two_factor_attempts`, tokenHash).Scan(&attempts)
+	if errors.Is(err, sql.ErrNoRows) {
+		return true, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if attempts < maxAttempts {
+		return false, nil
+	}
+	_, err = db.conn.ExecContext(ctx, `DELETE FROM sessions WHERE token_hash = $1`, tokenHash)
+	return err == nil, err
+}
+
+// GetOrgRequiresTwoFactor reports whether an organization of the tenant
+// requires its members to use two-factor authentication
+func (db *Database) GetOrgRequiresTwoFactor(ctx context.Context, orgID int) (bool, error) {
+	ctx, cancel := db.queryContext(ctx)
+	defer cancel()
+
+	var required bool
+	err := db.conn.QueryRowContext(ctx, `SELECT require_two_factor FROM organizations WHERE id = $1 AND tenant_id = $2`,
+		orgID, TenantFrom(ctx)).Scan(&required)
+	return required, err
+}
+
+// SetOrgRequiresTwoFactor changes whether an organization of the tenant
+// requires two-factor authentication, returning sql.ErrNoRows if there is no
+// such organization
+func (db *Database) SetOrgRequiresTwoFactor(ctx context.Context, orgID int, required bool) error {
+	affected, err := db.execCount(ctx, `UPDATE organizations SET require_two_factor = $2 WHERE id = $1 AND tenant_id = $3`,
+		orgID, required, TenantFrom(ctx))
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
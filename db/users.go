@@ -0,0 +1,131 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// User is a person who signed in through an external identity provider
+type User struct {
+	ID        int       `json:"id"`
+	Email     string    `json:"email,omitempty"`
+	Name      string    `json:"name,omitempty"`
+	Role      string    `json:"role"`
+	CreatedAt time.Time `json:"createdAt"`
+	// TwoFactor is set once the user has enrolled an authenticator app
+	TwoFactor bool `json:"twoFactorEnabled"`
+}
+
+const userColumns = `users.id, COALESCE(users.email, ''), COALESCE(users.name, ''), users.role, users.created_at, users.totp_enabled_at IS NOT NULL`
+
+func scanUser(row rowScanner) (*User, error) {
+	var u User
+	if err := row.Scan(&u.ID, &u.Email, &u.Name, &u.Role, &u.CreatedAt, &u.TwoFactor); err != nil {
+		return nil, err
+	}
+	return &u, nil
+}
+
+// UpsertOAuthUser returns the user linked to an identity provider account,
+// creating the user on first sign-in and refreshing email and name afterwards
+func (db *Database) UpsertOAuthUser(ctx context.Context, provider, subject, email, name string) (*User, error) {
+	ctx, cancel := db.queryContext(ctx)
+	defer cancel()
+
+	tx, err := db.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var userID int
+	err = tx.QueryRowContext(ctx, `SELECT user_id FROM user_identities WHERE provider = $1 AND subject = $2`,
+		provider, subject).Scan(&userID)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		if err := tx.QueryRowContext(ctx, `INSERT INTO users (email, name) VALUES (NULLIF($1, ''), NULLIF($2, '')) RETURNING id`,
+			email, name).Scan(&userID); err != nil {
+			return nil, err
+		}
+		if _, err := tx.ExecContext(ctx, `INSERT INTO user_identities (provider, subject, user_id) VALUES ($1, $2, $3)`,
+			provider, subject, userID); err != nil {
+			return nil, err
+		}
+	case err != nil:
+		return nil, err
+	}
+
+	user, err := scanUser(tx.QueryRowContext(ctx, `UPDATE users
+		SET email = COALESCE(NULLIF($2, ''), email), name = COALESCE(NULLIF($3, ''), name)
+		WHERE id = $1 RETURNING `+userColumns, userID, email, name))
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// CreateSession stores a login session under the hash of its cookie token
+func (db *Database) CreateSession(ctx context.Context, tokenHash string, userID int, ttl time.Duration) error {
+	ctx, cancel := db.queryContext(ctx)
+	defer cancel()
+
+	query := `INSERT INTO sessions (token_hash, user_id, expires_at) VALUES ($1, $2, NOW() + $3 * INTERVAL '1 second')`
+	_, err := db.conn.ExecContext(ctx, query, tokenHash, userID, ttl.Seconds())
+	return err
+}
+
+// CreatePendingSession stores a login session that only becomes usable once
+// the user's second factor is checked by CompleteSession
+func (db *Database) CreatePendingSession(ctx context.Context, tokenHash string, userID int, ttl time.Duration) error {
+	ctx, cancel := db.queryContext(ctx)
+	defer cancel()
+
+	query := `INSERT INTO sessions (token_hash, user_id, expires_at, pending_two_factor)
+			  VALUES ($1, $2, NOW() + $3 * INTERVAL '1 second', TRUE)`
+	_, err := db.conn.ExecContext(ctx, query, tokenHash, userID, ttl.Seconds())
+	return err
+}
+
+// GetSessionUser returns the user of an unexpired session, excluding those
+// still waiting for a second factor. Sessions are read from the primary so a
+// login is usable before replicas catch up.
+func (db *Database) GetSessionUser(ctx context.Context, tokenHash string) (*User, error) {
+	ctx, cancel := db.queryContext(ctx)
+	defer cancel()
+
+	query := `SELECT ` + userColumns + ` FROM sessions JOIN users ON users.id = sessions.user_id
+			  WHERE sessions.token_hash = $1 AND sessions.expires_at > NOW() AND NOT sessions.pending_two_factor`
+	return scanUser(db.conn.QueryRowContext(ctx, query, tokenHash))
+}
+
+// GetOwnerEmail returns the email address of the signed-in user who created a
+// link, or sql.ErrNoRows for anonymous links and users without an email
+func (db *Database) GetOwnerEmail(ctx context.Context, shortCode string) (string, error) {
+	query := `SELECT users.email FROM urls JOIN users ON users.id::TEXT = urls.owner_id
+			  WHERE urls.short_code = $1 AND urls.tenant_id = $2 AND COALESCE(urls.domain, '') = $3 AND users.email IS NOT NULL`
+
+	var email string
+	err := db.read(ctx, func(ctx context.Context, conn *sql.DB) error {
+		return conn.QueryRowContext(ctx, query, shortCode, TenantFrom(ctx), DomainFrom(ctx)).Scan(&email)
+	})
+	return email, err
+}
+
+// PruneSessions deletes sessions that have expired
+func (db *Database) PruneSessions(ctx context.Context) (int64, error) {
+	return db.execCount(ctx, `DELETE FROM sessions WHERE expires_at <= NOW()`)
+}
+
+func (db *Database) DeleteSession(ctx context.Context, tokenHash string) error {
+	ctx, cancel := db.queryContext(ctx)
+	defer cancel()
+
+	_, err := db.conn.ExecContext(ctx, `DELETE FROM sessions WHERE token_hash = $1`, tokenHash)
+	return err
+}
@@ -0,0 +1,127 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+)
+
+// Variant is one weighted destination of an A/B tested link
+type Variant struct {
+	Name   string `json:"name"`
+	URL    string `json:"url"`
+	Weight int    `json:"weight"`
+}
+
+// VariantStats is a variant with the clicks and conversions recorded for it
+type VariantStats struct {
+	Variant
+	Clicks      int64 `json:"clicks"`
+	Conversions int64 `json:"conversions"`
+}
+
+// SetVariants replaces a link's A/B destinations; an empty slice ends the test.
+// Counters of variants that keep their name are preserved.
+func (db *Database) SetVariants(ctx context.Context, shortCode string, variants []Variant) error {
+	ctx, cancel := db.queryContext(ctx)
+	defer cancel()
+
+	var encoded any
+	if len(variants) > 0 {
+		b, err := json.Marshal(variants)
+		if err != nil {
+			return err
+		}
+		encoded = b
+	}
+
+	query := `UPDATE urls SET variants = $1, updated_at = NOW() WHERE short_code = $2 AND tenant_id = $3 AND COALESCE(domain, '') = $4 AND NOT locked`
+	result, err := db.conn.ExecContext(ctx, query, encoded, shortCode, TenantFrom(ctx), DomainFrom(ctx))
+	if err != nil {
+		return err
+	}
+	db.changed(ctx, shortCode)
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return db.missingOrLocked(ctx, shortCode)
+	}
+
+	return nil
+}
+
+// RecordVariantClick counts a human redirect to one of a link's variants
+func (db *Database) RecordVariantClick(ctx context.Context, shortCode, variant string) error {
+	ctx, cancel := db.queryContext(ctx)
+	defer cancel()
+
+	query := `INSERT INTO variant_stats (url_id, variant, clicks)
+			  SELECT id, $2, 1 FROM urls WHERE short_code = $1 AND tenant_id = $3 AND COALESCE(domain, '') = $4
+			  ON CONFLICT (url_id, variant) DO UPDATE SET clicks = variant_stats.clicks + 1`
+	_, err := db.conn.ExecContext(ctx, query, shortCode, variant, TenantFrom(ctx), DomainFrom(ctx))
+	return err
+}
+
+// RecordConversion counts a conversion for a variant of a link. It returns
+// sql.ErrNoRows when the link has no variant with that name.
+func (db *Database) RecordConversion(ctx context.Context, shortCode, variant string) error {
+	ctx, cancel := db.queryContext(ctx)
+	defer cancel()
+
+	query := `INSERT INTO variant_stats (url_id, variant, conversions)
+			  SELECT id, $2, 1 FROM urls
+			  WHERE short_code = $1 AND tenant_id = $3 AND COALESCE(domain, '') = $4 AND variants @> jsonb_build_array(jsonb_build_object('name', $2::text))
+			  ON CONFLICT (url_id, variant) DO UPDATE SET conversions = variant_stats.conversions + 1`
+	result, err := db.conn.ExecContext(ctx, query, shortCode, variant, TenantFrom(ctx), DomainFrom(ctx))
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// GetVariantStats returns the counters of a link's current variants, in the
+// order they were configured
+func (db *Database) GetVariantStats(ctx context.Context, url *URL) ([]VariantStats, error) {
+	query := `SELECT variant, clicks, conversions FROM variant_stats WHERE url_id = $1`
+
+	counts := make(map[string][2]int64)
+	err := db.read(ctx, func(ctx context.Context, conn *sql.DB) error {
+		rows, err := conn.QueryContext(ctx, query, url.ID)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var name string
+			var clicks, conversions int64
+			if err := rows.Scan(&name, &clicks, &conversions); err != nil {
+				return err
+			}
+			counts[name] = [2]int64{clicks, conversions}
+		}
+		return rows.Err()
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	stats := make([]VariantStats, 0, len(url.Variants))
+	for _, v := range url.Variants {
+		c := counts[v.Name]
+		stats = append(stats, VariantStats{Variant: v, Clicks: c[0], Conversions: c[1]})
+	}
+	return stats, nil
+}
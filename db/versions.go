@@ -0,0 +1,65 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// URLVersion is one destination a link has pointed to
+type URLVersion struct {
+	ID        int64     `json:"id"`
+	Original  string    `json:"original"`
+	CreatedBy string    `json:"createdBy,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+	Current   bool      `json:"current"`
+}
+
+// GetURLVersions lists a link's destination history, newest first
+func (db *Database) GetURLVersions(ctx context.Context, shortCode string) ([]URLVersion, error) {
+	query := `SELECT v.id, v.original, COALESCE(v.created_by, ''), v.created_at
+			  FROM url_versions v JOIN urls u ON u.id = v.url_id
+			  WHERE u.short_code = $1 AND u.tenant_id = $2 AND COALESCE(u.domain, '') = $3 ORDER BY v.created_at DESC, v.id DESC`
+
+	var versions []URLVersion
+	err := db.read(ctx, func(ctx context.Context, conn *sql.DB) error {
+		rows, err := conn.QueryContext(ctx, query, shortCode, TenantFrom(ctx), DomainFrom(ctx))
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		versions = make([]URLVersion, 0)
+		for rows.Next() {
+			var v URLVersion
+			if err := rows.Scan(&v.ID, &v.Original, &v.CreatedBy, &v.CreatedAt); err != nil {
+				return err
+			}
+			versions = append(versions, v)
+		}
+		return rows.Err()
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(versions) > 0 {
+		versions[0].Current = true
+	}
+	return versions, nil
+}
+
+// GetURLVersionDestination returns the destination of one of a link's versions, or
+// sql.ErrNoRows when the version does not belong to the link
+func (db *Database) GetURLVersionDestination(ctx context.Context, shortCode string, versionID int64) (string, error) {
+	ctx, cancel := db.queryContext(ctx)
+	defer cancel()
+
+	var original string
+	query := `SELECT v.original FROM url_versions v JOIN urls u ON u.id = v.url_id
+			  WHERE u.short_code = $1 AND u.tenant_id = $3 AND COALESCE(u.domain, '') = $4 AND v.id = $2`
+	if err := db.conn.QueryRowContext(ctx, query, shortCode, versionID, TenantFrom(ctx), DomainFrom(ctx)).Scan(&original); err != nil {
+		return "", err
+	}
+	return original, nil
+}
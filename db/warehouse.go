@@ -0,0 +1,186 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+)
+
+// WarehouseExport is where an organization's daily click aggregates are
+// exported to
+type WarehouseExport struct {
+	OrgID int `json:"orgId"`
+	// Destination is parquet, bigquery or snowflake
+	Destination string `json:"destination"`
+	// Settings locate the destination, e.g. a BigQuery project and table
+	Settings map[string]string `json:"settings"`
+	// Credentials authenticate to the destination and are never returned
+	Credentials    string `json:"-"`
+	HasCredentials bool   `json:"hasCredentials"`
+	// ExportedThrough is the last day exported, nil before the first export
+	ExportedThrough *time.Time `json:"exportedThrough"`
+	LastError       string     `json:"lastError,omitempty"`
+	UpdatedAt       time.Time  `json:"updatedAt"`
+}
+
+// ClickAggregate counts a link's clicks on one day with the same country,
+// device, browser and OS
+type ClickAggregate struct {
+	Day       time.Time
+	ShortCode string
+	Domain    string
+	Campaign  string
+	Country   string
+	Device    string
+	Browser   string
+	OS        string
+	Clicks    int64
+}
+
+const warehouseExportColumns = `w.org_id, w.destination, w.settings, w.credentials, w.exported_through, COALESCE(w.last_error, ''), w.updated_at`
+
+func scanWarehouseExport(row rowScanner) (*WarehouseExport, error) {
+	var w WarehouseExport
+	var settings []byte
+	var through sql.NullTime
+	if err := row.Scan(&w.OrgID, &w.Destination, &settings, &w.Credentials, &through, &w.LastError, &w.UpdatedAt); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(settings, &w.Settings); err != nil {
+		return nil, err
+	}
+	if through.Valid {
+		w.ExportedThrough = &through.Time
+	}
+	w.HasCredentials = w.Credentials != ""
+	return &w, nil
+}
+
+// GetWarehouseExport returns the export of an organization of the tenant, or
+// sql.ErrNoRows if it has none
+func (db *Database) GetWarehouseExport(ctx context.Context, orgID int) (*WarehouseExport, error) {
+	ctx, cancel := db.queryContext(ctx)
+	defer cancel()
+
+	query := `SELECT ` + warehouseExportColumns + ` FROM warehouse_exports w JOIN organizations o ON o.id = w.org_id
+			  WHERE w.org_id = $1 AND o.tenant_id = $2`
+	return scanWarehouseExport(db.conn.QueryRowContext(ctx, query, orgID, TenantFrom(ctx)))
+}
+
+// SetWarehouseExport stores the export of an organization and fills in its
+// update time. Empty credentials keep the stored ones. A non-nil from
+// restarts the export at that day; otherwise an existing export continues
+// where it left off.
+func (db *Database) SetWarehouseExport(ctx context.Context, w *WarehouseExport, from *time.Time) error {
+	ctx, cancel := db.queryContext(ctx)
+	defer cancel()
+
+	settings, err := json.Marshal(w.Settings)
+	if err != nil {
+		return err
+	}
+	var through any
+	if from != nil {
+		through = from.AddDate(0, 0, -1)
+	}
+	query := `INSERT INTO warehouse_exports AS w (org_id, destination, settings, credentials, exported_through) VALUES ($1, $2, $3, $4, $5)
+			  ON CONFLICT (org_id) DO UPDATE SET destination = EXCLUDED.destination, settings = EXCLUDED.settings,
+			  credentials = COALESCE(NULLIF(EXCLUDED.credentials, ''), w.credentials),
+			  exported_through = CASE WHEN $5::DATE IS NULL THEN w.exported_through ELSE EXCLUDED.exported_through END,
+			  last_error = NULL, updated_at = NOW()
+			  RETURNING ` + warehouseExportColumns
+	stored, err := scanWarehouseExport(db.conn.QueryRowContext(ctx, query, w.OrgID, w.Destination, settings, w.Credentials, through))
+	if err != nil {
+		return err
+	}
+	*w = *stored
+	return nil
+}
+
+// DeleteWarehouseExport stops exporting an organization's clicks, returning
+// sql.ErrNoRows if it has no export
+func (db *Database) DeleteWarehouseExport(ctx context.Context, orgID int) error {
+	affected, err := db.execCount(ctx, `DELETE FROM warehouse_exports WHERE org_id = $1`, orgID)
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// DueWarehouseExports returns the exports of every tenant that have days up
+// to through left to export, with their credentials
+func (db *Database) DueWarehouseExports(ctx context.Context, through time.Time) ([]WarehouseExport, error) {
+	ctx, cancel := db.queryContext(ctx)
+	defer cancel()
+
+	rows, err := db.conn.QueryContext(ctx, `SELECT `+warehouseExportColumns+` FROM warehouse_exports w
+											WHERE w.exported_through IS NULL OR w.exported_through < $1 ORDER BY w.org_id`, through)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	exports := make([]WarehouseExport, 0)
+	for rows.Next() {
+		w, err := scanWarehouseExport(rows)
+		if err != nil {
+			return nil, err
+		}
+		exports = append(exports, *w)
+	}
+	return exports, rows.Err()
+}
+
+// MarkWarehouseExport records that an organization's clicks were exported
+// through day, or, with a non-empty failure, why the next day failed
+func (db *Database) MarkWarehouseExport(ctx context.Context, orgID int, day time.Time, failure string) error {
+	query := `UPDATE warehouse_exports SET exported_through = $2, last_error = NULL WHERE org_id = $1`
+	args := []any{orgID, day}
+	if failure != "" {
+		query = `UPDATE warehouse_exports SET last_error = $2 WHERE org_id = $1`
+		args = []any{orgID, failure}
+	}
+	_, err := db.execCount(ctx, query, args...)
+	return err
+}
+
+// GetClickAggregates aggregates the clicks on an organization's links on one
+// UTC day, including those already folded into rollups
+func (db *Database) GetClickAggregates(ctx context.Context, orgID int, day time.Time) ([]ClickAggregate, error) {
+	query := `SELECT u.short_code, COALESCE(u.domain, ''), COALESCE(u.utm_campaign, ''), c.country, c.device, c.browser, c.os, SUM(c.clicks)
+			  FROM (
+				SELECT url_id, country, device, browser, os, 1 AS clicks FROM clicks
+				WHERE clicked_at >= $2 AND clicked_at < $2 + INTERVAL '1 day'
+				UNION ALL
+				SELECT url_id, country, device, browser, os, clicks FROM click_rollups WHERE day = $2::DATE
+			  ) c JOIN urls u ON u.id = c.url_id
+			  WHERE u.org_id = $1
+			  GROUP BY 1, 2, 3, 4, 5, 6, 7 ORDER BY 1, 2, 4, 5, 6, 7`
+
+	var clicks []ClickAggregate
+	err := db.read(ctx, func(ctx context.Context, conn *sql.DB) error {
+		rows, err := conn.QueryContext(ctx, query, orgID, day)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		clicks = make([]ClickAggregate, 0)
+		for rows.Next() {
+			d := ClickAggregate{Day: day}
+			if err := rows.Scan(&d.ShortCode, &d.Domain, &d.Campaign, &d.Country, &d.Device, &d.Browser, &d.OS, &d.Clicks); err != nil {
+				return err
+			}
+			clicks = append(clicks, d)
+		}
+		return rows.Err()
+	})
+	if err != nil {
+		return nil, err
+	}
+	return clicks, nil
+}
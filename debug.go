@@ -0,0 +1,62 @@
+package main
+
+import (
+	"errors"
+	"expvar"
+	"log"
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+	"time"
+	"url-shortener/config"
+)
+
+// startDebugServer serves pprof profiles and the expvar metrics on DEBUG_ADDR,
+// a listener apart from the public one meant to be reachable only from inside
+// the deployment. It returns nil when DEBUG_ADDR is unset.
+func startDebugServer(cfg *config.Config) *http.Server {
+	if cfg.Debug.Addr == "" {
+		return nil
+	}
+	// Mutex and block profiles stay empty unless sampling is turned on
+	runtime.SetMutexProfileFraction(cfg.Debug.MutexProfileFraction)
+	runtime.SetBlockProfileRate(cfg.Debug.BlockProfileRate)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	mux.Handle("/debug/vars", expvar.Handler())
+
+	// No write timeout: CPU profiles and traces take as long as they're asked to
+	srv := &http.Server{Addr: cfg.Debug.Addr, Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatalf("Debug listener failed: %v", err)
+		}
+	}()
+	log.Println("Serving pprof and expvar on", cfg.Debug.Addr)
+	return srv
+}
+
+// publishRuntimeMetrics adds the goroutine count and the size of the
+// in-memory limiters to the expvar metrics, next to the memstats expvar
+// publishes itself, so their growth can be followed between heap profiles
+func publishRuntimeMetrics() {
+	expvar.Publish("goroutines", expvar.Func(func() any { return runtime.NumGoroutine() }))
+	expvar.Publish("limiter_keys", expvar.Func(func() any {
+		keys := map[string]int{}
+		if rateLimiter != nil {
+			keys["rateLimit"] = rateLimiter.Keys()
+		}
+		if captchaAllowance != nil {
+			keys["captcha"] = captchaAllowance.Keys()
+		}
+		if detector := fraudDetector.Load(); detector != nil {
+			keys["clickFraudIPs"], keys["clickFraudLinks"] = detector.Keys()
+		}
+		return keys
+	}))
+}
@@ -19,7 +19,7 @@ const docTemplate = `{
     "host": "{{.Host}}",
     "basePath": "{{.BasePath}}",
     "paths": {
-        "/urls": {
+        "/api/v1/urls": {
             "get": {
                 "description": "Returns a list of all shortened URLs created within the last 7 days",
                 "produces": [
@@ -30,6 +30,47 @@ const docTemplate = `{
                 ],
                 "summary": "Get all shortened URLs",
                 "operationId": "getAllShortURLs",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "List the links of this organization (members only) instead of personal links",
+                        "name": "orgId",
+                        "in": "query",
+                        "required": false
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only list links created with this utm_campaign",
+                        "name": "campaign",
+                        "in": "query",
+                        "required": false
+                    },
+                    {
+                        "type": "string",
+                        "enum": [
+                            "broken",
+                            "ok"
+                        ],
+                        "description": "Only list links whose destination failed (broken) or passed (ok) its latest health check",
+                        "name": "health",
+                        "in": "query",
+                        "required": false
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only list links whose metadata has this value for key, e.g. metadata.ticket=OPS-42; numbers and booleans match their text. Can be given for several keys.",
+                        "name": "metadata.{key}",
+                        "in": "query",
+                        "required": false
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag from a previous response",
+                        "name": "If-None-Match",
+                        "in": "header",
+                        "required": false
+                    }
+                ],
                 "responses": {
                     "200": {
                         "description": "Successful operation",
@@ -38,6 +79,27 @@ const docTemplate = `{
                             "items": {
                                 "$ref": "#/definitions/URL"
                             }
+                        },
+                        "headers": {
+                            "ETag": {
+                                "type": "string",
+                                "description": "Weak entity tag of the listing"
+                            }
+                        }
+                    },
+                    "304": {
+                        "description": "Listing unchanged since the supplied ETag"
+                    },
+                    "400": {
+                        "description": "Invalid health filter",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Not a member of the organization",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
                         }
                     },
                     "500": {
@@ -62,6 +124,13 @@ const docTemplate = `{
                 "summary": "Create a new short URL",
                 "operationId": "createShortURL",
                 "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Solved hCaptcha/Turnstile token, required from anonymous clients over the CAPTCHA_THRESHOLD when CAPTCHA is enabled",
+                        "name": "X-Captcha-Token",
+                        "in": "header",
+                        "required": false
+                    },
                     {
                         "description": "URL to be shortened",
                         "name": "body",
@@ -73,9 +142,95 @@ const docTemplate = `{
                                 "url"
                             ],
                             "properties": {
+                                "domain": {
+                                    "description": "Custom domain the link is served from",
+                                    "type": "string",
+                                    "example": "sho.rt"
+                                },
+                                "forwardPath": {
+                                    "description": "Forward the path after the short code to the destination, replacing a \"*\" in it or appending to it",
+                                    "type": "boolean"
+                                },
+                                "forwardQuery": {
+                                    "description": "Append the short link's query string (e.g. UTM or affiliate parameters) to the destination on redirect. Parameters already on the destination are kept.",
+                                    "type": "boolean"
+                                },
+                                "frame": {
+                                    "description": "Show the destination in a full-page iframe on the short domain instead of redirecting. The destination must be an https URL on a host listed in FRAME_ALLOWED_HOSTS; when it no longer is, visitors are redirected as usual.",
+                                    "type": "boolean"
+                                },
+                                "metadata": {
+                                    "description": "JSON object integrations keep with the link, e.g. ticket IDs, at most 4096 bytes. On update it replaces the previous metadata; null clears it.",
+                                    "type": "object"
+                                },
+                                "notes": {
+                                    "description": "Free-form notes kept with the link, at most 2000 characters",
+                                    "type": "string"
+                                },
+                                "openGraph": {
+                                    "$ref": "#/definitions/OpenGraph"
+                                },
+                                "orgId": {
+                                    "description": "Organization the link belongs to; requires the owner or editor role",
+                                    "type": "integer"
+                                },
+                                "publicStats": {
+                                    "description": "Serve a public stats page for the link at /{shortCode}/stats",
+                                    "type": "boolean"
+                                },
+                                "shortCode": {
+                                    "description": "Custom short code of up to 64 letters, digits, \"-\" or \"_\", unique on the link's domain; one is generated when omitted",
+                                    "type": "string",
+                                    "example": "sale"
+                                },
+                                "slugStyle": {
+                                    "description": "How the code is generated when shortCode is omitted. readable derives it from the destination's title and path, e.g. spring-sale-2025, adding a number when taken; base62 (the default) uses a random code. Cannot be combined with shortCode.",
+                                    "type": "string",
+                                    "enum": [
+                                        "base62",
+                                        "readable"
+                                    ]
+                                },
+                                "tags": {
+                                    "type": "array",
+                                    "items": {
+                                        "type": "string"
+                                    }
+                                },
+                                "targets": {
+                                    "$ref": "#/definitions/Targets"
+                                },
+                                "trackConversions": {
+                                    "description": "Pass each click's ID to the destination in the clid query parameter (CLICK_ID_PARAM when set), which the destination site reports conversions with at POST /api/v1/conversions",
+                                    "type": "boolean"
+                                },
                                 "url": {
                                     "type": "string",
                                     "example": "https://example.com/very/long/url/path"
+                                },
+                                "utm": {
+                                    "description": "UTM tags appended to the destination, replacing any it already has. The campaign is stored on the link for filtering listings and click events.",
+                                    "type": "object",
+                                    "properties": {
+                                        "campaign": {
+                                            "type": "string",
+                                            "example": "spring-launch"
+                                        },
+                                        "content": {
+                                            "type": "string"
+                                        },
+                                        "medium": {
+                                            "type": "string",
+                                            "example": "email"
+                                        },
+                                        "source": {
+                                            "type": "string",
+                                            "example": "newsletter"
+                                        },
+                                        "term": {
+                                            "type": "string"
+                                        }
+                                    }
                                 }
                             }
                         }
@@ -89,7 +244,37 @@ const docTemplate = `{
                         }
                     },
                     "400": {
-                        "description": "Invalid request body",
+                        "description": "Invalid request body, URL, short code, domain or target",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Authentication required",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Insufficient role, not allowed to create links in the organization, the short code is under a prefix another organization reserved, or CAPTCHA verification required",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "402": {
+                        "description": "The plan of the user, or of the organization, allows no more links (total or this month), or with billing enabled does not include custom domains; details give the quota, plan, limit and usage, or the feature",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "The short code is already taken on the domain",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "422": {
+                        "description": "Destination is on the blocklist or too long, or can't be shown in frame mode",
                         "schema": {
                             "$ref": "#/definitions/ErrorResponse"
                         }
@@ -105,7 +290,7 @@ const docTemplate = `{
         },
         "/urls/{shortCode}": {
             "get": {
-                "description": "Redirects to the original URL associated with the short code",
+                "description": "Redirects to the original URL associated with the short code on the custom domain the request was sent to, or with the code on the default base URL when that domain has no such link",
                 "tags": [
                     "urls"
                 ],
@@ -121,37 +306,84 @@ const docTemplate = `{
                     }
                 ],
                 "responses": {
+                    "200": {
+                        "description": "Page showing the destination in a full-page iframe, for links in frame mode, or firing the link's retargeting pixels before forwarding to it"
+                    },
                     "302": {
                         "description": "Redirect to original URL"
                     },
                     "404": {
                         "description": "Short URL not found"
+                    },
+                    "410": {
+                        "description": "Link has been disabled"
                     }
                 }
             },
-            "put": {
-                "description": "Updates the original URL for an existing short code",
-                "consumes": [
-                    "application/json"
+            "head": {
+                "description": "Returns the redirect status and Location header without counting a click",
+                "tags": [
+                    "urls"
                 ],
-                "produces": [
-                    "application/json"
+                "summary": "Resolve short URL headers",
+                "operationId": "headOriginalURL",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Short code of the URL",
+                        "name": "shortCode",
+                        "in": "path",
+                        "required": true
+                    }
                 ],
+                "responses": {
+                    "302": {
+                        "description": "Redirect to original URL"
+                    },
+                    "404": {
+                        "description": "Short URL not found"
+                    }
+                }
+            },
+            "options": {
+                "description": "Returns the Allow and CORS headers for the short URL route",
                 "tags": [
                     "urls"
                 ],
-                "summary": "Update a short URL",
-                "operationId": "updateShortURL",
+                "summary": "Supported methods",
+                "operationId": "optionsShortURL",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "Short code of the URL to update",
+                        "description": "Short code of the URL",
                         "name": "shortCode",
                         "in": "path",
                         "required": true
-                    },
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "Allowed methods listed in the Allow header"
+                    }
+                }
+            }
+        },
+        "/api/v1/urls/suggest": {
+            "post": {
+                "description": "Proposes up to five human-readable codes for a destination, e.g. spring-sale-2025, derived from its page title (when PAGE_META_ENABLED is on) and its URL. Every suggestion is free on the domain; a taken code is replaced by a numbered variant such as spring-sale-2. Pass the chosen code as shortCode when creating the link.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "urls"
+                ],
+                "summary": "Suggest readable short codes",
+                "operationId": "suggestShortCodes",
+                "parameters": [
                     {
-                        "description": "New URL",
                         "name": "body",
                         "in": "body",
                         "required": true,
@@ -161,9 +393,17 @@ const docTemplate = `{
                                 "url"
                             ],
                             "properties": {
+                                "domain": {
+                                    "description": "Custom domain the link will be created on",
+                                    "type": "string"
+                                },
+                                "orgId": {
+                                    "description": "Organization the link will belong to; codes under its reserved prefixes are suggested too",
+                                    "type": "integer"
+                                },
                                 "url": {
-                                    "type": "string",
-                                    "example": "https://example.com/new/url/path"
+                                    "description": "Destination of the link to be created",
+                                    "type": "string"
                                 }
                             }
                         }
@@ -171,63 +411,190 @@ const docTemplate = `{
                 ],
                 "responses": {
                     "200": {
-                        "description": "URL updated successfully",
+                        "description": "Suggested codes, best first",
                         "schema": {
                             "type": "object",
                             "properties": {
-                                "message": {
+                                "suggestions": {
+                                    "type": "array",
+                                    "items": {
+                                        "type": "string"
+                                    }
+                                },
+                                "title": {
+                                    "description": "Title of the destination page, empty when it wasn't fetched",
                                     "type": "string"
                                 }
                             }
                         }
                     },
                     "400": {
-                        "description": "Invalid request body",
+                        "description": "Missing or invalid url or domain",
                         "schema": {
                             "$ref": "#/definitions/ErrorResponse"
                         }
                     },
-                    "404": {
-                        "description": "Short URL not found",
+                    "422": {
+                        "description": "Destination is on the blocklist or too long",
                         "schema": {
                             "$ref": "#/definitions/ErrorResponse"
                         }
                     }
                 }
-            },
-            "delete": {
-                "description": "Deletes a shortened URL by its short code",
+            }
+        },
+        "/api/v1/urls/batch": {
+            "patch": {
+                "description": "Applies the same changes to links picked by short code or by a tag or campaign filter, in one transaction. Each link is checked like a single-link update; links the caller may not change, locked links and unknown short codes are left out and reported in the per-link results.",
+                "consumes": [
+                    "application/json"
+                ],
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
                     "urls"
                 ],
-                "summary": "Delete a short URL",
-                "operationId": "deleteShortURL",
+                "summary": "Update many short URLs",
+                "operationId": "batchUpdateURLs",
                 "parameters": [
                     {
-                        "type": "string",
-                        "description": "Short code of the URL to delete",
-                        "name": "shortCode",
-                        "in": "path",
-                        "required": true
+                        "name": "body",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "type": "object",
+                            "properties": {
+                                "disabled": {
+                                    "description": "Stop the links from redirecting, or let disabled links redirect again",
+                                    "type": "boolean"
+                                },
+                                "disabledReason": {
+                                    "description": "Reason shown for links disabled with disabled=true",
+                                    "type": "string"
+                                },
+                                "filter": {
+                                    "description": "Picks every link of the domain with the tag and/or in the campaign instead; at most 1000 links may match",
+                                    "type": "object",
+                                    "properties": {
+                                        "campaignId": {
+                                            "type": "integer"
+                                        },
+                                        "tag": {
+                                            "type": "string"
+                                        }
+                                    }
+                                },
+                                "forwardPath": {
+                                    "type": "boolean"
+                                },
+                                "forwardQuery": {
+                                    "type": "boolean"
+                                },
+                                "metadata": {
+                                    "description": "Replaces the metadata of every link; null clears it",
+                                    "type": "object"
+                                },
+                                "notes": {
+                                    "type": "string"
+                                },
+                                "publicStats": {
+                                    "type": "boolean"
+                                },
+                                "shortCodes": {
+                                    "description": "Short codes of the links on the domain given by ?domain=, at most 1000",
+                                    "type": "array",
+                                    "items": {
+                                        "type": "string"
+                                    }
+                                },
+                                "trackConversions": {
+                                    "type": "boolean"
+                                }
+                            }
+                        }
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "URL deleted successfully",
+                        "description": "Per-link results",
+                        "schema": {
+                            "$ref": "#/definitions/BatchResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request body, missing selection or too many links",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Campaign not found",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "description": "Deletes links picked by short code or by a tag or campaign filter, e.g. every link of a finished campaign, in one transaction. Each link is checked like a single-link delete; links the caller may not delete, locked links and unknown short codes are kept and reported in the per-link results. With ARCHIVE_ON_DELETE, links that fail to archive are kept as well.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "urls"
+                ],
+                "summary": "Delete many short URLs",
+                "operationId": "batchDeleteURLs",
+                "parameters": [
+                    {
+                        "name": "body",
+                        "in": "body",
+                        "required": true,
                         "schema": {
                             "type": "object",
                             "properties": {
-                                "message": {
-                                    "type": "string"
+                                "filter": {
+                                    "description": "Picks every link of the domain with the tag and/or in the campaign instead; at most 1000 links may match",
+                                    "type": "object",
+                                    "properties": {
+                                        "campaignId": {
+                                            "type": "integer"
+                                        },
+                                        "tag": {
+                                            "type": "string"
+                                        }
+                                    }
+                                },
+                                "shortCodes": {
+                                    "description": "Short codes of the links on the domain given by ?domain=, at most 1000",
+                                    "type": "array",
+                                    "items": {
+                                        "type": "string"
+                                    }
                                 }
                             }
                         }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Per-link results",
+                        "schema": {
+                            "$ref": "#/definitions/BatchResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request body, missing selection or too many links",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
                     },
                     "404": {
-                        "description": "Short URL not found",
+                        "description": "Campaign not found",
                         "schema": {
                             "$ref": "#/definitions/ErrorResponse"
                         }
@@ -235,76 +602,8476 @@ const docTemplate = `{
                 }
             }
         },
-        "/urls/{shortCode}/stats": {
+        "/api/v1/urls/archived": {
             "get": {
-                "description": "Returns statistics for a shortened URL",
+                "description": "Lists links archived for inactivity, most recently archived first. Archived links are left out of the regular listing but keep redirecting.",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
                     "urls"
                 ],
-                "summary": "Get URL statistics",
-                "operationId": "getURLStats",
+                "summary": "Search archived URLs",
+                "operationId": "searchArchivedURLs",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "Short code of the URL",
-                        "name": "shortCode",
-                        "in": "path",
-                        "required": true
+                        "description": "Only list links whose short code, destination or title contains this text",
+                        "name": "q",
+                        "in": "query",
+                        "required": false
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Search the archived links of this organization (members only) instead of personal links",
+                        "name": "orgId",
+                        "in": "query",
+                        "required": false
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Maximum number of links (default 100, at most 1000)",
+                        "name": "limit",
+                        "in": "query",
+                        "required": false
                     }
                 ],
                 "responses": {
                     "200": {
                         "description": "Successful operation",
                         "schema": {
-                            "$ref": "#/definitions/URL"
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/URL"
+                            }
                         }
                     },
-                    "404": {
-                        "description": "Short URL not found",
+                    "400": {
+                        "description": "Invalid limit",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Not a member of the organization",
                         "schema": {
                             "$ref": "#/definitions/ErrorResponse"
                         }
                     }
                 }
             }
-        }
-    },
-    "definitions": {
-        "URL": {
-            "type": "object",
-            "properties": {
-                "accessCount": {
-                    "type": "integer",
-                    "format": "int64"
-                },
-                "createdAt": {
-                    "type": "string",
-                    "format": "date-time"
-                },
-                "id": {
-                    "type": "integer",
-                    "format": "int64"
-                },
-                "original": {
-                    "type": "string"
-                },
-                "shortCode": {
-                    "type": "string"
-                },
-                "updatedAt": {
-                    "type": "string",
-                    "format": "date-time"
-                }
-            }
         },
-        "ErrorResponse": {
-            "type": "object",
-            "properties": {
-                "error": {
-                    "type": "string"
+        "/api/v1/urls/{shortCode}": {
+            "put": {
+                "description": "Updates the original URL for an existing short code",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "urls"
+                ],
+                "summary": "Update a short URL",
+                "operationId": "updateShortURL",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Short code of the URL to update",
+                        "name": "shortCode",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Management token returned when the link was created (or the admin token as a bearer token)",
+                        "name": "X-Management-Token",
+                        "in": "header",
+                        "required": false
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Expiry of a URL signed for edit, as issued by /api/v1/urls/{shortCode}/signed-urls",
+                        "name": "expires",
+                        "in": "query",
+                        "required": false
+                    },
+                    {
+                        "type": "string",
+                        "description": "Signature of a URL signed for edit, accepted instead of the management token",
+                        "name": "signature",
+                        "in": "query",
+                        "required": false
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag of the link from a previous PUT or PATCH response; the update fails with 409 if the link changed since",
+                        "name": "If-Match",
+                        "in": "header",
+                        "required": false
+                    },
+                    {
+                        "description": "New URL and/or link options. The url may be omitted when only forwarding options change.",
+                        "name": "body",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "type": "object",
+                            "properties": {
+                                "forwardPath": {
+                                    "description": "Forward the path after the short code to the destination",
+                                    "type": "boolean"
+                                },
+                                "forwardQuery": {
+                                    "description": "Append the short link's query string to the destination on redirect",
+                                    "type": "boolean"
+                                },
+                                "frame": {
+                                    "description": "Show the destination in a full-page iframe on the short domain instead of redirecting. The destination must be an https URL on a host listed in FRAME_ALLOWED_HOSTS; when it no longer is, visitors are redirected as usual.",
+                                    "type": "boolean"
+                                },
+                                "metadata": {
+                                    "description": "JSON object integrations keep with the link, e.g. ticket IDs, at most 4096 bytes. On update it replaces the previous metadata; null clears it.",
+                                    "type": "object"
+                                },
+                                "notes": {
+                                    "description": "Free-form notes kept with the link, at most 2000 characters",
+                                    "type": "string"
+                                },
+                                "publicStats": {
+                                    "description": "Serve a public stats page for the link at /{shortCode}/stats",
+                                    "type": "boolean"
+                                },
+                                "trackConversions": {
+                                    "description": "Pass each click's ID to the destination in the clid query parameter (CLICK_ID_PARAM when set), which the destination site reports conversions with at POST /api/v1/conversions",
+                                    "type": "boolean"
+                                },
+                                "updatedAt": {
+                                    "description": "The link's updatedAt as last read; the update fails with 409 if the link changed since",
+                                    "type": "string",
+                                    "format": "date-time"
+                                },
+                                "url": {
+                                    "type": "string",
+                                    "example": "https://example.com/new/url/path"
+                                }
+                            }
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "URL updated successfully",
+                        "schema": {
+                            "type": "object",
+                            "properties": {
+                                "message": {
+                                    "type": "string"
+                                }
+                            }
+                        },
+                        "headers": {
+                            "ETag": {
+                                "type": "string",
+                                "description": "Entity tag of the updated link, for If-Match on the next update"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request body",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Management token required",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Invalid management token",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Short URL not found",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "The link was changed since the If-Match ETag or updatedAt the client sent",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "422": {
+                        "description": "Destination is on the blocklist or too long, or can't be shown in frame mode",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "423": {
+                        "description": "Short URL is locked",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    }
+                }
+            },
+            "patch": {
+                "description": "Changes only the fields present in the body, a JSON merge patch (RFC 7396), so fields the client didn't send keep their values. Null resets a field, i.e. false for options and empty for notes and tags. Metadata is merged into the link's metadata, with null members removing keys. Read-only and unknown fields are rejected. Returns the updated link.",
+                "consumes": [
+                    "application/merge-patch+json",
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "urls"
+                ],
+                "summary": "Partially update a short URL",
+                "operationId": "patchShortURL",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Short code of the URL to update",
+                        "name": "shortCode",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Management token returned when the link was created (or the admin token as a bearer token)",
+                        "name": "X-Management-Token",
+                        "in": "header",
+                        "required": false
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag of the link from a previous PUT or PATCH response; the update fails with 409 if the link changed since",
+                        "name": "If-Match",
+                        "in": "header",
+                        "required": false
+                    },
+                    {
+                        "name": "body",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "type": "object",
+                            "properties": {
+                                "disabled": {
+                                    "description": "Stop the link from redirecting, or let it redirect again",
+                                    "type": "boolean"
+                                },
+                                "disabledReason": {
+                                    "description": "Reason shown for a disabled link",
+                                    "type": "string"
+                                },
+                                "forwardPath": {
+                                    "type": "boolean"
+                                },
+                                "forwardQuery": {
+                                    "type": "boolean"
+                                },
+                                "frame": {
+                                    "type": "boolean"
+                                },
+                                "metadata": {
+                                    "description": "Merged into the link's metadata",
+                                    "type": "object"
+                                },
+                                "notes": {
+                                    "type": "string"
+                                },
+                                "publicStats": {
+                                    "type": "boolean"
+                                },
+                                "tags": {
+                                    "type": "array",
+                                    "items": {
+                                        "type": "string"
+                                    }
+                                },
+                                "trackConversions": {
+                                    "type": "boolean"
+                                },
+                                "updatedAt": {
+                                    "description": "Not changed, but checked like If-Match against the link's updatedAt as last read",
+                                    "type": "string",
+                                    "format": "date-time"
+                                },
+                                "url": {
+                                    "description": "New destination, an http or https URL",
+                                    "type": "string"
+                                }
+                            }
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "The updated link",
+                        "schema": {
+                            "$ref": "#/definitions/URL"
+                        },
+                        "headers": {
+                            "ETag": {
+                                "type": "string",
+                                "description": "Entity tag of the updated link, for If-Match on the next update"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid body, or an unknown, read-only or invalid field",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Management token required",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Invalid management token",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Short URL not found",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "The link was changed since the If-Match ETag or updatedAt the client sent",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "422": {
+                        "description": "Destination is on the blocklist or too long, or can't be shown in frame mode",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "423": {
+                        "description": "Short URL is locked",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "description": "Deletes a shortened URL by its short code",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "urls"
+                ],
+                "summary": "Delete a short URL",
+                "operationId": "deleteShortURL",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Short code of the URL to delete",
+                        "name": "shortCode",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Management token returned when the link was created (or the admin token as a bearer token)",
+                        "name": "X-Management-Token",
+                        "in": "header",
+                        "required": false
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "URL deleted successfully",
+                        "schema": {
+                            "type": "object",
+                            "properties": {
+                                "message": {
+                                    "type": "string"
+                                }
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Management token required",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Invalid management token",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Short URL not found",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "423": {
+                        "description": "Short URL is locked",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/urls/{shortCode}/stats": {
+            "get": {
+                "description": "Returns statistics for a shortened URL, including human clicks broken down by device type, browser and OS",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "urls"
+                ],
+                "summary": "Get URL statistics",
+                "operationId": "getURLStats",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Short code of the URL",
+                        "name": "shortCode",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Management token returned when the link was created (or the admin token as a bearer token)",
+                        "name": "X-Management-Token",
+                        "in": "header",
+                        "required": false
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Expiry of a URL signed for stats, as issued by /api/v1/urls/{shortCode}/signed-urls",
+                        "name": "expires",
+                        "in": "query",
+                        "required": false
+                    },
+                    {
+                        "type": "string",
+                        "description": "Signature of a URL signed for stats, accepted instead of the management token",
+                        "name": "signature",
+                        "in": "query",
+                        "required": false
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag from a previous response",
+                        "name": "If-None-Match",
+                        "in": "header",
+                        "required": false
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Successful operation",
+                        "schema": {
+                            "$ref": "#/definitions/URLStats"
+                        },
+                        "headers": {
+                            "ETag": {
+                                "type": "string",
+                                "description": "Weak entity tag of the stats"
+                            }
+                        }
+                    },
+                    "304": {
+                        "description": "Stats unchanged since the supplied ETag"
+                    },
+                    "401": {
+                        "description": "Management token required",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Invalid management token",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Short URL not found",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/urls/{shortCode}/stats/stream": {
+            "get": {
+                "description": "Server-Sent Events stream. Emits a ` + "`" + `url.clicked` + "`" + ` event (an event envelope) for each redirect served by this instance and a ` + "`" + `stats` + "`" + ` event with accessCount, botClicks and lastMinute counters on connect and every few seconds.",
+                "produces": [
+                    "text/event-stream"
+                ],
+                "tags": [
+                    "urls"
+                ],
+                "summary": "Stream live URL statistics",
+                "operationId": "streamURLStats",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Short code of the URL",
+                        "name": "shortCode",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Management token returned when the link was created (or the admin token as a bearer token)",
+                        "name": "X-Management-Token",
+                        "in": "header",
+                        "required": false
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Expiry of a URL signed for stats, as issued by /api/v1/urls/{shortCode}/signed-urls",
+                        "name": "expires",
+                        "in": "query",
+                        "required": false
+                    },
+                    {
+                        "type": "string",
+                        "description": "Signature of a URL signed for stats, accepted instead of the management token",
+                        "name": "signature",
+                        "in": "query",
+                        "required": false
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Event stream"
+                    },
+                    "401": {
+                        "description": "Management token required",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Invalid management token",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Short URL not found",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/urls/{shortCode}/stats/reset": {
+            "post": {
+                "description": "Without a body, zeroes the link's click counts and deletes everything behind its stats (click events, rollups, imported clicks, anomalies, unique visitors, A/B test and rotation counts), e.g. after test traffic before a launch. With clicks or uniqueClicks, subtracts those amounts from the totals instead, stopping at zero and leaving breakdowns and time series alone. Both are recorded in the audit log with the counts before and after.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "urls"
+                ],
+                "summary": "Reset or adjust a link's stats",
+                "operationId": "resetURLStats",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Short code of the URL",
+                        "name": "shortCode",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Management token returned when the link was created (or the admin token as a bearer token)",
+                        "name": "X-Management-Token",
+                        "in": "header",
+                        "required": false
+                    },
+                    {
+                        "name": "body",
+                        "in": "body",
+                        "required": false,
+                        "schema": {
+                            "type": "object",
+                            "properties": {
+                                "clicks": {
+                                    "description": "Clicks to subtract from accessCount",
+                                    "type": "integer",
+                                    "example": 120
+                                },
+                                "reason": {
+                                    "description": "Why the stats were corrected, kept in the audit log",
+                                    "type": "string",
+                                    "example": "Pre-launch QA traffic"
+                                },
+                                "uniqueClicks": {
+                                    "description": "Unique clicks to subtract",
+                                    "type": "integer"
+                                }
+                            }
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Stats reset or adjusted; after is only present for adjustments",
+                        "schema": {
+                            "type": "object",
+                            "properties": {
+                                "after": {
+                                    "$ref": "#/definitions/ClickCounts"
+                                },
+                                "before": {
+                                    "$ref": "#/definitions/ClickCounts"
+                                },
+                                "message": {
+                                    "type": "string"
+                                }
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request body or amounts",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Management token required",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Invalid management token",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Short URL not found",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/urls/{shortCode}/stats/timeseries": {
+            "get": {
+                "description": "Clicks per hour, day or week (UTC, weeks starting on Monday) over a range widened to whole buckets, with empty buckets listed as zero. Counts are read from hourly rollups updated about once a minute, so the most recent clicks may not be included yet.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "urls"
+                ],
+                "summary": "Get a click time series",
+                "operationId": "getURLTimeSeries",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Short code of the URL",
+                        "name": "shortCode",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "default": "day",
+                        "enum": [
+                            "hour",
+                            "day",
+                            "week"
+                        ],
+                        "description": "Bucket size",
+                        "name": "interval",
+                        "in": "query",
+                        "required": false
+                    },
+                    {
+                        "type": "string",
+                        "description": "Start of the range, an RFC 3339 timestamp or a date (default 24 hours, 30 days or 12 weeks before to)",
+                        "name": "from",
+                        "in": "query",
+                        "required": false
+                    },
+                    {
+                        "type": "string",
+                        "description": "End of the range, an RFC 3339 timestamp or a date (default now)",
+                        "name": "to",
+                        "in": "query",
+                        "required": false
+                    },
+                    {
+                        "type": "string",
+                        "description": "Management token returned when the link was created (or the admin token as a bearer token)",
+                        "name": "X-Management-Token",
+                        "in": "header",
+                        "required": false
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Expiry of a URL signed for stats, as issued by /api/v1/urls/{shortCode}/signed-urls",
+                        "name": "expires",
+                        "in": "query",
+                        "required": false
+                    },
+                    {
+                        "type": "string",
+                        "description": "Signature of a URL signed for stats, accepted instead of the management token",
+                        "name": "signature",
+                        "in": "query",
+                        "required": false
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Click time series",
+                        "schema": {
+                            "$ref": "#/definitions/TimeSeries"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid interval or range, or more than 1000 buckets",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Management token required",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Invalid management token",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Short URL not found",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/urls/{shortCode}/history": {
+            "get": {
+                "description": "Lists every destination the link has pointed to, newest first. The first entry is the current destination.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "urls"
+                ],
+                "summary": "Get destination history",
+                "operationId": "getURLHistory",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Short code of the URL",
+                        "name": "shortCode",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Management token returned when the link was created (or the admin token as a bearer token)",
+                        "name": "X-Management-Token",
+                        "in": "header",
+                        "required": false
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Successful operation",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/URLVersion"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Management token required",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Invalid management token",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Short URL not found",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/urls/{shortCode}/rollback/{versionId}": {
+            "post": {
+                "description": "Points the link at the destination of an earlier version. The rollback is recorded as a new version.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "urls"
+                ],
+                "summary": "Roll back to an earlier destination",
+                "operationId": "rollbackURL",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Short code of the URL",
+                        "name": "shortCode",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "format": "int64",
+                        "description": "ID of the version to restore, from the history",
+                        "name": "versionId",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Management token returned when the link was created (or the admin token as a bearer token)",
+                        "name": "X-Management-Token",
+                        "in": "header",
+                        "required": false
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "URL rolled back",
+                        "schema": {
+                            "type": "object",
+                            "properties": {
+                                "message": {
+                                    "type": "string"
+                                },
+                                "original": {
+                                    "type": "string"
+                                }
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Management token required",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Invalid management token",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Short URL or version not found",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "422": {
+                        "description": "Destination is on the blocklist or too long",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "423": {
+                        "description": "Short URL is locked",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/urls/{shortCode}/unarchive": {
+            "post": {
+                "description": "Lists an archived link again. Its inactivity is counted afresh from now, so the policy does not archive it again right away.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "urls"
+                ],
+                "summary": "Unarchive a short URL",
+                "operationId": "unarchiveShortURL",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Short code of the URL",
+                        "name": "shortCode",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Management token returned when the link was created (or the admin token as a bearer token)",
+                        "name": "X-Management-Token",
+                        "in": "header",
+                        "required": false
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "URL unarchived successfully",
+                        "schema": {
+                            "$ref": "#/definitions/MessageResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Management token required",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Invalid management token",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Short URL not found",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Short URL is not archived",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "423": {
+                        "description": "Short URL is locked",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/urls/{shortCode}/transfer": {
+            "post": {
+                "description": "Offers the link to another user or organization, e.g. when its creator leaves. Ownership moves once the recipient accepts at /api/v1/transfers/{id}/accept. Links of an organization can only be transferred by its owners. Requires a signed-in user or admin rights.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "urls"
+                ],
+                "summary": "Transfer a short URL",
+                "operationId": "transferURL",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Short code of the URL",
+                        "name": "shortCode",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Management token returned when the link was created (or the admin token as a bearer token)",
+                        "name": "X-Management-Token",
+                        "in": "header",
+                        "required": false
+                    },
+                    {
+                        "name": "body",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/TransferRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Transfer awaiting acceptance",
+                        "schema": {
+                            "$ref": "#/definitions/Transfer"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request body, or not exactly one recipient",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Authentication required",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Invalid management token, or not an owner of the link's organization",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Short URL or recipient organization not found",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "A transfer of the link is already awaiting acceptance",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/urls/{shortCode}/signed-urls": {
+            "post": {
+                "description": "Issues a URL that lets anyone holding it view the stats of, or edit, this one link until it expires, without an account or the management token, e.g. to email a customer their stats for a week. Stats URLs open the link's stats page at /{shortCode}/stats and also work on the stats API routes; edit URLs are for PUT /api/v1/urls/{shortCode}. Signed URLs stop working when the link's management token changes, e.g. after a transfer. Only available when SIGNED_URL_SECRET is set.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "urls"
+                ],
+                "summary": "Create a signed URL",
+                "operationId": "createSignedURL",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Short code of the URL",
+                        "name": "shortCode",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Management token returned when the link was created (or the admin token as a bearer token)",
+                        "name": "X-Management-Token",
+                        "in": "header",
+                        "required": false
+                    },
+                    {
+                        "name": "body",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "type": "object",
+                            "required": [
+                                "action"
+                            ],
+                            "properties": {
+                                "action": {
+                                    "type": "string",
+                                    "enum": [
+                                        "stats",
+                                        "edit"
+                                    ]
+                                },
+                                "expiresIn": {
+                                    "description": "Seconds until the URL expires, at most 90 days (default 7 days)",
+                                    "type": "integer",
+                                    "example": 604800
+                                }
+                            }
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Signed URL",
+                        "schema": {
+                            "type": "object",
+                            "properties": {
+                                "action": {
+                                    "type": "string"
+                                },
+                                "expiresAt": {
+                                    "type": "string",
+                                    "format": "date-time"
+                                },
+                                "method": {
+                                    "description": "HTTP method the URL is used with",
+                                    "type": "string",
+                                    "example": "GET"
+                                },
+                                "url": {
+                                    "type": "string",
+                                    "example": "https://sho.rt/abc123/stats?expires=1767225600&signature=Vf1x..."
+                                }
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid action or expiry",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Management token required",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Invalid management token",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Short URL not found, or signed URLs are not enabled",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/urls/{shortCode}/targets": {
+            "put": {
+                "description": "Replaces the link's per-platform destinations. Visitors whose User-Agent matches a platform are sent to its destination instead of the original URL; ios and android are matched before mobile, tablet and desktop. An empty object removes all targets.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "urls"
+                ],
+                "summary": "Set device-specific destinations",
+                "operationId": "setURLTargets",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Short code of the URL",
+                        "name": "shortCode",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Management token returned when the link was created (or the admin token as a bearer token)",
+                        "name": "X-Management-Token",
+                        "in": "header",
+                        "required": false
+                    },
+                    {
+                        "description": "Destination per platform",
+                        "name": "body",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/Targets"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Targets updated",
+                        "schema": {
+                            "type": "object",
+                            "properties": {
+                                "message": {
+                                    "type": "string"
+                                },
+                                "targets": {
+                                    "$ref": "#/definitions/Targets"
+                                }
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request body or target",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Management token required",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Invalid management token",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Short URL not found",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "422": {
+                        "description": "Destination is on the blocklist or too long",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "423": {
+                        "description": "Short URL is locked",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/urls/{shortCode}/variants": {
+            "put": {
+                "description": "Splits the link's traffic between weighted destinations. Each visitor is assigned a variant by cookie, or by a hash of their IP on first visit, and keeps it on later clicks. Platform targets take precedence over variants. An empty array ends the test.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "urls"
+                ],
+                "summary": "Set A/B test variants",
+                "operationId": "setURLVariants",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Short code of the URL",
+                        "name": "shortCode",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Management token returned when the link was created (or the admin token as a bearer token)",
+                        "name": "X-Management-Token",
+                        "in": "header",
+                        "required": false
+                    },
+                    {
+                        "description": "Variants in display order",
+                        "name": "body",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/Variant"
+                            }
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Variants updated",
+                        "schema": {
+                            "type": "object",
+                            "properties": {
+                                "message": {
+                                    "type": "string"
+                                },
+                                "variants": {
+                                    "type": "array",
+                                    "items": {
+                                        "$ref": "#/definitions/Variant"
+                                    }
+                                }
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request body or variant",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Management token required",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Invalid management token",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Short URL not found",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "422": {
+                        "description": "Destination is on the blocklist or too long",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "423": {
+                        "description": "Short URL is locked",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/urls/{shortCode}/rotation": {
+            "put": {
+                "description": "Cycles the link's clicks through several destinations, taking them in turn (round-robin) or picking one at random on each click, e.g. to spread signups over several booking pages. Crawlers and excluded clicks get a random destination without taking a turn. Platform targets take precedence over the rotation, and a link cannot be rotated while it is A/B tested. An empty destinations list ends the rotation.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "urls"
+                ],
+                "summary": "Set rotated destinations",
+                "operationId": "setURLRotation",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Short code of the URL",
+                        "name": "shortCode",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Management token returned when the link was created (or the admin token as a bearer token)",
+                        "name": "X-Management-Token",
+                        "in": "header",
+                        "required": false
+                    },
+                    {
+                        "name": "body",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/Rotation"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Rotation updated",
+                        "schema": {
+                            "type": "object",
+                            "properties": {
+                                "message": {
+                                    "type": "string"
+                                },
+                                "rotation": {
+                                    "$ref": "#/definitions/Rotation"
+                                }
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request body or rotation",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Management token required",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Invalid management token",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Short URL not found",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "The link is A/B tested",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "422": {
+                        "description": "Destination is on the blocklist or too long",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "423": {
+                        "description": "Short URL is locked",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/urls/{shortCode}/app-link": {
+            "put": {
+                "description": "Sends iOS and Android visitors to an app through its own URI scheme. They are served a short page that tries to open the app and, when it does not open within APP_LINK_FALLBACK_DELAY, goes on to the app store for their platform, or the link's destination when no store URL is set. Other visitors are redirected as usual. An empty uri removes the app link.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "urls"
+                ],
+                "summary": "Set the app link",
+                "operationId": "setURLAppLink",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Short code of the URL",
+                        "name": "shortCode",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Management token returned when the link was created (or the admin token as a bearer token)",
+                        "name": "X-Management-Token",
+                        "in": "header",
+                        "required": false
+                    },
+                    {
+                        "name": "body",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/AppLink"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "App link updated",
+                        "schema": {
+                            "type": "object",
+                            "properties": {
+                                "appLink": {
+                                    "$ref": "#/definitions/AppLink"
+                                },
+                                "message": {
+                                    "type": "string"
+                                }
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request body or app link",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Management token required",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Invalid management token",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Short URL not found",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "422": {
+                        "description": "Destination is on the blocklist or too long",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "423": {
+                        "description": "Short URL is locked",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/urls/{shortCode}/open-graph": {
+            "put": {
+                "description": "Overrides the title, description and image social networks and chat apps show when the link is shared. Their link unfurlers are served a page with the matching Open Graph and Twitter Card tags instead of a redirect; the page forwards anyone who opens it to the destination. Fields left out fall back to the title and description fetched from the destination page. An empty body removes the override.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "urls"
+                ],
+                "summary": "Set the share preview",
+                "operationId": "setURLOpenGraph",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Short code of the URL",
+                        "name": "shortCode",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Management token returned when the link was created (or the admin token as a bearer token)",
+                        "name": "X-Management-Token",
+                        "in": "header",
+                        "required": false
+                    },
+                    {
+                        "name": "body",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/OpenGraph"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Share preview updated",
+                        "schema": {
+                            "type": "object",
+                            "properties": {
+                                "message": {
+                                    "type": "string"
+                                },
+                                "openGraph": {
+                                    "$ref": "#/definitions/OpenGraph"
+                                }
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request body or metadata",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Management token required",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Invalid management token",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Short URL not found",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "423": {
+                        "description": "Short URL is locked",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/urls/{shortCode}/cache-ttl": {
+            "put": {
+                "description": "Sets how many seconds a CDN may cache the link's redirect, overriding REDIRECT_CACHE_TTL. Cached redirects carry Cache-Control, Surrogate-Control and a link-{shortCode} Surrogate-Key and Cache-Tag, which are purged through CDN_PROVIDER whenever the link's destination or redirect settings change. Redirects answered by the CDN are not counted as clicks. Links with platform targets, an A/B test, rotation, an app link, a share preview, frame mode or retargeting pixels are never cached. A ttl of 0 keeps the link from being cached and null restores the default.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "urls"
+                ],
+                "summary": "Set the CDN cache TTL",
+                "operationId": "setURLCacheTTL",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Short code of the URL",
+                        "name": "shortCode",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Management token returned when the link was created (or the admin token as a bearer token)",
+                        "name": "X-Management-Token",
+                        "in": "header",
+                        "required": false
+                    },
+                    {
+                        "name": "body",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "type": "object",
+                            "properties": {
+                                "ttl": {
+                                    "description": "Seconds, at most 604800; null for the instance default",
+                                    "type": "integer",
+                                    "example": 300
+                                }
+                            }
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Cache TTL updated",
+                        "schema": {
+                            "type": "object",
+                            "properties": {
+                                "cacheTtl": {
+                                    "type": "integer"
+                                },
+                                "message": {
+                                    "type": "string"
+                                }
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request body or ttl",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Management token required",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Invalid management token",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Short URL not found",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "423": {
+                        "description": "Short URL is locked",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/urls/{shortCode}/pixels": {
+            "put": {
+                "description": "Replaces the pixels fired before the link's redirect. Clicks then get an intermediate page running each pixel's script, which forwards the visitor to the destination after PIXEL_REDIRECT_DELAY, so paid-social teams can build retargeting audiences from link clicks. Pixels fire for human clicks only, not for crawlers, excluded clicks or visitors sending DNT or Sec-GPC. The pixels must belong to the link's organization.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "urls"
+                ],
+                "summary": "Set a link's retargeting pixels",
+                "operationId": "setURLPixels",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Short code of the URL",
+                        "name": "shortCode",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Management token returned when the link was created (or the admin token as a bearer token)",
+                        "name": "X-Management-Token",
+                        "in": "header",
+                        "required": false
+                    },
+                    {
+                        "name": "body",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "type": "object",
+                            "properties": {
+                                "pixels": {
+                                    "description": "IDs of up to 10 pixels; an empty list removes them",
+                                    "type": "array",
+                                    "items": {
+                                        "type": "integer"
+                                    },
+                                    "example": [
+                                        3,
+                                        7
+                                    ]
+                                }
+                            }
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Pixels updated",
+                        "schema": {
+                            "type": "object",
+                            "properties": {
+                                "message": {
+                                    "type": "string"
+                                },
+                                "pixels": {
+                                    "type": "array",
+                                    "items": {
+                                        "type": "integer"
+                                    }
+                                }
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request body, too many pixels, a pixel of another organization, or a link outside any organization",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Management token required",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Invalid management token",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Short URL not found",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "423": {
+                        "description": "Short URL is locked",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/urls/{shortCode}/conversions": {
+            "post": {
+                "description": "Counts a conversion for a variant of the link. Landing pages on another domain pass the variant in the body; otherwise the visitor's assignment cookie is used.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "urls"
+                ],
+                "summary": "Record an A/B conversion",
+                "operationId": "recordConversion",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Short code of the URL",
+                        "name": "shortCode",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "name": "body",
+                        "in": "body",
+                        "required": false,
+                        "schema": {
+                            "type": "object",
+                            "properties": {
+                                "variant": {
+                                    "type": "string"
+                                }
+                            }
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Conversion recorded",
+                        "schema": {
+                            "$ref": "#/definitions/MessageResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request body or no variant given",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Link has no such variant",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/conversions": {
+            "post": {
+                "description": "Called by destination sites of links with trackConversions to report that the visitor of a click converted, using the click ID received in the clid query parameter, or CLICK_ID_PARAM when set. A click converts at most once per goal, so retried reports are not counted twice. Click IDs are only known while the click is kept, so with CLICK_RETENTION_DAYS set, conversions must be reported within that period.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "urls"
+                ],
+                "summary": "Record a click conversion",
+                "operationId": "recordClickConversion",
+                "parameters": [
+                    {
+                        "name": "body",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "type": "object",
+                            "required": [
+                                "clickId"
+                            ],
+                            "properties": {
+                                "clickId": {
+                                    "description": "Click ID the destination received in its query",
+                                    "type": "string"
+                                },
+                                "name": {
+                                    "description": "Goal that was reached, e.g. signup or purchase; defaults to conversion",
+                                    "type": "string"
+                                },
+                                "value": {
+                                    "description": "Value of the conversion, e.g. the order total, summed in the stats",
+                                    "type": "number"
+                                }
+                            }
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "The click already converted for this goal",
+                        "schema": {
+                            "$ref": "#/definitions/MessageResponse"
+                        }
+                    },
+                    "201": {
+                        "description": "Conversion recorded",
+                        "schema": {
+                            "$ref": "#/definitions/MessageResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Missing click ID or invalid name or value",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Unknown click ID",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/urls/{shortCode}/lock": {
+            "post": {
+                "description": "Prevents the destination from being changed or the link deleted until it is unlocked. Requires the admin token.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Lock a short URL",
+                "operationId": "lockShortURL",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Short code of the URL",
+                        "name": "shortCode",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "name": "body",
+                        "in": "body",
+                        "required": false,
+                        "schema": {
+                            "type": "object",
+                            "properties": {
+                                "reason": {
+                                    "type": "string"
+                                }
+                            }
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "URL locked successfully",
+                        "schema": {
+                            "$ref": "#/definitions/MessageResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Invalid admin token",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Short URL not found",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/urls/{shortCode}/unlock": {
+            "post": {
+                "description": "First step of changing a locked link. A reason is required and recorded with the acting admin.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Unlock a short URL",
+                "operationId": "unlockShortURL",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Short code of the URL",
+                        "name": "shortCode",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "name": "body",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "type": "object",
+                            "required": [
+                                "reason"
+                            ],
+                            "properties": {
+                                "reason": {
+                                    "type": "string"
+                                }
+                            }
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "URL unlocked successfully",
+                        "schema": {
+                            "$ref": "#/definitions/MessageResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Missing reason",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Invalid admin token",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Short URL not found",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/urls/{shortCode}/metadata/refresh": {
+            "post": {
+                "description": "Fetches the destination page again and stores its title and meta description",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "urls"
+                ],
+                "summary": "Re-fetch destination page metadata",
+                "operationId": "refreshPageMetadata",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Short code of the URL",
+                        "name": "shortCode",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Management token returned when the link was created (or the admin token as a bearer token)",
+                        "name": "X-Management-Token",
+                        "in": "header",
+                        "required": false
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Metadata refreshed",
+                        "schema": {
+                            "$ref": "#/definitions/PageMetadata"
+                        }
+                    },
+                    "401": {
+                        "description": "Management token required",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Invalid management token, or destination disallows fetching via robots.txt",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Short URL not found",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "502": {
+                        "description": "Failed to fetch destination page",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "Page metadata fetching is disabled",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/tags": {
+            "get": {
+                "description": "Lists every tag in use with the number of links carrying it",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "tags"
+                ],
+                "summary": "List tags",
+                "operationId": "getTags",
+                "responses": {
+                    "200": {
+                        "description": "Successful operation",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/TagCount"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/tags/bulk": {
+            "post": {
+                "description": "Applies a tag change to links selected either by an explicit list of short codes or by a filter",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "tags"
+                ],
+                "summary": "Add or remove a tag on many links",
+                "operationId": "bulkTagLinks",
+                "parameters": [
+                    {
+                        "name": "body",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "type": "object",
+                            "required": [
+                                "action",
+                                "tag"
+                            ],
+                            "properties": {
+                                "action": {
+                                    "type": "string",
+                                    "enum": [
+                                        "add",
+                                        "remove"
+                                    ]
+                                },
+                                "filter": {
+                                    "type": "object",
+                                    "properties": {
+                                        "originalContains": {
+                                            "type": "string"
+                                        },
+                                        "tag": {
+                                            "type": "string"
+                                        }
+                                    }
+                                },
+                                "shortCodes": {
+                                    "type": "array",
+                                    "items": {
+                                        "type": "string"
+                                    }
+                                },
+                                "tag": {
+                                    "type": "string"
+                                }
+                            }
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Tags updated",
+                        "schema": {
+                            "type": "object",
+                            "properties": {
+                                "action": {
+                                    "type": "string"
+                                },
+                                "affected": {
+                                    "type": "integer"
+                                },
+                                "tag": {
+                                    "type": "string"
+                                }
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request body or missing selector",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/tags/{tag}/rename": {
+            "post": {
+                "description": "Renames a tag on every link. Renaming onto an existing tag merges the two.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "tags"
+                ],
+                "summary": "Rename or merge a tag",
+                "operationId": "renameTag",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "name": "tag",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "name": "body",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "type": "object",
+                            "required": [
+                                "to"
+                            ],
+                            "properties": {
+                                "to": {
+                                    "type": "string"
+                                }
+                            }
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Tag renamed",
+                        "schema": {
+                            "type": "object",
+                            "properties": {
+                                "affected": {
+                                    "type": "integer"
+                                },
+                                "from": {
+                                    "type": "string"
+                                },
+                                "to": {
+                                    "type": "string"
+                                }
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request body",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/campaigns": {
+            "get": {
+                "description": "Lists the signed-in user's campaigns; admins see every campaign",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "campaigns"
+                ],
+                "summary": "List campaigns",
+                "operationId": "getCampaigns",
+                "responses": {
+                    "200": {
+                        "description": "Successful operation",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/Campaign"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Authentication required",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "Creates an empty campaign owned by the signed-in user. Attach links to it to report on and expire them together.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "campaigns"
+                ],
+                "summary": "Create a campaign",
+                "operationId": "createCampaign",
+                "parameters": [
+                    {
+                        "name": "body",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "type": "object",
+                            "required": [
+                                "name"
+                            ],
+                            "properties": {
+                                "name": {
+                                    "type": "string"
+                                }
+                            }
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Campaign created",
+                        "schema": {
+                            "$ref": "#/definitions/Campaign"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request body",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Authentication required",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/campaigns/{id}": {
+            "delete": {
+                "description": "The campaign's links keep working and are detached from it",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "campaigns"
+                ],
+                "summary": "Delete a campaign",
+                "operationId": "deleteCampaign",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Campaign deleted",
+                        "schema": {
+                            "$ref": "#/definitions/MessageResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Authentication required",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Campaign belongs to another user",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Campaign not found",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/campaigns/{id}/urls": {
+            "get": {
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "campaigns"
+                ],
+                "summary": "List a campaign's links",
+                "operationId": "getCampaignURLs",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Successful operation",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/URL"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Authentication required",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Campaign belongs to another user",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Campaign not found",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "Users can attach links they created; admins any link. A link belongs to at most one campaign, so attaching moves it. Locked links, unknown codes and other users' links are reported as skipped.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "campaigns"
+                ],
+                "summary": "Attach links to a campaign",
+                "operationId": "attachCampaignURLs",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "name": "body",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "type": "object",
+                            "required": [
+                                "shortCodes"
+                            ],
+                            "properties": {
+                                "shortCodes": {
+                                    "type": "array",
+                                    "items": {
+                                        "type": "string"
+                                    }
+                                }
+                            }
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Links attached",
+                        "schema": {
+                            "type": "object",
+                            "properties": {
+                                "attached": {
+                                    "type": "array",
+                                    "items": {
+                                        "type": "string"
+                                    }
+                                },
+                                "skipped": {
+                                    "type": "array",
+                                    "items": {
+                                        "type": "string"
+                                    }
+                                }
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "shortCodes is required",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Authentication required",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Campaign belongs to another user",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Campaign not found",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/campaigns/{id}/urls/{shortCode}": {
+            "delete": {
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "campaigns"
+                ],
+                "summary": "Detach a link from a campaign",
+                "operationId": "detachCampaignURL",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "name": "shortCode",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Link detached",
+                        "schema": {
+                            "$ref": "#/definitions/MessageResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Authentication required",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Campaign belongs to another user",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Campaign not found, or the link is not part of it",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/campaigns/{id}/stats": {
+            "get": {
+                "description": "Sums clicks across every link of the campaign, with the combined device, browser and OS breakdown and each link's clicks",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "campaigns"
+                ],
+                "summary": "Get campaign statistics",
+                "operationId": "getCampaignStats",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Successful operation",
+                        "schema": {
+                            "$ref": "#/definitions/CampaignStats"
+                        }
+                    },
+                    "401": {
+                        "description": "Authentication required",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Campaign belongs to another user",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Campaign not found",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/campaigns/{id}/expire": {
+            "post": {
+                "description": "Disables every enabled link of the campaign, so they answer 410 Gone. Links can be enabled again one by one by an admin.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "campaigns"
+                ],
+                "summary": "Expire a campaign's links",
+                "operationId": "expireCampaign",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Links disabled",
+                        "schema": {
+                            "type": "object",
+                            "properties": {
+                                "expired": {
+                                    "type": "array",
+                                    "items": {
+                                        "type": "string"
+                                    }
+                                }
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Authentication required",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Campaign belongs to another user",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Campaign not found",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/campaigns/{id}/pixels": {
+            "put": {
+                "description": "Replaces the pixels fired before the redirects of the campaign's links, in addition to their own. A pixel only fires on links of its organization, and the caller must be an owner or editor of it.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "campaigns"
+                ],
+                "summary": "Set a campaign's retargeting pixels",
+                "operationId": "setCampaignPixels",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "name": "body",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "type": "object",
+                            "properties": {
+                                "pixels": {
+                                    "description": "IDs of up to 10 pixels; an empty list removes them",
+                                    "type": "array",
+                                    "items": {
+                                        "type": "integer"
+                                    },
+                                    "example": [
+                                        3,
+                                        7
+                                    ]
+                                }
+                            }
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Pixels updated",
+                        "schema": {
+                            "type": "object",
+                            "properties": {
+                                "message": {
+                                    "type": "string"
+                                },
+                                "pixels": {
+                                    "type": "array",
+                                    "items": {
+                                        "type": "integer"
+                                    }
+                                }
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request body, too many pixels or an unknown pixel",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Authentication required",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Campaign belongs to another user, or a pixel belongs to an organization the caller can't change links of",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Campaign not found",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/campaigns/{id}/transfer": {
+            "post": {
+                "description": "Offers the campaign, with all of its links, to another user or organization. Ownership moves once the recipient accepts at /api/v1/transfers/{id}/accept.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "campaigns"
+                ],
+                "summary": "Transfer a campaign",
+                "operationId": "transferCampaign",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "name": "body",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/TransferRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Transfer awaiting acceptance",
+                        "schema": {
+                            "$ref": "#/definitions/Transfer"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request body, or not exactly one recipient",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Authentication required",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Campaign belongs to another user",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Campaign or recipient organization not found",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "A transfer of the campaign is already awaiting acceptance",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/transfers": {
+            "get": {
+                "description": "Lists the transfers awaiting acceptance that the user requested or may accept, as the recipient or an owner of the recipient organization, oldest first. Admins see every pending transfer.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "transfers"
+                ],
+                "summary": "List pending transfers",
+                "operationId": "getTransfers",
+                "responses": {
+                    "200": {
+                        "description": "Successful operation",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/Transfer"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Authentication required",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/transfers/{id}/accept": {
+            "post": {
+                "description": "Gives the link or campaign to the recipient. Transfers to an organization make it the owner of the links, with the accepting user as their creator. The links get a new management token, returned once, so the previous owner's token stops working. Only the recipient user, an owner of the recipient organization or an admin can accept.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "transfers"
+                ],
+                "summary": "Accept a transfer",
+                "operationId": "acceptTransfer",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Ownership transferred",
+                        "schema": {
+                            "type": "object",
+                            "properties": {
+                                "links": {
+                                    "description": "Links that changed hands",
+                                    "type": "integer"
+                                },
+                                "managementToken": {
+                                    "description": "New management token of the transferred links",
+                                    "type": "string"
+                                },
+                                "transfer": {
+                                    "$ref": "#/definitions/Transfer"
+                                }
+                            }
+                        }
+                    },
+                    "403": {
+                        "description": "Not the recipient",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Transfer not found",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Transfer is no longer pending",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/transfers/{id}/decline": {
+            "post": {
+                "description": "Closes a pending transfer without moving anything. The recipient declines it; the requester or an admin cancels it.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "transfers"
+                ],
+                "summary": "Decline or cancel a transfer",
+                "operationId": "declineTransfer",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Transfer declined or cancelled",
+                        "schema": {
+                            "$ref": "#/definitions/Transfer"
+                        }
+                    },
+                    "403": {
+                        "description": "Neither the recipient nor the requester",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Transfer not found",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Transfer is no longer pending",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/users/{id}/data": {
+            "delete": {
+                "description": "Right-to-be-forgotten erasure. Deletes the user's account, sessions, campaigns and organization memberships, and every link they created together with its click events, and anonymizes the user in the audit log. Deleted links are not archived. Users may erase themselves; admins may erase anyone.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "users"
+                ],
+                "summary": "Erase a user's data",
+                "operationId": "eraseUserData",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "User data erased",
+                        "schema": {
+                            "type": "object",
+                            "properties": {
+                                "deletedLinks": {
+                                    "type": "integer"
+                                },
+                                "message": {
+                                    "type": "string"
+                                }
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Authentication required",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "You can only erase your own data",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "User not found",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/graphql": {
+            "post": {
+                "description": "Executes a GraphQL query over links, their stats, tags and campaigns. The query type has url(shortCode), urls(first, after, orgId, campaign) as a cursor connection, tags, campaigns and campaign(id); links and campaigns have a stats field. Access rules are those of the REST endpoints. Failing fields are null with an entry in errors. Fragments, directives, introspection and mutations are not supported. The same query can be sent with GET as query, operationName and variables parameters.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "graphql"
+                ],
+                "summary": "Run a GraphQL query",
+                "operationId": "graphql",
+                "parameters": [
+                    {
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "type": "object",
+                            "required": [
+                                "query"
+                            ],
+                            "properties": {
+                                "operationName": {
+                                    "type": "string"
+                                },
+                                "query": {
+                                    "type": "string"
+                                },
+                                "variables": {
+                                    "type": "object"
+                                }
+                            }
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Query executed, possibly with field errors",
+                        "schema": {
+                            "$ref": "#/definitions/GraphQLResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "The query could not be parsed or executed",
+                        "schema": {
+                            "$ref": "#/definitions/GraphQLResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/orgs": {
+            "get": {
+                "description": "Lists the organizations the signed-in user belongs to, with their role in each",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "orgs"
+                ],
+                "summary": "List my organizations",
+                "operationId": "getOrganizations",
+                "responses": {
+                    "200": {
+                        "description": "Successful operation",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/Organization"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Authentication required",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "Creates a shared link workspace owned by the signed-in user",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "orgs"
+                ],
+                "summary": "Create an organization",
+                "operationId": "createOrganization",
+                "parameters": [
+                    {
+                        "name": "body",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "type": "object",
+                            "required": [
+                                "name"
+                            ],
+                            "properties": {
+                                "name": {
+                                    "type": "string"
+                                }
+                            }
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Organization created",
+                        "schema": {
+                            "$ref": "#/definitions/Organization"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request body",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Authentication required",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/orgs/{orgId}/members": {
+            "get": {
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "orgs"
+                ],
+                "summary": "List organization members",
+                "operationId": "getOrgMembers",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "name": "orgId",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Successful operation",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/Member"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Authentication required",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Not a member of the organization",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/orgs/{orgId}/members/{userId}": {
+            "put": {
+                "description": "Owners only. Owners manage members; editors create, change and delete links; viewers see links and stats.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "orgs"
+                ],
+                "summary": "Add a member or change their role",
+                "operationId": "setOrgMember",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "name": "orgId",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "name": "userId",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "name": "body",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "type": "object",
+                            "required": [
+                                "role"
+                            ],
+                            "properties": {
+                                "role": {
+                                    "type": "string",
+                                    "enum": [
+                                        "owner",
+                                        "editor",
+                                        "viewer"
+                                    ]
+                                }
+                            }
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Member updated",
+                        "schema": {
+                            "$ref": "#/definitions/MessageResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid role",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Only owners can manage members",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Organization must keep at least one owner",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "description": "Owners may remove anyone; other members may remove themselves",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "orgs"
+                ],
+                "summary": "Remove a member",
+                "operationId": "removeOrgMember",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "name": "orgId",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "name": "userId",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Member removed",
+                        "schema": {
+                            "$ref": "#/definitions/MessageResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Only owners can manage members",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Member not found",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Organization must keep at least one owner",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/orgs/{orgId}/inactivity-policy": {
+            "get": {
+                "description": "What happens to the organization's links without clicks for a number of months. Organizations without a policy of their own follow INACTIVE_LINK_MONTHS and INACTIVE_LINK_ACTION.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "orgs"
+                ],
+                "summary": "Get the inactivity policy",
+                "operationId": "getOrgInactivityPolicy",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "name": "orgId",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Successful operation",
+                        "schema": {
+                            "$ref": "#/definitions/InactivityPolicy"
+                        }
+                    },
+                    "401": {
+                        "description": "Authentication required",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Not a member of the organization",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    }
+                }
+            },
+            "put": {
+                "description": "Owners only. Links without clicks for months months are archived (no longer listed, still redirecting) or disabled. Months 0 turns the policy off; null months restores the instance default.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "orgs"
+                ],
+                "summary": "Set the inactivity policy",
+                "operationId": "setOrgInactivityPolicy",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "name": "orgId",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "name": "body",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "type": "object",
+                            "properties": {
+                                "action": {
+                                    "type": "string",
+                                    "enum": [
+                                        "archive",
+                                        "disable"
+                                    ],
+                                    "default": "archive"
+                                },
+                                "months": {
+                                    "type": "integer",
+                                    "example": 12,
+                                    "x-nullable": true
+                                }
+                            }
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Policy now in effect",
+                        "schema": {
+                            "$ref": "#/definitions/InactivityPolicy"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid months or action",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Only owners can change the inactivity policy",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Organization not found",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/orgs/{orgId}/sso": {
+            "get": {
+                "description": "Owners only. The SAML identity provider members sign in through at /auth/saml/{orgId}/login.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "orgs"
+                ],
+                "summary": "Get the single sign-on setup",
+                "operationId": "getOrgSSO",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "name": "orgId",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Successful operation",
+                        "schema": {
+                            "$ref": "#/definitions/OrgSSO"
+                        }
+                    },
+                    "403": {
+                        "description": "Only owners can manage single sign-on",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Single sign-on is not set up",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    }
+                }
+            },
+            "put": {
+                "description": "Owners only. Connects the organization to its SAML identity provider; configure the identity provider with the service provider metadata at /auth/saml/{orgId}/metadata. Members signing in for the first time join with defaultRole. With billing enabled the organization's plan must include sso.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "orgs"
+                ],
+                "summary": "Set up single sign-on",
+                "operationId": "setOrgSSO",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "name": "orgId",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "name": "body",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/OrgSSORequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Setup stored",
+                        "schema": {
+                            "$ref": "#/definitions/OrgSSO"
+                        }
+                    },
+                    "400": {
+                        "description": "Missing fields or invalid certificate",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "402": {
+                        "description": "Single sign-on is not included in the organization's plan",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Only owners can manage single sign-on",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "description": "Owners only. Also revokes the SCIM token. Members keep their membership and sessions.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "orgs"
+                ],
+                "summary": "Remove single sign-on",
+                "operationId": "deleteOrgSSO",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "name": "orgId",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Single sign-on removed",
+                        "schema": {
+                            "$ref": "#/definitions/MessageResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Only owners can manage single sign-on",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Single sign-on is not set up",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/orgs/{orgId}/sso/scim-token": {
+            "post": {
+                "description": "Owners only. Returns the bearer token the identity provider provisions members with at /scim/v2, replacing any previous token. The token is only shown once. Once SCIM is enabled, only members provisioned by it can sign in.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "orgs"
+                ],
+                "summary": "Issue a SCIM token",
+                "operationId": "createSCIMToken",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "name": "orgId",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Token issued",
+                        "schema": {
+                            "type": "object",
+                            "properties": {
+                                "scimUrl": {
+                                    "type": "string",
+                                    "example": "/scim/v2"
+                                },
+                                "token": {
+                                    "type": "string"
+                                }
+                            }
+                        }
+                    },
+                    "402": {
+                        "description": "Single sign-on is not included in the organization's plan",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Only owners can manage single sign-on",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Single sign-on is not set up",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/orgs/{orgId}/two-factor": {
+            "put": {
+                "description": "Owners only. While required, members signed in with a session must have two-factor authentication to use the organization and its links. Members authenticated by JWT rely on their identity provider. Owners must enable two-factor authentication themselves before requiring it.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "orgs"
+                ],
+                "summary": "Require two-factor authentication",
+                "operationId": "setOrgTwoFactorPolicy",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "name": "orgId",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "name": "body",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "type": "object",
+                            "required": [
+                                "required"
+                            ],
+                            "properties": {
+                                "required": {
+                                    "type": "boolean"
+                                }
+                            }
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Policy now in effect",
+                        "schema": {
+                            "type": "object",
+                            "properties": {
+                                "requireTwoFactor": {
+                                    "type": "boolean"
+                                }
+                            }
+                        }
+                    },
+                    "403": {
+                        "description": "Only owners can change the two-factor policy",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "The owner has not enabled two-factor authentication",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/orgs/{orgId}/pixels": {
+            "get": {
+                "description": "Retargeting pixels that can be attached to the organization's links and to campaigns. Members only.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "orgs"
+                ],
+                "summary": "List an organization's pixels",
+                "operationId": "getOrgPixels",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "name": "orgId",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Pixels, oldest first",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/Pixel"
+                            }
+                        }
+                    },
+                    "403": {
+                        "description": "Not a member of this organization",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "Adds a Meta (facebook) pixel or Google tag (google) to the organization. Each provider's script comes from the pixel-{provider} template of pixels.html, which can be overridden through TEMPLATES_DIR. Owners and editors only.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "orgs"
+                ],
+                "summary": "Add a pixel",
+                "operationId": "createOrgPixel",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "name": "orgId",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "name": "body",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "type": "object",
+                            "required": [
+                                "provider",
+                                "pixelId"
+                            ],
+                            "properties": {
+                                "name": {
+                                    "type": "string",
+                                    "example": "Spring retargeting"
+                                },
+                                "pixelId": {
+                                    "description": "Numeric Meta pixel ID, or a Google tag ID starting with G-, AW-, DC- or GT-",
+                                    "type": "string",
+                                    "example": "1234567890"
+                                },
+                                "provider": {
+                                    "type": "string",
+                                    "enum": [
+                                        "facebook",
+                                        "google"
+                                    ]
+                                }
+                            }
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Pixel added",
+                        "schema": {
+                            "$ref": "#/definitions/Pixel"
+                        }
+                    },
+                    "400": {
+                        "description": "Unknown provider or invalid pixel ID",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Not an owner or editor of this organization",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/orgs/{orgId}/pixels/{pixelId}": {
+            "delete": {
+                "description": "Removes the pixel and detaches it from every link and campaign. Owners and editors only.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "orgs"
+                ],
+                "summary": "Delete a pixel",
+                "operationId": "deleteOrgPixel",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "name": "orgId",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "name": "pixelId",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Pixel deleted"
+                    },
+                    "403": {
+                        "description": "Not an owner or editor of this organization",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Pixel not found",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/orgs/{orgId}/reserved-prefixes": {
+            "get": {
+                "description": "Short code prefixes kept for the organization's links. Members only.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "orgs"
+                ],
+                "summary": "List an organization's reserved prefixes",
+                "operationId": "getOrgReservedPrefixes",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "name": "orgId",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Reserved prefixes in alphabetical order",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/ReservedPrefix"
+                            }
+                        }
+                    },
+                    "403": {
+                        "description": "Not a member of this organization",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "Reserves a prefix such as acme-* so that only links of the organization can be created with codes starting with it, compared case-insensitively, on any domain of the tenant. Readable codes and suggestions skip prefixes reserved by other organizations. Links already using such codes are kept. An organization can reserve up to 20 prefixes. Owners only.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "orgs"
+                ],
+                "summary": "Reserve a short code prefix",
+                "operationId": "createOrgReservedPrefix",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "name": "orgId",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "name": "body",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "type": "object",
+                            "required": [
+                                "prefix"
+                            ],
+                            "properties": {
+                                "prefix": {
+                                    "description": "At least 3 letters, digits, \"-\" or \"_\", optionally followed by \"*\"; stored in lowercase",
+                                    "type": "string",
+                                    "example": "acme-*"
+                                }
+                            }
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Prefix reserved",
+                        "schema": {
+                            "$ref": "#/definitions/ReservedPrefix"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid prefix",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Not an owner of this organization",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "The prefix is already reserved, or overlaps a prefix reserved by another organization",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "422": {
+                        "description": "The organization already reserved 20 prefixes",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/orgs/{orgId}/reserved-prefixes/{prefixId}": {
+            "delete": {
+                "description": "Lets anyone create codes under the prefix again. Owners only.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "orgs"
+                ],
+                "summary": "Release a reserved prefix",
+                "operationId": "deleteOrgReservedPrefix",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "name": "orgId",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "name": "prefixId",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Prefix released"
+                    },
+                    "403": {
+                        "description": "Not an owner of this organization",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Reserved prefix not found",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/orgs/{orgId}/warehouse-export": {
+            "get": {
+                "description": "Where the organization's daily click aggregates are exported to, the last day exported and the error of the last failed run. Credentials are never returned. Owners only; 404 unless WAREHOUSE_EXPORT_INTERVAL is set.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "orgs"
+                ],
+                "summary": "Get an organization's warehouse export",
+                "operationId": "getOrgWarehouseExport",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "name": "orgId",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "The export",
+                        "schema": {
+                            "$ref": "#/definitions/WarehouseExport"
+                        }
+                    },
+                    "403": {
+                        "description": "Not an owner of this organization",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "No export is set up, or exports are not enabled",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    }
+                }
+            },
+            "put": {
+                "description": "Exports the clicks on the organization's links once a day, aggregated per UTC day, link, country, device, browser and OS into the columns day (DATE), short_code, domain, campaign, country, device, browser, os (STRING) and clicks (INT64). Each day is exported an hour after it ends; days that fail are retried on the next run.\n\n- parquet: one file per day at clicks_daily/day=YYYY-MM-DD/clicks.parquet, in the instance's object store under warehouse/org-{orgId}/, or in an S3 bucket of the organization's with settings bucket, region and prefix and credentials {\"accessKeyId\": \"...\", \"secretAccessKey\": \"...\"}\n- bigquery: streams rows into an existing table, settings project, dataset and table, with a service account JSON key that may insert into it as credentials\n- snowflake: replaces the day's rows of an existing table, settings account, user, database, schema, table and optionally warehouse and role, with the user's PEM private key for key pair authentication as credentials\n\nOwners only.\n",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "orgs"
+                ],
+                "summary": "Set up an organization's warehouse export",
+                "operationId": "setOrgWarehouseExport",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "name": "orgId",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "name": "body",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "type": "object",
+                            "required": [
+                                "destination"
+                            ],
+                            "properties": {
+                                "backfillFrom": {
+                                    "description": "Export again starting at this day, at most 366 days back. New exports otherwise start with yesterday and existing ones continue where they left off.",
+                                    "type": "string",
+                                    "format": "date"
+                                },
+                                "credentials": {
+                                    "description": "Secret the destination is written with; omit to keep the stored one",
+                                    "type": "string"
+                                },
+                                "destination": {
+                                    "type": "string",
+                                    "enum": [
+                                        "parquet",
+                                        "bigquery",
+                                        "snowflake"
+                                    ]
+                                },
+                                "settings": {
+                                    "type": "object",
+                                    "example": {
+                                        "project": "acme-analytics",
+                                        "dataset": "links",
+                                        "table": "clicks_daily"
+                                    },
+                                    "additionalProperties": {
+                                        "type": "string"
+                                    }
+                                }
+                            }
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Export stored",
+                        "schema": {
+                            "$ref": "#/definitions/WarehouseExport"
+                        }
+                    },
+                    "400": {
+                        "description": "Unknown destination, invalid settings or credentials, or invalid backfillFrom",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Not an owner of this organization",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Exports are not enabled",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "description": "Stops the export and deletes its credentials. Data already exported is kept. Owners only.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "orgs"
+                ],
+                "summary": "Stop an organization's warehouse export",
+                "operationId": "deleteOrgWarehouseExport",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "name": "orgId",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Export removed"
+                    },
+                    "403": {
+                        "description": "Not an owner of this organization",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "No export is set up, or exports are not enabled",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/account/usage": {
+            "get": {
+                "description": "The signed-in user's links, links created and API calls this calendar month (UTC) against the limits of their plan, or the links of one of their organizations with orgId. API calls are metered per user; once the monthly limit is reached other API requests get 429 until the month ends. This endpoint is not metered.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "account"
+                ],
+                "summary": "Get usage against plan limits",
+                "operationId": "getAccountUsage",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "name": "orgId",
+                        "in": "query",
+                        "required": false
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Successful operation",
+                        "schema": {
+                            "$ref": "#/definitions/AccountUsage"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid organization ID",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Authentication required",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Not a member of the organization",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/plans": {
+            "get": {
+                "description": "Plans sold through Stripe with the features they include and the limits QUOTA_PLANS gives them, e.g. for a pricing page.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "billing"
+                ],
+                "summary": "List the plans for sale",
+                "operationId": "getPlans",
+                "responses": {
+                    "200": {
+                        "description": "Successful operation",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/PlanOffer"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/billing/checkout": {
+            "post": {
+                "description": "Creates a Stripe Checkout session subscribing the signed-in user, or with orgId an organization they own, to a plan and returns the page to send them to. The plan takes effect once Stripe reports the subscription active through the webhook.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "billing"
+                ],
+                "summary": "Start a subscription",
+                "operationId": "createCheckoutSession",
+                "parameters": [
+                    {
+                        "name": "body",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "type": "object",
+                            "required": [
+                                "plan"
+                            ],
+                            "properties": {
+                                "orgId": {
+                                    "type": "integer"
+                                },
+                                "plan": {
+                                    "type": "string",
+                                    "example": "pro"
+                                }
+                            }
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Checkout session created",
+                        "schema": {
+                            "type": "object",
+                            "properties": {
+                                "id": {
+                                    "type": "string"
+                                },
+                                "url": {
+                                    "description": "Stripe Checkout page to redirect the customer to",
+                                    "type": "string"
+                                }
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request body or plan not for sale",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Authentication required",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Not an owner of the organization",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Billing is not enabled",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "502": {
+                        "description": "Stripe failed to create the session",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/billing/subscription": {
+            "get": {
+                "description": "The most recently changed subscription of the signed-in user, or with orgId of an organization they own.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "billing"
+                ],
+                "summary": "Get the current subscription",
+                "operationId": "getSubscription",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "name": "orgId",
+                        "in": "query",
+                        "required": false
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Successful operation",
+                        "schema": {
+                            "$ref": "#/definitions/Subscription"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid organization ID",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Authentication required",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Not an owner of the organization",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "No subscription",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/admin/feature-flags": {
+            "get": {
+                "description": "Lists the feature flags gating experimental behavior, whether each is on in this instance's APP_ENV for links outside any organization, whether FEATURE_FLAGS turns it on, and every override stored for it. Requires the admin token.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "List feature flags",
+                "operationId": "getFeatureFlags",
+                "responses": {
+                    "200": {
+                        "description": "Successful operation",
+                        "schema": {
+                            "type": "object",
+                            "properties": {
+                                "environment": {
+                                    "type": "string",
+                                    "example": "production"
+                                },
+                                "flags": {
+                                    "type": "array",
+                                    "items": {
+                                        "$ref": "#/definitions/FeatureFlagStatus"
+                                    }
+                                }
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Invalid admin token",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/admin/feature-flags/{name}": {
+            "put": {
+                "description": "Turns a flag on or off at runtime, everywhere or only in one environment and/or for one organization, replacing any override with the same scope. The most specific override wins, from the organization in this environment down to everyone in every environment; without one the flag follows FEATURE_FLAGS. Other instances pick the change up within IP_RULES_REFRESH. Requires the admin token.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Set a feature flag",
+                "operationId": "setFeatureFlag",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "enum": [
+                            "analytics_pipeline",
+                            "interstitial",
+                            "redis_cache"
+                        ],
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "name": "body",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "type": "object",
+                            "required": [
+                                "enabled"
+                            ],
+                            "properties": {
+                                "enabled": {
+                                    "type": "boolean"
+                                },
+                                "environment": {
+                                    "description": "APP_ENV the override is limited to; empty for every environment",
+                                    "type": "string",
+                                    "example": "staging"
+                                },
+                                "orgId": {
+                                    "description": "Organization the override is limited to; 0 for every organization",
+                                    "type": "integer"
+                                }
+                            }
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Override stored",
+                        "schema": {
+                            "$ref": "#/definitions/FeatureFlag"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request body",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Invalid admin token",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Feature flag not found",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "description": "Removes the override with the given scope, so the flag falls back to a less specific override or to FEATURE_FLAGS. Requires the admin token.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Delete a feature flag override",
+                "operationId": "deleteFeatureFlag",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "enum": [
+                            "analytics_pipeline",
+                            "interstitial",
+                            "redis_cache"
+                        ],
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Environment of the override; omit for the one covering every environment",
+                        "name": "environment",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Organization of the override; omit for the one covering every organization",
+                        "name": "orgId",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Override deleted",
+                        "schema": {
+                            "$ref": "#/definitions/MessageResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid orgId",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Invalid admin token",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Feature flag or override not found",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/admin/tenants": {
+            "get": {
+                "description": "Lists the tenants served by this deployment with their hostnames. Links created before tenants existed, and by requests matching no tenant, belong to the default tenant, which is not listed. Requires the admin token.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "List tenants",
+                "operationId": "getTenants",
+                "responses": {
+                    "200": {
+                        "description": "Successful operation",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/Tenant"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Invalid admin token",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "Adds a tenant served on its hostnames and to its API keys. Other instances pick it up within IP_RULES_REFRESH. Requires the admin token.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Create a tenant",
+                "operationId": "createTenant",
+                "parameters": [
+                    {
+                        "name": "body",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/TenantRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Tenant created",
+                        "schema": {
+                            "$ref": "#/definitions/Tenant"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request body or hostname",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Invalid admin token",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "A hostname belongs to another tenant",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/admin/tenants/{id}": {
+            "put": {
+                "description": "Renames a tenant and replaces its hostnames. Requires the admin token.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Update a tenant",
+                "operationId": "updateTenant",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "name": "body",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/TenantRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Tenant updated",
+                        "schema": {
+                            "$ref": "#/definitions/Tenant"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid tenant ID, request body or hostname",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Invalid admin token",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Tenant not found",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "A hostname belongs to another tenant",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/admin/tenants/{id}/api-keys": {
+            "post": {
+                "description": "Issues an API key for a tenant. Requests sending it in the X-API-Key header are served for the tenant, and rejected with 403 on another tenant's hostname. A key with scopes can only be used on routes within them, where it acts without a JWT or management token; a key without scopes only selects the tenant. The key is only returned in this response. Requires the admin token.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Issue an API key",
+                "operationId": "createAPIKey",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "name": "body",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "type": "object",
+                            "required": [
+                                "name"
+                            ],
+                            "properties": {
+                                "name": {
+                                    "type": "string",
+                                    "example": "Partner integration"
+                                },
+                                "scopes": {
+                                    "type": "array",
+                                    "items": {
+                                        "type": "string",
+                                        "enum": [
+                                            "links:read",
+                                            "links:write",
+                                            "stats:read",
+                                            "admin"
+                                        ]
+                                    },
+                                    "example": [
+                                        "stats:read"
+                                    ]
+                                }
+                            }
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "API key issued",
+                        "schema": {
+                            "type": "object",
+                            "properties": {
+                                "apiKey": {
+                                    "$ref": "#/definitions/APIKey"
+                                },
+                                "key": {
+                                    "description": "The API key, shown only once",
+                                    "type": "string"
+                                }
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid tenant ID or request body",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Invalid admin token",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Tenant not found",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/admin/api-keys": {
+            "get": {
+                "description": "Lists the API keys of every tenant, without the keys themselves. Requires the admin token.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "List API keys",
+                "operationId": "getAPIKeys",
+                "responses": {
+                    "200": {
+                        "description": "Successful operation",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/APIKey"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Invalid admin token",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/admin/api-keys/{id}": {
+            "delete": {
+                "description": "Revokes an API key; requests still sending it are rejected with 401. Requires the admin token.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Revoke an API key",
+                "operationId": "deleteAPIKey",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "API key revoked",
+                        "schema": {
+                            "$ref": "#/definitions/MessageResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid API key ID",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Invalid admin token",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "API key not found",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/admin/users/{id}/plan": {
+            "put": {
+                "description": "Puts a user on one of the QUOTA_PLANS, limiting their personal links and their API calls; an empty plan restores QUOTA_DEFAULT_PLAN. Requires the admin token.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Set a user's plan",
+                "operationId": "setUserPlan",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "name": "body",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/PlanRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Plan now in effect",
+                        "schema": {
+                            "type": "object",
+                            "properties": {
+                                "plan": {
+                                    "type": "string"
+                                },
+                                "userId": {
+                                    "type": "string"
+                                }
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request body or unknown plan",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Invalid admin token",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "User not found",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/admin/plans/{name}": {
+            "put": {
+                "description": "Sells a plan at a Stripe price, or changes its price or features. With QUOTA_PLANS set the plan must be one of them, which gives its limits. Requires the admin token.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Sell a plan",
+                "operationId": "setBillingPlan",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "name": "body",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "type": "object",
+                            "properties": {
+                                "customDomains": {
+                                    "description": "Whether subscribers may create links on custom domains",
+                                    "type": "boolean"
+                                },
+                                "sso": {
+                                    "description": "Whether subscribing organizations may use SAML single sign-on and SCIM",
+                                    "type": "boolean"
+                                },
+                                "stripePriceId": {
+                                    "type": "string",
+                                    "example": "price_1PxYz"
+                                }
+                            }
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Plan stored",
+                        "schema": {
+                            "$ref": "#/definitions/BillingPlan"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request body or plan not in QUOTA_PLANS",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Invalid admin token",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Another plan is sold at this price",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "description": "Removes a plan from sale; subscribers keep it until their subscription ends. Requires the admin token.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Stop selling a plan",
+                "operationId": "deleteBillingPlan",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Plan removed",
+                        "schema": {
+                            "$ref": "#/definitions/MessageResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Invalid admin token",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Plan not found",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/admin/orgs/{orgId}/plan": {
+            "put": {
+                "description": "Puts an organization on one of the QUOTA_PLANS, limiting its links; an empty plan restores QUOTA_DEFAULT_PLAN. Requires the admin token.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Set an organization's plan",
+                "operationId": "setOrgPlan",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "name": "orgId",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "name": "body",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/PlanRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Plan now in effect",
+                        "schema": {
+                            "type": "object",
+                            "properties": {
+                                "orgId": {
+                                    "type": "integer"
+                                },
+                                "plan": {
+                                    "type": "string"
+                                }
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request body or unknown plan",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Invalid admin token",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Organization not found",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/admin/audit": {
+            "get": {
+                "description": "Lists create, update, delete, lock and unlock operations with actor, time, client IP and the values before and after, newest first. Requires the admin token.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Query the audit log",
+                "operationId": "getAuditLog",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "name": "actor",
+                        "in": "query",
+                        "required": false
+                    },
+                    {
+                        "type": "string",
+                        "enum": [
+                            "create",
+                            "update",
+                            "delete",
+                            "lock",
+                            "unlock",
+                            "rollback",
+                            "reset_stats",
+                            "adjust_stats",
+                            "reload_config",
+                            "transfer"
+                        ],
+                        "name": "action",
+                        "in": "query",
+                        "required": false
+                    },
+                    {
+                        "type": "string",
+                        "enum": [
+                            "url",
+                            "tag",
+                            "organization",
+                            "organization_member",
+                            "click_import",
+                            "feature_flag",
+                            "tenant",
+                            "api_key",
+                            "user",
+                            "plan",
+                            "transfer",
+                            "sso"
+                        ],
+                        "name": "entityType",
+                        "in": "query",
+                        "required": false
+                    },
+                    {
+                        "type": "string",
+                        "description": "For URLs, the short code",
+                        "name": "entityId",
+                        "in": "query",
+                        "required": false
+                    },
+                    {
+                        "type": "string",
+                        "format": "date-time",
+                        "name": "since",
+                        "in": "query",
+                        "required": false
+                    },
+                    {
+                        "type": "string",
+                        "format": "date-time",
+                        "name": "until",
+                        "in": "query",
+                        "required": false
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Maximum number of entries (default 100, at most 1000)",
+                        "name": "limit",
+                        "in": "query",
+                        "required": false
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Successful operation",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/AuditEntry"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid filter",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Invalid admin token",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/admin/anomalies": {
+            "get": {
+                "description": "Lists links where click fraud detection saw the same IP hammering the link, clicks from datacenter networks or sudden traffic spikes, most recently flagged first. Hammering and datacenter clicks are counted in suspiciousClicks instead of accessCount; spikes are only flagged. Requires the admin token.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Report links with anomalous clicks",
+                "operationId": "getAnomalousLinks",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Maximum number of links (default 100, at most 1000)",
+                        "name": "limit",
+                        "in": "query",
+                        "required": false
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Successful operation",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/AnomalousLink"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid limit",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Invalid admin token",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/admin/metrics": {
+            "get": {
+                "description": "Returns the process metrics published with expvar, including memory statistics, the number of requests that panicked (panics) the runs, failures, skipped runs and last duration and error of each background job (jobs), the goroutine count (goroutines) and how many clients each in-memory limiter keeps state for (limiter_keys). Requires the admin token.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Read process metrics",
+                "operationId": "getMetrics",
+                "responses": {
+                    "200": {
+                        "description": "Successful operation",
+                        "schema": {
+                            "type": "object"
+                        }
+                    },
+                    "401": {
+                        "description": "Invalid admin token",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/admin/config/reload": {
+            "post": {
+                "description": "Reads the .env file again and applies changed rate limits, bot filtering, click fraud detection, page metadata fetching, archiving on delete, CORS origins and default feature flags without a restart, so in-flight redirects are not dropped. The destination blocklist, IP rules and feature flag overrides are reloaded from the database too. Variables set in the process environment take precedence over the file. Nothing is applied when a new setting is invalid. Other changed sections are listed as needing a restart. With CONFIG_WATCH_INTERVAL set, the file is also reloaded whenever it changes. Requires the admin token.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Reload the configuration",
+                "operationId": "reloadConfig",
+                "responses": {
+                    "200": {
+                        "description": "Configuration reloaded",
+                        "schema": {
+                            "type": "object",
+                            "properties": {
+                                "applied": {
+                                    "description": "Sections applied, e.g. RateLimit or CORS",
+                                    "type": "array",
+                                    "items": {
+                                        "type": "string"
+                                    }
+                                },
+                                "restartRequired": {
+                                    "description": "Sections changed since startup that only take effect after a restart",
+                                    "type": "array",
+                                    "items": {
+                                        "type": "string"
+                                    }
+                                }
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "A new setting is invalid; nothing was applied",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Invalid admin token",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/urls/{shortCode}/enable": {
+            "post": {
+                "description": "Lets a link disabled by a blocklist scan redirect again. Requires the admin token.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Enable a disabled short URL",
+                "operationId": "enableShortURL",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Short code of the URL",
+                        "name": "shortCode",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "URL enabled successfully",
+                        "schema": {
+                            "$ref": "#/definitions/MessageResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Invalid admin token",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Short URL not found",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/admin/blocklist": {
+            "get": {
+                "description": "Lists destination domains and URL patterns that cannot be shortened. Requires the admin token.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "List blocked destinations",
+                "operationId": "getBlockedDestinations",
+                "responses": {
+                    "200": {
+                        "description": "Successful operation",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/BlockedDestination"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Invalid admin token",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "Blocks a destination domain with all its subdomains (domain), or destinations whose full URL matches a regular expression (regex). New and updated links are checked against the list; run a scan to disable existing ones. Requires the admin token.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Block a destination",
+                "operationId": "addBlockedDestination",
+                "parameters": [
+                    {
+                        "name": "body",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "type": "object",
+                            "required": [
+                                "kind",
+                                "pattern"
+                            ],
+                            "properties": {
+                                "kind": {
+                                    "type": "string",
+                                    "enum": [
+                                        "domain",
+                                        "regex"
+                                    ]
+                                },
+                                "note": {
+                                    "type": "string"
+                                },
+                                "pattern": {
+                                    "type": "string",
+                                    "example": "phishing.example"
+                                }
+                            }
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Rule added",
+                        "schema": {
+                            "$ref": "#/definitions/BlockedDestination"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request body, kind, domain or pattern",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Invalid admin token",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Rule already exists",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/admin/blocklist/{id}": {
+            "delete": {
+                "description": "Removes a blocklist rule. Links it disabled stay disabled until enabled. Requires the admin token.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Unblock a destination",
+                "operationId": "deleteBlockedDestination",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "format": "int64",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Rule deleted",
+                        "schema": {
+                            "$ref": "#/definitions/MessageResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Invalid admin token",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Rule not found",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/admin/blocklist/scan": {
+            "post": {
+                "description": "Checks every enabled link, including its platform targets and A/B variants, against the blocklist and disables the offenders. Requires the admin token.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Disable links to blocked destinations",
+                "operationId": "scanBlockedDestinations",
+                "responses": {
+                    "200": {
+                        "description": "Scan finished",
+                        "schema": {
+                            "type": "object",
+                            "properties": {
+                                "disabled": {
+                                    "type": "array",
+                                    "items": {
+                                        "type": "string"
+                                    }
+                                },
+                                "scanned": {
+                                    "type": "integer"
+                                }
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Invalid admin token",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/admin/ip-rules": {
+            "get": {
+                "description": "Lists blocked networks and the admin allowlist. Requires the admin token.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "List IP rules",
+                "operationId": "getIPRules",
+                "responses": {
+                    "200": {
+                        "description": "Successful operation",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/IPRule"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Invalid admin token",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "Blocks an IP or CIDR from the whole service (block), or adds it to the admin allowlist (admin_allow). Once the allowlist has an entry, admin routes are only reachable from listed addresses. Rules that would lock the caller out are rejected. Requires the admin token.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Add an IP rule",
+                "operationId": "addIPRule",
+                "parameters": [
+                    {
+                        "name": "body",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "type": "object",
+                            "required": [
+                                "cidr",
+                                "action"
+                            ],
+                            "properties": {
+                                "action": {
+                                    "type": "string",
+                                    "enum": [
+                                        "block",
+                                        "admin_allow"
+                                    ]
+                                },
+                                "cidr": {
+                                    "type": "string",
+                                    "example": "203.0.113.0/24"
+                                },
+                                "note": {
+                                    "type": "string"
+                                }
+                            }
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Rule added",
+                        "schema": {
+                            "$ref": "#/definitions/IPRule"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request body, CIDR or action",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Invalid admin token",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Rule already exists or would lock the caller out",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/admin/ip-rules/{id}": {
+            "delete": {
+                "description": "Removes a block or allowlist entry. Requires the admin token.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Delete an IP rule",
+                "operationId": "deleteIPRule",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "format": "int64",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Rule deleted",
+                        "schema": {
+                            "$ref": "#/definitions/MessageResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Invalid admin token",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Rule not found",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Deleting the rule would lock the caller out",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/admin/imports": {
+            "get": {
+                "description": "Lists the most recent historical click imports with their provenance. Requires the admin token.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "List click imports",
+                "operationId": "getClickImports",
+                "responses": {
+                    "200": {
+                        "description": "Successful operation",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/ClickImport"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Invalid admin token",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/admin/imports/clicks": {
+            "post": {
+                "description": "Backfills click history from another shortener onto existing short codes. Each record is either a daily count or a single event with a timestamp. Imported clicks are added to the access count, and the batch is recorded with its source. Requires the admin token.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Import historical clicks",
+                "operationId": "importClicks",
+                "parameters": [
+                    {
+                        "name": "body",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "type": "object",
+                            "required": [
+                                "source",
+                                "records"
+                            ],
+                            "properties": {
+                                "externalId": {
+                                    "description": "Identifier of the batch in the source system; a repeated source and externalId is rejected",
+                                    "type": "string"
+                                },
+                                "note": {
+                                    "type": "string"
+                                },
+                                "records": {
+                                    "type": "array",
+                                    "items": {
+                                        "type": "object",
+                                        "required": [
+                                            "shortCode"
+                                        ],
+                                        "properties": {
+                                            "clicks": {
+                                                "description": "Defaults to 1, for imports of individual events",
+                                                "type": "integer"
+                                            },
+                                            "date": {
+                                                "type": "string",
+                                                "format": "date"
+                                            },
+                                            "shortCode": {
+                                                "type": "string"
+                                            },
+                                            "timestamp": {
+                                                "type": "string",
+                                                "format": "date-time"
+                                            }
+                                        }
+                                    }
+                                },
+                                "source": {
+                                    "type": "string",
+                                    "example": "bitly"
+                                }
+                            }
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Clicks imported",
+                        "schema": {
+                            "$ref": "#/definitions/ClickImport"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request body",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Invalid admin token",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Import already applied",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "422": {
+                        "description": "Records reference unknown short codes",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/auth/login": {
+            "get": {
+                "description": "Redirects to the provider's consent page. After the callback, a session cookie is set and the browser is sent to ` + "`" + `redirect` + "`" + `. Users with two-factor authentication get a pending session to complete at /auth/2fa/verify instead.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "auth"
+                ],
+                "summary": "Sign in with an identity provider",
+                "operationId": "oauthLogin",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "enum": [
+                            "google",
+                            "github"
+                        ],
+                        "name": "provider",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Same-site path to return to after signing in",
+                        "name": "redirect",
+                        "in": "query",
+                        "required": false
+                    }
+                ],
+                "responses": {
+                    "302": {
+                        "description": "Redirect to the provider"
+                    },
+                    "400": {
+                        "description": "Unknown or disabled provider",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/auth/callback": {
+            "get": {
+                "description": "OAuth2 redirect target. Links the provider account to a user, creating it on first sign-in, and starts a session.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "auth"
+                ],
+                "summary": "Complete sign-in",
+                "operationId": "oauthCallback",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "name": "code",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "name": "state",
+                        "in": "query",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "302": {
+                        "description": "Signed in; redirect to the page that started the login"
+                    },
+                    "400": {
+                        "description": "Invalid or expired login state",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "502": {
+                        "description": "Provider request failed",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/auth/logout": {
+            "post": {
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "auth"
+                ],
+                "summary": "Sign out",
+                "operationId": "oauthLogout",
+                "responses": {
+                    "200": {
+                        "description": "Session ended",
+                        "schema": {
+                            "$ref": "#/definitions/MessageResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/auth/me": {
+            "get": {
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "auth"
+                ],
+                "summary": "Get the signed-in user",
+                "operationId": "currentUser",
+                "responses": {
+                    "200": {
+                        "description": "Successful operation",
+                        "schema": {
+                            "$ref": "#/definitions/User"
+                        }
+                    },
+                    "401": {
+                        "description": "Not signed in",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/auth/2fa": {
+            "get": {
+                "description": "Whether the signed-in user has two-factor authentication and how many recovery codes they have left.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "auth"
+                ],
+                "summary": "Get two-factor status",
+                "operationId": "getTwoFactor",
+                "responses": {
+                    "200": {
+                        "description": "Successful operation",
+                        "schema": {
+                            "type": "object",
+                            "properties": {
+                                "enabled": {
+                                    "type": "boolean"
+                                },
+                                "recoveryCodesLeft": {
+                                    "type": "integer"
+                                }
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Not signed in",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/auth/2fa/setup": {
+            "post": {
+                "description": "Generates a TOTP secret for the signed-in user. Enter the secret in an authenticator app, or show the otpauth URI as a QR code, then confirm with /auth/2fa/enable.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "auth"
+                ],
+                "summary": "Start enrolling an authenticator app",
+                "operationId": "setupTwoFactor",
+                "responses": {
+                    "200": {
+                        "description": "Secret generated",
+                        "schema": {
+                            "type": "object",
+                            "properties": {
+                                "secret": {
+                                    "type": "string",
+                                    "example": "JBSWY3DPEHPK3PXPJBSWY3DPEHPK3PXP"
+                                },
+                                "uri": {
+                                    "type": "string",
+                                    "example": "otpauth://totp/sho.rt:ann@example.com?issuer=sho.rt&secret=JBSWY3DPEHPK3PXPJBSWY3DPEHPK3PXP"
+                                }
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Not signed in",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Two-factor authentication is already enabled",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/auth/2fa/enable": {
+            "post": {
+                "description": "Confirms enrollment with a code from the app. Returns ten single-use recovery codes, which are only shown here.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "auth"
+                ],
+                "summary": "Enable two-factor authentication",
+                "operationId": "enableTwoFactor",
+                "parameters": [
+                    {
+                        "name": "body",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/SecondFactor"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Enabled",
+                        "schema": {
+                            "$ref": "#/definitions/RecoveryCodes"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid code or no enrollment started",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Two-factor authentication is already enabled",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/auth/2fa/disable": {
+            "post": {
+                "description": "Requires a current code or a recovery code.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "auth"
+                ],
+                "summary": "Disable two-factor authentication",
+                "operationId": "disableTwoFactor",
+                "parameters": [
+                    {
+                        "name": "body",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/SecondFactor"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Disabled",
+                        "schema": {
+                            "$ref": "#/definitions/MessageResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Invalid code",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Two-factor authentication is not enabled",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/auth/2fa/recovery-codes": {
+            "post": {
+                "description": "Replaces the signed-in user's recovery codes. Requires a current code or a recovery code.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "auth"
+                ],
+                "summary": "Regenerate recovery codes",
+                "operationId": "regenerateRecoveryCodes",
+                "parameters": [
+                    {
+                        "name": "body",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/SecondFactor"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "New recovery codes",
+                        "schema": {
+                            "$ref": "#/definitions/RecoveryCodes"
+                        }
+                    },
+                    "403": {
+                        "description": "Invalid code",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/auth/2fa/verify": {
+            "post": {
+                "description": "Users with two-factor authentication get a pending session when they sign in, held in the two_factor cookie for ten minutes. A code from their app, or a recovery code, turns it into a session. Five wrong codes end the pending session.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "auth"
+                ],
+                "summary": "Complete sign-in with a second factor",
+                "operationId": "verifyTwoFactor",
+                "parameters": [
+                    {
+                        "name": "body",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/SecondFactor"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Signed in",
+                        "schema": {
+                            "$ref": "#/definitions/User"
+                        }
+                    },
+                    "401": {
+                        "description": "No pending sign-in, or it expired or had too many wrong codes",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Invalid code",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/auth/saml/{orgId}/login": {
+            "get": {
+                "description": "Redirects to the SAML identity provider of the organization. After it posts back to the ACS URL, a session cookie is set and the browser is sent to ` + "`" + `redirect` + "`" + `.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "auth"
+                ],
+                "summary": "Sign in with the organization's identity provider",
+                "operationId": "samlLogin",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "name": "orgId",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Same-site path to return to after signing in",
+                        "name": "redirect",
+                        "in": "query",
+                        "required": false
+                    }
+                ],
+                "responses": {
+                    "302": {
+                        "description": "Redirect to the identity provider"
+                    },
+                    "404": {
+                        "description": "Single sign-on is not set up",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/auth/saml/{orgId}/acs": {
+            "post": {
+                "description": "Assertion consumer service for the HTTP-POST binding. Verifies the signed response, adds the user to the organization on their first sign-in and starts a session. Responses the identity provider sends on its own are accepted; each assertion can only be used once.",
+                "consumes": [
+                    "application/x-www-form-urlencoded"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "auth"
+                ],
+                "summary": "Complete SAML sign-in",
+                "operationId": "samlACS",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "name": "orgId",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "name": "SAMLResponse",
+                        "in": "formData",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Same-site path to return to",
+                        "name": "RelayState",
+                        "in": "formData",
+                        "required": false
+                    }
+                ],
+                "responses": {
+                    "302": {
+                        "description": "Signed in; redirect to the page that started the login"
+                    },
+                    "401": {
+                        "description": "Invalid, expired or replayed SAML response",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "SCIM is enabled and the user is not provisioned",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/auth/saml/{orgId}/metadata": {
+            "get": {
+                "description": "SAML metadata to configure the identity provider with. Its entity ID is this URL.",
+                "produces": [
+                    "application/samlmetadata+xml"
+                ],
+                "tags": [
+                    "auth"
+                ],
+                "summary": "Get service provider metadata",
+                "operationId": "samlMetadata",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "name": "orgId",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Service provider metadata"
+                    },
+                    "404": {
+                        "description": "Single sign-on is not set up",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/scim/v2/Users": {
+            "get": {
+                "description": "SCIM 2.0 for the identity provider of the organization whose SCIM token is the bearer token. Lists the users it has signed in or provisioned; active users are members. Only the ` + "`" + `userName eq \"...\"` + "`" + ` filter is supported.",
+                "produces": [
+                    "application/scim+json"
+                ],
+                "tags": [
+                    "scim"
+                ],
+                "summary": "List provisioned users",
+                "operationId": "getSCIMUsers",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Bearer SCIM token",
+                        "name": "Authorization",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "name": "filter",
+                        "in": "query",
+                        "required": false,
+                        "example": "userName eq \"ann@example.com\""
+                    },
+                    {
+                        "type": "integer",
+                        "default": 1,
+                        "name": "startIndex",
+                        "in": "query",
+                        "required": false
+                    },
+                    {
+                        "type": "integer",
+                        "default": 200,
+                        "name": "count",
+                        "in": "query",
+                        "required": false,
+                        "maximum": 200
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "SCIM ListResponse of users"
+                    },
+                    "400": {
+                        "description": "Unsupported filter"
+                    },
+                    "401": {
+                        "description": "Missing or invalid SCIM token"
+                    }
+                }
+            },
+            "post": {
+                "description": "Creates the user, or links them if they signed in already, and adds them to the organization with its default role.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/scim+json"
+                ],
+                "tags": [
+                    "scim"
+                ],
+                "summary": "Provision a user",
+                "operationId": "createSCIMUser",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "name": "Authorization",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "name": "body",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/SCIMUser"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "User provisioned",
+                        "schema": {
+                            "$ref": "#/definitions/SCIMUser"
+                        }
+                    },
+                    "409": {
+                        "description": "A user with this userName exists"
+                    }
+                }
+            }
+        },
+        "/scim/v2/Users/{id}": {
+            "get": {
+                "produces": [
+                    "application/scim+json"
+                ],
+                "tags": [
+                    "scim"
+                ],
+                "summary": "Get a provisioned user",
+                "operationId": "getSCIMUser",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "name": "Authorization",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Successful operation",
+                        "schema": {
+                            "$ref": "#/definitions/SCIMUser"
+                        }
+                    },
+                    "404": {
+                        "description": "User not found"
+                    }
+                }
+            },
+            "put": {
+                "description": "Updates email and name. Setting active to false offboards the user as DELETE does; true adds them back with the default role.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/scim+json"
+                ],
+                "tags": [
+                    "scim"
+                ],
+                "summary": "Replace a provisioned user",
+                "operationId": "replaceSCIMUser",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "name": "Authorization",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "name": "body",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/SCIMUser"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "User updated",
+                        "schema": {
+                            "$ref": "#/definitions/SCIMUser"
+                        }
+                    },
+                    "409": {
+                        "description": "The user is the organization's last owner"
+                    }
+                }
+            },
+            "patch": {
+                "description": "Applies add and replace PatchOp operations on active, displayName, name and emails; other attributes are ignored. Replacing active with false offboards the user as DELETE does.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/scim+json"
+                ],
+                "tags": [
+                    "scim"
+                ],
+                "summary": "Update a provisioned user",
+                "operationId": "patchSCIMUser",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "name": "Authorization",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "name": "body",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "type": "object",
+                            "properties": {
+                                "Operations": {
+                                    "type": "array",
+                                    "items": {
+                                        "type": "object",
+                                        "properties": {
+                                            "op": {
+                                                "type": "string",
+                                                "example": "replace"
+                                            },
+                                            "path": {
+                                                "type": "string",
+                                                "example": "active"
+                                            },
+                                            "value": {}
+                                        }
+                                    }
+                                },
+                                "schemas": {
+                                    "type": "array",
+                                    "items": {
+                                        "type": "string"
+                                    },
+                                    "example": [
+                                        "urn:ietf:params:scim:api:messages:2.0:PatchOp"
+                                    ]
+                                }
+                            }
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "User updated",
+                        "schema": {
+                            "$ref": "#/definitions/SCIMUser"
+                        }
+                    },
+                    "400": {
+                        "description": "Unsupported operation or invalid value"
+                    },
+                    "409": {
+                        "description": "The user is the organization's last owner"
+                    }
+                }
+            },
+            "delete": {
+                "description": "Removes the user from the organization and ends their sessions. API keys they created are revoked, and their personal links and their links in the organization are transferred to the organization.",
+                "tags": [
+                    "scim"
+                ],
+                "summary": "Offboard a user",
+                "operationId": "deleteSCIMUser",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "name": "Authorization",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "User offboarded"
+                    },
+                    "404": {
+                        "description": "User not found"
+                    },
+                    "409": {
+                        "description": "The user is the organization's last owner"
+                    }
+                }
+            }
+        },
+        "/scim/v2/ServiceProviderConfig": {
+            "get": {
+                "produces": [
+                    "application/scim+json"
+                ],
+                "tags": [
+                    "scim"
+                ],
+                "summary": "Get SCIM capabilities",
+                "operationId": "getSCIMServiceProviderConfig",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "name": "Authorization",
+                        "in": "header",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "SCIM ServiceProviderConfig"
+                    }
+                }
+            }
+        },
+        "/billing/webhook": {
+            "post": {
+                "description": "Endpoint for Stripe webhooks, verified with STRIPE_WEBHOOK_SECRET. customer.subscription.created, updated and deleted events put the subscriber on the plan sold at the subscription's price while it is active, trialing or past due, and back on QUOTA_DEFAULT_PLAN once it ends. Other events are acknowledged and ignored.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "billing"
+                ],
+                "summary": "Receive Stripe events",
+                "operationId": "stripeWebhook",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "name": "Stripe-Signature",
+                        "in": "header",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Event received"
+                    },
+                    "400": {
+                        "description": "Missing or invalid signature, or malformed event",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Billing is not enabled",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Failed to store the subscription; Stripe retries the event",
+                        "schema": {
+                            "$ref": "#/definitions/ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/{shortCode}": {
+            "get": {
+                "description": "Root-level redirect for a short code (served under BASE_PATH when configured). Reserved segments such as urls, swagger and healthz never resolve as codes.",
+                "tags": [
+                    "urls"
+                ],
+                "summary": "Redirect to original URL",
+                "operationId": "getRootRedirect",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Short code of the URL",
+                        "name": "shortCode",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Page showing the destination in a full-page iframe, for links in frame mode, or firing the link's retargeting pixels before forwarding to it"
+                    },
+                    "302": {
+                        "description": "Redirect to original URL"
+                    },
+                    "404": {
+                        "description": "Short URL not found"
+                    },
+                    "410": {
+                        "description": "Link has been disabled"
+                    }
+                }
+            }
+        },
+        "/{shortCode}/{path}": {
+            "get": {
+                "description": "For links created with forwardPath, forwards the rest of the path to the destination. A \"*\" in the destination is replaced by the path (https://internal.wiki/pages/* serves /docs/guides/setup as https://internal.wiki/pages/guides/setup); otherwise the path is appended.",
+                "tags": [
+                    "urls"
+                ],
+                "summary": "Redirect with forwarded path",
+                "operationId": "getForwardedRedirect",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Short code of the URL",
+                        "name": "shortCode",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Remaining path, may contain slashes",
+                        "name": "path",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "302": {
+                        "description": "Redirect to the destination with the path forwarded"
+                    },
+                    "404": {
+                        "description": "Short URL not found or link does not forward paths"
+                    },
+                    "410": {
+                        "description": "Link has been disabled"
+                    }
+                }
+            }
+        },
+        "/{shortCode}/stats": {
+            "get": {
+                "description": "For links with publicStats set, an HTML page with the link's total clicks, clicks per day over the last 30 days, top countries, devices and browsers. Needs no credentials. For other links the path is treated like any other forwarded path.",
+                "produces": [
+                    "text/html"
+                ],
+                "tags": [
+                    "urls"
+                ],
+                "summary": "Public stats page",
+                "operationId": "getPublicStats",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Short code of the URL",
+                        "name": "shortCode",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Stats page"
+                    },
+                    "404": {
+                        "description": "Short URL not found or link does not forward paths"
+                    }
+                }
+            }
+        },
+        "/healthz": {
+            "get": {
+                "description": "Reports whether the service can reach its database",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "health"
+                ],
+                "summary": "Health check",
+                "operationId": "healthCheck",
+                "responses": {
+                    "200": {
+                        "description": "Service healthy",
+                        "schema": {
+                            "$ref": "#/definitions/HealthResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "Database unreachable",
+                        "schema": {
+                            "$ref": "#/definitions/HealthResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/.well-known/events-schema": {
+            "get": {
+                "description": "Returns versioned JSON Schemas for every webhook and stream event payload",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "events"
+                ],
+                "summary": "Event payload schemas",
+                "operationId": "getEventsSchema",
+                "responses": {
+                    "200": {
+                        "description": "JSON Schema document keyed by event type",
+                        "schema": {
+                            "type": "object"
+                        }
+                    }
+                }
+            }
+        }
+    },
+    "definitions": {
+        "GraphQLResponse": {
+            "type": "object",
+            "properties": {
+                "data": {
+                    "type": "object"
+                },
+                "errors": {
+                    "type": "array",
+                    "items": {
+                        "type": "object",
+                        "properties": {
+                            "extensions": {
+                                "type": "object",
+                                "properties": {
+                                    "code": {
+                                        "type": "string"
+                                    }
+                                }
+                            },
+                            "message": {
+                                "type": "string"
+                            },
+                            "path": {
+                                "type": "array",
+                                "items": {}
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "Targets": {
+            "description": "Destination per platform; keys are ios, android, mobile, tablet and desktop",
+            "type": "object",
+            "example": {
+                "ios": "https://apps.apple.com/app/id123456789",
+                "android": "https://play.google.com/store/apps/details?id=com.example.app"
+            },
+            "additionalProperties": {
+                "type": "string"
+            }
+        },
+        "URL": {
+            "type": "object",
+            "properties": {
+                "accessCount": {
+                    "type": "integer",
+                    "format": "int64"
+                },
+                "appLink": {
+                    "$ref": "#/definitions/AppLink"
+                },
+                "archived": {
+                    "description": "Archived links were left out of listings after a period without clicks, but keep redirecting",
+                    "type": "boolean"
+                },
+                "botClicks": {
+                    "description": "Redirects made by crawlers and link unfurlers, not included in accessCount",
+                    "type": "integer",
+                    "format": "int64"
+                },
+                "cacheTtl": {
+                    "description": "Seconds a CDN may cache the redirect, only present when set for the link",
+                    "type": "integer"
+                },
+                "campaign": {
+                    "description": "utm_campaign the link was created with, omitted when none",
+                    "type": "string"
+                },
+                "campaignId": {
+                    "description": "Campaign the link is attached to, omitted when none",
+                    "type": "integer"
+                },
+                "createdAt": {
+                    "type": "string",
+                    "format": "date-time"
+                },
+                "description": {
+                    "type": "string"
+                },
+                "disabled": {
+                    "description": "Disabled links no longer redirect, e.g. after their destination was blocklisted",
+                    "type": "boolean"
+                },
+                "disabledReason": {
+                    "type": "string"
+                },
+                "domain": {
+                    "type": "string"
+                },
+                "forwardPath": {
+                    "description": "Whether the path after the short code is forwarded to the destination",
+                    "type": "boolean"
+                },
+                "forwardQuery": {
+                    "description": "Whether the short link's query string is appended to the destination",
+                    "type": "boolean"
+                },
+                "frame": {
+                    "description": "Whether the destination is shown in an iframe on the short domain instead of redirecting",
+                    "type": "boolean"
+                },
+                "health": {
+                    "$ref": "#/definitions/LinkHealth"
+                },
+                "id": {
+                    "type": "integer",
+                    "format": "int64"
+                },
+                "locked": {
+                    "type": "boolean"
+                },
+                "managementToken": {
+                    "description": "Secret required to update, delete or view stats of the link. Only returned on creation.",
+                    "type": "string"
+                },
+                "metadata": {
+                    "description": "JSON object kept with the link, left out when empty",
+                    "type": "object"
+                },
+                "notes": {
+                    "description": "Free-form notes kept with the link",
+                    "type": "string"
+                },
+                "openGraph": {
+                    "$ref": "#/definitions/OpenGraph"
+                },
+                "orgId": {
+                    "description": "Organization the link belongs to, omitted for personal links",
+                    "type": "integer"
+                },
+                "original": {
+                    "type": "string"
+                },
+                "pixels": {
+                    "description": "IDs of the retargeting pixels fired before the redirect",
+                    "type": "array",
+                    "items": {
+                        "type": "integer"
+                    }
+                },
+                "publicStats": {
+                    "description": "Whether anyone can see the link's stats page at /{shortCode}/stats",
+                    "type": "boolean"
+                },
+                "rotation": {
+                    "$ref": "#/definitions/Rotation"
+                },
+                "shortCode": {
+                    "type": "string"
+                },
+                "shortUrl": {
+                    "type": "string",
+                    "example": "https://sho.rt/abc123"
+                },
+                "suspiciousClicks": {
+                    "description": "Clicks excluded from accessCount by click fraud detection (one IP hammering the link, or datacenter networks)",
+                    "type": "integer",
+                    "format": "int64"
+                },
+                "tags": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "targets": {
+                    "$ref": "#/definitions/Targets"
+                },
+                "title": {
+                    "type": "string"
+                },
+                "trackConversions": {
+                    "description": "Whether each click's ID is passed to the destination for conversion tracking",
+                    "type": "boolean"
+                },
+                "uniqueClicks": {
+                    "description": "Clicks counting each visitor once per day, identified by a first-party cookie or a hash of IP and User-Agent",
+                    "type": "integer"
+                },
+                "updatedAt": {
+                    "type": "string",
+                    "format": "date-time"
+                }
+            }
+        },
+        "LinkHealth": {
+            "description": "Latest periodic check of the destination; absent until the link is checked",
+            "type": "object",
+            "properties": {
+                "broken": {
+                    "description": "The destination answered with a 4xx or 5xx status or could not be reached",
+                    "type": "boolean"
+                },
+                "brokenSince": {
+                    "type": "string",
+                    "format": "date-time"
+                },
+                "checkedAt": {
+                    "type": "string",
+                    "format": "date-time"
+                },
+                "error": {
+                    "description": "Why the request failed, e.g. a DNS lookup error",
+                    "type": "string"
+                },
+                "latencyMs": {
+                    "type": "integer",
+                    "format": "int64"
+                },
+                "redirects": {
+                    "description": "URLs the destination redirected through, in order",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "statusCode": {
+                    "description": "Status of the final response, absent when the request failed",
+                    "type": "integer",
+                    "example": 404
+                }
+            }
+        },
+        "URLStats": {
+            "allOf": [
+                {
+                    "$ref": "#/definitions/URL"
+                },
+                {
+                    "type": "object",
+                    "properties": {
+                        "breakdown": {
+                            "$ref": "#/definitions/ClickBreakdown"
+                        },
+                        "variants": {
+                            "type": "array",
+                            "description": "Clicks and conversions per variant, only present for A/B tested links",
+                            "items": {
+                                "$ref": "#/definitions/VariantStats"
+                            }
+                        },
+                        "rotation": {
+                            "type": "array",
+                            "description": "Clicks per destination, only present for rotating links",
+                            "items": {
+                                "$ref": "#/definitions/RotationStats"
+                            }
+                        },
+                        "conversions": {
+                            "type": "array",
+                            "description": "Conversions per goal, only present for links tracking conversions",
+                            "items": {
+                                "$ref": "#/definitions/ConversionStats"
+                            }
+                        }
+                    }
+                }
+            ]
+        },
+        "Rotation": {
+            "type": "object",
+            "properties": {
+                "destinations": {
+                    "description": "Up to 50 distinct destinations, in the order they are taken in turn",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "https://calendly.com/alice/intro",
+                        "https://calendly.com/bob/intro"
+                    ]
+                },
+                "mode": {
+                    "type": "string",
+                    "enum": [
+                        "round-robin",
+                        "random"
+                    ],
+                    "default": "round-robin"
+                }
+            }
+        },
+        "ClickCounts": {
+            "type": "object",
+            "properties": {
+                "botClicks": {
+                    "type": "integer"
+                },
+                "clicks": {
+                    "type": "integer"
+                },
+                "suspiciousClicks": {
+                    "type": "integer"
+                },
+                "uniqueClicks": {
+                    "type": "integer"
+                }
+            }
+        },
+        "OpenGraph": {
+            "type": "object",
+            "properties": {
+                "description": {
+                    "description": "Up to 1000 characters",
+                    "type": "string"
+                },
+                "image": {
+                    "description": "http(s) URL of the preview image",
+                    "type": "string",
+                    "example": "https://example.com/images/spring-sale.png"
+                },
+                "title": {
+                    "description": "Up to 300 characters",
+                    "type": "string",
+                    "example": "Spring sale - 30% off everything"
+                }
+            }
+        },
+        "AppLink": {
+            "type": "object",
+            "required": [
+                "uri"
+            ],
+            "properties": {
+                "androidStore": {
+                    "description": "Google Play URL Android visitors fall back to",
+                    "type": "string",
+                    "example": "https://play.google.com/store/apps/details?id=com.example.myapp"
+                },
+                "iosStore": {
+                    "description": "App Store URL iOS visitors fall back to",
+                    "type": "string",
+                    "example": "https://apps.apple.com/app/id123456789"
+                },
+                "uri": {
+                    "description": "URI with the app's own scheme; http(s), javascript and similar schemes are not allowed",
+                    "type": "string",
+                    "example": "myapp://item/42"
+                }
+            }
+        },
+        "RotationStats": {
+            "type": "object",
+            "properties": {
+                "clicks": {
+                    "type": "integer",
+                    "format": "int64"
+                },
+                "url": {
+                    "type": "string"
+                }
+            }
+        },
+        "ConversionStats": {
+            "type": "object",
+            "properties": {
+                "conversions": {
+                    "type": "integer",
+                    "format": "int64"
+                },
+                "name": {
+                    "description": "Goal the conversions were reported for",
+                    "type": "string"
+                },
+                "rate": {
+                    "description": "Share of the link's clicks that converted",
+                    "type": "number"
+                },
+                "value": {
+                    "description": "Sum of the values reported with the conversions",
+                    "type": "number"
+                }
+            }
+        },
+        "BlockedDestination": {
+            "type": "object",
+            "properties": {
+                "createdAt": {
+                    "type": "string",
+                    "format": "date-time"
+                },
+                "createdBy": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer",
+                    "format": "int64"
+                },
+                "kind": {
+                    "type": "string",
+                    "enum": [
+                        "domain",
+                        "regex"
+                    ]
+                },
+                "note": {
+                    "type": "string"
+                },
+                "pattern": {
+                    "type": "string"
+                }
+            }
+        },
+        "FeatureFlag": {
+            "type": "object",
+            "properties": {
+                "enabled": {
+                    "type": "boolean"
+                },
+                "environment": {
+                    "description": "APP_ENV the override is limited to; absent for every environment",
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string",
+                    "example": "interstitial"
+                },
+                "orgId": {
+                    "description": "Organization the override is limited to; absent for every organization",
+                    "type": "integer"
+                },
+                "updatedAt": {
+                    "type": "string",
+                    "format": "date-time"
+                },
+                "updatedBy": {
+                    "type": "string"
+                }
+            }
+        },
+        "FeatureFlagStatus": {
+            "type": "object",
+            "properties": {
+                "default": {
+                    "description": "Whether FEATURE_FLAGS turns the flag on",
+                    "type": "boolean"
+                },
+                "enabled": {
+                    "description": "Whether the flag is on in this environment for links outside any organization",
+                    "type": "boolean"
+                },
+                "name": {
+                    "type": "string",
+                    "example": "interstitial"
+                },
+                "overrides": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/FeatureFlag"
+                    }
+                }
+            }
+        },
+        "Tenant": {
+            "type": "object",
+            "properties": {
+                "createdAt": {
+                    "type": "string",
+                    "format": "date-time"
+                },
+                "hostnames": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "go.acme.com"
+                    ]
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "name": {
+                    "type": "string",
+                    "example": "Acme"
+                }
+            }
+        },
+        "TenantRequest": {
+            "type": "object",
+            "required": [
+                "name"
+            ],
+            "properties": {
+                "hostnames": {
+                    "description": "Hostnames routing requests without an API key to the tenant; each may belong to one tenant only",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "go.acme.com"
+                    ]
+                },
+                "name": {
+                    "type": "string",
+                    "example": "Acme"
+                }
+            }
+        },
+        "APIKey": {
+            "type": "object",
+            "properties": {
+                "createdAt": {
+                    "type": "string",
+                    "format": "date-time"
+                },
+                "createdBy": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "name": {
+                    "type": "string",
+                    "example": "Partner integration"
+                },
+                "scopes": {
+                    "description": "links:read lists and reads any link of the tenant, links:write creates and changes links, stats:read reads any link's stats and admin includes every scope and the admin API. Empty for keys that only select the tenant.",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "tenantId": {
+                    "type": "integer"
+                }
+            }
+        },
+        "PlanRequest": {
+            "type": "object",
+            "properties": {
+                "plan": {
+                    "description": "One of the plans in QUOTA_PLANS, or empty for QUOTA_DEFAULT_PLAN",
+                    "type": "string",
+                    "example": "pro"
+                }
+            }
+        },
+        "BillingPlan": {
+            "type": "object",
+            "properties": {
+                "customDomains": {
+                    "type": "boolean"
+                },
+                "name": {
+                    "type": "string",
+                    "example": "pro"
+                },
+                "sso": {
+                    "type": "boolean"
+                },
+                "stripePriceId": {
+                    "type": "string",
+                    "example": "price_1PxYz"
+                },
+                "updatedAt": {
+                    "type": "string",
+                    "format": "date-time"
+                }
+            }
+        },
+        "PlanOffer": {
+            "description": "A plan for sale with its limits; 0 means no limit",
+            "type": "object",
+            "properties": {
+                "customDomains": {
+                    "type": "boolean"
+                },
+                "links": {
+                    "type": "integer"
+                },
+                "monthlyApiCalls": {
+                    "type": "integer",
+                    "format": "int64"
+                },
+                "monthlyLinks": {
+                    "type": "integer"
+                },
+                "name": {
+                    "type": "string",
+                    "example": "pro"
+                },
+                "sso": {
+                    "type": "boolean"
+                },
+                "stripePriceId": {
+                    "type": "string",
+                    "example": "price_1PxYz"
+                },
+                "updatedAt": {
+                    "type": "string",
+                    "format": "date-time"
+                }
+            }
+        },
+        "Subscription": {
+            "type": "object",
+            "properties": {
+                "currentPeriodEnd": {
+                    "type": "string",
+                    "format": "date-time"
+                },
+                "customerId": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string",
+                    "example": "sub_1PxYz"
+                },
+                "orgId": {
+                    "type": "integer"
+                },
+                "plan": {
+                    "type": "string",
+                    "example": "pro"
+                },
+                "status": {
+                    "description": "Stripe subscription status, e.g. active, past_due or canceled",
+                    "type": "string"
+                },
+                "updatedAt": {
+                    "type": "string",
+                    "format": "date-time"
+                },
+                "userId": {
+                    "type": "string"
+                }
+            }
+        },
+        "QuotaUsage": {
+            "type": "object",
+            "properties": {
+                "limit": {
+                    "description": "0 means no limit",
+                    "type": "integer",
+                    "format": "int64"
+                },
+                "used": {
+                    "type": "integer",
+                    "format": "int64"
+                }
+            }
+        },
+        "AccountUsage": {
+            "type": "object",
+            "properties": {
+                "links": {
+                    "$ref": "#/definitions/QuotaUsage"
+                },
+                "monthlyApiCalls": {
+                    "$ref": "#/definitions/QuotaUsage"
+                },
+                "monthlyLinks": {
+                    "$ref": "#/definitions/QuotaUsage"
+                },
+                "orgId": {
+                    "type": "integer"
+                },
+                "periodEnd": {
+                    "type": "string",
+                    "format": "date-time"
+                },
+                "periodStart": {
+                    "type": "string",
+                    "format": "date-time"
+                },
+                "plan": {
+                    "type": "string",
+                    "example": "free"
+                }
+            }
+        },
+        "IPRule": {
+            "type": "object",
+            "properties": {
+                "action": {
+                    "type": "string",
+                    "enum": [
+                        "block",
+                        "admin_allow"
+                    ]
+                },
+                "cidr": {
+                    "type": "string",
+                    "example": "203.0.113.0/24"
+                },
+                "createdAt": {
+                    "type": "string",
+                    "format": "date-time"
+                },
+                "createdBy": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer",
+                    "format": "int64"
+                },
+                "note": {
+                    "type": "string"
+                }
+            }
+        },
+        "Variant": {
+            "type": "object",
+            "properties": {
+                "name": {
+                    "type": "string",
+                    "example": "b"
+                },
+                "url": {
+                    "type": "string",
+                    "example": "https://example.com/landing-b"
+                },
+                "weight": {
+                    "type": "integer",
+                    "example": 50
+                }
+            }
+        },
+        "VariantStats": {
+            "allOf": [
+                {
+                    "$ref": "#/definitions/Variant"
+                },
+                {
+                    "type": "object",
+                    "properties": {
+                        "clicks": {
+                            "type": "integer",
+                            "format": "int64"
+                        },
+                        "conversions": {
+                            "type": "integer",
+                            "format": "int64"
+                        }
+                    }
+                }
+            ]
+        },
+        "ClickBreakdown": {
+            "type": "object",
+            "properties": {
+                "browsers": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/BreakdownEntry"
+                    }
+                },
+                "devices": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/BreakdownEntry"
+                    }
+                },
+                "os": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/BreakdownEntry"
+                    }
+                }
+            }
+        },
+        "BreakdownEntry": {
+            "type": "object",
+            "properties": {
+                "clicks": {
+                    "type": "integer"
+                },
+                "name": {
+                    "type": "string",
+                    "example": "mobile"
+                }
+            }
+        },
+        "TimeSeries": {
+            "type": "object",
+            "properties": {
+                "buckets": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/TimeBucket"
+                    }
+                },
+                "from": {
+                    "type": "string",
+                    "format": "date-time"
+                },
+                "interval": {
+                    "type": "string",
+                    "example": "day"
+                },
+                "shortCode": {
+                    "type": "string",
+                    "example": "abc123"
+                },
+                "to": {
+                    "type": "string",
+                    "format": "date-time"
+                }
+            }
+        },
+        "TimeBucket": {
+            "type": "object",
+            "properties": {
+                "clicks": {
+                    "type": "integer"
+                },
+                "start": {
+                    "type": "string",
+                    "format": "date-time"
+                }
+            }
+        },
+        "TransferRequest": {
+            "description": "Exactly one recipient",
+            "type": "object",
+            "properties": {
+                "toOrgId": {
+                    "type": "integer"
+                },
+                "toUserId": {
+                    "type": "string"
+                }
+            }
+        },
+        "Transfer": {
+            "type": "object",
+            "properties": {
+                "campaignId": {
+                    "description": "Campaign transferred with its links, omitted for link transfers",
+                    "type": "integer"
+                },
+                "createdAt": {
+                    "type": "string",
+                    "format": "date-time"
+                },
+                "domain": {
+                    "description": "Custom domain of the link",
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "requestedBy": {
+                    "type": "string"
+                },
+                "resolvedAt": {
+                    "type": "string",
+                    "format": "date-time"
+                },
+                "resolvedBy": {
+                    "description": "Who accepted, declined or cancelled the transfer",
+                    "type": "string"
+                },
+                "shortCode": {
+                    "description": "Link transferred, omitted for campaign transfers",
+                    "type": "string"
+                },
+                "status": {
+                    "type": "string",
+                    "enum": [
+                        "pending",
+                        "accepted",
+                        "declined",
+                        "cancelled"
+                    ]
+                },
+                "toOrgId": {
+                    "type": "integer"
+                },
+                "toUserId": {
+                    "type": "string"
+                }
+            }
+        },
+        "Campaign": {
+            "type": "object",
+            "properties": {
+                "createdAt": {
+                    "type": "string",
+                    "format": "date-time"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "links": {
+                    "description": "Number of links attached",
+                    "type": "integer"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "ownerId": {
+                    "description": "User who created the campaign, omitted for campaigns created with the admin token",
+                    "type": "string"
+                },
+                "pixels": {
+                    "description": "IDs of the retargeting pixels fired before the redirects of its links",
+                    "type": "array",
+                    "items": {
+                        "type": "integer"
+                    }
+                }
+            }
+        },
+        "CampaignStats": {
+            "allOf": [
+                {
+                    "$ref": "#/definitions/Campaign"
+                },
+                {
+                    "type": "object",
+                    "properties": {
+                        "clicks": {
+                            "type": "integer"
+                        },
+                        "botClicks": {
+                            "type": "integer"
+                        },
+                        "breakdown": {
+                            "$ref": "#/definitions/ClickBreakdown"
+                        },
+                        "urls": {
+                            "type": "array",
+                            "items": {
+                                "type": "object",
+                                "properties": {
+                                    "shortCode": {
+                                        "type": "string"
+                                    },
+                                    "clicks": {
+                                        "type": "integer"
+                                    },
+                                    "botClicks": {
+                                        "type": "integer"
+                                    }
+                                }
+                            }
+                        }
+                    }
+                }
+            ]
+        },
+        "AnomalousLink": {
+            "type": "object",
+            "properties": {
+                "clicks": {
+                    "type": "integer"
+                },
+                "reasons": {
+                    "type": "array",
+                    "items": {
+                        "type": "object",
+                        "properties": {
+                            "firstSeen": {
+                                "type": "string",
+                                "format": "date-time"
+                            },
+                            "lastSeen": {
+                                "type": "string",
+                                "format": "date-time"
+                            },
+                            "occurrences": {
+                                "description": "Excluded clicks for ip_hammering and datacenter_ip, flagged minutes for traffic_spike",
+                                "type": "integer"
+                            },
+                            "reason": {
+                                "type": "string",
+                                "enum": [
+                                    "ip_hammering",
+                                    "datacenter_ip",
+                                    "traffic_spike"
+                                ]
+                            }
+                        }
+                    }
+                },
+                "shortCode": {
+                    "type": "string"
+                },
+                "suspiciousClicks": {
+                    "type": "integer"
+                }
+            }
+        },
+        "Organization": {
+            "type": "object",
+            "properties": {
+                "createdAt": {
+                    "type": "string",
+                    "format": "date-time"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "requireTwoFactor": {
+                    "type": "boolean"
+                },
+                "role": {
+                    "description": "The requesting user's role",
+                    "type": "string"
+                }
+            }
+        },
+        "InactivityPolicy": {
+            "type": "object",
+            "properties": {
+                "action": {
+                    "type": "string",
+                    "enum": [
+                        "archive",
+                        "disable"
+                    ]
+                },
+                "inherited": {
+                    "description": "The organization has no policy of its own and follows the instance default",
+                    "type": "boolean"
+                },
+                "months": {
+                    "description": "Months without clicks before links are acted on; 0 never acts",
+                    "type": "integer",
+                    "example": 12
+                }
+            }
+        },
+        "OrgSSORequest": {
+            "type": "object",
+            "required": [
+                "idpEntityId",
+                "ssoUrl",
+                "certificate"
+            ],
+            "properties": {
+                "certificate": {
+                    "description": "The identity provider's signing certificate, PEM or base64",
+                    "type": "string"
+                },
+                "defaultRole": {
+                    "type": "string",
+                    "enum": [
+                        "owner",
+                        "editor",
+                        "viewer"
+                    ],
+                    "default": "viewer"
+                },
+                "idpEntityId": {
+                    "type": "string",
+                    "example": "https://idp.example.com/metadata"
+                },
+                "ssoUrl": {
+                    "description": "The identity provider's HTTP-Redirect single sign-on URL",
+                    "type": "string",
+                    "example": "https://idp.example.com/sso"
+                }
+            }
+        },
+        "Pixel": {
+            "type": "object",
+            "properties": {
+                "createdAt": {
+                    "type": "string",
+                    "format": "date-time"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "orgId": {
+                    "type": "integer"
+                },
+                "pixelId": {
+                    "type": "string"
+                },
+                "provider": {
+                    "type": "string",
+                    "enum": [
+                        "facebook",
+                        "google"
+                    ]
+                }
+            }
+        },
+        "ReservedPrefix": {
+            "type": "object",
+            "properties": {
+                "createdAt": {
+                    "type": "string",
+                    "format": "date-time"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "orgId": {
+                    "type": "integer"
+                },
+                "prefix": {
+                    "type": "string",
+                    "example": "acme-"
+                }
+            }
+        },
+        "WarehouseExport": {
+            "type": "object",
+            "properties": {
+                "destination": {
+                    "type": "string",
+                    "enum": [
+                        "parquet",
+                        "bigquery",
+                        "snowflake"
+                    ]
+                },
+                "exportedThrough": {
+                    "description": "Last day exported, null before the first export",
+                    "type": "string",
+                    "format": "date-time"
+                },
+                "hasCredentials": {
+                    "type": "boolean"
+                },
+                "lastError": {
+                    "description": "Why the last run failed, omitted after a successful run",
+                    "type": "string"
+                },
+                "orgId": {
+                    "type": "integer"
+                },
+                "settings": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                },
+                "updatedAt": {
+                    "type": "string",
+                    "format": "date-time"
+                }
+            }
+        },
+        "OrgSSO": {
+            "type": "object",
+            "properties": {
+                "certificate": {
+                    "type": "string"
+                },
+                "defaultRole": {
+                    "type": "string"
+                },
+                "idpEntityId": {
+                    "type": "string"
+                },
+                "orgId": {
+                    "type": "integer"
+                },
+                "scimEnabled": {
+                    "type": "boolean"
+                },
+                "ssoUrl": {
+                    "type": "string"
+                },
+                "updatedAt": {
+                    "type": "string",
+                    "format": "date-time"
+                }
+            }
+        },
+        "SCIMUser": {
+            "type": "object",
+            "properties": {
+                "active": {
+                    "description": "Whether the user is a member of the organization",
+                    "type": "boolean"
+                },
+                "displayName": {
+                    "type": "string"
+                },
+                "emails": {
+                    "type": "array",
+                    "items": {
+                        "type": "object",
+                        "properties": {
+                            "primary": {
+                                "type": "boolean"
+                            },
+                            "type": {
+                                "type": "string"
+                            },
+                            "value": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                },
+                "id": {
+                    "type": "string",
+                    "readOnly": true
+                },
+                "name": {
+                    "type": "object",
+                    "properties": {
+                        "familyName": {
+                            "type": "string"
+                        },
+                        "formatted": {
+                            "type": "string"
+                        },
+                        "givenName": {
+                            "type": "string"
+                        }
+                    }
+                },
+                "schemas": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "urn:ietf:params:scim:schemas:core:2.0:User"
+                    ]
+                },
+                "userName": {
+                    "description": "The NameID the identity provider signs the user in with",
+                    "type": "string",
+                    "example": "ann@example.com"
+                }
+            }
+        },
+        "Member": {
+            "type": "object",
+            "properties": {
+                "addedAt": {
+                    "type": "string",
+                    "format": "date-time"
+                },
+                "role": {
+                    "type": "string",
+                    "enum": [
+                        "owner",
+                        "editor",
+                        "viewer"
+                    ]
+                },
+                "userId": {
+                    "type": "string"
+                }
+            }
+        },
+        "SecondFactor": {
+            "description": "A code from the authenticator app or a recovery code",
+            "type": "object",
+            "properties": {
+                "code": {
+                    "type": "string",
+                    "example": "123456"
+                },
+                "recoveryCode": {
+                    "type": "string",
+                    "example": "abcd2345-efgh6789"
+                }
+            }
+        },
+        "RecoveryCodes": {
+            "type": "object",
+            "properties": {
+                "recoveryCodes": {
+                    "description": "Single-use codes for signing in without the app",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "User": {
+            "type": "object",
+            "properties": {
+                "createdAt": {
+                    "type": "string",
+                    "format": "date-time"
+                },
+                "email": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "role": {
+                    "type": "string"
+                },
+                "twoFactorEnabled": {
+                    "type": "boolean"
+                }
+            }
+        },
+        "URLVersion": {
+            "type": "object",
+            "properties": {
+                "createdAt": {
+                    "type": "string",
+                    "format": "date-time"
+                },
+                "createdBy": {
+                    "type": "string"
+                },
+                "current": {
+                    "type": "boolean"
+                },
+                "id": {
+                    "type": "integer",
+                    "format": "int64"
+                },
+                "original": {
+                    "type": "string"
+                }
+            }
+        },
+        "AuditEntry": {
+            "type": "object",
+            "properties": {
+                "action": {
+                    "type": "string"
+                },
+                "actor": {
+                    "description": "User ID, admin actor, \"management-token\" or \"anonymous\"",
+                    "type": "string"
+                },
+                "entityId": {
+                    "type": "string"
+                },
+                "entityType": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer",
+                    "format": "int64"
+                },
+                "newValue": {
+                    "type": "object"
+                },
+                "occurredAt": {
+                    "type": "string",
+                    "format": "date-time"
+                },
+                "oldValue": {
+                    "type": "object"
+                },
+                "remoteIp": {
+                    "description": "Salted hash of the client IP; the address itself is not stored",
+                    "type": "string"
+                }
+            }
+        },
+        "ClickImport": {
+            "type": "object",
+            "properties": {
+                "externalId": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "importedAt": {
+                    "type": "string",
+                    "format": "date-time"
+                },
+                "importedBy": {
+                    "type": "string"
+                },
+                "note": {
+                    "type": "string"
+                },
+                "source": {
+                    "type": "string"
+                },
+                "totalClicks": {
+                    "type": "integer",
+                    "format": "int64"
+                }
+            }
+        },
+        "PageMetadata": {
+            "type": "object",
+            "properties": {
+                "description": {
+                    "type": "string"
+                },
+                "shortCode": {
+                    "type": "string"
+                },
+                "title": {
+                    "type": "string"
+                }
+            }
+        },
+        "HealthResponse": {
+            "type": "object",
+            "properties": {
+                "error": {
+                    "type": "string"
+                },
+                "status": {
+                    "type": "string"
+                }
+            }
+        },
+        "TagCount": {
+            "type": "object",
+            "properties": {
+                "count": {
+                    "type": "integer"
+                },
+                "tag": {
+                    "type": "string"
+                }
+            }
+        },
+        "BatchResponse": {
+            "type": "object",
+            "properties": {
+                "deleted": {
+                    "description": "Number of links deleted (DELETE only)",
+                    "type": "integer"
+                },
+                "results": {
+                    "type": "array",
+                    "items": {
+                        "type": "object",
+                        "properties": {
+                            "error": {
+                                "type": "string"
+                            },
+                            "shortCode": {
+                                "type": "string"
+                            },
+                            "status": {
+                                "type": "string",
+                                "enum": [
+                                    "updated",
+                                    "deleted",
+                                    "not_found",
+                                    "forbidden",
+                                    "locked",
+                                    "failed"
+                                ]
+                            }
+                        }
+                    }
+                },
+                "updated": {
+                    "description": "Number of links updated (PATCH only)",
+                    "type": "integer"
+                }
+            }
+        },
+        "MessageResponse": {
+            "type": "object",
+            "properties": {
+                "message": {
+                    "type": "string"
+                }
+            }
+        },
+        "ErrorResponse": {
+            "type": "object",
+            "properties": {
+                "error": {
+                    "type": "object",
+                    "properties": {
+                        "code": {
+                            "description": "Machine-readable error code",
+                            "type": "string",
+                            "enum": [
+                                "validation_failed",
+                                "unauthorized",
+                                "forbidden",
+                                "not_found",
+                                "not_acceptable",
+                                "conflict",
+                                "gone",
+                                "payload_too_large",
+                                "unprocessable",
+                                "locked",
+                                "rate_limited",
+                                "quota_exceeded",
+                                "internal_error",
+                                "upstream_error",
+                                "unavailable"
+                            ],
+                            "example": "not_found"
+                        },
+                        "details": {
+                            "description": "Additional data about the failure, such as the offending values",
+                            "type": "object"
+                        },
+                        "message": {
+                            "description": "Human-readable description, which may change",
+                            "type": "string",
+                            "example": "Short URL not found"
+                        },
+                        "requestId": {
+                            "description": "ID of the request, as sent in the X-Request-ID header",
+                            "type": "string"
+                        }
+                    }
                 }
             }
         }
@@ -318,7 +9085,7 @@ var SwaggerInfo = &swag.Spec{
 	BasePath:         "/",
 	Schemes:          []string{"http"},
 	Title:            "URL Shortener API",
-	Description:      "API for shortening URLs, managing redirects, and tracking statistics",
+	Description:      "API for shortening URLs, managing redirects, and tracking statistics. The management API is versioned under /api/v1; the unversioned /urls routes remain as deprecated aliases. When JWT authentication is configured, endpoints that create or change links require a bearer JWT with a write role. A deployment may serve several tenants, each with its own links, organizations, campaigns and rate limit buckets; a request belongs to the tenant of its X-API-Key header or, without one, of its hostname, and to the default tenant otherwise. Short codes are unique per custom domain, so the same code may name different links on different domains; endpoints taking a short code address the link of the default base URL unless a domain query parameter names its custom domain.",
 	InfoInstanceName: "swagger",
 	SwaggerTemplate:  docTemplate,
 }
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+	"url-shortener/db"
+	"url-shortener/pkg/apierror"
+
+	"github.com/gin-gonic/gin"
+)
+
+// customCodePattern matches the short codes clients may choose for their links
+var customCodePattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// validCustomCode reports whether a client may choose code for a link
+func validCustomCode(code string) bool {
+	return customCodePattern.MatchString(code) && !slices.Contains(reservedCodes, strings.ToLower(code))
+}
+
+// linkDomain scopes the short codes an API request names to the custom
+// domain given as ?domain=, or to the default base URL without one
+func linkDomain(c *gin.Context) {
+	domain := strings.ToLower(c.Query("domain"))
+	if domain != "" && !domainPattern.MatchString(domain) {
+		apierror.Abort(c, apierror.Validation("Invalid domain"))
+		return
+	}
+	c.Request = c.Request.WithContext(db.WithDomain(c.Request.Context(), domain))
+	c.Next()
+}
+
+// hostDomain is the custom domain a short link was followed on, or "" when
+// the request reached the default base URL
+func hostDomain(c *gin.Context) string {
+	host := requestHostname(c)
+	if base, err := url.Parse(publicBaseURL); err == nil && normalizeHostname(base.Hostname()) == host {
+		return ""
+	}
+	return host
+}
+
+// resolveLink finds the link a short code leads to on the host a request was
+// sent to: the host's own link with that code or, without one, the link of
+// the default base URL, which every host serves. The request is scoped to
+// the domain of the link found, so its clicks are counted on that link.
+func resolveLink(c *gin.Context, shortCode string) (*db.URL, error) {
+	ctx := c.Request.Context()
+	domain := hostDomain(c)
+	link, err := database.ResolveShortCode(db.WithDomain(ctx, domain), shortCode)
+	if errors.Is(err, sql.ErrNoRows) && domain != "" {
+		domain = ""
+		link, err = database.ResolveShortCode(db.WithDomain(ctx, domain), shortCode)
+	}
+	if err == nil {
+		c.Request = c.Request.WithContext(db.WithDomain(ctx, domain))
+	}
+	return link, err
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+	"url-shortener/db"
+	"url-shortener/pkg/apierror"
+	"url-shortener/pkg/cdn"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxCacheTTL caps the per-link CDN cache TTL, in seconds
+const maxCacheTTL = 7 * 24 * 60 * 60
+
+var (
+	// redirectCacheTTL and redirectBrowserTTL are how long CDNs and browsers
+	// may cache redirects of links without a TTL of their own
+	redirectCacheTTL   time.Duration
+	redirectBrowserTTL time.Duration
+	// edgePurger evicts changed links from the CDN; nil when no CDN is configured
+	edgePurger *cdn.Purger
+)
+
+// edgeCacheKey tags a link's cached redirect so it can be purged on its own
+func edgeCacheKey(shortCode string) string {
+	return "link-" + shortCode
+}
+
+// edgeCacheable reports whether every visitor of link is sent to the same
+// place, so one cached redirect can answer them all
+func edgeCacheable(link *db.URL) bool {
+	return len(link.Targets) == 0 && len(link.Variants) == 0 && link.Rotation == nil &&
+		link.AppLink == nil && link.OpenGraph == nil && !link.Frame &&
+		len(link.Pixels) == 0 && len(link.CampaignPixels) == 0 && clickIDParamFor(link) == ""
+}
+
+// cacheRedirect sets the headers that let a CDN cache link's redirect for
+// its TTL, tagged with the link's cache key. Links that opted out, or whose
+// redirect depends on the visitor, are marked uncacheable instead; nothing is
+// set while edge caching is not in use. Responses setting a cookie are never
+// cached, so one visitor's cookie is not handed to others.
+func cacheRedirect(c *gin.Context, link *db.URL) {
+	ttl := redirectCacheTTL
+	if link.CacheTTL != nil {
+		ttl = time.Duration(*link.CacheTTL) * time.Second
+	} else if ttl <= 0 {
+		return
+	}
+
+	if ttl <= 0 || !edgeCacheable(link) || c.Writer.Header().Get("Set-Cookie") != "" {
+		c.Header("Cache-Control", "private, no-store")
+		return
+	}
+
+	seconds := strconv.Itoa(int(ttl.Seconds()))
+	browser := strconv.Itoa(int(min(redirectBrowserTTL, ttl).Seconds()))
+	c.Header("Cache-Control", "public, max-age="+browser+", s-maxage="+seconds)
+	c.Header("Surrogate-Control", "max-age="+seconds)
+	// Fastly purges by Surrogate-Key and Cloudflare by Cache-Tag
+	c.Header("Surrogate-Key", edgeCacheKey(link.ShortCode))
+	c.Header("Cache-Tag", edgeCacheKey(link.ShortCode))
+}
+
+// purgeEdge evicts the cached redirects of links from the CDN in the
+// background, or everything cached when no short codes are given
+func purgeEdge(shortCodes ...string) {
+	keys := make([]string, 0, len(shortCodes))
+	for _, shortCode := range shortCodes {
+		keys = append(keys, edgeCacheKey(shortCode))
+	}
+
+	runInBackground("cdn-purge", func(ctx context.Context) error {
+		if err := edgePurger.Purge(ctx, keys...); err != nil {
+			return fmt.Errorf("purging %d links from the CDN (none means all): %w", len(shortCodes), err)
+		}
+		return nil
+	})
+}
+
+// setURLCacheTTL sets how many seconds a CDN may cache the link's redirect;
+// 0 keeps it from being cached and null restores the instance default
+func setURLCacheTTL(c *gin.Context) {
+	shortCode := c.Param("shortCode")
+
+	var request struct {
+		TTL *int `json:"ttl"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		respondError(c, apierror.Validation("Invalid request body"))
+		return
+	}
+	if request.TTL != nil && (*request.TTL < 0 || *request.TTL > maxCacheTTL) {
+		respondError(c, apierror.Validation("ttl must be between 0 and "+strconv.Itoa(maxCacheTTL)+" seconds"))
+		return
+	}
+
+	previous, err := database.GetURLByShortCode(c.Request.Context(), shortCode)
+	if err != nil {
+		respondError(c, notFound(err, "Short URL not found"))
+		return
+	}
+
+	if err := database.SetCacheTTL(c.Request.Context(), shortCode, request.TTL); err != nil {
+		respondError(c, notFound(err, "Short URL not found"))
+		return
+	}
+
+	recordAudit(c, auditUpdate, "url", shortCode, gin.H{"cacheTtl": previous.CacheTTL}, gin.H{"cacheTtl": request.TTL})
+	c.JSON(http.StatusOK, gin.H{"message": "Cache TTL updated successfully", "cacheTtl": request.TTL})
+}
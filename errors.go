@@ -0,0 +1,42 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"url-shortener/db"
+	"url-shortener/pkg/apierror"
+
+	"github.com/gin-gonic/gin"
+)
+
+// respondError answers the request with err in the JSON error envelope.
+// Errors from the database layer are mapped to their status here, so
+// handlers can pass them through; anything unrecognized is a 500.
+func respondError(c *gin.Context, err error) {
+	apierror.Abort(c, toAPIError(err))
+}
+
+// toAPIError maps errors from the database layer to the error reported for
+// them, leaving anything unrecognized to be reported as an internal error
+func toAPIError(err error) error {
+	var apiErr *apierror.Error
+	switch {
+	case errors.As(err, &apiErr):
+	case errors.Is(err, db.ErrLocked):
+		err = apierror.Locked("Short URL is locked")
+	case errors.Is(err, db.ErrNotFound):
+		err = apierror.NotFound("Short URL not found")
+	case errors.Is(err, sql.ErrNoRows):
+		err = apierror.NotFound("Not found")
+	}
+	return err
+}
+
+// notFound reports a lookup that found nothing as a 404 with message, leaving
+// other failures to be reported as internal errors
+func notFound(err error, message string) error {
+	if errors.Is(err, sql.ErrNoRows) || errors.Is(err, db.ErrNotFound) {
+		return apierror.NotFound(message)
+	}
+	return err
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"url-shortener/config"
+	"url-shortener/db"
+	"url-shortener/db/dbtest"
+	"url-shortener/pkg/base62"
+
+	"github.com/gin-gonic/gin"
+)
+
+// useStubDatabase points the handlers at a database answered by respond for
+// the rest of the test
+func useStubDatabase(t *testing.T, respond dbtest.Responder) {
+	conn := dbtest.Open(respond)
+	previous := database
+	database = db.New(conn, config.GetDefaultConfig())
+	t.Cleanup(func() {
+		database = previous
+		conn.Close()
+	})
+}
+
+func TestGetOriginalURLLookupErrors(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	pages, err := loadPageTemplates(config.GetDefaultConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		result dbtest.Result
+		want   int
+	}{
+		{name: "missing", result: dbtest.Result{Columns: []string{"id"}}, want: http.StatusNotFound},
+		{name: "lookup fails", result: dbtest.Result{Err: errors.New("connection reset by peer")}, want: http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useStubDatabase(t, func(string, []driver.NamedValue) dbtest.Result {
+				return tt.result
+			})
+			r := gin.New()
+			r.SetHTMLTemplate(pages)
+			r.GET("/:shortCode", getOriginalURL)
+
+			for _, shortCode := range []string{"golden-missing", base62.EncodeID(42)} {
+				w := httptest.NewRecorder()
+				r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/"+shortCode, nil))
+				if w.Code != tt.want {
+					t.Errorf("GET /%s: want %d, got %d", shortCode, tt.want, w.Code)
+				}
+			}
+		})
+	}
+}
+
+func TestRespondErrorStatus(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tests := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{name: "missing link", err: fmt.Errorf("%w: golden", db.ErrNotFound), want: http.StatusNotFound},
+		{name: "locked link", err: db.ErrLocked, want: http.StatusLocked},
+		{name: "missing row", err: notFound(fmt.Errorf("scanning: %w", sql.ErrNoRows), "Short URL not found"), want: http.StatusNotFound},
+		{name: "database failure", err: errors.New("connection reset by peer"), want: http.StatusInternalServerError},
+		{name: "database failure through notFound", err: notFound(errors.New("connection reset by peer"), "Short URL not found"), want: http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest(http.MethodGet, "/", nil)
+
+			respondError(c, tt.err)
+			if w.Code != tt.want {
+				t.Fatalf("want %d, got %d", tt.want, w.Code)
+			}
+		})
+	}
+}
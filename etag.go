@@ -0,0 +1,110 @@
+package main
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+	"url-shortener/db"
+	"url-shortener/pkg/apierror"
+
+	"github.com/gin-gonic/gin"
+)
+
+// weakETag derives a weak entity tag from a version fingerprint
+func weakETag(version string) string {
+	sum := sha1.Sum([]byte(version))
+	return `W/"` + hex.EncodeToString(sum[:8]) + `"`
+}
+
+// notModified sets the ETag header and answers 304 when the client's
+// If-None-Match already covers it. Callers must stop handling the request when it returns true.
+func notModified(c *gin.Context, version string) bool {
+	etag := weakETag(version)
+	c.Header("ETag", etag)
+
+	ifNoneMatch := c.GetHeader("If-None-Match")
+	if ifNoneMatch == "" {
+		return false
+	}
+
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+			c.Status(http.StatusNotModified)
+			return true
+		}
+	}
+
+	return false
+}
+
+// linkETag is a strong entity tag for the editable state of a link. It
+// changes with every edit, but not with clicks, so If-Match only fails when
+// someone else changed the link.
+func linkETag(link *db.URL) string {
+	sum := sha1.Sum([]byte(link.ShortCode + "-" + link.UpdatedAt))
+	return `"` + hex.EncodeToString(sum[:8]) + `"`
+}
+
+// editConflict checks the preconditions of an edit against the link as
+// stored: the If-Match header, and updatedAt when the client sent the
+// updatedAt it last read. It writes a 409 and returns true when the link was
+// changed since. Callers must stop handling the request when it returns true.
+func editConflict(c *gin.Context, link *db.URL, updatedAt *time.Time) bool {
+	current := parseTime(link.UpdatedAt)
+	conflict := updatedAt != nil && !updatedAt.Equal(current)
+
+	if ifMatch := c.GetHeader("If-Match"); ifMatch != "" && !conflict {
+		etag := linkETag(link)
+		conflict = true
+		for _, candidate := range strings.Split(ifMatch, ",") {
+			// Weak tags never match, as If-Match compares strongly
+			if candidate = strings.TrimSpace(candidate); candidate == "*" || candidate == etag {
+				conflict = false
+				break
+			}
+		}
+	}
+	if !conflict {
+		return false
+	}
+
+	respondConflict(c, link)
+	return true
+}
+
+// respondConflict answers an edit of a link changed since the client read it
+// with a 409 carrying the link's current ETag and updatedAt
+func respondConflict(c *gin.Context, link *db.URL) {
+	c.Header("ETag", linkETag(link))
+	respondError(c, apierror.Conflict("Short URL was changed since it was read; reload it and try again").
+		WithDetails(gin.H{"updatedAt": parseTime(link.UpdatedAt)}))
+}
+
+// editVersion is the version of link an edit must apply to: the one read by
+// the handler when the client sent a precondition, which editConflict
+// checked it against, and any version otherwise
+func editVersion(c *gin.Context, link *db.URL, updatedAt *time.Time) string {
+	if updatedAt == nil && c.GetHeader("If-Match") == "" {
+		return ""
+	}
+	return link.UpdatedAt
+}
+
+// respondEditError answers an edit that failed in the database. An edit that
+// lost the race against another one gets the same 409 as a stale
+// precondition.
+func respondEditError(c *gin.Context, shortCode string, err error) {
+	if errors.Is(err, db.ErrEditConflict) {
+		if link, getErr := database.GetURLByShortCode(c.Request.Context(), shortCode); getErr == nil {
+			respondConflict(c, link)
+			return
+		}
+		respondError(c, apierror.Conflict("Short URL was changed since it was read; reload it and try again"))
+		return
+	}
+	respondError(c, notFound(err, "Short URL not found"))
+}
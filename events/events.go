@@ -0,0 +1,57 @@
+package events
+
+import (
+	_ "embed"
+	"time"
+)
+
+// SchemaVersion is bumped whenever an event payload changes incompatibly
+const SchemaVersion = "1"
+
+// Type identifies the kind of event carried in an Envelope
+type Type string
+
+const (
+	URLCreated Type = "url.created"
+	URLUpdated Type = "url.updated"
+	URLDeleted Type = "url.deleted"
+	URLClicked Type = "url.clicked"
+)
+
+// Envelope wraps every event delivered to webhooks and streams
+type Envelope struct {
+	ID         string    `json:"id"`
+	Type       Type      `json:"type"`
+	Version    string    `json:"version"`
+	OccurredAt time.Time `json:"occurredAt"`
+	Data       any       `json:"data"`
+}
+
+// URLData is the payload of url.created, url.updated and url.deleted events
+type URLData struct {
+	ShortCode   string `json:"shortCode"`
+	OriginalURL string `json:"original"`
+}
+
+// ClickData is the payload of url.clicked events
+type ClickData struct {
+	ShortCode string `json:"shortCode"`
+	// ClickID is unique to the redirect, and passed on to the destination
+	// when CLICK_ID_PARAM is set or the link tracks conversions
+	ClickID   string `json:"clickId"`
+	Referrer  string `json:"referrer,omitempty"`
+	UserAgent string `json:"userAgent,omitempty"`
+	Country   string `json:"country,omitempty"`
+	Device    string `json:"device,omitempty"`
+	Browser   string `json:"browser,omitempty"`
+	OS        string `json:"os,omitempty"`
+	Bot       bool   `json:"bot,omitempty"`
+	// Suspicious is why click fraud detection flagged the click
+	Suspicious string `json:"suspicious,omitempty"`
+	Campaign   string `json:"campaign,omitempty"`
+}
+
+// Schemas is the JSON Schema document describing every event payload for SchemaVersion
+//
+//go:embed schemas.json
+var Schemas []byte
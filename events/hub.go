@@ -0,0 +1,61 @@
+package events
+
+import (
+	"context"
+	"sync"
+)
+
+// subscriberBuffer is how many events a slow subscriber may fall behind before
+// further events are dropped for it
+const subscriberBuffer = 64
+
+// Hub fans events out in-process to subscribers of a key, such as live stats
+// streams for one short code. It only sees events raised on this instance.
+type Hub struct {
+	mu   sync.RWMutex
+	subs map[string]map[chan Envelope]struct{}
+}
+
+func NewHub() *Hub {
+	return &Hub{subs: make(map[string]map[chan Envelope]struct{})}
+}
+
+// Subscribe returns a channel receiving events published under key and a
+// function that must be called to unsubscribe
+func (h *Hub) Subscribe(key string) (<-chan Envelope, func()) {
+	ch := make(chan Envelope, subscriberBuffer)
+
+	h.mu.Lock()
+	if h.subs[key] == nil {
+		h.subs[key] = make(map[chan Envelope]struct{})
+	}
+	h.subs[key][ch] = struct{}{}
+	h.mu.Unlock()
+
+	return ch, func() {
+		h.mu.Lock()
+		delete(h.subs[key], ch)
+		if len(h.subs[key]) == 0 {
+			delete(h.subs, key)
+		}
+		h.mu.Unlock()
+	}
+}
+
+// Publish delivers the event to current subscribers without blocking
+func (h *Hub) Publish(ctx context.Context, key string, event Envelope) error {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	for ch := range h.subs[key] {
+		select {
+		case ch <- event:
+		default:
+		}
+	}
+	return nil
+}
+
+func (h *Hub) Close() error {
+	return nil
+}
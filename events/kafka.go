@@ -0,0 +1,81 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// KafkaPublisher writes JSON-encoded envelopes to a Kafka topic. Writes are
+// batched and asynchronous so publishing never delays the request that caused it.
+type KafkaPublisher struct {
+	writer *kafka.Writer
+}
+
+func NewKafkaPublisher(brokers []string, topic string) *KafkaPublisher {
+	return &KafkaPublisher{
+		writer: &kafka.Writer{
+			Addr:         kafka.TCP(brokers...),
+			Topic:        topic,
+			Balancer:     &kafka.Hash{},
+			BatchTimeout: 50 * time.Millisecond,
+			Async:        true,
+			Completion: func(messages []kafka.Message, err error) {
+				if err != nil {
+					log.Printf("Failed to publish %d events to Kafka: %v", len(messages), err)
+				}
+			},
+		},
+	}
+}
+
+// NewSyncKafkaPublisher creates a publisher whose writes return only once
+// every in-sync replica has the messages, for deliveries that are retried
+// until they succeed
+func NewSyncKafkaPublisher(brokers []string, topic string) *KafkaPublisher {
+	return &KafkaPublisher{
+		writer: &kafka.Writer{
+			Addr:         kafka.TCP(brokers...),
+			Topic:        topic,
+			Balancer:     &kafka.Hash{},
+			BatchTimeout: 10 * time.Millisecond,
+			RequiredAcks: kafka.RequireAll,
+		},
+	}
+}
+
+// Publish queues the event; messages sharing a key land on the same partition
+func (p *KafkaPublisher) Publish(ctx context.Context, key string, event Envelope) error {
+	value, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	return p.writer.WriteMessages(ctx, kafka.Message{
+		Key:   []byte(key),
+		Value: value,
+		Time:  event.OccurredAt,
+	})
+}
+
+// PublishBatch writes messages in one batch. With a sync publisher it
+// returns once all of them are stored, or with an error when any may not be.
+func (p *KafkaPublisher) PublishBatch(ctx context.Context, messages []Message) error {
+	batch := make([]kafka.Message, 0, len(messages))
+	for _, m := range messages {
+		value, err := json.Marshal(m.Event)
+		if err != nil {
+			return err
+		}
+		batch = append(batch, kafka.Message{Key: []byte(m.Key), Value: value, Time: m.Event.OccurredAt})
+	}
+	return p.writer.WriteMessages(ctx, batch...)
+}
+
+// Close flushes pending messages
+func (p *KafkaPublisher) Close() error {
+	return p.writer.Close()
+}
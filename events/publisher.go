@@ -0,0 +1,37 @@
+package events
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"time"
+)
+
+// Publisher delivers events to an external system
+type Publisher interface {
+	Publish(ctx context.Context, key string, event Envelope) error
+	Close() error
+}
+
+// Message is an event and the key it is published under
+type Message struct {
+	Key   string
+	Event Envelope
+}
+
+// New wraps data in an envelope of the current schema version
+func New(eventType Type, data any) Envelope {
+	return Envelope{
+		ID:         newID(),
+		Type:       eventType,
+		Version:    SchemaVersion,
+		OccurredAt: time.Now().UTC(),
+		Data:       data,
+	}
+}
+
+func newID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
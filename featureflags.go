@@ -0,0 +1,229 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"url-shortener/config"
+	"url-shortener/db"
+	"url-shortener/pkg/apierror"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Feature flags gating experimental behavior until it is rolled out everywhere
+const (
+	// flagAnalyticsPipeline sends clicks through the new analytics pipeline
+	flagAnalyticsPipeline = "analytics_pipeline"
+	// flagInterstitial shows an interstitial page before redirecting
+	flagInterstitial = "interstitial"
+	// flagRedisCache caches links in Redis in front of the database
+	flagRedisCache = "redis_cache"
+)
+
+// featureFlagNames are the flags that can be set; any other name is rejected
+// so a typo does not silently leave a behavior off
+var featureFlagNames = []string{flagAnalyticsPipeline, flagInterstitial, flagRedisCache}
+
+// deploymentEnvironment is the APP_ENV this instance runs in, which flag
+// overrides may be limited to
+var deploymentEnvironment string
+
+// flagScope identifies an override that applies to this instance: one
+// organization's links or all of them (orgID 0), set for this environment
+// only or for every environment
+type flagScope struct {
+	name        string
+	orgID       int
+	environment bool
+}
+
+var (
+	// flagDefaults are the flags FEATURE_FLAGS turns on
+	flagDefaults atomic.Pointer[map[string]bool]
+	// flagOverrides are the overrides stored in the database for this environment
+	flagOverrides atomic.Pointer[map[flagScope]bool]
+)
+
+// featureEnabled reports whether a flag is on for an organization's links, or
+// for links outside any organization when orgID is 0. The most specific
+// override wins: the organization in this environment, then the organization,
+// then everyone in this environment, then everyone, then FEATURE_FLAGS.
+func featureEnabled(name string, orgID int) bool {
+	if overrides := flagOverrides.Load(); overrides != nil {
+		scopes := []flagScope{{name, 0, true}, {name, 0, false}}
+		if orgID > 0 {
+			scopes = append([]flagScope{{name, orgID, true}, {name, orgID, false}}, scopes...)
+		}
+		for _, scope := range scopes {
+			if enabled, ok := (*overrides)[scope]; ok {
+				return enabled
+			}
+		}
+	}
+	if defaults := flagDefaults.Load(); defaults != nil {
+		return (*defaults)[name]
+	}
+	return false
+}
+
+// prepareFeatureFlags turns on the flags listed in FEATURE_FLAGS by default
+func prepareFeatureFlags(cfg *config.Config) (func(), error) {
+	defaults := make(map[string]bool, len(cfg.Features.Enabled))
+	for _, name := range cfg.Features.Enabled {
+		if !slices.Contains(featureFlagNames, name) {
+			return nil, fmt.Errorf("unknown feature flag %q in FEATURE_FLAGS", name)
+		}
+		defaults[name] = true
+	}
+	return func() { flagDefaults.Store(&defaults) }, nil
+}
+
+// loadFeatureFlags reads the overrides for this environment from the database
+func loadFeatureFlags(ctx context.Context) error {
+	flags, err := database.GetFeatureFlags(ctx)
+	if err != nil {
+		return err
+	}
+
+	overrides := make(map[flagScope]bool, len(flags))
+	for _, flag := range flags {
+		if flag.Environment != "" && flag.Environment != deploymentEnvironment {
+			continue
+		}
+		overrides[flagScope{flag.Name, flag.OrgID, flag.Environment != ""}] = flag.Enabled
+	}
+	flagOverrides.Store(&overrides)
+	return nil
+}
+
+// featureFlagStatus is a flag as seen by this instance along with every override
+type featureFlagStatus struct {
+	Name string `json:"name"`
+	// Enabled is whether the flag is on for links outside any organization here
+	Enabled bool `json:"enabled"`
+	// Default is whether FEATURE_FLAGS turns it on
+	Default   bool             `json:"default"`
+	Overrides []db.FeatureFlag `json:"overrides"`
+}
+
+// getFeatureFlags lists the feature flags, whether they are on in this
+// environment and the overrides stored for them
+func getFeatureFlags(c *gin.Context) {
+	flags, err := database.GetFeatureFlags(c.Request.Context())
+	if err != nil {
+		respondError(c, apierror.Internal("Database error").Wrap(err))
+		return
+	}
+
+	defaults := flagDefaults.Load()
+	statuses := make([]featureFlagStatus, 0, len(featureFlagNames))
+	for _, name := range featureFlagNames {
+		status := featureFlagStatus{
+			Name:      name,
+			Enabled:   featureEnabled(name, 0),
+			Default:   defaults != nil && (*defaults)[name],
+			Overrides: make([]db.FeatureFlag, 0),
+		}
+		for _, flag := range flags {
+			if flag.Name == name {
+				status.Overrides = append(status.Overrides, flag)
+			}
+		}
+		statuses = append(statuses, status)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"environment": deploymentEnvironment, "flags": statuses})
+}
+
+// featureFlagParam reads the :name path parameter, writing a 404 for flags
+// that do not exist
+func featureFlagParam(c *gin.Context) (string, bool) {
+	name := c.Param("name")
+	if !slices.Contains(featureFlagNames, name) {
+		respondError(c, apierror.NotFound("Feature flag not found"))
+		return "", false
+	}
+	return name, true
+}
+
+// setFeatureFlag turns a flag on or off at runtime, everywhere or only in one
+// environment and/or for one organization
+func setFeatureFlag(c *gin.Context) {
+	name, ok := featureFlagParam(c)
+	if !ok {
+		return
+	}
+
+	var request struct {
+		Enabled     *bool  `json:"enabled" binding:"required"`
+		Environment string `json:"environment"`
+		OrgID       int    `json:"orgId"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil || request.OrgID < 0 {
+		respondError(c, apierror.Validation("Invalid request body"))
+		return
+	}
+
+	flag := db.FeatureFlag{
+		Name:        name,
+		Environment: strings.TrimSpace(request.Environment),
+		OrgID:       request.OrgID,
+		Enabled:     *request.Enabled,
+		UpdatedBy:   auditActor(c),
+	}
+	if err := database.SetFeatureFlag(c.Request.Context(), &flag); err != nil {
+		respondError(c, apierror.Internal("Failed to store feature flag").Wrap(err))
+		return
+	}
+
+	reloadFeatureFlags(c)
+	recordAudit(c, auditUpdate, "feature_flag", name, nil, flag)
+	c.JSON(http.StatusOK, flag)
+}
+
+// deleteFeatureFlag removes an override, so the flag falls back to the next
+// less specific one or to FEATURE_FLAGS
+func deleteFeatureFlag(c *gin.Context) {
+	name, ok := featureFlagParam(c)
+	if !ok {
+		return
+	}
+
+	orgID := 0
+	if value := c.Query("orgId"); value != "" {
+		var err error
+		if orgID, err = strconv.Atoi(value); err != nil || orgID < 0 {
+			respondError(c, apierror.Validation("Invalid orgId"))
+			return
+		}
+	}
+
+	flag, err := database.DeleteFeatureFlag(c.Request.Context(), name, strings.TrimSpace(c.Query("environment")), orgID)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondError(c, apierror.NotFound("Override not found"))
+		return
+	}
+	if err != nil {
+		respondError(c, apierror.Internal("Database error").Wrap(err))
+		return
+	}
+
+	reloadFeatureFlags(c)
+	recordAudit(c, auditDelete, "feature_flag", name, flag, nil)
+	c.JSON(http.StatusOK, gin.H{"message": "Override deleted successfully"})
+}
+
+// reloadFeatureFlags applies a flag change on this instance right away
+func reloadFeatureFlags(c *gin.Context) {
+	if err := loadFeatureFlags(c.Request.Context()); err != nil {
+		log.Printf("Failed to reload feature flags: %v", err)
+	}
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"net/url"
+	"path"
+	"strings"
+	"url-shortener/db"
+
+	"github.com/gin-gonic/gin"
+)
+
+// pathWildcard marks where a path-forwarding destination takes the forwarded path
+const pathWildcard = "*"
+
+// forwardedPath is the part of the request path after the short code, e.g.
+// "guides/setup" for /docs/guides/setup
+func forwardedPath(c *gin.Context) string {
+	return strings.Trim(c.Param("path"), "/")
+}
+
+// acceptsPath reports whether a link may serve the request: a path after the
+// short code is only allowed on links that forward it
+func acceptsPath(c *gin.Context, link *db.URL) bool {
+	return link.ForwardPath || forwardedPath(c) == ""
+}
+
+// forwardRequest applies a link's passthrough options to the chosen destination
+func forwardRequest(c *gin.Context, link *db.URL, destination string) string {
+	if rest := forwardedPath(c); rest != "" && link.ForwardPath {
+		destination = withForwardedPath(destination, rest)
+	}
+	if link.ForwardQuery {
+		destination = withForwardedQuery(destination, c.Request.URL.Query())
+	}
+	return destination
+}
+
+// withForwardedPath substitutes rest for the wildcard in a destination such as
+// https://internal.wiki/pages/*, or appends it when there is none. The path is
+// cleaned first so "../" cannot climb above the destination's prefix.
+func withForwardedPath(destination, rest string) string {
+	u, err := url.Parse(destination)
+	if err != nil {
+		return destination
+	}
+
+	rest = strings.TrimPrefix(path.Clean("/"+rest), "/")
+	if strings.Contains(u.Path, pathWildcard) {
+		u.Path = strings.Replace(u.Path, pathWildcard, rest, 1)
+	} else {
+		u.Path = strings.TrimRight(u.Path, "/") + "/" + rest
+	}
+	u.RawPath = ""
+	return u.String()
+}
+
+// withForwardedQuery appends the short link request's query parameters to a
+// destination. Parameters already present on the destination keep the value
+// the link owner set, so visitors cannot override affiliate or campaign tags.
+func withForwardedQuery(destination string, incoming url.Values) string {
+	if len(incoming) == 0 {
+		return destination
+	}
+
+	u, err := url.Parse(destination)
+	if err != nil {
+		return destination
+	}
+
+	existing := u.Query()
+	extra := url.Values{}
+	for key, values := range incoming {
+		if _, ok := existing[key]; !ok {
+			extra[key] = values
+		}
+	}
+	if len(extra) == 0 {
+		return destination
+	}
+
+	// Append rather than re-encode so the destination's own parameters keep their order
+	if u.RawQuery != "" {
+		u.RawQuery += "&"
+	}
+	u.RawQuery += extra.Encode()
+	return u.String()
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"url-shortener/config"
+	"url-shortener/db"
+
+	"github.com/gin-gonic/gin"
+)
+
+// frameHosts are the destination hosts, with their subdomains, that links
+// may show in frame mode; most sites refuse to be framed through
+// X-Frame-Options or a CSP, so only those known to allow it are listed
+var frameHosts atomic.Pointer[[]string]
+
+// prepareFrames installs the FRAME_ALLOWED_HOSTS allowlist. Leading "*." or
+// "." are accepted and ignored, as subdomains always match.
+func prepareFrames(cfg *config.Config) (func(), error) {
+	hosts := make([]string, 0, len(cfg.Frames.AllowedHosts))
+	for _, host := range cfg.Frames.AllowedHosts {
+		host = strings.TrimPrefix(strings.TrimPrefix(strings.ToLower(host), "*"), ".")
+		if !domainPattern.MatchString(host) {
+			return nil, errors.New("FRAME_ALLOWED_HOSTS must be a comma-separated list of host names")
+		}
+		hosts = append(hosts, host)
+	}
+	return func() { frameHosts.Store(&hosts) }, nil
+}
+
+// frameable reports whether destination is an https URL on an allowed host;
+// http pages would be blocked as mixed content on https short domains
+func frameable(destination string) bool {
+	u, err := url.Parse(destination)
+	if err != nil || u.Scheme != "https" {
+		return false
+	}
+	host := strings.ToLower(u.Hostname())
+	hosts := frameHosts.Load()
+	if hosts == nil {
+		return false
+	}
+	for _, allowed := range *hosts {
+		if host == allowed || strings.HasSuffix(host, "."+allowed) {
+			return true
+		}
+	}
+	return false
+}
+
+// serveFrame shows destination in a full-page iframe on the short domain,
+// keeping the short URL in the address bar, for links in frame mode; the
+// page fires the link's pixels as well. Links whose destination is not on
+// the allowlist, e.g. after it was changed, are redirected as usual. It
+// reports whether the page was served.
+func serveFrame(c *gin.Context, link *db.URL, destination string, pixels []db.Pixel) bool {
+	if !link.Frame || !frameable(destination) {
+		return false
+	}
+
+	title := link.Title
+	if link.OpenGraph != nil && link.OpenGraph.Title != "" {
+		title = link.OpenGraph.Title
+	}
+	if title == "" {
+		title = shortURLFor(c, link.Domain, link.ShortCode)
+	}
+
+	// Like a redirect, the page may lead to a different destination on the
+	// next click, so it must not be reused
+	c.Header("Cache-Control", "no-store")
+	c.HTML(http.StatusOK, "frame.html", gin.H{
+		"title":       title,
+		"destination": destination,
+		"pixels":      pixels,
+	})
+	return true
+}
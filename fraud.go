@@ -0,0 +1,113 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"log"
+	"net/http"
+	"net/netip"
+	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"url-shortener/config"
+	"url-shortener/middleware"
+	"url-shortener/pkg/apierror"
+	"url-shortener/pkg/clickfraud"
+
+	"github.com/gin-gonic/gin"
+)
+
+// fraudDetector flags abnormal click patterns; nil when detection is disabled
+var fraudDetector atomic.Pointer[clickfraud.Detector]
+
+// maxAnomalyLimit bounds a single anomaly report
+const maxAnomalyLimit = 1000
+
+// newFraudDetector configures click fraud detection, reading the datacenter
+// networks from CLICK_FRAUD_DATACENTER_CIDRS and CLICK_FRAUD_DATACENTER_FILE
+func newFraudDetector(cfg *config.Config) (*clickfraud.Detector, error) {
+	cidrs := cfg.ClickFraud.DatacenterCIDRs
+	if cfg.ClickFraud.DatacenterFile != "" {
+		lines, err := readCIDRFile(cfg.ClickFraud.DatacenterFile)
+		if err != nil {
+			return nil, err
+		}
+		cidrs = append(cidrs, lines...)
+	}
+
+	datacenters := make([]netip.Prefix, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		prefix, err := middleware.ParsePrefix(cidr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid datacenter range %q: %w", cidr, err)
+		}
+		datacenters = append(datacenters, prefix)
+	}
+
+	return clickfraud.New(clickfraud.Config{
+		IPLimit:        cfg.ClickFraud.IPLimit,
+		IPWindow:       cfg.ClickFraud.IPWindow,
+		SpikeFactor:    cfg.ClickFraud.SpikeFactor,
+		SpikeMinClicks: cfg.ClickFraud.SpikeMinClicks,
+		Datacenters:    datacenters,
+	}), nil
+}
+
+// readCIDRFile reads one CIDR per line, as published by cloud providers,
+// skipping blank lines and # comments
+func readCIDRFile(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var cidrs []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line != "" && !strings.HasPrefix(line, "#") {
+			cidrs = append(cidrs, line)
+		}
+	}
+	return cidrs, scanner.Err()
+}
+
+// checkClick runs click fraud detection on a human click. Spikes are recorded
+// for the anomaly report right away, since their clicks are still counted.
+func checkClick(c *gin.Context, shortCode string, bot bool) clickfraud.Verdict {
+	detector := fraudDetector.Load()
+	if bot || detector == nil {
+		return clickfraud.Verdict{}
+	}
+
+	verdict := detector.Check(shortCode, c.ClientIP())
+	if verdict.Reason != "" && !verdict.Exclude {
+		if err := database.RecordAnomaly(c.Request.Context(), shortCode, verdict.Reason); err != nil {
+			log.Printf("Failed to record %s on %s: %v", verdict.Reason, shortCode, err)
+		}
+	}
+	return verdict
+}
+
+// getAnomalousLinks reports links with abnormal click patterns, most recently flagged first
+func getAnomalousLinks(c *gin.Context) {
+	limit := 100
+	if raw := c.Query("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n <= 0 || n > maxAnomalyLimit {
+			respondError(c, apierror.Validation("limit must be between 1 and "+strconv.Itoa(maxAnomalyLimit)))
+			return
+		}
+		limit = n
+	}
+
+	links, err := database.GetAnomalousLinks(c.Request.Context(), limit)
+	if err != nil {
+		respondError(c, apierror.Internal("Database error").Wrap(err))
+		return
+	}
+
+	c.JSON(http.StatusOK, links)
+}
@@ -0,0 +1,293 @@
+package main
+
+// TestGolden checks the JSON shape of API responses against golden files, so
+// unintended changes to response bodies fail loudly.
+//
+// Responses are reduced to their shape (keys and value types) before comparison,
+// so IDs, timestamps and counters do not cause spurious failures. The test runs
+// the full router against the database named by the DATABASE_* variables and is
+// skipped when DATABASE_HOST is not set.
+//
+// Usage:
+//
+//	DATABASE_HOST=localhost go test -run TestGolden .          # verify
+//	DATABASE_HOST=localhost go test -run TestGolden . -update  # re-record
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+var updateGolden = flag.Bool("update", false, "re-record the golden files of TestGolden instead of comparing")
+
+// step is a single request of the golden scenario. {code} in path is replaced
+// by the short code returned from the create step, and every later request
+// carries the management token returned alongside it, unless noToken is set.
+// Steps marked admin also carry the admin token.
+type step struct {
+	name    string
+	method  string
+	path    string
+	body    string
+	noToken bool
+	admin   bool
+}
+
+var scenario = []step{
+	{name: "root", method: http.MethodGet, path: "/"},
+	{name: "healthz", method: http.MethodGet, path: "/healthz"},
+	{name: "events_schema", method: http.MethodGet, path: "/.well-known/events-schema"},
+	{name: "create", method: http.MethodPost, path: "/api/v1/urls", body: `{"url": "https://example.com/golden"}`},
+	{name: "create_invalid", method: http.MethodPost, path: "/api/v1/urls", body: `{`},
+	{name: "create_unsafe_scheme", method: http.MethodPost, path: "/api/v1/urls", body: `{"url": "javascript:alert(1)"}`},
+	{name: "list", method: http.MethodGet, path: "/api/v1/urls"},
+	{name: "redirect", method: http.MethodGet, path: "/{code}"},
+	{name: "redirect_prefixed", method: http.MethodGet, path: "/urls/{code}"},
+	{name: "redirect_head", method: http.MethodHead, path: "/urls/{code}"},
+	{name: "options", method: http.MethodOptions, path: "/urls/{code}"},
+	{name: "stats", method: http.MethodGet, path: "/api/v1/urls/{code}/stats"},
+	{name: "stats_not_found", method: http.MethodGet, path: "/api/v1/urls/golden-missing/stats"},
+	{name: "timeseries_invalid", method: http.MethodGet, path: "/api/v1/urls/{code}/stats/timeseries?interval=year"},
+	{name: "metadata_refresh_disabled", method: http.MethodPost, path: "/api/v1/urls/{code}/metadata/refresh"},
+	{name: "cache_ttl", method: http.MethodPut, path: "/api/v1/urls/{code}/cache-ttl", body: `{"ttl": 60}`},
+	{name: "cache_ttl_invalid", method: http.MethodPut, path: "/api/v1/urls/{code}/cache-ttl", body: `{"ttl": -1}`},
+	{name: "targets_invalid", method: http.MethodPut, path: "/api/v1/urls/{code}/targets", body: `{"ios": "javascript:alert(1)"}`},
+	{name: "signed_url_disabled", method: http.MethodPost, path: "/api/v1/urls/{code}/signed-urls", body: `{"action": "stats"}`},
+	{name: "rollback_not_found", method: http.MethodPost, path: "/api/v1/urls/{code}/rollback/none"},
+	{name: "patch_invalid", method: http.MethodPatch, path: "/api/v1/urls/{code}", body: `{"shortCode": "golden"}`},
+	{name: "graphql_invalid", method: http.MethodPost, path: "/api/v1/graphql", body: `{`},
+	{name: "campaigns_unauthenticated", method: http.MethodGet, path: "/api/v1/campaigns", noToken: true},
+	{name: "admin_no_token", method: http.MethodGet, path: "/api/v1/admin/feature-flags", noToken: true},
+	{name: "lock_no_token", method: http.MethodPost, path: "/api/v1/urls/{code}/lock"},
+	{name: "lock", method: http.MethodPost, path: "/api/v1/urls/{code}/lock", body: `{"reason": "golden"}`, admin: true},
+	{name: "update_locked", method: http.MethodPut, path: "/api/v1/urls/{code}", body: `{"url": "https://example.com/golden/locked"}`},
+	{name: "unlock_no_reason", method: http.MethodPost, path: "/api/v1/urls/{code}/unlock", admin: true},
+	{name: "unlock", method: http.MethodPost, path: "/api/v1/urls/{code}/unlock", body: `{"reason": "golden"}`, admin: true},
+	{name: "update_no_token", method: http.MethodPut, path: "/api/v1/urls/{code}", body: `{"url": "https://example.com/golden/updated"}`, noToken: true},
+	{name: "update", method: http.MethodPut, path: "/api/v1/urls/{code}", body: `{"url": "https://example.com/golden/updated"}`},
+	{name: "update_invalid", method: http.MethodPut, path: "/api/v1/urls/{code}", body: `{`},
+	{name: "delete", method: http.MethodDelete, path: "/api/v1/urls/{code}"},
+	{name: "delete_not_found", method: http.MethodDelete, path: "/api/v1/urls/{code}"},
+}
+
+// golden is the recorded outcome of a step
+type golden struct {
+	Status int `json:"status"`
+	Body   any `json:"body"`
+}
+
+func TestGolden(t *testing.T) {
+	server := httptest.NewServer(routerWithDatabase(t))
+	defer server.Close()
+
+	client := server.Client()
+	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+
+	code, token := "", ""
+	for _, s := range scenario {
+		stepToken := token
+		if s.noToken {
+			stepToken = ""
+		}
+
+		got, raw, err := runStep(client, server.URL, s, code, stepToken)
+		if err != nil {
+			t.Fatalf("%s: %v", s.name, err)
+		}
+		if s.name == "create" {
+			var created struct {
+				ShortCode       string `json:"shortCode"`
+				ManagementToken string `json:"managementToken"`
+			}
+			if err := json.Unmarshal(raw, &created); err != nil || created.ShortCode == "" {
+				t.Fatalf("create: response has no shortCode: %s", raw)
+			}
+			code, token = created.ShortCode, created.ManagementToken
+		}
+
+		path := filepath.Join("testdata", "golden", s.name+".json")
+		if *updateGolden {
+			if err := writeGolden(path, got); err != nil {
+				t.Fatalf("%s: %v", s.name, err)
+			}
+			continue
+		}
+
+		want, err := readGolden(path)
+		if err != nil {
+			t.Fatalf("%s: %v (run with -update to record)", s.name, err)
+		}
+		for _, d := range compare(want, got) {
+			t.Errorf("%s: %s", s.name, d)
+		}
+	}
+}
+
+func runStep(client *http.Client, target string, s step, code, token string) (golden, []byte, error) {
+	var body io.Reader
+	if s.body != "" {
+		body = strings.NewReader(s.body)
+	}
+
+	req, err := http.NewRequest(s.method, target+strings.ReplaceAll(s.path, "{code}", code), body)
+	if err != nil {
+		return golden{}, nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if token != "" {
+		req.Header.Set("X-Management-Token", token)
+	}
+	if s.admin {
+		req.Header.Set("Authorization", "Bearer "+testAdminToken)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return golden{}, nil, err
+	}
+	defer resp.Body.Close()
+
+	raw, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return golden{}, nil, err
+	}
+
+	g := golden{Status: resp.StatusCode}
+	if strings.Contains(resp.Header.Get("Content-Type"), "json") && len(bytes.TrimSpace(raw)) > 0 {
+		var v any
+		if err := json.Unmarshal(raw, &v); err != nil {
+			return golden{}, nil, fmt.Errorf("invalid JSON body: %w", err)
+		}
+		g.Body = shape(v)
+	}
+	return g, raw, nil
+}
+
+// shape replaces every scalar with its JSON type name and collapses arrays to
+// the shape of their first element
+func shape(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		out := make(map[string]any, len(v))
+		for k, child := range v {
+			out[k] = shape(child)
+		}
+		return out
+	case []any:
+		if len(v) == 0 {
+			return []any{}
+		}
+		return []any{shape(v[0])}
+	case string:
+		return "string"
+	case float64:
+		return "number"
+	case bool:
+		return "boolean"
+	default:
+		return "null"
+	}
+}
+
+// compare lists the paths at which got's shape differs from want's
+func compare(want, got golden) []string {
+	var diffs []string
+	if want.Status != got.Status {
+		diffs = append(diffs, fmt.Sprintf("status: want %d, got %d", want.Status, got.Status))
+	}
+
+	// Round-trip through JSON so recorded and live values have identical Go types
+	var w, g any
+	normalize(want.Body, &w)
+	normalize(got.Body, &g)
+	return append(diffs, diffShape("body", w, g)...)
+}
+
+func normalize(v any, out *any) {
+	b, _ := json.Marshal(v)
+	json.Unmarshal(b, out)
+}
+
+func diffShape(path string, want, got any) []string {
+	wm, wok := want.(map[string]any)
+	gm, gok := got.(map[string]any)
+	if wok && gok {
+		keys := map[string]bool{}
+		for k := range wm {
+			keys[k] = true
+		}
+		for k := range gm {
+			keys[k] = true
+		}
+		sorted := make([]string, 0, len(keys))
+		for k := range keys {
+			sorted = append(sorted, k)
+		}
+		sort.Strings(sorted)
+
+		var diffs []string
+		for _, k := range sorted {
+			wv, inWant := wm[k]
+			gv, inGot := gm[k]
+			switch {
+			case !inGot:
+				diffs = append(diffs, fmt.Sprintf("%s.%s: missing", path, k))
+			case !inWant:
+				diffs = append(diffs, fmt.Sprintf("%s.%s: unexpected", path, k))
+			default:
+				diffs = append(diffs, diffShape(path+"."+k, wv, gv)...)
+			}
+		}
+		return diffs
+	}
+
+	wa, wok := want.([]any)
+	ga, gok := got.([]any)
+	if wok && gok {
+		if len(wa) == 0 || len(ga) == 0 {
+			return nil
+		}
+		return diffShape(path+"[]", wa[0], ga[0])
+	}
+
+	wj, _ := json.Marshal(want)
+	gj, _ := json.Marshal(got)
+	if !bytes.Equal(wj, gj) {
+		return []string{fmt.Sprintf("%s: want %s, got %s", path, wj, gj)}
+	}
+	return nil
+}
+
+func readGolden(path string) (golden, error) {
+	var g golden
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return g, err
+	}
+	return g, json.Unmarshal(b, &g)
+}
+
+func writeGolden(path string, g golden) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	b, err := json.MarshalIndent(g, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(b, '\n'), 0o644)
+}
@@ -0,0 +1,306 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"url-shortener/db"
+	"url-shortener/middleware"
+	"url-shortener/models"
+	"url-shortener/pkg/apierror"
+	"url-shortener/pkg/graphql"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// graphqlPageSize is how many links a urls query returns without first
+	graphqlPageSize = 20
+	// graphqlMaxPageSize caps first on urls queries
+	graphqlMaxPageSize = 100
+	// graphqlMaxSeriesDays caps the days of a clickSeries
+	graphqlMaxSeriesDays = 90
+)
+
+// graphqlSchema answers /graphql queries over links, their stats, tags and
+// campaigns, with the same access rules as the REST endpoints
+var graphqlSchema = newGraphQLSchema()
+
+// urlConnection is a page of links in the cursor connection format
+type urlConnection struct {
+	Edges    []urlEdge `json:"edges"`
+	PageInfo pageInfo  `json:"pageInfo"`
+}
+
+type urlEdge struct {
+	Cursor string      `json:"cursor"`
+	Node   *models.URL `json:"node"`
+}
+
+type pageInfo struct {
+	HasNextPage bool   `json:"hasNextPage"`
+	EndCursor   string `json:"endCursor,omitempty"`
+}
+
+func newGraphQLSchema() *graphql.Schema {
+	entry := &graphql.Object{Name: "BreakdownEntry", Fields: scalarFields("name", "clicks")}
+	breakdown := &graphql.Object{Name: "ClickBreakdown", Fields: map[string]*graphql.Field{
+		"devices":  {Type: entry},
+		"browsers": {Type: entry},
+		"os":       {Type: entry},
+	}}
+	daily := &graphql.Object{Name: "DailyClicks", Fields: scalarFields("date", "clicks")}
+
+	urlStats := &graphql.Object{Name: "URLStats", Fields: scalarFields("accessCount", "uniqueClicks", "botClicks", "suspiciousClicks")}
+	urlStats.Fields["breakdown"] = &graphql.Field{Type: breakdown, Resolve: resolveURLBreakdown}
+	urlStats.Fields["clickSeries"] = &graphql.Field{Type: daily, Args: map[string]any{"days": 7}, Resolve: resolveClickSeries}
+
+	url := &graphql.Object{Name: "URL", Fields: scalarFields(
+		"id", "original", "shortCode", "shortUrl", "domain", "orgId", "campaign", "campaignId",
+		"createdAt", "updatedAt", "accessCount", "uniqueClicks", "botClicks", "suspiciousClicks", "locked", "tags",
+		"title", "description", "forwardQuery", "forwardPath", "disabled", "disabledReason", "targets", "publicStats", "archived", "rotation", "appLink", "openGraph", "cacheTtl",
+	)}
+	url.Fields["stats"] = &graphql.Field{Type: urlStats, Resolve: resolveURLStats}
+	url.Fields["health"] = &graphql.Field{Type: &graphql.Object{
+		Name:   "LinkHealth",
+		Fields: scalarFields("checkedAt", "statusCode", "latencyMs", "redirects", "error", "broken", "brokenSince"),
+	}}
+
+	edge := &graphql.Object{Name: "URLEdge", Fields: map[string]*graphql.Field{
+		"cursor": {},
+		"node":   {Type: url},
+	}}
+	connection := &graphql.Object{Name: "URLConnection", Fields: map[string]*graphql.Field{
+		"edges":    {Type: edge},
+		"pageInfo": {Type: &graphql.Object{Name: "PageInfo", Fields: scalarFields("hasNextPage", "endCursor")}},
+	}}
+
+	campaignStats := &graphql.Object{Name: "CampaignStats", Fields: scalarFields("clicks", "botClicks")}
+	campaignStats.Fields["breakdown"] = &graphql.Field{Type: breakdown}
+	campaignStats.Fields["urls"] = &graphql.Field{Type: &graphql.Object{
+		Name:   "CampaignLinkStats",
+		Fields: scalarFields("shortCode", "clicks", "botClicks"),
+	}}
+	campaign := &graphql.Object{Name: "Campaign", Fields: scalarFields("id", "name", "ownerId", "links", "createdAt")}
+	campaign.Fields["stats"] = &graphql.Field{Type: campaignStats, Resolve: resolveCampaignStats}
+
+	query := &graphql.Object{Name: "Query", Fields: map[string]*graphql.Field{
+		"url":       {Type: url, Args: map[string]any{"shortCode": nil}, Resolve: resolveURL},
+		"urls":      {Type: connection, Args: map[string]any{"first": graphqlPageSize, "after": nil, "orgId": nil, "campaign": nil, "health": nil}, Resolve: resolveURLs},
+		"tags":      {Type: &graphql.Object{Name: "TagCount", Fields: scalarFields("tag", "count")}, Resolve: resolveTags},
+		"campaigns": {Type: campaign, Resolve: resolveCampaigns},
+		"campaign":  {Type: campaign, Args: map[string]any{"id": nil}, Resolve: resolveCampaign},
+	}}
+
+	return &graphql.Schema{Query: query, Present: presentGraphQLError}
+}
+
+// scalarFields declares fields read straight from the source value
+func scalarFields(names ...string) map[string]*graphql.Field {
+	fields := make(map[string]*graphql.Field, len(names))
+	for _, name := range names {
+		fields[name] = &graphql.Field{}
+	}
+	return fields
+}
+
+// presentGraphQLError reports a resolver error with the message and code the
+// REST API would answer with, logging internal failures
+func presentGraphQLError(ctx context.Context, err error) *graphql.Error {
+	var e *apierror.Error
+	if !errors.As(toAPIError(err), &e) {
+		e = apierror.Internal("Internal server error").Wrap(err)
+	}
+	if e.Status >= http.StatusInternalServerError && e.Err != nil {
+		c := ctx.(*gin.Context)
+		log.Printf("%s %s failed (request %s): %v", c.Request.Method, c.Request.URL.Path, c.GetString(middleware.ContextRequestID), e.Err)
+	}
+	return &graphql.Error{Message: e.Message, Extensions: map[string]any{"code": e.Code}}
+}
+
+// postGraphQL executes a GraphQL query sent as JSON, or as query parameters
+// on GET. Field errors are reported alongside the data with a 200; requests
+// that cannot be executed at all get a 400.
+func postGraphQL(c *gin.Context) {
+	var request graphql.Request
+	if c.Request.Method == http.MethodGet {
+		request.Query = c.Query("query")
+		request.OperationName = c.Query("operationName")
+		if variables := c.Query("variables"); variables != "" {
+			if err := json.Unmarshal([]byte(variables), &request.Variables); err != nil {
+				c.JSON(http.StatusBadRequest, graphql.Response{Errors: []graphql.Error{{Message: "Invalid variables"}}})
+				return
+			}
+		}
+	} else if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, graphql.Response{Errors: []graphql.Error{{Message: "Invalid request body"}}})
+		return
+	}
+
+	response := graphqlSchema.Execute(c, request)
+	if response.Data == nil {
+		c.JSON(http.StatusBadRequest, response)
+		return
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+func resolveURL(ctx context.Context, _ any, args map[string]any) (any, error) {
+	c := ctx.(*gin.Context)
+	shortCode, _ := graphql.String(args, "shortCode")
+	record, err := database.GetURLByShortCode(c.Request.Context(), shortCode)
+	if err != nil {
+		// A missing link is null rather than an error
+		if errors.Is(err, sql.ErrNoRows) || errors.Is(err, db.ErrNotFound) {
+			return nil, nil
+		}
+		return nil, apierror.Internal("Database error").Wrap(err)
+	}
+	url := toURLModel(c, record)
+	return &url, nil
+}
+
+// resolveURLs pages through links newest first, the cursor being the ID of
+// the last link of the previous page
+func resolveURLs(ctx context.Context, _ any, args map[string]any) (any, error) {
+	c := ctx.(*gin.Context)
+	first, ok := graphql.Int(args, "first")
+	if !ok || first < 1 || first > graphqlMaxPageSize {
+		return nil, apierror.Validation("first must be between 1 and " + strconv.Itoa(graphqlMaxPageSize))
+	}
+
+	afterID := 0
+	if after, ok := graphql.String(args, "after"); ok {
+		var err error
+		if afterID, err = decodeCursor(after); err != nil {
+			return nil, apierror.Validation("Invalid cursor")
+		}
+	}
+
+	// Links of an organization are only listed to its members
+	orgID, _ := graphql.Int(args, "orgId")
+	if orgID > 0 && !adminOfOrg(c, orgID) {
+		if _, err := memberRole(c, orgID); err != nil {
+			return nil, err
+		}
+	}
+	campaign, _ := graphql.String(args, "campaign")
+	health, _ := graphql.String(args, "health")
+	if health != "" && health != "broken" && health != "ok" {
+		return nil, apierror.Validation("health must be broken or ok")
+	}
+
+	records, err := database.GetURLsPage(c.Request.Context(), first+1, afterID, orgID, campaign, health)
+	if err != nil {
+		return nil, apierror.Internal("Database error").Wrap(err)
+	}
+
+	connection := &urlConnection{Edges: []urlEdge{}}
+	if len(records) > first {
+		records = records[:first]
+		connection.PageInfo.HasNextPage = true
+	}
+	for i := range records {
+		node := toURLModel(c, &records[i])
+		edge := urlEdge{Cursor: encodeCursor(node.ID), Node: &node}
+		connection.Edges = append(connection.Edges, edge)
+		connection.PageInfo.EndCursor = edge.Cursor
+	}
+	return connection, nil
+}
+
+func encodeCursor(id int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.Itoa(id)))
+}
+
+func decodeCursor(cursor string) (int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, err
+	}
+	id, err := strconv.Atoi(string(b))
+	if err == nil && id <= 0 {
+		err = errors.New("cursor out of range")
+	}
+	return id, err
+}
+
+// resolveURLStats hands the link on to its stats fields once the caller has
+// shown they may read them, as on the REST stats endpoint
+func resolveURLStats(ctx context.Context, source any, _ map[string]any) (any, error) {
+	url := source.(*models.URL)
+	if err := linkAccess(ctx.(*gin.Context), url.ShortCode, false); err != nil {
+		return nil, err
+	}
+	return url, nil
+}
+
+func resolveURLBreakdown(ctx context.Context, source any, _ map[string]any) (any, error) {
+	breakdown, err := database.GetClickBreakdown(ctx.(*gin.Context).Request.Context(), source.(*models.URL).ID)
+	if err != nil {
+		return nil, apierror.Internal("Database error").Wrap(err)
+	}
+	return breakdown, nil
+}
+
+func resolveClickSeries(ctx context.Context, source any, args map[string]any) (any, error) {
+	days, ok := graphql.Int(args, "days")
+	if !ok || days < 1 || days > graphqlMaxSeriesDays {
+		return nil, apierror.Validation("days must be between 1 and " + strconv.Itoa(graphqlMaxSeriesDays))
+	}
+	series, err := database.GetDailyClicks(ctx.(*gin.Context).Request.Context(), source.(*models.URL).ID, days)
+	if err != nil {
+		return nil, apierror.Internal("Database error").Wrap(err)
+	}
+	return series, nil
+}
+
+func resolveTags(ctx context.Context, _ any, _ map[string]any) (any, error) {
+	counts, err := database.GetTagCounts(ctx.(*gin.Context).Request.Context())
+	if err != nil {
+		return nil, apierror.Internal("Database error").Wrap(err)
+	}
+	return counts, nil
+}
+
+// resolveCampaigns lists the requesting user's campaigns, or every campaign for admins
+func resolveCampaigns(ctx context.Context, _ any, _ map[string]any) (any, error) {
+	c := ctx.(*gin.Context)
+	userID := c.GetString(middleware.ContextUserID)
+	if isAdmin(c) {
+		userID = ""
+	} else if userID == "" {
+		return nil, apierror.Unauthorized("Authentication required")
+	}
+
+	campaigns, err := database.GetCampaigns(c.Request.Context(), userID)
+	if err != nil {
+		return nil, apierror.Internal("Database error").Wrap(err)
+	}
+	return campaigns, nil
+}
+
+func resolveCampaign(ctx context.Context, _ any, args map[string]any) (any, error) {
+	id, _ := graphql.Int(args, "id")
+	return findCampaign(ctx.(*gin.Context), id)
+}
+
+func resolveCampaignStats(ctx context.Context, source any, _ map[string]any) (any, error) {
+	var campaign db.Campaign
+	switch source := source.(type) {
+	case db.Campaign:
+		campaign = source
+	case *db.Campaign:
+		campaign = *source
+	}
+	stats, err := database.GetCampaignStats(ctx.(*gin.Context).Request.Context(), &campaign)
+	if err != nil {
+		return nil, apierror.Internal("Database error").Wrap(err)
+	}
+	return stats, nil
+}
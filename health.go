@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+	"url-shortener/config"
+	"url-shortener/db"
+	"url-shortener/pkg/jobs"
+	"url-shortener/pkg/linkcheck"
+)
+
+const (
+	// healthCheckBatch is how many due links are claimed at a time
+	healthCheckBatch = 100
+	// healthCheckPoll is how often the monitor looks for links due for a check
+	healthCheckPoll = time.Minute
+)
+
+// monitorLinkHealth returns the job that requests every enabled link's
+// destination once per HEALTH_CHECK_INTERVAL, recording whether it still
+// works. Each run checks the links that are due.
+func monitorLinkHealth(cfg *config.Config) jobs.Func {
+	checker := linkcheck.NewChecker(cfg.HealthCheck.Timeout, cfg.HealthCheck.UserAgent)
+	workers := max(cfg.HealthCheck.Concurrency, 1)
+	notify := cfg.HealthCheck.Notify && emailer != nil
+
+	return func(ctx context.Context) error {
+		for ctx.Err() == nil {
+			links, err := database.ClaimHealthChecks(ctx, time.Now().Add(-cfg.HealthCheck.Interval), healthCheckBatch)
+			if err != nil {
+				return err
+			}
+			checkLinks(ctx, checker, workers, notify, links)
+			if len(links) < healthCheckBatch {
+				break
+			}
+		}
+		return nil
+	}
+}
+
+// checkLinks checks the destinations of links, workers at a time
+func checkLinks(ctx context.Context, checker *linkcheck.Checker, workers int, notify bool, links []db.URL) {
+	queue := make(chan *db.URL)
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for link := range queue {
+				checkLink(ctx, checker, notify, link)
+			}
+		}()
+	}
+
+	for i := range links {
+		queue <- &links[i]
+	}
+	close(queue)
+	wg.Wait()
+}
+
+// checkLink requests a link's destination and stores the outcome, emailing
+// the owner when notify is set and the destination has just started failing
+func checkLink(ctx context.Context, checker *linkcheck.Checker, notify bool, link *db.URL) {
+	// Links of every tenant are checked, each stored and reported within its own
+	ctx = db.WithLink(ctx, link)
+	result := checker.Check(ctx, link.OriginalURL)
+	if ctx.Err() != nil {
+		return
+	}
+
+	health := &db.LinkHealth{
+		CheckedAt:  time.Now().UTC(),
+		StatusCode: result.StatusCode,
+		LatencyMs:  result.Latency.Milliseconds(),
+		Redirects:  result.Redirects,
+		Broken:     result.Broken(),
+	}
+	if result.Err != nil {
+		health.Error = result.Err.Error()
+	}
+
+	wasBroken := link.Health != nil && link.Health.Broken
+	if health.Broken {
+		health.BrokenSince = &health.CheckedAt
+		if wasBroken && link.Health.BrokenSince != nil {
+			health.BrokenSince = link.Health.BrokenSince
+		}
+	}
+
+	if err := database.SetLinkHealth(ctx, link.ShortCode, link.OriginalURL, health); err != nil {
+		log.Printf("Failed to store health check of %s: %v", link.ShortCode, err)
+		return
+	}
+	if notify && health.Broken && !wasBroken {
+		notifyBrokenLink(ctx, link, health)
+	}
+}
+
+// notifyBrokenLink emails a link's owner that its destination stopped
+// working. Anonymous links have nobody to notify.
+func notifyBrokenLink(ctx context.Context, link *db.URL, health *db.LinkHealth) {
+	to, err := database.GetOwnerEmail(ctx, link.ShortCode)
+	if errors.Is(err, sql.ErrNoRows) {
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to look up owner of %s: %v", link.ShortCode, err)
+		return
+	}
+
+	problem := health.Error
+	if problem == "" {
+		problem = "HTTP " + strconv.Itoa(health.StatusCode)
+	}
+	err = emailer.Send(to, "Your link's destination is broken", "broken.html", map[string]string{
+		"ShortURL": shortURLFor(nil, link.Domain, link.ShortCode),
+		"Original": link.OriginalURL,
+		"Problem":  problem,
+	})
+	if err != nil {
+		log.Printf("Failed to queue broken link email for %s: %v", link.ShortCode, err)
+	}
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"url-shortener/db"
+	"url-shortener/pkg/apierror"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxImportRecords bounds a single import so it fits in one transaction
+const maxImportRecords = 10000
+
+// importClicks backfills historical clicks (daily counts or individual events)
+// from another system onto existing short codes, recording where they came from
+func importClicks(c *gin.Context) {
+	var request struct {
+		Source     string `json:"source" binding:"required"`
+		ExternalID string `json:"externalId"`
+		Note       string `json:"note"`
+		Records    []struct {
+			ShortCode string     `json:"shortCode" binding:"required"`
+			Date      string     `json:"date"`
+			Timestamp *time.Time `json:"timestamp"`
+			Clicks    *int       `json:"clicks"`
+		} `json:"records" binding:"required,dive"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		respondError(c, apierror.Validation("Invalid request body"))
+		return
+	}
+
+	if len(request.Records) == 0 || len(request.Records) > maxImportRecords {
+		respondError(c, apierror.Validation("Between 1 and "+strconv.Itoa(maxImportRecords)+" records are required"))
+		return
+	}
+
+	records := make([]db.ClickImportRecord, 0, len(request.Records))
+	for i, r := range request.Records {
+		// A record without a click count is a single historical event
+		clicks := 1
+		if r.Clicks != nil {
+			clicks = *r.Clicks
+		}
+		if clicks < 0 {
+			respondError(c, apierror.Validation("Record "+strconv.Itoa(i)+": clicks must not be negative"))
+			return
+		}
+
+		var day time.Time
+		switch {
+		case r.Timestamp != nil:
+			day = r.Timestamp.UTC().Truncate(24 * time.Hour)
+		case r.Date != "":
+			parsed, err := time.Parse(time.DateOnly, r.Date)
+			if err != nil {
+				respondError(c, apierror.Validation("Record "+strconv.Itoa(i)+": date must be YYYY-MM-DD"))
+				return
+			}
+			day = parsed
+		default:
+			respondError(c, apierror.Validation("Record "+strconv.Itoa(i)+": date or timestamp is required"))
+			return
+		}
+		if day.After(time.Now()) {
+			respondError(c, apierror.Validation("Record "+strconv.Itoa(i)+": date is in the future"))
+			return
+		}
+
+		records = append(records, db.ClickImportRecord{ShortCode: r.ShortCode, Day: day, Clicks: clicks})
+	}
+
+	result, err := database.ImportClicks(c.Request.Context(), db.ClickImport{
+		Source:     strings.TrimSpace(request.Source),
+		ExternalID: strings.TrimSpace(request.ExternalID),
+		Note:       request.Note,
+		ImportedBy: requestActor(c),
+	}, records)
+
+	var unknown *db.UnknownCodesError
+	switch {
+	case errors.As(err, &unknown):
+		respondError(c, apierror.Unprocessable("Unknown short codes").WithDetails(gin.H{"shortCodes": unknown.ShortCodes}))
+		return
+	case errors.Is(err, db.ErrDuplicateImport):
+		respondError(c, apierror.Conflict("An import with this source and externalId was already applied"))
+		return
+	case err != nil:
+		respondError(c, apierror.Internal("Failed to import clicks").Wrap(err))
+		return
+	}
+
+	recordAudit(c, auditCreate, "click_import", strconv.Itoa(result.ID), nil, result)
+	c.JSON(http.StatusCreated, result)
+}
+
+func getClickImports(c *gin.Context) {
+	imports, err := database.GetClickImports(c.Request.Context(), 100)
+	if err != nil {
+		respondError(c, apierror.Internal("Database error").Wrap(err))
+		return
+	}
+
+	c.JSON(http.StatusOK, imports)
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"url-shortener/db"
+	"url-shortener/models"
+	"url-shortener/pkg/apierror"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// inactivityInterval is how often inactivity policies are applied
+	inactivityInterval = 24 * time.Hour
+	// maxArchivedLimit bounds a single archived link search
+	maxArchivedLimit = 1000
+)
+
+// defaultInactivityPolicy applies to links outside an organization and in
+// organizations without a policy of their own
+var defaultInactivityPolicy db.InactivityPolicy
+
+// applyInactivityPolicies archives or disables links without recent clicks.
+// It is scheduled once a day.
+func applyInactivityPolicies(ctx context.Context) error {
+	archived, disabled, err := database.ApplyInactivityPolicies(ctx, defaultInactivityPolicy)
+	if err != nil {
+		return err
+	}
+	if archived > 0 || disabled > 0 {
+		log.Printf("Inactivity policies archived %d and disabled %d links", archived, disabled)
+	}
+	return nil
+}
+
+// validInactivityAction reports whether action is something a policy can do to links
+func validInactivityAction(action string) bool {
+	return action == db.InactiveArchive || action == db.InactiveDisable
+}
+
+// searchArchivedURLs lists archived links, personal ones or those of an
+// organization the caller belongs to, optionally matching a search term
+func searchArchivedURLs(c *gin.Context) {
+	orgID, _ := strconv.Atoi(c.Query("orgId"))
+	if orgID > 0 && !adminOfOrg(c, orgID) {
+		if _, ok := orgRole(c, orgID); !ok {
+			return
+		}
+	}
+
+	limit := 100
+	if value := c.Query("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n <= 0 || n > maxArchivedLimit {
+			respondError(c, apierror.Validation("limit must be between 1 and "+strconv.Itoa(maxArchivedLimit)))
+			return
+		}
+		limit = n
+	}
+
+	records, err := database.SearchArchivedURLs(c.Request.Context(), orgID, strings.TrimSpace(c.Query("q")), limit)
+	if err != nil {
+		respondError(c, apierror.Internal("Database error").Wrap(err))
+		return
+	}
+
+	urls := make([]models.URL, 0, len(records))
+	for i := range records {
+		urls = append(urls, toURLModel(c, &records[i]))
+	}
+	c.JSON(http.StatusOK, urls)
+}
+
+// unarchiveShortURL lists an archived link again
+func unarchiveShortURL(c *gin.Context) {
+	shortCode := c.Param("shortCode")
+
+	err := database.UnarchiveURL(c.Request.Context(), shortCode)
+	if errors.Is(err, db.ErrNotArchived) {
+		respondError(c, apierror.Conflict("Short URL is not archived"))
+		return
+	}
+	if err != nil {
+		respondError(c, notFound(err, "Short URL not found"))
+		return
+	}
+
+	recordAudit(c, auditUnarchive, "url", shortCode, gin.H{"archived": true}, gin.H{"archived": false})
+	c.JSON(http.StatusOK, gin.H{"message": "URL unarchived successfully"})
+}
+
+func getOrgInactivityPolicy(c *gin.Context) {
+	orgID, ok := orgIDParam(c)
+	if !ok {
+		return
+	}
+	if _, ok := orgRole(c, orgID); !ok {
+		return
+	}
+
+	policy, err := database.GetInactivityPolicy(c.Request.Context(), orgID, defaultInactivityPolicy)
+	if err != nil {
+		respondError(c, notFound(err, "Organization not found"))
+		return
+	}
+	c.JSON(http.StatusOK, policy)
+}
+
+// setOrgInactivityPolicy sets what happens to an organization's links without
+// recent clicks; null months restores the instance default. Only owners may
+// do this.
+func setOrgInactivityPolicy(c *gin.Context) {
+	orgID, ok := orgIDParam(c)
+	if !ok {
+		return
+	}
+
+	var request struct {
+		Months *int   `json:"months"`
+		Action string `json:"action"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		respondError(c, apierror.Validation("Invalid request body"))
+		return
+	}
+
+	var policy *db.InactivityPolicy
+	if request.Months != nil {
+		if *request.Months < 0 {
+			respondError(c, apierror.Validation("months must not be negative"))
+			return
+		}
+		policy = &db.InactivityPolicy{Months: *request.Months, Action: request.Action}
+		if policy.Action == "" {
+			policy.Action = db.InactiveArchive
+		}
+		if !validInactivityAction(policy.Action) {
+			respondError(c, apierror.Validation("action must be archive or disable"))
+			return
+		}
+	}
+
+	role, ok := orgRole(c, orgID)
+	if !ok {
+		return
+	}
+	if role != db.RoleOwner {
+		respondError(c, apierror.Forbidden("Only owners can change the inactivity policy"))
+		return
+	}
+
+	before, err := database.GetInactivityPolicy(c.Request.Context(), orgID, defaultInactivityPolicy)
+	if err != nil {
+		respondError(c, notFound(err, "Organization not found"))
+		return
+	}
+	if err := database.SetInactivityPolicy(c.Request.Context(), orgID, policy); err != nil {
+		respondError(c, notFound(err, "Organization not found"))
+		return
+	}
+
+	after := policy
+	if after == nil {
+		after = &db.InactivityPolicy{Months: defaultInactivityPolicy.Months, Action: defaultInactivityPolicy.Action, Inherited: true}
+	}
+	recordAudit(c, auditUpdate, "inactivity_policy", strconv.Itoa(orgID), before, after)
+	c.JSON(http.StatusOK, after)
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"url-shortener/db"
+	"url-shortener/middleware"
+	"url-shortener/pkg/apierror"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ipFilter enforces the IP rules stored in the database
+var ipFilter = middleware.NewIPFilter()
+
+// loadIPRules reads the IP rules from the database into ipFilter
+func loadIPRules(ctx context.Context) error {
+	rules, err := database.GetIPRules(ctx)
+	if err != nil {
+		return err
+	}
+
+	blocked, adminAllowed := splitIPRules(rules)
+	ipFilter.SetRules(blocked, adminAllowed)
+	return nil
+}
+
+// splitIPRules parses rules into blocked networks and the admin allowlist
+func splitIPRules(rules []db.IPRule) (blocked, adminAllowed []netip.Prefix) {
+	for _, rule := range rules {
+		prefix, err := middleware.ParsePrefix(rule.CIDR)
+		if err != nil {
+			log.Printf("Skipping invalid IP rule %d (%s): %v", rule.ID, rule.CIDR, err)
+			continue
+		}
+		switch rule.Action {
+		case db.IPRuleBlock:
+			blocked = append(blocked, prefix)
+		case db.IPRuleAdminAllow:
+			adminAllowed = append(adminAllowed, prefix)
+		}
+	}
+	return blocked, adminAllowed
+}
+
+// getIPRules lists the blocked networks and the admin allowlist
+func getIPRules(c *gin.Context) {
+	rules, err := database.GetIPRules(c.Request.Context())
+	if err != nil {
+		respondError(c, apierror.Internal("Database error").Wrap(err))
+		return
+	}
+
+	c.JSON(http.StatusOK, rules)
+}
+
+// addIPRule blocks a network or adds it to the admin allowlist
+func addIPRule(c *gin.Context) {
+	var request struct {
+		CIDR   string `json:"cidr"`
+		Action string `json:"action"`
+		Note   string `json:"note"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		respondError(c, apierror.Validation("Invalid request body"))
+		return
+	}
+
+	prefix, err := middleware.ParsePrefix(request.CIDR)
+	if err != nil {
+		respondError(c, apierror.Validation("Invalid IP or CIDR"))
+		return
+	}
+	if request.Action != db.IPRuleBlock && request.Action != db.IPRuleAdminAllow {
+		respondError(c, apierror.Validation("Action must be block or admin_allow"))
+		return
+	}
+
+	rule := db.IPRule{CIDR: prefix.String(), Action: request.Action, Note: request.Note, CreatedBy: auditActor(c)}
+	if !keepsAdminAccess(c, func(rules []db.IPRule) []db.IPRule { return append(rules, rule) }) {
+		return
+	}
+
+	if err := database.AddIPRule(c.Request.Context(), &rule); err != nil {
+		if errors.Is(err, db.ErrDuplicateIPRule) {
+			respondError(c, apierror.Conflict("Rule already exists"))
+			return
+		}
+		respondError(c, apierror.Internal("Failed to store rule").Wrap(err))
+		return
+	}
+
+	reloadIPRules(c)
+	recordAudit(c, auditCreate, "ip_rule", strconv.FormatInt(rule.ID, 10), nil, rule)
+	c.JSON(http.StatusCreated, rule)
+}
+
+// deleteIPRule removes a rule
+func deleteIPRule(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		respondError(c, apierror.NotFound("Rule not found"))
+		return
+	}
+
+	if !keepsAdminAccess(c, func(rules []db.IPRule) []db.IPRule {
+		kept := rules[:0]
+		for _, r := range rules {
+			if r.ID != id {
+				kept = append(kept, r)
+			}
+		}
+		return kept
+	}) {
+		return
+	}
+
+	rule, err := database.DeleteIPRule(c.Request.Context(), id)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondError(c, apierror.NotFound("Rule not found"))
+		return
+	}
+	if err != nil {
+		respondError(c, apierror.Internal("Database error").Wrap(err))
+		return
+	}
+
+	reloadIPRules(c)
+	recordAudit(c, auditDelete, "ip_rule", strconv.FormatInt(rule.ID, 10), rule, nil)
+	c.JSON(http.StatusOK, gin.H{"message": "Rule deleted successfully"})
+}
+
+// keepsAdminAccess refuses a change that would block the caller or leave them
+// outside the admin allowlist, since they could not undo it afterwards
+func keepsAdminAccess(c *gin.Context, change func([]db.IPRule) []db.IPRule) bool {
+	rules, err := database.GetIPRules(c.Request.Context())
+	if err != nil {
+		respondError(c, apierror.Internal("Database error").Wrap(err))
+		return false
+	}
+
+	blocked, adminAllowed := splitIPRules(change(rules))
+	ip := c.ClientIP()
+	if middleware.ContainsIP(blocked, ip) || (len(adminAllowed) > 0 && !middleware.ContainsIP(adminAllowed, ip)) {
+		respondError(c, apierror.Conflict("Rule would lock your address out of the admin API"))
+		return false
+	}
+	return true
+}
+
+// reloadIPRules applies a rule change on this instance right away
+func reloadIPRules(c *gin.Context) {
+	if err := loadIPRules(c.Request.Context()); err != nil {
+		log.Printf("Failed to reload IP rules: %v", err)
+	}
+}
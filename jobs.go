@@ -0,0 +1,37 @@
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+	"url-shortener/pkg/jobs"
+)
+
+// limiterCleanupInterval is how often idle clients are dropped from the in-memory limiters
+const limiterCleanupInterval = 10 * time.Minute
+
+// backgroundJobs runs the scheduled maintenance jobs and the work handlers
+// hand off, and is drained on shutdown
+var backgroundJobs *jobs.Scheduler
+
+// runInBackground hands run off to the worker pool, logging when it has to be dropped
+func runInBackground(name string, run jobs.Func) {
+	if err := backgroundJobs.Go(name, run); err != nil {
+		log.Printf("Dropped %s task: %v", name, err)
+	}
+}
+
+// cleanupLimiters drops clients that went idle from the rate limiter, the
+// CAPTCHA allowance and the click fraud detector
+func cleanupLimiters(ctx context.Context) error {
+	if rateLimiter != nil {
+		rateLimiter.Cleanup()
+	}
+	if captchaAllowance != nil {
+		captchaAllowance.Cleanup()
+	}
+	if detector := fraudDetector.Load(); detector != nil {
+		detector.Cleanup()
+	}
+	return nil
+}
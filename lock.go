@@ -0,0 +1,60 @@
+package main
+
+import (
+	"net/http"
+	"url-shortener/middleware"
+	"url-shortener/pkg/apierror"
+
+	"github.com/gin-gonic/gin"
+)
+
+// requestActor identifies who performed an admin action, for the lock trail
+func requestActor(c *gin.Context) string {
+	if userID := c.GetString(middleware.ContextUserID); userID != "" {
+		return userID
+	}
+	if actor := c.GetHeader("X-Actor"); actor != "" {
+		return actor
+	}
+	return "admin"
+}
+
+func lockShortURL(c *gin.Context) {
+	shortCode := c.Param("shortCode")
+	var request struct {
+		Reason string `json:"reason"`
+	}
+	// The reason is optional when locking
+	_ = c.ShouldBindJSON(&request)
+
+	actor := requestActor(c)
+	if err := database.SetLocked(c.Request.Context(), shortCode, true, actor, request.Reason); err != nil {
+		respondError(c, notFound(err, "Short URL not found"))
+		return
+	}
+
+	recordAudit(c, auditLock, "url", shortCode, gin.H{"locked": false}, gin.H{"locked": true, "reason": request.Reason})
+	c.JSON(http.StatusOK, gin.H{"message": "URL locked successfully"})
+}
+
+// unlockShortURL is the first step of changing a locked link: it must be
+// unlocked explicitly, with a reason, before it can be updated or deleted
+func unlockShortURL(c *gin.Context) {
+	shortCode := c.Param("shortCode")
+	var request struct {
+		Reason string `json:"reason" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		respondError(c, apierror.Validation("A reason is required to unlock a URL"))
+		return
+	}
+
+	actor := requestActor(c)
+	if err := database.SetLocked(c.Request.Context(), shortCode, false, actor, request.Reason); err != nil {
+		respondError(c, notFound(err, "Short URL not found"))
+		return
+	}
+
+	recordAudit(c, auditUnlock, "url", shortCode, gin.H{"locked": true}, gin.H{"locked": false, "reason": request.Reason})
+	c.JSON(http.StatusOK, gin.H{"message": "URL unlocked successfully"})
+}
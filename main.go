@@ -1,69 +1,262 @@
 package main
 
 import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"expvar"
 	"fmt"
 	"log"
+	"net"
 	"net/http"
 	"os"
+	"os/signal"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"syscall"
 	"time"
 	"url-shortener/config"
 	"url-shortener/db"
 	_ "url-shortener/docs" // Import docs for Swagger
+	"url-shortener/events"
 	"url-shortener/middleware"
 	"url-shortener/models"
+	"url-shortener/pkg/apierror"
+	"url-shortener/pkg/botdetect"
+	"url-shortener/pkg/captcha"
+	"url-shortener/pkg/cdn"
+	"url-shortener/pkg/jobs"
+	"url-shortener/pkg/limiter"
+	"url-shortener/pkg/objectstore"
+	"url-shortener/pkg/pagemeta"
 
-	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
-	"github.com/joho/godotenv"
 	swaggerFiles "github.com/swaggo/files"
 	ginSwagger "github.com/swaggo/gin-swagger"
 )
 
-const chars = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+// reservedCodes are top-level path segments that must never resolve as short codes
+var reservedCodes = []string{"api", "urls", "auth", "swagger", "healthz", ".well-known", "favicon.ico", "robots.txt", "static", "graphql", "plans", "transfers", "scim", "conversions"}
+
+// domainPattern matches a bare hostname such as sho.rt, without scheme, port or path
+var domainPattern = regexp.MustCompile(`^([a-zA-Z0-9]([a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?\.)+[a-zA-Z]{2,63}$`)
 
 var database *db.Database
 
-func base62Encode(num int) string {
-	encoded := ""
-	for num > 0 {
-		remainder := num % 62
-		encoded = string(chars[remainder]) + encoded
-		num /= 62
-	}
-	return encoded
-}
+// pageFetcher loads destination pages for their title and description; nil
+// when PAGE_META_ENABLED is off. Like the other detectors it is swapped when
+// the configuration is reloaded.
+var pageFetcher atomic.Pointer[pagemeta.Fetcher]
 
-func generateShortCode() string {
-	timestamp := time.Now().UnixNano()
-	return base62Encode(int(timestamp % 100000000))
-}
+// botDetector classifies redirects as bot hits; nil when bot filtering is disabled
+var botDetector atomic.Pointer[botdetect.Detector]
+
+// eventPublisher receives a url.clicked event per redirect; nil when no broker is configured
+var eventPublisher events.Publisher
+var countryHeader string
+
+// publicBaseURL and linkBasePath are used to build the shortUrl returned to clients
+var publicBaseURL string
+var linkBasePath string
+
+// maxURLLength caps the length of link destinations; 0 means no limit
+var maxURLLength int
 
 func createShortURL(c *gin.Context) {
 	var request struct {
-		URL string `json:"url"`
+		URL       string   `json:"url"`
+		ShortCode string   `json:"shortCode"`
+		Domain    string   `json:"domain"`
+		Tags      []string `json:"tags"`
+		OrgID     int      `json:"orgId"`
+
+		Targets      map[string]string `json:"targets"`
+		ForwardQuery bool              `json:"forwardQuery"`
+		ForwardPath  bool              `json:"forwardPath"`
+		UTM          *utmParams        `json:"utm"`
+		PublicStats  bool              `json:"publicStats"`
+		OpenGraph    *db.OpenGraph     `json:"openGraph"`
+		Frame        bool              `json:"frame"`
+
+		TrackConversions bool `json:"trackConversions"`
+
+		Notes    string          `json:"notes"`
+		Metadata json.RawMessage `json:"metadata"`
+
+		// SlugStyle picks how the code is generated when none is given
+		SlugStyle string `json:"slugStyle" binding:"omitempty,oneof=base62 readable"`
 	}
 	if err := c.ShouldBindJSON(&request); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		respondError(c, apierror.Validation("Invalid request body"))
+		return
+	}
+	if request.ShortCode != "" && request.SlugStyle == slugReadable {
+		respondError(c, apierror.Validation("slugStyle readable generates the code; leave out shortCode"))
 		return
 	}
 
-	shortCode := generateShortCode()
-	timestamp := time.Now()
+	request.Domain = strings.ToLower(request.Domain)
+	if request.Domain != "" && !domainPattern.MatchString(request.Domain) {
+		respondError(c, apierror.Validation("Invalid domain"))
+		return
+	}
+	if request.ShortCode != "" && !validCustomCode(request.ShortCode) {
+		respondError(c, apierror.Validation("Invalid short code"))
+		return
+	}
 
-	url := models.URL{
-		Original:    request.URL,
-		ShortCode:   shortCode,
-		CreatedAt:   timestamp,
-		UpdatedAt:   timestamp,
-		AccessCount: 0,
+	tags, ok := normalizeTags(request.Tags)
+	if !ok {
+		respondError(c, apierror.Validation("Invalid tag"))
+		return
 	}
 
-	err := database.CreateShortURL(url.Original, url.ShortCode)
+	original, ok := withUTM(request.URL, request.UTM)
+	if !ok || !validDestination(original) {
+		respondError(c, apierror.Validation("url must be an http or https URL"))
+		return
+	}
+	var campaign string
+	if request.UTM != nil {
+		campaign = strings.TrimSpace(request.UTM.Campaign)
+	}
+
+	targets, ok := normalizeTargets(request.Targets)
+	if !ok {
+		respondError(c, apierror.Validation("Invalid target"))
+		return
+	}
+
+	openGraph, ok := normalizeOpenGraph(request.OpenGraph)
+	if !ok {
+		respondError(c, apierror.Validation("Invalid Open Graph metadata"))
+		return
+	}
+
+	notes, ok := normalizeNotes(request.Notes)
+	if !ok {
+		respondError(c, apierror.Validation("notes must be at most 2000 characters"))
+		return
+	}
+	metadata, ok := normalizeMetadata(request.Metadata)
+	if !ok {
+		respondError(c, apierror.Validation("metadata must be a JSON object of at most 4096 bytes"))
+		return
+	}
+
+	if !allowedDestinations(c, linkDestinations(&db.URL{OriginalURL: original, Targets: targets})...) {
+		return
+	}
+	if request.Frame && !frameable(original) {
+		respondError(c, apierror.Unprocessable("Destination can't be shown in frame mode: it must be an https URL on an allowed host"))
+		return
+	}
+
+	if request.OrgID > 0 && !adminOfOrg(c, request.OrgID) {
+		role, ok := orgRole(c, request.OrgID)
+		if !ok {
+			return
+		}
+		if !canWriteOrgLinks(role) {
+			respondError(c, apierror.Forbidden("Your role does not allow creating links"))
+			return
+		}
+	}
+
+	if request.Domain != "" && !checkCustomDomain(c, request.OrgID) {
+		return
+	}
+	if request.ShortCode != "" && !checkReservedPrefix(c, request.ShortCode, request.OrgID) {
+		return
+	}
+	if !checkLinkQuota(c, request.OrgID) {
+		return
+	}
+
+	token, tokenHash, err := newManagementToken()
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store URL"})
+		respondError(c, apierror.Internal("Failed to generate management token").Wrap(err))
 		return
 	}
 
+	// The new link's code belongs to the namespace of its domain, whatever
+	// ?domain= says, and so does the work it hands off below
+	c.Request = c.Request.WithContext(db.WithDomain(c.Request.Context(), request.Domain))
+	create := database.CreateSequencedURL
+	if request.SlugStyle == slugReadable {
+		create = createReadableURL
+	}
+	id, shortCode, err := create(c.Request.Context(), db.NewURL{
+		OriginalURL:    original,
+		ShortCode:      request.ShortCode,
+		Domain:         request.Domain,
+		Tags:           tags,
+		OwnerTokenHash: tokenHash,
+		OwnerID:        c.GetString(middleware.ContextUserID),
+		OrgID:          request.OrgID,
+		Targets:        targets,
+		ForwardQuery:   request.ForwardQuery,
+		ForwardPath:    request.ForwardPath,
+		Campaign:       campaign,
+		PublicStats:    request.PublicStats,
+		OpenGraph:      openGraph,
+		Frame:          request.Frame,
+
+		TrackConversions: request.TrackConversions,
+		Notes:            notes,
+		Metadata:         metadata,
+	})
+	if errors.Is(err, db.ErrCodeTaken) {
+		respondError(c, apierror.Conflict("Short code is already taken on this domain"))
+		return
+	}
+	if err != nil {
+		respondError(c, apierror.Internal("Failed to store URL").Wrap(err))
+		return
+	}
+	rememberCode(shortCode)
+
+	timestamp := time.Now()
+	url := models.URL{
+		ID:           int(id),
+		Original:     original,
+		ShortCode:    shortCode,
+		ShortURL:     shortURLFor(c, request.Domain, shortCode),
+		Domain:       request.Domain,
+		OrgID:        request.OrgID,
+		Targets:      targets,
+		ForwardQuery: request.ForwardQuery,
+		ForwardPath:  request.ForwardPath,
+		Campaign:     campaign,
+		Tags:         tags,
+		CreatedAt:    timestamp,
+		UpdatedAt:    timestamp,
+		AccessCount:  0,
+		PublicStats:  request.PublicStats,
+		Frame:        request.Frame,
+		OpenGraph:    (*models.OpenGraph)(openGraph),
+
+		TrackConversions: request.TrackConversions,
+		Notes:            notes,
+		Metadata:         linkMetadata(metadata),
+
+		ManagementToken: token,
+	}
+
+	audited := url
+	audited.ManagementToken = ""
+	recordAudit(c, auditCreate, "url", shortCode, nil, audited)
+
+	if fetcher := pageFetcher.Load(); fetcher != nil {
+		runInBackground("page-metadata", withRequestTenant(c, func(ctx context.Context) error {
+			return fetchPageMetadata(ctx, fetcher, url.ShortCode, url.Original)
+		}))
+	}
+
 	c.JSON(http.StatusCreated, url)
 }
 
@@ -71,72 +264,449 @@ func getOriginalURL(c *gin.Context) {
 	fmt.Println("getOriginalURL")
 
 	shortCode := c.Param("shortCode")
+	if !codeMayExist(shortCode) {
+		c.HTML(http.StatusNotFound, "notfound.html", gin.H{
+			"message": "Short URL not found",
+		})
+		return
+	}
 
-	url, err := database.GetURLByShortCode(shortCode)
-	if err != nil {
+	url, err := resolveLink(c, shortCode)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		log.Printf("Failed to resolve %s (request %s): %v", shortCode, c.GetString(middleware.ContextRequestID), err)
+		c.HTML(http.StatusInternalServerError, "error.html", gin.H{
+			"message": "Something went wrong. Please try again.",
+		})
+		return
+	}
+	if err != nil || !acceptsPath(c, url) {
 		c.HTML(http.StatusNotFound, "notfound.html", gin.H{
 			"message": "Short URL not found",
 		})
 		return
 	}
+	if url.Disabled {
+		c.HTML(http.StatusGone, "disabled.html", gin.H{
+			"message": "This link has been disabled",
+		})
+		return
+	}
 
-	// Increment access count
-	if err := database.IncrementClickCount(shortCode); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update access count"})
+	// Crawlers and link unfurlers are counted separately from human clicks, as
+	// are clicks excluded by click fraud detection
+	click := parseClick(c.Request.UserAgent())
+	click.Country = visitorCountry(c)
+	click.ClickID = newClickID()
+	detector := botDetector.Load()
+	isBot := detector != nil && detector.IsBot(c.Request.UserAgent())
+	verdict := checkClick(c, shortCode, isBot)
+	switch {
+	case url.Stale:
+		// The database just failed the lookup, so the click goes uncounted
+		// rather than waiting for it to time out again
+	case isBot:
+		err = database.IncrementBotClickCount(c.Request.Context(), shortCode)
+	case verdict.Exclude:
+		err = database.IncrementSuspiciousClickCount(c.Request.Context(), shortCode, verdict.Reason)
+	default:
+		var clicks int
+		clicks, err = database.IncrementClickCount(c.Request.Context(), shortCode, click)
+		if err == nil {
+			notifyClickMilestone(c, url, clicks)
+			if err := database.RecordVisit(c.Request.Context(), url.ID, visitorID(c)); err != nil {
+				log.Printf("Failed to record unique click on %s: %v", shortCode, err)
+			}
+		}
+	}
+	if err != nil {
+		respondError(c, apierror.Internal("Failed to update access count").Wrap(err))
+		return
+	}
+
+	publishClick(c, url, click, isBot, verdict.Reason)
+
+	// Platform targets take precedence over a rotation or an A/B split, so
+	// app-store links are never diluted by them
+	destination, ok := targetFor(url, c.Request.UserAgent(), click)
+	if !ok {
+		destination = url.OriginalURL
+		if rotated, ok := rotateDestination(c, url, !isBot && !verdict.Exclude); ok {
+			destination = rotated
+		} else if variant, ok := assignVariant(c, url); ok {
+			destination = variant.URL
+			if !isBot && !verdict.Exclude {
+				if err := database.RecordVariantClick(c.Request.Context(), shortCode, variant.Name); err != nil {
+					log.Printf("Failed to record click for variant %s of %s: %v", variant.Name, shortCode, err)
+				}
+			}
+		}
+	}
+
+	destination = forwardRequest(c, url, withClickID(url, destination, click.ClickID))
+	if serveOpenGraph(c, url, destination) {
+		return
+	}
+	if !isBot && openAppLink(c, url, destination) {
+		return
+	}
+	// Retargeting pixels fire for human clicks only
+	var pixels []db.Pixel
+	if !isBot && !verdict.Exclude {
+		pixels = linkPixels(c, url)
+	}
+	if serveFrame(c, url, destination, pixels) || firePixels(c, destination, pixels) {
+		return
+	}
+	cacheRedirect(c, url)
+	c.Redirect(http.StatusFound, destination)
+}
+
+// headOriginalURL answers link-preview HEAD requests with the redirect headers
+// without counting them as clicks
+func headOriginalURL(c *gin.Context) {
+	shortCode := c.Param("shortCode")
+	if !codeMayExist(shortCode) {
+		c.Status(http.StatusNotFound)
+		return
+	}
+
+	url, err := resolveLink(c, shortCode)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+	if err != nil || !acceptsPath(c, url) {
+		c.Status(http.StatusNotFound)
+		return
+	}
+	if url.Disabled {
+		c.Status(http.StatusGone)
+		return
+	}
+
+	destination := forwardRequest(c, url, url.OriginalURL)
+	if url.Frame && frameable(destination) {
+		c.Header("Cache-Control", "no-store")
+		c.Status(http.StatusOK)
+		return
+	}
+	cacheRedirect(c, url)
+	c.Redirect(http.StatusFound, destination)
+}
+
+// optionsShortURL reports the methods supported on a short link route
+func optionsShortURL(allowed string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Header("Allow", allowed)
+		c.Header("Access-Control-Allow-Methods", allowed)
+		c.Header("Access-Control-Allow-Headers", "Origin, Content-Length, Content-Type, Authorization, "+managementTokenHeader+", "+apiKeyHeader)
+		c.Status(http.StatusNoContent)
+	}
+}
+
+// rootShortCode guards root-level short link handlers against reserved path segments
+func rootShortCode(handler gin.HandlerFunc) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if slices.Contains(reservedCodes, c.Param("shortCode")) {
+			c.HTML(http.StatusNotFound, "notfound.html", gin.H{
+				"message": "Short URL not found",
+			})
+			return
+		}
+		handler(c)
+	}
+}
+
+// homePage serves the web UI to browsers and a pointer to the API to everyone else
+func homePage(c *gin.Context) {
+	if c.NegotiateFormat(gin.MIMEJSON, gin.MIMEHTML) == gin.MIMEHTML {
+		c.HTML(http.StatusOK, "home.html", nil)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "URL Shortener API", "docs": "/swagger/index.html", "api": "/api/v1"})
+}
+
+func healthCheck(c *gin.Context) {
+	if err := database.Ping(c.Request.Context()); err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"status": "unavailable", "error": "Database unreachable"})
 		return
 	}
 
-	c.Redirect(http.StatusFound, url.OriginalURL)
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
 }
 
 func updateShortURL(c *gin.Context) {
 	shortCode := c.Param("shortCode")
 	var request struct {
 		URL string `json:"url"`
+		// The forwarding options are optional; the destination may be omitted when only they change
+		ForwardQuery *bool `json:"forwardQuery"`
+		ForwardPath  *bool `json:"forwardPath"`
+		PublicStats  *bool `json:"publicStats"`
+		Frame        *bool `json:"frame"`
+
+		TrackConversions *bool `json:"trackConversions"`
+
+		Notes *string `json:"notes"`
+		// Metadata replaces the link's metadata; null clears it
+		Metadata json.RawMessage `json:"metadata"`
+
+		// UpdatedAt is the link's updatedAt as the client last read it; the
+		// update fails with 409 if the link changed since
+		UpdatedAt *time.Time `json:"updatedAt"`
 	}
 	if err := c.ShouldBindJSON(&request); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		respondError(c, apierror.Validation("Invalid request body"))
 		return
 	}
+	if request.Notes != nil {
+		notes, ok := normalizeNotes(*request.Notes)
+		if !ok {
+			respondError(c, apierror.Validation("notes must be at most 2000 characters"))
+			return
+		}
+		request.Notes = &notes
+	}
+	var metadata json.RawMessage
+	if request.Metadata != nil {
+		var ok bool
+		if metadata, ok = normalizeMetadata(request.Metadata); !ok {
+			respondError(c, apierror.Validation("metadata must be a JSON object of at most 4096 bytes"))
+			return
+		}
+	}
 
-	if err := database.UpdateURL(shortCode, request.URL); err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Short URL not found"})
+	if request.URL != "" && !validDestination(request.URL) {
+		respondError(c, apierror.Validation("url must be an http or https URL"))
+		return
+	}
+	if request.URL != "" && !allowedDestinations(c, request.URL) {
+		return
+	}
+
+	previous, err := database.GetURLByShortCode(c.Request.Context(), shortCode)
+	if err != nil {
+		respondError(c, notFound(err, "Short URL not found"))
+		return
+	}
+	if editConflict(c, previous, request.UpdatedAt) {
 		return
 	}
 
+	if request.Frame != nil && *request.Frame {
+		destination := previous.OriginalURL
+		if request.URL != "" {
+			destination = request.URL
+		}
+		if !frameable(destination) {
+			respondError(c, apierror.Unprocessable("Destination can't be shown in frame mode: it must be an https URL on an allowed host"))
+			return
+		}
+	}
+
+	edit := db.LinkEdit{
+		Destination: request.URL,
+		Update: db.BatchUpdate{
+			ForwardQuery:     request.ForwardQuery,
+			ForwardPath:      request.ForwardPath,
+			PublicStats:      request.PublicStats,
+			Frame:            request.Frame,
+			TrackConversions: request.TrackConversions,
+			Notes:            request.Notes,
+			Metadata:         metadata,
+		},
+	}
+	if edit.Destination == "" && edit.Update.Empty() {
+		respondError(c, apierror.Validation("url is required unless options change"))
+		return
+	}
+	old, updated := gin.H{}, gin.H{}
+	if request.URL != "" {
+		old["original"], updated["original"] = previous.OriginalURL, request.URL
+	}
+	for _, option := range []struct {
+		field    string
+		previous bool
+		value    *bool
+	}{
+		{"forwardQuery", previous.ForwardQuery, request.ForwardQuery},
+		{"forwardPath", previous.ForwardPath, request.ForwardPath},
+		{"publicStats", previous.PublicStats, request.PublicStats},
+		{"frame", previous.Frame, request.Frame},
+		{"trackConversions", previous.TrackConversions, request.TrackConversions},
+	} {
+		if option.value != nil {
+			old[option.field], updated[option.field] = option.previous, *option.value
+		}
+	}
+	if request.Notes != nil {
+		old["notes"], updated["notes"] = previous.Notes, *request.Notes
+	}
+	if metadata != nil {
+		old["metadata"], updated["metadata"] = previous.Metadata, linkMetadata(metadata)
+	}
+
+	if err := database.EditLink(c.Request.Context(), shortCode, editVersion(c, previous, request.UpdatedAt), edit, auditActor(c)); err != nil {
+		respondEditError(c, shortCode, err)
+		return
+	}
+
+	recordAudit(c, auditUpdate, "url", shortCode, old, updated)
+	if link, err := database.GetURLByShortCode(c.Request.Context(), shortCode); err == nil {
+		c.Header("ETag", linkETag(link))
+	}
 	c.JSON(http.StatusOK, gin.H{"message": "URL updated successfully"})
 }
 
 func deleteShortURL(c *gin.Context) {
 	shortCode := c.Param("shortCode")
 
-	if err := database.DeleteURL(shortCode); err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Short URL not found"})
+	url, err := database.GetURLByShortCode(c.Request.Context(), shortCode)
+	if err != nil {
+		respondError(c, notFound(err, "Short URL not found"))
 		return
 	}
 
+	if archiveOnDelete.Load() {
+		if url.Locked {
+			respondError(c, apierror.Locked("Short URL is locked"))
+			return
+		}
+
+		if err := archiveURL(c.Request.Context(), url); err != nil {
+			log.Printf("Failed to archive %s before delete: %v", shortCode, err)
+			respondError(c, apierror.Internal("Failed to archive URL"))
+			return
+		}
+	}
+
+	if err := database.DeleteURL(c.Request.Context(), shortCode); err != nil {
+		respondError(c, notFound(err, "Short URL not found"))
+		return
+	}
+
+	recordAudit(c, auditDelete, "url", shortCode, toURLModel(c, url), nil)
 	c.JSON(http.StatusOK, gin.H{"message": "URL deleted successfully"})
 }
 
 func getURLStats(c *gin.Context) {
 	shortCode := c.Param("shortCode")
 
-	url, err := database.GetURLByShortCode(shortCode)
+	if version, err := database.GetURLVersion(c.Request.Context(), shortCode); err == nil && notModified(c, version) {
+		return
+	}
+
+	url, err := database.GetURLByShortCode(c.Request.Context(), shortCode)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Short URL not found"})
+		respondError(c, notFound(err, "Short URL not found"))
 		return
 	}
 
-	urlStats := models.URL{
-		ID:          url.ID,
-		Original:    url.OriginalURL,
-		ShortCode:   url.ShortCode,
-		CreatedAt:   parseTime(url.CreatedAt),
-		UpdatedAt:   parseTime(url.UpdatedAt),
-		AccessCount: url.Clicks,
+	breakdown, err := database.GetClickBreakdown(c.Request.Context(), url.ID)
+	if err != nil {
+		respondError(c, apierror.Internal("Database error").Wrap(err))
+		return
 	}
 
-	c.JSON(http.StatusOK, urlStats)
+	stats := urlStats{URL: toURLModel(c, url), Breakdown: breakdown}
+	if len(url.Variants) > 0 {
+		if stats.Variants, err = database.GetVariantStats(c.Request.Context(), url); err != nil {
+			respondError(c, apierror.Internal("Database error").Wrap(err))
+			return
+		}
+	}
+	if url.Rotation != nil {
+		if stats.Rotation, err = database.GetRotationStats(c.Request.Context(), url); err != nil {
+			respondError(c, apierror.Internal("Database error").Wrap(err))
+			return
+		}
+	}
+	if url.TrackConversions || url.Conversions > 0 {
+		if stats.Conversions, err = database.GetConversionStats(c.Request.Context(), url.ID); err != nil {
+			respondError(c, apierror.Internal("Database error").Wrap(err))
+			return
+		}
+		for i := range stats.Conversions {
+			if url.Clicks > 0 {
+				stats.Conversions[i].Rate = float64(stats.Conversions[i].Conversions) / float64(url.Clicks)
+			}
+		}
+	}
+
+	c.JSON(http.StatusOK, stats)
+}
+
+// siteURL is BASE_URL, or the scheme and host the current request arrived on
+func siteURL(c *gin.Context) string {
+	if publicBaseURL != "" {
+		return publicBaseURL
+	}
+	return requestScheme(c) + "://" + c.Request.Host
+}
+
+// shortURLFor builds the public link for a code, preferring the link's custom
+// domain, then BASE_URL, then the host the current request arrived on
+func shortURLFor(c *gin.Context, domain, shortCode string) string {
+	base := publicBaseURL
+	switch {
+	case domain != "":
+		base = "https://" + domain
+	case base == "" && c != nil:
+		base = requestScheme(c) + "://" + c.Request.Host
+	}
+
+	return base + strings.TrimRight(linkBasePath, "/") + "/" + shortCode
+}
+
+// requestScheme reports whether the client reached us over http or https,
+// honoring X-Forwarded-Proto from a TLS-terminating proxy
+func requestScheme(c *gin.Context) string {
+	if c.Request.TLS != nil || c.GetHeader("X-Forwarded-Proto") == "https" {
+		return "https"
+	}
+	return "http"
+}
+
+// toURLModel converts a database record into its API representation.
+// c may be nil outside a request, in which case shortUrl relies on BASE_URL.
+func toURLModel(c *gin.Context, record *db.URL) models.URL {
+	return models.URL{
+		ID:               record.ID,
+		Original:         record.OriginalURL,
+		ShortCode:        record.ShortCode,
+		ShortURL:         shortURLFor(c, record.Domain, record.ShortCode),
+		Domain:           record.Domain,
+		OrgID:            record.OrgID,
+		Locked:           record.Locked,
+		Tags:             append([]string{}, record.Tags...),
+		CreatedAt:        parseTime(record.CreatedAt),
+		UpdatedAt:        parseTime(record.UpdatedAt),
+		AccessCount:      record.Clicks,
+		BotClicks:        record.BotClicks,
+		SuspiciousClicks: record.SuspiciousClicks,
+		UniqueClicks:     record.UniqueClicks,
+		PublicStats:      record.PublicStats,
+		Frame:            record.Frame,
+		TrackConversions: record.TrackConversions,
+		Notes:            record.Notes,
+		Metadata:         record.Metadata,
+		Pixels:           record.Pixels,
+		Title:            record.Title,
+		Description:      record.Description,
+		Targets:          record.Targets,
+		ForwardQuery:     record.ForwardQuery,
+		ForwardPath:      record.ForwardPath,
+		Campaign:         record.Campaign,
+		CampaignID:       record.CampaignID,
+		Disabled:         record.Disabled,
+		DisabledReason:   record.DisabledReason,
+		Archived:         record.Archived,
+		Health:           (*models.LinkHealth)(record.Health),
+		Rotation:         (*models.Rotation)(record.Rotation),
+		AppLink:          (*models.AppLink)(record.AppLink),
+		OpenGraph:        (*models.OpenGraph)(record.OpenGraph),
+		CacheTTL:         record.CacheTTL,
+	}
 }
 
 func parseTime(timeStr string) time.Time {
@@ -148,33 +718,192 @@ func parseTime(timeStr string) time.Time {
 }
 
 func getAllShortURLs(c *gin.Context) {
-	urlRecords, err := database.GetAllURLs(7)
+	// Links of an organization are only listed to its members, so a
+	// non-member can't learn from a 304 that they are unchanged either
+	orgID, _ := strconv.Atoi(c.Query("orgId"))
+	if orgID > 0 && !adminOfOrg(c, orgID) {
+		if _, ok := orgRole(c, orgID); !ok {
+			return
+		}
+	}
+
+	if version, err := database.GetURLsVersion(c.Request.Context()); err == nil && notModified(c, version) {
+		return
+	}
+
+	health := c.Query("health")
+	if health != "" && health != "broken" && health != "ok" {
+		respondError(c, apierror.Validation("health must be broken or ok"))
+		return
+	}
+
+	urlRecords, err := database.GetAllURLs(c.Request.Context(), 7, orgID, c.Query("campaign"), health, metadataFilter(c))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		respondError(c, apierror.Internal("Database error").Wrap(err))
 		return
 	}
 
 	urls := []models.URL{}
 
 	for _, record := range urlRecords {
-		url := models.URL{
-			ID:          record.ID,
-			Original:    record.OriginalURL,
-			ShortCode:   record.ShortCode,
-			CreatedAt:   parseTime(record.CreatedAt),
-			UpdatedAt:   parseTime(record.UpdatedAt),
-			AccessCount: record.Clicks,
-		}
-		urls = append(urls, url)
+		urls = append(urls, toURLModel(c, &record))
 	}
 
 	c.JSON(http.StatusOK, urls)
 }
 
+// getEventsSchema serves the versioned JSON Schemas of all event payloads
+func getEventsSchema(c *gin.Context) {
+	c.Header("X-Events-Schema-Version", events.SchemaVersion)
+	c.Data(http.StatusOK, "application/json; charset=utf-8", events.Schemas)
+}
+
+// apiAuth holds the per-route authorization checks of the management API
+type apiAuth struct {
+	// admin guards admin-only routes
+	admin gin.HandlerFunc
+	// owner guards routes acting on a single link
+	owner gin.HandlerFunc
+	// write guards routes that create or change links
+	write gin.HandlerFunc
+	// captcha challenges anonymous link creation
+	captcha gin.HandlerFunc
+}
+
+// registerAPIRoutes mounts the JSON management API on a versioned (or legacy) group
+func registerAPIRoutes(api *gin.RouterGroup, auth apiAuth) {
+	api.GET("/urls", requireScope(scopeLinksRead), getAllShortURLs)
+	api.GET("/urls/archived", requireScope(scopeLinksRead), searchArchivedURLs)
+	api.POST("/urls", requireScope(scopeLinksWrite), auth.write, auth.captcha, createShortURL)
+	api.POST("/urls/suggest", requireScope(scopeLinksWrite), auth.write, suggestShortCodes)
+	api.PATCH("/urls/batch", requireScope(scopeLinksWrite), auth.write, batchUpdateURLs)
+	api.DELETE("/urls/batch", requireScope(scopeLinksWrite), auth.write, batchDeleteURLs)
+	api.PUT("/urls/:shortCode", requireScope(scopeLinksWrite), acceptSigned(signedEdit), auth.write, auth.owner, updateShortURL)
+	api.PATCH("/urls/:shortCode", requireScope(scopeLinksWrite), auth.write, auth.owner, patchShortURL)
+	api.DELETE("/urls/:shortCode", requireScope(scopeLinksWrite), auth.write, auth.owner, deleteShortURL)
+	api.GET("/urls/:shortCode/stats", requireScope(scopeStatsRead), acceptSigned(signedStats), auth.owner, getURLStats)
+	api.GET("/urls/:shortCode/stats/stream", requireScope(scopeStatsRead), acceptSigned(signedStats), auth.owner, streamURLStats)
+	api.GET("/urls/:shortCode/stats/timeseries", requireScope(scopeStatsRead), acceptSigned(signedStats), auth.owner, getURLTimeSeries)
+	api.POST("/urls/:shortCode/stats/reset", requireScope(scopeLinksWrite), auth.write, auth.owner, resetURLStats)
+	api.PUT("/urls/:shortCode/targets", requireScope(scopeLinksWrite), auth.write, auth.owner, setURLTargets)
+	api.PUT("/urls/:shortCode/variants", requireScope(scopeLinksWrite), auth.write, auth.owner, setURLVariants)
+	api.PUT("/urls/:shortCode/rotation", requireScope(scopeLinksWrite), auth.write, auth.owner, setURLRotation)
+	api.PUT("/urls/:shortCode/app-link", requireScope(scopeLinksWrite), auth.write, auth.owner, setURLAppLink)
+	api.PUT("/urls/:shortCode/open-graph", requireScope(scopeLinksWrite), auth.write, auth.owner, setURLOpenGraph)
+	api.PUT("/urls/:shortCode/cache-ttl", requireScope(scopeLinksWrite), auth.write, auth.owner, setURLCacheTTL)
+	api.PUT("/urls/:shortCode/pixels", requireScope(scopeLinksWrite), auth.write, auth.owner, setURLPixels)
+	api.POST("/urls/:shortCode/conversions", requireScope(scopeLinksWrite), recordConversion)
+	api.POST("/conversions", requireScope(scopeLinksWrite), recordClickConversion)
+	api.POST("/urls/:shortCode/metadata/refresh", requireScope(scopeLinksWrite), auth.write, auth.owner, refreshPageMetadata)
+	api.GET("/urls/:shortCode/history", requireScope(scopeLinksRead), auth.owner, getURLHistory)
+	api.POST("/urls/:shortCode/rollback/:versionId", requireScope(scopeLinksWrite), auth.write, auth.owner, rollbackURL)
+	api.POST("/urls/:shortCode/unarchive", requireScope(scopeLinksWrite), auth.write, auth.owner, unarchiveShortURL)
+	api.POST("/urls/:shortCode/transfer", requireScope(scopeLinksWrite), auth.write, auth.owner, transferURL)
+	api.POST("/urls/:shortCode/signed-urls", requireScope(scopeLinksWrite), auth.write, auth.owner, createSignedURL)
+
+	api.GET("/tags", requireScope(scopeLinksRead), getTags)
+	api.POST("/tags/bulk", requireScope(scopeLinksWrite), auth.write, bulkTagLinks)
+	api.POST("/tags/:tag/rename", requireScope(scopeLinksWrite), auth.write, renameTag)
+
+	api.POST("/urls/:shortCode/lock", requireScope(scopeAdmin), auth.admin, lockShortURL)
+	api.POST("/urls/:shortCode/unlock", requireScope(scopeAdmin), auth.admin, unlockShortURL)
+	api.POST("/urls/:shortCode/enable", requireScope(scopeAdmin), auth.admin, enableShortURL)
+
+	api.GET("/campaigns", requireScope(scopeLinksRead), getCampaigns)
+	api.POST("/campaigns", requireScope(scopeLinksWrite), auth.write, createCampaign)
+	api.DELETE("/campaigns/:id", requireScope(scopeLinksWrite), auth.write, deleteCampaign)
+	api.GET("/campaigns/:id/urls", requireScope(scopeLinksRead), getCampaignURLs)
+	api.POST("/campaigns/:id/urls", requireScope(scopeLinksWrite), auth.write, attachCampaignURLs)
+	api.DELETE("/campaigns/:id/urls/:shortCode", requireScope(scopeLinksWrite), auth.write, detachCampaignURL)
+	api.GET("/campaigns/:id/stats", requireScope(scopeStatsRead), getCampaignStats)
+	api.POST("/campaigns/:id/expire", requireScope(scopeLinksWrite), auth.write, expireCampaign)
+	api.POST("/campaigns/:id/transfer", requireScope(scopeLinksWrite), auth.write, transferCampaign)
+	api.PUT("/campaigns/:id/pixels", requireScope(scopeLinksWrite), auth.write, setCampaignPixels)
+
+	api.GET("/transfers", requireScope(scopeAdmin), getTransfers)
+	api.POST("/transfers/:id/accept", requireScope(scopeAdmin), auth.write, acceptTransfer)
+	api.POST("/transfers/:id/decline", requireScope(scopeAdmin), declineTransfer)
+
+	api.DELETE("/users/:id/data", requireScope(scopeAdmin), eraseUserData)
+
+	api.GET("/graphql", requireScope(scopeLinksRead), postGraphQL)
+	api.POST("/graphql", requireScope(scopeLinksRead), postGraphQL)
+
+	api.GET("/orgs", requireScope(scopeAdmin), getOrganizations)
+	api.POST("/orgs", requireScope(scopeAdmin), createOrganization)
+	api.GET("/orgs/:orgId/members", requireScope(scopeAdmin), getOrgMembers)
+	api.PUT("/orgs/:orgId/members/:userId", requireScope(scopeAdmin), setOrgMember)
+	api.DELETE("/orgs/:orgId/members/:userId", requireScope(scopeAdmin), removeOrgMember)
+	api.GET("/orgs/:orgId/inactivity-policy", requireScope(scopeAdmin), getOrgInactivityPolicy)
+	api.PUT("/orgs/:orgId/inactivity-policy", requireScope(scopeAdmin), setOrgInactivityPolicy)
+	api.GET("/orgs/:orgId/sso", requireScope(scopeAdmin), getOrgSSO)
+	api.PUT("/orgs/:orgId/sso", requireScope(scopeAdmin), setOrgSSO)
+	api.DELETE("/orgs/:orgId/sso", requireScope(scopeAdmin), deleteOrgSSO)
+	api.POST("/orgs/:orgId/sso/scim-token", requireScope(scopeAdmin), createSCIMToken)
+	api.PUT("/orgs/:orgId/two-factor", requireScope(scopeAdmin), setOrgTwoFactorPolicy)
+	api.GET("/orgs/:orgId/pixels", requireScope(scopeAdmin), getOrgPixels)
+	api.POST("/orgs/:orgId/pixels", requireScope(scopeAdmin), createOrgPixel)
+	api.DELETE("/orgs/:orgId/pixels/:pixelId", requireScope(scopeAdmin), deleteOrgPixel)
+	api.GET("/orgs/:orgId/reserved-prefixes", requireScope(scopeAdmin), getOrgReservedPrefixes)
+	api.POST("/orgs/:orgId/reserved-prefixes", requireScope(scopeAdmin), createOrgReservedPrefix)
+	api.DELETE("/orgs/:orgId/reserved-prefixes/:prefixId", requireScope(scopeAdmin), deleteOrgReservedPrefix)
+	api.GET("/orgs/:orgId/warehouse-export", requireScope(scopeAdmin), getOrgWarehouseExport)
+	api.PUT("/orgs/:orgId/warehouse-export", requireScope(scopeAdmin), setOrgWarehouseExport)
+	api.DELETE("/orgs/:orgId/warehouse-export", requireScope(scopeAdmin), deleteOrgWarehouseExport)
+
+	api.GET("/account/usage", requireScope(scopeStatsRead), getAccountUsage)
+	api.GET("/plans", requireScope(scopeLinksRead), getPlans)
+	api.POST("/billing/checkout", requireScope(scopeAdmin), createCheckoutSession)
+	api.GET("/billing/subscription", requireScope(scopeAdmin), getSubscription)
+
+	api.GET("/admin/metrics", requireScope(scopeAdmin), auth.admin, gin.WrapH(expvar.Handler()))
+	api.POST("/admin/config/reload", requireScope(scopeAdmin), auth.admin, reloadConfigHandler)
+	api.GET("/admin/feature-flags", requireScope(scopeAdmin), auth.admin, getFeatureFlags)
+	api.PUT("/admin/feature-flags/:name", requireScope(scopeAdmin), auth.admin, setFeatureFlag)
+	api.DELETE("/admin/feature-flags/:name", requireScope(scopeAdmin), auth.admin, deleteFeatureFlag)
+	api.GET("/admin/tenants", requireScope(scopeAdmin), auth.admin, getTenants)
+	api.POST("/admin/tenants", requireScope(scopeAdmin), auth.admin, createTenant)
+	api.PUT("/admin/tenants/:id", requireScope(scopeAdmin), auth.admin, updateTenant)
+	api.POST("/admin/tenants/:id/api-keys", requireScope(scopeAdmin), auth.admin, createAPIKey)
+	api.GET("/admin/api-keys", requireScope(scopeAdmin), auth.admin, getAPIKeys)
+	api.DELETE("/admin/api-keys/:id", requireScope(scopeAdmin), auth.admin, deleteAPIKey)
+	api.PUT("/admin/users/:id/plan", requireScope(scopeAdmin), auth.admin, setUserPlan)
+	api.PUT("/admin/orgs/:orgId/plan", requireScope(scopeAdmin), auth.admin, setOrgPlan)
+	api.PUT("/admin/plans/:name", requireScope(scopeAdmin), auth.admin, setBillingPlan)
+	api.DELETE("/admin/plans/:name", requireScope(scopeAdmin), auth.admin, deleteBillingPlan)
+	api.GET("/admin/audit", requireScope(scopeAdmin), auth.admin, getAuditLog)
+	api.GET("/admin/imports", requireScope(scopeAdmin), auth.admin, getClickImports)
+	api.POST("/admin/imports/clicks", requireScope(scopeAdmin), auth.admin, importClicks)
+	api.GET("/admin/anomalies", requireScope(scopeAdmin), auth.admin, getAnomalousLinks)
+	api.GET("/admin/ip-rules", requireScope(scopeAdmin), auth.admin, getIPRules)
+	api.POST("/admin/ip-rules", requireScope(scopeAdmin), auth.admin, addIPRule)
+	api.DELETE("/admin/ip-rules/:id", requireScope(scopeAdmin), auth.admin, deleteIPRule)
+	api.GET("/admin/blocklist", requireScope(scopeAdmin), auth.admin, getBlockedDestinations)
+	api.POST("/admin/blocklist", requireScope(scopeAdmin), auth.admin, addBlockedDestination)
+	api.DELETE("/admin/blocklist/:id", requireScope(scopeAdmin), auth.admin, deleteBlockedDestination)
+	api.POST("/admin/blocklist/scan", requireScope(scopeAdmin), auth.admin, scanBlockedDestinations)
+}
+
+// requestPriority classifies routes for admission control under overload
+func requestPriority(c *gin.Context) middleware.Priority {
+	path := c.FullPath()
+	switch {
+	case strings.HasSuffix(path, "/urls") || strings.HasSuffix(path, "/stats") || strings.HasSuffix(path, "/timeseries"):
+		if c.Request.Method == http.MethodGet {
+			return middleware.PriorityLow
+		}
+		return middleware.PriorityNormal
+	case strings.HasSuffix(path, "/:shortCode") && c.Request.Method == http.MethodGet:
+		return middleware.PriorityCritical
+	default:
+		return middleware.PriorityNormal
+	}
+}
+
 func main() {
 	var err error
 
-	if err = godotenv.Load(); err != nil {
+	if err = loadEnvFile(); err != nil {
 		log.Println("Warning: .env file not found, using environment variables")
 	}
 
@@ -183,41 +912,400 @@ func main() {
 		port = "8080"
 	}
 
-	database, err = db.InitDB()
+	cfg := config.GetDefaultConfig()
+	closeAll := initialize(cfg)
+	defer closeAll()
+
+	tlsConfig, redirectHandler, err := configureTLS(cfg, port)
+	if err != nil {
+		log.Fatalf("Failed to configure TLS: %v", err)
+	}
+
+	r := newRouter(cfg, tlsConfig != nil)
+
+	// Request contexts derive from baseCtx so in-flight queries can be aborted
+	// if the server fails to drain within the shutdown timeout
+	baseCtx, cancelRequests := context.WithCancel(context.Background())
+	defer cancelRequests()
+
+	srv := &http.Server{
+		Addr:        ":" + port,
+		Handler:     r,
+		BaseContext: func(net.Listener) context.Context { return baseCtx },
+		TLSConfig:   tlsConfig,
+	}
+
+	go func() {
+		var err error
+		if tlsConfig != nil {
+			err = srv.ListenAndServeTLS("", "")
+		} else {
+			err = srv.ListenAndServe()
+		}
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatalf("Server failed: %v", err)
+		}
+	}()
+
+	// With TLS served here, plain HTTP is only redirected (and answers ACME challenges)
+	var redirectSrv *http.Server
+	if tlsConfig != nil && cfg.TLS.RedirectPort != "off" {
+		redirectSrv = &http.Server{Addr: ":" + cfg.TLS.RedirectPort, Handler: redirectHandler, ReadHeaderTimeout: 10 * time.Second}
+		go func() {
+			if err := redirectSrv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				log.Fatalf("HTTP redirect listener failed: %v", err)
+			}
+		}()
+		log.Println("Redirecting HTTP on port", cfg.TLS.RedirectPort, "to HTTPS")
+	}
+
+	debugSrv := startDebugServer(cfg)
+
+	log.Println("Server is running on port", port)
+	log.Println("Swagger documentation available at: http://localhost:" + port + "/swagger/index.html")
+
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	<-quit
+
+	log.Println("Shutting down server...")
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
+	defer cancel()
+
+	if redirectSrv != nil {
+		redirectSrv.Close()
+	}
+	if debugSrv != nil {
+		debugSrv.Close()
+	}
+	if err := srv.Shutdown(ctx); err != nil {
+		log.Printf("Server did not drain in time, cancelling in-flight requests: %v", err)
+		cancelRequests()
+	}
+	if err := backgroundJobs.Stop(ctx); err != nil {
+		log.Printf("Background jobs did not finish in time, cancelling them: %v", err)
+	}
+	if err := flushAPIUsage(ctx); err != nil {
+		log.Printf("Failed to record API usage: %v", err)
+	}
+}
+
+// initialize connects to the database and the services configured in cfg,
+// loads what the handlers need and schedules the background jobs. The
+// returned function closes the connections again.
+func initialize(cfg *config.Config) func() {
+	var err error
+
+	publicBaseURL = cfg.Server.BaseURL
+	adminToken = cfg.Admin.Token
+	if cfg.Security.SignedURLSecret != "" {
+		signingSecret = []byte(cfg.Security.SignedURLSecret)
+	}
+	linkBasePath = cfg.Server.BasePath
+	maxURLLength = cfg.Server.MaxURLLength
+
+	database, err = db.InitDB(cfg)
 	if err != nil {
 		log.Fatalf("Failed to initialize database: %v", err)
 	}
-	defer database.Close()
+	closers := []func(){func() { database.Close() }}
 
 	log.Println("Successfully connected to PostgreSQL database")
 
-	cfg := config.GetDefaultConfig()
+	database.SetReservedCodes(reservedCodes)
+
+	objectStore, err = objectstore.FromConfig(cfg)
+	if err != nil {
+		log.Fatalf("Failed to configure object storage: %v", err)
+	}
+
+	countryHeader = cfg.Events.CountryHeader
+	if len(cfg.Events.KafkaBrokers) > 0 {
+		eventPublisher = events.NewKafkaPublisher(cfg.Events.KafkaBrokers, cfg.Events.KafkaTopic)
+		closers = append(closers, func() { eventPublisher.Close() })
+
+		// Link lifecycle events are written in the same statement as the
+		// change and delivered from the outbox, so none is lost on a crash
+		outboxPublisher = events.NewSyncKafkaPublisher(cfg.Events.KafkaBrokers, cfg.Events.LinkTopic)
+		closers = append(closers, func() { outboxPublisher.Close() })
+		database.EnableOutbox()
+	}
+	if cfg.Database.PreparedStatements {
+		if err := database.PrepareStatements(context.Background()); err != nil {
+			log.Fatalf("Failed to prepare statements: %v", err)
+		}
+	}
+
+	if err := configureMailer(cfg); err != nil {
+		log.Fatalf("Failed to configure mailer: %v", err)
+	}
+	if emailer != nil {
+		closers = append(closers, emailer.Close)
+	}
+	if err := configureBilling(cfg); err != nil {
+		log.Fatalf("Failed to configure billing: %v", err)
+	}
+
+	// Scheduled maintenance and work handed off by handlers share one worker
+	// pool, drained on shutdown
+	backgroundJobs = jobs.New(cfg.Jobs.Workers, cfg.Jobs.QueueSize)
+	expvar.Publish("jobs", expvar.Func(func() any { return backgroundJobs.Stats() }))
+	expvar.Publish("stale_redirects", expvar.Func(func() any { return database.StaleLinksServed() }))
+	publishRuntimeMetrics()
+	backgroundJobs.Schedule(jobs.Job{Name: "probe-database", Every: db.ProbeInterval, Run: database.Probe})
+
+	if err := loadIPRules(context.Background()); err != nil {
+		log.Printf("Warning: failed to load IP rules: %v", err)
+	}
+	if err := loadBlocklist(context.Background()); err != nil {
+		log.Printf("Warning: failed to load destination blocklist: %v", err)
+	}
+	if err := loadTenants(context.Background()); err != nil {
+		log.Printf("Warning: failed to load tenants: %v", err)
+	}
+	if err := loadBillingPlans(context.Background()); err != nil {
+		log.Printf("Warning: failed to load billing plans: %v", err)
+	}
+	deploymentEnvironment = cfg.Features.Environment
+	if err := loadFeatureFlags(context.Background()); err != nil {
+		log.Printf("Warning: failed to load feature flags: %v", err)
+	}
+	if err := configureClickPartitions(cfg); err != nil {
+		log.Fatalf("Failed to partition clicks: %v", err)
+	}
+	if err := configurePrivacy(cfg); err != nil {
+		log.Fatalf("Failed to configure privacy controls: %v", err)
+	}
+	if err := configureCodeFilter(context.Background(), cfg); err != nil {
+		log.Fatalf("Failed to load short code filter: %v", err)
+	}
+	backgroundJobs.Schedule(jobs.Job{Name: "aggregate-clicks", Every: cfg.Stats.AggregateInterval, RunAtStart: true, Run: aggregateClicks})
+	if !validInactivityAction(cfg.Inactivity.Action) {
+		log.Fatalf("INACTIVE_LINK_ACTION must be archive or disable, not %q", cfg.Inactivity.Action)
+	}
+	defaultInactivityPolicy = db.InactivityPolicy{Months: cfg.Inactivity.Months, Action: cfg.Inactivity.Action}
+	appLinkFallbackDelay = cfg.AppLinks.FallbackDelay
+	pixelRedirectDelay = cfg.Pixels.RedirectDelay
+	clickIDParam = cfg.ClickIDs.Param
+	redirectCacheTTL, redirectBrowserTTL = cfg.EdgeCache.TTL, cfg.EdgeCache.BrowserTTL
+	if cfg.EdgeCache.Provider != "" {
+		edgePurger, err = cdn.New(cfg.EdgeCache.Provider, cfg.EdgeCache.Zone, cfg.EdgeCache.APIToken, cfg.EdgeCache.PurgeTimeout)
+		if err != nil {
+			log.Fatalf("Failed to configure CDN purging: %v", err)
+		}
+		database.OnChange(purgeEdge)
+	}
+	backgroundJobs.Schedule(jobs.Job{Name: "inactivity-policies", Every: inactivityInterval, RunAtStart: true, Run: applyInactivityPolicies})
+	if cfg.HealthCheck.Interval > 0 {
+		if cfg.HealthCheck.Notify && emailer == nil {
+			log.Println("Warning: HEALTH_CHECK_NOTIFY is set but no SMTP_HOST is configured; broken links will not be emailed")
+		}
+		backgroundJobs.Schedule(jobs.Job{Name: "link-health", Every: healthCheckPoll, RunAtStart: true, Run: monitorLinkHealth(cfg)})
+	}
+	if cfg.Backup.Interval > 0 {
+		if objectStore == nil {
+			log.Println("Warning: BACKUP_INTERVAL is set but no OBJECT_STORE is configured; backups are disabled")
+		} else {
+			backgroundJobs.Schedule(jobs.Job{Name: "backup", Every: cfg.Backup.Interval, Run: runBackup})
+		}
+	}
+	if cfg.Warehouse.ExportInterval > 0 {
+		warehouseExportsEnabled = true
+		backgroundJobs.Schedule(jobs.Job{Name: "warehouse-export", Every: cfg.Warehouse.ExportInterval, RunAtStart: true, Run: exportClicks})
+	}
+	// Rules changed through another instance take effect here too
+	backgroundJobs.Schedule(jobs.Job{Name: "refresh-ip-rules", Every: cfg.Security.IPRulesRefresh, Run: loadIPRules})
+	backgroundJobs.Schedule(jobs.Job{Name: "refresh-blocklist", Every: cfg.Security.IPRulesRefresh, Run: loadBlocklist})
+	backgroundJobs.Schedule(jobs.Job{Name: "refresh-feature-flags", Every: cfg.Security.IPRulesRefresh, Run: loadFeatureFlags})
+	backgroundJobs.Schedule(jobs.Job{Name: "refresh-tenants", Every: cfg.Security.IPRulesRefresh, Run: loadTenants})
+	backgroundJobs.Schedule(jobs.Job{Name: "refresh-billing-plans", Every: cfg.Security.IPRulesRefresh, Run: loadBillingPlans})
+	backgroundJobs.Schedule(jobs.Job{Name: "limiter-cleanup", Every: limiterCleanupInterval, Run: cleanupLimiters})
+	backgroundJobs.Schedule(jobs.Job{Name: "flush-api-usage", Every: apiUsageFlushInterval, Run: flushAPIUsage})
+	if outboxPublisher != nil {
+		backgroundJobs.Schedule(jobs.Job{Name: "outbox-dispatch", Every: cfg.Events.OutboxInterval, RunAtStart: true, Run: dispatchOutbox})
+	}
+
+	return func() {
+		for i := len(closers) - 1; i >= 0; i-- {
+			closers[i]()
+		}
+	}
+}
+
+// newRouter builds the handler serving the API, the short links and the
+// pages, with the middleware cfg asks for. HSTS is only sent when the
+// server terminates TLS itself.
+func newRouter(cfg *config.Config, tlsEnabled bool) *gin.Engine {
+	r := gin.New()
+	r.Use(middleware.RequestID, gin.Logger(), middleware.Recovery)
+	if tlsEnabled && cfg.TLS.HSTSMaxAge > 0 {
+		r.Use(middleware.HSTS(cfg.TLS.HSTSMaxAge))
+	}
 
-	r := gin.Default()
+	// Forwarded client IPs are only honored when sent by a configured proxy, so
+	// clients cannot pick their own rate limit bucket with a spoofed header
+	if err := r.SetTrustedProxies(cfg.Server.TrustedProxies); err != nil {
+		log.Fatalf("Invalid TRUSTED_PROXIES: %v", err)
+	}
+	r.RemoteIPHeaders = cfg.Server.ClientIPHeaders
+
+	r.Use(ipFilter.Block)
+	// Every later handler and query is scoped to the tenant of the request
+	r.Use(resolveTenant)
+	r.Use(middleware.MaxBodySize(int64(cfg.Server.MaxBodyBytes)))
+	if cfg.Server.RequestTimeout > 0 {
+		// Stats streams stay open for as long as the client watches
+		r.Use(middleware.Timeout(cfg.Server.RequestTimeout, func(c *gin.Context) bool {
+			return strings.HasSuffix(c.FullPath(), "/stream")
+		}))
+	}
+
+	var adaptive *middleware.AdaptiveController
+	if cfg.RateLimit.Adaptive.Enabled {
+		ac := cfg.RateLimit.Adaptive
+		adaptive = middleware.NewAdaptiveController(database, ac.LatencyThreshold, ac.ErrorThreshold, ac.MinFactor)
+		backgroundJobs.Schedule(jobs.Job{Name: "adaptive-rate-limit", Every: ac.Interval, Run: adaptive.Evaluate})
+	}
 
 	if cfg.RateLimit.Enabled {
-		rateLimiter := middleware.NewRateLimitMiddleware(cfg.RateLimit.RequestsPerMinute)
+		l, err := limiter.New(cfg.RateLimit.Algorithm, cfg.RateLimit.RequestsPerMinute, time.Minute)
+		if err != nil {
+			log.Fatalf("Invalid rate limit configuration: %v", err)
+		}
+		rateLimiter = middleware.NewRateLimitMiddleware(l)
+		rateLimiter.SetKey(tenantRateKey)
+		if adaptive != nil {
+			rateLimiter.SetAdaptive(adaptive)
+		}
 		r.Use(rateLimiter.Limit)
 	}
 
-	r.Use(cors.Default())
+	if adaptive != nil {
+		r.Use(middleware.NewPriorityAdmission(adaptive, requestPriority).Admit)
+	}
+	if ls := cfg.LoadShed; ls.Enabled {
+		if ls.MinConcurrency < 1 || ls.MaxConcurrency < ls.MinConcurrency {
+			log.Fatal("Invalid load shedding configuration: need 1 <= LOAD_SHED_MIN_CONCURRENCY <= LOAD_SHED_MAX_CONCURRENCY")
+		}
+		shedder := middleware.NewLoadShedder(requestPriority, ls.TargetLatency, ls.MinConcurrency, ls.MaxConcurrency)
+		expvar.Publish("load_shedding", expvar.Func(func() any { return shedder.Stats() }))
+		r.Use(shedder.Admit)
+	}
+
+	// Rate limits, bot filtering, click fraud detection, page metadata,
+	// archiving, CORS and default feature flags can change without a restart
+	if err := configureReloadable(cfg); err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
+	}
+	if cfg.Reload.WatchInterval > 0 {
+		backgroundJobs.Schedule(jobs.Job{Name: "watch-config", Every: cfg.Reload.WatchInterval, Run: watchConfigFile()})
+	}
+	r.Use(applyCORS)
 
-	r.LoadHTMLGlob("templates/*")
+	pages, err := loadPageTemplates(cfg)
+	if err != nil {
+		log.Fatalf("Failed to load page templates: %v", err)
+	}
+	r.SetHTMLTemplate(pages)
 
 	r.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
 
-	r.GET("/urls", getAllShortURLs)
-	r.POST("/urls", createShortURL)
+	auth := apiAuth{
+		admin:   ipFilter.AdminOnly(requireAdmin),
+		owner:   requireLinkOwner(),
+		write:   func(c *gin.Context) { c.Next() },
+		captcha: func(c *gin.Context) { c.Next() },
+	}
+
+	if cfg.Captcha.Provider != "" {
+		verifier, err := captcha.New(cfg.Captcha.Provider, cfg.Captcha.Secret, cfg.Captcha.Timeout)
+		if err != nil {
+			log.Fatalf("Failed to configure CAPTCHA: %v", err)
+		}
+		auth.captcha = requireCaptcha(verifier, cfg.Captcha.Threshold)
+	}
+
+	// With an identity provider configured, creating and changing links requires a JWT
+	authenticate := func(c *gin.Context) { c.Next() }
+	if cfg.JWT.HMACSecret != "" || cfg.JWT.JWKSURL != "" {
+		jwtAuth, err := middleware.NewJWTAuth(middleware.JWTConfig{
+			HMACSecret: cfg.JWT.HMACSecret,
+			JWKSURL:    cfg.JWT.JWKSURL,
+			Issuer:     cfg.JWT.Issuer,
+			Audience:   cfg.JWT.Audience,
+			RoleClaim:  cfg.JWT.RoleClaim,
+		})
+		if err != nil {
+			log.Fatalf("Failed to configure JWT auth: %v", err)
+		}
+		authenticate = jwtAuth.Authenticate
+		requireWriter := middleware.RequireRole(cfg.JWT.WriteRoles...)
+		auth.write = func(c *gin.Context) {
+			if isAdmin(c) || keyGrants(c, scopeLinksWrite) || signedRequest(c) {
+				c.Next()
+				return
+			}
+			requireWriter(c)
+		}
+	}
+
+	configureOAuth(cfg)
+
+	authGroup := r.Group("/auth")
+	authGroup.GET("/login", oauthLogin)
+	authGroup.GET("/callback", oauthCallback)
+	authGroup.POST("/logout", oauthLogout)
+	authGroup.GET("/me", sessionAuth, currentUser)
+	authGroup.GET("/2fa", sessionAuth, getTwoFactor)
+	authGroup.POST("/2fa/setup", sessionAuth, setupTwoFactor)
+	authGroup.POST("/2fa/enable", sessionAuth, enableTwoFactor)
+	authGroup.POST("/2fa/disable", sessionAuth, disableTwoFactor)
+	authGroup.POST("/2fa/recovery-codes", sessionAuth, regenerateRecoveryCodes)
+	authGroup.POST("/2fa/verify", verifyTwoFactor)
+	authGroup.GET("/saml/:orgId/login", samlLogin)
+	authGroup.POST("/saml/:orgId/acs", samlACS)
+	authGroup.GET("/saml/:orgId/metadata", samlMetadata)
+
+	// Identity providers provision organization members with their SCIM token
+	scim := r.Group("/scim/v2", scimAuth)
+	scim.GET("/ServiceProviderConfig", getSCIMServiceProviderConfig)
+	scim.GET("/Users", getSCIMUsers)
+	scim.POST("/Users", createSCIMUser)
+	scim.GET("/Users/:id", getSCIMUser)
+	scim.PUT("/Users/:id", replaceSCIMUser)
+	scim.PATCH("/Users/:id", patchSCIMUser)
+	scim.DELETE("/Users/:id", deleteSCIMUser)
+
+	// Stripe signs its webhooks, so they need no other authentication
+	r.POST("/billing/webhook", stripeWebhook)
+
+	v1 := r.Group("/api/v1", middleware.APIVersion("1"), authenticate, sessionAuth, meterAPICalls, linkDomain)
+	registerAPIRoutes(v1, auth)
+
+	// The unversioned API stays available for existing integrations but is deprecated
+	legacy := r.Group("/", middleware.Deprecated("/api/v1", cfg.Server.LegacySunset), authenticate, sessionAuth, meterAPICalls, linkDomain)
+	registerAPIRoutes(legacy, auth)
+
 	r.GET("/urls/:shortCode", getOriginalURL)
-	r.PUT("/urls/:shortCode", updateShortURL)
-	r.DELETE("/urls/:shortCode", deleteShortURL)
-	r.GET("/urls/:shortCode/stats", getURLStats)
+	r.HEAD("/urls/:shortCode", headOriginalURL)
+	r.OPTIONS("/urls/:shortCode", optionsShortURL("GET, HEAD, PUT, PATCH, DELETE, OPTIONS"))
 
-	r.GET("/", func(c *gin.Context) {
-		c.JSON(http.StatusOK, gin.H{"message": "URL Shortener API", "docs": "/swagger/index.html"})
-	})
+	r.GET("/.well-known/events-schema", getEventsSchema)
+	r.GET("/healthz", healthCheck)
 
-	log.Println("Server is running on port", port)
-	log.Println("Swagger documentation available at: http://localhost:" + port + "/swagger/index.html")
-	r.Run(":" + port)
+	static := http.FS(staticFiles(cfg.Pages.StaticDir))
+	r.StaticFS("/static", static)
+	r.GET("/robots.txt", func(c *gin.Context) { c.FileFromFS("robots.txt", static) })
+
+	// Short links are also served directly under BASE_PATH (the root by default)
+	links := r.Group(cfg.Server.BasePath)
+	links.GET("/:shortCode", rootShortCode(getOriginalURL))
+	links.HEAD("/:shortCode", rootShortCode(headOriginalURL))
+	links.OPTIONS("/:shortCode", optionsShortURL("GET, HEAD, OPTIONS"))
+	links.GET("/:shortCode/*path", rootShortCode(linkSubpath))
+	links.HEAD("/:shortCode/*path", rootShortCode(headOriginalURL))
+
+	r.GET("/", homePage)
+
+	return r
 }
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"os"
+	"sync"
+	"testing"
+	"url-shortener/config"
+
+	"github.com/gin-gonic/gin"
+)
+
+// testAdminToken is the ADMIN_TOKEN tests over the database run with
+const testAdminToken = "test-admin-token"
+
+// The router over the database is set up once, for every test and benchmark
+// that needs it, as startup schedules jobs and sets globals
+var (
+	testRouterOnce  sync.Once
+	testRouter      *gin.Engine
+	closeTestRouter func()
+)
+
+func TestMain(m *testing.M) {
+	flag.Parse()
+	code := m.Run()
+	if closeTestRouter != nil {
+		backgroundJobs.Stop(context.Background())
+		closeTestRouter()
+	}
+	os.Exit(code)
+}
+
+// routerWithDatabase returns the full router over the database named by the
+// DATABASE_* variables, skipping tb when DATABASE_HOST is not set
+func routerWithDatabase(tb testing.TB) *gin.Engine {
+	if os.Getenv("DATABASE_HOST") == "" {
+		tb.Skip("DATABASE_HOST is not set")
+	}
+	testRouterOnce.Do(func() {
+		// Page titles are fetched asynchronously and would make responses
+		// vary, and rate limits would throttle benchmarks
+		os.Setenv("PAGE_META_ENABLED", "false")
+		os.Setenv("RATE_LIMIT_ENABLED", "false")
+		os.Setenv("SIGNED_URL_SECRET", "")
+		os.Setenv("ADMIN_TOKEN", testAdminToken)
+
+		gin.SetMode(gin.TestMode)
+		cfg := config.GetDefaultConfig()
+		closeTestRouter = initialize(cfg)
+		testRouter = newRouter(cfg, false)
+	})
+	return testRouter
+}
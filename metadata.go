@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"url-shortener/pkg/apierror"
+	"url-shortener/pkg/pagemeta"
+
+	"github.com/gin-gonic/gin"
+)
+
+// fetchPageMetadata loads the destination page and stores its title and
+// description. It runs in the background after a link is created.
+func fetchPageMetadata(ctx context.Context, fetcher *pagemeta.Fetcher, shortCode, originalURL string) error {
+	meta, err := fetcher.Fetch(ctx, originalURL)
+	if err != nil {
+		return fmt.Errorf("fetching page metadata for %s: %w", shortCode, err)
+	}
+
+	if err := database.UpdatePageMetadata(ctx, shortCode, meta.Title, meta.Description); err != nil {
+		return fmt.Errorf("storing page metadata for %s: %w", shortCode, err)
+	}
+	return nil
+}
+
+func refreshPageMetadata(c *gin.Context) {
+	shortCode := c.Param("shortCode")
+
+	fetcher := pageFetcher.Load()
+	if fetcher == nil {
+		respondError(c, apierror.Unavailable("Page metadata fetching is disabled"))
+		return
+	}
+
+	url, err := database.GetURLByShortCode(c.Request.Context(), shortCode)
+	if err != nil {
+		respondError(c, notFound(err, "Short URL not found"))
+		return
+	}
+
+	meta, err := fetcher.Fetch(c.Request.Context(), url.OriginalURL)
+	if errors.Is(err, pagemeta.ErrDisallowed) {
+		respondError(c, apierror.Forbidden("Destination disallows fetching via robots.txt"))
+		return
+	}
+	if err != nil {
+		respondError(c, apierror.Upstream("Failed to fetch destination page").Wrap(err))
+		return
+	}
+
+	if err := database.UpdatePageMetadata(c.Request.Context(), shortCode, meta.Title, meta.Description); err != nil {
+		respondError(c, apierror.Internal("Failed to store page metadata").Wrap(err))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"shortCode": shortCode, "title": meta.Title, "description": meta.Description})
+}
@@ -0,0 +1,69 @@
+package middleware
+
+import (
+	"context"
+	"log"
+	"math"
+	"sync/atomic"
+	"time"
+)
+
+// HealthSource reports the recent latency and error rate (0..1) of a backend
+type HealthSource interface {
+	Health() (time.Duration, float64)
+}
+
+// AdaptiveController scales rate limits down while the backend is unhealthy
+// and lets them recover gradually once it is healthy again
+type AdaptiveController struct {
+	source           HealthSource
+	latencyThreshold time.Duration
+	errorThreshold   float64
+	minFactor        float64
+	recoveryStep     float64
+	factor           atomic.Uint64 // math.Float64bits of the current factor
+}
+
+// NewAdaptiveController creates a controller at full limits. Schedule
+// Evaluate to have it follow source.
+func NewAdaptiveController(source HealthSource, latencyThreshold time.Duration, errorThreshold, minFactor float64) *AdaptiveController {
+	ac := &AdaptiveController{
+		source:           source,
+		latencyThreshold: latencyThreshold,
+		errorThreshold:   errorThreshold,
+		minFactor:        minFactor,
+		recoveryStep:     0.1,
+	}
+	ac.factor.Store(math.Float64bits(1))
+	return ac
+}
+
+// Factor returns the multiplier (minFactor..1) currently applied to rate limits
+func (ac *AdaptiveController) Factor() float64 {
+	return math.Float64frombits(ac.factor.Load())
+}
+
+// Overloaded reports whether limits are currently tightened
+func (ac *AdaptiveController) Overloaded() bool {
+	return ac.Factor() < 1
+}
+
+// Evaluate tightens or relaxes the limits once from the source's current
+// health; it is run as a recurring job
+func (ac *AdaptiveController) Evaluate(context.Context) error {
+	latency, errorRate := ac.source.Health()
+	current := ac.Factor()
+
+	next := current
+	if latency > ac.latencyThreshold || errorRate > ac.errorThreshold {
+		next = math.Max(current/2, ac.minFactor)
+	} else if current < 1 {
+		next = math.Min(current+ac.recoveryStep, 1)
+	}
+
+	if next != current {
+		log.Printf("Adaptive rate limit factor %.2f -> %.2f (db latency %s, error rate %.2f)", current, next, latency, errorRate)
+		ac.factor.Store(math.Float64bits(next))
+	}
+	return nil
+}
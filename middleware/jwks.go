@@ -0,0 +1,133 @@
+package middleware
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// jwksRefreshInterval is how long fetched keys are trusted before refetching,
+// and the minimum gap between refetches triggered by unknown key IDs
+const (
+	jwksRefreshInterval = time.Hour
+	jwksMinRefetch      = time.Minute
+)
+
+// jwks caches the signing keys published at an identity provider's JWKS URL
+type jwks struct {
+	url    string
+	client *http.Client
+
+	mu        sync.Mutex
+	keys      map[string]any
+	fetchedAt time.Time
+}
+
+func newJWKS(url string) *jwks {
+	return &jwks{url: url, client: &http.Client{Timeout: 10 * time.Second}}
+}
+
+// key returns the public key with the given ID, refetching the key set when
+// it is stale or the ID is unknown (for example after a key rotation)
+func (j *jwks) key(kid string) (any, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	key, ok := j.keys[kid]
+	stale := time.Since(j.fetchedAt) > jwksRefreshInterval
+	if ok && !stale {
+		return key, nil
+	}
+
+	if stale || time.Since(j.fetchedAt) > jwksMinRefetch {
+		if err := j.fetch(); err != nil && !ok {
+			return nil, err
+		}
+	}
+
+	if key, ok := j.keys[kid]; ok {
+		return key, nil
+	}
+	return nil, fmt.Errorf("unknown signing key %q", kid)
+}
+
+func (j *jwks) fetch() error {
+	resp, err := j.client.Get(j.url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("fetching JWKS: %s", resp.Status)
+	}
+
+	var set struct {
+		Keys []struct {
+			Kid string `json:"kid"`
+			Kty string `json:"kty"`
+			Use string `json:"use"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+			Crv string `json:"crv"`
+			X   string `json:"x"`
+			Y   string `json:"y"`
+		} `json:"keys"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&set); err != nil {
+		return fmt.Errorf("decoding JWKS: %w", err)
+	}
+
+	keys := make(map[string]any, len(set.Keys))
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		switch k.Kty {
+		case "RSA":
+			n, errN := decodeBigInt(k.N)
+			e, errE := decodeBigInt(k.E)
+			if errN != nil || errE != nil {
+				continue
+			}
+			keys[k.Kid] = &rsa.PublicKey{N: n, E: int(e.Int64())}
+		case "EC":
+			var curve elliptic.Curve
+			switch k.Crv {
+			case "P-256":
+				curve = elliptic.P256()
+			case "P-384":
+				curve = elliptic.P384()
+			case "P-521":
+				curve = elliptic.P521()
+			default:
+				continue
+			}
+			x, errX := decodeBigInt(k.X)
+			y, errY := decodeBigInt(k.Y)
+			if errX != nil || errY != nil {
+				continue
+			}
+			keys[k.Kid] = &ecdsa.PublicKey{Curve: curve, X: x, Y: y}
+		}
+	}
+
+	j.keys = keys
+	j.fetchedAt = time.Now()
+	return nil
+}
+
+func decodeBigInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(b), nil
+}
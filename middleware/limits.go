@@ -0,0 +1,70 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+	"url-shortener/pkg/apierror"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Timeout bounds how long a request may run by giving it a context with a
+// deadline, which cancels the database queries it is waiting on. A request
+// that runs out of time before writing a response is answered with 503.
+// Requests for which exempt reports true, such as event streams, are left alone.
+func Timeout(timeout time.Duration, exempt func(*gin.Context) bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if exempt != nil && exempt(c) {
+			c.Next()
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c.Request.Context(), timeout)
+		defer cancel()
+		c.Request = c.Request.WithContext(ctx)
+
+		c.Next()
+
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) && !c.Writer.Written() {
+			apierror.Abort(c, apierror.Unavailable("Request timed out"))
+		}
+	}
+}
+
+// MaxBodySize caps request bodies of POST, PUT and PATCH requests at limit
+// bytes. Oversized bodies that declare their length are rejected with 413 up
+// front; any other body stops being read at the limit, failing the handler's
+// decoding.
+func MaxBodySize(limit int64) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		switch c.Request.Method {
+		case http.MethodPost, http.MethodPut, http.MethodPatch:
+		default:
+			c.Next()
+			return
+		}
+
+		if c.Request.ContentLength > limit {
+			apierror.Abort(c, apierror.TooLarge("Request body too large"))
+			return
+		}
+
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, limit)
+		c.Next()
+	}
+}
+
+// HSTS tells browsers to only reach the service over HTTPS for maxAge, on
+// responses to requests that arrived over TLS
+func HSTS(maxAge time.Duration) gin.HandlerFunc {
+	value := "max-age=" + strconv.Itoa(int(maxAge.Seconds()))
+	return func(c *gin.Context) {
+		if c.Request.TLS != nil {
+			c.Header("Strict-Transport-Security", value)
+		}
+		c.Next()
+	}
+}
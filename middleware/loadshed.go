@@ -0,0 +1,131 @@
+package middleware
+
+import (
+	"math"
+	"strconv"
+	"sync"
+	"time"
+	"url-shortener/pkg/apierror"
+
+	"github.com/gin-gonic/gin"
+)
+
+// shedWindow is how often the concurrency limit is re-evaluated
+const shedWindow = time.Second
+
+// concurrencyShares are the fractions of the concurrency limit each priority
+// may fill. Redirects are never shed: the room left above the others' shares
+// is kept for them.
+var concurrencyShares = map[Priority]float64{
+	PriorityLow:    0.5,
+	PriorityNormal: 0.8,
+}
+
+// LoadShedder bounds the requests in flight with a limit that adapts to
+// redirect latency. While the database is slow requests pile up, so the limit
+// shrinks and low priority requests are rejected with 503 right away instead
+// of everything queueing until it times out together.
+type LoadShedder struct {
+	classify func(*gin.Context) Priority
+	target   time.Duration
+	minLimit float64
+	maxLimit float64
+
+	mu          sync.Mutex
+	limit       float64
+	inFlight    int
+	windowStart time.Time
+	latencySum  time.Duration
+	samples     int
+	shed        map[Priority]int64
+}
+
+// LoadShedStats is a snapshot of a LoadShedder, for the metrics endpoint
+type LoadShedStats struct {
+	Limit    int              `json:"limit"`
+	InFlight int              `json:"inFlight"`
+	Shed     map[string]int64 `json:"shed"`
+}
+
+// NewLoadShedder creates a shedder whose limit moves between minLimit and
+// maxLimit, shrinking while redirects take longer than target on average
+func NewLoadShedder(classify func(*gin.Context) Priority, target time.Duration, minLimit, maxLimit int) *LoadShedder {
+	return &LoadShedder{
+		classify:    classify,
+		target:      target,
+		minLimit:    float64(minLimit),
+		maxLimit:    float64(maxLimit),
+		limit:       float64(maxLimit),
+		windowStart: time.Now(),
+		shed:        map[Priority]int64{},
+	}
+}
+
+// Admit is the middleware function that rejects requests over their
+// priority's share of the limit with 503
+func (ls *LoadShedder) Admit(c *gin.Context) {
+	priority := ls.classify(c)
+
+	ls.mu.Lock()
+	if share, ok := concurrencyShares[priority]; ok && float64(ls.inFlight) >= ls.limit*share {
+		ls.shed[priority]++
+		ls.mu.Unlock()
+		c.Header("Retry-After", strconv.Itoa(5))
+		apierror.Abort(c, apierror.Unavailable("Service is under heavy load. Try again later."))
+		return
+	}
+	ls.inFlight++
+	ls.mu.Unlock()
+
+	start := time.Now()
+	defer func() {
+		ls.release(priority, time.Since(start))
+	}()
+	c.Next()
+}
+
+// release ends a request, sampling redirect latency: redirects are uniform
+// enough that their latency tracks how loaded the database is
+func (ls *LoadShedder) release(priority Priority, latency time.Duration) {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+
+	ls.inFlight--
+	if priority == PriorityCritical {
+		ls.latencySum += latency
+		ls.samples++
+	}
+	if time.Since(ls.windowStart) >= shedWindow {
+		ls.adjust()
+	}
+}
+
+// adjust cuts the limit by a tenth after a window whose redirects averaged
+// over the target and otherwise grows it back towards the maximum, by the
+// square root of the limit so that recovery speeds up as it goes
+func (ls *LoadShedder) adjust() {
+	if ls.samples > 0 && ls.latencySum/time.Duration(ls.samples) > ls.target {
+		ls.limit = math.Max(ls.limit*0.9, ls.minLimit)
+	} else {
+		ls.limit = math.Min(ls.limit+math.Sqrt(ls.limit), ls.maxLimit)
+	}
+	ls.windowStart = time.Now()
+	ls.latencySum = 0
+	ls.samples = 0
+}
+
+// Stats returns the current limit, the requests in flight and how many
+// requests of each priority have been shed
+func (ls *LoadShedder) Stats() LoadShedStats {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+
+	return LoadShedStats{
+		Limit:    int(ls.limit),
+		InFlight: ls.inFlight,
+		Shed: map[string]int64{
+			"low":    ls.shed[PriorityLow],
+			"normal": ls.shed[PriorityNormal],
+		},
+	}
+}
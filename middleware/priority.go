@@ -0,0 +1,57 @@
+package middleware
+
+import (
+	"math/rand/v2"
+	"strconv"
+	"url-shortener/pkg/apierror"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Priority ranks how important a request is to keep serving under overload
+type Priority int
+
+const (
+	// PriorityLow covers analytics, listings and exports
+	PriorityLow Priority = iota
+	// PriorityNormal covers link management (create, update, delete)
+	PriorityNormal
+	// PriorityCritical covers redirects, the core product
+	PriorityCritical
+)
+
+// priorityWeights scale the adaptive factor into an admission probability, so
+// at factor 0.5 low priority traffic is halved while redirects are untouched
+var priorityWeights = map[Priority]float64{
+	PriorityLow:      1,
+	PriorityNormal:   2,
+	PriorityCritical: 10,
+}
+
+// PriorityAdmission sheds lower priority requests first while the adaptive controller reports overload
+type PriorityAdmission struct {
+	controller *AdaptiveController
+	classify   func(*gin.Context) Priority
+}
+
+// NewPriorityAdmission creates admission control classifying requests with classify
+func NewPriorityAdmission(controller *AdaptiveController, classify func(*gin.Context) Priority) *PriorityAdmission {
+	return &PriorityAdmission{controller: controller, classify: classify}
+}
+
+// Admit is the middleware function that rejects shed requests with 503
+func (pa *PriorityAdmission) Admit(c *gin.Context) {
+	if !pa.controller.Overloaded() {
+		c.Next()
+		return
+	}
+
+	probability := pa.controller.Factor() * priorityWeights[pa.classify(c)]
+	if probability >= 1 || rand.Float64() < probability {
+		c.Next()
+		return
+	}
+
+	c.Header("Retry-After", strconv.Itoa(5))
+	apierror.Abort(c, apierror.Unavailable("Service is under heavy load. Try again later."))
+}
@@ -1,58 +1,92 @@
 package middleware
 
 import (
-	"net/http"
-	"sync"
+	"math"
+	"strconv"
+	"sync/atomic"
 	"time"
+	"url-shortener/pkg/apierror"
+	"url-shortener/pkg/limiter"
 
 	"github.com/gin-gonic/gin"
 )
 
-// RateLimiter implements a simple rate limiting middleware
+// RateLimiter limits requests per client IP using any limiter algorithm
 type RateLimiter struct {
-	requestsPerMinute int
-	clients           map[string][]time.Time
-	mu                sync.Mutex
+	limiter  limiter.Limiter
+	baseline atomic.Int64
+	adaptive *AdaptiveController
+	key      func(*gin.Context) string
 }
 
-// NewRateLimitMiddleware creates a new rate limiter middleware
-func NewRateLimitMiddleware(requestsPerMinute int) *RateLimiter {
-	return &RateLimiter{
-		requestsPerMinute: requestsPerMinute,
-		clients:           make(map[string][]time.Time),
+// NewRateLimitMiddleware creates a rate limiting middleware backed by l
+func NewRateLimitMiddleware(l limiter.Limiter) *RateLimiter {
+	rl := &RateLimiter{limiter: l, key: (*gin.Context).ClientIP}
+	rl.baseline.Store(int64(l.Limit()))
+	return rl
+}
+
+// SetBaseline changes the requests allowed per window before any adaptive
+// tightening, e.g. when the configuration is reloaded
+func (rl *RateLimiter) SetBaseline(limit int) {
+	rl.baseline.Store(int64(max(1, limit)))
+}
+
+// Cleanup drops the state of clients that stopped making requests
+func (rl *RateLimiter) Cleanup() {
+	rl.limiter.Cleanup()
+}
+
+// Keys reports how many clients the limiter keeps state for
+func (rl *RateLimiter) Keys() int {
+	return rl.limiter.Keys()
+}
+
+// SetAdaptive makes the limiter scale its limit by the controller's current factor
+func (rl *RateLimiter) SetAdaptive(ac *AdaptiveController) {
+	rl.adaptive = ac
+}
+
+// SetKey makes the limiter count requests per key(c) instead of per client
+// IP, e.g. to keep the clients of each tenant apart
+func (rl *RateLimiter) SetKey(key func(*gin.Context) string) {
+	rl.key = key
+}
+
+// limit returns the effective requests per window, after any adaptive tightening
+func (rl *RateLimiter) limit() int {
+	baseline := int(rl.baseline.Load())
+	if rl.adaptive == nil {
+		return baseline
 	}
+	return max(1, int(float64(baseline)*rl.adaptive.Factor()))
 }
 
 // Limit is the middleware function that limits requests
 func (rl *RateLimiter) Limit(c *gin.Context) {
-	rl.mu.Lock()
-	defer rl.mu.Unlock()
-
-	// Get client IP
-	clientIP := c.ClientIP()
-	now := time.Now()
-
-	// Clean old requests
-	var requests []time.Time
-	for _, req := range rl.clients[clientIP] {
-		if now.Sub(req) <= time.Minute {
-			requests = append(requests, req)
-		}
+	if limit := rl.limit(); limit != rl.limiter.Limit() {
+		rl.limiter.SetLimit(limit)
 	}
 
-	// Update requests for this client
-	rl.clients[clientIP] = requests
+	key := rl.key(c)
+	allowed := rl.limiter.Allow(key)
 
-	// Check if limit exceeded
-	if len(requests) >= rl.requestsPerMinute {
-		c.JSON(http.StatusTooManyRequests, gin.H{
-			"error": "Rate limit exceeded. Try again later.",
-		})
-		c.Abort()
+	// Clients can pace themselves from these instead of retrying blindly
+	c.Header("X-RateLimit-Limit", strconv.Itoa(rl.limiter.Limit()))
+	c.Header("X-RateLimit-Remaining", strconv.Itoa(rl.limiter.Remaining(key)))
+
+	if !allowed {
+		retryAfter := strconv.Itoa(ceilSeconds(rl.limiter.NextAvailable(key)))
+		c.Header("Retry-After", retryAfter)
+		c.Header("X-RateLimit-Reset", retryAfter)
+		apierror.Abort(c, apierror.RateLimited("Rate limit exceeded. Try again later."))
 		return
 	}
 
-	// Add current request
-	rl.clients[clientIP] = append(rl.clients[clientIP], now)
 	c.Next()
 }
+
+// ceilSeconds rounds d up to whole seconds, at least 1, as Retry-After requires
+func ceilSeconds(d time.Duration) int {
+	return max(1, int(math.Ceil(d.Seconds())))
+}
@@ -0,0 +1,70 @@
+package middleware
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"expvar"
+	"log"
+	"net/http"
+	"regexp"
+	"runtime/debug"
+	"url-shortener/pkg/apierror"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequestIDHeader carries the ID correlating a request with its log lines
+const RequestIDHeader = "X-Request-ID"
+
+// ContextRequestID is the context key holding the request ID
+const ContextRequestID = apierror.ContextRequestID
+
+// requestIDPattern limits client-supplied request IDs to what is safe to log and echo
+var requestIDPattern = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+// Panics counts requests that panicked, published with the other expvar metrics
+var Panics = expvar.NewInt("panics")
+
+// RequestID tags each request with the ID sent by the client or a proxy in
+// X-Request-ID, or a random one, and echoes it in the response
+func RequestID(c *gin.Context) {
+	id := c.GetHeader(RequestIDHeader)
+	if !requestIDPattern.MatchString(id) {
+		b := make([]byte, 16)
+		rand.Read(b)
+		id = hex.EncodeToString(b)
+	}
+
+	c.Set(ContextRequestID, id)
+	c.Header(RequestIDHeader, id)
+	c.Next()
+}
+
+// Recovery turns a panicking handler into a 500 error response naming the
+// request ID, logging the stack trace under the same ID
+func Recovery(c *gin.Context) {
+	defer func() {
+		recovered := recover()
+		if recovered == nil {
+			return
+		}
+
+		// The client went away mid-response; there is no one to answer
+		if err, ok := recovered.(error); ok && errors.Is(err, http.ErrAbortHandler) {
+			c.Abort()
+			return
+		}
+
+		Panics.Add(1)
+		log.Printf("Panic serving %s %s (request %s): %v\n%s", c.Request.Method, c.Request.URL.Path, c.GetString(ContextRequestID), recovered, debug.Stack())
+
+		if c.Writer.Written() {
+			c.Abort()
+			return
+		}
+		apierror.Abort(c, apierror.Internal("Internal server error"))
+	}()
+
+	c.Next()
+}
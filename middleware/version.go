@@ -0,0 +1,52 @@
+package middleware
+
+import (
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+	"url-shortener/pkg/apierror"
+
+	"github.com/gin-gonic/gin"
+)
+
+// acceptVersion extracts N from media types like application/vnd.urlshortener.vN+json
+var acceptVersion = regexp.MustCompile(`application/vnd\.urlshortener\.v(\d+)\+json`)
+
+// APIVersion negotiates the API version of a route group. Clients may pin a
+// version with the API-Version header or a vendor media type in Accept; requests
+// for a version the group does not serve are rejected with 406.
+func APIVersion(version string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requested := c.GetHeader("API-Version")
+		if requested == "" {
+			if m := acceptVersion.FindStringSubmatch(c.GetHeader("Accept")); m != nil {
+				requested = m[1]
+			}
+		}
+		requested = strings.TrimPrefix(requested, "v")
+
+		if requested != "" && requested != version {
+			apierror.Abort(c, apierror.NotAcceptable("Unsupported API version").WithDetails(gin.H{
+				"supportedVersions": []string{version},
+			}))
+			return
+		}
+
+		c.Header("API-Version", version)
+		c.Next()
+	}
+}
+
+// Deprecated marks every response of a route group as deprecated, pointing
+// clients at the successor path prefix. A zero sunset omits the Sunset header.
+func Deprecated(successorPrefix string, sunset time.Time) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Header("Deprecation", "true")
+		if !sunset.IsZero() {
+			c.Header("Sunset", sunset.UTC().Format(http.TimeFormat))
+		}
+		c.Header("Link", "<"+successorPrefix+c.Request.URL.Path+`>; rel="successor-version"`)
+		c.Next()
+	}
+}
@@ -1,6 +1,7 @@
 package models
 
 import (
+	"encoding/json"
 	"time"
 )
 
@@ -8,7 +9,112 @@ type URL struct {
 	ID          int       `json:"id"`
 	Original    string    `json:"original"`
 	ShortCode   string    `json:"shortCode"`
+	ShortURL    string    `json:"shortUrl"`
+	Domain      string    `json:"domain,omitempty"`
+	OrgID       int       `json:"orgId,omitempty"`
+	Campaign    string    `json:"campaign,omitempty"`
+	CampaignID  int       `json:"campaignId,omitempty"`
 	CreatedAt   time.Time `json:"createdAt"`
 	UpdatedAt   time.Time `json:"updatedAt"`
 	AccessCount int       `json:"accessCount"`
+	BotClicks   int       `json:"botClicks"`
+	Locked      bool      `json:"locked"`
+	Tags        []string  `json:"tags"`
+	Title       string    `json:"title,omitempty"`
+	Description string    `json:"description,omitempty"`
+
+	// ForwardQuery appends the short link's query string to the destination on redirect
+	ForwardQuery bool `json:"forwardQuery"`
+	// ForwardPath forwards the path after the short code to the destination
+	ForwardPath bool `json:"forwardPath"`
+
+	// SuspiciousClicks were excluded from AccessCount by click fraud detection
+	SuspiciousClicks int `json:"suspiciousClicks"`
+
+	// Disabled links no longer redirect; the reason says why, e.g. a blocklisted destination
+	Disabled       bool   `json:"disabled"`
+	DisabledReason string `json:"disabledReason,omitempty"`
+
+	// Targets maps a platform to the destination its visitors are sent to
+	Targets map[string]string `json:"targets,omitempty"`
+
+	// Rotation spreads clicks over several destinations, round-robin or at random
+	Rotation *Rotation `json:"rotation,omitempty"`
+
+	// AppLink opens an app on iOS and Android, falling back to its app store or the destination
+	AppLink *AppLink `json:"appLink,omitempty"`
+
+	// OpenGraph overrides the title, description and image shown when the link is shared
+	OpenGraph *OpenGraph `json:"openGraph,omitempty"`
+
+	// CacheTTL is how many seconds a CDN may cache the redirect, left out when the instance default applies
+	CacheTTL *int `json:"cacheTtl,omitempty"`
+
+	// UniqueClicks counts each visitor once per day, while AccessCount counts every click
+	UniqueClicks int `json:"uniqueClicks"`
+
+	// PublicStats serves a stats page for the link to anyone at /:shortCode/stats
+	PublicStats bool `json:"publicStats"`
+
+	// Frame shows the destination in a full-page iframe on the short domain instead of redirecting
+	Frame bool `json:"frame"`
+
+	// TrackConversions passes each click's ID to the destination as clid, for reporting conversions
+	TrackConversions bool `json:"trackConversions"`
+
+	// Notes and Metadata are free-form context kept with the link; Metadata is a JSON object
+	Notes    string          `json:"notes,omitempty"`
+	Metadata json.RawMessage `json:"metadata,omitempty"`
+
+	// Pixels are the IDs of the retargeting pixels fired before the redirect
+	Pixels []int64 `json:"pixels,omitempty"`
+
+	// Archived links are left out of listings after a period without clicks, but keep redirecting
+	Archived bool `json:"archived"`
+
+	// Health is the latest check of the destination, left out until it is checked
+	Health *LinkHealth `json:"health,omitempty"`
+
+	// ManagementToken is only returned when the link is created
+	ManagementToken string `json:"managementToken,omitempty"`
+}
+
+// Rotation is the destinations a link cycles through and how it picks them
+type Rotation struct {
+	Mode         string   `json:"mode"`
+	Destinations []string `json:"destinations"`
+}
+
+// AppLink is the app URI mobile visitors are sent to and the app store URLs
+// used when the app is not installed
+type AppLink struct {
+	URI          string `json:"uri"`
+	IOSStore     string `json:"iosStore,omitempty"`
+	AndroidStore string `json:"androidStore,omitempty"`
+}
+
+// OpenGraph is the preview social networks and chat apps show for a shared link
+type OpenGraph struct {
+	Title       string `json:"title,omitempty"`
+	Description string `json:"description,omitempty"`
+	Image       string `json:"image,omitempty"`
+}
+
+// LinkHealth is the outcome of a periodic check of a link's destination
+type LinkHealth struct {
+	CheckedAt  time.Time `json:"checkedAt"`
+	StatusCode int       `json:"statusCode,omitempty"`
+	LatencyMs  int64     `json:"latencyMs"`
+	// Redirects are the URLs the destination redirected through
+	Redirects []string `json:"redirects,omitempty"`
+	Error     string   `json:"error,omitempty"`
+	// Broken destinations answered with a 4xx or 5xx status or could not be reached
+	Broken      bool       `json:"broken"`
+	BrokenSince *time.Time `json:"brokenSince,omitempty"`
+}
+
+// ArchivedURL is the record written to object storage when a link is deleted
+type ArchivedURL struct {
+	URL        URL       `json:"url"`
+	ArchivedAt time.Time `json:"archivedAt"`
 }
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// maxNotes bounds a link's notes, in characters
+	maxNotes = 2000
+	// maxMetadataBytes bounds a link's metadata object, as compact JSON
+	maxMetadataBytes = 4096
+	// metadataQueryPrefix marks the query parameters filtering links by a
+	// metadata key, e.g. ?metadata.ticket=OPS-42
+	metadataQueryPrefix = "metadata."
+)
+
+// normalizeNotes trims a link's notes and checks their length
+func normalizeNotes(notes string) (string, bool) {
+	notes = strings.TrimSpace(notes)
+	return notes, utf8.RuneCountInString(notes) <= maxNotes
+}
+
+// normalizeMetadata compacts the metadata integrations store with a link,
+// which must be a JSON object. Missing or null metadata is the empty object.
+func normalizeMetadata(raw json.RawMessage) (json.RawMessage, bool) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return json.RawMessage("{}"), true
+	}
+	var object map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &object); err != nil || object == nil {
+		return nil, false
+	}
+	var compact bytes.Buffer
+	if err := json.Compact(&compact, raw); err != nil || compact.Len() > maxMetadataBytes {
+		return nil, false
+	}
+	return compact.Bytes(), true
+}
+
+// linkMetadata returns metadata as links report it, nil when empty
+func linkMetadata(metadata json.RawMessage) json.RawMessage {
+	if string(metadata) == "{}" {
+		return nil
+	}
+	return metadata
+}
+
+// metadataFilter collects the ?metadata.key=value parameters of a listing.
+// Values are compared with the text of the key's value, so ?metadata.count=3
+// matches both 3 and "3".
+func metadataFilter(c *gin.Context) map[string]string {
+	filter := map[string]string{}
+	for key, values := range c.Request.URL.Query() {
+		if name, ok := strings.CutPrefix(key, metadataQueryPrefix); ok && name != "" && len(values) > 0 {
+			filter[name] = values[0]
+		}
+	}
+	return filter
+}
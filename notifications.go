@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"html/template"
+	"path/filepath"
+	"strconv"
+	"url-shortener/config"
+	"url-shortener/db"
+	"url-shortener/pkg/mailer"
+
+	"github.com/gin-gonic/gin"
+)
+
+// emailer delivers notification emails; nil when SMTP is not configured
+var emailer *mailer.Mailer
+
+// clickMilestones are the click counts at which a link's owner is emailed
+var clickMilestones = map[int]bool{}
+
+// configureMailer starts the mail queue when an SMTP host is configured
+func configureMailer(cfg *config.Config) error {
+	if cfg.SMTP.Host == "" {
+		return nil
+	}
+
+	templates, err := template.ParseFS(defaultEmails, "emails/*.html")
+	if err != nil {
+		return err
+	}
+	// TEMPLATES_DIR/emails replaces built-in emails of the same name
+	if cfg.Pages.TemplatesDir != "" {
+		overrides, err := filepath.Glob(filepath.Join(cfg.Pages.TemplatesDir, "emails", "*.html"))
+		if err != nil {
+			return err
+		}
+		if len(overrides) > 0 {
+			if templates, err = templates.ParseFiles(overrides...); err != nil {
+				return err
+			}
+		}
+	}
+	emailer, err = mailer.New(mailer.Config{
+		Host:     cfg.SMTP.Host,
+		Port:     cfg.SMTP.Port,
+		Username: cfg.SMTP.Username,
+		Password: cfg.SMTP.Password,
+		From:     cfg.SMTP.From,
+	}, templates, cfg.SMTP.QueueSize)
+	if err != nil {
+		return err
+	}
+
+	for _, n := range cfg.Notifications.ClickMilestones {
+		clickMilestones[n] = true
+	}
+	return nil
+}
+
+// notifyClickMilestone emails the link's owner when a click brings it to one
+// of the configured milestones. Anonymous links have nobody to notify.
+func notifyClickMilestone(c *gin.Context, link *db.URL, clicks int) {
+	if emailer == nil || !clickMilestones[clicks] {
+		return
+	}
+
+	shortURL := shortURLFor(c, link.Domain, link.ShortCode)
+	// The request context ends with the redirect, before the lookup runs
+	runInBackground("milestone-email", withRequestTenant(c, func(ctx context.Context) error {
+		to, err := database.GetOwnerEmail(ctx, link.ShortCode)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("looking up owner of %s: %w", link.ShortCode, err)
+		}
+
+		count := formatCount(clicks)
+		err = emailer.Send(to, "Your link hit "+count+" clicks", "milestone.html", map[string]string{
+			"ShortURL": shortURL,
+			"Original": link.OriginalURL,
+			"Clicks":   count,
+		})
+		if err != nil {
+			return fmt.Errorf("queueing milestone email for %s: %w", link.ShortCode, err)
+		}
+		return nil
+	}))
+}
+
+// formatCount writes n with thousands separators, e.g. 10,000
+func formatCount(n int) string {
+	s := strconv.Itoa(n)
+	for i := len(s) - 3; i > 0; i -= 3 {
+		s = s[:i] + "," + s[i:]
+	}
+	return s
+}
@@ -0,0 +1,278 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+	"url-shortener/config"
+	"url-shortener/middleware"
+	"url-shortener/pkg/apierror"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/oauth2"
+)
+
+const (
+	sessionCookie    = "session"
+	oauthStateCookie = "oauth_state"
+)
+
+// oauthProvider is an identity provider users can sign in with
+type oauthProvider struct {
+	config *oauth2.Config
+	// identify fetches the provider's stable account ID, email and display name
+	identify func(ctx context.Context, client *http.Client) (subject, email, name string, err error)
+}
+
+// oauthProviders holds the configured providers by name; empty when login is disabled
+var oauthProviders = map[string]*oauthProvider{}
+
+var sessionTTL time.Duration
+var oauthCallbackURL string
+
+func configureOAuth(cfg *config.Config) {
+	sessionTTL = cfg.OAuth.SessionTTL
+	oauthCallbackURL = cfg.OAuth.CallbackURL
+
+	if cfg.OAuth.GoogleClientID != "" {
+		oauthProviders["google"] = &oauthProvider{
+			config: &oauth2.Config{
+				ClientID:     cfg.OAuth.GoogleClientID,
+				ClientSecret: cfg.OAuth.GoogleClientSecret,
+				Endpoint: oauth2.Endpoint{
+					AuthURL:  "https://accounts.google.com/o/oauth2/v2/auth",
+					TokenURL: "https://oauth2.googleapis.com/token",
+				},
+				Scopes: []string{"openid", "email", "profile"},
+			},
+			identify: googleIdentity,
+		}
+	}
+	if cfg.OAuth.GitHubClientID != "" {
+		oauthProviders["github"] = &oauthProvider{
+			config: &oauth2.Config{
+				ClientID:     cfg.OAuth.GitHubClientID,
+				ClientSecret: cfg.OAuth.GitHubClientSecret,
+				Endpoint: oauth2.Endpoint{
+					AuthURL:  "https://github.com/login/oauth/authorize",
+					TokenURL: "https://github.com/login/oauth/access_token",
+				},
+				Scopes: []string{"read:user", "user:email"},
+			},
+			identify: githubIdentity,
+		}
+	}
+}
+
+func googleIdentity(ctx context.Context, client *http.Client) (string, string, string, error) {
+	var info struct {
+		Sub   string `json:"sub"`
+		Email string `json:"email"`
+		Name  string `json:"name"`
+	}
+	if err := getJSON(ctx, client, "https://openidconnect.googleapis.com/v1/userinfo", &info); err != nil {
+		return "", "", "", err
+	}
+	return info.Sub, info.Email, info.Name, nil
+}
+
+func githubIdentity(ctx context.Context, client *http.Client) (string, string, string, error) {
+	var info struct {
+		ID    int64  `json:"id"`
+		Login string `json:"login"`
+		Name  string `json:"name"`
+		Email string `json:"email"`
+	}
+	if err := getJSON(ctx, client, "https://api.github.com/user", &info); err != nil {
+		return "", "", "", err
+	}
+	name := info.Name
+	if name == "" {
+		name = info.Login
+	}
+	return strconv.FormatInt(info.ID, 10), info.Email, name, nil
+}
+
+func getJSON(ctx context.Context, client *http.Client, url string, out any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s returned %s", url, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// oauthState is kept in a short-lived cookie between login and callback
+type oauthState struct {
+	State    string `json:"state"`
+	Provider string `json:"provider"`
+	Verifier string `json:"verifier"`
+	Redirect string `json:"redirect"`
+}
+
+// oauthLogin sends the user to the chosen provider's consent page
+func oauthLogin(c *gin.Context) {
+	name := c.Query("provider")
+	provider, ok := oauthProviders[name]
+	if !ok {
+		respondError(c, apierror.Validation("Unknown or disabled login provider"))
+		return
+	}
+
+	state := oauthState{
+		State:    randomToken(),
+		Provider: name,
+		Verifier: oauth2.GenerateVerifier(),
+		Redirect: sameSiteRedirect(c.DefaultQuery("redirect", "/")),
+	}
+	encoded, _ := json.Marshal(state)
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(oauthStateCookie, base64.RawURLEncoding.EncodeToString(encoded), 600, "/auth", "", isSecureRequest(c), true)
+
+	c.Redirect(http.StatusFound, provider.config.AuthCodeURL(state.State,
+		oauth2.S256ChallengeOption(state.Verifier),
+		oauth2.SetAuthURLParam("redirect_uri", callbackURL(c))))
+}
+
+// oauthCallback completes the login, links the provider account to a user and
+// starts a session
+func oauthCallback(c *gin.Context) {
+	raw, err := c.Cookie(oauthStateCookie)
+	if err != nil {
+		respondError(c, apierror.Validation("Login session expired"))
+		return
+	}
+	c.SetCookie(oauthStateCookie, "", -1, "/auth", "", isSecureRequest(c), true)
+
+	var state oauthState
+	decoded, err := base64.RawURLEncoding.DecodeString(raw)
+	if err != nil || json.Unmarshal(decoded, &state) != nil || state.State == "" || state.State != c.Query("state") {
+		respondError(c, apierror.Validation("Invalid login state"))
+		return
+	}
+
+	provider, ok := oauthProviders[state.Provider]
+	if !ok {
+		respondError(c, apierror.Validation("Unknown or disabled login provider"))
+		return
+	}
+	if errParam := c.Query("error"); errParam != "" {
+		respondError(c, apierror.Unauthorized("Login was not completed: "+errParam))
+		return
+	}
+
+	ctx := c.Request.Context()
+	token, err := provider.config.Exchange(ctx, c.Query("code"),
+		oauth2.VerifierOption(state.Verifier),
+		oauth2.SetAuthURLParam("redirect_uri", callbackURL(c)))
+	if err != nil {
+		respondError(c, apierror.Upstream("Failed to exchange authorization code").Wrap(err))
+		return
+	}
+
+	subject, email, name, err := provider.identify(ctx, provider.config.Client(ctx, token))
+	if err != nil || subject == "" {
+		log.Printf("Failed to fetch %s identity: %v", state.Provider, err)
+		respondError(c, apierror.Upstream("Failed to fetch account details"))
+		return
+	}
+
+	user, err := database.UpsertOAuthUser(ctx, state.Provider, subject, email, name)
+	if err != nil {
+		respondError(c, apierror.Internal("Failed to store user").Wrap(err))
+		return
+	}
+
+	startSession(c, user, state.Redirect)
+}
+
+func oauthLogout(c *gin.Context) {
+	if session, err := c.Cookie(sessionCookie); err == nil {
+		if err := database.DeleteSession(c.Request.Context(), hashSecret(session)); err != nil {
+			respondError(c, apierror.Internal("Failed to end session").Wrap(err))
+			return
+		}
+	}
+
+	c.SetCookie(sessionCookie, "", -1, "/", "", isSecureRequest(c), true)
+	c.JSON(http.StatusOK, gin.H{"message": "Logged out"})
+}
+
+// currentUser returns the signed-in user
+func currentUser(c *gin.Context) {
+	user, ok := c.Get(contextUser)
+	if !ok {
+		if _, err := c.Cookie(twoFactorCookie); err == nil {
+			respondError(c, apierror.Unauthorized("Two-factor code required").WithDetails(gin.H{"verify": "/auth/2fa/verify"}))
+			return
+		}
+		respondError(c, apierror.Unauthorized("Not signed in"))
+		return
+	}
+
+	c.JSON(http.StatusOK, user)
+}
+
+// contextUser holds the *db.User of a request authenticated by session cookie
+const contextUser = "user"
+
+// sessionAuth identifies users by their session cookie. Requests already
+// authenticated by a JWT, or without a session, pass through unchanged.
+func sessionAuth(c *gin.Context) {
+	session, err := c.Cookie(sessionCookie)
+	if err != nil || session == "" || c.GetString(middleware.ContextUserID) != "" {
+		c.Next()
+		return
+	}
+
+	user, err := database.GetSessionUser(c.Request.Context(), hashSecret(session))
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		// Expired or revoked; treat the request as anonymous
+	case err != nil:
+		respondError(c, apierror.Internal("Database error").Wrap(err))
+		return
+	default:
+		c.Set(contextUser, user)
+		c.Set(middleware.ContextUserID, strconv.Itoa(user.ID))
+		c.Set(middleware.ContextRole, user.Role)
+	}
+
+	c.Next()
+}
+
+// callbackURL is OAUTH_CALLBACK_URL, or /auth/callback on the host the request arrived on
+func callbackURL(c *gin.Context) string {
+	if oauthCallbackURL != "" {
+		return oauthCallbackURL
+	}
+	return requestScheme(c) + "://" + c.Request.Host + "/auth/callback"
+}
+
+func isSecureRequest(c *gin.Context) bool {
+	return requestScheme(c) == "https"
+}
+
+func randomToken() string {
+	b := make([]byte, 32)
+	rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
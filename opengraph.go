@@ -0,0 +1,111 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+	"url-shortener/db"
+	"url-shortener/pkg/apierror"
+	"url-shortener/pkg/botdetect"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// maxOpenGraphTitle and maxOpenGraphDescription bound preview text, in characters
+	maxOpenGraphTitle       = 300
+	maxOpenGraphDescription = 1000
+)
+
+// normalizeOpenGraph trims an Open Graph override and checks its lengths and
+// that the image is an http(s) URL. An override with nothing set means none.
+func normalizeOpenGraph(og *db.OpenGraph) (*db.OpenGraph, bool) {
+	if og == nil {
+		return nil, true
+	}
+
+	normalized := db.OpenGraph{
+		Title:       strings.TrimSpace(og.Title),
+		Description: strings.TrimSpace(og.Description),
+		Image:       strings.TrimSpace(og.Image),
+	}
+	if normalized == (db.OpenGraph{}) {
+		return nil, true
+	}
+	if utf8.RuneCountInString(normalized.Title) > maxOpenGraphTitle || utf8.RuneCountInString(normalized.Description) > maxOpenGraphDescription {
+		return nil, false
+	}
+	if normalized.Image != "" {
+		u, err := url.Parse(normalized.Image)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return nil, false
+		}
+	}
+	return &normalized, true
+}
+
+// serveOpenGraph answers social networks and chat apps unfurling a link with
+// an Open Graph override with a page carrying its preview tags instead of a
+// redirect. The page still forwards anyone who opens it to destination, so
+// it is only served for http(s) destinations. It reports whether the page
+// was served.
+func serveOpenGraph(c *gin.Context, link *db.URL, destination string) bool {
+	og := link.OpenGraph
+	if og == nil || !botdetect.IsUnfurler(c.Request.UserAgent()) || !validDestination(destination) {
+		return false
+	}
+
+	// Whatever the override leaves out is taken from the destination page
+	title, description := og.Title, og.Description
+	if title == "" {
+		title = link.Title
+	}
+	if description == "" {
+		description = link.Description
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.HTML(http.StatusOK, "opengraph.html", gin.H{
+		"title":       title,
+		"description": description,
+		"image":       og.Image,
+		"shortURL":    shortURLFor(c, link.Domain, link.ShortCode),
+		"destination": destination,
+	})
+	return true
+}
+
+// setURLOpenGraph sets the title, description and image shown when the link
+// is shared; an empty body removes the override
+func setURLOpenGraph(c *gin.Context) {
+	shortCode := c.Param("shortCode")
+
+	var request db.OpenGraph
+	if err := c.ShouldBindJSON(&request); err != nil {
+		respondError(c, apierror.Validation("Invalid request body"))
+		return
+	}
+
+	og, ok := normalizeOpenGraph(&request)
+	if !ok {
+		respondError(c, apierror.Validation("Invalid Open Graph metadata: title is limited to "+strconv.Itoa(maxOpenGraphTitle)+
+			" characters, description to "+strconv.Itoa(maxOpenGraphDescription)+" and image must be an http(s) URL"))
+		return
+	}
+
+	previous, err := database.GetURLByShortCode(c.Request.Context(), shortCode)
+	if err != nil {
+		respondError(c, notFound(err, "Short URL not found"))
+		return
+	}
+
+	if err := database.SetOpenGraph(c.Request.Context(), shortCode, og); err != nil {
+		respondError(c, notFound(err, "Short URL not found"))
+		return
+	}
+
+	recordAudit(c, auditUpdate, "url", shortCode, gin.H{"openGraph": previous.OpenGraph}, gin.H{"openGraph": og})
+	c.JSON(http.StatusOK, gin.H{"message": "Open Graph metadata updated successfully", "openGraph": og})
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"url-shortener/db"
+	"url-shortener/middleware"
+	"url-shortener/pkg/apierror"
+
+	"github.com/gin-gonic/gin"
+)
+
+// orgWriteRoles may create, change and delete an organization's links
+var orgWriteRoles = []string{db.RoleOwner, db.RoleEditor}
+
+// orgRole returns the requesting user's role in an organization, writing an
+// error response and returning false when they are not signed in or not a member
+func orgRole(c *gin.Context, orgID int) (string, bool) {
+	role, err := memberRole(c, orgID)
+	if err != nil {
+		respondError(c, err)
+		return "", false
+	}
+	return role, true
+}
+
+// memberRole returns the requesting user's role in an organization, failing
+// when they are not signed in, not a member, or lack two-factor
+// authentication the organization requires
+func memberRole(c *gin.Context, orgID int) (string, error) {
+	userID := c.GetString(middleware.ContextUserID)
+	if userID == "" {
+		return "", apierror.Unauthorized("Authentication required")
+	}
+
+	role, err := database.GetMemberRole(c.Request.Context(), orgID, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", apierror.Forbidden("Not a member of this organization")
+	}
+	if err != nil {
+		return "", apierror.Internal("Database error").Wrap(err)
+	}
+	if err := checkOrgTwoFactor(c, orgID); err != nil {
+		return "", err
+	}
+	return role, nil
+}
+
+// orgIDParam parses the :orgId path parameter
+func orgIDParam(c *gin.Context) (int, bool) {
+	id, err := strconv.Atoi(c.Param("orgId"))
+	if err != nil || id <= 0 {
+		respondError(c, apierror.NotFound("Organization not found"))
+		return 0, false
+	}
+	return id, true
+}
+
+func createOrganization(c *gin.Context) {
+	var request struct {
+		Name string `json:"name" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil || strings.TrimSpace(request.Name) == "" {
+		respondError(c, apierror.Validation("Invalid request body"))
+		return
+	}
+
+	userID := c.GetString(middleware.ContextUserID)
+	if userID == "" {
+		respondError(c, apierror.Unauthorized("Authentication required"))
+		return
+	}
+
+	org, err := database.CreateOrganization(c.Request.Context(), strings.TrimSpace(request.Name), userID)
+	if err != nil {
+		respondError(c, apierror.Internal("Failed to create organization").Wrap(err))
+		return
+	}
+
+	recordAudit(c, auditCreate, "organization", strconv.Itoa(org.ID), nil, org)
+	c.JSON(http.StatusCreated, org)
+}
+
+// getOrganizations lists the organizations the requesting user belongs to
+func getOrganizations(c *gin.Context) {
+	userID := c.GetString(middleware.ContextUserID)
+	if userID == "" {
+		respondError(c, apierror.Unauthorized("Authentication required"))
+		return
+	}
+
+	orgs, err := database.GetUserOrganizations(c.Request.Context(), userID)
+	if err != nil {
+		respondError(c, apierror.Internal("Database error").Wrap(err))
+		return
+	}
+
+	c.JSON(http.StatusOK, orgs)
+}
+
+func getOrgMembers(c *gin.Context) {
+	orgID, ok := orgIDParam(c)
+	if !ok {
+		return
+	}
+	if _, ok := orgRole(c, orgID); !ok {
+		return
+	}
+
+	members, err := database.GetMembers(c.Request.Context(), orgID)
+	if err != nil {
+		respondError(c, apierror.Internal("Database error").Wrap(err))
+		return
+	}
+
+	c.JSON(http.StatusOK, members)
+}
+
+// setOrgMember adds a member or changes their role; only owners may do this
+func setOrgMember(c *gin.Context) {
+	orgID, ok := orgIDParam(c)
+	if !ok {
+		return
+	}
+
+	var request struct {
+		Role string `json:"role" binding:"required,oneof=owner editor viewer"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		respondError(c, apierror.Validation("Role must be owner, editor or viewer"))
+		return
+	}
+
+	role, ok := orgRole(c, orgID)
+	if !ok {
+		return
+	}
+	if role != db.RoleOwner {
+		respondError(c, apierror.Forbidden("Only owners can manage members"))
+		return
+	}
+
+	err := database.SetMember(c.Request.Context(), orgID, c.Param("userId"), request.Role)
+	if errors.Is(err, db.ErrLastOwner) {
+		respondError(c, apierror.Conflict("Organization must keep at least one owner"))
+		return
+	}
+	if err != nil {
+		respondError(c, apierror.Internal("Failed to update member").Wrap(err))
+		return
+	}
+
+	recordAudit(c, auditUpdate, "organization_member", strconv.Itoa(orgID)+"/"+c.Param("userId"), nil, gin.H{"role": request.Role})
+	c.JSON(http.StatusOK, gin.H{"message": "Member updated"})
+}
+
+// removeOrgMember removes a member; owners may remove anyone, others only themselves
+func removeOrgMember(c *gin.Context) {
+	orgID, ok := orgIDParam(c)
+	if !ok {
+		return
+	}
+
+	role, ok := orgRole(c, orgID)
+	if !ok {
+		return
+	}
+	userID := c.Param("userId")
+	if role != db.RoleOwner && userID != c.GetString(middleware.ContextUserID) {
+		respondError(c, apierror.Forbidden("Only owners can manage members"))
+		return
+	}
+
+	err := database.RemoveMember(c.Request.Context(), orgID, userID)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		respondError(c, apierror.NotFound("Member not found"))
+	case errors.Is(err, db.ErrLastOwner):
+		respondError(c, apierror.Conflict("Organization must keep at least one owner"))
+	case err != nil:
+		respondError(c, apierror.Internal("Failed to remove member").Wrap(err))
+	default:
+		recordAudit(c, auditDelete, "organization_member", strconv.Itoa(orgID)+"/"+userID, nil, nil)
+		c.JSON(http.StatusOK, gin.H{"message": "Member removed"})
+	}
+}
+
+// canWriteOrgLinks reports whether a role may change an organization's links
+func canWriteOrgLinks(role string) bool {
+	return slices.Contains(orgWriteRoles, role)
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+	"url-shortener/db"
+	"url-shortener/events"
+)
+
+const (
+	// outboxBatch is how many events are claimed and published at a time
+	outboxBatch = 100
+	// outboxLease is how long claimed events are held before another run may
+	// claim them again, e.g. after this instance died while publishing
+	outboxLease = time.Minute
+)
+
+// outboxPublisher delivers link lifecycle events from the outbox; nil when no
+// broker is configured
+var outboxPublisher *events.KafkaPublisher
+
+// dispatchOutbox publishes the events due in the outbox, removing them once
+// the broker has stored them and backing them off when it fails. Delivery is
+// at least once: consumers drop duplicates by the envelope ID, which stays
+// the same across attempts.
+func dispatchOutbox(ctx context.Context) error {
+	for {
+		claimed, err := database.ClaimOutboxEvents(ctx, outboxBatch, outboxLease)
+		if err != nil || len(claimed) == 0 {
+			return err
+		}
+
+		messages := make([]events.Message, 0, len(claimed))
+		ids := make([]int64, 0, len(claimed))
+		for _, e := range claimed {
+			messages = append(messages, events.Message{Key: e.Key, Event: outboxEnvelope(e)})
+			ids = append(ids, e.ID)
+		}
+
+		if err := outboxPublisher.PublishBatch(ctx, messages); err != nil {
+			// Events left claimed are retried once their lease runs out
+			if err := database.RetryOutboxEvents(ctx, ids, err.Error()); err != nil {
+				log.Printf("Failed to back off %d outbox events: %v", len(ids), err)
+			}
+			return fmt.Errorf("publishing %d outbox events: %w", len(ids), err)
+		}
+		if err := database.DeleteOutboxEvents(ctx, ids); err != nil {
+			return err
+		}
+		if len(claimed) < outboxBatch {
+			return nil
+		}
+	}
+}
+
+// outboxEnvelope wraps an outbox event the way events.New would have when it happened
+func outboxEnvelope(e db.OutboxEvent) events.Envelope {
+	return events.Envelope{
+		ID:         e.EventID,
+		Type:       e.Type,
+		Version:    events.SchemaVersion,
+		OccurredAt: e.CreatedAt.UTC(),
+		Data:       json.RawMessage(e.Payload),
+	}
+}
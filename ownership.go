@@ -0,0 +1,185 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+	"url-shortener/db"
+	"url-shortener/middleware"
+	"url-shortener/pkg/apierror"
+
+	"github.com/gin-gonic/gin"
+)
+
+// managementTokenHeader carries the secret returned when a link was created
+const managementTokenHeader = "X-Management-Token"
+
+// newManagementToken generates a link's secret management token and the hash stored for it
+func newManagementToken() (string, string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", err
+	}
+	token := base64.RawURLEncoding.EncodeToString(b)
+	return token, hashSecret(token), nil
+}
+
+// hashSecret is how management and session tokens and API keys are stored,
+// so a database leak does not expose usable secrets
+func hashSecret(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// requireLinkOwner only lets through requests for a link that present its
+// management token, come from the user who created it or a member of its
+// organization allowed to act on it, or carry admin rights. Links created
+// before management tokens existed have no stored hash and stay open.
+func requireLinkOwner() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		readOnly := c.Request.Method == http.MethodGet || c.Request.Method == http.MethodHead
+		if err := linkAccess(c, c.Param("shortCode"), !readOnly); err != nil {
+			respondError(c, err)
+			return
+		}
+		c.Next()
+	}
+}
+
+// linkAccess applies the rules of requireLinkOwner to a request reading, or
+// with write set changing, the link shortCode. Unknown links are let through
+// for the caller to report.
+func linkAccess(c *gin.Context, shortCode string, write bool) error {
+	if isAdmin(c) {
+		return nil
+	}
+	// Keys scoped to read links or stats may read any link of their tenant
+	if !write && keyGrants(c, scopeLinksRead, scopeStatsRead) {
+		return nil
+	}
+
+	owner, err := database.GetOwnership(c.Request.Context(), shortCode)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return apierror.Internal("Database error").Wrap(err)
+	}
+	if owner.TokenHash == "" {
+		return nil
+	}
+	if signedRequest(c) {
+		return verifySignedURL(c, shortCode, owner)
+	}
+	userID := c.GetString(middleware.ContextUserID)
+	if userID != "" && userID == owner.OwnerID {
+		return nil
+	}
+	if userID != "" && owner.OrgID != 0 {
+		role, err := database.GetMemberRole(c.Request.Context(), owner.OrgID, userID)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return apierror.Internal("Database error").Wrap(err)
+		}
+		if role != "" {
+			if err := checkOrgTwoFactor(c, owner.OrgID); err != nil {
+				return err
+			}
+		}
+		// Viewers may read stats but not change the link
+		if role != "" && (!write || canWriteOrgLinks(role)) {
+			return nil
+		}
+		if role != "" {
+			return apierror.Forbidden("Your role does not allow changing this link")
+		}
+	}
+
+	token := c.GetHeader(managementTokenHeader)
+	if token == "" {
+		return apierror.Unauthorized("Management token required")
+	}
+	if subtle.ConstantTimeCompare([]byte(hashSecret(token)), []byte(owner.TokenHash)) != 1 {
+		return apierror.Forbidden("Invalid management token")
+	}
+	return nil
+}
+
+// adminToken is the configured ADMIN_TOKEN, empty when token access is disabled
+var adminToken string
+
+// adminReach is how far the admin rights of a request extend
+type adminReach int
+
+const (
+	notAdmin adminReach = iota
+	// tenantAdmin is an API key with the admin scope, which administers the
+	// links, organizations and campaigns of its own tenant only
+	tenantAdmin
+	// globalAdmin is the admin token or an admin JWT, which administer every
+	// tenant and the admin API
+	globalAdmin
+)
+
+// adminRights is the one check of a request's admin rights, behind both
+// isAdmin and the admin API. Rights only count from an address on the admin
+// allowlist, and an admin API key only on requests for its own tenant.
+func adminRights(c *gin.Context) adminReach {
+	if !ipFilter.AdminAllowed(c.ClientIP()) {
+		return notAdmin
+	}
+	if c.GetString(middleware.ContextRole) == middleware.RoleAdmin {
+		return globalAdmin
+	}
+	presented := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+	if adminToken != "" && subtle.ConstantTimeCompare([]byte(presented), []byte(adminToken)) == 1 {
+		return globalAdmin
+	}
+	if key, ok := scopedAPIKey(c); ok && slices.Contains(key.Scopes, scopeAdmin) && key.TenantID == db.TenantFrom(c.Request.Context()) {
+		return tenantAdmin
+	}
+	return notAdmin
+}
+
+// isAdmin reports whether the request has admin rights over the resources of
+// its tenant, which every tenant-scoped lookup is limited to
+func isAdmin(c *gin.Context) bool {
+	return adminRights(c) != notAdmin
+}
+
+// adminOfOrg reports whether the request's admin rights cover the organization
+// orgID: global admins administer every organization, admin API keys only
+// those of their tenant
+func adminOfOrg(c *gin.Context, orgID int) bool {
+	switch adminRights(c) {
+	case globalAdmin:
+		return true
+	case tenantAdmin:
+		exists, err := database.OrganizationExists(c.Request.Context(), orgID)
+		return err == nil && exists
+	}
+	return false
+}
+
+// requireAdmin only lets global admins through to the admin API, which spans
+// every tenant. An empty admin token disables token access to it.
+func requireAdmin(c *gin.Context) {
+	switch adminRights(c) {
+	case globalAdmin:
+		c.Next()
+	case tenantAdmin:
+		apierror.Abort(c, apierror.Forbidden("Admin API keys only administer their own tenant; this route needs the admin token"))
+	default:
+		if adminToken == "" {
+			apierror.Abort(c, apierror.Forbidden("Admin API is disabled"))
+			return
+		}
+		apierror.Abort(c, apierror.Unauthorized("Invalid admin token"))
+	}
+}
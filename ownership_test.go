@@ -0,0 +1,104 @@
+package main
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"url-shortener/db"
+	"url-shortener/db/dbtest"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TestAdminKeyReach checks an API key with the admin scope administers its own
+// tenant, and neither other tenants nor the admin API, while the admin token
+// reaches all of them
+func TestAdminKeyReach(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	const adminKey = "tenant-one-admin-key"
+
+	previousIndex, previousToken := tenantDirectory.Load(), adminToken
+	tenantDirectory.Store(&tenantIndex{
+		hosts: map[string]int{"one.example": 1, "two.example": 2},
+		keys:  map[string]*db.APIKey{hashSecret(adminKey): {ID: 1, TenantID: 1, Name: "admin", Scopes: []string{scopeAdmin}}},
+	})
+	adminToken = testAdminToken
+	t.Cleanup(func() {
+		tenantDirectory.Store(previousIndex)
+		adminToken = previousToken
+	})
+
+	// Organization 10 belongs to tenant 1 and organization 20 to tenant 2
+	owners := map[int]int{10: 1, 20: 2}
+	useStubDatabase(t, func(query string, args []driver.NamedValue) dbtest.Result {
+		exists := len(args) == 2 && owners[args[0].Value.(int)] == args[1].Value.(int)
+		return dbtest.Result{Columns: []string{"exists"}, Rows: [][]driver.Value{{exists}}}
+	})
+
+	r := gin.New()
+	r.Use(resolveTenant)
+	r.GET("/probe", func(c *gin.Context) {
+		orgID, _ := strconv.Atoi(c.Query("orgId"))
+		c.JSON(http.StatusOK, gin.H{"admin": isAdmin(c), "orgAdmin": adminOfOrg(c, orgID)})
+	})
+	r.GET("/api/v1/admin/api-keys", requireScope(scopeAdmin), ipFilter.AdminOnly(requireAdmin), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	tests := []struct {
+		name     string
+		host     string
+		path     string
+		key      string
+		token    string
+		want     int
+		admin    bool
+		orgAdmin bool
+	}{
+		{name: "key on its own tenant", host: "one.example", path: "/probe?orgId=10", key: adminKey, want: http.StatusOK, admin: true, orgAdmin: true},
+		{name: "key without a tenant hostname", host: "example.com", path: "/probe?orgId=10", key: adminKey, want: http.StatusOK, admin: true, orgAdmin: true},
+		{name: "key on another tenant's organization", host: "one.example", path: "/probe?orgId=20", key: adminKey, want: http.StatusOK, admin: true},
+		{name: "key on another tenant's hostname", host: "two.example", path: "/probe?orgId=20", key: adminKey, want: http.StatusForbidden},
+		{name: "token on another tenant", host: "two.example", path: "/probe?orgId=10", token: testAdminToken, want: http.StatusOK, admin: true, orgAdmin: true},
+		{name: "no credentials", host: "one.example", path: "/probe?orgId=10", want: http.StatusOK},
+		{name: "key on the admin API", host: "one.example", path: "/api/v1/admin/api-keys", key: adminKey, want: http.StatusForbidden},
+		{name: "token on the admin API", host: "one.example", path: "/api/v1/admin/api-keys", token: testAdminToken, want: http.StatusOK},
+		{name: "wrong token on the admin API", host: "one.example", path: "/api/v1/admin/api-keys", token: "wrong", want: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			req.Host = tt.host
+			if tt.key != "" {
+				req.Header.Set(apiKeyHeader, tt.key)
+			}
+			if tt.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != tt.want {
+				t.Fatalf("want %d, got %d: %s", tt.want, w.Code, w.Body)
+			}
+			if tt.want != http.StatusOK || !strings.HasPrefix(tt.path, "/probe") {
+				return
+			}
+			var got struct {
+				Admin    bool `json:"admin"`
+				OrgAdmin bool `json:"orgAdmin"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+				t.Fatal(err)
+			}
+			if got.Admin != tt.admin || got.OrgAdmin != tt.orgAdmin {
+				t.Errorf("want admin %t and orgAdmin %t, got %t and %t", tt.admin, tt.orgAdmin, got.Admin, got.OrgAdmin)
+			}
+		})
+	}
+}
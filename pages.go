@@ -0,0 +1,32 @@
+package main
+
+import (
+	"html/template"
+	"path/filepath"
+	"url-shortener/config"
+)
+
+// loadPageTemplates parses the built-in pages, then any *.html files in
+// TEMPLATES_DIR, which replace the built-in file of the same name or the
+// head, logo and footer blocks of layout.html. Every page can read the
+// THEME_* settings through the theme function.
+func loadPageTemplates(cfg *config.Config) (*template.Template, error) {
+	theme := cfg.Pages.Theme
+	templates := template.New("").Funcs(template.FuncMap{
+		"theme": func() any { return theme },
+	})
+
+	templates, err := templates.ParseFS(defaultTemplates, "templates/*.html")
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg.Pages.TemplatesDir == "" {
+		return templates, nil
+	}
+	overrides, err := filepath.Glob(filepath.Join(cfg.Pages.TemplatesDir, "*.html"))
+	if err != nil || len(overrides) == 0 {
+		return templates, err
+	}
+	return templates.ParseFiles(overrides...)
+}
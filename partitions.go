@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"path"
+	"time"
+	"url-shortener/config"
+	"url-shortener/db"
+	"url-shortener/pkg/jobs"
+	"url-shortener/pkg/parquet"
+)
+
+const (
+	// clickPartitionsAhead is how many months past the current one have their
+	// partition created in advance, so clicks never arrive without one
+	clickPartitionsAhead = 2
+	// clickPartitionInterval is how often partitions are created and expired
+	clickPartitionInterval = 24 * time.Hour
+)
+
+// archivedClickFields are the columns of archived click events
+var archivedClickFields = []parquet.Field{
+	{Name: "id", Kind: parquet.Int64},
+	{Name: "url_id", Kind: parquet.Int64},
+	{Name: "clicked_at", Kind: parquet.Timestamp},
+	{Name: "device", Kind: parquet.String},
+	{Name: "browser", Kind: parquet.String},
+	{Name: "os", Kind: parquet.String},
+	{Name: "country", Kind: parquet.String},
+	{Name: "click_id", Kind: parquet.String},
+}
+
+// configureClickPartitions partitions the clicks table by month when
+// CLICK_PARTITIONING is set and schedules the job keeping partitions ahead
+// of time and expiring them after CLICK_RETENTION_DAYS
+func configureClickPartitions(cfg *config.Config) error {
+	if !cfg.Database.ClickPartitioning {
+		return nil
+	}
+	converted, err := database.PartitionClicks(context.Background())
+	if err != nil {
+		return err
+	}
+	if converted {
+		log.Println("Partitioned the clicks table by month")
+	}
+	if cfg.Privacy.ClickRetentionDays > 0 && objectStore == nil {
+		log.Println("Warning: CLICK_PARTITIONING is set without an OBJECT_STORE; expired partitions are rolled up and dropped without archiving their click events")
+	}
+
+	retention := cfg.Privacy.ClickRetentionDays
+	backgroundJobs.Schedule(jobs.Job{Name: "click-partitions", Every: clickPartitionInterval, RunAtStart: true, Run: func(ctx context.Context) error {
+		return maintainClickPartitions(ctx, retention)
+	}})
+	return nil
+}
+
+// maintainClickPartitions creates the partitions of the coming months and,
+// with a retention of more than zero days, rolls up and detaches those
+// whose clicks are all older than it. Detached partitions are archived to
+// the object store and dropped; one that fails to archive is retried on the
+// next run.
+func maintainClickPartitions(ctx context.Context, retentionDays int) error {
+	partitions, err := database.ClickPartitions(ctx)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+	month := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i <= clickPartitionsAhead; i++ {
+		m := month.AddDate(0, i, 0)
+		// Months up to the first monthly partition are held by the legacy one
+		if len(partitions) > 0 && partitions[0].Start.IsZero() && m.Before(partitions[0].End) {
+			continue
+		}
+		if err := database.CreateClickPartition(ctx, m); err != nil {
+			return fmt.Errorf("create partition for %s: %w", m.Format("2006-01"), err)
+		}
+	}
+
+	if retentionDays > 0 {
+		cutoff := now.AddDate(0, 0, -retentionDays)
+		for _, p := range partitions {
+			if p.End.After(cutoff) {
+				break
+			}
+			if err := database.DetachClickPartition(ctx, p.Name); err != nil {
+				return fmt.Errorf("detach %s: %w", p.Name, err)
+			}
+			log.Printf("Rolled up and detached click partition %s", p.Name)
+		}
+	}
+
+	detached, err := database.DetachedClickPartitions(ctx)
+	if err != nil {
+		return err
+	}
+	var failed []error
+	for _, name := range detached {
+		if err := archiveClickPartition(ctx, name); err != nil {
+			failed = append(failed, fmt.Errorf("archive %s: %w", name, err))
+			continue
+		}
+		if err := database.DropClickPartition(ctx, name); err != nil {
+			failed = append(failed, fmt.Errorf("drop %s: %w", name, err))
+		}
+	}
+	return errors.Join(failed...)
+}
+
+// archiveClickPartition writes the click events of a detached partition to
+// the object store as one Parquet file per day, partitioned like warehouse
+// exports: archive/clicks/day=2025-01-31/clicks.parquet. Without an object
+// store there is nothing to do, and the events only live on in the rollups.
+func archiveClickPartition(ctx context.Context, name string) error {
+	if objectStore == nil {
+		return nil
+	}
+
+	var table *parquet.Table
+	var day time.Time
+	flush := func() error {
+		if table == nil {
+			return nil
+		}
+		data, err := table.Encode()
+		if err != nil {
+			return err
+		}
+		key := path.Join("archive/clicks", "day="+day.Format(time.DateOnly), "clicks.parquet")
+		return objectStore.Put(ctx, key, data, "application/vnd.apache.parquet")
+	}
+
+	err := database.EachDetachedClick(ctx, name, func(e db.ClickEvent) error {
+		if d := e.ClickedAt.UTC().Truncate(24 * time.Hour); table == nil || !d.Equal(day) {
+			if err := flush(); err != nil {
+				return err
+			}
+			table, day = parquet.NewTable(archivedClickFields...), d
+		}
+		return table.Append(e.ID, e.URLID, e.ClickedAt, e.Device, e.Browser, e.OS, e.Country, e.ClickID)
+	})
+	if err != nil {
+		return err
+	}
+	return flush()
+}
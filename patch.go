@@ -0,0 +1,255 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"slices"
+	"time"
+	"url-shortener/db"
+	"url-shortener/pkg/apierror"
+
+	"github.com/gin-gonic/gin"
+)
+
+// patchableFields are the link fields PATCH /urls/:shortCode accepts
+var patchableFields = []string{
+	"url", "tags", "forwardQuery", "forwardPath", "publicStats", "frame", "trackConversions",
+	"notes", "metadata", "disabled", "disabledReason",
+}
+
+// defaultDisabledReason is recorded for links their owner disables without a reason
+const defaultDisabledReason = "Disabled by its owner"
+
+// validDestination reports whether destination is an absolute http or https URL
+func validDestination(destination string) bool {
+	u, err := url.Parse(destination)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+// mergePatch applies a JSON merge patch (RFC 7396) to target: members of an
+// object patch replace those of target, recursively, and null members remove
+// them. Any other patch replaces target.
+func mergePatch(target, patch json.RawMessage) (json.RawMessage, error) {
+	var patchObject map[string]json.RawMessage
+	if err := json.Unmarshal(patch, &patchObject); err != nil || patchObject == nil {
+		return patch, nil
+	}
+	var targetObject map[string]json.RawMessage
+	if json.Unmarshal(target, &targetObject) != nil || targetObject == nil {
+		targetObject = map[string]json.RawMessage{}
+	}
+	for key, value := range patchObject {
+		if string(value) == "null" {
+			delete(targetObject, key)
+			continue
+		}
+		merged, err := mergePatch(targetObject[key], value)
+		if err != nil {
+			return nil, err
+		}
+		targetObject[key] = merged
+	}
+	return json.Marshal(targetObject)
+}
+
+// patchShortURL changes the fields of a link present in a JSON merge patch
+// and leaves the rest alone, so clients don't overwrite fields they didn't
+// send. Null resets a field: false for options, empty for notes and tags.
+// Metadata is merged into the link's metadata. The updated link is returned.
+func patchShortURL(c *gin.Context) {
+	shortCode := c.Param("shortCode")
+
+	var patch map[string]json.RawMessage
+	if err := c.ShouldBindJSON(&patch); err != nil || patch == nil {
+		respondError(c, apierror.Validation("Body must be a JSON object"))
+		return
+	}
+	// updatedAt is not changed but checked, as a precondition like If-Match
+	var updatedAt *time.Time
+	if raw, ok := patch["updatedAt"]; ok {
+		if err := json.Unmarshal(raw, &updatedAt); err != nil {
+			respondError(c, apierror.Validation("updatedAt must be an RFC 3339 time"))
+			return
+		}
+		delete(patch, "updatedAt")
+	}
+	fields := make([]string, 0, len(patch))
+	for field := range patch {
+		fields = append(fields, field)
+	}
+	slices.Sort(fields)
+	for _, field := range fields {
+		if !slices.Contains(patchableFields, field) {
+			respondError(c, apierror.Validation("Unknown or read-only field: "+field))
+			return
+		}
+	}
+
+	ctx := c.Request.Context()
+	previous, err := database.GetURLByShortCode(ctx, shortCode)
+	if err != nil {
+		respondError(c, notFound(err, "Short URL not found"))
+		return
+	}
+	if previous.Locked {
+		respondError(c, apierror.Locked("Short URL is locked"))
+		return
+	}
+	if editConflict(c, previous, updatedAt) {
+		return
+	}
+
+	var update db.BatchUpdate
+	old, updated := gin.H{}, gin.H{}
+
+	destination := previous.OriginalURL
+	if raw, ok := patch["url"]; ok {
+		if err := json.Unmarshal(raw, &destination); err != nil || !validDestination(destination) {
+			respondError(c, apierror.Validation("url must be an http or https URL"))
+			return
+		}
+		if !allowedDestinations(c, destination) {
+			return
+		}
+		old["original"], updated["original"] = previous.OriginalURL, destination
+	}
+
+	options := []struct {
+		field    string
+		previous bool
+		target   **bool
+	}{
+		{"forwardQuery", previous.ForwardQuery, &update.ForwardQuery},
+		{"forwardPath", previous.ForwardPath, &update.ForwardPath},
+		{"publicStats", previous.PublicStats, &update.PublicStats},
+		{"frame", previous.Frame, &update.Frame},
+		{"trackConversions", previous.TrackConversions, &update.TrackConversions},
+	}
+	for _, option := range options {
+		raw, ok := patch[option.field]
+		if !ok {
+			continue
+		}
+		var value *bool
+		if err := json.Unmarshal(raw, &value); err != nil {
+			respondError(c, apierror.Validation(option.field+" must be a boolean"))
+			return
+		}
+		if value == nil {
+			value = new(bool)
+		}
+		*option.target = value
+		old[option.field], updated[option.field] = option.previous, *value
+	}
+	frame := previous.Frame
+	if update.Frame != nil {
+		frame = *update.Frame
+	}
+	if frame && !frameable(destination) {
+		respondError(c, apierror.Unprocessable("Destination can't be shown in frame mode: it must be an https URL on an allowed host"))
+		return
+	}
+
+	if raw, ok := patch["tags"]; ok {
+		var tags []string
+		if err := json.Unmarshal(raw, &tags); err != nil {
+			respondError(c, apierror.Validation("tags must be a list of tags"))
+			return
+		}
+		if update.Tags, ok = normalizeTags(tags); !ok {
+			respondError(c, apierror.Validation("Invalid tag"))
+			return
+		}
+		old["tags"], updated["tags"] = previous.Tags, update.Tags
+	}
+
+	if raw, ok := patch["notes"]; ok {
+		var notes *string
+		if err := json.Unmarshal(raw, &notes); err != nil {
+			respondError(c, apierror.Validation("notes must be a string"))
+			return
+		}
+		if notes == nil {
+			notes = new(string)
+		}
+		normalized, ok := normalizeNotes(*notes)
+		if !ok {
+			respondError(c, apierror.Validation("notes must be at most 2000 characters"))
+			return
+		}
+		update.Notes = &normalized
+		old["notes"], updated["notes"] = previous.Notes, normalized
+	}
+
+	if raw, ok := patch["metadata"]; ok {
+		merged, err := mergePatch(previous.Metadata, raw)
+		if err != nil {
+			respondError(c, apierror.Validation("Invalid metadata"))
+			return
+		}
+		if update.Metadata, ok = normalizeMetadata(merged); !ok {
+			respondError(c, apierror.Validation("metadata must be a JSON object of at most 4096 bytes"))
+			return
+		}
+		old["metadata"], updated["metadata"] = previous.Metadata, linkMetadata(update.Metadata)
+	}
+
+	_, hasDisabled := patch["disabled"]
+	_, hasReason := patch["disabledReason"]
+	if hasDisabled || hasReason {
+		disabled := previous.Disabled
+		if hasDisabled {
+			var value *bool
+			if err := json.Unmarshal(patch["disabled"], &value); err != nil {
+				respondError(c, apierror.Validation("disabled must be a boolean"))
+				return
+			}
+			disabled = value != nil && *value
+		}
+		var reason string
+		if hasReason {
+			var value *string
+			if err := json.Unmarshal(patch["disabledReason"], &value); err != nil {
+				respondError(c, apierror.Validation("disabledReason must be a string"))
+				return
+			}
+			if value != nil {
+				reason = *value
+			}
+		} else if disabled {
+			reason = previous.DisabledReason
+		}
+		if !disabled && reason != "" {
+			respondError(c, apierror.Validation("disabledReason can only be set on disabled links"))
+			return
+		}
+		if disabled && reason == "" {
+			reason = defaultDisabledReason
+		}
+		update.DisabledReason = &reason
+		old["disabled"], updated["disabled"] = previous.Disabled, disabled
+		old["disabledReason"], updated["disabledReason"] = previous.DisabledReason, reason
+	}
+
+	edit := db.LinkEdit{Update: update}
+	if destination != previous.OriginalURL {
+		edit.Destination = destination
+	}
+	if edit.Destination != "" || !update.Empty() {
+		if err := database.EditLink(ctx, shortCode, editVersion(c, previous, updatedAt), edit, auditActor(c)); err != nil {
+			respondEditError(c, shortCode, err)
+			return
+		}
+	}
+
+	link, err := database.GetURLByShortCode(ctx, shortCode)
+	if err != nil {
+		respondError(c, notFound(err, "Short URL not found"))
+		return
+	}
+
+	recordAudit(c, auditUpdate, "url", shortCode, old, updated)
+	c.Header("ETag", linkETag(link))
+	c.JSON(http.StatusOK, toURLModel(c, link))
+}
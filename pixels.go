@@ -0,0 +1,304 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"url-shortener/db"
+	"url-shortener/pkg/apierror"
+
+	"github.com/gin-gonic/gin"
+)
+
+// pixelRedirectDelay is how long the pixel page waits for the pixels to fire
+// before sending the visitor on
+var pixelRedirectDelay = 500 * time.Millisecond
+
+// pixelProviders maps the providers pixels can be created for to the form of
+// their IDs. Each has a script template, pixel-<provider> in pixels.html,
+// which operators can override like the other page templates.
+var pixelProviders = map[string]*regexp.Regexp{
+	// Meta (Facebook) pixel IDs
+	"facebook": regexp.MustCompile(`^[0-9]{6,20}$`),
+	// Google tag IDs: Analytics (G-), Ads (AW-), Floodlight (DC-) and tags (GT-)
+	"google": regexp.MustCompile(`^(G|AW|DC|GT)-[A-Z0-9]{4,20}$`),
+}
+
+// maxLinkPixels bounds how many pixels a link or campaign fires
+const maxLinkPixels = 10
+
+// linkPixels returns the pixels to fire before link's redirect: its own and
+// its campaign's, as long as they belong to its organization. Visitors who
+// opted out of tracking with Do Not Track or Global Privacy Control get none.
+func linkPixels(c *gin.Context, link *db.URL) []db.Pixel {
+	if link.OrgID == 0 || (len(link.Pixels) == 0 && len(link.CampaignPixels) == 0) {
+		return nil
+	}
+	if c.GetHeader("DNT") == "1" || c.GetHeader("Sec-GPC") == "1" {
+		return nil
+	}
+
+	ids := append(slices.Clone(link.Pixels), link.CampaignPixels...)
+	slices.Sort(ids)
+	pixels, err := database.GetPixelsByID(c.Request.Context(), link.OrgID, slices.Compact(ids))
+	if err != nil {
+		log.Printf("Failed to load pixels of %s: %v", link.ShortCode, err)
+		return nil
+	}
+	return pixels
+}
+
+// firePixels serves a page that runs the script of each pixel and then
+// forwards the visitor to destination, reporting whether it was served. The
+// page's script only ever redirects to http(s) URLs; other destinations,
+// stored before they were checked, get a plain redirect without pixels.
+func firePixels(c *gin.Context, destination string, pixels []db.Pixel) bool {
+	if len(pixels) == 0 || !validDestination(destination) {
+		return false
+	}
+
+	// Like a redirect, the page may lead to a different destination on the
+	// next click, so it must not be reused
+	c.Header("Cache-Control", "no-store")
+	c.HTML(http.StatusOK, "pixel.html", gin.H{
+		"pixels":      pixels,
+		"destination": destination,
+		"delay":       pixelRedirectDelay.Milliseconds(),
+	})
+	return true
+}
+
+// normalizePixelIDs removes duplicates from the pixel IDs of a request
+func normalizePixelIDs(ids []int64) ([]int64, bool) {
+	ids = slices.Clone(ids)
+	slices.Sort(ids)
+	ids = slices.Compact(ids)
+	if len(ids) > maxLinkPixels || (len(ids) > 0 && ids[0] <= 0) {
+		return nil, false
+	}
+	if ids == nil {
+		ids = []int64{}
+	}
+	return ids, true
+}
+
+// pixelWriter returns the :orgId organization, writing an error unless the
+// caller may change its links
+func pixelWriter(c *gin.Context) (int, bool) {
+	orgID, ok := orgIDParam(c)
+	if !ok {
+		return 0, false
+	}
+	role, ok := orgRole(c, orgID)
+	if !ok {
+		return 0, false
+	}
+	if !canWriteOrgLinks(role) {
+		respondError(c, apierror.Forbidden("Your role does not allow managing pixels"))
+		return 0, false
+	}
+	return orgID, true
+}
+
+// getOrgPixels lists an organization's pixels to its members
+func getOrgPixels(c *gin.Context) {
+	orgID, ok := orgIDParam(c)
+	if !ok {
+		return
+	}
+	if _, ok := orgRole(c, orgID); !ok {
+		return
+	}
+
+	pixels, err := database.GetPixels(c.Request.Context(), orgID)
+	if err != nil {
+		respondError(c, apierror.Internal("Database error").Wrap(err))
+		return
+	}
+	c.JSON(http.StatusOK, pixels)
+}
+
+// createOrgPixel adds a pixel to an organization, to be attached to its links
+// and campaigns
+func createOrgPixel(c *gin.Context) {
+	orgID, ok := pixelWriter(c)
+	if !ok {
+		return
+	}
+
+	var request struct {
+		Name     string `json:"name"`
+		Provider string `json:"provider" binding:"required"`
+		PixelID  string `json:"pixelId" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		respondError(c, apierror.Validation("provider and pixelId are required"))
+		return
+	}
+	pattern, ok := pixelProviders[request.Provider]
+	if !ok {
+		respondError(c, apierror.Validation("provider must be facebook or google"))
+		return
+	}
+	pixel := db.Pixel{
+		OrgID:    orgID,
+		Name:     strings.TrimSpace(request.Name),
+		Provider: request.Provider,
+		PixelID:  strings.TrimSpace(request.PixelID),
+	}
+	if !pattern.MatchString(pixel.PixelID) {
+		respondError(c, apierror.Validation("Invalid "+request.Provider+" pixel ID"))
+		return
+	}
+	if pixel.Name == "" {
+		pixel.Name = pixel.Provider + " " + pixel.PixelID
+	}
+
+	if err := database.CreatePixel(c.Request.Context(), &pixel); err != nil {
+		respondError(c, apierror.Internal("Failed to store pixel").Wrap(err))
+		return
+	}
+
+	recordAudit(c, auditCreate, "pixel", strconv.Itoa(pixel.ID), nil, pixel)
+	c.JSON(http.StatusCreated, pixel)
+}
+
+// deleteOrgPixel removes a pixel, detaching it from the links and campaigns it fired on
+func deleteOrgPixel(c *gin.Context) {
+	orgID, ok := pixelWriter(c)
+	if !ok {
+		return
+	}
+	id, err := strconv.Atoi(c.Param("pixelId"))
+	if err != nil {
+		respondError(c, apierror.NotFound("Pixel not found"))
+		return
+	}
+
+	if err := database.DeletePixel(c.Request.Context(), orgID, id); err != nil {
+		respondError(c, notFound(err, "Pixel not found"))
+		return
+	}
+
+	recordAudit(c, auditDelete, "pixel", strconv.Itoa(id), nil, nil)
+	c.JSON(http.StatusOK, gin.H{"message": "Pixel deleted successfully"})
+}
+
+// setURLPixels replaces the pixels fired before a link's redirect; they must
+// belong to the link's organization. An empty list removes them.
+func setURLPixels(c *gin.Context) {
+	shortCode := c.Param("shortCode")
+
+	var request struct {
+		Pixels []int64 `json:"pixels"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		respondError(c, apierror.Validation("Invalid request body"))
+		return
+	}
+	ids, ok := normalizePixelIDs(request.Pixels)
+	if !ok {
+		respondError(c, apierror.Validation("pixels must be at most "+strconv.Itoa(maxLinkPixels)+" pixel IDs"))
+		return
+	}
+
+	ctx := c.Request.Context()
+	previous, err := database.GetURLByShortCode(ctx, shortCode)
+	if err != nil {
+		respondError(c, notFound(err, "Short URL not found"))
+		return
+	}
+	if len(ids) > 0 {
+		if previous.OrgID == 0 {
+			respondError(c, apierror.Validation("Pixels can only be attached to links of an organization"))
+			return
+		}
+		pixels, err := database.GetPixelsByID(ctx, previous.OrgID, ids)
+		if err != nil {
+			respondError(c, apierror.Internal("Database error").Wrap(err))
+			return
+		}
+		if len(pixels) != len(ids) {
+			respondError(c, apierror.Validation("Unknown pixel: pixels must belong to the link's organization"))
+			return
+		}
+	}
+
+	if err := database.SetURLPixels(ctx, shortCode, ids); err != nil {
+		respondError(c, notFound(err, "Short URL not found"))
+		return
+	}
+
+	recordAudit(c, auditUpdate, "url", shortCode, gin.H{"pixels": previous.Pixels}, gin.H{"pixels": ids})
+	c.JSON(http.StatusOK, gin.H{"message": "Pixels updated successfully", "pixels": ids})
+}
+
+// setCampaignPixels replaces the pixels fired before the redirects of a
+// campaign's links. The caller must be able to change links of the
+// organization of each pixel, which only fire on that organization's links.
+func setCampaignPixels(c *gin.Context) {
+	campaign, ok := campaignAccess(c)
+	if !ok {
+		return
+	}
+
+	var request struct {
+		Pixels []int64 `json:"pixels"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		respondError(c, apierror.Validation("Invalid request body"))
+		return
+	}
+	ids, ok := normalizePixelIDs(request.Pixels)
+	if !ok {
+		respondError(c, apierror.Validation("pixels must be at most "+strconv.Itoa(maxLinkPixels)+" pixel IDs"))
+		return
+	}
+
+	ctx := c.Request.Context()
+	pixels, err := database.GetPixelsByID(ctx, 0, ids)
+	if err != nil {
+		respondError(c, apierror.Internal("Database error").Wrap(err))
+		return
+	}
+	if len(pixels) != len(ids) {
+		respondError(c, apierror.Validation("Unknown pixel"))
+		return
+	}
+	if !isAdmin(c) {
+		checked := map[int]bool{}
+		for _, pixel := range pixels {
+			if checked[pixel.OrgID] {
+				continue
+			}
+			checked[pixel.OrgID] = true
+			role, ok := orgRole(c, pixel.OrgID)
+			if !ok {
+				return
+			}
+			if !canWriteOrgLinks(role) {
+				respondError(c, apierror.Forbidden("Your role does not allow using the pixels of organization "+strconv.Itoa(pixel.OrgID)))
+				return
+			}
+		}
+	}
+
+	err = database.SetCampaignPixels(ctx, campaign.ID, ids)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondError(c, apierror.NotFound("Campaign not found"))
+		return
+	}
+	if err != nil {
+		respondError(c, apierror.Internal("Database error").Wrap(err))
+		return
+	}
+
+	recordAudit(c, auditUpdate, "campaign", strconv.Itoa(campaign.ID), gin.H{"pixels": campaign.Pixels}, gin.H{"pixels": ids})
+	c.JSON(http.StatusOK, gin.H{"message": "Pixels updated successfully", "pixels": ids})
+}
@@ -0,0 +1,185 @@
+package apierror
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Codes identify the kind of failure, so clients can branch on them instead
+// of parsing messages
+const (
+	CodeValidation    = "validation_failed"
+	CodeUnauthorized  = "unauthorized"
+	CodeForbidden     = "forbidden"
+	CodeNotFound      = "not_found"
+	CodeNotAcceptable = "not_acceptable"
+	CodeConflict      = "conflict"
+	CodeGone          = "gone"
+	CodeTooLarge      = "payload_too_large"
+	CodeUnprocessable = "unprocessable"
+	CodeLocked        = "locked"
+	CodeRateLimited   = "rate_limited"
+	CodeQuotaExceeded = "quota_exceeded"
+	CodeInternal      = "internal_error"
+	CodeUpstream      = "upstream_error"
+	CodeUnavailable   = "unavailable"
+)
+
+// ContextRequestID is the context key holding the ID errors are reported under
+const ContextRequestID = "requestID"
+
+// Error is a failure to report to the client. Message is shown to the client;
+// the underlying Err, if any, is only logged.
+type Error struct {
+	Status  int
+	Code    string
+	Message string
+	Details any
+	Err     error
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return e.Message + ": " + e.Err.Error()
+	}
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// WithDetails attaches structured data, such as the offending values, to the response
+func (e *Error) WithDetails(details any) *Error {
+	e.Details = details
+	return e
+}
+
+// Wrap records the cause of the failure for the log
+func (e *Error) Wrap(err error) *Error {
+	e.Err = err
+	return e
+}
+
+func newError(status int, code, message string) *Error {
+	return &Error{Status: status, Code: code, Message: message}
+}
+
+// Validation reports a malformed request or invalid input (400)
+func Validation(message string) *Error {
+	return newError(http.StatusBadRequest, CodeValidation, message)
+}
+
+// Unauthorized reports missing or invalid credentials (401)
+func Unauthorized(message string) *Error {
+	return newError(http.StatusUnauthorized, CodeUnauthorized, message)
+}
+
+// PaymentRequired reports a request over the limits of the caller's plan,
+// lifted by moving to a larger plan (402)
+func PaymentRequired(message string) *Error {
+	return newError(http.StatusPaymentRequired, CodeQuotaExceeded, message)
+}
+
+// QuotaExceeded reports a caller that used up its plan's allowance for the
+// current period (429)
+func QuotaExceeded(message string) *Error {
+	return newError(http.StatusTooManyRequests, CodeQuotaExceeded, message)
+}
+
+// Forbidden reports credentials that do not allow the request (403)
+func Forbidden(message string) *Error {
+	return newError(http.StatusForbidden, CodeForbidden, message)
+}
+
+// NotFound reports a resource that does not exist (404)
+func NotFound(message string) *Error {
+	return newError(http.StatusNotFound, CodeNotFound, message)
+}
+
+// NotAcceptable reports a representation or API version that is not served (406)
+func NotAcceptable(message string) *Error {
+	return newError(http.StatusNotAcceptable, CodeNotAcceptable, message)
+}
+
+// Conflict reports a request clashing with existing state, such as a duplicate (409)
+func Conflict(message string) *Error {
+	return newError(http.StatusConflict, CodeConflict, message)
+}
+
+// Gone reports a resource that no longer serves requests (410)
+func Gone(message string) *Error {
+	return newError(http.StatusGone, CodeGone, message)
+}
+
+// TooLarge reports a request body over the size limit (413)
+func TooLarge(message string) *Error {
+	return newError(http.StatusRequestEntityTooLarge, CodeTooLarge, message)
+}
+
+// Unprocessable reports well-formed input that is refused, such as a blocked destination (422)
+func Unprocessable(message string) *Error {
+	return newError(http.StatusUnprocessableEntity, CodeUnprocessable, message)
+}
+
+// Locked reports a resource that may not be changed (423)
+func Locked(message string) *Error {
+	return newError(http.StatusLocked, CodeLocked, message)
+}
+
+// RateLimited reports a client over its request budget (429)
+func RateLimited(message string) *Error {
+	return newError(http.StatusTooManyRequests, CodeRateLimited, message)
+}
+
+// Internal reports a failure on our side (500)
+func Internal(message string) *Error {
+	return newError(http.StatusInternalServerError, CodeInternal, message)
+}
+
+// Upstream reports a failure of a service the request depends on (502)
+func Upstream(message string) *Error {
+	return newError(http.StatusBadGateway, CodeUpstream, message)
+}
+
+// Unavailable reports a feature or the service being unavailable (503)
+func Unavailable(message string) *Error {
+	return newError(http.StatusServiceUnavailable, CodeUnavailable, message)
+}
+
+// Body is the JSON envelope every failed request is answered with
+type Body struct {
+	Error Detail `json:"error"`
+}
+
+// Detail describes a failure
+type Detail struct {
+	Code      string `json:"code"`
+	Message   string `json:"message"`
+	RequestID string `json:"requestId,omitempty"`
+	Details   any    `json:"details,omitempty"`
+}
+
+// Abort answers the request with err and stops the handler chain. Errors
+// other than *Error are reported as internal errors without exposing their text.
+func Abort(c *gin.Context, err error) {
+	var e *Error
+	if !errors.As(err, &e) {
+		e = Internal("Internal server error").Wrap(err)
+	}
+
+	requestID := c.GetString(ContextRequestID)
+	if e.Status >= http.StatusInternalServerError && e.Err != nil {
+		log.Printf("%s %s failed (request %s): %v", c.Request.Method, c.Request.URL.Path, requestID, e.Err)
+	}
+
+	c.AbortWithStatusJSON(e.Status, Body{Detail{
+		Code:      e.Code,
+		Message:   e.Message,
+		RequestID: requestID,
+		Details:   e.Details,
+	}})
+}
@@ -0,0 +1,107 @@
+package backup
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"time"
+	"url-shortener/db"
+	"url-shortener/pkg/objectstore"
+)
+
+// Latest is the key of the object naming the most recent complete backup
+const Latest = "backups/latest"
+
+// Manifest is written last, so a backup without one is incomplete
+type Manifest struct {
+	CreatedAt time.Time `json:"createdAt"`
+	Tables    []string  `json:"tables"`
+}
+
+// Create dumps the link and analytics tables to store as gzip-compressed CSV
+// under backups/<UTC timestamp>/ and returns that prefix
+func Create(ctx context.Context, database *db.Database, store objectstore.Store) (string, error) {
+	now := time.Now().UTC()
+	prefix := "backups/" + now.Format("20060102T150405Z")
+
+	err := database.DumpTables(ctx, func(table string) (io.WriteCloser, error) {
+		w := &objectWriter{ctx: ctx, store: store, key: tableKey(prefix, table)}
+		w.gz = gzip.NewWriter(&w.buf)
+		return w, nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	manifest, err := json.MarshalIndent(Manifest{CreatedAt: now, Tables: db.BackupTables}, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	if err := store.Put(ctx, path.Join(prefix, "manifest.json"), manifest, "application/json"); err != nil {
+		return "", err
+	}
+	if err := store.Put(ctx, Latest, []byte(prefix), "text/plain"); err != nil {
+		return "", err
+	}
+	return prefix, nil
+}
+
+// Restore loads the backup under prefix, or the most recent one when prefix
+// is empty, into the database. With truncate unset the tables must be empty.
+func Restore(ctx context.Context, database *db.Database, store objectstore.Store, prefix string, truncate bool) (string, error) {
+	if prefix == "" {
+		latest, err := store.Get(ctx, Latest)
+		if err != nil {
+			return "", fmt.Errorf("find latest backup: %w", err)
+		}
+		prefix = strings.TrimSpace(string(latest))
+	}
+	prefix = strings.TrimRight(prefix, "/")
+
+	data, err := store.Get(ctx, path.Join(prefix, "manifest.json"))
+	if err != nil {
+		return "", fmt.Errorf("read manifest of %s: %w", prefix, err)
+	}
+	var manifest Manifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return "", fmt.Errorf("read manifest of %s: %w", prefix, err)
+	}
+
+	err = database.LoadTables(ctx, manifest.Tables, func(table string) (io.ReadCloser, error) {
+		data, err := store.Get(ctx, tableKey(prefix, table))
+		if err != nil {
+			return nil, err
+		}
+		return gzip.NewReader(bytes.NewReader(data))
+	}, truncate)
+	return prefix, err
+}
+
+func tableKey(prefix, table string) string {
+	return path.Join(prefix, table+".csv.gz")
+}
+
+// objectWriter compresses a table dump in memory and uploads it on Close
+type objectWriter struct {
+	ctx   context.Context
+	store objectstore.Store
+	key   string
+	buf   bytes.Buffer
+	gz    *gzip.Writer
+}
+
+func (w *objectWriter) Write(p []byte) (int, error) {
+	return w.gz.Write(p)
+}
+
+func (w *objectWriter) Close() error {
+	if err := w.gz.Close(); err != nil {
+		return err
+	}
+	return w.store.Put(w.ctx, w.key, w.buf.Bytes(), "application/gzip")
+}
@@ -0,0 +1,58 @@
+package base62
+
+import (
+	"errors"
+	"math"
+	"strings"
+)
+
+// Alphabet is the digit set used for short codes, in ascending digit value
+const Alphabet = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+
+const base = uint64(len(Alphabet))
+
+var (
+	// ErrInvalidCode is returned when a code is empty or contains characters outside the alphabet
+	ErrInvalidCode = errors.New("invalid base62 code")
+	// ErrOverflow is returned when a code decodes to a value larger than math.MaxInt64
+	ErrOverflow = errors.New("base62 code overflows int64")
+)
+
+// EncodeID converts a non-negative ID into its base62 code. Zero encodes as the
+// first alphabet character so every ID has a non-empty code; negative IDs encode as "".
+func EncodeID(id int64) string {
+	if id < 0 {
+		return ""
+	}
+	if id == 0 {
+		return Alphabet[:1]
+	}
+
+	var buf [11]byte // ceil(log62(2^63))
+	i := len(buf)
+	for n := uint64(id); n > 0; n /= base {
+		i--
+		buf[i] = Alphabet[n%base]
+	}
+	return string(buf[i:])
+}
+
+// DecodeCode converts a base62 code back into the ID it encodes
+func DecodeCode(code string) (int64, error) {
+	if code == "" {
+		return 0, ErrInvalidCode
+	}
+
+	var n uint64
+	for i := 0; i < len(code); i++ {
+		digit := strings.IndexByte(Alphabet, code[i])
+		if digit < 0 {
+			return 0, ErrInvalidCode
+		}
+		if n > (math.MaxInt64-uint64(digit))/base {
+			return 0, ErrOverflow
+		}
+		n = n*base + uint64(digit)
+	}
+	return int64(n), nil
+}
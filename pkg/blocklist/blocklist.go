@@ -0,0 +1,78 @@
+package blocklist
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// Rule kinds
+const (
+	// Domain blocks a host and all of its subdomains
+	Domain = "domain"
+	// Regex blocks destinations whose full URL matches a regular expression
+	Regex = "regex"
+)
+
+// List matches destination URLs against blocked domains and patterns
+type List struct {
+	domains  []string
+	patterns []*regexp.Regexp
+}
+
+// Rule is a single blocked domain or pattern
+type Rule struct {
+	Kind    string
+	Pattern string
+}
+
+// New compiles rules into a list, failing on an unknown kind or invalid pattern
+func New(rules []Rule) (*List, error) {
+	l := &List{}
+	for _, r := range rules {
+		switch r.Kind {
+		case Domain:
+			l.domains = append(l.domains, NormalizeDomain(r.Pattern))
+		case Regex:
+			re, err := regexp.Compile(r.Pattern)
+			if err != nil {
+				return nil, fmt.Errorf("invalid pattern %q: %w", r.Pattern, err)
+			}
+			l.patterns = append(l.patterns, re)
+		default:
+			return nil, fmt.Errorf("unknown rule kind %q", r.Kind)
+		}
+	}
+	return l, nil
+}
+
+// NormalizeDomain lowercases a domain and strips a leading "*." or "."
+func NormalizeDomain(domain string) string {
+	domain = strings.ToLower(strings.TrimSpace(domain))
+	domain = strings.TrimPrefix(domain, "*.")
+	return strings.TrimPrefix(domain, ".")
+}
+
+// Match returns the rule blocking rawURL, if any. A nil list blocks nothing.
+func (l *List) Match(rawURL string) (string, bool) {
+	if l == nil {
+		return "", false
+	}
+
+	if u, err := url.Parse(rawURL); err == nil {
+		host := strings.TrimSuffix(strings.ToLower(u.Hostname()), ".")
+		for _, d := range l.domains {
+			if host == d || strings.HasSuffix(host, "."+d) {
+				return d, true
+			}
+		}
+	}
+
+	for _, re := range l.patterns {
+		if re.MatchString(rawURL) {
+			return re.String(), true
+		}
+	}
+	return "", false
+}
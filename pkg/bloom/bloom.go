@@ -0,0 +1,90 @@
+package bloom
+
+import (
+	"hash/maphash"
+	"math"
+	"sync"
+)
+
+// Filter is a concurrency-safe Bloom filter over strings. Test never reports
+// false for an added key; it reports true for a key never added at roughly
+// the false positive rate the filter was sized for, as long as no more than
+// its capacity of keys has been added.
+type Filter struct {
+	mu       sync.RWMutex
+	bits     []uint64
+	hashes   int
+	count    int
+	capacity int
+	seed     maphash.Seed
+}
+
+// New sizes a filter to hold capacity keys at the given false positive rate
+func New(capacity int, falsePositiveRate float64) *Filter {
+	capacity = max(capacity, 1)
+	if falsePositiveRate <= 0 || falsePositiveRate >= 1 {
+		falsePositiveRate = 0.01
+	}
+
+	// m = -n ln p / (ln 2)^2 bits and k = m/n ln 2 hash functions
+	m := math.Ceil(-float64(capacity) * math.Log(falsePositiveRate) / (math.Ln2 * math.Ln2))
+	k := max(1, int(math.Round(m/float64(capacity)*math.Ln2)))
+	return &Filter{
+		bits:     make([]uint64, (int(m)+63)/64),
+		hashes:   k,
+		capacity: capacity,
+		seed:     maphash.MakeSeed(),
+	}
+}
+
+// Add records key as present. Keys added again, and the rare new key whose
+// bits were all set already, don't count towards the capacity.
+func (f *Filter) Add(key string) {
+	h1, h2 := f.hash(key)
+	n := uint64(len(f.bits) * 64)
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	added := false
+	for i := 0; i < f.hashes; i++ {
+		bit := (h1 + uint64(i)*h2) % n
+		mask := uint64(1) << (bit % 64)
+		if f.bits[bit/64]&mask == 0 {
+			f.bits[bit/64] |= mask
+			added = true
+		}
+	}
+	if added {
+		f.count++
+	}
+}
+
+// Test reports whether key may have been added
+func (f *Filter) Test(key string) bool {
+	h1, h2 := f.hash(key)
+	n := uint64(len(f.bits) * 64)
+
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	for i := 0; i < f.hashes; i++ {
+		bit := (h1 + uint64(i)*h2) % n
+		if f.bits[bit/64]&(1<<(bit%64)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// Full reports whether more keys than the filter was sized for have been
+// added, after which its false positive rate climbs
+func (f *Filter) Full() bool {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return f.count > f.capacity
+}
+
+// hash derives the two base hashes combined into each of the k bit positions
+func (f *Filter) hash(key string) (uint64, uint64) {
+	h := maphash.String(f.seed, key)
+	return h, h>>32 | h<<32 | 1
+}
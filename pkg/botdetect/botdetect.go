@@ -0,0 +1,103 @@
+// Package botdetect classifies requests as automated based on their User-Agent
+package botdetect
+
+import (
+	_ "embed"
+	"strings"
+)
+
+//go:embed patterns.txt
+var defaultPatterns string
+
+// DefaultPatterns returns the maintained list of bot User-Agent substrings
+func DefaultPatterns() []string {
+	var patterns []string
+	for _, line := range strings.Split(defaultPatterns, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		patterns = append(patterns, line)
+	}
+	return patterns
+}
+
+// unfurlers are User-Agent substrings of the social networks and chat apps
+// that fetch a shared link to build its preview
+var unfurlers = []string{
+	"facebookexternalhit",
+	"facebookcatalog",
+	"twitterbot",
+	"linkedinbot",
+	"slackbot",
+	"slack-imgproxy",
+	"discordbot",
+	"telegrambot",
+	"whatsapp",
+	"skypeuripreview",
+	"pinterest",
+	"redditbot",
+	"embedly",
+	"iframely",
+	"vkshare",
+	"mastodon",
+	"cardyb",
+}
+
+// IsUnfurler reports whether the User-Agent belongs to a social network or
+// chat app building a link preview, as opposed to any other bot
+func IsUnfurler(userAgent string) bool {
+	ua := strings.ToLower(userAgent)
+	for _, p := range unfurlers {
+		if strings.Contains(ua, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// Detector matches User-Agents against bot patterns. Allow patterns take
+// precedence, so a deployment can exempt a client the default list catches.
+type Detector struct {
+	patterns []string
+	allow    []string
+}
+
+// New builds a detector from the default patterns plus extra ones, with allow
+// patterns overriding both
+func New(extra, allow []string) *Detector {
+	return &Detector{
+		patterns: lower(append(DefaultPatterns(), extra...)),
+		allow:    lower(allow),
+	}
+}
+
+// IsBot reports whether the User-Agent belongs to a crawler, unfurler or script.
+// Requests without a User-Agent are treated as automated.
+func (d *Detector) IsBot(userAgent string) bool {
+	ua := strings.ToLower(strings.TrimSpace(userAgent))
+	if ua == "" {
+		return true
+	}
+	for _, p := range d.allow {
+		if strings.Contains(ua, p) {
+			return false
+		}
+	}
+	for _, p := range d.patterns {
+		if strings.Contains(ua, p) {
+			return true
+		}
+	}
+	return false
+}
+
+func lower(patterns []string) []string {
+	out := make([]string, 0, len(patterns))
+	for _, p := range patterns {
+		if p = strings.ToLower(strings.TrimSpace(p)); p != "" {
+			out = append(out, p)
+		}
+	}
+	return out
+}
@@ -0,0 +1,107 @@
+// Package cdn purges cached responses from a CDN through its API
+package cdn
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// API endpoints of the supported providers
+const (
+	cloudflareAPI = "https://api.cloudflare.com/client/v4"
+	fastlyAPI     = "https://api.fastly.com"
+)
+
+// Purger evicts responses tagged with cache keys from a CDN. Responses are
+// tagged through the Cache-Tag header on Cloudflare and the Surrogate-Key
+// header on Fastly.
+type Purger struct {
+	client   *http.Client
+	provider string
+	// zone is the Cloudflare zone ID or the Fastly service ID
+	zone  string
+	token string
+}
+
+// New creates a purger for provider ("cloudflare" or "fastly")
+func New(provider, zone, token string, timeout time.Duration) (*Purger, error) {
+	provider = strings.ToLower(provider)
+	if provider != "cloudflare" && provider != "fastly" {
+		return nil, fmt.Errorf("unknown cdn provider %q", provider)
+	}
+	if zone == "" || token == "" {
+		return nil, fmt.Errorf("cdn provider %s needs a zone and an API token", provider)
+	}
+
+	return &Purger{
+		client:   &http.Client{Timeout: timeout},
+		provider: provider,
+		zone:     zone,
+		token:    token,
+	}, nil
+}
+
+// Purge evicts the responses tagged with any of keys, or everything cached
+// for the zone when no keys are given
+func (p *Purger) Purge(ctx context.Context, keys ...string) error {
+	if p.provider == "fastly" {
+		return p.purgeFastly(ctx, keys)
+	}
+	return p.purgeCloudflare(ctx, keys)
+}
+
+func (p *Purger) purgeCloudflare(ctx context.Context, keys []string) error {
+	body := map[string]any{"purge_everything": true}
+	if len(keys) > 0 {
+		body = map[string]any{"tags": keys}
+	}
+	encoded, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, cloudflareAPI+"/zones/"+p.zone+"/purge_cache", bytes.NewReader(encoded))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+p.token)
+	req.Header.Set("Content-Type", "application/json")
+	return p.do(req)
+}
+
+func (p *Purger) purgeFastly(ctx context.Context, keys []string) error {
+	endpoint := fastlyAPI + "/service/" + p.zone + "/purge_all"
+	if len(keys) > 0 {
+		endpoint = fastlyAPI + "/service/" + p.zone + "/purge"
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Fastly-Key", p.token)
+	if len(keys) > 0 {
+		req.Header.Set("Surrogate-Key", strings.Join(keys, " "))
+	}
+	return p.do(req)
+}
+
+func (p *Purger) do(req *http.Request) error {
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s purge returned %s", p.provider, resp.Status)
+	}
+	return nil
+}
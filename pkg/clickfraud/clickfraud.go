@@ -0,0 +1,167 @@
+package clickfraud
+
+import (
+	"net/netip"
+	"sync"
+	"time"
+	"url-shortener/pkg/limiter"
+)
+
+// Reasons a click is considered suspicious
+const (
+	// IPHammering is a single IP clicking the same link over and over
+	IPHammering = "ip_hammering"
+	// Datacenter is a click from a hosting or cloud provider's network, where
+	// browsers of real visitors rarely run
+	Datacenter = "datacenter_ip"
+	// Spike is a link suddenly receiving many times its usual traffic
+	Spike = "traffic_spike"
+)
+
+// Config tunes the detector
+type Config struct {
+	// IPLimit clicks per IPWindow from one IP on one link are allowed before
+	// further clicks count as hammering
+	IPLimit  int
+	IPWindow time.Duration
+	// SpikeFactor is how many times a link's usual clicks per minute make a
+	// spike; minutes with fewer than SpikeMinClicks clicks never do
+	SpikeFactor    float64
+	SpikeMinClicks int
+	// Datacenters are networks whose clicks are excluded
+	Datacenters []netip.Prefix
+}
+
+// Verdict is the outcome of checking a click. The zero value is a normal click.
+type Verdict struct {
+	// Reason names the anomaly, empty for a normal click
+	Reason string
+	// Exclude is set when the click must not be counted in the link's stats.
+	// Spikes are only flagged, since a link going viral looks the same.
+	Exclude bool
+}
+
+// baselineWeight is how much each finished minute moves a link's baseline
+const baselineWeight = 0.1
+
+// idleAfter is how long a link's traffic rate is kept after its last click
+const idleAfter = time.Hour
+
+// Detector tracks click rates in memory, so each instance judges the traffic it serves
+type Detector struct {
+	cfg   Config
+	perIP limiter.Limiter
+
+	mu        sync.Mutex
+	rates     map[string]*rate
+	lastSweep time.Time
+}
+
+// rate is a link's clicks in the current minute and its usual clicks per minute
+type rate struct {
+	minute   int64
+	clicks   int
+	baseline float64
+	flagged  bool
+}
+
+// New creates a detector
+func New(cfg Config) *Detector {
+	return &Detector{
+		cfg:       cfg,
+		perIP:     limiter.NewSlidingWindow(cfg.IPLimit, cfg.IPWindow),
+		rates:     make(map[string]*rate),
+		lastSweep: time.Now(),
+	}
+}
+
+// Check judges a click on shortCode from ip
+func (d *Detector) Check(shortCode, ip string) Verdict {
+	if d.fromDatacenter(ip) {
+		return Verdict{Reason: Datacenter, Exclude: true}
+	}
+	if !d.perIP.Allow(ip + "|" + shortCode) {
+		return Verdict{Reason: IPHammering, Exclude: true}
+	}
+	if d.spiking(shortCode, time.Now()) {
+		return Verdict{Reason: Spike}
+	}
+	return Verdict{}
+}
+
+func (d *Detector) fromDatacenter(ip string) bool {
+	if len(d.cfg.Datacenters) == 0 {
+		return false
+	}
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, p := range d.cfg.Datacenters {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// spiking counts a click and reports whether it is the one that pushed the
+// current minute over the spike threshold, so each spike is flagged once
+func (d *Detector) spiking(shortCode string, now time.Time) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.sweep(now)
+
+	minute := now.Unix() / 60
+	r, ok := d.rates[shortCode]
+	if !ok {
+		r = &rate{minute: minute}
+		d.rates[shortCode] = r
+	}
+
+	if minute != r.minute {
+		// Fold the finished minute into the baseline, then decay it for
+		// every minute without clicks since
+		r.baseline += baselineWeight * (float64(r.clicks) - r.baseline)
+		for idle := min(minute-r.minute-1, 60); idle > 0; idle-- {
+			r.baseline -= baselineWeight * r.baseline
+		}
+		r.minute, r.clicks, r.flagged = minute, 0, false
+	}
+
+	r.clicks++
+	if r.flagged || r.clicks < d.cfg.SpikeMinClicks || float64(r.clicks) <= d.cfg.SpikeFactor*r.baseline {
+		return false
+	}
+	r.flagged = true
+	return true
+}
+
+// Cleanup drops the per-IP counts of addresses that stopped clicking
+func (d *Detector) Cleanup() {
+	d.perIP.Cleanup()
+}
+
+// Keys reports how many client IPs and links the detector keeps state for
+func (d *Detector) Keys() (ips, links int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.perIP.Keys(), len(d.rates)
+}
+
+// sweep drops the rates of links without clicks for idleAfter
+func (d *Detector) sweep(now time.Time) {
+	if now.Sub(d.lastSweep) < idleAfter {
+		return
+	}
+	d.lastSweep = now
+
+	cutoff := now.Add(-idleAfter).Unix() / 60
+	for shortCode, r := range d.rates {
+		if r.minute < cutoff {
+			delete(d.rates, shortCode)
+		}
+	}
+}
@@ -0,0 +1,388 @@
+package graphql
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"strings"
+)
+
+// ResolveFunc computes a field's value from the value of the object it
+// belongs to and the field's arguments, with defaults applied
+type ResolveFunc func(ctx context.Context, source any, args map[string]any) (any, error)
+
+// Object is an object type: the fields that may be selected on its values
+type Object struct {
+	Name   string
+	Fields map[string]*Field
+}
+
+// Field is a field of an object type
+type Field struct {
+	// Type is the object type of the value, or of its items when the value is
+	// a slice; nil for scalars, which are returned as they marshal to JSON
+	Type *Object
+	// Args are the arguments the field accepts, mapped to their defaults
+	Args map[string]any
+	// Resolve computes the value; when nil, the value is the source struct
+	// field or map entry whose JSON name is the field name
+	Resolve ResolveFunc
+}
+
+// Schema is the entry point of the queries the executor answers. Mutations
+// and subscriptions are not supported.
+type Schema struct {
+	Query *Object
+	// Present turns an error returned by a resolver into the error reported
+	// to the client; when nil, the error's message is reported as is
+	Present func(ctx context.Context, err error) *Error
+}
+
+// Request is a GraphQL request as posted by clients
+type Request struct {
+	Query         string         `json:"query"`
+	OperationName string         `json:"operationName"`
+	Variables     map[string]any `json:"variables"`
+}
+
+// Error is an error reported in a response, with the path of the field it
+// occurred in
+type Error struct {
+	Message    string         `json:"message"`
+	Path       []any          `json:"path,omitempty"`
+	Extensions map[string]any `json:"extensions,omitempty"`
+}
+
+// Response is the result of executing a request. Data is nil when the
+// request could not be executed at all.
+type Response struct {
+	Data   any     `json:"data,omitempty"`
+	Errors []Error `json:"errors,omitempty"`
+}
+
+// Execute parses and runs a query against the schema. Fields are resolved
+// one after another; a failing field is returned as null with an error.
+func (s *Schema) Execute(ctx context.Context, req Request) Response {
+	operations, err := parse(req.Query)
+	if err != nil {
+		return Response{Errors: []Error{{Message: err.Error()}}}
+	}
+
+	op, err := selectOperation(operations, req.OperationName)
+	if err != nil {
+		return Response{Errors: []Error{{Message: err.Error()}}}
+	}
+	if op.kind != "query" {
+		return Response{Errors: []Error{{Message: op.kind + " operations are not supported"}}}
+	}
+
+	variables, err := coerceVariables(op, req.Variables)
+	if err != nil {
+		return Response{Errors: []Error{{Message: err.Error()}}}
+	}
+
+	e := &executor{schema: s, variables: variables}
+	data := e.object(ctx, s.Query, nil, op.selections, nil)
+	return Response{Data: data, Errors: e.errors}
+}
+
+func selectOperation(operations []*operation, name string) (*operation, error) {
+	if name == "" {
+		if len(operations) > 1 {
+			return nil, errors.New("operationName is required when the document contains several operations")
+		}
+		return operations[0], nil
+	}
+	for _, op := range operations {
+		if op.name == name {
+			return op, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown operation %q", name)
+}
+
+func coerceVariables(op *operation, given map[string]any) (map[string]any, error) {
+	variables := map[string]any{}
+	for _, def := range op.variables {
+		v, ok := given[def.name]
+		if !ok && def.hasDefault {
+			v = def.def
+		}
+		if def.nonNull && v == nil {
+			return nil, fmt.Errorf("variable $%s is required", def.name)
+		}
+		variables[def.name] = v
+	}
+	return variables, nil
+}
+
+type executor struct {
+	schema    *Schema
+	variables map[string]any
+	errors    []Error
+}
+
+// queryError is a mistake in the query itself, reported without Present
+type queryError string
+
+func (e queryError) Error() string {
+	return string(e)
+}
+
+func queryErrorf(format string, args ...any) error {
+	return queryError(fmt.Sprintf(format, args...))
+}
+
+func (e *executor) fail(ctx context.Context, path []any, err error) {
+	reported := &Error{Message: err.Error()}
+	var invalid queryError
+	if e.schema.Present != nil && !errors.As(err, &invalid) {
+		reported = e.schema.Present(ctx, err)
+	}
+	reported.Path = append([]any(nil), path...)
+	e.errors = append(e.errors, *reported)
+}
+
+// object resolves the selected fields of a value of type t
+func (e *executor) object(ctx context.Context, t *Object, source any, selections []*selection, path []any) object {
+	var result object
+	for _, sel := range mergeSelections(selections) {
+		key := sel.responseKey()
+		fieldPath := append(path[:len(path):len(path)], key)
+
+		if sel.name == "__typename" {
+			result = append(result, member{key, t.Name})
+			continue
+		}
+
+		field, ok := t.Fields[sel.name]
+		if !ok {
+			e.fail(ctx, fieldPath, queryErrorf("cannot query field %q on type %q", sel.name, t.Name))
+			result = append(result, member{key, nil})
+			continue
+		}
+
+		value, err := e.resolve(ctx, field, source, sel)
+		if err != nil {
+			e.fail(ctx, fieldPath, err)
+			result = append(result, member{key, nil})
+			continue
+		}
+		result = append(result, member{key, e.complete(ctx, field, value, sel, fieldPath)})
+	}
+	return result
+}
+
+func (e *executor) resolve(ctx context.Context, field *Field, source any, sel *selection) (any, error) {
+	args := map[string]any{}
+	for name, def := range field.Args {
+		args[name] = def
+	}
+	for name, v := range sel.args {
+		if _, ok := field.Args[name]; !ok {
+			return nil, queryErrorf("unknown argument %q", name)
+		}
+		v, err := e.value(v)
+		if err != nil {
+			return nil, err
+		}
+		if v != nil {
+			args[name] = v
+		}
+	}
+
+	if field.Type == nil && len(sel.selections) > 0 {
+		return nil, queryErrorf("field %q is a scalar and takes no selections", sel.name)
+	}
+	if field.Type != nil && len(sel.selections) == 0 {
+		return nil, queryErrorf("field %q is an object and needs selections", sel.name)
+	}
+
+	if field.Resolve != nil {
+		return field.Resolve(ctx, source, args)
+	}
+	return structField(source, sel.name), nil
+}
+
+// value substitutes variables in an argument value
+func (e *executor) value(v any) (any, error) {
+	switch v := v.(type) {
+	case variable:
+		value, ok := e.variables[string(v)]
+		if !ok {
+			return nil, queryErrorf("variable $%s is not defined", v)
+		}
+		return value, nil
+	case []any:
+		list := make([]any, len(v))
+		for i, item := range v {
+			var err error
+			if list[i], err = e.value(item); err != nil {
+				return nil, err
+			}
+		}
+		return list, nil
+	case map[string]any:
+		obj := make(map[string]any, len(v))
+		for key, item := range v {
+			var err error
+			if obj[key], err = e.value(item); err != nil {
+				return nil, err
+			}
+		}
+		return obj, nil
+	}
+	return v, nil
+}
+
+// complete resolves the selections of an object value, or of each item of a list of objects
+func (e *executor) complete(ctx context.Context, field *Field, value any, sel *selection, path []any) any {
+	if field.Type == nil || isNil(value) {
+		return value
+	}
+
+	rv := reflect.ValueOf(value)
+	if rv.Kind() != reflect.Slice {
+		return e.object(ctx, field.Type, value, sel.selections, path)
+	}
+	list := make([]any, rv.Len())
+	for i := range list {
+		itemPath := append(path[:len(path):len(path)], i)
+		list[i] = e.object(ctx, field.Type, rv.Index(i).Interface(), sel.selections, itemPath)
+	}
+	return list
+}
+
+// mergeSelections combines selections sharing a response key, as when a
+// field is selected twice with different subfields
+func mergeSelections(selections []*selection) []*selection {
+	var merged []*selection
+	byKey := map[string]*selection{}
+	for _, sel := range selections {
+		if first, ok := byKey[sel.responseKey()]; ok {
+			first.selections = append(first.selections[:len(first.selections):len(first.selections)], sel.selections...)
+			continue
+		}
+		sel := *sel
+		byKey[sel.responseKey()] = &sel
+		merged = append(merged, &sel)
+	}
+	return merged
+}
+
+func isNil(v any) bool {
+	if v == nil {
+		return true
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Pointer, reflect.Map, reflect.Slice, reflect.Interface:
+		return rv.IsNil()
+	}
+	return false
+}
+
+// structField reads the field of a struct, or the entry of a map, that
+// marshals to JSON under name, looking into embedded structs
+func structField(source any, name string) any {
+	rv := reflect.ValueOf(source)
+	for rv.Kind() == reflect.Pointer || rv.Kind() == reflect.Interface {
+		if rv.IsNil() {
+			return nil
+		}
+		rv = rv.Elem()
+	}
+
+	switch rv.Kind() {
+	case reflect.Map:
+		if rv.Type().Key().Kind() != reflect.String {
+			return nil
+		}
+		v := rv.MapIndex(reflect.ValueOf(name).Convert(rv.Type().Key()))
+		if !v.IsValid() {
+			return nil
+		}
+		return v.Interface()
+	case reflect.Struct:
+		if v, ok := lookupField(rv, name); ok {
+			return v.Interface()
+		}
+	}
+	return nil
+}
+
+func lookupField(rv reflect.Value, name string) (reflect.Value, bool) {
+	t := rv.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if f.Anonymous && tag == "" && f.Type.Kind() == reflect.Struct {
+			if v, ok := lookupField(rv.Field(i), name); ok {
+				return v, true
+			}
+			continue
+		}
+		if !f.IsExported() || tag == "-" {
+			continue
+		}
+		if tag == name || (tag == "" && f.Name == name) {
+			return rv.Field(i), true
+		}
+	}
+	return reflect.Value{}, false
+}
+
+// Int reads an integer argument, which arrives as a float64 when it was
+// passed in the JSON variables
+func Int(args map[string]any, name string) (int, bool) {
+	switch v := args[name].(type) {
+	case int:
+		return v, true
+	case float64:
+		if v == math.Trunc(v) && math.Abs(v) <= math.MaxInt32 {
+			return int(v), true
+		}
+	}
+	return 0, false
+}
+
+// String reads a string argument
+func String(args map[string]any, name string) (string, bool) {
+	v, ok := args[name].(string)
+	return v, ok
+}
+
+// object is a resolved object, marshaled with its fields in selection order
+type object []member
+
+type member struct {
+	key   string
+	value any
+}
+
+func (o object) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, m := range o {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		key, err := json.Marshal(m.key)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(key)
+		buf.WriteByte(':')
+		value, err := json.Marshal(m.value)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
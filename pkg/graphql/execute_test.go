@@ -0,0 +1,367 @@
+package graphql
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+type testLink struct {
+	ShortCode string   `json:"shortCode"`
+	Original  string   `json:"original"`
+	Clicks    int      `json:"accessCount"`
+	Tags      []string `json:"tags"`
+	Owner     *testUser
+	secret    string
+}
+
+type testUser struct {
+	testName
+	Email string `json:"email"`
+}
+
+type testName struct {
+	Name string `json:"name"`
+}
+
+var errDatabase = errors.New("database unreachable")
+
+func testSchema() *Schema {
+	links := []testLink{
+		{ShortCode: "a", Original: "https://example.com/a", Clicks: 3, Tags: []string{"x"}, Owner: &testUser{testName{"Ann"}, "ann@example.com"}},
+		{ShortCode: "b", Original: "https://example.com/b"},
+		{ShortCode: "c", Original: "https://example.com/c", Clicks: 1},
+	}
+
+	user := &Object{Name: "User", Fields: map[string]*Field{"name": {}, "email": {}}}
+	link := &Object{Name: "URL", Fields: map[string]*Field{
+		"shortCode":   {},
+		"original":    {},
+		"accessCount": {},
+		"tags":        {},
+		"Owner":       {Type: user},
+		"secret":      {},
+		"clicksTimes": {
+			Args: map[string]any{"factor": 1},
+			Resolve: func(_ context.Context, source any, args map[string]any) (any, error) {
+				factor, ok := Int(args, "factor")
+				if !ok {
+					return nil, errors.New("factor must be an integer")
+				}
+				return source.(testLink).Clicks * factor, nil
+			},
+		},
+		"fail": {Resolve: func(context.Context, any, map[string]any) (any, error) {
+			return nil, errDatabase
+		}},
+	}}
+
+	return &Schema{Query: &Object{Name: "Query", Fields: map[string]*Field{
+		"url": {
+			Type: link,
+			Args: map[string]any{"shortCode": nil},
+			Resolve: func(_ context.Context, _ any, args map[string]any) (any, error) {
+				code, _ := String(args, "shortCode")
+				for _, l := range links {
+					if l.ShortCode == code {
+						return l, nil
+					}
+				}
+				return (*testLink)(nil), nil
+			},
+		},
+		"urls": {
+			Type: link,
+			Args: map[string]any{"limit": 2},
+			Resolve: func(_ context.Context, _ any, args map[string]any) (any, error) {
+				limit, ok := Int(args, "limit")
+				if !ok || limit < 0 {
+					return nil, errors.New("limit must be a non-negative integer")
+				}
+				return links[:min(limit, len(links))], nil
+			},
+		},
+		"stats": {Resolve: func(context.Context, any, map[string]any) (any, error) {
+			return map[string]int{"links": len(links)}, nil
+		}},
+		"fail": {Resolve: func(context.Context, any, map[string]any) (any, error) {
+			return nil, errDatabase
+		}},
+	}}}
+}
+
+// execute runs req against the test schema, returning the response as JSON
+func execute(t *testing.T, schema *Schema, req Request) string {
+	t.Helper()
+	b, err := json.Marshal(schema.Execute(context.Background(), req))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+func TestExecute(t *testing.T) {
+	tests := []struct {
+		name string
+		req  Request
+		want string
+	}{
+		{
+			name: "fields in selection order",
+			req:  Request{Query: `{ url(shortCode: "a") { original shortCode accessCount tags } }`},
+			want: `{"data":{"url":{"original":"https://example.com/a","shortCode":"a","accessCount":3,"tags":["x"]}}}`,
+		},
+		{
+			name: "aliases and typename",
+			req:  Request{Query: `{ first: url(shortCode: "a") { code: shortCode __typename } other: url(shortCode: "b") { code: shortCode } __typename }`},
+			want: `{"data":{"first":{"code":"a","__typename":"URL"},"other":{"code":"b"},"__typename":"Query"}}`,
+		},
+		{
+			name: "nested and embedded struct fields",
+			req:  Request{Query: `{ url(shortCode: "a") { Owner { name email } } }`},
+			want: `{"data":{"url":{"Owner":{"name":"Ann","email":"ann@example.com"}}}}`,
+		},
+		{
+			name: "null object",
+			req:  Request{Query: `{ url(shortCode: "missing") { shortCode } }`},
+			want: `{"data":{"url":null}}`,
+		},
+		{
+			name: "null nested object",
+			req:  Request{Query: `{ url(shortCode: "b") { Owner { name } } }`},
+			want: `{"data":{"url":{"Owner":null}}}`,
+		},
+		{
+			name: "list with argument default",
+			req:  Request{Query: `{ urls { shortCode } }`},
+			want: `{"data":{"urls":[{"shortCode":"a"},{"shortCode":"b"}]}}`,
+		},
+		{
+			name: "null argument keeps the default",
+			req:  Request{Query: `{ urls(limit: null) { shortCode } }`},
+			want: `{"data":{"urls":[{"shortCode":"a"},{"shortCode":"b"}]}}`,
+		},
+		{
+			name: "map source",
+			req:  Request{Query: `{ stats }`},
+			want: `{"data":{"stats":{"links":3}}}`,
+		},
+		{
+			name: "unexported field",
+			req:  Request{Query: `{ url(shortCode: "a") { secret } }`},
+			want: `{"data":{"url":{"secret":null}}}`,
+		},
+		{
+			name: "merged selections",
+			req:  Request{Query: `{ url(shortCode: "a") { shortCode } url(shortCode: "a") { original } }`},
+			want: `{"data":{"url":{"shortCode":"a","original":"https://example.com/a"}}}`,
+		},
+		{
+			name: "resolver with arguments",
+			req:  Request{Query: `{ url(shortCode: "a") { clicksTimes(factor: 4) } }`},
+			want: `{"data":{"url":{"clicksTimes":12}}}`,
+		},
+	}
+
+	schema := testSchema()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := execute(t, schema, tt.req); got != tt.want {
+				t.Fatalf("want %s\n got %s", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestExecuteVariables(t *testing.T) {
+	const query = `query Links($limit: Int = 1, $code: String!) {
+		urls(limit: $limit) { shortCode }
+		url(shortCode: $code) { clicksTimes(factor: $limit) }
+	}`
+
+	tests := []struct {
+		name      string
+		variables string
+		want      string
+	}{
+		{
+			name:      "given",
+			variables: `{"limit": 3, "code": "c"}`,
+			want:      `{"data":{"urls":[{"shortCode":"a"},{"shortCode":"b"},{"shortCode":"c"}],"url":{"clicksTimes":3}}}`,
+		},
+		{
+			name:      "default",
+			variables: `{"code": "a"}`,
+			want:      `{"data":{"urls":[{"shortCode":"a"}],"url":{"clicksTimes":3}}}`,
+		},
+		{
+			name:      "required missing",
+			variables: `{"limit": 3}`,
+			want:      `{"errors":[{"message":"variable $code is required"}]}`,
+		},
+		{
+			name:      "required null",
+			variables: `{"code": null}`,
+			want:      `{"errors":[{"message":"variable $code is required"}]}`,
+		},
+		{
+			name:      "fractional integer",
+			variables: `{"limit": 1.5, "code": "a"}`,
+			want:      `{"data":{"urls":null,"url":{"clicksTimes":null}},"errors":[{"message":"limit must be a non-negative integer","path":["urls"]},{"message":"factor must be an integer","path":["url","clicksTimes"]}]}`,
+		},
+	}
+
+	schema := testSchema()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Variables arrive decoded from JSON, with numbers as float64
+			req := Request{Query: query}
+			if err := json.Unmarshal([]byte(tt.variables), &req.Variables); err != nil {
+				t.Fatal(err)
+			}
+			if got := execute(t, schema, req); got != tt.want {
+				t.Fatalf("want %s\n got %s", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestExecuteUndefinedVariable(t *testing.T) {
+	got := execute(t, testSchema(), Request{Query: `{ urls(limit: $limit) { shortCode } }`})
+	want := `{"data":{"urls":null},"errors":[{"message":"variable $limit is not defined","path":["urls"]}]}`
+	if got != want {
+		t.Fatalf("want %s\n got %s", want, got)
+	}
+}
+
+func TestExecuteOperationName(t *testing.T) {
+	const query = `query A { stats } query B { urls(limit: 1) { shortCode } }`
+	tests := []struct {
+		name          string
+		operationName string
+		want          string
+	}{
+		{"selected", "B", `{"data":{"urls":[{"shortCode":"a"}]}}`},
+		{"required", "", `{"errors":[{"message":"operationName is required when the document contains several operations"}]}`},
+		{"unknown", "C", `{"errors":[{"message":"unknown operation \"C\""}]}`},
+	}
+
+	schema := testSchema()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := execute(t, schema, Request{Query: query, OperationName: tt.operationName}); got != tt.want {
+				t.Fatalf("want %s\n got %s", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestExecuteRequestErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		want  string
+	}{
+		{"syntax error", `{ url(`, "syntax error at 1:7"},
+		{"mutation", `mutation { deleteURL }`, "mutation operations are not supported"},
+		{"subscription", `subscription { clicks }`, "subscription operations are not supported"},
+	}
+
+	schema := testSchema()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			response := schema.Execute(context.Background(), Request{Query: tt.query})
+			if response.Data != nil {
+				t.Fatalf("want no data, got %v", response.Data)
+			}
+			if len(response.Errors) != 1 || !strings.Contains(response.Errors[0].Message, tt.want) {
+				t.Fatalf("want an error containing %q, got %+v", tt.want, response.Errors)
+			}
+		})
+	}
+}
+
+func TestExecuteFieldErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		want  string
+	}{
+		{
+			name:  "unknown field",
+			query: `{ url(shortCode: "a") { shortCode nope } }`,
+			want:  `{"data":{"url":{"shortCode":"a","nope":null}},"errors":[{"message":"cannot query field \"nope\" on type \"URL\"","path":["url","nope"]}]}`,
+		},
+		{
+			name:  "unknown argument",
+			query: `{ url(code: "a") { shortCode } }`,
+			want:  `{"data":{"url":null},"errors":[{"message":"unknown argument \"code\"","path":["url"]}]}`,
+		},
+		{
+			name:  "selections on a scalar",
+			query: `{ stats { links } }`,
+			want:  `{"data":{"stats":null},"errors":[{"message":"field \"stats\" is a scalar and takes no selections","path":["stats"]}]}`,
+		},
+		{
+			name:  "object without selections",
+			query: `{ url(shortCode: "a") }`,
+			want:  `{"data":{"url":null},"errors":[{"message":"field \"url\" is an object and needs selections","path":["url"]}]}`,
+		},
+		{
+			name:  "resolver error in a list",
+			query: `{ urls { shortCode fail } stats }`,
+			want:  `{"data":{"urls":[{"shortCode":"a","fail":null},{"shortCode":"b","fail":null}],"stats":{"links":3}},"errors":[{"message":"database unreachable","path":["urls",0,"fail"]},{"message":"database unreachable","path":["urls",1,"fail"]}]}`,
+		},
+	}
+
+	schema := testSchema()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := execute(t, schema, Request{Query: tt.query}); got != tt.want {
+				t.Fatalf("want %s\n got %s", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestExecutePresent(t *testing.T) {
+	schema := testSchema()
+	schema.Present = func(_ context.Context, err error) *Error {
+		if errors.Is(err, errDatabase) {
+			return &Error{Message: "Internal error", Extensions: map[string]any{"code": "INTERNAL"}}
+		}
+		return &Error{Message: err.Error()}
+	}
+
+	// Mistakes in the query are reported as they are, not through Present
+	got := execute(t, schema, Request{Query: `{ fail nope }`})
+	want := `{"data":{"fail":null,"nope":null},"errors":[{"message":"Internal error","path":["fail"],"extensions":{"code":"INTERNAL"}},{"message":"cannot query field \"nope\" on type \"Query\"","path":["nope"]}]}`
+	if got != want {
+		t.Fatalf("want %s\n got %s", want, got)
+	}
+}
+
+func TestInt(t *testing.T) {
+	tests := []struct {
+		value any
+		want  int
+		ok    bool
+	}{
+		{7, 7, true},
+		{7.0, 7, true},
+		{-3.0, -3, true},
+		{7.5, 0, false},
+		{1e12, 0, false},
+		{"7", 0, false},
+		{nil, 0, false},
+	}
+
+	for _, tt := range tests {
+		got, ok := Int(map[string]any{"n": tt.value}, "n")
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("Int(%#v): want %d, %t, got %d, %t", tt.value, tt.want, tt.ok, got, ok)
+		}
+	}
+}
@@ -0,0 +1,437 @@
+package graphql
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// operation is a parsed query operation
+type operation struct {
+	kind       string
+	name       string
+	variables  []variableDef
+	selections []*selection
+}
+
+// variableDef declares a variable an operation takes
+type variableDef struct {
+	name       string
+	nonNull    bool
+	def        any
+	hasDefault bool
+}
+
+// selection is a field requested from an object, with its own subfields
+// when the field is itself an object
+type selection struct {
+	alias      string
+	name       string
+	args       map[string]any
+	selections []*selection
+}
+
+// responseKey is the name the field's value is returned under
+func (s *selection) responseKey() string {
+	if s.alias != "" {
+		return s.alias
+	}
+	return s.name
+}
+
+// variable is a reference to an operation variable in an argument value
+type variable string
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenPunct
+	tokenName
+	tokenInt
+	tokenFloat
+	tokenString
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	pos  int
+}
+
+// parser reads the executable subset of GraphQL: query operations with
+// variables, fields, aliases and arguments. Fragments, directives and
+// block strings are rejected.
+type parser struct {
+	src string
+	pos int
+	tok token
+}
+
+func parse(src string) ([]*operation, error) {
+	p := &parser{src: src}
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+
+	var operations []*operation
+	for p.tok.kind != tokenEOF {
+		op, err := p.parseOperation()
+		if err != nil {
+			return nil, err
+		}
+		operations = append(operations, op)
+	}
+	if len(operations) == 0 {
+		return nil, fmt.Errorf("document contains no operations")
+	}
+	return operations, nil
+}
+
+func (p *parser) errorf(format string, args ...any) error {
+	line, col := 1, 1
+	for _, r := range p.src[:p.tok.pos] {
+		if r == '\n' {
+			line, col = line+1, 1
+		} else {
+			col++
+		}
+	}
+	return fmt.Errorf("syntax error at %d:%d: %s", line, col, fmt.Sprintf(format, args...))
+}
+
+// next advances to the following token, skipping whitespace, commas and comments
+func (p *parser) next() error {
+	for p.pos < len(p.src) {
+		switch c := p.src[p.pos]; {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == ',':
+			p.pos++
+		case c == '#':
+			for p.pos < len(p.src) && p.src[p.pos] != '\n' && p.src[p.pos] != '\r' {
+				p.pos++
+			}
+		default:
+			return p.lex()
+		}
+	}
+	p.tok = token{kind: tokenEOF, pos: p.pos}
+	return nil
+}
+
+func (p *parser) lex() error {
+	start := p.pos
+	c := p.src[p.pos]
+	switch {
+	case strings.HasPrefix(p.src[p.pos:], "..."):
+		p.tok = token{kind: tokenPunct, text: "...", pos: start}
+		return p.errorf("fragments are not supported")
+	case strings.ContainsRune("{}()[]:=!$@", rune(c)):
+		p.pos++
+		p.tok = token{kind: tokenPunct, text: string(c), pos: start}
+	case c == '_' || isLetter(c):
+		for p.pos < len(p.src) && (p.src[p.pos] == '_' || isLetter(p.src[p.pos]) || isDigit(p.src[p.pos])) {
+			p.pos++
+		}
+		p.tok = token{kind: tokenName, text: p.src[start:p.pos], pos: start}
+	case c == '-' || isDigit(c):
+		kind := tokenInt
+		p.pos++
+		for p.pos < len(p.src) {
+			c := p.src[p.pos]
+			if c == '.' || c == 'e' || c == 'E' || ((c == '+' || c == '-') && (p.src[p.pos-1] == 'e' || p.src[p.pos-1] == 'E')) {
+				kind = tokenFloat
+			} else if !isDigit(c) {
+				break
+			}
+			p.pos++
+		}
+		p.tok = token{kind: kind, text: p.src[start:p.pos], pos: start}
+	case c == '"':
+		if strings.HasPrefix(p.src[p.pos:], `"""`) {
+			p.tok = token{pos: start}
+			return p.errorf("block strings are not supported")
+		}
+		p.pos++
+		for p.pos < len(p.src) && p.src[p.pos] != '"' {
+			if p.src[p.pos] == '\n' || p.src[p.pos] == '\r' {
+				break
+			}
+			if p.src[p.pos] == '\\' {
+				p.pos++
+			}
+			p.pos++
+		}
+		if p.pos >= len(p.src) || p.src[p.pos] != '"' {
+			p.tok = token{pos: start}
+			return p.errorf("unterminated string")
+		}
+		p.pos++
+		// GraphQL string escapes are those of JSON
+		var s string
+		if err := json.Unmarshal([]byte(p.src[start:p.pos]), &s); err != nil {
+			p.tok = token{pos: start}
+			return p.errorf("invalid string")
+		}
+		p.tok = token{kind: tokenString, text: s, pos: start}
+	default:
+		p.tok = token{pos: start}
+		return p.errorf("unexpected character %q", c)
+	}
+	return nil
+}
+
+func isLetter(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func (p *parser) peek(punct string) bool {
+	return p.tok.kind == tokenPunct && p.tok.text == punct
+}
+
+func (p *parser) expect(punct string) error {
+	if !p.peek(punct) {
+		return p.errorf("expected %q", punct)
+	}
+	return p.next()
+}
+
+func (p *parser) name() (string, error) {
+	if p.tok.kind != tokenName {
+		return "", p.errorf("expected a name")
+	}
+	name := p.tok.text
+	return name, p.next()
+}
+
+func (p *parser) parseOperation() (*operation, error) {
+	op := &operation{kind: "query"}
+	if p.tok.kind == tokenName {
+		op.kind = p.tok.text
+		if op.kind != "query" && op.kind != "mutation" && op.kind != "subscription" {
+			return nil, p.errorf("unexpected %q", op.kind)
+		}
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		if p.tok.kind == tokenName {
+			op.name = p.tok.text
+			if err := p.next(); err != nil {
+				return nil, err
+			}
+		}
+		if p.peek("(") {
+			if err := p.parseVariableDefs(op); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if p.peek("@") {
+		return nil, p.errorf("directives are not supported")
+	}
+
+	var err error
+	op.selections, err = p.parseSelectionSet()
+	return op, err
+}
+
+func (p *parser) parseVariableDefs(op *operation) error {
+	if err := p.expect("("); err != nil {
+		return err
+	}
+	for !p.peek(")") {
+		if err := p.expect("$"); err != nil {
+			return err
+		}
+		name, err := p.name()
+		if err != nil {
+			return err
+		}
+		if err := p.expect(":"); err != nil {
+			return err
+		}
+		def := variableDef{name: name}
+		if def.nonNull, err = p.parseType(); err != nil {
+			return err
+		}
+		if p.peek("=") {
+			if err := p.next(); err != nil {
+				return err
+			}
+			if def.def, err = p.parseValue(true); err != nil {
+				return err
+			}
+			def.hasDefault = true
+		}
+		op.variables = append(op.variables, def)
+	}
+	return p.next()
+}
+
+// parseType skips over a type reference, reporting whether it is non-null;
+// argument values are checked by the resolvers rather than against types
+func (p *parser) parseType() (bool, error) {
+	if p.peek("[") {
+		if err := p.next(); err != nil {
+			return false, err
+		}
+		if _, err := p.parseType(); err != nil {
+			return false, err
+		}
+		if err := p.expect("]"); err != nil {
+			return false, err
+		}
+	} else if _, err := p.name(); err != nil {
+		return false, err
+	}
+
+	if p.peek("!") {
+		return true, p.next()
+	}
+	return false, nil
+}
+
+func (p *parser) parseSelectionSet() ([]*selection, error) {
+	if err := p.expect("{"); err != nil {
+		return nil, err
+	}
+	var selections []*selection
+	for !p.peek("}") {
+		sel, err := p.parseField()
+		if err != nil {
+			return nil, err
+		}
+		selections = append(selections, sel)
+	}
+	if len(selections) == 0 {
+		return nil, p.errorf("empty selection set")
+	}
+	return selections, p.next()
+}
+
+func (p *parser) parseField() (*selection, error) {
+	name, err := p.name()
+	if err != nil {
+		return nil, err
+	}
+	sel := &selection{name: name}
+	if p.peek(":") {
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		sel.alias = name
+		if sel.name, err = p.name(); err != nil {
+			return nil, err
+		}
+	}
+
+	if p.peek("(") {
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		sel.args = map[string]any{}
+		for !p.peek(")") {
+			arg, err := p.name()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect(":"); err != nil {
+				return nil, err
+			}
+			if sel.args[arg], err = p.parseValue(false); err != nil {
+				return nil, err
+			}
+		}
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+	}
+	if p.peek("@") {
+		return nil, p.errorf("directives are not supported")
+	}
+
+	if p.peek("{") {
+		if sel.selections, err = p.parseSelectionSet(); err != nil {
+			return nil, err
+		}
+	}
+	return sel, nil
+}
+
+// parseValue reads an argument or default value; constant values, as in
+// defaults, may not refer to variables. Enum values are read as strings.
+func (p *parser) parseValue(constant bool) (any, error) {
+	tok := p.tok
+	switch {
+	case tok.kind == tokenInt:
+		n, err := strconv.Atoi(tok.text)
+		if err != nil {
+			return nil, p.errorf("invalid integer %s", tok.text)
+		}
+		return n, p.next()
+	case tok.kind == tokenFloat:
+		f, err := strconv.ParseFloat(tok.text, 64)
+		if err != nil {
+			return nil, p.errorf("invalid number %s", tok.text)
+		}
+		return f, p.next()
+	case tok.kind == tokenString:
+		return tok.text, p.next()
+	case tok.kind == tokenName:
+		var v any = tok.text
+		switch tok.text {
+		case "true":
+			v = true
+		case "false":
+			v = false
+		case "null":
+			v = nil
+		}
+		return v, p.next()
+	case p.peek("$"):
+		if constant {
+			return nil, p.errorf("variables are not allowed here")
+		}
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		name, err := p.name()
+		return variable(name), err
+	case p.peek("["):
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		list := []any{}
+		for !p.peek("]") {
+			v, err := p.parseValue(constant)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, v)
+		}
+		return list, p.next()
+	case p.peek("{"):
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		obj := map[string]any{}
+		for !p.peek("}") {
+			key, err := p.name()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect(":"); err != nil {
+				return nil, err
+			}
+			if obj[key], err = p.parseValue(constant); err != nil {
+				return nil, err
+			}
+		}
+		return obj, p.next()
+	}
+	return nil, p.errorf("expected a value")
+}
@@ -0,0 +1,148 @@
+package graphql
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	operations, err := parse(`
+		# Comments and commas are ignored
+		query Links($limit: Int = 10, $tag: [String!]!) {
+			first: links(limit: $limit, tags: $tag, order: DESC, filter: {archived: false, min: -1.5e2}) {
+				shortCode,
+				clicks: accessCount
+			}
+			__typename
+		}
+		{ url(shortCode: "a\"bé") { original } }
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(operations) != 2 {
+		t.Fatalf("want 2 operations, got %d", len(operations))
+	}
+
+	op := operations[0]
+	if op.kind != "query" || op.name != "Links" {
+		t.Errorf("want query Links, got %s %s", op.kind, op.name)
+	}
+	wantVariables := []variableDef{
+		{name: "limit", def: 10, hasDefault: true},
+		{name: "tag", nonNull: true},
+	}
+	if !reflect.DeepEqual(op.variables, wantVariables) {
+		t.Errorf("variables: want %+v, got %+v", wantVariables, op.variables)
+	}
+
+	links := op.selections[0]
+	if links.alias != "first" || links.name != "links" || links.responseKey() != "first" {
+		t.Errorf("want links aliased as first, got %q as %q", links.name, links.alias)
+	}
+	wantArgs := map[string]any{
+		"limit":  variable("limit"),
+		"tags":   variable("tag"),
+		"order":  "DESC",
+		"filter": map[string]any{"archived": false, "min": -150.0},
+	}
+	if !reflect.DeepEqual(links.args, wantArgs) {
+		t.Errorf("args: want %#v, got %#v", wantArgs, links.args)
+	}
+	if len(links.selections) != 2 || links.selections[1].responseKey() != "clicks" || links.selections[1].name != "accessCount" {
+		t.Errorf("unexpected subselections: %+v", links.selections)
+	}
+	if op.selections[1].name != "__typename" {
+		t.Errorf("want __typename, got %q", op.selections[1].name)
+	}
+
+	shorthand := operations[1]
+	if shorthand.kind != "query" || shorthand.name != "" {
+		t.Errorf("want an anonymous query, got %s %q", shorthand.kind, shorthand.name)
+	}
+	if got := shorthand.selections[0].args["shortCode"]; got != "a\"bé" {
+		t.Errorf("want the escaped string decoded, got %q", got)
+	}
+}
+
+func TestParseValues(t *testing.T) {
+	tests := []struct {
+		src  string
+		want any
+	}{
+		{`42`, 42},
+		{`-7`, -7},
+		{`2.5`, 2.5},
+		{`1e3`, 1000.0},
+		{`"text"`, "text"},
+		{`true`, true},
+		{`false`, false},
+		{`null`, nil},
+		{`ENUM_VALUE`, "ENUM_VALUE"},
+		{`[1, "two", [3]]`, []any{1, "two", []any{3}}},
+		{`[]`, []any{}},
+		{`{a: 1, b: {c: null}}`, map[string]any{"a": 1, "b": map[string]any{"c": nil}}},
+		{`$v`, variable("v")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.src, func(t *testing.T) {
+			operations, err := parse(`{ f(x: ` + tt.src + `) }`)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := operations[0].selections[0].args["x"]; !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("want %#v, got %#v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want string
+	}{
+		{"empty document", ``, "document contains no operations"},
+		{"comment only", `# nothing`, "document contains no operations"},
+		{"empty selection set", `{ }`, "empty selection set"},
+		{"unclosed selection set", `{ a`, `expected a name`},
+		{"missing selection set", `query Q`, `expected "{"`},
+		{"unknown keyword", `fetch { a }`, `unexpected "fetch"`},
+		{"fragment spread", `{ ...Fields }`, "fragments are not supported"},
+		{"inline fragment", `{ a { ... on URL { b } } }`, "fragments are not supported"},
+		{"field directive", `{ a @skip(if: true) }`, "directives are not supported"},
+		{"operation directive", `query Q @live { a }`, "directives are not supported"},
+		{"block string", `{ a(x: """text""") }`, "block strings are not supported"},
+		{"unterminated string", `{ a(x: "text) }`, "unterminated string"},
+		{"string across lines", "{ a(x: \"te\nxt\") }", "unterminated string"},
+		{"invalid escape", `{ a(x: "\q") }`, "invalid string"},
+		{"invalid integer", `{ a(x: 99999999999999999999) }`, "invalid integer"},
+		{"unexpected character", `{ a(x: %) }`, "unexpected character '%'"},
+		{"missing argument value", `{ a(x: ) }`, "expected a value"},
+		{"missing colon", `{ a(x 1) }`, `expected ":"`},
+		{"variable in default", `query Q($a: Int = $b) { a }`, "variables are not allowed here"},
+		{"variable without type", `query Q($a) { a }`, `expected ":"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parse(tt.src)
+			if err == nil {
+				t.Fatalf("want an error containing %q, got none", tt.want)
+			}
+			if !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("want an error containing %q, got %q", tt.want, err)
+			}
+		})
+	}
+}
+
+func TestParseErrorPosition(t *testing.T) {
+	_, err := parse("{\n  a\n  b(x: %)\n}")
+	if err == nil || !strings.HasPrefix(err.Error(), "syntax error at 3:8:") {
+		t.Fatalf("want a syntax error at 3:8, got %v", err)
+	}
+}
@@ -0,0 +1,242 @@
+// Package jobs runs background work on a bounded pool of workers: recurring
+// jobs on an interval and one-off tasks handed off by request handlers
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrStopped is returned for work submitted after Stop
+	ErrStopped = errors.New("scheduler stopped")
+	// ErrQueueFull is returned when every worker is busy and the queue is full
+	ErrQueueFull = errors.New("job queue full")
+)
+
+// Func is one run of a job. Its context ends when the scheduler stops and
+// running jobs have had their grace period.
+type Func func(ctx context.Context) error
+
+// Job is work run every interval. A run is skipped while the previous one is
+// still queued or running, so slow runs never pile up.
+type Job struct {
+	Name  string
+	Every time.Duration
+	// RunAtStart runs the job as soon as it is scheduled instead of after the first interval
+	RunAtStart bool
+	Run        Func
+}
+
+// Stats are the counters kept for each job or kind of task
+type Stats struct {
+	Runs     int64 `json:"runs"`
+	Failures int64 `json:"failures"`
+	// Skipped counts runs dropped because the previous one was still going or the queue was full
+	Skipped int64 `json:"skipped"`
+	Running int   `json:"running"`
+	// LastRun is when the last run started, LastDuration how long it took in seconds
+	LastRun      time.Time `json:"lastRun"`
+	LastDuration float64   `json:"lastDurationSeconds"`
+	// LastError is the error of the last run, if it failed
+	LastError string `json:"lastError,omitempty"`
+}
+
+// state is a job's stats along with its queued and running runs
+type state struct {
+	Stats
+	pending int
+}
+
+type task struct {
+	name string
+	run  Func
+}
+
+// Scheduler runs jobs and tasks on a fixed number of workers
+type Scheduler struct {
+	ctx     context.Context
+	cancel  context.CancelFunc
+	queue   chan task
+	stop    chan struct{}
+	workers sync.WaitGroup
+	tickers sync.WaitGroup
+
+	mu      sync.Mutex
+	stopped bool
+	states  map[string]*state
+}
+
+// New starts a scheduler with workers running work from a queue of up to queueSize
+func New(workers, queueSize int) *Scheduler {
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &Scheduler{
+		ctx:    ctx,
+		cancel: cancel,
+		queue:  make(chan task, max(queueSize, 1)),
+		stop:   make(chan struct{}),
+		states: make(map[string]*state),
+	}
+
+	for range max(workers, 1) {
+		s.workers.Add(1)
+		go s.work()
+	}
+	return s
+}
+
+// Schedule runs job every job.Every until the scheduler stops
+func (s *Scheduler) Schedule(job Job) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.stopped {
+		return
+	}
+	s.stateOf(job.Name)
+
+	s.tickers.Add(1)
+	go func() {
+		defer s.tickers.Done()
+
+		ticker := time.NewTicker(job.Every)
+		defer ticker.Stop()
+
+		if job.RunAtStart {
+			s.submit(job.Name, job.Run, true)
+		}
+		for {
+			select {
+			case <-s.stop:
+				return
+			case <-ticker.C:
+				s.submit(job.Name, job.Run, true)
+			}
+		}
+	}()
+}
+
+// Go runs a one-off task on the pool, counted under name. It never blocks:
+// the task is dropped when the queue is full.
+func (s *Scheduler) Go(name string, run Func) error {
+	return s.submit(name, run, false)
+}
+
+func (s *Scheduler) submit(name string, run Func, recurring bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.stopped {
+		return ErrStopped
+	}
+
+	st := s.stateOf(name)
+	if recurring && st.pending > 0 {
+		st.Skipped++
+		return nil
+	}
+	select {
+	case s.queue <- task{name, run}:
+		st.pending++
+		return nil
+	default:
+		st.Skipped++
+		return ErrQueueFull
+	}
+}
+
+// stateOf returns the state of a job, creating it on first use. Callers hold mu.
+func (s *Scheduler) stateOf(name string) *state {
+	st, ok := s.states[name]
+	if !ok {
+		st = &state{}
+		s.states[name] = st
+	}
+	return st
+}
+
+func (s *Scheduler) work() {
+	defer s.workers.Done()
+	for t := range s.queue {
+		s.run(t)
+	}
+}
+
+// run runs a task and records its outcome, turning a panic into a failure so
+// the worker survives it
+func (s *Scheduler) run(t task) {
+	s.mu.Lock()
+	st := s.states[t.name]
+	st.Running++
+	s.mu.Unlock()
+
+	started := time.Now()
+	err := func() (err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				err = fmt.Errorf("panic: %v", r)
+			}
+		}()
+		return t.run(s.ctx)
+	}()
+	if err != nil && s.ctx.Err() == nil {
+		log.Printf("Job %s failed: %v", t.name, err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st.pending--
+	st.Running--
+	st.Runs++
+	st.LastRun = started
+	st.LastDuration = time.Since(started).Seconds()
+	st.LastError = ""
+	if err != nil {
+		st.Failures++
+		st.LastError = err.Error()
+	}
+}
+
+// Stats returns the counters of every job and kind of task by name
+func (s *Scheduler) Stats() map[string]Stats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stats := make(map[string]Stats, len(s.states))
+	for name, st := range s.states {
+		stats[name] = st.Stats
+	}
+	return stats
+}
+
+// Stop stops scheduling and waits for queued and running work to finish.
+// Once ctx ends, the context of the work still running is cancelled and Stop
+// returns without waiting for it.
+func (s *Scheduler) Stop(ctx context.Context) error {
+	s.mu.Lock()
+	if s.stopped {
+		s.mu.Unlock()
+		return nil
+	}
+	s.stopped = true
+	close(s.stop)
+	close(s.queue)
+	s.mu.Unlock()
+
+	s.tickers.Wait()
+	done := make(chan struct{})
+	go func() {
+		s.workers.Wait()
+		close(done)
+	}()
+
+	defer s.cancel()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,53 @@
+package limiter
+
+import (
+	"time"
+)
+
+// LeakyBucketLimiter drains requests at a constant rate of one every
+// window/limit, smoothing traffic instead of allowing bursts
+type LeakyBucketLimiter struct {
+	*base[time.Time]
+}
+
+// NewLeakyBucket creates a leaky bucket limiter allowing limit evenly spaced requests per window
+func NewLeakyBucket(limit int, window time.Duration) *LeakyBucketLimiter {
+	return &LeakyBucketLimiter{newBase[time.Time](limit, window)}
+}
+
+// next returns the earliest time key's next request may leak through. Callers hold mu.
+func (lb *LeakyBucketLimiter) next(key string, now time.Time) *time.Time {
+	return lb.get(key, now, func() *time.Time { return &now })
+}
+
+func (lb *LeakyBucketLimiter) Allow(key string) bool {
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+
+	now := time.Now()
+	next := lb.next(key, now)
+	if now.Before(*next) {
+		return false
+	}
+	*next = now.Add(lb.interval())
+	return true
+}
+
+func (lb *LeakyBucketLimiter) Remaining(key string) int {
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+
+	now := time.Now()
+	if now.Before(*lb.next(key, now)) {
+		return 0
+	}
+	return 1
+}
+
+func (lb *LeakyBucketLimiter) NextAvailable(key string) time.Duration {
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+
+	now := time.Now()
+	return max(0, lb.next(key, now).Sub(now))
+}
@@ -1,126 +1,118 @@
 package limiter
 
 import (
+	"fmt"
 	"sync"
 	"time"
 )
 
-// RateLimiter defines a token bucket rate limiter
-type RateLimiter struct {
-	mu           sync.Mutex
-	tokens       map[string]*bucket
-	rate         int           // tokens per interval
-	interval     time.Duration // refill interval
-	maxTokens    int           // maximum tokens per bucket
-	cleanupAfter time.Duration // how long to keep buckets in memory
-}
+// Algorithms selectable with New
+const (
+	TokenBucket   = "token_bucket"
+	SlidingWindow = "sliding_window"
+	LeakyBucket   = "leaky_bucket"
+)
 
-type bucket struct {
-	tokens    int
-	lastSeen  time.Time
-	lastRefil time.Time
+// Limiter allows up to a limit of requests per window for each key, typically a client IP
+type Limiter interface {
+	// Allow consumes one request of key's allowance, reporting false when none is left
+	Allow(key string) bool
+	// Remaining reports how many more requests key may make right now
+	Remaining(key string) int
+	// NextAvailable reports how long until key may make another request, 0 if it may now
+	NextAvailable(key string) time.Duration
+	// Limit returns the number of requests currently allowed per window
+	Limit() int
+	// SetLimit changes the number of requests allowed per window, e.g. while
+	// limits are tightened under load
+	SetLimit(limit int)
+	// Cleanup drops the state of idle keys; callers run it periodically to
+	// keep memory bounded
+	Cleanup()
+	// Keys reports how many keys state is kept for
+	Keys() int
 }
 
-func NewRateLimiter(rate int, interval time.Duration, maxTokens int) *RateLimiter {
-	limiter := &RateLimiter{
-		tokens:       make(map[string]*bucket),
-		rate:         rate,
-		interval:     interval,
-		maxTokens:    maxTokens,
-		cleanupAfter: time.Hour,
+// New creates a limiter using the named algorithm
+func New(algorithm string, limit int, window time.Duration) (Limiter, error) {
+	switch algorithm {
+	case TokenBucket:
+		return NewTokenBucket(limit, window), nil
+	case SlidingWindow, "":
+		return NewSlidingWindow(limit, window), nil
+	case LeakyBucket:
+		return NewLeakyBucket(limit, window), nil
+	default:
+		return nil, fmt.Errorf("unknown rate limit algorithm %q", algorithm)
 	}
-
-	go limiter.cleanup()
-
-	return limiter
 }
 
-func (rl *RateLimiter) Allow(key string) bool {
-	rl.mu.Lock()
-	defer rl.mu.Unlock()
+// idleAfter is how long a key's state is kept after its last request
+const idleAfter = time.Hour
 
-	now := time.Now()
-	b, exists := rl.tokens[key]
-
-	if !exists {
-		rl.tokens[key] = &bucket{
-			tokens:    rl.maxTokens - 1,
-			lastSeen:  now,
-			lastRefil: now,
-		}
-		return true
-	}
-
-	b.lastSeen = now
-
-	elapsed := now.Sub(b.lastRefil)
-	tokensToAdd := int(elapsed/rl.interval) * rl.rate
-
-	if tokensToAdd > 0 {
-		b.tokens = min(b.tokens+tokensToAdd, rl.maxTokens)
-		b.lastRefil = now
-	}
+// base holds the settings and per-key state shared by all algorithms
+type base[S any] struct {
+	mu     sync.Mutex
+	limit  int
+	window time.Duration
+	state  map[string]*S
+	seen   map[string]time.Time
+}
 
-	if b.tokens > 0 {
-		b.tokens--
-		return true
+func newBase[S any](limit int, window time.Duration) *base[S] {
+	b := &base[S]{
+		limit:  max(1, limit),
+		window: window,
+		state:  make(map[string]*S),
+		seen:   make(map[string]time.Time),
 	}
-
-	return false
+	return b
 }
 
-func (rl *RateLimiter) RemainingTokens(key string) int {
-	rl.mu.Lock()
-	defer rl.mu.Unlock()
-
-	b, exists := rl.tokens[key]
+// get returns key's state, creating it with init on first use. Callers hold mu.
+func (b *base[S]) get(key string, now time.Time, init func() *S) *S {
+	s, exists := b.state[key]
 	if !exists {
-		return rl.maxTokens
+		s = init()
+		b.state[key] = s
 	}
-
-	now := time.Now()
-	elapsed := now.Sub(b.lastRefil)
-	tokensToAdd := int(elapsed/rl.interval) * rl.rate
-
-	return min(b.tokens+tokensToAdd, rl.maxTokens)
+	b.seen[key] = now
+	return s
 }
 
-func (rl *RateLimiter) NextAvailable(key string) time.Duration {
-	rl.mu.Lock()
-	defer rl.mu.Unlock()
+func (b *base[S]) Limit() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.limit
+}
 
-	b, exists := rl.tokens[key]
-	if !exists || b.tokens > 0 {
-		return 0
-	}
+func (b *base[S]) SetLimit(limit int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.limit = max(1, limit)
+}
 
-	now := time.Now()
-	elapsed := now.Sub(b.lastRefil)
-	remaining := rl.interval - (elapsed % rl.interval)
+// interval is the time it takes to regain one request of allowance. Callers hold mu.
+func (b *base[S]) interval() time.Duration {
+	return b.window / time.Duration(b.limit)
+}
 
-	return remaining
+func (b *base[S]) Keys() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.state)
 }
 
-// cleanup periodically removes inactive buckets to prevent memory leaks
-func (rl *RateLimiter) cleanup() {
-	ticker := time.NewTicker(10 * time.Minute)
-	defer ticker.Stop()
+// Cleanup removes keys inactive for idleAfter or the window, whichever is longer
+func (b *base[S]) Cleanup() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
 
-	for range ticker.C {
-		rl.mu.Lock()
-		now := time.Now()
-		for key, bucket := range rl.tokens {
-			if now.Sub(bucket.lastSeen) > rl.cleanupAfter {
-				delete(rl.tokens, key)
-			}
+	now := time.Now()
+	for key, lastSeen := range b.seen {
+		if now.Sub(lastSeen) > max(idleAfter, b.window) {
+			delete(b.state, key)
+			delete(b.seen, key)
 		}
-		rl.mu.Unlock()
 	}
 }
-
-func min(a, b int) int {
-	if a < b {
-		return a
-	}
-	return b
-}
@@ -0,0 +1,64 @@
+package limiter
+
+import (
+	"time"
+)
+
+// SlidingWindowLimiter keeps the time of each key's recent requests and allows
+// a request while fewer than limit fall within the last window
+type SlidingWindowLimiter struct {
+	*base[[]time.Time]
+}
+
+// NewSlidingWindow creates a sliding window limiter allowing limit requests per window
+func NewSlidingWindow(limit int, window time.Duration) *SlidingWindowLimiter {
+	return &SlidingWindowLimiter{newBase[[]time.Time](limit, window)}
+}
+
+// recent returns key's requests within the window, dropping older ones. Callers hold mu.
+func (sw *SlidingWindowLimiter) recent(key string, now time.Time) *[]time.Time {
+	requests := sw.get(key, now, func() *[]time.Time { return &[]time.Time{} })
+
+	kept := (*requests)[:0]
+	for _, t := range *requests {
+		if now.Sub(t) <= sw.window {
+			kept = append(kept, t)
+		}
+	}
+	*requests = kept
+	return requests
+}
+
+func (sw *SlidingWindowLimiter) Allow(key string) bool {
+	sw.mu.Lock()
+	defer sw.mu.Unlock()
+
+	now := time.Now()
+	requests := sw.recent(key, now)
+	if len(*requests) >= sw.limit {
+		return false
+	}
+	*requests = append(*requests, now)
+	return true
+}
+
+func (sw *SlidingWindowLimiter) Remaining(key string) int {
+	sw.mu.Lock()
+	defer sw.mu.Unlock()
+
+	return max(0, sw.limit-len(*sw.recent(key, time.Now())))
+}
+
+func (sw *SlidingWindowLimiter) NextAvailable(key string) time.Duration {
+	sw.mu.Lock()
+	defer sw.mu.Unlock()
+
+	now := time.Now()
+	requests := *sw.recent(key, now)
+	if len(requests) < sw.limit {
+		return 0
+	}
+
+	// A slot frees up when the oldest request that keeps us at the limit leaves the window
+	return requests[len(requests)-sw.limit].Add(sw.window).Sub(now)
+}
@@ -0,0 +1,63 @@
+package limiter
+
+import (
+	"time"
+)
+
+// TokenBucketLimiter gives each key a bucket of limit tokens that refills
+// steadily over the window, allowing bursts up to the full limit
+type TokenBucketLimiter struct {
+	*base[bucket]
+}
+
+type bucket struct {
+	tokens     float64
+	lastRefill time.Time
+}
+
+// NewTokenBucket creates a token bucket limiter allowing limit requests per window
+func NewTokenBucket(limit int, window time.Duration) *TokenBucketLimiter {
+	return &TokenBucketLimiter{newBase[bucket](limit, window)}
+}
+
+// refill returns key's bucket topped up for the time since its last refill. Callers hold mu.
+func (tb *TokenBucketLimiter) refill(key string, now time.Time) *bucket {
+	b := tb.get(key, now, func() *bucket {
+		return &bucket{tokens: float64(tb.limit), lastRefill: now}
+	})
+
+	elapsed := now.Sub(b.lastRefill)
+	b.tokens = min(b.tokens+float64(elapsed)/float64(tb.interval()), float64(tb.limit))
+	b.lastRefill = now
+	return b
+}
+
+func (tb *TokenBucketLimiter) Allow(key string) bool {
+	tb.mu.Lock()
+	defer tb.mu.Unlock()
+
+	b := tb.refill(key, time.Now())
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+func (tb *TokenBucketLimiter) Remaining(key string) int {
+	tb.mu.Lock()
+	defer tb.mu.Unlock()
+
+	return int(tb.refill(key, time.Now()).tokens)
+}
+
+func (tb *TokenBucketLimiter) NextAvailable(key string) time.Duration {
+	tb.mu.Lock()
+	defer tb.mu.Unlock()
+
+	b := tb.refill(key, time.Now())
+	if b.tokens >= 1 {
+		return 0
+	}
+	return time.Duration((1 - b.tokens) * float64(tb.interval()))
+}
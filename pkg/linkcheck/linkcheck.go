@@ -0,0 +1,110 @@
+package linkcheck
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+	"url-shortener/pkg/netguard"
+)
+
+// maxRedirects caps how many redirects a check follows before giving up
+const maxRedirects = 10
+
+// Result is the outcome of checking a destination
+type Result struct {
+	// StatusCode is the status of the final response, 0 when none arrived
+	StatusCode int
+	Latency    time.Duration
+	// Redirects are the URLs redirected to, in order
+	Redirects []string
+	Err       error
+}
+
+// Broken reports whether the destination answered with a client or server
+// error, or could not be reached at all
+func (r *Result) Broken() bool {
+	return r.Err != nil || r.StatusCode >= http.StatusBadRequest
+}
+
+// Checker requests destinations to tell whether they still work
+type Checker struct {
+	client    *http.Client
+	userAgent string
+}
+
+// NewChecker returns a checker that only connects to public addresses, so
+// the results of checks can't reveal what runs inside the deployment. A
+// destination or redirect resolving to any other address is reported broken.
+func NewChecker(timeout time.Duration, userAgent string) *Checker {
+	return &Checker{
+		client: &http.Client{
+			Timeout:   timeout,
+			Transport: netguard.Transport(),
+			// Redirects are followed by Check, which records them
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		userAgent: userAgent,
+	}
+}
+
+// Check sends a HEAD request to rawURL, following redirects. Servers that do
+// not allow HEAD are asked with GET instead, without reading the body.
+func (c *Checker) Check(ctx context.Context, rawURL string) (result Result) {
+	start := time.Now()
+	defer func() { result.Latency = time.Since(start) }()
+
+	target, err := url.Parse(rawURL)
+	if err != nil {
+		result.Err = fmt.Errorf("invalid url: %w", err)
+		return result
+	}
+
+	method := http.MethodHead
+	for {
+		if target.Scheme != "http" && target.Scheme != "https" {
+			result.Err = fmt.Errorf("unsupported scheme: %s", target.Scheme)
+			return result
+		}
+
+		resp, err := c.do(ctx, method, target.String())
+		if err != nil {
+			result.Err = err
+			return result
+		}
+		resp.Body.Close()
+
+		if method == http.MethodHead && (resp.StatusCode == http.StatusMethodNotAllowed || resp.StatusCode == http.StatusNotImplemented) {
+			method = http.MethodGet
+			continue
+		}
+
+		location := resp.Header.Get("Location")
+		if resp.StatusCode < 300 || resp.StatusCode >= 400 || location == "" {
+			result.StatusCode = resp.StatusCode
+			return result
+		}
+		if len(result.Redirects) == maxRedirects {
+			result.Err = errors.New("too many redirects")
+			return result
+		}
+		if target, err = target.Parse(location); err != nil {
+			result.Err = fmt.Errorf("invalid redirect: %w", err)
+			return result
+		}
+		result.Redirects = append(result.Redirects, target.String())
+	}
+}
+
+func (c *Checker) do(ctx context.Context, method, rawURL string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", c.userAgent)
+	return c.client.Do(req)
+}
@@ -0,0 +1,116 @@
+package lru
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// Cache is a concurrency-safe least recently used cache whose entries also
+// expire a fixed time after they were added. Expired entries stay until they
+// are replaced or evicted, so GetStale can still fall back on them.
+type Cache[K comparable, V any] struct {
+	mu      sync.Mutex
+	size    int
+	ttl     time.Duration
+	order   *list.List
+	entries map[K]*list.Element
+}
+
+type entry[K comparable, V any] struct {
+	key     K
+	value   V
+	expires time.Time
+}
+
+// New creates a cache holding at most size entries, each for at most ttl
+func New[K comparable, V any](size int, ttl time.Duration) *Cache[K, V] {
+	return &Cache[K, V]{
+		size:    max(size, 1),
+		ttl:     ttl,
+		order:   list.New(),
+		entries: make(map[K]*list.Element),
+	}
+}
+
+// Get returns the value cached for key, marking it recently used
+func (c *Cache[K, V]) Get(key K) (V, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var zero V
+	el, ok := c.entries[key]
+	if !ok {
+		return zero, false
+	}
+	e := el.Value.(*entry[K, V])
+	if time.Now().After(e.expires) {
+		return zero, false
+	}
+	c.order.MoveToFront(el)
+	return e.value, true
+}
+
+// GetStale returns the value cached for key even when it expired, as long as
+// it expired at most maxStale ago
+func (c *Cache[K, V]) GetStale(key K, maxStale time.Duration) (V, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var zero V
+	el, ok := c.entries[key]
+	if !ok {
+		return zero, false
+	}
+	e := el.Value.(*entry[K, V])
+	if time.Now().After(e.expires.Add(maxStale)) {
+		c.remove(el)
+		return zero, false
+	}
+	c.order.MoveToFront(el)
+	return e.value, true
+}
+
+// Add caches value under key, evicting the least recently used entry when full
+func (c *Cache[K, V]) Add(key K, value V) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	expires := time.Now().Add(c.ttl)
+	if el, ok := c.entries[key]; ok {
+		e := el.Value.(*entry[K, V])
+		e.value, e.expires = value, expires
+		c.order.MoveToFront(el)
+		return
+	}
+
+	c.entries[key] = c.order.PushFront(&entry[K, V]{key: key, value: value, expires: expires})
+	if c.order.Len() > c.size {
+		c.remove(c.order.Back())
+	}
+}
+
+// Remove drops key from the cache
+func (c *Cache[K, V]) Remove(key K) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.entries[key]; ok {
+		c.remove(el)
+	}
+}
+
+// Purge drops every entry
+func (c *Cache[K, V]) Purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.order.Init()
+	clear(c.entries)
+}
+
+// remove unlinks an entry. Callers hold mu.
+func (c *Cache[K, V]) remove(el *list.Element) {
+	c.order.Remove(el)
+	delete(c.entries, el.Value.(*entry[K, V]).key)
+}
@@ -0,0 +1,128 @@
+package mailer
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"html/template"
+	"log"
+	"mime"
+	"net"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrQueueFull is returned by Send when the queue cannot take another email
+var ErrQueueFull = errors.New("mail queue is full")
+
+// Config holds the SMTP server the mailer delivers through
+type Config struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+	From     string
+}
+
+// Mailer renders HTML emails from templates and delivers them from a
+// background queue, so request handlers never wait on the SMTP server
+type Mailer struct {
+	cfg       Config
+	templates *template.Template
+	queue     chan message
+	done      chan struct{}
+}
+
+type message struct {
+	to      string
+	subject string
+	body    []byte
+}
+
+// New starts a mailer that queues up to queueSize emails
+func New(cfg Config, templates *template.Template, queueSize int) (*Mailer, error) {
+	if cfg.Host == "" || cfg.From == "" {
+		return nil, errors.New("mailer needs an SMTP host and a from address")
+	}
+	if queueSize < 1 {
+		queueSize = 1
+	}
+
+	m := &Mailer{
+		cfg:       cfg,
+		templates: templates,
+		queue:     make(chan message, queueSize),
+		done:      make(chan struct{}),
+	}
+	go m.run()
+	return m, nil
+}
+
+// Send renders the named template with data and queues the email for
+// delivery. Rendering errors are returned right away; delivery errors are logged.
+func (m *Mailer) Send(to, subject, name string, data any) error {
+	if strings.ContainsAny(to+subject, "\r\n") {
+		return errors.New("invalid recipient or subject")
+	}
+
+	var body bytes.Buffer
+	if err := m.templates.ExecuteTemplate(&body, name, data); err != nil {
+		return fmt.Errorf("render %s: %w", name, err)
+	}
+
+	select {
+	case m.queue <- message{to: to, subject: subject, body: body.Bytes()}:
+		return nil
+	default:
+		return ErrQueueFull
+	}
+}
+
+// Close stops accepting emails and waits for the queue to drain
+func (m *Mailer) Close() {
+	close(m.queue)
+	<-m.done
+}
+
+func (m *Mailer) run() {
+	defer close(m.done)
+	for msg := range m.queue {
+		if err := m.deliver(msg); err != nil {
+			log.Printf("Failed to send %q to %s: %v", msg.subject, msg.to, err)
+		}
+	}
+}
+
+func (m *Mailer) deliver(msg message) error {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "From: %s\r\n", m.cfg.From)
+	fmt.Fprintf(&b, "To: %s\r\n", msg.to)
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", msg.subject))
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/html; charset=UTF-8\r\n\r\n")
+	b.Write(msg.body)
+
+	// PlainAuth only sends credentials over TLS (or to localhost); SendMail
+	// upgrades the connection with STARTTLS when the server offers it
+	var auth smtp.Auth
+	if m.cfg.Username != "" {
+		auth = smtp.PlainAuth("", m.cfg.Username, m.cfg.Password, m.cfg.Host)
+	}
+
+	addr := net.JoinHostPort(m.cfg.Host, strconv.Itoa(m.cfg.Port))
+	return smtp.SendMail(addr, auth, envelopeAddress(m.cfg.From), []string{msg.to}, b.Bytes())
+}
+
+// envelopeAddress extracts the bare address from a From header such as
+// "Shortener <noreply@sho.rt>"
+func envelopeAddress(from string) string {
+	if start := strings.LastIndex(from, "<"); start >= 0 {
+		if end := strings.Index(from[start:], ">"); end > 0 {
+			return from[start+1 : start+end]
+		}
+	}
+	return from
+}
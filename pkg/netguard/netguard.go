@@ -0,0 +1,98 @@
+// Package netguard keeps requests to user-supplied URLs away from loopback,
+// private, link-local and other non-public addresses, so destinations can't
+// be used to reach services inside the deployment
+package netguard
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"syscall"
+	"time"
+)
+
+// ErrNotPublic is returned when a request would connect to a non-public address
+var ErrNotPublic = errors.New("destination address is not public")
+
+// maxRedirects caps how many redirects a client follows
+const maxRedirects = 10
+
+// reserved are the ranges that pass the netip checks in Public without being
+// reachable public addresses, or that map to IPv4 addresses those checks
+// don't see
+var reserved = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("192.0.2.0/24"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+	netip.MustParsePrefix("198.51.100.0/24"),
+	netip.MustParsePrefix("203.0.113.0/24"),
+	netip.MustParsePrefix("240.0.0.0/4"),
+	netip.MustParsePrefix("64:ff9b::/96"),
+	netip.MustParsePrefix("64:ff9b:1::/48"),
+	netip.MustParsePrefix("2001::/23"),
+	netip.MustParsePrefix("2001:db8::/32"),
+	netip.MustParsePrefix("2002::/16"),
+}
+
+// Public reports whether ip is a public unicast address
+func Public(ip netip.Addr) bool {
+	ip = ip.Unmap()
+	if !ip.IsGlobalUnicast() || ip.IsPrivate() {
+		return false
+	}
+	for _, prefix := range reserved {
+		if prefix.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// control runs after DNS resolution, on the address actually dialed, so a
+// host name resolving to an internal address is refused too
+func control(_, address string, _ syscall.RawConn) error {
+	addr, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return err
+	}
+	if !Public(addr.Addr()) {
+		return fmt.Errorf("%w: %s", ErrNotPublic, addr.Addr())
+	}
+	return nil
+}
+
+// Transport returns an HTTP transport that only connects to public
+// addresses. It ignores proxy settings, as the proxy would connect on its
+// behalf.
+func Transport() *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = (&net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+		Control:   control,
+	}).DialContext
+	return transport
+}
+
+// CheckRedirect follows up to 10 redirects, each to an http(s) URL. The
+// transport checks the address of every hop.
+func CheckRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) >= maxRedirects {
+		return errors.New("too many redirects")
+	}
+	if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+		return fmt.Errorf("redirect to unsupported scheme: %s", req.URL.Scheme)
+	}
+	return nil
+}
+
+// NewClient returns a client for requests to user-supplied URLs, which only
+// connects to public addresses and follows redirects with CheckRedirect
+func NewClient(timeout time.Duration) *http.Client {
+	return &http.Client{Timeout: timeout, Transport: Transport(), CheckRedirect: CheckRedirect}
+}
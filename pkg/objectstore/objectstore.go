@@ -0,0 +1,77 @@
+package objectstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"url-shortener/config"
+)
+
+// ErrNotFound is returned by Get when no object exists under the key
+var ErrNotFound = errors.New("object not found")
+
+// Store persists opaque objects under slash-separated keys
+type Store interface {
+	Put(ctx context.Context, key string, data []byte, contentType string) error
+	Get(ctx context.Context, key string) ([]byte, error)
+}
+
+// FromConfig builds the configured backend (OBJECT_STORE=file or s3), or
+// returns nil when none is configured
+func FromConfig(cfg *config.Config) (Store, error) {
+	switch cfg.ObjectStore.Backend {
+	case "":
+		return nil, nil
+	case "file":
+		return NewFileStore(cfg.ObjectStore.Dir), nil
+	case "s3":
+		return NewS3Store(cfg.ObjectStore.S3Endpoint, cfg.ObjectStore.S3Region,
+			cfg.ObjectStore.S3Bucket, cfg.ObjectStore.S3AccessKey, cfg.ObjectStore.S3SecretKey)
+	default:
+		return nil, fmt.Errorf("unknown object store backend: %s", cfg.ObjectStore.Backend)
+	}
+}
+
+// FileStore keeps objects as files below a root directory, for self-hosted
+// deployments without an object storage service
+type FileStore struct {
+	root string
+}
+
+func NewFileStore(root string) *FileStore {
+	return &FileStore{root: root}
+}
+
+func (fs *FileStore) Put(ctx context.Context, key string, data []byte, contentType string) error {
+	path, err := fs.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
+
+func (fs *FileStore) Get(ctx context.Context, key string) ([]byte, error) {
+	path, err := fs.path(key)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	return data, err
+}
+
+// path maps a key below the root, rejecting keys that would escape it
+func (fs *FileStore) path(key string) (string, error) {
+	if strings.Contains(key, "..") {
+		return "", errors.New("invalid object key: " + key)
+	}
+	return filepath.Join(fs.root, filepath.FromSlash(filepath.Clean("/"+key))), nil
+}
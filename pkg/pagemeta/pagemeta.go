@@ -0,0 +1,251 @@
+package pagemeta
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+	"url-shortener/pkg/netguard"
+
+	"golang.org/x/net/html"
+)
+
+// ErrDisallowed is returned when the destination's robots.txt forbids fetching the page
+var ErrDisallowed = errors.New("fetching disallowed by robots.txt")
+
+// maxBodySize caps how much of a destination page is read while looking for metadata
+const maxBodySize = 1 << 20
+
+// Metadata holds the human-readable details extracted from a destination page
+type Metadata struct {
+	Title       string
+	Description string
+}
+
+// Fetcher downloads destination pages and extracts their title and description
+type Fetcher struct {
+	client        *http.Client
+	userAgent     string
+	respectRobots bool
+}
+
+// NewFetcher returns a fetcher that only connects to public addresses, so
+// destinations can't make the server fetch internal pages
+func NewFetcher(timeout time.Duration, userAgent string, respectRobots bool) *Fetcher {
+	return &Fetcher{
+		client:        netguard.NewClient(timeout),
+		userAgent:     userAgent,
+		respectRobots: respectRobots,
+	}
+}
+
+// Fetch retrieves rawURL and returns the page title and meta description
+func (f *Fetcher) Fetch(ctx context.Context, rawURL string) (*Metadata, error) {
+	target, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid url: %w", err)
+	}
+	if target.Scheme != "http" && target.Scheme != "https" {
+		return nil, fmt.Errorf("unsupported scheme: %s", target.Scheme)
+	}
+
+	if f.respectRobots {
+		allowed, err := f.robotsAllowed(ctx, target)
+		if err != nil {
+			return nil, err
+		}
+		if !allowed {
+			return nil, ErrDisallowed
+		}
+	}
+
+	resp, err := f.get(ctx, target.String())
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status fetching page: %d", resp.StatusCode)
+	}
+
+	contentType := resp.Header.Get("Content-Type")
+	if contentType != "" && !strings.Contains(contentType, "html") {
+		return nil, fmt.Errorf("unsupported content type: %s", contentType)
+	}
+
+	return parse(io.LimitReader(resp.Body, maxBodySize)), nil
+}
+
+func (f *Fetcher) get(ctx context.Context, rawURL string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", f.userAgent)
+	return f.client.Do(req)
+}
+
+// robotsAllowed reports whether robots.txt on the target host permits fetching its path.
+// A missing or unreadable robots.txt is treated as allowing everything.
+func (f *Fetcher) robotsAllowed(ctx context.Context, target *url.URL) (bool, error) {
+	robotsURL := url.URL{Scheme: target.Scheme, Host: target.Host, Path: "/robots.txt"}
+
+	resp, err := f.get(ctx, robotsURL.String())
+	if err != nil {
+		if ctx.Err() != nil {
+			return false, ctx.Err()
+		}
+		return true, nil
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return true, nil
+	}
+
+	path := target.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+
+	return robotsRulesAllow(io.LimitReader(resp.Body, maxBodySize), f.userAgent, path), nil
+}
+
+// robotsRulesAllow evaluates the robots.txt groups matching userAgent (or "*")
+// using longest-match precedence between Allow and Disallow rules
+func robotsRulesAllow(r io.Reader, userAgent, path string) bool {
+	agent := strings.ToLower(strings.SplitN(userAgent, "/", 2)[0])
+
+	var (
+		groupAgents   []string
+		inRules       bool
+		specific      []rule
+		wildcard      []rule
+		foundSpecific bool
+	)
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+
+		switch key {
+		case "user-agent":
+			if inRules {
+				groupAgents = nil
+				inRules = false
+			}
+			groupAgents = append(groupAgents, strings.ToLower(value))
+		case "allow", "disallow":
+			inRules = true
+			if value == "" && key == "disallow" {
+				continue
+			}
+			r := rule{prefix: value, allow: key == "allow"}
+			for _, ga := range groupAgents {
+				switch {
+				case ga == "*":
+					wildcard = append(wildcard, r)
+				case agent != "" && strings.Contains(agent, ga):
+					specific = append(specific, r)
+					foundSpecific = true
+				}
+			}
+		}
+	}
+
+	rules := wildcard
+	if foundSpecific {
+		rules = specific
+	}
+
+	allowed, matched := true, -1
+	for _, r := range rules {
+		if strings.HasPrefix(path, r.prefix) && len(r.prefix) > matched {
+			allowed, matched = r.allow, len(r.prefix)
+		}
+	}
+	return allowed
+}
+
+type rule struct {
+	prefix string
+	allow  bool
+}
+
+// parse walks the document head collecting <title> and the description meta tags
+func parse(r io.Reader) *Metadata {
+	meta := &Metadata{}
+	tokenizer := html.NewTokenizer(r)
+	inTitle := false
+
+	for {
+		switch tokenizer.Next() {
+		case html.ErrorToken:
+			return meta
+		case html.StartTagToken, html.SelfClosingTagToken:
+			token := tokenizer.Token()
+			switch token.Data {
+			case "title":
+				inTitle = true
+			case "meta":
+				readMetaTag(token, meta)
+			case "body":
+				if meta.Title != "" {
+					return meta
+				}
+			}
+		case html.EndTagToken:
+			token := tokenizer.Token()
+			if token.Data == "title" {
+				inTitle = false
+			}
+			if token.Data == "head" && meta.Title != "" && meta.Description != "" {
+				return meta
+			}
+		case html.TextToken:
+			if inTitle {
+				meta.Title = strings.Join(strings.Fields(string(tokenizer.Text())), " ")
+			}
+		}
+	}
+}
+
+func readMetaTag(token html.Token, meta *Metadata) {
+	var name, content string
+	for _, attr := range token.Attr {
+		switch strings.ToLower(attr.Key) {
+		case "name", "property":
+			name = strings.ToLower(attr.Val)
+		case "content":
+			content = strings.TrimSpace(attr.Val)
+		}
+	}
+
+	switch name {
+	case "description":
+		meta.Description = content
+	case "og:description":
+		if meta.Description == "" {
+			meta.Description = content
+		}
+	case "og:title":
+		if meta.Title == "" {
+			meta.Title = content
+		}
+	}
+}
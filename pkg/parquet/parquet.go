@@ -0,0 +1,238 @@
+// Package parquet writes flat tables as Apache Parquet files that data
+// warehouses and query engines load directly. It covers what exports need:
+// required string, 64-bit integer, date and timestamp columns in a single row group,
+// PLAIN-encoded and gzip-compressed.
+package parquet
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"fmt"
+	"time"
+)
+
+// magic opens and closes every Parquet file
+const magic = "PAR1"
+
+// Kind is the type of a column's values
+type Kind int
+
+const (
+	// String columns hold UTF-8 text
+	String Kind = iota
+	// Int64 columns hold signed 64-bit integers
+	Int64
+	// Date columns hold calendar days, given as time.Time in UTC
+	Date
+	// Timestamp columns hold instants with microsecond precision, given as time.Time
+	Timestamp
+)
+
+// Field names and types a column
+type Field struct {
+	Name string
+	Kind Kind
+}
+
+// Table accumulates rows column by column until it is encoded
+type Table struct {
+	fields []Field
+	values []bytes.Buffer
+	rows   int
+}
+
+// NewTable starts an empty table with the given columns
+func NewTable(fields ...Field) *Table {
+	return &Table{fields: fields, values: make([]bytes.Buffer, len(fields))}
+}
+
+// Append adds a row with a value for every column: a string, an int64 or a
+// time.Time depending on its kind
+func (t *Table) Append(values ...any) error {
+	if len(values) != len(t.fields) {
+		return fmt.Errorf("parquet: row has %d values for %d columns", len(values), len(t.fields))
+	}
+	for i, field := range t.fields {
+		if !t.appendValue(i, field.Kind, values[i]) {
+			return fmt.Errorf("parquet: invalid value %T for column %s", values[i], field.Name)
+		}
+	}
+	t.rows++
+	return nil
+}
+
+// appendValue PLAIN-encodes one value onto column i
+func (t *Table) appendValue(i int, kind Kind, value any) bool {
+	column := &t.values[i]
+	switch kind {
+	case String:
+		s, ok := value.(string)
+		if ok {
+			column.Write(binary.LittleEndian.AppendUint32(nil, uint32(len(s))))
+			column.WriteString(s)
+		}
+		return ok
+	case Int64:
+		n, ok := value.(int64)
+		if ok {
+			column.Write(binary.LittleEndian.AppendUint64(nil, uint64(n)))
+		}
+		return ok
+	case Date:
+		day, ok := value.(time.Time)
+		if ok {
+			days := day.UTC().Truncate(24*time.Hour).Unix() / 86400
+			column.Write(binary.LittleEndian.AppendUint32(nil, uint32(int32(days))))
+		}
+		return ok
+	case Timestamp:
+		at, ok := value.(time.Time)
+		if ok {
+			column.Write(binary.LittleEndian.AppendUint64(nil, uint64(at.UnixMicro())))
+		}
+		return ok
+	}
+	return false
+}
+
+// Rows is the number of rows appended so far
+func (t *Table) Rows() int {
+	return t.rows
+}
+
+// Parquet enums, as numbered in parquet.thrift
+const (
+	typeInt32     = 1
+	typeInt64     = 2
+	typeByteArray = 6
+
+	convertedUTF8 = 0
+	convertedDate = 6
+	// convertedTimestampMicros marks microseconds since the Unix epoch in UTC
+	convertedTimestampMicros = 10
+
+	repetitionRequired = 0
+
+	encodingPlain = 0
+	encodingRLE   = 3
+
+	codecGzip = 2
+
+	pageData = 0
+)
+
+// physical returns the Parquet type and converted type of a kind, or -1 when
+// it has no converted type
+func physical(kind Kind) (int32, int32) {
+	switch kind {
+	case String:
+		return typeByteArray, convertedUTF8
+	case Date:
+		return typeInt32, convertedDate
+	case Timestamp:
+		return typeInt64, convertedTimestampMicros
+	default:
+		return typeInt64, -1
+	}
+}
+
+// Encode returns the table as a Parquet file, each column stored as a
+// single data page
+func (t *Table) Encode() ([]byte, error) {
+	var file bytes.Buffer
+	file.WriteString(magic)
+
+	type chunk struct {
+		offset            int64
+		compressed, plain int64
+	}
+	chunks := make([]chunk, len(t.fields))
+	var totalSize int64
+	for i := range t.fields {
+		plain := t.values[i].Bytes()
+		var compressed bytes.Buffer
+		gz := gzip.NewWriter(&compressed)
+		if _, err := gz.Write(plain); err != nil {
+			return nil, err
+		}
+		if err := gz.Close(); err != nil {
+			return nil, err
+		}
+
+		// Required, non-nested columns have no repetition or definition
+		// levels, so a page is just its values
+		var header compact
+		header.i32(1, pageData)
+		header.i32(2, int32(len(plain)))
+		header.i32(3, int32(compressed.Len()))
+		header.beginStruct(5)
+		header.i32(1, int32(t.rows))
+		header.i32(2, encodingPlain)
+		header.i32(3, encodingRLE)
+		header.i32(4, encodingRLE)
+		header.endStruct()
+		header.stop()
+
+		chunks[i] = chunk{
+			offset:     int64(file.Len()),
+			compressed: int64(header.buf.Len() + compressed.Len()),
+			plain:      int64(header.buf.Len() + len(plain)),
+		}
+		totalSize += chunks[i].plain
+		file.Write(header.buf.Bytes())
+		file.Write(compressed.Bytes())
+	}
+
+	var meta compact
+	meta.i32(1, 1)
+	meta.beginList(2, compactStruct, len(t.fields)+1)
+	meta.beginElement()
+	meta.binary(4, "schema")
+	meta.i32(5, int32(len(t.fields)))
+	meta.endStruct()
+	for _, field := range t.fields {
+		physicalType, converted := physical(field.Kind)
+		meta.beginElement()
+		meta.i32(1, physicalType)
+		meta.i32(3, repetitionRequired)
+		meta.binary(4, field.Name)
+		if converted >= 0 {
+			meta.i32(6, converted)
+		}
+		meta.endStruct()
+	}
+	meta.i64(3, int64(t.rows))
+	meta.beginList(4, compactStruct, 1)
+	meta.beginElement()
+	meta.beginList(1, compactStruct, len(t.fields))
+	for i, field := range t.fields {
+		physicalType, _ := physical(field.Kind)
+		meta.beginElement()
+		meta.i64(2, chunks[i].offset)
+		meta.beginStruct(3)
+		meta.i32(1, physicalType)
+		meta.beginList(2, compactI32, 2)
+		meta.element(encodingPlain)
+		meta.element(encodingRLE)
+		meta.beginList(3, compactBinary, 1)
+		meta.elementBinary(field.Name)
+		meta.i32(4, codecGzip)
+		meta.i64(5, int64(t.rows))
+		meta.i64(6, chunks[i].plain)
+		meta.i64(7, chunks[i].compressed)
+		meta.i64(9, chunks[i].offset)
+		meta.endStruct()
+		meta.endStruct()
+	}
+	meta.i64(2, totalSize)
+	meta.i64(3, int64(t.rows))
+	meta.endStruct()
+	meta.binary(6, "url-shortener")
+	meta.stop()
+
+	file.Write(meta.buf.Bytes())
+	file.Write(binary.LittleEndian.AppendUint32(nil, uint32(meta.buf.Len())))
+	file.WriteString(magic)
+	return file.Bytes(), nil
+}
@@ -0,0 +1,302 @@
+package parquet
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"testing"
+	"time"
+)
+
+// thriftStruct is a decoded Thrift struct, its values keyed by field ID
+type thriftStruct map[int16]any
+
+// reader decodes the Thrift compact protocol independently of the writer, so
+// files are checked against the format rather than against the encoder
+type reader struct {
+	b   []byte
+	pos int
+}
+
+func (r *reader) byte() (byte, error) {
+	if r.pos >= len(r.b) {
+		return 0, io.ErrUnexpectedEOF
+	}
+	r.pos++
+	return r.b[r.pos-1], nil
+}
+
+func (r *reader) uvarint() (uint64, error) {
+	v, n := binary.Uvarint(r.b[r.pos:])
+	if n <= 0 {
+		return 0, fmt.Errorf("invalid varint at %d", r.pos)
+	}
+	r.pos += n
+	return v, nil
+}
+
+func (r *reader) zigzag() (int64, error) {
+	v, err := r.uvarint()
+	return int64(v>>1) ^ -int64(v&1), err
+}
+
+// value reads one value of a compact type
+func (r *reader) value(kind byte) (any, error) {
+	switch kind {
+	case 1, 2:
+		return kind == 1, nil
+	case 3:
+		b, err := r.byte()
+		return int8(b), err
+	case 4, compactI32, compactI64:
+		return r.zigzag()
+	case compactBinary:
+		n, err := r.uvarint()
+		if err != nil {
+			return nil, err
+		}
+		if r.pos+int(n) > len(r.b) {
+			return nil, io.ErrUnexpectedEOF
+		}
+		r.pos += int(n)
+		return string(r.b[r.pos-int(n) : r.pos]), nil
+	case compactList:
+		header, err := r.byte()
+		if err != nil {
+			return nil, err
+		}
+		n := uint64(header >> 4)
+		if n == 15 {
+			if n, err = r.uvarint(); err != nil {
+				return nil, err
+			}
+		}
+		list := make([]any, n)
+		for i := range list {
+			if list[i], err = r.value(header & 0x0f); err != nil {
+				return nil, err
+			}
+		}
+		return list, nil
+	case compactStruct:
+		return r.structure()
+	}
+	return nil, fmt.Errorf("unsupported compact type %d at %d", kind, r.pos)
+}
+
+func (r *reader) structure() (thriftStruct, error) {
+	s := thriftStruct{}
+	var last int16
+	for {
+		header, err := r.byte()
+		if err != nil {
+			return nil, err
+		}
+		if header == 0 {
+			return s, nil
+		}
+		id := last + int16(header>>4)
+		if header>>4 == 0 {
+			v, err := r.zigzag()
+			if err != nil {
+				return nil, err
+			}
+			id = int16(v)
+		}
+		if s[id], err = r.value(header & 0x0f); err != nil {
+			return nil, err
+		}
+		last = id
+	}
+}
+
+// ids lists the field IDs set in s, in ascending order
+func (s thriftStruct) ids() []int16 {
+	var ids []int16
+	for id := int16(0); id < 32; id++ {
+		if _, ok := s[id]; ok {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+func TestEncode(t *testing.T) {
+	day := time.Date(2024, 3, 9, 0, 0, 0, 0, time.UTC)
+	at := time.Date(2024, 3, 9, 12, 30, 15, 250000000, time.UTC)
+	table := NewTable(
+		Field{Name: "short_code", Kind: String},
+		Field{Name: "clicks", Kind: Int64},
+		Field{Name: "day", Kind: Date},
+		Field{Name: "clicked_at", Kind: Timestamp},
+	)
+	rows := [][]any{
+		{"abc", int64(3), day, at},
+		{"", int64(-1), day.AddDate(0, 0, 1), at.Add(time.Hour)},
+	}
+	for _, row := range rows {
+		if err := table.Append(row...); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	file, err := table.Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(file, []byte(magic)) || !bytes.HasSuffix(file, []byte(magic)) {
+		t.Fatalf("file does not start and end with %s", magic)
+	}
+
+	footer := int(binary.LittleEndian.Uint32(file[len(file)-8:]))
+	start := len(file) - 8 - footer
+	if footer <= 0 || start < len(magic) {
+		t.Fatalf("invalid footer length %d for a %d byte file", footer, len(file))
+	}
+	r := &reader{b: file[:len(file)-8], pos: start}
+	meta, err := r.structure()
+	if err != nil {
+		t.Fatalf("decoding FileMetaData: %v", err)
+	}
+	if r.pos != len(file)-8 {
+		t.Fatalf("FileMetaData is %d bytes, footer length says %d", r.pos-start, footer)
+	}
+
+	if got, want := fmt.Sprint(meta.ids()), "[1 2 3 4 6]"; got != want {
+		t.Errorf("FileMetaData field IDs: want %s, got %s", want, got)
+	}
+	if meta[1] != int64(1) || meta[3] != int64(len(rows)) || meta[6] != "url-shortener" {
+		t.Errorf("version, num_rows or created_by: got %v, %v, %v", meta[1], meta[3], meta[6])
+	}
+
+	schema := meta[2].([]any)
+	if len(schema) != len(table.fields)+1 {
+		t.Fatalf("want %d schema elements, got %d", len(table.fields)+1, len(schema))
+	}
+	root := schema[0].(thriftStruct)
+	if root[4] != "schema" || root[5] != int64(len(table.fields)) {
+		t.Errorf("root schema element: got name %v and %v children", root[4], root[5])
+	}
+	wantTypes := []struct{ physical, converted int64 }{
+		{typeByteArray, convertedUTF8}, {typeInt64, -1}, {typeInt32, convertedDate}, {typeInt64, convertedTimestampMicros},
+	}
+	for i, field := range table.fields {
+		element := schema[i+1].(thriftStruct)
+		converted, ok := element[6]
+		if !ok {
+			converted = int64(-1)
+		}
+		if element[1] != wantTypes[i].physical || converted != wantTypes[i].converted || element[3] != int64(repetitionRequired) || element[4] != field.Name {
+			t.Errorf("schema element %s: got %v", field.Name, element)
+		}
+	}
+
+	groups := meta[4].([]any)
+	if len(groups) != 1 {
+		t.Fatalf("want 1 row group, got %d", len(groups))
+	}
+	group := groups[0].(thriftStruct)
+	if got, want := fmt.Sprint(group.ids()), "[1 2 3]"; got != want {
+		t.Errorf("RowGroup field IDs: want %s, got %s", want, got)
+	}
+	columns := group[1].([]any)
+	if len(columns) != len(table.fields) {
+		t.Fatalf("want %d column chunks, got %d", len(table.fields), len(columns))
+	}
+
+	for i, field := range table.fields {
+		chunk := columns[i].(thriftStruct)
+		column := chunk[3].(thriftStruct)
+		if got, want := fmt.Sprint(column.ids()), "[1 2 3 4 5 6 7 9]"; got != want {
+			t.Errorf("%s: ColumnMetaData field IDs: want %s, got %s", field.Name, want, got)
+		}
+		if path := column[3].([]any); len(path) != 1 || path[0] != field.Name {
+			t.Errorf("%s: path_in_schema %v", field.Name, path)
+		}
+		if column[4] != int64(codecGzip) || column[5] != int64(len(rows)) || chunk[2] != column[9] {
+			t.Errorf("%s: codec %v, num_values %v, file_offset %v, data_page_offset %v", field.Name, column[4], column[5], chunk[2], column[9])
+		}
+
+		offset, ok := column[9].(int64)
+		if !ok {
+			t.Fatalf("%s: no data_page_offset", field.Name)
+		}
+		values := readPage(t, file, int(offset), column[7].(int64))
+		if got, want := values, plainValues(t, field.Kind, rows, i); !bytes.Equal(got, want) {
+			t.Errorf("%s: page values %x, want %x", field.Name, got, want)
+		}
+	}
+}
+
+// readPage decodes the data page at offset, checking its header against the
+// chunk size, and returns its decompressed values
+func readPage(t *testing.T, file []byte, offset int, chunkSize int64) []byte {
+	t.Helper()
+	r := &reader{b: file, pos: offset}
+	header, err := r.structure()
+	if err != nil {
+		t.Fatalf("decoding PageHeader at %d: %v", offset, err)
+	}
+	if got, want := fmt.Sprint(header.ids()), "[1 2 3 5]"; got != want {
+		t.Errorf("PageHeader field IDs: want %s, got %s", want, got)
+	}
+	compressed := int(header[3].(int64))
+	if int64(r.pos-offset+compressed) != chunkSize {
+		t.Errorf("page is %d bytes, total_compressed_size says %d", r.pos-offset+compressed, chunkSize)
+	}
+	data := header[5].(thriftStruct)
+	if data[2] != int64(encodingPlain) {
+		t.Errorf("page encoding %v, want PLAIN", data[2])
+	}
+
+	gz, err := gzip.NewReader(bytes.NewReader(file[r.pos : r.pos+compressed]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	values, err := io.ReadAll(gz)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if int64(len(values)) != header[2].(int64) {
+		t.Errorf("page has %d bytes of values, uncompressed_page_size says %v", len(values), header[2])
+	}
+	return values
+}
+
+// plainValues PLAIN-encodes column i of rows as the Parquet spec describes
+func plainValues(t *testing.T, kind Kind, rows [][]any, i int) []byte {
+	t.Helper()
+	var b []byte
+	for _, row := range rows {
+		switch kind {
+		case String:
+			s := row[i].(string)
+			b = binary.LittleEndian.AppendUint32(b, uint32(len(s)))
+			b = append(b, s...)
+		case Int64:
+			b = binary.LittleEndian.AppendUint64(b, uint64(row[i].(int64)))
+		case Date:
+			b = binary.LittleEndian.AppendUint32(b, uint32(row[i].(time.Time).Unix()/86400))
+		case Timestamp:
+			b = binary.LittleEndian.AppendUint64(b, uint64(row[i].(time.Time).UnixMicro()))
+		default:
+			t.Fatalf("unknown kind %d", kind)
+		}
+	}
+	return b
+}
+
+func TestAppendInvalid(t *testing.T) {
+	table := NewTable(Field{Name: "clicks", Kind: Int64})
+	if err := table.Append("3"); err == nil {
+		t.Error("want an error for a string in an Int64 column")
+	}
+	if err := table.Append(int64(1), int64(2)); err == nil {
+		t.Error("want an error for a row with too many values")
+	}
+	if table.Rows() != 0 {
+		t.Errorf("want no rows after failed appends, got %d", table.Rows())
+	}
+}
@@ -0,0 +1,100 @@
+package parquet
+
+import (
+	"bytes"
+	"encoding/binary"
+)
+
+// Element types of the Thrift compact protocol, which Parquet encodes its
+// page headers and file metadata with
+const (
+	compactI32    = 5
+	compactI64    = 6
+	compactBinary = 8
+	compactList   = 9
+	compactStruct = 12
+)
+
+// compact writes Thrift structs in the compact protocol. Field headers carry
+// the difference to the previous field ID of the same struct, so the IDs of
+// the enclosing structs are kept on a stack.
+type compact struct {
+	buf   bytes.Buffer
+	last  int16
+	outer []int16
+}
+
+func (w *compact) uvarint(v uint64) {
+	w.buf.Write(binary.AppendUvarint(nil, v))
+}
+
+func (w *compact) field(id int16, kind byte) {
+	if delta := id - w.last; delta > 0 && delta <= 15 {
+		w.buf.WriteByte(byte(delta)<<4 | kind)
+	} else {
+		w.buf.WriteByte(kind)
+		w.uvarint(uint64(uint16((id << 1) ^ (id >> 15))))
+	}
+	w.last = id
+}
+
+func (w *compact) i32(id int16, v int32) {
+	w.field(id, compactI32)
+	w.element(v)
+}
+
+func (w *compact) i64(id int16, v int64) {
+	w.field(id, compactI64)
+	w.uvarint(uint64((v << 1) ^ (v >> 63)))
+}
+
+func (w *compact) binary(id int16, s string) {
+	w.field(id, compactBinary)
+	w.elementBinary(s)
+}
+
+// beginStruct opens a struct field, closed with endStruct
+func (w *compact) beginStruct(id int16) {
+	w.field(id, compactStruct)
+	w.beginElement()
+}
+
+// beginList opens a list field of n elements of the given type, which follow
+// without field headers
+func (w *compact) beginList(id int16, kind byte, n int) {
+	w.field(id, compactList)
+	if n < 15 {
+		w.buf.WriteByte(byte(n)<<4 | kind)
+	} else {
+		w.buf.WriteByte(0xf0 | kind)
+		w.uvarint(uint64(n))
+	}
+}
+
+// beginElement opens a struct element of a list, closed with endStruct
+func (w *compact) beginElement() {
+	w.outer = append(w.outer, w.last)
+	w.last = 0
+}
+
+func (w *compact) endStruct() {
+	w.stop()
+	w.last = w.outer[len(w.outer)-1]
+	w.outer = w.outer[:len(w.outer)-1]
+}
+
+// stop ends the outermost struct
+func (w *compact) stop() {
+	w.buf.WriteByte(0)
+}
+
+// element writes an i32 list element
+func (w *compact) element(v int32) {
+	w.uvarint(uint64(uint32((v << 1) ^ (v >> 31))))
+}
+
+// elementBinary writes a string list element
+func (w *compact) elementBinary(s string) {
+	w.uvarint(uint64(len(s)))
+	w.buf.WriteString(s)
+}
@@ -0,0 +1,273 @@
+package saml
+
+import (
+	"bytes"
+	"compress/flate"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	protocolNamespace  = "urn:oasis:names:tc:SAML:2.0:protocol"
+	assertionNamespace = "urn:oasis:names:tc:SAML:2.0:assertion"
+	statusSuccess      = "urn:oasis:names:tc:SAML:2.0:status:Success"
+	bearerConfirmation = "urn:oasis:names:tc:SAML:2.0:cm:bearer"
+	postBinding        = "urn:oasis:names:tc:SAML:2.0:bindings:HTTP-POST"
+)
+
+// clockSkew is how far the identity provider's clock may be off from ours
+const clockSkew = 3 * time.Minute
+
+// ErrEncrypted is returned for responses carrying encrypted assertions,
+// which are not supported
+var ErrEncrypted = errors.New("saml: encrypted assertions are not supported")
+
+// ServiceProvider signs users in with one identity provider
+type ServiceProvider struct {
+	// EntityID identifies this service provider to the identity provider
+	EntityID string
+	// ACSURL is where the identity provider posts its responses
+	ACSURL string
+
+	IdPEntityID    string
+	IdPSSOURL      string
+	IdPCertificate *x509.Certificate
+}
+
+// ParseCertificate reads an identity provider's signing certificate, in PEM
+// or as the bare base64 found in IdP metadata
+func ParseCertificate(s string) (*x509.Certificate, error) {
+	der := []byte(nil)
+	if block, _ := pem.Decode([]byte(strings.TrimSpace(s))); block != nil {
+		der = block.Bytes
+	} else {
+		decoded, err := decodeBase64(s)
+		if err != nil {
+			return nil, errors.New("saml: certificate is neither PEM nor base64")
+		}
+		der = decoded
+	}
+	return x509.ParseCertificate(der)
+}
+
+// AuthnRequestURL returns the identity provider URL that starts a login, for
+// the HTTP-Redirect binding, along with the ID of the request that the
+// response must answer
+func (sp *ServiceProvider) AuthnRequestURL(relayState string, now time.Time) (string, string, error) {
+	id, err := newID()
+	if err != nil {
+		return "", "", err
+	}
+
+	var request bytes.Buffer
+	request.WriteString(`<samlp:AuthnRequest xmlns:samlp="` + protocolNamespace + `" xmlns:saml="` + assertionNamespace + `"`)
+	request.WriteString(` ID="` + id + `" Version="2.0" IssueInstant="` + now.UTC().Format(time.RFC3339) + `"`)
+	request.WriteString(` Destination="` + escape(sp.IdPSSOURL) + `" AssertionConsumerServiceURL="` + escape(sp.ACSURL) + `"`)
+	request.WriteString(` ProtocolBinding="` + postBinding + `">`)
+	request.WriteString(`<saml:Issuer>` + escape(sp.EntityID) + `</saml:Issuer>`)
+	request.WriteString(`<samlp:NameIDPolicy AllowCreate="true"/>`)
+	request.WriteString(`</samlp:AuthnRequest>`)
+
+	var deflated bytes.Buffer
+	writer, _ := flate.NewWriter(&deflated, flate.BestCompression)
+	writer.Write(request.Bytes())
+	writer.Close()
+
+	target, err := url.Parse(sp.IdPSSOURL)
+	if err != nil {
+		return "", "", err
+	}
+	query := target.Query()
+	query.Set("SAMLRequest", base64.StdEncoding.EncodeToString(deflated.Bytes()))
+	if relayState != "" {
+		query.Set("RelayState", relayState)
+	}
+	target.RawQuery = query.Encode()
+	return target.String(), id, nil
+}
+
+// Metadata describes this service provider for the identity provider's setup
+func (sp *ServiceProvider) Metadata() []byte {
+	var b bytes.Buffer
+	b.WriteString(xml.Header)
+	b.WriteString(`<md:EntityDescriptor xmlns:md="urn:oasis:names:tc:SAML:2.0:metadata" entityID="` + escape(sp.EntityID) + `">`)
+	b.WriteString(`<md:SPSSODescriptor AuthnRequestsSigned="false" WantAssertionsSigned="true" protocolSupportEnumeration="` + protocolNamespace + `">`)
+	b.WriteString(`<md:AssertionConsumerService Binding="` + postBinding + `" Location="` + escape(sp.ACSURL) + `" index="0" isDefault="true"/>`)
+	b.WriteString(`</md:SPSSODescriptor></md:EntityDescriptor>`)
+	return b.Bytes()
+}
+
+// Assertion is what a verified response says about the user who signed in
+type Assertion struct {
+	ID     string
+	NameID string
+	// Expires is when the assertion may no longer be used
+	Expires    time.Time
+	Attributes map[string][]string
+}
+
+// Attribute returns the first value of the first of the named attributes present
+func (a *Assertion) Attribute(names ...string) string {
+	for _, name := range names {
+		if values := a.Attributes[name]; len(values) > 0 {
+			return values[0]
+		}
+	}
+	return ""
+}
+
+// ParseResponse verifies a base64 response posted to the ACS URL and returns
+// its assertion. requestID is the ID of the AuthnRequest the response must
+// answer; when empty, only unsolicited (IdP-initiated) responses are accepted.
+// Either the assertion or the whole response must be signed by the identity
+// provider, and the assertion must be meant for this service provider now.
+func (sp *ServiceProvider) ParseResponse(encoded, requestID string, now time.Time) (*Assertion, error) {
+	data, err := decodeBase64(encoded)
+	if err != nil {
+		return nil, errors.New("saml: response is not base64")
+	}
+	root, err := parseXML(data)
+	if err != nil {
+		return nil, err
+	}
+	if !root.is(protocolNamespace, "Response") {
+		return nil, errors.New("saml: not a SAML response")
+	}
+
+	if status := root.path(protocolNamespace, "Status", "StatusCode"); status == nil || status.attr("Value") != statusSuccess {
+		value := ""
+		if status != nil {
+			value = status.attr("Value")
+		}
+		return nil, fmt.Errorf("saml: login failed with status %q", value)
+	}
+	if destination := root.attr("Destination"); destination != "" && destination != sp.ACSURL {
+		return nil, errors.New("saml: response is meant for another destination")
+	}
+	if root.attr("InResponseTo") != requestID {
+		return nil, errors.New("saml: response does not answer the login request")
+	}
+	if issuer := root.child(assertionNamespace, "Issuer"); issuer != nil && issuer.text() != sp.IdPEntityID {
+		return nil, errors.New("saml: response is from another identity provider")
+	}
+
+	if len(root.all(assertionNamespace, "EncryptedAssertion")) > 0 {
+		return nil, ErrEncrypted
+	}
+	assertions := root.all(assertionNamespace, "Assertion")
+	if len(assertions) != 1 {
+		return nil, errors.New("saml: response must carry exactly one assertion")
+	}
+	assertion := assertions[0]
+
+	// Only what a valid signature covers is read from here on
+	assertionErr := verifySignature(root, assertion, sp.IdPCertificate)
+	if assertionErr != nil && !errors.Is(assertionErr, errNotSigned) {
+		return nil, assertionErr
+	}
+	if err := verifySignature(root, root, sp.IdPCertificate); err != nil && (!errors.Is(err, errNotSigned) || assertionErr != nil) {
+		return nil, err
+	}
+
+	return sp.readAssertion(assertion, requestID, now)
+}
+
+// readAssertion checks that a verified assertion is meant for this service
+// provider now and extracts the user's details
+func (sp *ServiceProvider) readAssertion(assertion *element, requestID string, now time.Time) (*Assertion, error) {
+	if issuer := assertion.child(assertionNamespace, "Issuer"); issuer == nil || issuer.text() != sp.IdPEntityID {
+		return nil, errors.New("saml: assertion is from another identity provider")
+	}
+
+	subject := assertion.child(assertionNamespace, "Subject")
+	if subject == nil {
+		return nil, errors.New("saml: assertion has no subject")
+	}
+	nameID := subject.child(assertionNamespace, "NameID")
+	if nameID == nil || nameID.text() == "" {
+		return nil, errors.New("saml: assertion has no NameID")
+	}
+
+	var expires time.Time
+	confirmed := false
+	for _, confirmation := range subject.all(assertionNamespace, "SubjectConfirmation") {
+		data := confirmation.child(assertionNamespace, "SubjectConfirmationData")
+		if confirmation.attr("Method") != bearerConfirmation || data == nil {
+			continue
+		}
+		notOnOrAfter, err := time.Parse(time.RFC3339, data.attr("NotOnOrAfter"))
+		if err != nil || !now.Before(notOnOrAfter.Add(clockSkew)) {
+			continue
+		}
+		if data.attr("Recipient") != sp.ACSURL || data.attr("InResponseTo") != requestID {
+			continue
+		}
+		confirmed = true
+		expires = notOnOrAfter
+	}
+	if !confirmed {
+		return nil, errors.New("saml: assertion has no valid bearer confirmation")
+	}
+
+	conditions := assertion.child(assertionNamespace, "Conditions")
+	if conditions == nil {
+		return nil, errors.New("saml: assertion has no conditions")
+	}
+	if notBefore := conditions.attr("NotBefore"); notBefore != "" {
+		t, err := time.Parse(time.RFC3339, notBefore)
+		if err != nil || now.Add(clockSkew).Before(t) {
+			return nil, errors.New("saml: assertion is not valid yet")
+		}
+	}
+	if notOnOrAfter := conditions.attr("NotOnOrAfter"); notOnOrAfter != "" {
+		t, err := time.Parse(time.RFC3339, notOnOrAfter)
+		if err != nil || !now.Before(t.Add(clockSkew)) {
+			return nil, errors.New("saml: assertion has expired")
+		}
+	}
+	for _, restriction := range conditions.all(assertionNamespace, "AudienceRestriction") {
+		allowed := false
+		for _, audience := range restriction.all(assertionNamespace, "Audience") {
+			allowed = allowed || audience.text() == sp.EntityID
+		}
+		if !allowed {
+			return nil, errors.New("saml: assertion is meant for another service provider")
+		}
+	}
+
+	attributes := map[string][]string{}
+	for _, statement := range assertion.all(assertionNamespace, "AttributeStatement") {
+		for _, attribute := range statement.all(assertionNamespace, "Attribute") {
+			name := attribute.attr("Name")
+			for _, value := range attribute.all(assertionNamespace, "AttributeValue") {
+				attributes[name] = append(attributes[name], value.text())
+			}
+		}
+	}
+
+	return &Assertion{ID: assertion.attr("ID"), NameID: nameID.text(), Expires: expires, Attributes: attributes}, nil
+}
+
+// newID generates a request ID; IDs must not start with a digit
+func newID() (string, error) {
+	b := make([]byte, 20)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return "_" + hex.EncodeToString(b), nil
+}
+
+func escape(s string) string {
+	var b bytes.Buffer
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
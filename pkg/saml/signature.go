@@ -0,0 +1,152 @@
+package saml
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const (
+	dsigNamespace = "http://www.w3.org/2000/09/xmldsig#"
+	excC14N       = "http://www.w3.org/2001/10/xml-exc-c14n#"
+	envelopedSig  = "http://www.w3.org/2000/09/xmldsig#enveloped-signature"
+)
+
+// signatureMethods are the signature algorithms accepted, with their hashes.
+// SHA-1 is no longer accepted.
+var signatureMethods = map[string]crypto.Hash{
+	"http://www.w3.org/2001/04/xmldsig-more#rsa-sha256": crypto.SHA256,
+	"http://www.w3.org/2001/04/xmldsig-more#rsa-sha512": crypto.SHA512,
+}
+
+var digestMethods = map[string]crypto.Hash{
+	"http://www.w3.org/2001/04/xmlenc#sha256": crypto.SHA256,
+	"http://www.w3.org/2001/04/xmlenc#sha512": crypto.SHA512,
+}
+
+// errNotSigned is returned for elements without an enveloped signature
+var errNotSigned = errors.New("saml: element is not signed")
+
+// verifySignature checks the enveloped signature of signed, which must cover
+// signed itself, against the identity provider's certificate
+func verifySignature(root, signed *element, cert *x509.Certificate) error {
+	signatures := signed.all(dsigNamespace, "Signature")
+	if len(signatures) == 0 {
+		return errNotSigned
+	}
+	if len(signatures) > 1 {
+		return errors.New("saml: more than one signature")
+	}
+	signature := signatures[0]
+
+	signedInfo := signature.child(dsigNamespace, "SignedInfo")
+	if signedInfo == nil {
+		return errors.New("saml: signature has no SignedInfo")
+	}
+	c14n := signedInfo.child(dsigNamespace, "CanonicalizationMethod")
+	if c14n == nil || c14n.attr("Algorithm") != excC14N {
+		return errors.New("saml: unsupported canonicalization method")
+	}
+	method := signedInfo.child(dsigNamespace, "SignatureMethod")
+	if method == nil {
+		return errors.New("saml: signature has no SignatureMethod")
+	}
+	hash, ok := signatureMethods[method.attr("Algorithm")]
+	if !ok {
+		return fmt.Errorf("saml: unsupported signature method %q", method.attr("Algorithm"))
+	}
+
+	references := signedInfo.all(dsigNamespace, "Reference")
+	if len(references) != 1 {
+		return errors.New("saml: signature must have exactly one reference")
+	}
+	reference := references[0]
+	id := signed.attr("ID")
+	if id == "" || reference.attr("URI") != "#"+id {
+		return errors.New("saml: signature does not cover the signed element")
+	}
+	if target, err := findByID(root, id); err != nil || target != signed {
+		return errors.New("saml: signature reference is ambiguous")
+	}
+
+	var inclusive []string
+	transforms := reference.child(dsigNamespace, "Transforms")
+	if transforms == nil {
+		return errors.New("saml: reference has no transforms")
+	}
+	enveloped := false
+	for _, transform := range transforms.all(dsigNamespace, "Transform") {
+		switch transform.attr("Algorithm") {
+		case envelopedSig:
+			enveloped = true
+		case excC14N:
+			inclusive = inclusivePrefixes(transform)
+		default:
+			return fmt.Errorf("saml: unsupported transform %q", transform.attr("Algorithm"))
+		}
+	}
+	if !enveloped {
+		return errors.New("saml: signature is not enveloped")
+	}
+
+	digestMethod := reference.child(dsigNamespace, "DigestMethod")
+	if digestMethod == nil {
+		return errors.New("saml: reference has no DigestMethod")
+	}
+	digestHash, ok := digestMethods[digestMethod.attr("Algorithm")]
+	if !ok {
+		return fmt.Errorf("saml: unsupported digest method %q", digestMethod.attr("Algorithm"))
+	}
+	digestValue := reference.child(dsigNamespace, "DigestValue")
+	if digestValue == nil {
+		return errors.New("saml: reference has no DigestValue")
+	}
+	expected, err := decodeBase64(digestValue.text())
+	if err != nil {
+		return errors.New("saml: invalid DigestValue")
+	}
+	digest := digestHash.New()
+	digest.Write(canonicalize(signed, signature, inclusive))
+	if !bytes.Equal(digest.Sum(nil), expected) {
+		return errors.New("saml: digest mismatch")
+	}
+
+	signatureValue := signature.child(dsigNamespace, "SignatureValue")
+	if signatureValue == nil {
+		return errors.New("saml: signature has no SignatureValue")
+	}
+	value, err := decodeBase64(signatureValue.text())
+	if err != nil {
+		return errors.New("saml: invalid SignatureValue")
+	}
+	key, ok := cert.PublicKey.(*rsa.PublicKey)
+	if !ok {
+		return errors.New("saml: certificate does not hold an RSA key")
+	}
+	hashed := hash.New()
+	hashed.Write(canonicalize(signedInfo, nil, inclusivePrefixes(c14n)))
+	if err := rsa.VerifyPKCS1v15(key, hash, hashed.Sum(nil), value); err != nil {
+		return errors.New("saml: invalid signature")
+	}
+	return nil
+}
+
+// inclusivePrefixes reads the InclusiveNamespaces PrefixList of an exclusive
+// canonicalization method or transform
+func inclusivePrefixes(method *element) []string {
+	list := method.child(excC14N, "InclusiveNamespaces")
+	if list == nil {
+		return nil
+	}
+	return strings.Fields(list.attr("PrefixList"))
+}
+
+// decodeBase64 decodes base64 that may be wrapped across lines
+func decodeBase64(s string) ([]byte, error) {
+	return base64.StdEncoding.DecodeString(strings.Join(strings.Fields(s), ""))
+}
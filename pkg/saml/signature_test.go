@@ -0,0 +1,196 @@
+package saml
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"math/big"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const (
+	rsaSHA1   = "http://www.w3.org/2000/09/xmldsig#rsa-sha1"
+	rsaSHA256 = "http://www.w3.org/2001/04/xmldsig-more#rsa-sha256"
+	sha256URI = "http://www.w3.org/2001/04/xmlenc#sha256"
+)
+
+var (
+	testKeyOnce sync.Once
+	testKey     *rsa.PrivateKey
+	testCert    *x509.Certificate
+)
+
+// identityProvider returns the key and self-signed certificate the test
+// identity provider signs with, generated once
+func identityProvider(t *testing.T) (*rsa.PrivateKey, *x509.Certificate) {
+	t.Helper()
+	testKeyOnce.Do(func() {
+		key, err := rsa.GenerateKey(rand.Reader, 2048)
+		if err != nil {
+			t.Fatal(err)
+		}
+		template := &x509.Certificate{
+			SerialNumber: big.NewInt(1),
+			Subject:      pkix.Name{CommonName: "idp.example.com"},
+			NotBefore:    time.Now().Add(-time.Hour),
+			NotAfter:     time.Now().Add(time.Hour),
+		}
+		der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if testCert, err = x509.ParseCertificate(der); err != nil {
+			t.Fatal(err)
+		}
+		testKey = key
+	})
+	if testKey == nil {
+		t.Fatal("no identity provider key")
+	}
+	return testKey, testCert
+}
+
+// response is a SAML response whose assertion has the ID a1 and carries
+// {signature} where its signature goes
+const response = `<samlp:Response xmlns:samlp="urn:oasis:names:tc:SAML:2.0:protocol" xmlns:saml="urn:oasis:names:tc:SAML:2.0:assertion" ID="r1">` +
+	`<saml:Assertion ID="a1"><saml:Issuer>https://idp.example.com</saml:Issuer>{signature}` +
+	`<saml:Subject><saml:NameID>alice@example.com</saml:NameID></saml:Subject></saml:Assertion></samlp:Response>`
+
+// signature describes the enveloped signature to put into a document
+type signature struct {
+	method string
+	// references are the IDs the signature's references point at
+	references []string
+	key        *rsa.PrivateKey
+}
+
+// sign fills {signature} in doc with an enveloped signature, digesting each
+// reference and signing SignedInfo the way an identity provider would
+func sign(t *testing.T, doc string, sig signature) string {
+	t.Helper()
+	hash := crypto.SHA256
+	if sig.method == rsaSHA1 {
+		hash = crypto.SHA1
+	}
+
+	render := func(digests []string, value string) string {
+		var refs strings.Builder
+		for i, id := range sig.references {
+			refs.WriteString(`<ds:Reference URI="#` + id + `"><ds:Transforms>` +
+				`<ds:Transform Algorithm="` + envelopedSig + `"/><ds:Transform Algorithm="` + excC14N + `"/></ds:Transforms>` +
+				`<ds:DigestMethod Algorithm="` + sha256URI + `"/><ds:DigestValue>` + digests[i] + `</ds:DigestValue></ds:Reference>`)
+		}
+		return strings.Replace(doc, "{signature}", `<ds:Signature xmlns:ds="`+dsigNamespace+`"><ds:SignedInfo>`+
+			`<ds:CanonicalizationMethod Algorithm="`+excC14N+`"/><ds:SignatureMethod Algorithm="`+sig.method+`"/>`+
+			refs.String()+`</ds:SignedInfo><ds:SignatureValue>`+value+`</ds:SignatureValue></ds:Signature>`, 1)
+	}
+	parse := func(doc string) (*element, *element) {
+		root, err := parseXML([]byte(doc))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var signature *element
+		var walk func(*element)
+		walk = func(e *element) {
+			if e.is(dsigNamespace, "Signature") && signature == nil {
+				signature = e
+			}
+			for _, child := range e.children {
+				if el, ok := child.(*element); ok {
+					walk(el)
+				}
+			}
+		}
+		walk(root)
+		return root, signature
+	}
+
+	digests := make([]string, len(sig.references))
+	root, signatureElement := parse(render(digests, ""))
+	for i, id := range sig.references {
+		target, err := findByID(root, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sum := crypto.SHA256.New()
+		sum.Write(canonicalize(target, signatureElement, nil))
+		digests[i] = base64.StdEncoding.EncodeToString(sum.Sum(nil))
+	}
+
+	_, signatureElement = parse(render(digests, ""))
+	hashed := hash.New()
+	hashed.Write(canonicalize(signatureElement.child(dsigNamespace, "SignedInfo"), nil, nil))
+	value, err := rsa.SignPKCS1v15(rand.Reader, sig.key, hash, hashed.Sum(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return render(digests, base64.StdEncoding.EncodeToString(value))
+}
+
+// verifyAssertion checks the signature of the one assertion directly in the
+// response doc, as ParseResponse does
+func verifyAssertion(t *testing.T, doc string, cert *x509.Certificate) error {
+	t.Helper()
+	root, err := parseXML([]byte(doc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return verifySignature(root, root.child(assertionNamespace, "Assertion"), cert)
+}
+
+func TestVerifySignature(t *testing.T) {
+	key, cert := identityProvider(t)
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signed := sign(t, response, signature{method: rsaSHA256, references: []string{"a1"}, key: key})
+
+	// A wrapping attack moves the genuinely signed assertion out of the way
+	// and puts a forged one where the service provider reads it, keeping the
+	// signature, whose reference still points at the genuine assertion
+	start := strings.Index(signed, "<saml:Assertion")
+	end := strings.Index(signed, "</samlp:Response>")
+	genuine := signed[start:end]
+	signatureXML := genuine[strings.Index(genuine, "<ds:Signature"):strings.Index(genuine, "<saml:Subject>")]
+	forged := `<saml:Assertion ID="a2"><saml:Issuer>https://idp.example.com</saml:Issuer>` + signatureXML +
+		`<saml:Subject><saml:NameID>mallory@example.com</saml:NameID></saml:Subject></saml:Assertion>`
+	wrapped := signed[:start] + forged + `<samlp:Extensions>` + genuine + `</samlp:Extensions>` + signed[end:]
+	// The same attack with the forged assertion reusing the genuine one's ID
+	duplicated := strings.Replace(wrapped, `ID="a2"`, `ID="a1"`, 1)
+
+	tests := []struct {
+		name string
+		doc  string
+		// want is part of the expected error, empty for a valid signature
+		want string
+	}{
+		{name: "valid", doc: signed},
+		{name: "tampered", doc: strings.Replace(signed, "alice@example.com", "mallory@example.com", 1), want: "digest mismatch"},
+		{name: "signed by another key", doc: sign(t, response, signature{method: rsaSHA256, references: []string{"a1"}, key: otherKey}), want: "invalid signature"},
+		{name: "wrapped", doc: wrapped, want: "does not cover the signed element"},
+		{name: "wrapped with a duplicate ID", doc: duplicated, want: "ambiguous"},
+		{name: "reference to another element", doc: sign(t, response, signature{method: rsaSHA256, references: []string{"r1"}, key: key}), want: "does not cover the signed element"},
+		{name: "rsa-sha1", doc: sign(t, response, signature{method: rsaSHA1, references: []string{"a1"}, key: key}), want: "unsupported signature method"},
+		{name: "multiple references", doc: sign(t, response, signature{method: rsaSHA256, references: []string{"a1", "a1"}, key: key}), want: "exactly one reference"},
+		{name: "unsigned", doc: strings.Replace(response, "{signature}", "", 1), want: errNotSigned.Error()},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := verifyAssertion(t, tt.doc, cert)
+			switch {
+			case tt.want == "" && err != nil:
+				t.Fatalf("want a valid signature, got %v", err)
+			case tt.want != "" && (err == nil || !strings.Contains(err.Error(), tt.want)):
+				t.Fatalf("want an error containing %q, got %v", tt.want, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,305 @@
+package saml
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// xmlNamespace is bound to the xml prefix in every document
+const xmlNamespace = "http://www.w3.org/XML/1998/namespace"
+
+// element is a parsed XML element that keeps the prefixes and namespace
+// declarations as written, which canonicalization needs and encoding/xml's
+// resolved names lose
+type element struct {
+	prefix, local string
+	// namespaces holds the declarations made on the element, by prefix ("" for the default)
+	namespaces map[string]string
+	attrs      []attribute
+	// children holds *element and string (character data) nodes in document order
+	children []any
+	parent   *element
+}
+
+type attribute struct {
+	prefix, local, value string
+}
+
+// parseXML reads a document into a tree. Comments and processing
+// instructions are dropped; DTDs are rejected.
+func parseXML(data []byte) (*element, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	var root, current *element
+	for {
+		token, err := decoder.RawToken()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			el := &element{prefix: t.Name.Space, local: t.Name.Local, namespaces: map[string]string{}, parent: current}
+			for _, a := range t.Attr {
+				switch {
+				case a.Name.Space == "xmlns":
+					el.namespaces[a.Name.Local] = a.Value
+				case a.Name.Space == "" && a.Name.Local == "xmlns":
+					el.namespaces[""] = a.Value
+				default:
+					el.attrs = append(el.attrs, attribute{a.Name.Space, a.Name.Local, a.Value})
+				}
+			}
+			if current == nil {
+				if root != nil {
+					return nil, errors.New("saml: more than one root element")
+				}
+				root = el
+			} else {
+				current.children = append(current.children, el)
+			}
+			current = el
+		case xml.EndElement:
+			if current == nil || t.Name.Space != current.prefix || t.Name.Local != current.local {
+				return nil, errors.New("saml: mismatched end tag")
+			}
+			current = current.parent
+		case xml.CharData:
+			if current != nil {
+				current.children = append(current.children, string(t))
+			}
+		case xml.Directive:
+			return nil, errors.New("saml: DTDs are not allowed")
+		}
+	}
+	if root == nil || current != nil {
+		return nil, errors.New("saml: incomplete document")
+	}
+	return root, nil
+}
+
+// lookup resolves a prefix to its namespace in the scope of the element
+func (e *element) lookup(prefix string) (string, bool) {
+	if prefix == "xml" {
+		return xmlNamespace, true
+	}
+	for el := e; el != nil; el = el.parent {
+		if uri, ok := el.namespaces[prefix]; ok {
+			return uri, true
+		}
+	}
+	return "", false
+}
+
+// namespace is the namespace of the element's name
+func (e *element) namespace() string {
+	uri, _ := e.lookup(e.prefix)
+	return uri
+}
+
+// is reports whether the element has the given namespace and local name
+func (e *element) is(namespace, local string) bool {
+	return e.local == local && e.namespace() == namespace
+}
+
+// attr returns the value of an unprefixed attribute
+func (e *element) attr(local string) string {
+	for _, a := range e.attrs {
+		if a.prefix == "" && a.local == local {
+			return a.value
+		}
+	}
+	return ""
+}
+
+// all returns the child elements with the given name
+func (e *element) all(namespace, local string) []*element {
+	var found []*element
+	for _, child := range e.children {
+		if el, ok := child.(*element); ok && el.is(namespace, local) {
+			found = append(found, el)
+		}
+	}
+	return found
+}
+
+// child returns the first child element with the given name, or nil
+func (e *element) child(namespace, local string) *element {
+	if found := e.all(namespace, local); len(found) > 0 {
+		return found[0]
+	}
+	return nil
+}
+
+// path follows a chain of child elements of one namespace, returning nil if any is missing
+func (e *element) path(namespace string, locals ...string) *element {
+	el := e
+	for _, local := range locals {
+		if el = el.child(namespace, local); el == nil {
+			return nil
+		}
+	}
+	return el
+}
+
+// text is the character data directly inside the element
+func (e *element) text() string {
+	var b strings.Builder
+	for _, child := range e.children {
+		if s, ok := child.(string); ok {
+			b.WriteString(s)
+		}
+	}
+	return strings.TrimSpace(b.String())
+}
+
+// canonicalize serializes the element as Exclusive XML Canonicalization 1.0
+// (without comments) does, leaving out skip and treating the prefixes of
+// inclusive ("#default" for the default namespace) as InclusiveNamespaces
+func canonicalize(e, skip *element, inclusive []string) []byte {
+	var b bytes.Buffer
+	e.writeCanonical(&b, skip, map[string]string{}, inclusive)
+	return b.Bytes()
+}
+
+func (e *element) writeCanonical(b *bytes.Buffer, skip *element, rendered map[string]string, inclusive []string) {
+	// Namespaces are output where they are first visibly used, rather than
+	// where they were declared
+	used := []string{e.prefix}
+	for _, a := range e.attrs {
+		if a.prefix != "" {
+			used = append(used, a.prefix)
+		}
+	}
+	for _, prefix := range inclusive {
+		if prefix == "#default" {
+			prefix = ""
+		}
+		if _, ok := e.lookup(prefix); ok {
+			used = append(used, prefix)
+		}
+	}
+
+	declared := map[string]string{}
+	for _, prefix := range used {
+		if prefix == "xml" {
+			continue
+		}
+		uri, _ := e.lookup(prefix)
+		if current, ok := rendered[prefix]; (ok && current == uri) || (!ok && uri == "") {
+			continue
+		}
+		declared[prefix] = uri
+	}
+	prefixes := make([]string, 0, len(declared))
+	for prefix := range declared {
+		prefixes = append(prefixes, prefix)
+	}
+	sort.Strings(prefixes)
+
+	attrs := make([]attribute, len(e.attrs))
+	copy(attrs, e.attrs)
+	sort.SliceStable(attrs, func(i, j int) bool {
+		ui, _ := e.lookup(attrs[i].prefix)
+		uj, _ := e.lookup(attrs[j].prefix)
+		if attrs[i].prefix == "" {
+			ui = ""
+		}
+		if attrs[j].prefix == "" {
+			uj = ""
+		}
+		if ui != uj {
+			return ui < uj
+		}
+		return attrs[i].local < attrs[j].local
+	})
+
+	name := qualifiedName(e.prefix, e.local)
+	b.WriteString("<" + name)
+	for _, prefix := range prefixes {
+		if prefix == "" {
+			b.WriteString(` xmlns="`)
+		} else {
+			b.WriteString(` xmlns:` + prefix + `="`)
+		}
+		escapeAttr(b, declared[prefix])
+		b.WriteString(`"`)
+	}
+	for _, a := range attrs {
+		b.WriteString(" " + qualifiedName(a.prefix, a.local) + `="`)
+		escapeAttr(b, a.value)
+		b.WriteString(`"`)
+	}
+	b.WriteString(">")
+
+	inner := rendered
+	if len(declared) > 0 {
+		inner = make(map[string]string, len(rendered)+len(declared))
+		for prefix, uri := range rendered {
+			inner[prefix] = uri
+		}
+		for prefix, uri := range declared {
+			inner[prefix] = uri
+		}
+	}
+	for _, child := range e.children {
+		switch c := child.(type) {
+		case string:
+			escapeText(b, c)
+		case *element:
+			if c != skip {
+				c.writeCanonical(b, skip, inner, inclusive)
+			}
+		}
+	}
+	b.WriteString("</" + name + ">")
+}
+
+func qualifiedName(prefix, local string) string {
+	if prefix == "" {
+		return local
+	}
+	return prefix + ":" + local
+}
+
+var textEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", "\r", "&#xD;")
+
+var attrEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", `"`, "&quot;", "\t", "&#x9;", "\n", "&#xA;", "\r", "&#xD;")
+
+func escapeText(b *bytes.Buffer, s string) {
+	textEscaper.WriteString(b, s)
+}
+
+func escapeAttr(b *bytes.Buffer, s string) {
+	attrEscaper.WriteString(b, s)
+}
+
+// findByID returns the element in the tree whose ID attribute is id. It
+// fails unless exactly one has it, so a signature cannot be made to cover a
+// different element than the one read.
+func findByID(root *element, id string) (*element, error) {
+	var found []*element
+	var walk func(*element)
+	walk = func(e *element) {
+		if e.attr("ID") == id {
+			found = append(found, e)
+		}
+		for _, child := range e.children {
+			if el, ok := child.(*element); ok {
+				walk(el)
+			}
+		}
+	}
+	walk(root)
+	if len(found) != 1 {
+		return nil, fmt.Errorf("saml: %d elements with ID %q", len(found), id)
+	}
+	return found[0], nil
+}
@@ -0,0 +1,179 @@
+package stripe
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// apiBase is the Stripe API the client talks to
+const apiBase = "https://api.stripe.com/v1"
+
+// webhookTolerance is how old a signed webhook may be before it is rejected
+// as a possible replay
+const webhookTolerance = 5 * time.Minute
+
+// ErrInvalidSignature is returned for webhooks not signed with the endpoint secret
+var ErrInvalidSignature = errors.New("invalid Stripe signature")
+
+// Client creates Stripe Checkout sessions
+type Client struct {
+	client    *http.Client
+	secretKey string
+}
+
+// New creates a client authenticating with a secret API key
+func New(secretKey string, timeout time.Duration) *Client {
+	return &Client{client: &http.Client{Timeout: timeout}, secretKey: secretKey}
+}
+
+// CheckoutParams describes a subscription to sell through Stripe Checkout
+type CheckoutParams struct {
+	PriceID    string
+	SuccessURL string
+	CancelURL  string
+	// Reference is shown on the session as client_reference_id
+	Reference string
+	// Metadata is copied onto the subscription, so its webhooks carry it
+	Metadata map[string]string
+}
+
+// CheckoutSession is a hosted payment page the customer is sent to
+type CheckoutSession struct {
+	ID  string `json:"id"`
+	URL string `json:"url"`
+}
+
+// CreateCheckoutSession starts a Checkout session subscribing to one unit of a price
+func (c *Client) CreateCheckoutSession(ctx context.Context, params CheckoutParams) (*CheckoutSession, error) {
+	form := url.Values{
+		"mode":                    {"subscription"},
+		"line_items[0][price]":    {params.PriceID},
+		"line_items[0][quantity]": {"1"},
+		"success_url":             {params.SuccessURL},
+		"cancel_url":              {params.CancelURL},
+	}
+	if params.Reference != "" {
+		form.Set("client_reference_id", params.Reference)
+	}
+	for key, value := range params.Metadata {
+		form.Set("subscription_data[metadata]["+key+"]", value)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, apiBase+"/checkout/sessions", strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.SetBasicAuth(c.secretKey, "")
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var failure struct {
+			Error struct {
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		json.NewDecoder(resp.Body).Decode(&failure)
+		return nil, fmt.Errorf("stripe returned %s: %s", resp.Status, failure.Error.Message)
+	}
+
+	var session CheckoutSession
+	if err := json.NewDecoder(resp.Body).Decode(&session); err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+// Event is a webhook notification
+type Event struct {
+	ID      string `json:"id"`
+	Type    string `json:"type"`
+	Created int64  `json:"created"`
+	Data    struct {
+		Object json.RawMessage `json:"object"`
+	} `json:"data"`
+}
+
+// Subscription is the object of customer.subscription.* events
+type Subscription struct {
+	ID               string            `json:"id"`
+	Customer         string            `json:"customer"`
+	Status           string            `json:"status"`
+	CurrentPeriodEnd int64             `json:"current_period_end"`
+	Metadata         map[string]string `json:"metadata"`
+	Items            struct {
+		Data []struct {
+			Price struct {
+				ID string `json:"id"`
+			} `json:"price"`
+		} `json:"data"`
+	} `json:"items"`
+}
+
+// PriceID returns the price of the subscription's first item
+func (s *Subscription) PriceID() string {
+	if len(s.Items.Data) == 0 {
+		return ""
+	}
+	return s.Items.Data[0].Price.ID
+}
+
+// ParseWebhook checks that payload was signed with the endpoint secret less
+// than five minutes before now, as the Stripe-Signature header claims, and
+// decodes the event it carries
+func ParseWebhook(payload []byte, signature, secret string, now time.Time) (*Event, error) {
+	var timestamp string
+	var signatures []string
+	for _, part := range strings.Split(signature, ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch key {
+		case "t":
+			timestamp = value
+		case "v1":
+			signatures = append(signatures, value)
+		}
+	}
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil || len(signatures) == 0 {
+		return nil, ErrInvalidSignature
+	}
+	if age := now.Sub(time.Unix(seconds, 0)); age > webhookTolerance || age < -webhookTolerance {
+		return nil, ErrInvalidSignature
+	}
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(payload)
+	expected := mac.Sum(nil)
+
+	valid := false
+	for _, candidate := range signatures {
+		if decoded, err := hex.DecodeString(candidate); err == nil && hmac.Equal(decoded, expected) {
+			valid = true
+		}
+	}
+	if !valid {
+		return nil, ErrInvalidSignature
+	}
+
+	var event Event
+	if err := json.Unmarshal(payload, &event); err != nil {
+		return nil, err
+	}
+	return &event, nil
+}
@@ -0,0 +1,99 @@
+// Package totp implements time-based one-time passwords (RFC 6238) as used by
+// authenticator apps: HMAC-SHA1, six digits, 30-second steps.
+package totp
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	// Period is how long each code is valid
+	Period = 30 * time.Second
+	// Digits is the length of a code
+	Digits = 6
+	// skew is how many steps either side of now are accepted, for clocks that drift
+	skew = 1
+)
+
+var encoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// NewSecret generates a 160-bit secret, base32-encoded as authenticator apps expect
+func NewSecret() (string, error) {
+	b := make([]byte, 20)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return encoding.EncodeToString(b), nil
+}
+
+// URI returns the otpauth:// URI an authenticator app enrolls the secret
+// from, usually shown as a QR code
+func URI(secret, issuer, account string) string {
+	label := url.PathEscape(issuer + ":" + account)
+	query := url.Values{
+		"secret": {secret},
+		"issuer": {issuer},
+	}
+	return "otpauth://totp/" + label + "?" + query.Encode()
+}
+
+// Code returns the code for the step containing t
+func Code(secret string, t time.Time) (string, error) {
+	key, err := encoding.DecodeString(strings.ToUpper(secret))
+	if err != nil {
+		return "", err
+	}
+	return code(key, Step(t)), nil
+}
+
+// Step returns the number of the step containing t
+func Step(t time.Time) int64 {
+	return t.Unix() / int64(Period/time.Second)
+}
+
+// Verify checks a code against the steps around now and returns the step it
+// matched. Codes for steps up to and including after are rejected, so
+// callers can store the returned step and refuse to accept a code twice.
+func Verify(secret, candidate string, now time.Time, after int64) (int64, bool) {
+	key, err := encoding.DecodeString(strings.ToUpper(secret))
+	if err != nil {
+		return 0, false
+	}
+	candidate = strings.ReplaceAll(candidate, " ", "")
+	if len(candidate) != Digits {
+		return 0, false
+	}
+
+	current := Step(now)
+	for step := current - skew; step <= current+skew; step++ {
+		if step <= after {
+			continue
+		}
+		if subtle.ConstantTimeCompare([]byte(code(key, step)), []byte(candidate)) == 1 {
+			return step, true
+		}
+	}
+	return 0, false
+}
+
+// code is HOTP (RFC 4226) of a step
+func code(key []byte, step int64) string {
+	var counter [8]byte
+	binary.BigEndian.PutUint64(counter[:], uint64(step))
+	mac := hmac.New(sha1.New, key)
+	mac.Write(counter[:])
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	return fmt.Sprintf("%0*d", Digits, value%1000000)
+}
@@ -0,0 +1,142 @@
+package warehouse
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+	"url-shortener/db"
+
+	"golang.org/x/oauth2/jwt"
+)
+
+const (
+	bigQueryAPI = "https://bigquery.googleapis.com/bigquery/v2"
+	// googleTokenURL is where service accounts exchange their signed
+	// assertions for access tokens
+	googleTokenURL = "https://oauth2.googleapis.com/token"
+	bigQueryScope  = "https://www.googleapis.com/auth/bigquery.insertdata"
+	// bigQueryBatch is how many rows one insertAll request carries, within
+	// the size BigQuery recommends
+	bigQueryBatch = 500
+)
+
+// projectPattern matches Google Cloud project IDs
+var projectPattern = regexp.MustCompile(`^[a-z][a-z0-9-]{4,28}[a-z0-9]$`)
+
+// bigQueryWriter streams rows into a BigQuery table with tabledata.insertAll,
+// authenticated as a service account
+type bigQueryWriter struct {
+	client *http.Client
+	url    string
+}
+
+// serviceAccountKey is the part of a service account's JSON key file needed
+// to sign in as it
+type serviceAccountKey struct {
+	ClientEmail  string `json:"client_email"`
+	PrivateKey   string `json:"private_key"`
+	PrivateKeyID string `json:"private_key_id"`
+}
+
+// newBigQueryWriter writes to the project, dataset and table named in
+// settings, with credentials holding a service account's JSON key
+func newBigQueryWriter(settings map[string]string, credentials string) (*bigQueryWriter, error) {
+	project, dataset, table := settings["project"], settings["dataset"], settings["table"]
+	if !projectPattern.MatchString(project) || !identifierPattern.MatchString(dataset) || !identifierPattern.MatchString(table) {
+		return nil, errors.New("bigquery exports need a valid project, dataset and table")
+	}
+	var key serviceAccountKey
+	if err := json.Unmarshal([]byte(credentials), &key); err != nil || key.ClientEmail == "" || key.PrivateKey == "" {
+		return nil, errors.New("bigquery exports need a service account JSON key as credentials")
+	}
+
+	config := &jwt.Config{
+		Email:        key.ClientEmail,
+		PrivateKey:   []byte(key.PrivateKey),
+		PrivateKeyID: key.PrivateKeyID,
+		Scopes:       []string{bigQueryScope},
+		TokenURL:     googleTokenURL,
+	}
+	client := config.Client(context.Background())
+	client.Timeout = requestTimeout
+	return &bigQueryWriter{
+		client: client,
+		url:    fmt.Sprintf("%s/projects/%s/datasets/%s/tables/%s/insertAll", bigQueryAPI, project, dataset, table),
+	}, nil
+}
+
+// bigQueryRow is a row of an insertAll request. The insert ID lets BigQuery
+// drop the row if a retried request delivers it again.
+type bigQueryRow struct {
+	InsertID string         `json:"insertId"`
+	JSON     map[string]any `json:"json"`
+}
+
+func (w *bigQueryWriter) Write(ctx context.Context, day time.Time, rows []db.ClickAggregate) error {
+	for start := 0; start < len(rows); start += bigQueryBatch {
+		batch := rows[start:min(start+bigQueryBatch, len(rows))]
+		request := struct {
+			Rows []bigQueryRow `json:"rows"`
+		}{Rows: make([]bigQueryRow, 0, len(batch))}
+		for _, r := range batch {
+			values := row(r)
+			record := make(map[string]any, len(Columns))
+			for i, column := range Columns {
+				record[column] = values[i]
+			}
+			record["day"] = day.Format(time.DateOnly)
+			// int64 values travel as strings in the JSON API
+			record["clicks"] = fmt.Sprint(r.Clicks)
+
+			id := sha1.Sum([]byte(strings.Join([]string{record["day"].(string), r.ShortCode, r.Domain, r.Country, r.Device, r.Browser, r.OS}, "\n")))
+			request.Rows = append(request.Rows, bigQueryRow{InsertID: hex.EncodeToString(id[:]), JSON: record})
+		}
+		if err := w.insert(ctx, request); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// insert sends one insertAll request, failing when any row was rejected
+func (w *bigQueryWriter) insert(ctx context.Context, request any) error {
+	body, err := json.Marshal(request)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("bigquery insert failed: %s: %.512s", resp.Status, data)
+	}
+	var result struct {
+		InsertErrors []json.RawMessage `json:"insertErrors"`
+	}
+	if err := json.Unmarshal(data, &result); err != nil {
+		return fmt.Errorf("bigquery insert: %w", err)
+	}
+	if len(result.InsertErrors) > 0 {
+		return fmt.Errorf("bigquery rejected %d rows, e.g. %.512s", len(result.InsertErrors), result.InsertErrors[0])
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+package warehouse
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"path"
+	"regexp"
+	"time"
+	"url-shortener/db"
+	"url-shortener/pkg/objectstore"
+	"url-shortener/pkg/parquet"
+)
+
+var (
+	bucketPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9.-]{1,61}[a-z0-9]$`)
+	regionPattern = regexp.MustCompile(`^[a-z]{2}(-[a-z]+)+-[0-9]$`)
+	prefixPattern = regexp.MustCompile(`^[A-Za-z0-9_-]+(/[A-Za-z0-9_-]+)*$`)
+)
+
+// parquetFields are the Parquet types of Columns
+var parquetFields = []parquet.Field{
+	{Name: "day", Kind: parquet.Date},
+	{Name: "short_code", Kind: parquet.String},
+	{Name: "domain", Kind: parquet.String},
+	{Name: "campaign", Kind: parquet.String},
+	{Name: "country", Kind: parquet.String},
+	{Name: "device", Kind: parquet.String},
+	{Name: "browser", Kind: parquet.String},
+	{Name: "os", Kind: parquet.String},
+	{Name: "clicks", Kind: parquet.Int64},
+}
+
+// parquetWriter writes a Parquet file per day under prefix, partitioned the
+// way Hive, Spark and external warehouse tables expect:
+// <prefix>/clicks_daily/day=2025-01-31/clicks.parquet
+type parquetWriter struct {
+	store  objectstore.Store
+	prefix string
+}
+
+// s3Credentials are the access keys of an organization's own bucket
+type s3Credentials struct {
+	AccessKeyID     string `json:"accessKeyId"`
+	SecretAccessKey string `json:"secretAccessKey"`
+}
+
+// newParquetWriter writes to the AWS S3 bucket named in settings, with the
+// access keys in credentials, or to store under prefix without one
+func newParquetWriter(settings map[string]string, credentials string, store objectstore.Store, prefix string) (*parquetWriter, error) {
+	bucket := settings["bucket"]
+	if bucket == "" {
+		if store == nil {
+			return nil, errors.New("parquet exports need a bucket, as no object store is configured")
+		}
+		return &parquetWriter{store: store, prefix: prefix}, nil
+	}
+
+	region := settings["region"]
+	if region == "" {
+		region = "us-east-1"
+	}
+	if !bucketPattern.MatchString(bucket) || !regionPattern.MatchString(region) {
+		return nil, errors.New("parquet exports need a valid bucket and region")
+	}
+	if settings["prefix"] != "" && !prefixPattern.MatchString(settings["prefix"]) {
+		return nil, errors.New("prefix must be slash-separated letters, digits, \"-\" or \"_\"")
+	}
+	var keys s3Credentials
+	if err := json.Unmarshal([]byte(credentials), &keys); err != nil || keys.AccessKeyID == "" || keys.SecretAccessKey == "" {
+		return nil, errors.New("parquet exports to a bucket need credentials with accessKeyId and secretAccessKey")
+	}
+
+	s3, err := objectstore.NewS3Store("https://s3."+region+".amazonaws.com", region, bucket, keys.AccessKeyID, keys.SecretAccessKey)
+	if err != nil {
+		return nil, err
+	}
+	return &parquetWriter{store: s3, prefix: settings["prefix"]}, nil
+}
+
+func (w *parquetWriter) Write(ctx context.Context, day time.Time, rows []db.ClickAggregate) error {
+	table := parquet.NewTable(parquetFields...)
+	for _, r := range rows {
+		if err := table.Append(row(r)...); err != nil {
+			return err
+		}
+	}
+	data, err := table.Encode()
+	if err != nil {
+		return err
+	}
+
+	key := path.Join(w.prefix, "clicks_daily", "day="+day.Format(time.DateOnly), "clicks.parquet")
+	return w.store.Put(ctx, key, data, "application/vnd.apache.parquet")
+}
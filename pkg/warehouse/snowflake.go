@@ -0,0 +1,236 @@
+package warehouse
+
+import (
+	"bytes"
+	"context"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"url-shortener/db"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	// snowflakeBatch is how many rows one INSERT binds
+	snowflakeBatch = 1000
+	// snowflakePoll is how often a statement still running is checked on
+	snowflakePoll = time.Second
+)
+
+// accountPattern matches Snowflake account identifiers, e.g. myorg-myaccount
+// or a legacy locator with its region such as xy12345.eu-central-1
+var accountPattern = regexp.MustCompile(`^[A-Za-z0-9_-]+(\.[A-Za-z0-9_-]+){0,2}$`)
+
+// snowflakeWriter runs statements through Snowflake's SQL API, signed in
+// with key pair authentication
+type snowflakeWriter struct {
+	client   *http.Client
+	endpoint string
+	// qualifiedUser is ACCOUNT.USER as key pair tokens name the user
+	qualifiedUser string
+	key           *rsa.PrivateKey
+	fingerprint   string
+	table         string
+	// context sets the warehouse and role statements run with, when given
+	context map[string]string
+}
+
+// newSnowflakeWriter writes to the account, database, schema and table named
+// in settings, as the settings' user with credentials holding the PEM private
+// key registered for that user
+func newSnowflakeWriter(settings map[string]string, credentials string) (*snowflakeWriter, error) {
+	account, user := settings["account"], settings["user"]
+	if !accountPattern.MatchString(account) || !identifierPattern.MatchString(user) {
+		return nil, errors.New("snowflake exports need a valid account and user")
+	}
+	database, schema, table := settings["database"], settings["schema"], settings["table"]
+	if !identifierPattern.MatchString(database) || !identifierPattern.MatchString(schema) || !identifierPattern.MatchString(table) {
+		return nil, errors.New("snowflake exports need a valid database, schema and table")
+	}
+	statementContext := map[string]string{"database": database, "schema": schema}
+	for _, name := range []string{"warehouse", "role"} {
+		if value := settings[name]; value != "" {
+			if !identifierPattern.MatchString(value) {
+				return nil, fmt.Errorf("invalid snowflake %s", name)
+			}
+			statementContext[name] = value
+		}
+	}
+
+	key, err := parseRSAKey(credentials)
+	if err != nil {
+		return nil, errors.New("snowflake exports need an unencrypted PEM RSA private key as credentials")
+	}
+	public, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+	fingerprint := sha256.Sum256(public)
+
+	// Tokens name the account without the region of a legacy locator
+	accountName, _, _ := strings.Cut(account, ".")
+	return &snowflakeWriter{
+		client:        newClient(),
+		endpoint:      "https://" + strings.ToLower(account) + ".snowflakecomputing.com",
+		qualifiedUser: strings.ToUpper(accountName + "." + user),
+		key:           key,
+		fingerprint:   "SHA256:" + base64.StdEncoding.EncodeToString(fingerprint[:]),
+		table:         database + "." + schema + "." + table,
+		context:       statementContext,
+	}, nil
+}
+
+// parseRSAKey reads a PKCS #8 or PKCS #1 RSA private key
+func parseRSAKey(data string) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode([]byte(data))
+	if block == nil {
+		return nil, errors.New("no PEM block")
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("not an RSA key")
+	}
+	return key, nil
+}
+
+// Write replaces the day's rows: it deletes them and inserts the new ones in
+// batches. A failed write leaves the day incomplete until it is written again.
+func (w *snowflakeWriter) Write(ctx context.Context, day time.Time, rows []db.ClickAggregate) error {
+	date := day.Format(time.DateOnly)
+	err := w.execute(ctx, "DELETE FROM "+w.table+" WHERE day = ?", map[string]snowflakeBinding{
+		"1": {Type: "DATE", Value: date},
+	})
+	if err != nil {
+		return err
+	}
+
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(Columns)), ", ")
+	insert := "INSERT INTO " + w.table + " (" + strings.Join(Columns, ", ") + ") VALUES (" + placeholders + ")"
+	for start := 0; start < len(rows); start += snowflakeBatch {
+		batch := rows[start:min(start+snowflakeBatch, len(rows))]
+
+		// Binding arrays inserts a row per element
+		columns := make([][]string, len(Columns))
+		for _, r := range batch {
+			for i, value := range row(r) {
+				switch v := value.(type) {
+				case time.Time:
+					columns[i] = append(columns[i], date)
+				case int64:
+					columns[i] = append(columns[i], strconv.FormatInt(v, 10))
+				default:
+					columns[i] = append(columns[i], v.(string))
+				}
+			}
+		}
+		bindings := make(map[string]snowflakeBinding, len(Columns))
+		for i, values := range columns {
+			kind := "TEXT"
+			switch Columns[i] {
+			case "day":
+				kind = "DATE"
+			case "clicks":
+				kind = "FIXED"
+			}
+			bindings[strconv.Itoa(i+1)] = snowflakeBinding{Type: kind, Value: values}
+		}
+		if err := w.execute(ctx, insert, bindings); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// snowflakeBinding is the value of a statement's ? placeholder, or a value
+// per row to insert
+type snowflakeBinding struct {
+	Type  string `json:"type"`
+	Value any    `json:"value"`
+}
+
+// execute runs a statement, waiting for it to finish when Snowflake runs it
+// asynchronously
+func (w *snowflakeWriter) execute(ctx context.Context, statement string, bindings map[string]snowflakeBinding) error {
+	request := map[string]any{"statement": statement, "timeout": int(requestTimeout.Seconds()), "bindings": bindings}
+	for name, value := range w.context {
+		request[name] = value
+	}
+	body, err := json.Marshal(request)
+	if err != nil {
+		return err
+	}
+
+	status, data, err := w.do(ctx, http.MethodPost, "/api/v2/statements", body)
+	for err == nil && status == http.StatusAccepted {
+		var pending struct {
+			StatementStatusURL string `json:"statementStatusUrl"`
+		}
+		if err := json.Unmarshal(data, &pending); err != nil || !strings.HasPrefix(pending.StatementStatusURL, "/api/v2/statements/") {
+			return fmt.Errorf("snowflake statement: unexpected response %.512s", data)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(snowflakePoll):
+		}
+		status, data, err = w.do(ctx, http.MethodGet, pending.StatementStatusURL, nil)
+	}
+	if err != nil {
+		return err
+	}
+	if status != http.StatusOK {
+		return fmt.Errorf("snowflake statement failed: %d: %.512s", status, data)
+	}
+	return nil
+}
+
+// do sends a request to the SQL API with a fresh key pair token
+func (w *snowflakeWriter) do(ctx context.Context, method, path string, body []byte) (int, []byte, error) {
+	now := time.Now()
+	token, err := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+		"iss": w.qualifiedUser + "." + w.fingerprint,
+		"sub": w.qualifiedUser,
+		"iat": now.Unix(),
+		"exp": now.Add(time.Hour).Unix(),
+	}).SignedString(w.key)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, w.endpoint+path, bytes.NewReader(body))
+	if err != nil {
+		return 0, nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("X-Snowflake-Authorization-Token-Type", "KEYPAIR_JWT")
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	return resp.StatusCode, data, err
+}
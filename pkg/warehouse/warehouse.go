@@ -0,0 +1,64 @@
+// Package warehouse exports daily click aggregates to a data warehouse,
+// BigQuery or Snowflake, or as Parquet files to object storage
+package warehouse
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"regexp"
+	"time"
+	"url-shortener/db"
+	"url-shortener/pkg/objectstore"
+)
+
+// Destinations clicks can be exported to
+const (
+	Parquet   = "parquet"
+	BigQuery  = "bigquery"
+	Snowflake = "snowflake"
+)
+
+// requestTimeout bounds each call to a destination's API
+const requestTimeout = time.Minute
+
+// Writer stores the click aggregates of one day. Writing a day again replaces
+// what was written for it before, except on BigQuery, whose streaming
+// inserts only drop repeated rows for a few minutes.
+type Writer interface {
+	Write(ctx context.Context, day time.Time, rows []db.ClickAggregate) error
+}
+
+// Columns are the columns of the exported table, in order: day (DATE),
+// short_code, domain, campaign, country, device, browser, os (strings) and
+// clicks (INT64)
+var Columns = []string{"day", "short_code", "domain", "campaign", "country", "device", "browser", "os", "clicks"}
+
+// identifierPattern matches the names of datasets, schemas and tables that
+// can be used in requests and SQL without quoting
+var identifierPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]{0,254}$`)
+
+// New creates the writer for a destination from its settings and
+// credentials. Parquet files go to the organization's own bucket when the
+// settings name one, and to store under prefix otherwise.
+func New(destination string, settings map[string]string, credentials string, store objectstore.Store, prefix string) (Writer, error) {
+	switch destination {
+	case Parquet:
+		return newParquetWriter(settings, credentials, store, prefix)
+	case BigQuery:
+		return newBigQueryWriter(settings, credentials)
+	case Snowflake:
+		return newSnowflakeWriter(settings, credentials)
+	default:
+		return nil, fmt.Errorf("unknown export destination %q", destination)
+	}
+}
+
+// row returns the values of an aggregate in the order of Columns
+func row(c db.ClickAggregate) []any {
+	return []any{c.Day, c.ShortCode, c.Domain, c.Campaign, c.Country, c.Device, c.Browser, c.OS, c.Clicks}
+}
+
+func newClient() *http.Client {
+	return &http.Client{Timeout: requestTimeout}
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"url-shortener/db"
+	"url-shortener/pkg/apierror"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// minPrefixLength keeps organizations from reserving whole swaths of
+	// short codes, such as every code starting with "a"
+	minPrefixLength = 3
+	// maxReservedPrefixes bounds how many prefixes an organization reserves
+	maxReservedPrefixes = 20
+)
+
+// normalizePrefix lowercases a prefix to reserve, accepting a trailing "*"
+// as in acme-*
+func normalizePrefix(prefix string) (string, bool) {
+	prefix = strings.ToLower(strings.TrimSuffix(strings.TrimSpace(prefix), "*"))
+	if len(prefix) < minPrefixLength || !customCodePattern.MatchString(prefix) || slices.Contains(reservedCodes, prefix) {
+		return "", false
+	}
+	return prefix, true
+}
+
+// checkReservedPrefix writes an error unless a link of orgID, 0 for personal
+// links, may use code: codes under a prefix reserved by an organization are
+// kept for that organization's links
+func checkReservedPrefix(c *gin.Context, code string, orgID int) bool {
+	owners, err := database.PrefixOwners(c.Request.Context(), []string{code})
+	if err != nil {
+		respondError(c, apierror.Internal("Database error").Wrap(err))
+		return false
+	}
+	if owner, ok := owners[code]; ok && owner != orgID {
+		respondError(c, apierror.Forbidden("Short code is under a prefix reserved by another organization"))
+		return false
+	}
+	return true
+}
+
+// prefixOwner returns the :orgId organization, writing an error unless the
+// caller owns it
+func prefixOwner(c *gin.Context) (int, bool) {
+	orgID, ok := orgIDParam(c)
+	if !ok {
+		return 0, false
+	}
+	role, ok := orgRole(c, orgID)
+	if !ok {
+		return 0, false
+	}
+	if role != db.RoleOwner {
+		respondError(c, apierror.Forbidden("Only owners can manage reserved prefixes"))
+		return 0, false
+	}
+	return orgID, true
+}
+
+// getOrgReservedPrefixes lists the prefixes an organization reserved to its members
+func getOrgReservedPrefixes(c *gin.Context) {
+	orgID, ok := orgIDParam(c)
+	if !ok {
+		return
+	}
+	if _, ok := orgRole(c, orgID); !ok {
+		return
+	}
+
+	prefixes, err := database.GetReservedPrefixes(c.Request.Context(), orgID)
+	if err != nil {
+		respondError(c, apierror.Internal("Database error").Wrap(err))
+		return
+	}
+	c.JSON(http.StatusOK, prefixes)
+}
+
+// createOrgReservedPrefix reserves a short code prefix, e.g. acme-*, for the
+// links of an organization, so nobody else can create codes under it. Links
+// already using such codes are kept.
+func createOrgReservedPrefix(c *gin.Context) {
+	orgID, ok := prefixOwner(c)
+	if !ok {
+		return
+	}
+
+	var request struct {
+		Prefix string `json:"prefix" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		respondError(c, apierror.Validation("prefix is required"))
+		return
+	}
+	prefix, ok := normalizePrefix(request.Prefix)
+	if !ok {
+		respondError(c, apierror.Validation("prefix must be at least "+strconv.Itoa(minPrefixLength)+" letters, digits, \"-\" or \"_\""))
+		return
+	}
+
+	ctx := c.Request.Context()
+	existing, err := database.GetReservedPrefixes(ctx, orgID)
+	if err != nil {
+		respondError(c, apierror.Internal("Database error").Wrap(err))
+		return
+	}
+	if len(existing) >= maxReservedPrefixes {
+		respondError(c, apierror.Unprocessable("An organization can reserve at most "+strconv.Itoa(maxReservedPrefixes)+" prefixes"))
+		return
+	}
+
+	reserved := db.ReservedPrefix{OrgID: orgID, Prefix: prefix}
+	err = database.CreateReservedPrefix(ctx, &reserved)
+	if errors.Is(err, db.ErrPrefixOverlaps) {
+		respondError(c, apierror.Conflict("Prefix is already reserved or overlaps a prefix reserved by another organization"))
+		return
+	}
+	if err != nil {
+		respondError(c, apierror.Internal("Failed to store reserved prefix").Wrap(err))
+		return
+	}
+
+	recordAudit(c, auditCreate, "reserved_prefix", strconv.Itoa(reserved.ID), nil, reserved)
+	c.JSON(http.StatusCreated, reserved)
+}
+
+// deleteOrgReservedPrefix releases a prefix, letting anyone create codes under it again
+func deleteOrgReservedPrefix(c *gin.Context) {
+	orgID, ok := prefixOwner(c)
+	if !ok {
+		return
+	}
+	id, err := strconv.Atoi(c.Param("prefixId"))
+	if err != nil {
+		respondError(c, apierror.NotFound("Reserved prefix not found"))
+		return
+	}
+
+	if err := database.DeleteReservedPrefix(c.Request.Context(), orgID, id); err != nil {
+		respondError(c, notFound(err, "Reserved prefix not found"))
+		return
+	}
+
+	recordAudit(c, auditDelete, "reserved_prefix", strconv.Itoa(id), nil, nil)
+	c.JSON(http.StatusOK, gin.H{"message": "Reserved prefix released"})
+}
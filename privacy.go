@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+	"url-shortener/config"
+	"url-shortener/middleware"
+	"url-shortener/pkg/apierror"
+	"url-shortener/pkg/jobs"
+
+	"github.com/gin-gonic/gin"
+)
+
+// rollupInterval is how often expired click events are folded into rollups
+const rollupInterval = 24 * time.Hour
+
+// ipHashKey salts the hashes client IPs are stored as
+var ipHashKey []byte
+
+// configurePrivacy sets up IP hashing and schedules pruning past days' visitor
+// records and, with CLICK_RETENTION_DAYS set, rolling up expired click events.
+// A partitioned clicks table expires whole partitions instead.
+func configurePrivacy(cfg *config.Config) error {
+	ipHashKey = []byte(cfg.Privacy.IPHashSalt)
+	visitorCookieEnabled = cfg.Privacy.VisitorCookie
+	if len(ipHashKey) == 0 {
+		ipHashKey = make([]byte, 32)
+		if _, err := rand.Read(ipHashKey); err != nil {
+			return err
+		}
+		log.Println("Warning: IP_HASH_SALT is not set; stored IP hashes will not match across restarts or instances")
+	}
+
+	if cfg.Privacy.ClickRetentionDays > 0 && !cfg.Database.ClickPartitioning {
+		retention := time.Duration(cfg.Privacy.ClickRetentionDays) * 24 * time.Hour
+		backgroundJobs.Schedule(jobs.Job{Name: "rollup-clicks", Every: rollupInterval, RunAtStart: true, Run: func(ctx context.Context) error {
+			return rollupClicks(ctx, retention)
+		}})
+	}
+	backgroundJobs.Schedule(jobs.Job{Name: "prune-visits", Every: time.Hour, RunAtStart: true, Run: pruneVisits})
+	backgroundJobs.Schedule(jobs.Job{Name: "prune-sessions", Every: time.Hour, RunAtStart: true, Run: pruneSessions})
+	return nil
+}
+
+// hashIP is how client IPs are stored: entries from one address can still be
+// matched, but the address itself is not kept
+func hashIP(ip string) string {
+	if ip == "" {
+		return ""
+	}
+	mac := hmac.New(sha256.New, ipHashKey)
+	mac.Write([]byte(ip))
+	return hex.EncodeToString(mac.Sum(nil)[:16])
+}
+
+// rollupClicks folds click events older than retention into daily per-link
+// counts, so stats keep their totals after the raw events are deleted
+func rollupClicks(ctx context.Context, retention time.Duration) error {
+	cutoff := time.Now().Add(-retention)
+	rows, err := database.RollupClicks(ctx, cutoff)
+	if err != nil {
+		return err
+	}
+	if rows > 0 {
+		log.Printf("Rolled up click events before %s into %d daily rollups", cutoff.Format(time.DateOnly), rows)
+	}
+	return nil
+}
+
+// pruneVisits drops visitor records once their day is over, as unique click
+// counting only compares against the current day
+func pruneVisits(ctx context.Context) error {
+	_, err := database.PruneVisits(ctx)
+	return err
+}
+
+// pruneSessions drops sessions once they have expired, as they can no
+// longer sign anyone in
+func pruneSessions(ctx context.Context) error {
+	_, err := database.PruneSessions(ctx)
+	return err
+}
+
+// eraseUserData handles right-to-be-forgotten requests: it deletes the user's
+// account and links with their click events, and anonymizes the user in the
+// audit log. Users may erase themselves; admins may erase anyone.
+func eraseUserData(c *gin.Context) {
+	userID := c.Param("id")
+	caller := c.GetString(middleware.ContextUserID)
+	if !isAdmin(c) && caller != userID {
+		if caller == "" {
+			respondError(c, apierror.Unauthorized("Authentication required"))
+			return
+		}
+		respondError(c, apierror.Forbidden("You can only erase your own data"))
+		return
+	}
+
+	links, err := database.EraseUser(c.Request.Context(), userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondError(c, apierror.NotFound("User not found"))
+		return
+	}
+	if err != nil {
+		respondError(c, apierror.Internal("Failed to erase user data").Wrap(err))
+		return
+	}
+
+	// A self-erasure is not audited, as the entry would name the erased user as actor
+	if caller == userID {
+		c.SetCookie(sessionCookie, "", -1, "/", "", isSecureRequest(c), true)
+	} else {
+		recordAudit(c, auditDelete, "user", userID, gin.H{"links": links}, nil)
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "User data erased", "deletedLinks": links})
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+	"url-shortener/db"
+	"url-shortener/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// publicStatsDays is how many days the public stats page charts
+	publicStatsDays = 30
+	// publicStatsCountries is how many countries the public stats page lists
+	publicStatsCountries = 10
+)
+
+// statsBar is one bar of a chart on the public stats page, its height or
+// width a percentage of the largest bar
+type statsBar struct {
+	Label   string
+	Clicks  int
+	Percent int
+}
+
+func statsBars(entries []db.BreakdownEntry) []statsBar {
+	top := 0
+	for _, entry := range entries {
+		top = max(top, entry.Clicks)
+	}
+	bars := make([]statsBar, len(entries))
+	for i, entry := range entries {
+		bars[i] = statsBar{Label: entry.Name, Clicks: entry.Clicks}
+		if top > 0 {
+			bars[i].Percent = entry.Clicks * 100 / top
+		}
+	}
+	return bars
+}
+
+// linkSubpath serves /:shortCode/*path: the public stats page for /stats on
+// links that opted in, and the redirect, with the path forwarded, otherwise
+func linkSubpath(c *gin.Context) {
+	if c.Param("path") == "/stats" && publicStatsPage(c) {
+		return
+	}
+	getOriginalURL(c)
+}
+
+// publicStatsPage renders the shareable stats dashboard of a link with
+// publicStats set, or requested with a URL signed for its stats, reporting
+// false when the link has none
+func publicStatsPage(c *gin.Context) bool {
+	shortCode := c.Param("shortCode")
+	if !codeMayExist(shortCode) {
+		return false
+	}
+
+	link, err := resolveLink(c, shortCode)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false
+	}
+	signed := false
+	if err == nil && !link.PublicStats {
+		if signed = signedStatsPage(c, shortCode); !signed {
+			return false
+		}
+	}
+
+	var series []db.DailyClicks
+	var countries []db.BreakdownEntry
+	var breakdown *db.ClickBreakdown
+	if err == nil {
+		series, err = database.GetDailyClicks(c.Request.Context(), link.ID, publicStatsDays)
+	}
+	if err == nil {
+		countries, err = database.GetTopCountries(c.Request.Context(), link.ID, publicStatsCountries)
+	}
+	if err == nil {
+		breakdown, err = database.GetClickBreakdown(c.Request.Context(), link.ID)
+	}
+	if err != nil {
+		log.Printf("Failed to load public stats of %s (request %s): %v", shortCode, c.GetString(middleware.ContextRequestID), err)
+		c.HTML(http.StatusInternalServerError, "error.html", gin.H{
+			"message": "Something went wrong. Please try again.",
+		})
+		return true
+	}
+
+	days := make([]db.BreakdownEntry, len(series))
+	for i, day := range series {
+		days[i] = db.BreakdownEntry{Name: day.Date, Clicks: day.Clicks}
+	}
+
+	created := link.CreatedAt
+	if len(created) > len(time.DateOnly) {
+		created = created[:len(time.DateOnly)]
+	}
+
+	if signed {
+		c.Header("Cache-Control", "private, no-store")
+	} else {
+		c.Header("Cache-Control", "public, max-age=60")
+	}
+	c.HTML(http.StatusOK, "stats.html", gin.H{
+		"shortURL":  shortURLFor(c, link.Domain, link.ShortCode),
+		"link":      link,
+		"created":   created,
+		"days":      statsBars(days),
+		"countries": statsBars(countries),
+		"devices":   statsBars(breakdown.Devices),
+		"browsers":  statsBars(breakdown.Browsers),
+	})
+	return true
+}
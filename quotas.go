@@ -0,0 +1,497 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	"url-shortener/config"
+	"url-shortener/middleware"
+	"url-shortener/pkg/apierror"
+
+	"github.com/gin-gonic/gin"
+)
+
+// apiUsageFlushInterval is how often metered API calls are written to the
+// database, where every instance adds up its share
+const apiUsageFlushInterval = 10 * time.Second
+
+// plan is a set of limits users and organizations are put on; 0 means no limit
+type plan struct {
+	Name            string
+	Links           int
+	MonthlyLinks    int
+	MonthlyAPICalls int64
+}
+
+// quotaPlans are the plans of QUOTA_PLANS and the one of accounts without a plan
+type quotaPlans struct {
+	plans       map[string]plan
+	defaultPlan string
+}
+
+// planLimits holds the plans in effect; with none configured nothing is limited
+var planLimits atomic.Pointer[quotaPlans]
+
+// prepareQuotas parses QUOTA_PLANS, each plan written as
+// name:links:monthlyLinks:monthlyApiCalls
+func prepareQuotas(cfg *config.Config) (func(), error) {
+	plans := &quotaPlans{plans: make(map[string]plan, len(cfg.Quotas.Plans)), defaultPlan: cfg.Quotas.DefaultPlan}
+	for _, entry := range cfg.Quotas.Plans {
+		fields := strings.Split(entry, ":")
+		if len(fields) != 4 || fields[0] == "" {
+			return nil, fmt.Errorf("invalid plan %q in QUOTA_PLANS", entry)
+		}
+		var limits [3]int64
+		for i, field := range fields[1:] {
+			n, err := strconv.ParseInt(field, 10, 64)
+			if err != nil || n < 0 {
+				return nil, fmt.Errorf("invalid limit %q for plan %q in QUOTA_PLANS", field, fields[0])
+			}
+			limits[i] = n
+		}
+		plans.plans[fields[0]] = plan{Name: fields[0], Links: int(limits[0]), MonthlyLinks: int(limits[1]), MonthlyAPICalls: limits[2]}
+	}
+	if _, ok := plans.plans[plans.defaultPlan]; len(plans.plans) > 0 && !ok {
+		return nil, fmt.Errorf("QUOTA_DEFAULT_PLAN %q is not in QUOTA_PLANS", plans.defaultPlan)
+	}
+	return func() { planLimits.Store(plans) }, nil
+}
+
+// quotasEnabled reports whether any plan is configured
+func quotasEnabled() bool {
+	plans := planLimits.Load()
+	return plans != nil && len(plans.plans) > 0
+}
+
+// planFor returns the limits of a plan, those of the default plan for "" or a
+// plan no longer configured, and no limits when there are no plans
+func planFor(name string) plan {
+	plans := planLimits.Load()
+	if plans == nil {
+		return plan{Name: name}
+	}
+	if name == "" {
+		name = plans.defaultPlan
+	}
+	if p, ok := plans.plans[name]; ok {
+		return p
+	}
+	if p, ok := plans.plans[plans.defaultPlan]; ok {
+		return p
+	}
+	return plan{Name: name}
+}
+
+// knownPlan reports whether a plan is in QUOTA_PLANS or sold through billing
+func knownPlan(name string) bool {
+	if plans := planLimits.Load(); plans != nil {
+		if _, ok := plans.plans[name]; ok {
+			return true
+		}
+	}
+	_, ok := billingPlan(name)
+	return ok
+}
+
+// accountPlan returns the plan an organization's links, or a user's personal
+// links when orgID is 0, are limited by
+func accountPlan(ctx context.Context, userID string, orgID int) (plan, error) {
+	var name string
+	var err error
+	if orgID > 0 {
+		name, err = database.GetOrgPlan(ctx, orgID)
+	} else {
+		name, err = database.GetUserPlan(ctx, userID)
+	}
+	if err != nil {
+		return plan{}, err
+	}
+	return planFor(name), nil
+}
+
+// monthStart is when the calendar month of t began, in UTC; monthly quotas
+// reset then
+func monthStart(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
+
+// quotaDetails describes an exceeded quota in an error response
+type quotaDetails struct {
+	Quota   string     `json:"quota"`
+	Plan    string     `json:"plan"`
+	Limit   int64      `json:"limit"`
+	Used    int64      `json:"used"`
+	ResetAt *time.Time `json:"resetAt,omitempty"`
+}
+
+// checkLinkQuota writes a 402 and returns false when the plan of the
+// organization, or of the user for personal links, allows no more links.
+// Admins and anonymous clients are not limited.
+func checkLinkQuota(c *gin.Context, orgID int) bool {
+	userID := c.GetString(middleware.ContextUserID)
+	if userID == "" || isAdmin(c) || !quotasEnabled() {
+		return true
+	}
+
+	ctx := c.Request.Context()
+	p, err := accountPlan(ctx, userID, orgID)
+	if err != nil {
+		respondError(c, apierror.Internal("Database error").Wrap(err))
+		return false
+	}
+	if p.Links == 0 && p.MonthlyLinks == 0 {
+		return true
+	}
+
+	period := monthStart(time.Now())
+	total, recent, err := database.CountLinks(ctx, userID, orgID, period)
+	if err != nil {
+		respondError(c, apierror.Internal("Database error").Wrap(err))
+		return false
+	}
+	switch {
+	case p.Links > 0 && total >= p.Links:
+		details := quotaDetails{Quota: "links", Plan: p.Name, Limit: int64(p.Links), Used: int64(total)}
+		respondError(c, apierror.PaymentRequired("Link limit of your plan reached").WithDetails(details))
+		return false
+	case p.MonthlyLinks > 0 && recent >= p.MonthlyLinks:
+		resetAt := period.AddDate(0, 1, 0)
+		details := quotaDetails{Quota: "monthlyLinks", Plan: p.Name, Limit: int64(p.MonthlyLinks), Used: int64(recent), ResetAt: &resetAt}
+		respondError(c, apierror.PaymentRequired("Monthly link limit of your plan reached").WithDetails(details))
+		return false
+	}
+	return true
+}
+
+// apiUsageKey identifies a user's API calls in a month, e.g. "2024-05"
+type apiUsageKey struct {
+	userID string
+	month  string
+}
+
+// apiUsage is a user's API calls this month: stored were counted in the
+// database when last read, pending on this instance since
+type apiUsage struct {
+	month   time.Time
+	plan    string
+	stored  int64
+	pending int64
+}
+
+var (
+	// apiUsageMu guards apiUsageByUser and the entries in it
+	apiUsageMu     sync.Mutex
+	apiUsageByUser = make(map[apiUsageKey]*apiUsage)
+)
+
+// meterAPICalls counts the API calls of signed-in users against their plan,
+// rejecting them with a 429 once the monthly limit is reached. Calls are
+// counted in memory and flushed periodically, so across instances a user may
+// go slightly over before being stopped. Checking usage is never refused, so
+// users can always see why they were.
+func meterAPICalls(c *gin.Context) {
+	userID := c.GetString(middleware.ContextUserID)
+	if userID == "" || !quotasEnabled() || strings.HasSuffix(c.FullPath(), "/account/usage") || isAdmin(c) {
+		c.Next()
+		return
+	}
+
+	now := time.Now()
+	key := apiUsageKey{userID, now.UTC().Format("2006-01")}
+	usage, err := loadAPIUsage(c.Request.Context(), key, monthStart(now))
+	if err != nil {
+		// Failing to meter should not take the API down with the database
+		log.Printf("Failed to load API usage of %s: %v", userID, err)
+		c.Next()
+		return
+	}
+
+	apiUsageMu.Lock()
+	// A flush may have dropped the entry since it was loaded
+	if current, ok := apiUsageByUser[key]; ok {
+		usage = current
+	} else {
+		apiUsageByUser[key] = usage
+	}
+	p := planFor(usage.plan)
+	used := usage.stored + usage.pending
+	exceeded := p.MonthlyAPICalls > 0 && used >= p.MonthlyAPICalls
+	if !exceeded {
+		usage.pending++
+	}
+	apiUsageMu.Unlock()
+
+	if exceeded {
+		resetAt := usage.month.AddDate(0, 1, 0)
+		c.Header("Retry-After", strconv.Itoa(int(time.Until(resetAt).Seconds())+1))
+		details := quotaDetails{Quota: "monthlyApiCalls", Plan: p.Name, Limit: p.MonthlyAPICalls, Used: used, ResetAt: &resetAt}
+		apierror.Abort(c, apierror.QuotaExceeded("Monthly API call limit of your plan reached").WithDetails(details))
+		return
+	}
+	c.Next()
+}
+
+// loadAPIUsage returns the metered API calls of a user for a month, reading
+// their plan and the calls counted so far on first use
+func loadAPIUsage(ctx context.Context, key apiUsageKey, month time.Time) (*apiUsage, error) {
+	apiUsageMu.Lock()
+	usage, ok := apiUsageByUser[key]
+	apiUsageMu.Unlock()
+	if ok {
+		return usage, nil
+	}
+
+	planName, err := database.GetUserPlan(ctx, key.userID)
+	if err != nil {
+		return nil, err
+	}
+	stored, err := database.GetAPICalls(ctx, key.userID, month)
+	if err != nil {
+		return nil, err
+	}
+
+	apiUsageMu.Lock()
+	defer apiUsageMu.Unlock()
+	// Another request may have loaded it meanwhile; keep the calls it counted
+	if usage, ok := apiUsageByUser[key]; ok {
+		return usage, nil
+	}
+	usage = &apiUsage{month: month, plan: planName, stored: stored}
+	apiUsageByUser[key] = usage
+	return usage, nil
+}
+
+// meteredPlanChanged meters this instance's calls by a user against their new
+// plan at once; others pick it up within apiUsageFlushInterval of the user's
+// last call
+func meteredPlanChanged(userID, name string) {
+	apiUsageMu.Lock()
+	defer apiUsageMu.Unlock()
+	for key, usage := range apiUsageByUser {
+		if key.userID == userID {
+			usage.plan = name
+		}
+	}
+}
+
+// currentAPICalls returns a user's API calls in a month, including those not
+// flushed yet
+func currentAPICalls(ctx context.Context, userID string, month time.Time) (int64, error) {
+	apiUsageMu.Lock()
+	usage, ok := apiUsageByUser[apiUsageKey{userID, month.Format("2006-01")}]
+	var calls int64
+	if ok {
+		calls = usage.stored + usage.pending
+	}
+	apiUsageMu.Unlock()
+	if ok {
+		return calls, nil
+	}
+	return database.GetAPICalls(ctx, userID, month)
+}
+
+// flushAPIUsage writes the API calls metered since the last flush. Users
+// without calls since are dropped from memory, so the next call reloads
+// their plan and what other instances counted.
+func flushAPIUsage(ctx context.Context) error {
+	byMonth := make(map[time.Time]map[string]int64)
+	apiUsageMu.Lock()
+	for key, usage := range apiUsageByUser {
+		if usage.pending == 0 {
+			delete(apiUsageByUser, key)
+			continue
+		}
+		if byMonth[usage.month] == nil {
+			byMonth[usage.month] = make(map[string]int64)
+		}
+		byMonth[usage.month][key.userID] = usage.pending
+		usage.pending = 0
+	}
+	apiUsageMu.Unlock()
+
+	var errs []error
+	for month, calls := range byMonth {
+		totals, err := database.AddAPICalls(ctx, month, calls)
+		apiUsageMu.Lock()
+		for userID, n := range calls {
+			usage, ok := apiUsageByUser[apiUsageKey{userID, month.Format("2006-01")}]
+			switch {
+			case !ok:
+			case err != nil:
+				// Count them again on the next flush
+				usage.pending += n
+			default:
+				usage.stored = totals[userID]
+			}
+		}
+		apiUsageMu.Unlock()
+		if err != nil {
+			errs = append(errs, fmt.Errorf("flushing API usage for %s: %w", month.Format("2006-01"), err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// quotaUsage is how much of a quota is used; a limit of 0 means no limit
+type quotaUsage struct {
+	Used  int64 `json:"used"`
+	Limit int64 `json:"limit"`
+}
+
+// accountUsage is the consumption of a user or organization against its plan
+type accountUsage struct {
+	Plan            string     `json:"plan"`
+	OrgID           int        `json:"orgId,omitempty"`
+	PeriodStart     time.Time  `json:"periodStart"`
+	PeriodEnd       time.Time  `json:"periodEnd"`
+	Links           quotaUsage `json:"links"`
+	MonthlyLinks    quotaUsage `json:"monthlyLinks"`
+	MonthlyAPICalls quotaUsage `json:"monthlyApiCalls"`
+}
+
+// getAccountUsage reports the signed-in user's consumption this month, or
+// that of one of their organizations with ?orgId. API calls are always the
+// user's own.
+func getAccountUsage(c *gin.Context) {
+	userID := c.GetString(middleware.ContextUserID)
+	if userID == "" {
+		respondError(c, apierror.Unauthorized("Authentication required"))
+		return
+	}
+
+	var orgID int
+	if raw := c.Query("orgId"); raw != "" {
+		id, err := strconv.Atoi(raw)
+		if err != nil || id <= 0 {
+			respondError(c, apierror.Validation("Invalid organization ID"))
+			return
+		}
+		if _, err := memberRole(c, id); err != nil {
+			respondError(c, err)
+			return
+		}
+		orgID = id
+	}
+
+	ctx := c.Request.Context()
+	p, err := accountPlan(ctx, userID, orgID)
+	if err != nil {
+		respondError(c, apierror.Internal("Database error").Wrap(err))
+		return
+	}
+	period := monthStart(time.Now())
+	total, recent, err := database.CountLinks(ctx, userID, orgID, period)
+	if err != nil {
+		respondError(c, apierror.Internal("Database error").Wrap(err))
+		return
+	}
+	calls, err := currentAPICalls(ctx, userID, period)
+	if err != nil {
+		respondError(c, apierror.Internal("Database error").Wrap(err))
+		return
+	}
+	callsPlan := p
+	if orgID > 0 {
+		if callsPlan, err = accountPlan(ctx, userID, 0); err != nil {
+			respondError(c, apierror.Internal("Database error").Wrap(err))
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, accountUsage{
+		Plan:            p.Name,
+		OrgID:           orgID,
+		PeriodStart:     period,
+		PeriodEnd:       period.AddDate(0, 1, 0),
+		Links:           quotaUsage{Used: int64(total), Limit: int64(p.Links)},
+		MonthlyLinks:    quotaUsage{Used: int64(recent), Limit: int64(p.MonthlyLinks)},
+		MonthlyAPICalls: quotaUsage{Used: calls, Limit: callsPlan.MonthlyAPICalls},
+	})
+}
+
+// bindPlan reads the plan to assign from the request body, writing a 400
+// when it is not known; "" selects the default plan
+func bindPlan(c *gin.Context) (string, bool) {
+	var request struct {
+		Plan string `json:"plan"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		respondError(c, apierror.Validation("Invalid request body"))
+		return "", false
+	}
+	request.Plan = strings.TrimSpace(request.Plan)
+	if request.Plan != "" && !knownPlan(request.Plan) {
+		respondError(c, apierror.Validation("Unknown plan"))
+		return "", false
+	}
+	return request.Plan, true
+}
+
+// setUserPlan puts a user on a plan
+func setUserPlan(c *gin.Context) {
+	userID := c.Param("id")
+	name, ok := bindPlan(c)
+	if !ok {
+		return
+	}
+
+	ctx := c.Request.Context()
+	previous, err := database.GetUserPlan(ctx, userID)
+	if err != nil {
+		respondError(c, apierror.Internal("Database error").Wrap(err))
+		return
+	}
+	err = database.SetUserPlan(ctx, userID, name)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondError(c, apierror.NotFound("User not found"))
+		return
+	}
+	if err != nil {
+		respondError(c, apierror.Internal("Database error").Wrap(err))
+		return
+	}
+
+	meteredPlanChanged(userID, name)
+	recordAudit(c, auditUpdate, "user", userID, gin.H{"plan": previous}, gin.H{"plan": name})
+	c.JSON(http.StatusOK, gin.H{"userId": userID, "plan": planFor(name).Name})
+}
+
+// setOrgPlan puts an organization on a plan
+func setOrgPlan(c *gin.Context) {
+	orgID, ok := orgIDParam(c)
+	if !ok {
+		return
+	}
+	name, ok := bindPlan(c)
+	if !ok {
+		return
+	}
+
+	ctx := c.Request.Context()
+	previous, err := database.GetOrgPlan(ctx, orgID)
+	if err != nil {
+		respondError(c, apierror.Internal("Database error").Wrap(err))
+		return
+	}
+	err = database.SetOrgPlan(ctx, orgID, name)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondError(c, apierror.NotFound("Organization not found"))
+		return
+	}
+	if err != nil {
+		respondError(c, apierror.Internal("Database error").Wrap(err))
+		return
+	}
+
+	recordAudit(c, auditUpdate, "organization", strconv.Itoa(orgID), gin.H{"plan": previous}, gin.H{"plan": name})
+	c.JSON(http.StatusOK, gin.H{"orgId": orgID, "plan": planFor(name).Name})
+}
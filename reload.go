@@ -0,0 +1,288 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	"url-shortener/config"
+	"url-shortener/middleware"
+	"url-shortener/pkg/apierror"
+	"url-shortener/pkg/botdetect"
+	"url-shortener/pkg/jobs"
+	"url-shortener/pkg/pagemeta"
+
+	"github.com/gin-contrib/cors"
+	"github.com/gin-gonic/gin"
+	"github.com/joho/godotenv"
+)
+
+// envFile is the configuration file read at startup and on reload
+const envFile = ".env"
+
+var (
+	// reloadMu serializes reloads and guards the state below
+	reloadMu sync.Mutex
+	// startupConfig is what the process started with and currentConfig what
+	// the reloadable sections were last built from
+	startupConfig, currentConfig *config.Config
+	// processEnv are the variables set before envFile was read, which it does
+	// not override; fileEnv are those last set from it
+	processEnv, fileEnv map[string]bool
+)
+
+// rateLimiter is the per-IP limiter middleware; nil when rate limiting is disabled
+var rateLimiter *middleware.RateLimiter
+
+// corsHandler answers CORS for the currently allowed origins
+var corsHandler atomic.Pointer[gin.HandlerFunc]
+
+// reloadableSection is a part of the configuration that is applied without a
+// restart. part points at the settings it covers; prepare validates them and
+// returns a func installing them, so an invalid section keeps a reload from
+// changing anything.
+type reloadableSection struct {
+	name    string
+	part    func(cfg *config.Config) any
+	prepare func(cfg *config.Config) (func(), error)
+}
+
+var reloadableSections = []reloadableSection{
+	{"RateLimit", func(cfg *config.Config) any { return &cfg.RateLimit.RequestsPerMinute }, prepareRateLimit},
+	{"BotFilter", func(cfg *config.Config) any { return &cfg.BotFilter }, prepareBotFilter},
+	{"ClickFraud", func(cfg *config.Config) any { return &cfg.ClickFraud }, prepareClickFraud},
+	{"PageMeta", func(cfg *config.Config) any { return &cfg.PageMeta }, preparePageMeta},
+	{"Archive", func(cfg *config.Config) any { return &cfg.Archive.OnDelete }, prepareArchive},
+	{"CORS", func(cfg *config.Config) any { return &cfg.CORS }, prepareCORS},
+	{"FeatureFlags", func(cfg *config.Config) any { return &cfg.Features.Enabled }, prepareFeatureFlags},
+	{"Quotas", func(cfg *config.Config) any { return &cfg.Quotas }, prepareQuotas},
+	{"Frames", func(cfg *config.Config) any { return &cfg.Frames }, prepareFrames},
+}
+
+func prepareRateLimit(cfg *config.Config) (func(), error) {
+	if cfg.RateLimit.RequestsPerMinute <= 0 {
+		return nil, errors.New("RATE_LIMIT_REQUESTS_PER_MINUTE must be positive")
+	}
+	return func() {
+		if rateLimiter != nil {
+			rateLimiter.SetBaseline(cfg.RateLimit.RequestsPerMinute)
+		}
+	}, nil
+}
+
+func prepareBotFilter(cfg *config.Config) (func(), error) {
+	var detector *botdetect.Detector
+	if cfg.BotFilter.Enabled {
+		detector = botdetect.New(cfg.BotFilter.ExtraPatterns, cfg.BotFilter.AllowPatterns)
+	}
+	return func() { botDetector.Store(detector) }, nil
+}
+
+func prepareClickFraud(cfg *config.Config) (func(), error) {
+	if !cfg.ClickFraud.Enabled {
+		return func() { fraudDetector.Store(nil) }, nil
+	}
+	detector, err := newFraudDetector(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return func() { fraudDetector.Store(detector) }, nil
+}
+
+func preparePageMeta(cfg *config.Config) (func(), error) {
+	var fetcher *pagemeta.Fetcher
+	if cfg.PageMeta.Enabled {
+		fetcher = pagemeta.NewFetcher(cfg.PageMeta.Timeout, cfg.PageMeta.UserAgent, cfg.PageMeta.RespectRobots)
+	}
+	return func() { pageFetcher.Store(fetcher) }, nil
+}
+
+func prepareArchive(cfg *config.Config) (func(), error) {
+	if cfg.Archive.OnDelete && objectStore == nil {
+		log.Println("Warning: ARCHIVE_ON_DELETE is set but no OBJECT_STORE is configured; deletes will not be archived")
+	}
+	return func() { archiveOnDelete.Store(cfg.Archive.OnDelete && objectStore != nil) }, nil
+}
+
+// prepareCORS allows browsers on CORS_ALLOWED_ORIGINS, or on any origin when
+// none are listed, to call the API
+func prepareCORS(cfg *config.Config) (func(), error) {
+	corsConfig := cors.DefaultConfig()
+	if len(cfg.CORS.AllowedOrigins) > 0 {
+		corsConfig.AllowOrigins = cfg.CORS.AllowedOrigins
+	} else {
+		corsConfig.AllowAllOrigins = true
+	}
+	corsConfig.AddAllowHeaders("Authorization", managementTokenHeader, captchaHeader, apiKeyHeader)
+	corsConfig.AddExposeHeaders(middleware.RequestIDHeader, "ETag", "Retry-After", "X-RateLimit-Limit", "X-RateLimit-Remaining", "X-RateLimit-Reset")
+	if err := corsConfig.Validate(); err != nil {
+		return nil, err
+	}
+
+	handler := cors.New(corsConfig)
+	return func() { corsHandler.Store(&handler) }, nil
+}
+
+// applyCORS runs the CORS handler in place when the middleware is reached
+func applyCORS(c *gin.Context) {
+	(*corsHandler.Load())(c)
+}
+
+// loadEnvFile sets the variables of envFile that are not already set in the
+// environment, remembering which ones it set so a reload can change them
+func loadEnvFile() error {
+	processEnv, fileEnv = make(map[string]bool), make(map[string]bool)
+	for _, variable := range os.Environ() {
+		name, _, _ := strings.Cut(variable, "=")
+		processEnv[name] = true
+	}
+	return readEnvFile()
+}
+
+// readEnvFile sets the variables of envFile, unsetting those removed from it
+// since it was last read. Callers hold reloadMu, except at startup.
+func readEnvFile() error {
+	values, err := godotenv.Read(envFile)
+	if err != nil {
+		return err
+	}
+
+	for name := range fileEnv {
+		if _, ok := values[name]; !ok {
+			os.Unsetenv(name)
+			delete(fileEnv, name)
+		}
+	}
+	for name, value := range values {
+		if processEnv[name] {
+			continue
+		}
+		os.Setenv(name, value)
+		fileEnv[name] = true
+	}
+	return nil
+}
+
+// configureReloadable applies every reloadable section of the configuration
+// the process starts with
+func configureReloadable(cfg *config.Config) error {
+	reloadMu.Lock()
+	defer reloadMu.Unlock()
+
+	for _, section := range reloadableSections {
+		install, err := section.prepare(cfg)
+		if err != nil {
+			return fmt.Errorf("%s: %w", section.name, err)
+		}
+		install()
+	}
+	startupConfig, currentConfig = cfg, cfg
+	return nil
+}
+
+// reloadConfig reads envFile again and applies the reloadable sections that
+// changed, returning their names along with the changed sections that only
+// take effect after a restart. Nothing is applied when any new setting is
+// invalid. The destination blocklist, IP rules and feature flag overrides are
+// reloaded from the database as well.
+func reloadConfig(ctx context.Context) (applied, restartRequired []string, err error) {
+	reloadMu.Lock()
+	defer reloadMu.Unlock()
+
+	if err := readEnvFile(); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, nil, err
+	}
+	cfg := config.GetDefaultConfig()
+
+	applied = []string{}
+	var installs []func()
+	for _, section := range reloadableSections {
+		if reflect.DeepEqual(section.part(currentConfig), section.part(cfg)) {
+			continue
+		}
+		install, err := section.prepare(cfg)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s: %w", section.name, err)
+		}
+		installs = append(installs, install)
+		applied = append(applied, section.name)
+	}
+	for _, install := range installs {
+		install()
+	}
+	currentConfig = cfg
+
+	if err := loadIPRules(ctx); err != nil {
+		log.Printf("Warning: failed to reload IP rules: %v", err)
+	}
+	if err := loadBlocklist(ctx); err != nil {
+		log.Printf("Warning: failed to reload destination blocklist: %v", err)
+	}
+	if err := loadFeatureFlags(ctx); err != nil {
+		log.Printf("Warning: failed to reload feature flags: %v", err)
+	}
+	return applied, changedSections(startupConfig, cfg), nil
+}
+
+// changedSections names the top-level sections that differ between two
+// configurations, leaving out the settings reloadableSections cover
+func changedSections(before, after *config.Config) []string {
+	a, b := *before, *after
+	for _, section := range reloadableSections {
+		reflect.ValueOf(section.part(&a)).Elem().SetZero()
+		reflect.ValueOf(section.part(&b)).Elem().SetZero()
+	}
+
+	changed := []string{}
+	va, vb := reflect.ValueOf(a), reflect.ValueOf(b)
+	for i := range va.NumField() {
+		if !reflect.DeepEqual(va.Field(i).Interface(), vb.Field(i).Interface()) {
+			changed = append(changed, va.Type().Field(i).Name)
+		}
+	}
+	return changed
+}
+
+// watchConfigFile returns the job that reloads the configuration whenever
+// envFile was modified since its last run
+func watchConfigFile() jobs.Func {
+	var modified time.Time
+	if info, err := os.Stat(envFile); err == nil {
+		modified = info.ModTime()
+	}
+
+	return func(ctx context.Context) error {
+		info, err := os.Stat(envFile)
+		if err != nil || !info.ModTime().After(modified) {
+			return nil
+		}
+		modified = info.ModTime()
+
+		applied, restartRequired, err := reloadConfig(ctx)
+		if err != nil {
+			return fmt.Errorf("reloading %s: %w", envFile, err)
+		}
+		log.Printf("Reloaded %s: applied %v; changes to %v need a restart", envFile, applied, restartRequired)
+		return nil
+	}
+}
+
+// reloadConfigHandler applies changes to the configuration file without a
+// restart, so in-flight redirects are not dropped
+func reloadConfigHandler(c *gin.Context) {
+	applied, restartRequired, err := reloadConfig(c.Request.Context())
+	if err != nil {
+		respondError(c, apierror.Validation("Configuration not reloaded: "+err.Error()))
+		return
+	}
+
+	recordAudit(c, auditReloadConfig, "config", envFile, nil, gin.H{"applied": applied, "restartRequired": restartRequired})
+	c.JSON(http.StatusOK, gin.H{"applied": applied, "restartRequired": restartRequired})
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"log"
+	"math/rand/v2"
+	"net/http"
+	"url-shortener/db"
+	"url-shortener/pkg/apierror"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxRotationDestinations caps how many destinations a link rotates through
+const maxRotationDestinations = 50
+
+// rotateDestination picks the destination of a rotating link. Only counted
+// clicks take a round-robin turn; crawlers and excluded clicks get a random
+// destination, so they do not skew the rotation or its stats.
+func rotateDestination(c *gin.Context, link *db.URL, counted bool) (string, bool) {
+	rotation := link.Rotation
+	if rotation == nil || len(rotation.Destinations) == 0 {
+		return "", false
+	}
+
+	index := rand.IntN(len(rotation.Destinations))
+	if !counted {
+		return rotation.Destinations[index], true
+	}
+
+	if rotation.Mode == db.RotationRoundRobin {
+		position, err := database.NextRotationPosition(c.Request.Context(), link.ShortCode)
+		if err != nil {
+			log.Printf("Failed to advance rotation of %s: %v", link.ShortCode, err)
+		} else {
+			index = int(position % int64(len(rotation.Destinations)))
+		}
+	}
+	destination := rotation.Destinations[index]
+	if err := database.RecordRotationClick(c.Request.Context(), link.ShortCode, destination); err != nil {
+		log.Printf("Failed to record click for rotated destination of %s: %v", link.ShortCode, err)
+	}
+	return destination, true
+}
+
+// normalizeRotation validates a rotation: a known mode and distinct absolute
+// destination URLs. No destinations means no rotation.
+func normalizeRotation(rotation db.Rotation) (*db.Rotation, bool) {
+	if len(rotation.Destinations) == 0 {
+		return nil, true
+	}
+	if rotation.Mode == "" {
+		rotation.Mode = db.RotationRoundRobin
+	}
+	if rotation.Mode != db.RotationRoundRobin && rotation.Mode != db.RotationRandom {
+		return nil, false
+	}
+	if len(rotation.Destinations) > maxRotationDestinations {
+		return nil, false
+	}
+
+	seen := make(map[string]bool, len(rotation.Destinations))
+	for _, destination := range rotation.Destinations {
+		if !validDestination(destination) || seen[destination] {
+			return nil, false
+		}
+		seen[destination] = true
+	}
+	return &rotation, true
+}
+
+// setURLRotation replaces the destinations a link rotates through; an empty
+// list ends the rotation and sends every visitor to the original URL again.
+// A link is either rotated or A/B tested, not both.
+func setURLRotation(c *gin.Context) {
+	shortCode := c.Param("shortCode")
+
+	var request db.Rotation
+	if err := c.ShouldBindJSON(&request); err != nil {
+		respondError(c, apierror.Validation("Invalid request body"))
+		return
+	}
+
+	rotation, ok := normalizeRotation(request)
+	if !ok {
+		respondError(c, apierror.Validation("Invalid rotation"))
+		return
+	}
+	if rotation != nil && !allowedDestinations(c, rotation.Destinations...) {
+		return
+	}
+
+	previous, err := database.GetURLByShortCode(c.Request.Context(), shortCode)
+	if err != nil {
+		respondError(c, notFound(err, "Short URL not found"))
+		return
+	}
+	if rotation != nil && len(previous.Variants) > 0 {
+		respondError(c, apierror.Conflict("End the link's A/B test before rotating its destinations"))
+		return
+	}
+
+	if err := database.SetRotation(c.Request.Context(), shortCode, rotation); err != nil {
+		respondError(c, notFound(err, "Short URL not found"))
+		return
+	}
+
+	recordAudit(c, auditUpdate, "url", shortCode, gin.H{"rotation": previous.Rotation}, gin.H{"rotation": rotation})
+	c.JSON(http.StatusOK, gin.H{"message": "Rotation updated successfully", "rotation": rotation})
+}
@@ -0,0 +1,475 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"url-shortener/db"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SCIM 2.0 schemas (RFC 7643, RFC 7644)
+const (
+	scimUserSchema   = "urn:ietf:params:scim:schemas:core:2.0:User"
+	scimListSchema   = "urn:ietf:params:scim:api:messages:2.0:ListResponse"
+	scimPatchSchema  = "urn:ietf:params:scim:api:messages:2.0:PatchOp"
+	scimErrorSchema  = "urn:ietf:params:scim:api:messages:2.0:Error"
+	scimConfigSchema = "urn:ietf:params:scim:schemas:core:2.0:ServiceProviderConfig"
+)
+
+// maxSCIMCount bounds a single page of SCIM users
+const maxSCIMCount = 200
+
+// contextSCIMOrg holds the *db.OrgSSO of a request authenticated by SCIM token
+const contextSCIMOrg = "scimOrg"
+
+// scimUserNameFilter is the only filter identity providers need: looking a
+// user up by userName before creating them
+var scimUserNameFilter = regexp.MustCompile(`(?i)^\s*userName\s+eq\s+"((?:[^"\\]|\\.)*)"\s*$`)
+
+// scimUser is the SCIM representation of a provisioned user
+type scimUser struct {
+	Schemas     []string    `json:"schemas"`
+	ID          string      `json:"id,omitempty"`
+	UserName    string      `json:"userName"`
+	DisplayName string      `json:"displayName,omitempty"`
+	Name        *scimName   `json:"name,omitempty"`
+	Emails      []scimEmail `json:"emails,omitempty"`
+	Active      *bool       `json:"active,omitempty"`
+	Meta        *scimMeta   `json:"meta,omitempty"`
+}
+
+type scimName struct {
+	Formatted  string `json:"formatted,omitempty"`
+	GivenName  string `json:"givenName,omitempty"`
+	FamilyName string `json:"familyName,omitempty"`
+}
+
+type scimEmail struct {
+	Value   string `json:"value"`
+	Type    string `json:"type,omitempty"`
+	Primary bool   `json:"primary,omitempty"`
+}
+
+type scimMeta struct {
+	ResourceType string `json:"resourceType"`
+	Created      string `json:"created"`
+	Location     string `json:"location"`
+}
+
+// email returns the primary email, or the first one
+func (u *scimUser) email() string {
+	for _, e := range u.Emails {
+		if e.Primary {
+			return e.Value
+		}
+	}
+	if len(u.Emails) > 0 {
+		return u.Emails[0].Value
+	}
+	return ""
+}
+
+// displayName returns the name to show for the user
+func (u *scimUser) displayName() string {
+	switch {
+	case u.DisplayName != "":
+		return u.DisplayName
+	case u.Name == nil:
+		return ""
+	case u.Name.Formatted != "":
+		return u.Name.Formatted
+	default:
+		return strings.TrimSpace(u.Name.GivenName + " " + u.Name.FamilyName)
+	}
+}
+
+func toSCIMUser(u *db.ProvisionedUser) scimUser {
+	id := strconv.Itoa(u.ID)
+	active := u.Active
+	user := scimUser{
+		Schemas:     []string{scimUserSchema},
+		ID:          id,
+		UserName:    u.UserName,
+		DisplayName: u.Name,
+		Active:      &active,
+		Meta:        &scimMeta{ResourceType: "User", Created: u.CreatedAt.UTC().Format("2006-01-02T15:04:05Z"), Location: "/scim/v2/Users/" + id},
+	}
+	if u.Name != "" {
+		user.Name = &scimName{Formatted: u.Name}
+	}
+	if u.Email != "" {
+		user.Emails = []scimEmail{{Value: u.Email, Type: "work", Primary: true}}
+	}
+	return user
+}
+
+// scimJSON writes a SCIM response
+func scimJSON(c *gin.Context, status int, v any) {
+	body, _ := json.Marshal(v)
+	c.Data(status, "application/scim+json", body)
+}
+
+// scimError aborts with a SCIM error; scimType may be empty
+func scimError(c *gin.Context, status int, scimType, detail string) {
+	body := gin.H{"schemas": []string{scimErrorSchema}, "status": strconv.Itoa(status), "detail": detail}
+	if scimType != "" {
+		body["scimType"] = scimType
+	}
+	scimJSON(c, status, body)
+	c.Abort()
+}
+
+// scimInternal logs a failure and aborts with a 500
+func scimInternal(c *gin.Context, err error) {
+	log.Printf("SCIM request %s %s failed: %v", c.Request.Method, c.Request.URL.Path, err)
+	scimError(c, http.StatusInternalServerError, "", "Internal error")
+}
+
+// scimAuth authenticates an organization's identity provider by the bearer
+// SCIM token issued to it
+func scimAuth(c *gin.Context) {
+	token, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+	if !ok || token == "" {
+		scimError(c, http.StatusUnauthorized, "", "Bearer token required")
+		return
+	}
+
+	sso, err := database.GetSCIMOrg(c.Request.Context(), hashSecret(strings.TrimSpace(token)))
+	if errors.Is(err, sql.ErrNoRows) {
+		scimError(c, http.StatusUnauthorized, "", "Invalid SCIM token")
+		return
+	}
+	if err != nil {
+		scimInternal(c, err)
+		return
+	}
+
+	c.Set(contextSCIMOrg, sso)
+	c.Next()
+}
+
+func scimOrg(c *gin.Context) *db.OrgSSO {
+	return c.MustGet(contextSCIMOrg).(*db.OrgSSO)
+}
+
+// scimUserParam loads the :id user of the organization, writing a 404 if
+// its identity provider doesn't know them
+func scimUserParam(c *gin.Context) (*db.ProvisionedUser, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		scimError(c, http.StatusNotFound, "", "User not found")
+		return nil, false
+	}
+
+	user, err := database.GetProvisionedUser(c.Request.Context(), scimOrg(c).OrgID, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		scimError(c, http.StatusNotFound, "", "User not found")
+		return nil, false
+	}
+	if err != nil {
+		scimInternal(c, err)
+		return nil, false
+	}
+	return user, true
+}
+
+// getSCIMServiceProviderConfig tells identity providers which SCIM features are supported
+func getSCIMServiceProviderConfig(c *gin.Context) {
+	scimJSON(c, http.StatusOK, gin.H{
+		"schemas":               []string{scimConfigSchema},
+		"patch":                 gin.H{"supported": true},
+		"bulk":                  gin.H{"supported": false, "maxOperations": 0, "maxPayloadSize": 0},
+		"filter":                gin.H{"supported": true, "maxResults": maxSCIMCount},
+		"changePassword":        gin.H{"supported": false},
+		"sort":                  gin.H{"supported": false},
+		"etag":                  gin.H{"supported": false},
+		"authenticationSchemes": []gin.H{{"type": "oauthbearertoken", "name": "Bearer token", "description": "The SCIM token issued to the organization"}},
+	})
+}
+
+// getSCIMUsers lists the users the organization's identity provider has
+// signed in or provisioned; only the userName eq filter is supported
+func getSCIMUsers(c *gin.Context) {
+	var userName string
+	if filter := c.Query("filter"); filter != "" {
+		match := scimUserNameFilter.FindStringSubmatch(filter)
+		if match == nil {
+			scimError(c, http.StatusBadRequest, "invalidFilter", `Only userName eq "..." filters are supported`)
+			return
+		}
+		userName = strings.NewReplacer(`\"`, `"`, `\\`, `\`).Replace(match[1])
+	}
+
+	startIndex, err := strconv.Atoi(c.DefaultQuery("startIndex", "1"))
+	if err != nil || startIndex < 1 {
+		startIndex = 1
+	}
+	count, err := strconv.Atoi(c.DefaultQuery("count", strconv.Itoa(maxSCIMCount)))
+	if err != nil || count < 0 || count > maxSCIMCount {
+		count = maxSCIMCount
+	}
+
+	users, total, err := database.GetProvisionedUsers(c.Request.Context(), scimOrg(c).OrgID, userName, startIndex-1, count)
+	if err != nil {
+		scimInternal(c, err)
+		return
+	}
+
+	resources := make([]scimUser, len(users))
+	for i := range users {
+		resources[i] = toSCIMUser(&users[i])
+	}
+	scimJSON(c, http.StatusOK, gin.H{
+		"schemas":      []string{scimListSchema},
+		"totalResults": total,
+		"startIndex":   startIndex,
+		"itemsPerPage": len(resources),
+		"Resources":    resources,
+	})
+}
+
+func getSCIMUser(c *gin.Context) {
+	user, ok := scimUserParam(c)
+	if !ok {
+		return
+	}
+	scimJSON(c, http.StatusOK, toSCIMUser(user))
+}
+
+// createSCIMUser provisions a user, making them a member of the organization
+// with its default role
+func createSCIMUser(c *gin.Context) {
+	var request scimUser
+	if err := c.ShouldBindJSON(&request); err != nil || strings.TrimSpace(request.UserName) == "" {
+		scimError(c, http.StatusBadRequest, "invalidValue", "userName is required")
+		return
+	}
+	sso := scimOrg(c)
+	ctx := c.Request.Context()
+
+	existing, _, err := database.GetProvisionedUsers(ctx, sso.OrgID, request.UserName, 0, 1)
+	if err != nil {
+		scimInternal(c, err)
+		return
+	}
+	if len(existing) > 0 {
+		scimError(c, http.StatusConflict, "uniqueness", "A user with this userName already exists")
+		return
+	}
+	if request.Active != nil && !*request.Active {
+		scimError(c, http.StatusBadRequest, "invalidValue", "Users are provisioned active")
+		return
+	}
+
+	user, err := database.ProvisionMember(ctx, sso.OrgID, request.UserName, request.email(), request.displayName(), sso.DefaultRole)
+	if err != nil {
+		scimInternal(c, err)
+		return
+	}
+
+	recordAudit(c, auditCreate, "organization_member", strconv.Itoa(sso.OrgID)+"/"+strconv.Itoa(user.ID), nil, gin.H{"role": sso.DefaultRole})
+	scimJSON(c, http.StatusCreated, toSCIMUser(user))
+}
+
+// replaceSCIMUser updates a user's email and name and, through active,
+// restores or offboards their membership
+func replaceSCIMUser(c *gin.Context) {
+	user, ok := scimUserParam(c)
+	if !ok {
+		return
+	}
+	var request scimUser
+	if err := c.ShouldBindJSON(&request); err != nil {
+		scimError(c, http.StatusBadRequest, "invalidSyntax", "Invalid request body")
+		return
+	}
+
+	changes := scimChanges{Email: request.email(), Name: request.displayName(), Active: request.Active}
+	applySCIMChanges(c, user, changes)
+}
+
+// patchSCIMUser applies PatchOp operations; identity providers mostly use it
+// to deactivate users
+func patchSCIMUser(c *gin.Context) {
+	user, ok := scimUserParam(c)
+	if !ok {
+		return
+	}
+	var request struct {
+		Schemas    []string `json:"schemas"`
+		Operations []struct {
+			Op    string          `json:"op"`
+			Path  string          `json:"path"`
+			Value json.RawMessage `json:"value"`
+		} `json:"Operations"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil || len(request.Operations) == 0 {
+		scimError(c, http.StatusBadRequest, "invalidSyntax", "Invalid PatchOp request")
+		return
+	}
+
+	var changes scimChanges
+	for _, op := range request.Operations {
+		if kind := strings.ToLower(op.Op); kind != "replace" && kind != "add" {
+			scimError(c, http.StatusBadRequest, "invalidValue", "Only add and replace operations are supported")
+			return
+		}
+
+		// Without a path the value is an object of attributes to set
+		values := map[string]json.RawMessage{}
+		if op.Path == "" {
+			if err := json.Unmarshal(op.Value, &values); err != nil {
+				scimError(c, http.StatusBadRequest, "invalidValue", "Operation value must be an object")
+				return
+			}
+		} else {
+			values[op.Path] = op.Value
+		}
+
+		for path, value := range values {
+			if err := changes.set(path, value); err != nil {
+				scimError(c, http.StatusBadRequest, "invalidValue", err.Error())
+				return
+			}
+		}
+	}
+	applySCIMChanges(c, user, changes)
+}
+
+// deleteSCIMUser offboards a user from the organization
+func deleteSCIMUser(c *gin.Context) {
+	user, ok := scimUserParam(c)
+	if !ok {
+		return
+	}
+	if user.Active && !offboardSCIMUser(c, user) {
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// scimChanges are the attributes a PUT or PATCH sets; empty ones are kept
+type scimChanges struct {
+	Email  string
+	Name   string
+	Active *bool
+}
+
+// set records a PATCH of one attribute path
+func (s *scimChanges) set(path string, value json.RawMessage) error {
+	var text string
+	switch strings.ToLower(path) {
+	case "active":
+		// Some identity providers send booleans as the strings "True" and "False"
+		var active bool
+		if err := json.Unmarshal(value, &active); err != nil {
+			if json.Unmarshal(value, &text) != nil {
+				return errors.New("active must be a boolean")
+			}
+			parsed, err := strconv.ParseBool(strings.ToLower(text))
+			if err != nil {
+				return errors.New("active must be a boolean")
+			}
+			active = parsed
+		}
+		s.Active = &active
+	case "displayname", "name.formatted":
+		if json.Unmarshal(value, &text) != nil {
+			return errors.New(path + " must be a string")
+		}
+		s.Name = text
+	case "emails", `emails[type eq "work"].value`, `emails[primary eq true].value`:
+		if json.Unmarshal(value, &text) == nil {
+			s.Email = text
+			return nil
+		}
+		user := scimUser{}
+		if json.Unmarshal(value, &user.Emails) != nil {
+			return errors.New("emails must be a list of emails")
+		}
+		s.Email = user.email()
+	case "name":
+		var name scimName
+		if json.Unmarshal(value, &name) != nil {
+			return errors.New("name must be an object")
+		}
+		s.Name = (&scimUser{Name: &name}).displayName()
+	default:
+		// Attributes that aren't stored, such as title or externalId, are ignored
+	}
+	return nil
+}
+
+// applySCIMChanges updates a user and writes the result
+func applySCIMChanges(c *gin.Context, user *db.ProvisionedUser, changes scimChanges) {
+	sso := scimOrg(c)
+	ctx := c.Request.Context()
+
+	if changes.Email != "" || changes.Name != "" {
+		if err := database.UpdateProvisionedUser(ctx, user.ID, changes.Email, changes.Name); err != nil {
+			scimInternal(c, err)
+			return
+		}
+	}
+
+	switch {
+	case changes.Active == nil || *changes.Active == user.Active:
+	case *changes.Active:
+		if _, err := database.ProvisionMember(ctx, sso.OrgID, user.UserName, "", "", sso.DefaultRole); err != nil {
+			scimInternal(c, err)
+			return
+		}
+		recordAudit(c, auditCreate, "organization_member", strconv.Itoa(sso.OrgID)+"/"+strconv.Itoa(user.ID), nil, gin.H{"role": sso.DefaultRole})
+	default:
+		if !offboardSCIMUser(c, user) {
+			return
+		}
+	}
+
+	updated, err := database.GetProvisionedUser(ctx, sso.OrgID, user.ID)
+	if err != nil {
+		scimInternal(c, err)
+		return
+	}
+	scimJSON(c, http.StatusOK, toSCIMUser(updated))
+}
+
+// offboardSCIMUser removes a user from the organization: their API keys are
+// revoked, their sessions end and their links are handed to the organization
+func offboardSCIMUser(c *gin.Context, user *db.ProvisionedUser) bool {
+	sso := scimOrg(c)
+
+	// The links get a fresh management token nobody holds; the organization's
+	// editors manage them through their membership
+	_, tokenHash, err := newManagementToken()
+	if err != nil {
+		scimInternal(c, err)
+		return false
+	}
+
+	offboarding, err := database.OffboardMember(c.Request.Context(), sso.OrgID, user.ID, tokenHash)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		// Already removed, e.g. by an owner
+		return true
+	case errors.Is(err, db.ErrLastOwner):
+		scimError(c, http.StatusConflict, "mutability", "Organization must keep at least one owner")
+		return false
+	case err != nil:
+		scimInternal(c, err)
+		return false
+	}
+
+	if len(offboarding.APIKeys) > 0 {
+		reloadTenants(c)
+	}
+	recordAudit(c, auditDelete, "organization_member", strconv.Itoa(sso.OrgID)+"/"+strconv.Itoa(user.ID), nil, offboarding)
+	return true
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+	"url-shortener/db"
+	"url-shortener/pkg/apierror"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Actions a signed URL can be issued for
+const (
+	// signedStats reads a link's stats, on the stats page or through the API
+	signedStats = "stats"
+	// signedEdit changes a link through PUT /api/v1/urls/:shortCode
+	signedEdit = "edit"
+)
+
+const (
+	defaultSignedURLTTL = 7 * 24 * time.Hour
+	maxSignedURLTTL     = 90 * 24 * time.Hour
+)
+
+// contextSignedAction holds the action a route accepts signed URLs for
+const contextSignedAction = "signedAction"
+
+// signingSecret signs management URLs; nil when SIGNED_URL_SECRET is unset
+var signingSecret []byte
+
+// acceptSigned lets a route be used with a URL signed for action, which
+// linkAccess verifies, instead of other credentials
+func acceptSigned(action string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set(contextSignedAction, action)
+		c.Next()
+	}
+}
+
+// signedRequest reports whether a request presents a signature on a route
+// accepting signed URLs. The signature itself is checked by linkAccess, which
+// every such route runs.
+func signedRequest(c *gin.Context) bool {
+	return c.GetString(contextSignedAction) != "" && c.Query("signature") != ""
+}
+
+// urlSignature signs an action on a link until expires. The link's management
+// token hash is signed too, so the URL stops working once the token changes,
+// e.g. after a transfer, or if the code is reused by another link.
+func urlSignature(c *gin.Context, shortCode, action string, expires int64, tokenHash string) string {
+	ctx := c.Request.Context()
+	mac := hmac.New(sha256.New, signingSecret)
+	mac.Write([]byte(strings.Join([]string{
+		strconv.Itoa(db.TenantFrom(ctx)), db.DomainFrom(ctx), shortCode, action, strconv.FormatInt(expires, 10), tokenHash,
+	}, "\n")))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// verifySignedURL checks the signature and expiry a request presents for the
+// action its route accepts
+func verifySignedURL(c *gin.Context, shortCode string, owner *db.Ownership) error {
+	if signingSecret == nil {
+		return apierror.Forbidden("Signed URLs are not enabled")
+	}
+	expires, err := strconv.ParseInt(c.Query("expires"), 10, 64)
+	if err != nil {
+		return apierror.Forbidden("Invalid signed URL")
+	}
+	expected := urlSignature(c, shortCode, c.GetString(contextSignedAction), expires, owner.TokenHash)
+	if !hmac.Equal([]byte(expected), []byte(c.Query("signature"))) {
+		return apierror.Forbidden("Invalid signed URL")
+	}
+	if time.Now().Unix() > expires {
+		return apierror.Forbidden("Signed URL has expired")
+	}
+	return nil
+}
+
+// signedStatsPage reports whether a stats page request carries a valid URL
+// signed for the link's stats. resolveLink has already scoped the request to
+// the link's domain.
+func signedStatsPage(c *gin.Context, shortCode string) bool {
+	if c.Query("signature") == "" {
+		return false
+	}
+	owner, err := database.GetOwnership(c.Request.Context(), shortCode)
+	if err != nil {
+		return false
+	}
+	c.Set(contextSignedAction, signedStats)
+	return verifySignedURL(c, shortCode, owner) == nil
+}
+
+// createSignedURL issues a URL that lets anyone holding it read the stats of,
+// or edit, one link until it expires, without an account or the management token
+func createSignedURL(c *gin.Context) {
+	if signingSecret == nil {
+		respondError(c, apierror.NotFound("Signed URLs are not enabled"))
+		return
+	}
+	var request struct {
+		Action string `json:"action" binding:"required,oneof=stats edit"`
+		// ExpiresIn is in seconds
+		ExpiresIn int64 `json:"expiresIn"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		respondError(c, apierror.Validation("action must be stats or edit"))
+		return
+	}
+	ttl := time.Duration(request.ExpiresIn) * time.Second
+	if request.ExpiresIn == 0 {
+		ttl = defaultSignedURLTTL
+	}
+	if ttl <= 0 || ttl > maxSignedURLTTL {
+		respondError(c, apierror.Validation("expiresIn must be between 1 second and 90 days"))
+		return
+	}
+
+	shortCode := c.Param("shortCode")
+	owner, err := database.GetOwnership(c.Request.Context(), shortCode)
+	if err != nil {
+		respondError(c, notFound(err, "Short URL not found"))
+		return
+	}
+
+	expiresAt := time.Now().Add(ttl).Truncate(time.Second)
+	query := url.Values{
+		"expires":   {strconv.FormatInt(expiresAt.Unix(), 10)},
+		"signature": {urlSignature(c, shortCode, request.Action, expiresAt.Unix(), owner.TokenHash)},
+	}
+	domain := db.DomainFrom(c.Request.Context())
+
+	// Stats links open the stats page; edit links are for API clients
+	method, target := http.MethodGet, shortURLFor(c, domain, shortCode)+"/stats"
+	if request.Action == signedEdit {
+		if domain != "" {
+			query.Set("domain", domain)
+		}
+		method, target = http.MethodPut, siteURL(c)+"/api/v1/urls/"+url.PathEscape(shortCode)
+	}
+
+	recordAudit(c, auditCreate, "url", shortCode, nil, gin.H{"signedUrl": request.Action, "expiresAt": expiresAt})
+	c.JSON(http.StatusCreated, gin.H{
+		"action":    request.Action,
+		"method":    method,
+		"url":       target + "?" + query.Encode(),
+		"expiresAt": expiresAt,
+	})
+}
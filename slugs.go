@@ -0,0 +1,243 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+	"url-shortener/db"
+	"url-shortener/pkg/apierror"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/text/unicode/norm"
+)
+
+// slugReadable is the slugStyle of links created with a code derived from
+// their destination's title and path instead of a base62 code
+const slugReadable = "readable"
+
+const (
+	// maxSlugLength bounds readable codes, which are cut at a word boundary
+	maxSlugLength = 40
+	// maxSlugWords is how many words of a title or path a readable code keeps
+	maxSlugWords = 5
+	// maxSlugSuffix is the highest number appended to a taken readable code
+	maxSlugSuffix = 9
+	// maxSuggestions is how many codes POST /urls/suggest returns
+	maxSuggestions = 5
+	// maxReadableAttempts is how many free readable codes creation tries
+	// before settling for a generated code, in case others take them first
+	maxReadableAttempts = 3
+)
+
+// slugStopWords are left out of readable codes unless nothing else is left
+var slugStopWords = map[string]bool{
+	"a": true, "an": true, "and": true, "are": true, "at": true, "by": true, "for": true, "from": true,
+	"in": true, "is": true, "of": true, "on": true, "or": true, "the": true, "to": true, "with": true,
+	"www": true, "index": true, "html": true, "htm": true, "php": true, "aspx": true,
+}
+
+// slugWords splits text into lowercase ASCII words, dropping accents, so
+// "Café Sale!" gives cafe and sale. Other scripts are left out.
+func slugWords(text string) []string {
+	var words []string
+	var word strings.Builder
+	flush := func() {
+		if word.Len() > 0 {
+			words = append(words, word.String())
+			word.Reset()
+		}
+	}
+	for _, r := range norm.NFD.String(text) {
+		switch {
+		case r < utf8.RuneSelf && (unicode.IsLetter(r) || unicode.IsDigit(r)):
+			word.WriteRune(unicode.ToLower(r))
+		case unicode.Is(unicode.Mn, r):
+			// Combining accents of a decomposed letter
+		default:
+			flush()
+		}
+	}
+	flush()
+	return words
+}
+
+// significantWords drops stop words, unless that leaves nothing, and keeps at
+// most limit words
+func significantWords(words []string, limit int) []string {
+	kept := make([]string, 0, len(words))
+	for _, word := range words {
+		if !slugStopWords[word] {
+			kept = append(kept, word)
+		}
+	}
+	if len(kept) == 0 {
+		kept = words
+	}
+	return kept[:min(len(kept), limit)]
+}
+
+// joinSlug joins words with dashes, stopping before the word that would make
+// the slug longer than maxSlugLength
+func joinSlug(words []string) string {
+	var slug string
+	for _, word := range words {
+		next := word
+		if slug != "" {
+			next = slug + "-" + word
+		}
+		if len(next) > maxSlugLength {
+			break
+		}
+		slug = next
+	}
+	return slug
+}
+
+// slugCandidates derives readable codes for a destination from its page
+// title and URL, best first
+func slugCandidates(title, destination string) []string {
+	titleWords := slugWords(title)
+	var pathWords, hostWords []string
+	if u, err := url.Parse(destination); err == nil {
+		segment := path.Base(strings.TrimSuffix(u.Path, "/"))
+		pathWords = slugWords(strings.TrimSuffix(segment, path.Ext(segment)))
+		labels := strings.Split(strings.TrimPrefix(u.Hostname(), "www."), ".")
+		hostWords = slugWords(labels[0])
+	}
+
+	candidates := []string{
+		joinSlug(significantWords(titleWords, maxSlugWords)),
+		joinSlug(significantWords(titleWords, 3)),
+		joinSlug(significantWords(pathWords, maxSlugWords)),
+		joinSlug(append(slices.Clone(hostWords), significantWords(pathWords, 2)...)),
+		joinSlug(append(slices.Clone(hostWords), significantWords(titleWords, 2)...)),
+		joinSlug(hostWords),
+	}
+
+	slugs := make([]string, 0, len(candidates))
+	for _, slug := range candidates {
+		if len(slug) >= 3 && validCustomCode(slug) && !slices.Contains(slugs, slug) {
+			slugs = append(slugs, slug)
+		}
+	}
+	return slugs
+}
+
+// availableSlugs picks up to limit codes from candidates that are free on the
+// domain of ctx for links of orgID, 0 for personal links. A taken candidate
+// is replaced by its first free numbered variant, e.g. spring-sale-2.
+func availableSlugs(ctx context.Context, candidates []string, orgID, limit int) ([]string, error) {
+	codes := make([]string, 0, len(candidates)*maxSlugSuffix)
+	for _, slug := range candidates {
+		codes = append(codes, slug)
+		for n := 2; n <= maxSlugSuffix; n++ {
+			codes = append(codes, slug+"-"+strconv.Itoa(n))
+		}
+	}
+	taken, err := database.TakenShortCodes(ctx, codes)
+	if err != nil {
+		return nil, err
+	}
+	owners, err := database.PrefixOwners(ctx, codes)
+	if err != nil {
+		return nil, err
+	}
+
+	available := make([]string, 0, limit)
+	for _, slug := range candidates {
+		for n := 1; n <= maxSlugSuffix && len(available) < limit; n++ {
+			code := slug
+			if n > 1 {
+				code += "-" + strconv.Itoa(n)
+			}
+			if owner, reserved := owners[code]; reserved && owner != orgID {
+				continue
+			}
+			if !taken[code] && validCustomCode(code) {
+				available = append(available, code)
+				break
+			}
+		}
+	}
+	return available, nil
+}
+
+// destinationTitle fetches the title of the destination page, or returns ""
+// when page metadata fetching is off or the page can't be read
+func destinationTitle(ctx context.Context, destination string) string {
+	fetcher := pageFetcher.Load()
+	if fetcher == nil {
+		return ""
+	}
+	meta, err := fetcher.Fetch(ctx, destination)
+	if err != nil {
+		log.Printf("Failed to fetch title of %s for readable codes: %v", destination, err)
+		return ""
+	}
+	return meta.Title
+}
+
+// createReadableURL stores u under a readable code derived from its
+// destination, falling back to a generated code when no candidate is free
+func createReadableURL(ctx context.Context, u db.NewURL) (int64, string, error) {
+	title := destinationTitle(ctx, u.OriginalURL)
+	codes, err := availableSlugs(ctx, slugCandidates(title, u.OriginalURL), u.OrgID, maxReadableAttempts)
+	if err != nil {
+		return 0, "", err
+	}
+	for _, code := range codes {
+		u.ShortCode = code
+		id, shortCode, err := database.CreateSequencedURL(ctx, u)
+		if !errors.Is(err, db.ErrCodeTaken) {
+			return id, shortCode, err
+		}
+	}
+	u.ShortCode = ""
+	return database.CreateSequencedURL(ctx, u)
+}
+
+// suggestShortCodes proposes readable codes for a destination, derived from
+// its page title and URL and free on the given domain, to pick from before
+// creating the link
+func suggestShortCodes(c *gin.Context) {
+	var request struct {
+		URL    string `json:"url" binding:"required"`
+		Domain string `json:"domain"`
+		// OrgID makes codes under the organization's reserved prefixes eligible
+		OrgID int `json:"orgId"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		respondError(c, apierror.Validation("url is required"))
+		return
+	}
+	if !validDestination(request.URL) {
+		respondError(c, apierror.Validation("url must be an http or https URL"))
+		return
+	}
+	domain := strings.ToLower(request.Domain)
+	if domain != "" && !domainPattern.MatchString(domain) {
+		respondError(c, apierror.Validation("Invalid domain"))
+		return
+	}
+	if !allowedDestinations(c, request.URL) {
+		return
+	}
+
+	ctx := db.WithDomain(c.Request.Context(), domain)
+	title := destinationTitle(ctx, request.URL)
+	suggestions, err := availableSlugs(ctx, slugCandidates(title, request.URL), request.OrgID, maxSuggestions)
+	if err != nil {
+		respondError(c, apierror.Internal("Database error").Wrap(err))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"title": title, "suggestions": suggestions})
+}
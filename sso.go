@@ -0,0 +1,346 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"url-shortener/db"
+	"url-shortener/pkg/apierror"
+	"url-shortener/pkg/saml"
+
+	"github.com/gin-gonic/gin"
+)
+
+// samlStateCookie carries a SAML login between the redirect to the identity
+// provider and the response it posts back
+const samlStateCookie = "saml_state"
+
+// samlState is kept in a short-lived cookie between login and ACS
+type samlState struct {
+	OrgID     int    `json:"orgId"`
+	RequestID string `json:"requestId"`
+	Redirect  string `json:"redirect"`
+}
+
+// Attributes identity providers commonly send the email and name under
+var (
+	samlEmailAttributes = []string{"email", "mail", "emailAddress",
+		"http://schemas.xmlsoap.org/ws/2005/05/identity/claims/emailaddress", "urn:oid:0.9.2342.19200300.100.1.3"}
+	samlNameAttributes = []string{"displayName", "name",
+		"http://schemas.xmlsoap.org/ws/2005/05/identity/claims/name", "urn:oid:2.16.840.1.113730.3.1.241"}
+)
+
+// usedAssertions remembers the assertions that started a session until they
+// expire, so a captured response cannot be posted again
+var usedAssertions = assertionCache{seen: make(map[string]time.Time)}
+
+type assertionCache struct {
+	mu   sync.Mutex
+	seen map[string]time.Time
+}
+
+// claim records an assertion as used, reporting false if it already was
+func (a *assertionCache) claim(id string, expires, now time.Time) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	for seen, until := range a.seen {
+		if now.After(until) {
+			delete(a.seen, seen)
+		}
+	}
+	if _, ok := a.seen[id]; ok {
+		return false
+	}
+	a.seen[id] = expires
+	return true
+}
+
+// serviceProvider describes this service to an organization's identity
+// provider. Each organization has its own entity ID and ACS URL, under
+// BASE_URL or the host the request arrived on.
+func serviceProvider(c *gin.Context, sso *db.OrgSSO) (*saml.ServiceProvider, error) {
+	cert, err := saml.ParseCertificate(sso.Certificate)
+	if err != nil {
+		return nil, err
+	}
+
+	prefix := siteURL(c) + "/auth/saml/" + strconv.Itoa(sso.OrgID)
+	return &saml.ServiceProvider{
+		EntityID:       prefix + "/metadata",
+		ACSURL:         prefix + "/acs",
+		IdPEntityID:    sso.IdPEntityID,
+		IdPSSOURL:      sso.SSOURL,
+		IdPCertificate: cert,
+	}, nil
+}
+
+// orgServiceProvider returns the service provider of the :orgId organization,
+// writing a 404 when it has no SAML setup
+func orgServiceProvider(c *gin.Context) (*db.OrgSSO, *saml.ServiceProvider, bool) {
+	orgID, ok := orgIDParam(c)
+	if !ok {
+		return nil, nil, false
+	}
+
+	sso, err := database.GetOrgSSO(c.Request.Context(), orgID)
+	if err != nil {
+		respondError(c, notFound(err, "Single sign-on is not set up for this organization"))
+		return nil, nil, false
+	}
+	sp, err := serviceProvider(c, sso)
+	if err != nil {
+		respondError(c, apierror.Internal("Invalid identity provider certificate").Wrap(err))
+		return nil, nil, false
+	}
+	return sso, sp, true
+}
+
+// samlLogin sends the user to their organization's identity provider
+func samlLogin(c *gin.Context) {
+	sso, sp, ok := orgServiceProvider(c)
+	if !ok {
+		return
+	}
+
+	target, requestID, err := sp.AuthnRequestURL("", time.Now())
+	if err != nil {
+		respondError(c, apierror.Internal("Failed to start login").Wrap(err))
+		return
+	}
+
+	state := samlState{OrgID: sso.OrgID, RequestID: requestID, Redirect: sameSiteRedirect(c.Query("redirect"))}
+	encoded, _ := json.Marshal(state)
+	// The identity provider posts back from its own site, which only carries
+	// SameSite=None cookies; browsers accept those over https alone
+	if isSecureRequest(c) {
+		c.SetSameSite(http.SameSiteNoneMode)
+	} else {
+		c.SetSameSite(http.SameSiteLaxMode)
+	}
+	c.SetCookie(samlStateCookie, base64.RawURLEncoding.EncodeToString(encoded), 600, "/auth/saml", "", isSecureRequest(c), true)
+
+	c.Redirect(http.StatusFound, target)
+}
+
+// samlACS verifies the response the identity provider posts back, adds the
+// user to the organization on their first sign-in and starts a session.
+// Responses the identity provider sends on its own, without a login started
+// here, are accepted too.
+func samlACS(c *gin.Context) {
+	sso, sp, ok := orgServiceProvider(c)
+	if !ok {
+		return
+	}
+
+	var state samlState
+	if raw, err := c.Cookie(samlStateCookie); err == nil {
+		c.SetCookie(samlStateCookie, "", -1, "/auth/saml", "", isSecureRequest(c), true)
+		decoded, err := base64.RawURLEncoding.DecodeString(raw)
+		if err != nil || json.Unmarshal(decoded, &state) != nil || state.OrgID != sso.OrgID {
+			state = samlState{}
+		}
+	}
+
+	now := time.Now()
+	assertion, err := sp.ParseResponse(c.PostForm("SAMLResponse"), state.RequestID, now)
+	if err != nil {
+		log.Printf("Rejected SAML response for organization %d: %v", sso.OrgID, err)
+		respondError(c, apierror.Unauthorized("Invalid SAML response"))
+		return
+	}
+	if !usedAssertions.claim(assertion.ID, assertion.Expires, now) {
+		respondError(c, apierror.Unauthorized("SAML response was already used"))
+		return
+	}
+
+	ctx := c.Request.Context()
+	email := assertion.Attribute(samlEmailAttributes...)
+	if email == "" && strings.Contains(assertion.NameID, "@") {
+		email = assertion.NameID
+	}
+
+	// With SCIM, the identity provider decides who belongs to the organization
+	if sso.SCIMEnabled {
+		users, _, err := database.GetProvisionedUsers(ctx, sso.OrgID, assertion.NameID, 0, 1)
+		if err != nil {
+			respondError(c, apierror.Internal("Database error").Wrap(err))
+			return
+		}
+		if len(users) == 0 || !users[0].Active {
+			respondError(c, apierror.Forbidden("Not provisioned for this organization"))
+			return
+		}
+	}
+
+	user, err := database.ProvisionMember(ctx, sso.OrgID, assertion.NameID, email, assertion.Attribute(samlNameAttributes...), sso.DefaultRole)
+	if err != nil {
+		respondError(c, apierror.Internal("Failed to store user").Wrap(err))
+		return
+	}
+
+	redirect := state.Redirect
+	if relay := c.PostForm("RelayState"); relay != "" {
+		redirect = sameSiteRedirect(relay)
+	}
+	if redirect == "" {
+		redirect = "/"
+	}
+	startSession(c, &user.User, redirect)
+}
+
+// samlMetadata describes the organization's service provider for its identity provider's setup
+func samlMetadata(c *gin.Context) {
+	_, sp, ok := orgServiceProvider(c)
+	if !ok {
+		return
+	}
+	c.Data(http.StatusOK, "application/samlmetadata+xml", sp.Metadata())
+}
+
+// sameSiteRedirect returns redirect if it is a path on this site, or "/",
+// so login flows can't be used as an open redirect
+func sameSiteRedirect(redirect string) string {
+	if !strings.HasPrefix(redirect, "/") || strings.HasPrefix(redirect, "//") {
+		return "/"
+	}
+	return redirect
+}
+
+// ssoOwner returns the :orgId organization, writing an error unless the
+// caller owns it
+func ssoOwner(c *gin.Context) (int, bool) {
+	orgID, ok := orgIDParam(c)
+	if !ok {
+		return 0, false
+	}
+	role, ok := orgRole(c, orgID)
+	if !ok {
+		return 0, false
+	}
+	if role != db.RoleOwner {
+		respondError(c, apierror.Forbidden("Only owners can manage single sign-on"))
+		return 0, false
+	}
+	return orgID, true
+}
+
+// getOrgSSO returns an organization's SAML setup
+func getOrgSSO(c *gin.Context) {
+	orgID, ok := ssoOwner(c)
+	if !ok {
+		return
+	}
+
+	sso, err := database.GetOrgSSO(c.Request.Context(), orgID)
+	if err != nil {
+		respondError(c, notFound(err, "Single sign-on is not set up for this organization"))
+		return
+	}
+	c.JSON(http.StatusOK, sso)
+}
+
+// setOrgSSO connects an organization to its SAML identity provider
+func setOrgSSO(c *gin.Context) {
+	orgID, ok := ssoOwner(c)
+	if !ok {
+		return
+	}
+
+	var request struct {
+		IdPEntityID string `json:"idpEntityId" binding:"required"`
+		SSOURL      string `json:"ssoUrl" binding:"required,url"`
+		Certificate string `json:"certificate" binding:"required"`
+		DefaultRole string `json:"defaultRole" binding:"omitempty,oneof=owner editor viewer"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		respondError(c, apierror.Validation("idpEntityId, ssoUrl and certificate are required; defaultRole must be owner, editor or viewer"))
+		return
+	}
+	if _, err := saml.ParseCertificate(request.Certificate); err != nil {
+		respondError(c, apierror.Validation("certificate must be a PEM or base64 X.509 certificate"))
+		return
+	}
+	if !checkSSO(c, orgID) {
+		return
+	}
+
+	ctx := c.Request.Context()
+	before, err := database.GetOrgSSO(ctx, orgID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		respondError(c, apierror.Internal("Database error").Wrap(err))
+		return
+	}
+
+	sso := db.OrgSSO{
+		OrgID:       orgID,
+		IdPEntityID: strings.TrimSpace(request.IdPEntityID),
+		SSOURL:      request.SSOURL,
+		Certificate: strings.TrimSpace(request.Certificate),
+		DefaultRole: request.DefaultRole,
+	}
+	if sso.DefaultRole == "" {
+		sso.DefaultRole = db.RoleViewer
+	}
+	if err := database.SetOrgSSO(ctx, &sso); err != nil {
+		respondError(c, apierror.Internal("Failed to store single sign-on setup").Wrap(err))
+		return
+	}
+
+	if before != nil {
+		recordAudit(c, auditUpdate, "sso", strconv.Itoa(orgID), before, sso)
+	} else {
+		recordAudit(c, auditCreate, "sso", strconv.Itoa(orgID), nil, sso)
+	}
+	c.JSON(http.StatusOK, sso)
+}
+
+// deleteOrgSSO disconnects an organization from its identity provider,
+// revoking SCIM access. Members keep their sessions and membership.
+func deleteOrgSSO(c *gin.Context) {
+	orgID, ok := ssoOwner(c)
+	if !ok {
+		return
+	}
+
+	if err := database.DeleteOrgSSO(c.Request.Context(), orgID); err != nil {
+		respondError(c, notFound(err, "Single sign-on is not set up for this organization"))
+		return
+	}
+
+	recordAudit(c, auditDelete, "sso", strconv.Itoa(orgID), nil, nil)
+	c.JSON(http.StatusOK, gin.H{"message": "Single sign-on removed"})
+}
+
+// createSCIMToken issues the token the organization's identity provider
+// authenticates to SCIM with, replacing any previous one. The token is only
+// returned here; just its hash is stored.
+func createSCIMToken(c *gin.Context) {
+	orgID, ok := ssoOwner(c)
+	if !ok {
+		return
+	}
+	if !checkSSO(c, orgID) {
+		return
+	}
+
+	token, tokenHash, err := newManagementToken()
+	if err != nil {
+		respondError(c, apierror.Internal("Failed to generate SCIM token").Wrap(err))
+		return
+	}
+	if err := database.SetSCIMToken(c.Request.Context(), orgID, tokenHash); err != nil {
+		respondError(c, notFound(err, "Single sign-on is not set up for this organization"))
+		return
+	}
+
+	recordAudit(c, auditUpdate, "sso", strconv.Itoa(orgID), nil, gin.H{"scimEnabled": true})
+	c.JSON(http.StatusCreated, gin.H{"token": token, "scimUrl": "/scim/v2"})
+}
@@ -0,0 +1,166 @@
+// Home page: shortens links through the API and keeps the ones created in
+// this browser, with their management tokens, in localStorage so their stats
+// can be viewed later.
+(function () {
+  "use strict";
+
+  var api = "/api/v1";
+  var storageKey = "recentLinks";
+  var maxRecent = 20;
+
+  function $(id) {
+    return document.getElementById(id);
+  }
+
+  function recentLinks() {
+    try {
+      return JSON.parse(localStorage.getItem(storageKey)) || [];
+    } catch (e) {
+      return [];
+    }
+  }
+
+  function remember(link) {
+    var links = recentLinks().filter(function (l) {
+      return l.shortCode !== link.shortCode;
+    });
+    links.unshift({
+      shortCode: link.shortCode,
+      shortUrl: link.shortUrl,
+      original: link.original,
+      managementToken: link.managementToken,
+    });
+    localStorage.setItem(storageKey, JSON.stringify(links.slice(0, maxRecent)));
+  }
+
+  // request calls the API, rejecting with the message of the error envelope
+  function request(method, path, body, headers) {
+    headers = headers || {};
+    if (body) {
+      headers["Content-Type"] = "application/json";
+    }
+    return fetch(api + path, {
+      method: method,
+      headers: headers,
+      body: body ? JSON.stringify(body) : undefined,
+    }).then(function (res) {
+      return res.json().then(function (data) {
+        if (!res.ok) {
+          throw new Error((data.error && data.error.message) || res.statusText);
+        }
+        return data;
+      });
+    });
+  }
+
+  function showError(message) {
+    $("error").textContent = message;
+    $("error").hidden = !message;
+  }
+
+  function copy(text, button) {
+    navigator.clipboard.writeText(text).then(function () {
+      var label = button.textContent;
+      button.textContent = "Copied";
+      setTimeout(function () {
+        button.textContent = label;
+      }, 1500);
+    });
+  }
+
+  function renderRecent() {
+    var links = recentLinks();
+    var list = $("recent");
+    list.textContent = "";
+    $("empty").hidden = links.length > 0;
+
+    links.forEach(function (link) {
+      var item = document.createElement("li");
+
+      var text = document.createElement("div");
+      var anchor = document.createElement("a");
+      anchor.href = link.shortUrl;
+      anchor.textContent = link.shortUrl;
+      anchor.target = "_blank";
+      anchor.rel = "noopener";
+      var original = document.createElement("div");
+      original.className = "original";
+      original.textContent = link.original;
+      text.append(anchor, original);
+
+      var actions = document.createElement("div");
+      var copyButton = document.createElement("button");
+      copyButton.type = "button";
+      copyButton.textContent = "Copy";
+      copyButton.onclick = function () {
+        copy(link.shortUrl, copyButton);
+      };
+      var statsButton = document.createElement("button");
+      statsButton.type = "button";
+      statsButton.textContent = "Stats";
+      statsButton.onclick = function () {
+        showStats(link);
+      };
+      actions.append(copyButton, statsButton);
+
+      item.append(text, actions);
+      list.append(item);
+    });
+  }
+
+  function renderBreakdown(id, entries) {
+    var list = $(id);
+    list.textContent = "";
+    (entries || []).forEach(function (entry) {
+      var item = document.createElement("li");
+      item.textContent = entry.name + ": " + entry.clicks;
+      list.append(item);
+    });
+  }
+
+  function showStats(link) {
+    showError("");
+    request("GET", "/urls/" + encodeURIComponent(link.shortCode) + "/stats", null, {
+      "X-Management-Token": link.managementToken,
+    })
+      .then(function (stats) {
+        $("stats-code").textContent = stats.shortCode;
+        $("stats-original").textContent = stats.original;
+        $("stats-clicks").textContent = stats.accessCount;
+        $("stats-unique").textContent = stats.uniqueClicks;
+        $("stats-bots").textContent = stats.botClicks;
+        $("stats-created").textContent = new Date(stats.createdAt).toLocaleString();
+        renderBreakdown("stats-devices", stats.breakdown && stats.breakdown.devices);
+        renderBreakdown("stats-browsers", stats.breakdown && stats.breakdown.browsers);
+        renderBreakdown("stats-os", stats.breakdown && stats.breakdown.os);
+        $("stats").hidden = false;
+        $("stats").scrollIntoView({ behavior: "smooth" });
+      })
+      .catch(function (err) {
+        showError(err.message);
+      });
+  }
+
+  $("shorten").addEventListener("submit", function (event) {
+    event.preventDefault();
+    showError("");
+    request("POST", "/urls", { url: $("url").value })
+      .then(function (link) {
+        remember(link);
+        renderRecent();
+        $("result-link").href = link.shortUrl;
+        $("result-link").textContent = link.shortUrl;
+        $("result").hidden = false;
+        $("url").value = "";
+      })
+      .catch(function (err) {
+        showError(err.message);
+      });
+  });
+
+  $("copy").addEventListener("click", function () {
+    copy($("result-link").textContent, $("copy"));
+  });
+
+  renderRecent();
+})();
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"url-shortener/pkg/apierror"
+
+	"github.com/gin-gonic/gin"
+)
+
+// resetURLStats clears a link's stats, e.g. when test traffic polluted them
+// before a launch. A body with clicks or uniqueClicks subtracts those amounts
+// from the totals instead, leaving the rest of the stats alone. Either way
+// the counts before and after are recorded in the audit log.
+func resetURLStats(c *gin.Context) {
+	shortCode := c.Param("shortCode")
+
+	var request struct {
+		Clicks       *int64 `json:"clicks"`
+		UniqueClicks *int64 `json:"uniqueClicks"`
+		Reason       string `json:"reason"`
+	}
+	// The body is optional, but a malformed one must not reset everything
+	if err := c.ShouldBindJSON(&request); err != nil && !errors.Is(err, io.EOF) {
+		respondError(c, apierror.Validation("Invalid request body"))
+		return
+	}
+
+	if request.Clicks == nil && request.UniqueClicks == nil {
+		before, err := database.ResetStats(c.Request.Context(), shortCode)
+		if err != nil {
+			respondError(c, notFound(err, "Short URL not found"))
+			return
+		}
+
+		recordAudit(c, auditResetStats, "url", shortCode, before, gin.H{"reason": request.Reason})
+		c.JSON(http.StatusOK, gin.H{"message": "Stats reset successfully", "before": before})
+		return
+	}
+
+	var clicks, uniqueClicks int64
+	if request.Clicks != nil {
+		clicks = *request.Clicks
+	}
+	if request.UniqueClicks != nil {
+		uniqueClicks = *request.UniqueClicks
+	}
+	if clicks < 0 || uniqueClicks < 0 || clicks+uniqueClicks == 0 {
+		respondError(c, apierror.Validation("clicks and uniqueClicks must not be negative, and at least one must be positive"))
+		return
+	}
+
+	before, after, err := database.SubtractClicks(c.Request.Context(), shortCode, clicks, uniqueClicks)
+	if err != nil {
+		respondError(c, notFound(err, "Short URL not found"))
+		return
+	}
+
+	recordAudit(c, auditAdjustStats, "url", shortCode, before, gin.H{
+		"clicks":       after.Clicks,
+		"uniqueClicks": after.UniqueClicks,
+		"subtracted":   gin.H{"clicks": clicks, "uniqueClicks": uniqueClicks},
+		"reason":       request.Reason,
+	})
+	c.JSON(http.StatusOK, gin.H{"message": "Stats adjusted successfully", "before": before, "after": after})
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"url-shortener/db"
+	"url-shortener/pkg/apierror"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxTagLength bounds tag names so they stay usable as labels
+const maxTagLength = 64
+
+// normalizeTags trims tags, drops empty and duplicate entries and reports whether all are valid
+func normalizeTags(tags []string) ([]string, bool) {
+	out := make([]string, 0, len(tags))
+	seen := make(map[string]bool, len(tags))
+	for _, tag := range tags {
+		tag = strings.TrimSpace(tag)
+		if tag == "" || seen[tag] {
+			continue
+		}
+		if len(tag) > maxTagLength {
+			return nil, false
+		}
+		seen[tag] = true
+		out = append(out, tag)
+	}
+	return out, true
+}
+
+func getTags(c *gin.Context) {
+	counts, err := database.GetTagCounts(c.Request.Context())
+	if err != nil {
+		respondError(c, apierror.Internal("Database error").Wrap(err))
+		return
+	}
+
+	c.JSON(http.StatusOK, counts)
+}
+
+// bulkTagLinks adds or removes a tag across many links selected by code list or filter
+func bulkTagLinks(c *gin.Context) {
+	var request struct {
+		Action     string   `json:"action" binding:"required,oneof=add remove"`
+		Tag        string   `json:"tag" binding:"required"`
+		ShortCodes []string `json:"shortCodes"`
+		Filter     struct {
+			Tag              string `json:"tag"`
+			OriginalContains string `json:"originalContains"`
+		} `json:"filter"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		respondError(c, apierror.Validation("Invalid request body"))
+		return
+	}
+
+	tags, ok := normalizeTags([]string{request.Tag})
+	if !ok || len(tags) == 0 {
+		respondError(c, apierror.Validation("Invalid tag"))
+		return
+	}
+
+	filter := db.TagFilter{
+		ShortCodes:       request.ShortCodes,
+		Tag:              request.Filter.Tag,
+		OriginalContains: request.Filter.OriginalContains,
+	}
+	if filter.Empty() {
+		respondError(c, apierror.Validation("Either shortCodes or a filter is required"))
+		return
+	}
+
+	var affected int64
+	var err error
+	if request.Action == "add" {
+		affected, err = database.AddTag(c.Request.Context(), tags[0], filter)
+	} else {
+		affected, err = database.RemoveTag(c.Request.Context(), tags[0], filter)
+	}
+	if err != nil {
+		respondError(c, apierror.Internal("Failed to update tags").Wrap(err))
+		return
+	}
+
+	recordAudit(c, auditUpdate, "tag", tags[0], nil, gin.H{"action": request.Action, "filter": filter, "affected": affected})
+	c.JSON(http.StatusOK, gin.H{"tag": tags[0], "action": request.Action, "affected": affected})
+}
+
+// renameTag renames a tag on every link; renaming onto an existing tag merges the two
+func renameTag(c *gin.Context) {
+	from := c.Param("tag")
+	var request struct {
+		To string `json:"to" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		respondError(c, apierror.Validation("Invalid request body"))
+		return
+	}
+
+	tags, ok := normalizeTags([]string{request.To})
+	if !ok || len(tags) == 0 {
+		respondError(c, apierror.Validation("Invalid tag"))
+		return
+	}
+
+	affected, err := database.RenameTag(c.Request.Context(), from, tags[0])
+	if err != nil {
+		respondError(c, apierror.Internal("Failed to rename tag").Wrap(err))
+		return
+	}
+
+	recordAudit(c, auditUpdate, "tag", from, gin.H{"tag": from}, gin.H{"tag": tags[0], "affected": affected})
+	c.JSON(http.StatusOK, gin.H{"from": from, "to": tags[0], "affected": affected})
+}
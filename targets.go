@@ -0,0 +1,108 @@
+package main
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+	"url-shortener/db"
+	"url-shortener/pkg/apierror"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Platforms a link can route to a dedicated destination. Operating systems are
+// matched before device types, so an "ios" target wins over "tablet" on an iPad.
+const (
+	platformIOS     = "ios"
+	platformAndroid = "android"
+)
+
+var targetPlatforms = []string{platformIOS, platformAndroid, deviceMobile, deviceTablet, deviceDesktop}
+
+// normalizeTargets validates per-platform destinations, lowercasing the keys.
+// It returns false when a key is not a known platform or a value is not an
+// http(s) URL; app deep links belong in the link's app link.
+func normalizeTargets(targets map[string]string) (map[string]string, bool) {
+	if len(targets) == 0 {
+		return nil, true
+	}
+
+	out := make(map[string]string, len(targets))
+	for platform, destination := range targets {
+		platform = strings.ToLower(strings.TrimSpace(platform))
+		if !slices.Contains(targetPlatforms, platform) {
+			return nil, false
+		}
+		if !validDestination(destination) {
+			return nil, false
+		}
+		out[platform] = destination
+	}
+	return out, true
+}
+
+// mobilePlatform tells iOS and Android devices apart by user agent, returning
+// an empty string for anything else
+func mobilePlatform(userAgent string) string {
+	lower := strings.ToLower(userAgent)
+	switch {
+	case strings.Contains(lower, "iphone") || strings.Contains(lower, "ipad") || strings.Contains(lower, "ipod"):
+		return platformIOS
+	case strings.Contains(lower, "android"):
+		return platformAndroid
+	}
+	return ""
+}
+
+// targetFor picks the destination configured for a visitor's platform,
+// reporting false when none of the link's targets match
+func targetFor(link *db.URL, userAgent string, click db.Click) (string, bool) {
+	if len(link.Targets) == 0 {
+		return "", false
+	}
+
+	for _, key := range []string{mobilePlatform(userAgent), click.Device} {
+		if destination, ok := link.Targets[key]; ok && key != "" {
+			return destination, true
+		}
+	}
+	return "", false
+}
+
+// setURLTargets replaces a link's platform-specific destinations; an empty
+// object removes them so every visitor goes to the original URL
+func setURLTargets(c *gin.Context) {
+	shortCode := c.Param("shortCode")
+
+	var request map[string]string
+	if err := c.ShouldBindJSON(&request); err != nil {
+		respondError(c, apierror.Validation("Invalid request body"))
+		return
+	}
+
+	targets, ok := normalizeTargets(request)
+	if !ok {
+		respondError(c, apierror.Validation("Invalid target"))
+		return
+	}
+	if !allowedDestinations(c, linkDestinations(&db.URL{Targets: targets})...) {
+		return
+	}
+
+	previous, err := database.GetURLByShortCode(c.Request.Context(), shortCode)
+	if err != nil {
+		respondError(c, notFound(err, "Short URL not found"))
+		return
+	}
+
+	if err := database.SetTargets(c.Request.Context(), shortCode, targets); err != nil {
+		respondError(c, notFound(err, "Short URL not found"))
+		return
+	}
+
+	if targets == nil {
+		targets = map[string]string{}
+	}
+	recordAudit(c, auditUpdate, "url", shortCode, gin.H{"targets": previous.Targets}, gin.H{"targets": targets})
+	c.JSON(http.StatusOK, gin.H{"message": "Targets updated successfully", "targets": targets})
+}
@@ -0,0 +1,293 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"url-shortener/db"
+	"url-shortener/pkg/apierror"
+	"url-shortener/pkg/jobs"
+
+	"github.com/gin-gonic/gin"
+)
+
+// apiKeyHeader carries the API key selecting the tenant of a request
+const apiKeyHeader = "X-API-Key"
+
+// tenantIndex maps the hostnames and API key hashes of every tenant to its ID
+type tenantIndex struct {
+	hosts map[string]int
+	keys  map[string]*db.APIKey
+}
+
+// tenantDirectory routes requests to tenants
+var tenantDirectory atomic.Pointer[tenantIndex]
+
+// loadTenants reads the hostnames and API keys of the tenants from the database
+func loadTenants(ctx context.Context) error {
+	tenants, err := database.GetTenants(ctx)
+	if err != nil {
+		return err
+	}
+	keys, err := database.GetAPIKeys(ctx)
+	if err != nil {
+		return err
+	}
+
+	index := &tenantIndex{hosts: make(map[string]int), keys: make(map[string]*db.APIKey, len(keys))}
+	for _, tenant := range tenants {
+		for _, hostname := range tenant.Hostnames {
+			index.hosts[hostname] = tenant.ID
+		}
+	}
+	for i := range keys {
+		index.keys[keys[i].KeyHash] = &keys[i]
+	}
+	tenantDirectory.Store(index)
+	return nil
+}
+
+// resolveTenant scopes a request to the tenant of its API key or, without
+// one, of its hostname; requests matching neither are served for the default
+// tenant. A key is rejected on another tenant's hostname, so one brand's
+// credentials cannot reach another brand's links.
+func resolveTenant(c *gin.Context) {
+	tenant := db.DefaultTenant
+	index := tenantDirectory.Load()
+	if index == nil {
+		index = &tenantIndex{}
+	}
+
+	hostTenant, hostKnown := index.hosts[requestHostname(c)]
+	if hostKnown {
+		tenant = hostTenant
+	}
+	if key := c.GetHeader(apiKeyHeader); key != "" {
+		apiKey, ok := index.keys[hashSecret(key)]
+		if !ok {
+			apierror.Abort(c, apierror.Unauthorized("Invalid API key"))
+			return
+		}
+		if hostKnown && apiKey.TenantID != hostTenant {
+			apierror.Abort(c, apierror.Forbidden("API key belongs to another tenant"))
+			return
+		}
+		tenant = apiKey.TenantID
+		c.Set(contextAPIKey, apiKey)
+	}
+
+	c.Request = c.Request.WithContext(db.WithTenant(c.Request.Context(), tenant))
+	c.Next()
+}
+
+// requestHostname is the hostname a request was sent to, without its port
+func requestHostname(c *gin.Context) string {
+	host := c.Request.Host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return normalizeHostname(host)
+}
+
+// normalizeHostname lowercases a hostname and drops a trailing dot, so it
+// matches however clients spell it
+func normalizeHostname(host string) string {
+	return strings.TrimSuffix(strings.ToLower(strings.TrimSpace(host)), ".")
+}
+
+// tenantRateKey keeps rate limit buckets apart per tenant, so a client's
+// requests to one brand do not count against another
+func tenantRateKey(c *gin.Context) string {
+	return strconv.Itoa(db.TenantFrom(c.Request.Context())) + "/" + c.ClientIP()
+}
+
+// withRequestTenant scopes work handed off to the background to the tenant
+// and link domain of the request handing it off, as the request context is
+// gone by the time it runs
+func withRequestTenant(c *gin.Context, run jobs.Func) jobs.Func {
+	tenant := db.TenantFrom(c.Request.Context())
+	domain := db.DomainFrom(c.Request.Context())
+	return func(ctx context.Context) error {
+		return run(db.WithDomain(db.WithTenant(ctx, tenant), domain))
+	}
+}
+
+// getTenants lists the tenants with their hostnames
+func getTenants(c *gin.Context) {
+	tenants, err := database.GetTenants(c.Request.Context())
+	if err != nil {
+		respondError(c, apierror.Internal("Database error").Wrap(err))
+		return
+	}
+
+	c.JSON(http.StatusOK, tenants)
+}
+
+// bindTenant reads a tenant's name and hostnames from the request body,
+// writing a 400 when they are invalid
+func bindTenant(c *gin.Context) (*db.Tenant, bool) {
+	var request struct {
+		Name      string   `json:"name"`
+		Hostnames []string `json:"hostnames"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil || strings.TrimSpace(request.Name) == "" {
+		respondError(c, apierror.Validation("Invalid request body"))
+		return nil, false
+	}
+
+	tenant := &db.Tenant{Name: strings.TrimSpace(request.Name), Hostnames: make([]string, 0, len(request.Hostnames))}
+	for _, hostname := range request.Hostnames {
+		hostname = normalizeHostname(hostname)
+		if hostname == "" || strings.ContainsAny(hostname, "/: ") {
+			respondError(c, apierror.Validation("Invalid hostname"))
+			return nil, false
+		}
+		tenant.Hostnames = append(tenant.Hostnames, hostname)
+	}
+	return tenant, true
+}
+
+// createTenant adds a tenant, served on its hostnames and to its API keys
+func createTenant(c *gin.Context) {
+	tenant, ok := bindTenant(c)
+	if !ok {
+		return
+	}
+
+	if err := database.CreateTenant(c.Request.Context(), tenant); err != nil {
+		respondTenantError(c, err)
+		return
+	}
+
+	reloadTenants(c)
+	recordAudit(c, auditCreate, "tenant", strconv.Itoa(tenant.ID), nil, tenant)
+	c.JSON(http.StatusCreated, tenant)
+}
+
+// updateTenant renames a tenant and replaces its hostnames
+func updateTenant(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		respondError(c, apierror.Validation("Invalid tenant ID"))
+		return
+	}
+	tenant, ok := bindTenant(c)
+	if !ok {
+		return
+	}
+	tenant.ID = id
+
+	if err := database.UpdateTenant(c.Request.Context(), tenant); err != nil {
+		respondTenantError(c, err)
+		return
+	}
+
+	reloadTenants(c)
+	recordAudit(c, auditUpdate, "tenant", strconv.Itoa(tenant.ID), nil, tenant)
+	c.JSON(http.StatusOK, tenant)
+}
+
+// respondTenantError reports a failure to store a tenant
+func respondTenantError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		respondError(c, apierror.NotFound("Tenant not found"))
+	case errors.Is(err, db.ErrHostnameTaken):
+		respondError(c, apierror.Conflict(err.Error()))
+	default:
+		respondError(c, apierror.Internal("Failed to store tenant").Wrap(err))
+	}
+}
+
+// getAPIKeys lists the API keys of every tenant, without the keys themselves.
+// Like the rest of the tenant admin it is deployment-wide, so it sits behind
+// the admin token or an admin JWT; admin API keys, which act for one tenant,
+// are refused.
+func getAPIKeys(c *gin.Context) {
+	keys, err := database.GetAPIKeys(c.Request.Context())
+	if err != nil {
+		respondError(c, apierror.Internal("Database error").Wrap(err))
+		return
+	}
+
+	c.JSON(http.StatusOK, keys)
+}
+
+// createAPIKey issues an API key for a tenant. The key is only returned here;
+// just its hash is stored.
+func createAPIKey(c *gin.Context) {
+	tenantID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		respondError(c, apierror.Validation("Invalid tenant ID"))
+		return
+	}
+	var request struct {
+		Name   string   `json:"name"`
+		Scopes []string `json:"scopes"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil || strings.TrimSpace(request.Name) == "" {
+		respondError(c, apierror.Validation("Invalid request body"))
+		return
+	}
+	for _, scope := range request.Scopes {
+		if !slices.Contains(apiKeyScopes, scope) {
+			respondError(c, apierror.Validation("Unknown scope "+scope).WithDetails(gin.H{"scopes": apiKeyScopes}))
+			return
+		}
+	}
+
+	// API keys are generated and stored like management tokens
+	secret, hash, err := newManagementToken()
+	if err != nil {
+		respondError(c, apierror.Internal("Failed to generate API key").Wrap(err))
+		return
+	}
+	slices.Sort(request.Scopes)
+	key := db.APIKey{TenantID: tenantID, Name: strings.TrimSpace(request.Name), KeyHash: hash, Scopes: slices.Compact(request.Scopes),
+		CreatedBy: auditActor(c)}
+	if err := database.CreateAPIKey(c.Request.Context(), &key); err != nil {
+		respondTenantError(c, err)
+		return
+	}
+
+	reloadTenants(c)
+	recordAudit(c, auditCreate, "api_key", strconv.Itoa(key.ID), nil, key)
+	c.JSON(http.StatusCreated, gin.H{"apiKey": key, "key": secret})
+}
+
+// deleteAPIKey revokes an API key
+func deleteAPIKey(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		respondError(c, apierror.Validation("Invalid API key ID"))
+		return
+	}
+
+	key, err := database.DeleteAPIKey(c.Request.Context(), id)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondError(c, apierror.NotFound("API key not found"))
+		return
+	}
+	if err != nil {
+		respondError(c, apierror.Internal("Database error").Wrap(err))
+		return
+	}
+
+	reloadTenants(c)
+	recordAudit(c, auditDelete, "api_key", strconv.Itoa(key.ID), key, nil)
+	c.JSON(http.StatusOK, gin.H{"message": "API key revoked successfully"})
+}
+
+// reloadTenants applies a tenant or API key change on this instance right away
+func reloadTenants(c *gin.Context) {
+	if err := loadTenants(c.Request.Context()); err != nil {
+		log.Printf("Failed to reload tenants: %v", err)
+	}
+}
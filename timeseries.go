@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+	"url-shortener/db"
+	"url-shortener/pkg/apierror"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxTimeSeriesBuckets caps the buckets of one time series request
+const maxTimeSeriesBuckets = 1000
+
+// timeSeriesIntervals are the bucket sizes of stats time series, with the
+// range covered when no from is given
+var timeSeriesIntervals = map[string]struct{ step, span time.Duration }{
+	"hour": {time.Hour, 24 * time.Hour},
+	"day":  {24 * time.Hour, 30 * 24 * time.Hour},
+	"week": {7 * 24 * time.Hour, 12 * 7 * 24 * time.Hour},
+}
+
+// timeSeries is a link's click counts bucketed by interval over [from, to)
+type timeSeries struct {
+	ShortCode string          `json:"shortCode"`
+	Interval  string          `json:"interval"`
+	From      time.Time       `json:"from"`
+	To        time.Time       `json:"to"`
+	Buckets   []db.TimeBucket `json:"buckets"`
+}
+
+// aggregateClicks adds new click events to the hourly counts time series are
+// read from. It is scheduled every STATS_AGGREGATE_INTERVAL.
+func aggregateClicks(ctx context.Context) error {
+	_, err := database.AggregateClicks(ctx)
+	return err
+}
+
+// bucketStart is the start of the UTC hour, day or week (from Monday) t is in
+func bucketStart(t time.Time, interval string) time.Time {
+	t = t.UTC()
+	switch interval {
+	case "hour":
+		return t.Truncate(time.Hour)
+	case "week":
+		t = t.AddDate(0, 0, -(int(t.Weekday())+6)%7)
+	}
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// parseTimeParam reads a from or to query parameter, either an RFC 3339
+// timestamp or a date taken as midnight UTC
+func parseTimeParam(value string) (time.Time, bool) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, true
+	}
+	if t, err := time.Parse(time.DateOnly, value); err == nil {
+		return t, true
+	}
+	return time.Time{}, false
+}
+
+// getURLTimeSeries returns a link's clicks per hour, day or week. The range is
+// widened to whole buckets; without from and to it ends with the current
+// bucket. Counts come from hourly rollups, so the latest minute or so of
+// clicks may not be included yet.
+func getURLTimeSeries(c *gin.Context) {
+	interval := c.DefaultQuery("interval", "day")
+	size, ok := timeSeriesIntervals[interval]
+	if !ok {
+		respondError(c, apierror.Validation("interval must be hour, day or week"))
+		return
+	}
+
+	to := time.Now()
+	if value := c.Query("to"); value != "" {
+		if to, ok = parseTimeParam(value); !ok {
+			respondError(c, apierror.Validation("to must be an RFC 3339 timestamp or a date"))
+			return
+		}
+	}
+	if start := bucketStart(to, interval); start.Before(to) {
+		to = start.Add(size.step)
+	} else {
+		to = start
+	}
+
+	from := to.Add(-size.span)
+	if value := c.Query("from"); value != "" {
+		if from, ok = parseTimeParam(value); !ok {
+			respondError(c, apierror.Validation("from must be an RFC 3339 timestamp or a date"))
+			return
+		}
+		from = bucketStart(from, interval)
+	}
+	if !from.Before(to) {
+		respondError(c, apierror.Validation("from must be before to"))
+		return
+	}
+	if to.Sub(from)/size.step > maxTimeSeriesBuckets {
+		respondError(c, apierror.Validation("A time series may have at most "+strconv.Itoa(maxTimeSeriesBuckets)+" buckets"))
+		return
+	}
+
+	shortCode := c.Param("shortCode")
+	url, err := database.GetURLByShortCode(c.Request.Context(), shortCode)
+	if err != nil {
+		respondError(c, notFound(err, "Short URL not found"))
+		return
+	}
+
+	buckets, err := database.GetClickTimeSeries(c.Request.Context(), url.ID, interval, from, to)
+	if err != nil {
+		respondError(c, apierror.Internal("Database error").Wrap(err))
+		return
+	}
+
+	c.JSON(http.StatusOK, timeSeries{ShortCode: shortCode, Interval: interval, From: from, To: to, Buckets: buckets})
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"crypto/tls"
+	"errors"
+	"net"
+	"net/http"
+	"url-shortener/config"
+
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// configureTLS returns the TLS settings the server listens with and the
+// handler of the plain HTTP listener, which redirects to HTTPS and, with
+// autocert, answers ACME challenges. Both are nil when neither TLS_CERT_FILE
+// nor TLS_AUTOCERT_DOMAINS is set and TLS is left to a proxy.
+func configureTLS(cfg *config.Config, port string) (*tls.Config, http.Handler, error) {
+	redirect := redirectToHTTPS(port)
+
+	switch {
+	case cfg.TLS.CertFile != "" && len(cfg.TLS.AutocertDomains) > 0:
+		return nil, nil, errors.New("TLS_CERT_FILE and TLS_AUTOCERT_DOMAINS are mutually exclusive")
+	case cfg.TLS.CertFile != "" || cfg.TLS.KeyFile != "":
+		cert, err := tls.LoadX509KeyPair(cfg.TLS.CertFile, cfg.TLS.KeyFile)
+		if err != nil {
+			return nil, nil, err
+		}
+		return &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12}, redirect, nil
+	case len(cfg.TLS.AutocertDomains) > 0:
+		manager := &autocert.Manager{
+			Prompt:     autocert.AcceptTOS,
+			HostPolicy: autocert.HostWhitelist(cfg.TLS.AutocertDomains...),
+			Cache:      autocert.DirCache(cfg.TLS.AutocertCacheDir),
+			Email:      cfg.TLS.AutocertEmail,
+		}
+		tlsConfig := manager.TLSConfig()
+		tlsConfig.MinVersion = tls.VersionTLS12
+		return tlsConfig, manager.HTTPHandler(redirect), nil
+	}
+	return nil, nil, nil
+}
+
+// redirectToHTTPS sends plain HTTP requests to the same URL over HTTPS on
+// port, keeping the method of requests other than GET and HEAD
+func redirectToHTTPS(port string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		if port != "443" {
+			host = net.JoinHostPort(host, port)
+		}
+
+		status := http.StatusMovedPermanently
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			status = http.StatusPermanentRedirect
+		}
+		http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), status)
+	})
+}
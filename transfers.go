@@ -0,0 +1,259 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"url-shortener/db"
+	"url-shortener/middleware"
+	"url-shortener/pkg/apierror"
+
+	"github.com/gin-gonic/gin"
+)
+
+// auditTransfer records a link or campaign changing hands
+const auditTransfer = "transfer"
+
+// bindTransferRecipient reads the user or organization a transfer is offered
+// to, writing an error response and returning false unless exactly one is given
+func bindTransferRecipient(c *gin.Context) (*db.Transfer, bool) {
+	var request struct {
+		ToUserID string `json:"toUserId"`
+		ToOrgID  int    `json:"toOrgId"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		respondError(c, apierror.Validation("Invalid request body"))
+		return nil, false
+	}
+	request.ToUserID = strings.TrimSpace(request.ToUserID)
+	if (request.ToUserID == "") == (request.ToOrgID <= 0) {
+		respondError(c, apierror.Validation("Exactly one of toUserId and toOrgId is required"))
+		return nil, false
+	}
+
+	requestedBy := c.GetString(middleware.ContextUserID)
+	if requestedBy == "" {
+		if !isAdmin(c) {
+			respondError(c, apierror.Unauthorized("Authentication required"))
+			return nil, false
+		}
+		requestedBy = auditActor(c)
+	}
+	return &db.Transfer{ToUserID: request.ToUserID, ToOrgID: request.ToOrgID, RequestedBy: requestedBy}, true
+}
+
+// offerTransfer stores a transfer and answers with it
+func offerTransfer(c *gin.Context, transfer *db.Transfer) {
+	err := database.CreateTransfer(c.Request.Context(), transfer)
+	if errors.Is(err, db.ErrTransferPending) {
+		respondError(c, apierror.Conflict("A transfer is already awaiting acceptance"))
+		return
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		respondError(c, apierror.NotFound("Organization not found"))
+		return
+	}
+	if err != nil {
+		respondError(c, apierror.Internal("Failed to create transfer").Wrap(err))
+		return
+	}
+
+	recordAudit(c, auditCreate, "transfer", strconv.Itoa(transfer.ID), nil, transfer)
+	c.JSON(http.StatusCreated, transfer)
+}
+
+// transferURL offers a link to another user or organization. It changes hands
+// once the recipient accepts. Links of an organization can only be given
+// away by its owners.
+func transferURL(c *gin.Context) {
+	shortCode := c.Param("shortCode")
+	transfer, ok := bindTransferRecipient(c)
+	if !ok {
+		return
+	}
+
+	owner, err := database.GetOwnership(c.Request.Context(), shortCode)
+	if err != nil {
+		respondError(c, notFound(err, "Short URL not found"))
+		return
+	}
+	if owner.OrgID != 0 && !adminOfOrg(c, owner.OrgID) {
+		role, ok := orgRole(c, owner.OrgID)
+		if !ok {
+			return
+		}
+		if role != db.RoleOwner {
+			respondError(c, apierror.Forbidden("Only organization owners can transfer its links"))
+			return
+		}
+	}
+
+	transfer.ShortCode = shortCode
+	transfer.Domain = db.DomainFrom(c.Request.Context())
+	offerTransfer(c, transfer)
+}
+
+// transferCampaign offers a campaign with all of its links to another user or organization
+func transferCampaign(c *gin.Context) {
+	campaign, ok := campaignAccess(c)
+	if !ok {
+		return
+	}
+	transfer, ok := bindTransferRecipient(c)
+	if !ok {
+		return
+	}
+
+	transfer.CampaignID = campaign.ID
+	offerTransfer(c, transfer)
+}
+
+// getTransfers lists the pending transfers the user requested or may accept,
+// or every pending transfer for admins
+func getTransfers(c *gin.Context) {
+	userID := c.GetString(middleware.ContextUserID)
+	if isAdmin(c) {
+		userID = ""
+	} else if userID == "" {
+		respondError(c, apierror.Unauthorized("Authentication required"))
+		return
+	}
+
+	transfers, err := database.GetPendingTransfers(c.Request.Context(), userID)
+	if err != nil {
+		respondError(c, apierror.Internal("Database error").Wrap(err))
+		return
+	}
+	c.JSON(http.StatusOK, transfers)
+}
+
+// pendingTransfer loads the :id transfer, writing an error response and
+// returning false unless it is still awaiting acceptance
+func pendingTransfer(c *gin.Context) (*db.Transfer, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		respondError(c, apierror.NotFound("Transfer not found"))
+		return nil, false
+	}
+	transfer, err := database.GetTransfer(c.Request.Context(), id)
+	if err != nil {
+		respondError(c, notFound(err, "Transfer not found"))
+		return nil, false
+	}
+	if transfer.Status != db.TransferPending {
+		respondError(c, apierror.Conflict("Transfer is already "+transfer.Status))
+		return nil, false
+	}
+	return transfer, true
+}
+
+// isTransferRecipient reports whether the request comes from the user a
+// transfer is offered to or an owner of the organization it is offered to
+func isTransferRecipient(c *gin.Context, transfer *db.Transfer) (bool, error) {
+	userID := c.GetString(middleware.ContextUserID)
+	if userID == "" {
+		return false, nil
+	}
+	if transfer.ToUserID != "" {
+		return transfer.ToUserID == userID, nil
+	}
+	role, err := database.GetMemberRole(c.Request.Context(), transfer.ToOrgID, userID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return false, err
+	}
+	return role == db.RoleOwner, nil
+}
+
+// acceptTransfer gives the link or campaign of a transfer to its recipient,
+// who may be an admin acting for them. The links get a new management token,
+// returned once, so the previous owner's token stops working.
+func acceptTransfer(c *gin.Context) {
+	transfer, ok := pendingTransfer(c)
+	if !ok {
+		return
+	}
+	if !isAdmin(c) {
+		recipient, err := isTransferRecipient(c, transfer)
+		if err != nil {
+			respondError(c, apierror.Internal("Database error").Wrap(err))
+			return
+		}
+		if !recipient {
+			respondError(c, apierror.Forbidden("Only the recipient can accept this transfer"))
+			return
+		}
+	}
+
+	token, tokenHash, err := newManagementToken()
+	if err != nil {
+		respondError(c, apierror.Internal("Failed to generate management token").Wrap(err))
+		return
+	}
+	acceptedBy := c.GetString(middleware.ContextUserID)
+	if acceptedBy == "" {
+		acceptedBy = auditActor(c)
+	}
+	links, err := database.AcceptTransfer(c.Request.Context(), transfer.ID, acceptedBy, tokenHash)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondError(c, apierror.Conflict("Transfer is no longer pending"))
+		return
+	}
+	if err != nil {
+		respondError(c, apierror.Internal("Failed to transfer").Wrap(err))
+		return
+	}
+
+	before := *transfer
+	transfer.Status = db.TransferAccepted
+	transfer.ResolvedBy = acceptedBy
+	recordAudit(c, auditUpdate, "transfer", strconv.Itoa(transfer.ID), before, transfer)
+	recipient := gin.H{"toUserId": transfer.ToUserID, "toOrgId": transfer.ToOrgID}
+	if transfer.CampaignID != 0 {
+		recordAudit(c, auditTransfer, "campaign", strconv.Itoa(transfer.CampaignID), nil, recipient)
+	} else {
+		recordAudit(c, auditTransfer, "url", transfer.ShortCode, nil, recipient)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"transfer": transfer, "links": links, "managementToken": token})
+}
+
+// declineTransfer closes a transfer without moving anything: declined by its
+// recipient, or cancelled by whoever requested it
+func declineTransfer(c *gin.Context) {
+	transfer, ok := pendingTransfer(c)
+	if !ok {
+		return
+	}
+
+	status := db.TransferDeclined
+	actor := c.GetString(middleware.ContextUserID)
+	recipient, err := isTransferRecipient(c, transfer)
+	if err != nil {
+		respondError(c, apierror.Internal("Database error").Wrap(err))
+		return
+	}
+	switch {
+	case recipient:
+	case actor != "" && actor == transfer.RequestedBy, isAdmin(c):
+		status = db.TransferCancelled
+	default:
+		respondError(c, apierror.Forbidden("Only the recipient or requester can decline this transfer"))
+		return
+	}
+	if actor == "" {
+		actor = auditActor(c)
+	}
+
+	if err := database.CloseTransfer(c.Request.Context(), transfer.ID, status, actor); err != nil {
+		respondError(c, notFound(err, "Transfer not found"))
+		return
+	}
+
+	before := *transfer
+	transfer.Status = status
+	transfer.ResolvedBy = actor
+	recordAudit(c, auditUpdate, "transfer", strconv.Itoa(transfer.ID), before, transfer)
+	c.JSON(http.StatusOK, transfer)
+}
@@ -0,0 +1,398 @@
+package main
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/base32"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+	"url-shortener/db"
+	"url-shortener/pkg/apierror"
+	"url-shortener/pkg/totp"
+
+	"github.com/gin-gonic/gin"
+)
+
+// twoFactorCookie holds a session waiting for its second factor
+const twoFactorCookie = "two_factor"
+
+const (
+	// twoFactorTTL is how long a user has to enter their code after signing in
+	twoFactorTTL = 10 * time.Minute
+	// maxTwoFactorAttempts wrong codes end a pending session
+	maxTwoFactorAttempts = 5
+	// recoveryCodeCount recovery codes are issued at a time
+	recoveryCodeCount = 10
+)
+
+// secondFactor is a code from the user's authenticator app or one of their recovery codes
+type secondFactor struct {
+	Code         string `json:"code"`
+	RecoveryCode string `json:"recoveryCode"`
+}
+
+// startSession signs a user in and sends them to redirect. Users with
+// two-factor authentication get a pending session instead, which a code
+// posted to /auth/2fa/verify completes.
+func startSession(c *gin.Context, user *db.User, redirect string) {
+	ctx := c.Request.Context()
+	session := randomToken()
+
+	if user.TwoFactor {
+		if err := database.CreatePendingSession(ctx, hashSecret(session), user.ID, twoFactorTTL); err != nil {
+			respondError(c, apierror.Internal("Failed to start session").Wrap(err))
+			return
+		}
+		c.SetSameSite(http.SameSiteLaxMode)
+		c.SetCookie(twoFactorCookie, session, int(twoFactorTTL.Seconds()), "/auth", "", isSecureRequest(c), true)
+		c.Redirect(http.StatusFound, redirect)
+		return
+	}
+
+	if err := database.CreateSession(ctx, hashSecret(session), user.ID, sessionTTL); err != nil {
+		respondError(c, apierror.Internal("Failed to start session").Wrap(err))
+		return
+	}
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(sessionCookie, session, int(sessionTTL.Seconds()), "/", "", isSecureRequest(c), true)
+	c.Redirect(http.StatusFound, redirect)
+}
+
+// checkSecondFactor reports whether a code or recovery code is valid for a
+// user, spending it so it can't be used again
+func checkSecondFactor(c *gin.Context, userID int, factor secondFactor) (bool, error) {
+	ctx := c.Request.Context()
+	if factor.RecoveryCode != "" {
+		return database.UseRecoveryCode(ctx, userID, hashSecret(normalizeRecoveryCode(factor.RecoveryCode)))
+	}
+
+	enrollment, err := database.GetTOTP(ctx, userID)
+	if err != nil {
+		return false, err
+	}
+	if !enrollment.Enabled {
+		return false, nil
+	}
+	step, ok := totp.Verify(enrollment.Secret, factor.Code, time.Now(), enrollment.LastStep)
+	if !ok {
+		return false, nil
+	}
+	return database.UseTOTPStep(ctx, userID, step)
+}
+
+// newRecoveryCodes generates a set of recovery codes and the hashes stored for them
+func newRecoveryCodes() ([]string, []string, error) {
+	codes := make([]string, recoveryCodeCount)
+	hashes := make([]string, recoveryCodeCount)
+	for i := range codes {
+		b := make([]byte, 10)
+		if _, err := rand.Read(b); err != nil {
+			return nil, nil, err
+		}
+		code := strings.ToLower(base32.StdEncoding.EncodeToString(b))
+		codes[i] = code[:8] + "-" + code[8:]
+		hashes[i] = hashSecret(normalizeRecoveryCode(codes[i]))
+	}
+	return codes, hashes, nil
+}
+
+// normalizeRecoveryCode ignores case, spaces and dashes, as people retype codes
+func normalizeRecoveryCode(code string) string {
+	return strings.NewReplacer("-", "", " ", "").Replace(strings.ToLower(code))
+}
+
+// signedInUser returns the user of the request's session, writing a 401 for
+// other requests: two-factor authentication protects dashboard accounts
+func signedInUser(c *gin.Context) (*db.User, bool) {
+	user, ok := c.Get(contextUser)
+	if !ok {
+		respondError(c, apierror.Unauthorized("Not signed in"))
+		return nil, false
+	}
+	return user.(*db.User), true
+}
+
+// getTwoFactor returns whether the signed-in user has two-factor
+// authentication and how many recovery codes they have left
+func getTwoFactor(c *gin.Context) {
+	user, ok := signedInUser(c)
+	if !ok {
+		return
+	}
+	left, err := database.CountRecoveryCodes(c.Request.Context(), user.ID)
+	if err != nil {
+		respondError(c, apierror.Internal("Database error").Wrap(err))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"enabled": user.TwoFactor, "recoveryCodesLeft": left})
+}
+
+// setupTwoFactor starts enrolling an authenticator app, returning the secret
+// to enter in it and the otpauth URI to show as a QR code
+func setupTwoFactor(c *gin.Context) {
+	user, ok := signedInUser(c)
+	if !ok {
+		return
+	}
+
+	secret, err := totp.NewSecret()
+	if err != nil {
+		respondError(c, apierror.Internal("Failed to generate secret").Wrap(err))
+		return
+	}
+	err = database.StartTOTP(c.Request.Context(), user.ID, secret)
+	if errors.Is(err, db.ErrTwoFactorEnabled) {
+		respondError(c, apierror.Conflict("Two-factor authentication is already enabled"))
+		return
+	}
+	if err != nil {
+		respondError(c, apierror.Internal("Database error").Wrap(err))
+		return
+	}
+
+	account := user.Email
+	if account == "" {
+		account = strconv.Itoa(user.ID)
+	}
+	c.JSON(http.StatusOK, gin.H{"secret": secret, "uri": totp.URI(secret, totpIssuer(c), account)})
+}
+
+// enableTwoFactor completes enrollment with a code from the app and returns
+// the recovery codes, which are only shown here
+func enableTwoFactor(c *gin.Context) {
+	user, ok := signedInUser(c)
+	if !ok {
+		return
+	}
+	var request secondFactor
+	if err := c.ShouldBindJSON(&request); err != nil || request.Code == "" {
+		respondError(c, apierror.Validation("code is required"))
+		return
+	}
+
+	ctx := c.Request.Context()
+	enrollment, err := database.GetTOTP(ctx, user.ID)
+	if err != nil {
+		respondError(c, apierror.Internal("Database error").Wrap(err))
+		return
+	}
+	if enrollment.Enabled {
+		respondError(c, apierror.Conflict("Two-factor authentication is already enabled"))
+		return
+	}
+	if enrollment.Secret == "" {
+		respondError(c, apierror.Validation("Start with POST /auth/2fa/setup"))
+		return
+	}
+	step, ok := totp.Verify(enrollment.Secret, request.Code, time.Now(), enrollment.LastStep)
+	if !ok {
+		respondError(c, apierror.Validation("Invalid code"))
+		return
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		respondError(c, apierror.Internal("Failed to generate recovery codes").Wrap(err))
+		return
+	}
+	err = database.EnableTOTP(ctx, user.ID, step, hashes)
+	if errors.Is(err, db.ErrTwoFactorEnabled) {
+		respondError(c, apierror.Conflict("Two-factor authentication is already enabled"))
+		return
+	}
+	if err != nil {
+		respondError(c, apierror.Internal("Database error").Wrap(err))
+		return
+	}
+
+	recordAudit(c, auditEnable, "user", strconv.Itoa(user.ID), nil, gin.H{"twoFactor": true})
+	c.JSON(http.StatusOK, gin.H{"recoveryCodes": codes})
+}
+
+// disableTwoFactor turns two-factor authentication off; it takes a current
+// code or recovery code so a hijacked session can't do it
+func disableTwoFactor(c *gin.Context) {
+	user, ok := signedInUser(c)
+	if !ok || !requireSecondFactor(c, user) {
+		return
+	}
+
+	if err := database.DisableTOTP(c.Request.Context(), user.ID); err != nil {
+		respondError(c, apierror.Internal("Database error").Wrap(err))
+		return
+	}
+
+	recordAudit(c, auditDisable, "user", strconv.Itoa(user.ID), gin.H{"twoFactor": true}, gin.H{"twoFactor": false})
+	c.JSON(http.StatusOK, gin.H{"message": "Two-factor authentication disabled"})
+}
+
+// regenerateRecoveryCodes replaces the signed-in user's recovery codes
+func regenerateRecoveryCodes(c *gin.Context) {
+	user, ok := signedInUser(c)
+	if !ok || !requireSecondFactor(c, user) {
+		return
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		respondError(c, apierror.Internal("Failed to generate recovery codes").Wrap(err))
+		return
+	}
+	if err := database.SetRecoveryCodes(c.Request.Context(), user.ID, hashes); err != nil {
+		respondError(c, apierror.Internal("Database error").Wrap(err))
+		return
+	}
+
+	recordAudit(c, auditUpdate, "user", strconv.Itoa(user.ID), nil, gin.H{"recoveryCodes": "regenerated"})
+	c.JSON(http.StatusOK, gin.H{"recoveryCodes": codes})
+}
+
+// requireSecondFactor checks the code or recovery code in the request body of
+// a user with two-factor authentication, writing an error if it is wrong
+func requireSecondFactor(c *gin.Context, user *db.User) bool {
+	if !user.TwoFactor {
+		respondError(c, apierror.Conflict("Two-factor authentication is not enabled"))
+		return false
+	}
+	var request secondFactor
+	if err := c.ShouldBindJSON(&request); err != nil || (request.Code == "" && request.RecoveryCode == "") {
+		respondError(c, apierror.Validation("code or recoveryCode is required"))
+		return false
+	}
+
+	valid, err := checkSecondFactor(c, user.ID, request)
+	if err != nil {
+		respondError(c, apierror.Internal("Database error").Wrap(err))
+		return false
+	}
+	if !valid {
+		respondError(c, apierror.Forbidden("Invalid code"))
+		return false
+	}
+	return true
+}
+
+// verifyTwoFactor completes a sign-in waiting for its second factor. Too many
+// wrong codes end the pending session, and the user must sign in again.
+func verifyTwoFactor(c *gin.Context) {
+	session, err := c.Cookie(twoFactorCookie)
+	if err != nil || session == "" {
+		respondError(c, apierror.Unauthorized("Sign in first"))
+		return
+	}
+	var request secondFactor
+	if err := c.ShouldBindJSON(&request); err != nil || (request.Code == "" && request.RecoveryCode == "") {
+		respondError(c, apierror.Validation("code or recoveryCode is required"))
+		return
+	}
+
+	ctx := c.Request.Context()
+	user, err := database.GetPendingSessionUser(ctx, hashSecret(session))
+	if errors.Is(err, sql.ErrNoRows) {
+		c.SetCookie(twoFactorCookie, "", -1, "/auth", "", isSecureRequest(c), true)
+		respondError(c, apierror.Unauthorized("Sign-in expired"))
+		return
+	}
+	if err != nil {
+		respondError(c, apierror.Internal("Database error").Wrap(err))
+		return
+	}
+
+	valid, err := checkSecondFactor(c, user.ID, request)
+	if err != nil {
+		respondError(c, apierror.Internal("Database error").Wrap(err))
+		return
+	}
+	if !valid {
+		ended, err := database.FailSessionTwoFactor(ctx, hashSecret(session), maxTwoFactorAttempts)
+		if err != nil {
+			respondError(c, apierror.Internal("Database error").Wrap(err))
+			return
+		}
+		if ended {
+			c.SetCookie(twoFactorCookie, "", -1, "/auth", "", isSecureRequest(c), true)
+			respondError(c, apierror.Unauthorized("Too many invalid codes; sign in again"))
+			return
+		}
+		respondError(c, apierror.Forbidden("Invalid code"))
+		return
+	}
+
+	if err := database.CompleteSession(ctx, hashSecret(session), sessionTTL); err != nil {
+		respondError(c, notFound(err, "Sign-in expired"))
+		return
+	}
+	c.SetCookie(twoFactorCookie, "", -1, "/auth", "", isSecureRequest(c), true)
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(sessionCookie, session, int(sessionTTL.Seconds()), "/", "", isSecureRequest(c), true)
+	c.JSON(http.StatusOK, user)
+}
+
+// totpIssuer names this service in authenticator apps
+func totpIssuer(c *gin.Context) string {
+	if base, err := url.Parse(publicBaseURL); err == nil && base.Host != "" {
+		return base.Host
+	}
+	return c.Request.Host
+}
+
+// checkOrgTwoFactor returns a 403 error when an organization requires
+// two-factor authentication and the request's session user hasn't enabled
+// it. Users authenticated by JWT are left to their identity provider.
+func checkOrgTwoFactor(c *gin.Context, orgID int) error {
+	user, ok := c.Get(contextUser)
+	if !ok || user.(*db.User).TwoFactor {
+		return nil
+	}
+
+	required, err := database.GetOrgRequiresTwoFactor(c.Request.Context(), orgID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return apierror.Internal("Database error").Wrap(err)
+	}
+	if required {
+		return apierror.Forbidden("This organization requires two-factor authentication")
+	}
+	return nil
+}
+
+// setOrgTwoFactorPolicy requires, or stops requiring, two-factor
+// authentication of an organization's members; only owners may do this
+func setOrgTwoFactorPolicy(c *gin.Context) {
+	orgID, ok := orgIDParam(c)
+	if !ok {
+		return
+	}
+	var request struct {
+		Required *bool `json:"required" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		respondError(c, apierror.Validation("required must be true or false"))
+		return
+	}
+
+	role, ok := orgRole(c, orgID)
+	if !ok {
+		return
+	}
+	if role != db.RoleOwner {
+		respondError(c, apierror.Forbidden("Only owners can change the two-factor policy"))
+		return
+	}
+	// An owner without two-factor authentication would lock themselves out
+	if user, ok := c.Get(contextUser); ok && *request.Required && !user.(*db.User).TwoFactor {
+		respondError(c, apierror.Conflict("Enable two-factor authentication on your account first"))
+		return
+	}
+
+	if err := database.SetOrgRequiresTwoFactor(c.Request.Context(), orgID, *request.Required); err != nil {
+		respondError(c, notFound(err, "Organization not found"))
+		return
+	}
+
+	recordAudit(c, auditUpdate, "organization", strconv.Itoa(orgID), nil, gin.H{"requireTwoFactor": *request.Required})
+	c.JSON(http.StatusOK, gin.H{"requireTwoFactor": *request.Required})
+}
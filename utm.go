@@ -0,0 +1,44 @@
+package main
+
+import (
+	"net/url"
+	"strings"
+)
+
+// utmParams are the campaign tags the create endpoint appends to a destination
+type utmParams struct {
+	Source   string `json:"source"`
+	Medium   string `json:"medium"`
+	Campaign string `json:"campaign"`
+	Term     string `json:"term"`
+	Content  string `json:"content"`
+}
+
+// withUTM sets the given UTM tags on a destination, replacing any the URL
+// already carries so every link of a campaign is tagged the same way. Query
+// parameters are re-encoded in sorted order, keeping the result stable.
+func withUTM(destination string, utm *utmParams) (string, bool) {
+	if utm == nil {
+		return destination, true
+	}
+
+	u, err := url.Parse(destination)
+	if err != nil {
+		return "", false
+	}
+
+	query := u.Query()
+	for key, value := range map[string]string{
+		"utm_source":   utm.Source,
+		"utm_medium":   utm.Medium,
+		"utm_campaign": utm.Campaign,
+		"utm_term":     utm.Term,
+		"utm_content":  utm.Content,
+	} {
+		if value = strings.TrimSpace(value); value != "" {
+			query.Set(key, value)
+		}
+	}
+	u.RawQuery = query.Encode()
+	return u.String(), true
+}
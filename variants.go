@@ -0,0 +1,170 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"hash/fnv"
+	"net/http"
+	"strings"
+	"url-shortener/db"
+	"url-shortener/pkg/apierror"
+
+	"github.com/gin-gonic/gin"
+)
+
+// variantCookiePrefix names the cookie that pins a visitor to the variant they
+// were first assigned, one cookie per link
+const variantCookiePrefix = "ab_"
+
+// variantCookieMaxAge keeps assignments stable for the length of a typical test
+const variantCookieMaxAge = 90 * 24 * 60 * 60
+
+const maxVariantName = 32
+
+// assignVariant picks the variant a visitor is sent to. Returning visitors keep
+// the variant named in their cookie; new visitors are bucketed by a hash of the
+// link and their IP, so the same client lands on the same page even without cookies.
+func assignVariant(c *gin.Context, link *db.URL) (db.Variant, bool) {
+	if len(link.Variants) == 0 {
+		return db.Variant{}, false
+	}
+
+	cookie := variantCookiePrefix + link.ShortCode
+	if name, err := c.Cookie(cookie); err == nil {
+		for _, v := range link.Variants {
+			if v.Name == name && v.Weight > 0 {
+				return v, true
+			}
+		}
+	}
+
+	total := 0
+	for _, v := range link.Variants {
+		total += v.Weight
+	}
+	if total == 0 {
+		return db.Variant{}, false
+	}
+
+	h := fnv.New32a()
+	h.Write([]byte(link.ShortCode + "|" + c.ClientIP()))
+	bucket := int(h.Sum32() % uint32(total))
+
+	var chosen db.Variant
+	for _, v := range link.Variants {
+		if bucket < v.Weight {
+			chosen = v
+			break
+		}
+		bucket -= v.Weight
+	}
+
+	c.SetCookie(cookie, chosen.Name, variantCookieMaxAge, "/", "", isSecureRequest(c), true)
+	return chosen, true
+}
+
+// normalizeVariants validates an A/B split: unique non-empty names, absolute
+// destination URLs, non-negative weights and at least one variant receiving traffic
+func normalizeVariants(variants []db.Variant) ([]db.Variant, bool) {
+	if len(variants) == 0 {
+		return nil, true
+	}
+
+	seen := make(map[string]bool, len(variants))
+	total := 0
+	out := make([]db.Variant, 0, len(variants))
+	for _, v := range variants {
+		v.Name = strings.TrimSpace(v.Name)
+		if v.Name == "" || len(v.Name) > maxVariantName || seen[v.Name] {
+			return nil, false
+		}
+		if !validDestination(v.URL) || v.Weight < 0 {
+			return nil, false
+		}
+		seen[v.Name] = true
+		total += v.Weight
+		out = append(out, v)
+	}
+	if total == 0 {
+		return nil, false
+	}
+	return out, true
+}
+
+// setURLVariants replaces a link's weighted destinations; an empty array ends
+// the test and sends every visitor to the original URL again
+func setURLVariants(c *gin.Context) {
+	shortCode := c.Param("shortCode")
+
+	var request []db.Variant
+	if err := c.ShouldBindJSON(&request); err != nil {
+		respondError(c, apierror.Validation("Invalid request body"))
+		return
+	}
+
+	variants, ok := normalizeVariants(request)
+	if !ok {
+		respondError(c, apierror.Validation("Invalid variant"))
+		return
+	}
+	if !allowedDestinations(c, linkDestinations(&db.URL{Variants: variants})...) {
+		return
+	}
+
+	previous, err := database.GetURLByShortCode(c.Request.Context(), shortCode)
+	if err != nil {
+		respondError(c, notFound(err, "Short URL not found"))
+		return
+	}
+	if variants != nil && previous.Rotation != nil {
+		respondError(c, apierror.Conflict("End the link's rotation before A/B testing it"))
+		return
+	}
+
+	if err := database.SetVariants(c.Request.Context(), shortCode, variants); err != nil {
+		respondError(c, notFound(err, "Short URL not found"))
+		return
+	}
+
+	if variants == nil {
+		variants = []db.Variant{}
+	}
+	recordAudit(c, auditUpdate, "url", shortCode, gin.H{"variants": previous.Variants}, gin.H{"variants": variants})
+	c.JSON(http.StatusOK, gin.H{"message": "Variants updated successfully", "variants": variants})
+}
+
+// recordConversion counts a conversion for the variant a visitor was shown.
+// Landing pages on another domain pass the variant explicitly; otherwise it is
+// read from the assignment cookie.
+func recordConversion(c *gin.Context) {
+	shortCode := c.Param("shortCode")
+
+	var request struct {
+		Variant string `json:"variant"`
+	}
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&request); err != nil {
+			respondError(c, apierror.Validation("Invalid request body"))
+			return
+		}
+	}
+	if request.Variant == "" {
+		request.Variant, _ = c.Cookie(variantCookiePrefix + shortCode)
+	}
+	if request.Variant == "" {
+		respondError(c, apierror.Validation("Variant is required"))
+		return
+	}
+
+	err := database.RecordConversion(c.Request.Context(), shortCode, request.Variant)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondError(c, apierror.NotFound("Variant not found"))
+		return
+	}
+	if err != nil {
+		respondError(c, apierror.Internal("Failed to record conversion").Wrap(err))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Conversion recorded"})
+}
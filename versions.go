@@ -0,0 +1,74 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+	"url-shortener/pkg/apierror"
+
+	"github.com/gin-gonic/gin"
+)
+
+// getURLHistory lists the destinations a link has pointed to, newest first
+func getURLHistory(c *gin.Context) {
+	shortCode := c.Param("shortCode")
+
+	if _, err := database.GetURLByShortCode(c.Request.Context(), shortCode); err != nil {
+		respondError(c, apierror.NotFound("Short URL not found"))
+		return
+	}
+
+	versions, err := database.GetURLVersions(c.Request.Context(), shortCode)
+	if err != nil {
+		respondError(c, apierror.Internal("Database error").Wrap(err))
+		return
+	}
+
+	c.JSON(http.StatusOK, versions)
+}
+
+// rollbackURL points a link back at the destination of an earlier version.
+// The rollback itself becomes the newest version, so it can be undone too.
+func rollbackURL(c *gin.Context) {
+	shortCode := c.Param("shortCode")
+
+	versionID, err := strconv.ParseInt(c.Param("versionId"), 10, 64)
+	if err != nil {
+		respondError(c, apierror.NotFound("Version not found"))
+		return
+	}
+
+	previous, err := database.GetURLByShortCode(c.Request.Context(), shortCode)
+	if err != nil {
+		respondError(c, notFound(err, "Short URL not found"))
+		return
+	}
+
+	original, err := database.GetURLVersionDestination(c.Request.Context(), shortCode, versionID)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondError(c, apierror.NotFound("Version not found"))
+		return
+	}
+	if err != nil {
+		respondError(c, apierror.Internal("Database error").Wrap(err))
+		return
+	}
+	// Versions from before destinations had to be http(s) are not restored
+	if !validDestination(original) {
+		respondError(c, apierror.Unprocessable("This version's destination is not an http or https URL"))
+		return
+	}
+	if !allowedDestinations(c, original) {
+		return
+	}
+
+	if err := database.UpdateURL(c.Request.Context(), shortCode, original, auditActor(c)); err != nil {
+		respondError(c, notFound(err, "Short URL not found"))
+		return
+	}
+
+	recordAudit(c, auditRollback, "url", shortCode,
+		gin.H{"original": previous.OriginalURL}, gin.H{"original": original, "versionId": versionID})
+	c.JSON(http.StatusOK, gin.H{"message": "URL rolled back successfully", "original": original})
+}
@@ -0,0 +1,220 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+	"url-shortener/db"
+	"url-shortener/pkg/apierror"
+	"url-shortener/pkg/warehouse"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// warehouseExportLag is how long after midnight UTC a day is exported, so
+	// clicks still being recorded make it in
+	warehouseExportLag = time.Hour
+	// maxExportDays bounds how many days one run exports per organization,
+	// so a long backfill doesn't hold up the others
+	maxExportDays = 31
+	// maxBackfillDays bounds how far back an export can start
+	maxBackfillDays = 366
+)
+
+// warehouseExportsEnabled is set when WAREHOUSE_EXPORT_INTERVAL schedules the export job
+var warehouseExportsEnabled bool
+
+// warehouseWriter returns the writer of an organization's export. Parquet
+// files without a bucket of the organization's own go to the configured
+// object store, under a prefix of its own.
+func warehouseWriter(export *db.WarehouseExport) (warehouse.Writer, error) {
+	return warehouse.New(export.Destination, export.Settings, export.Credentials, objectStore, "warehouse/org-"+strconv.Itoa(export.OrgID))
+}
+
+// lastExportableDay is the latest day whose clicks are complete at now
+func lastExportableDay(now time.Time) time.Time {
+	return now.UTC().Add(-warehouseExportLag).Truncate(24*time.Hour).AddDate(0, 0, -1)
+}
+
+// exportClicks writes the daily click aggregates of every organization with
+// an export, from the day after its last export through yesterday. It is
+// scheduled every WAREHOUSE_EXPORT_INTERVAL; enable it on a single instance only.
+func exportClicks(ctx context.Context) error {
+	through := lastExportableDay(time.Now())
+	exports, err := database.DueWarehouseExports(ctx, through)
+	if err != nil {
+		return err
+	}
+
+	var failed []error
+	for i := range exports {
+		if err := exportOrgClicks(ctx, &exports[i], through); err != nil {
+			log.Printf("Warehouse export failed: %v", err)
+			failed = append(failed, err)
+		}
+	}
+	return errors.Join(failed...)
+}
+
+// exportOrgClicks writes an organization's days up to through, recording each
+// one exported so that a failed day is retried on the next run
+func exportOrgClicks(ctx context.Context, export *db.WarehouseExport, through time.Time) error {
+	fail := func(err error) error {
+		if markErr := database.MarkWarehouseExport(ctx, export.OrgID, time.Time{}, err.Error()); markErr != nil {
+			log.Printf("Failed to record warehouse export error of organization %d: %v", export.OrgID, markErr)
+		}
+		return fmt.Errorf("organization %d: %w", export.OrgID, err)
+	}
+
+	writer, err := warehouseWriter(export)
+	if err != nil {
+		return fail(err)
+	}
+	day := through
+	if export.ExportedThrough != nil {
+		day = export.ExportedThrough.UTC().AddDate(0, 0, 1)
+	}
+	for n := 0; n < maxExportDays && !day.After(through); n++ {
+		rows, err := database.GetClickAggregates(ctx, export.OrgID, day)
+		if err == nil {
+			err = writer.Write(ctx, day, rows)
+		}
+		if err != nil {
+			return fail(fmt.Errorf("%s: %w", day.Format(time.DateOnly), err))
+		}
+		if err := database.MarkWarehouseExport(ctx, export.OrgID, day, ""); err != nil {
+			return err
+		}
+		day = day.AddDate(0, 0, 1)
+	}
+	return nil
+}
+
+// warehouseOwner returns the :orgId organization, writing an error unless
+// exports are enabled and the caller owns it
+func warehouseOwner(c *gin.Context) (int, bool) {
+	if !warehouseExportsEnabled {
+		respondError(c, apierror.NotFound("Warehouse exports are not enabled"))
+		return 0, false
+	}
+	orgID, ok := orgIDParam(c)
+	if !ok {
+		return 0, false
+	}
+	role, ok := orgRole(c, orgID)
+	if !ok {
+		return 0, false
+	}
+	if role != db.RoleOwner {
+		respondError(c, apierror.Forbidden("Only owners can manage warehouse exports"))
+		return 0, false
+	}
+	return orgID, true
+}
+
+// getOrgWarehouseExport returns where an organization's clicks are exported
+// to and how far the export got, without its credentials
+func getOrgWarehouseExport(c *gin.Context) {
+	orgID, ok := warehouseOwner(c)
+	if !ok {
+		return
+	}
+
+	export, err := database.GetWarehouseExport(c.Request.Context(), orgID)
+	if err != nil {
+		respondError(c, notFound(err, "This organization has no warehouse export"))
+		return
+	}
+	c.JSON(http.StatusOK, export)
+}
+
+// setOrgWarehouseExport starts or changes the daily export of an
+// organization's click aggregates. Without new credentials the stored ones
+// are kept. backfillFrom restarts the export at an earlier day.
+func setOrgWarehouseExport(c *gin.Context) {
+	orgID, ok := warehouseOwner(c)
+	if !ok {
+		return
+	}
+
+	var request struct {
+		Destination  string            `json:"destination" binding:"required,oneof=parquet bigquery snowflake"`
+		Settings     map[string]string `json:"settings"`
+		Credentials  string            `json:"credentials"`
+		BackfillFrom string            `json:"backfillFrom"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		respondError(c, apierror.Validation("destination must be parquet, bigquery or snowflake"))
+		return
+	}
+	var from *time.Time
+	if request.BackfillFrom != "" {
+		day, err := time.Parse(time.DateOnly, request.BackfillFrom)
+		last := lastExportableDay(time.Now())
+		if err != nil || day.After(last.AddDate(0, 0, 1)) || day.Before(last.AddDate(0, 0, -maxBackfillDays)) {
+			respondError(c, apierror.Validation("backfillFrom must be a date (YYYY-MM-DD) within the last "+strconv.Itoa(maxBackfillDays)+" days"))
+			return
+		}
+		from = &day
+	}
+
+	ctx := c.Request.Context()
+	before, err := database.GetWarehouseExport(ctx, orgID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		respondError(c, apierror.Internal("Database error").Wrap(err))
+		return
+	}
+
+	export := db.WarehouseExport{
+		OrgID:       orgID,
+		Destination: request.Destination,
+		Settings:    request.Settings,
+		Credentials: request.Credentials,
+	}
+	if export.Settings == nil {
+		export.Settings = map[string]string{}
+	}
+	check := export
+	if check.Credentials == "" && before != nil {
+		check.Credentials = before.Credentials
+	}
+	if _, err := warehouseWriter(&check); err != nil {
+		respondError(c, apierror.Validation("Invalid export: "+err.Error()))
+		return
+	}
+
+	if err := database.SetWarehouseExport(ctx, &export, from); err != nil {
+		respondError(c, apierror.Internal("Failed to store warehouse export").Wrap(err))
+		return
+	}
+
+	if before != nil {
+		recordAudit(c, auditUpdate, "warehouse_export", strconv.Itoa(orgID), before, export)
+	} else {
+		recordAudit(c, auditCreate, "warehouse_export", strconv.Itoa(orgID), nil, export)
+	}
+	c.JSON(http.StatusOK, export)
+}
+
+// deleteOrgWarehouseExport stops exporting an organization's clicks and
+// forgets its credentials. Data already exported stays where it is.
+func deleteOrgWarehouseExport(c *gin.Context) {
+	orgID, ok := warehouseOwner(c)
+	if !ok {
+		return
+	}
+
+	if err := database.DeleteWarehouseExport(c.Request.Context(), orgID); err != nil {
+		respondError(c, notFound(err, "This organization has no warehouse export"))
+		return
+	}
+
+	recordAudit(c, auditDelete, "warehouse_export", strconv.Itoa(orgID), nil, nil)
+	c.JSON(http.StatusOK, gin.H{"message": "Warehouse export removed"})
+}